@@ -0,0 +1,110 @@
+package achievements
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu          sync.Mutex
+	ruleMap     map[string]RuleStoreModel
+	progressMap map[string]map[string]ProgressStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		ruleMap:     make(map[string]RuleStoreModel),
+		progressMap: make(map[string]map[string]ProgressStoreModel),
+	}
+}
+
+func (m *MemStore) CreateRule(ctx context.Context, model *RuleStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.ruleMap[model.ID]; ok {
+		return ErrRuleExists
+	}
+	m.ruleMap[model.ID] = *model
+	return nil
+}
+
+func (m *MemStore) GetRule(ctx context.Context, id string) (*RuleStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rule, ok := m.ruleMap[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rule, nil
+}
+
+func (m *MemStore) ListRules(ctx context.Context) ([]RuleStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]RuleStoreModel, 0, len(m.ruleMap))
+	for _, rule := range m.ruleMap {
+		res = append(res, rule)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+func (m *MemStore) DeleteRule(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.ruleMap[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.ruleMap, id)
+	for _, progress := range m.progressMap {
+		delete(progress, id)
+	}
+	return nil
+}
+
+func (m *MemStore) ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]ProgressStoreModel, 0)
+	for _, p := range m.progressMap[clientID] {
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func (m *MemStore) UpdateProgress(ctx context.Context, clientID, ruleID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.progressMap[clientID]; !ok {
+		m.progressMap[clientID] = make(map[string]ProgressStoreModel)
+	}
+	p, ok := m.progressMap[clientID][ruleID]
+	if !ok {
+		id, _ := uuid.Parse(clientID)
+		p = ProgressStoreModel{ClientID: id, RuleID: ruleID}
+	}
+	// fn works on a copy so a failing update leaves the progress untouched
+	p.Keys = append(pq.StringArray(nil), p.Keys...)
+	if err := fn(&p); err != nil {
+		return nil, err
+	}
+	m.progressMap[clientID][ruleID] = p
+	return &p, nil
+}
+
+func (m *MemStore) DeleteRuleProgress(ctx context.Context, ruleID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, progress := range m.progressMap {
+		delete(progress, ruleID)
+	}
+	return nil
+}
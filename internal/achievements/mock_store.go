@@ -0,0 +1,73 @@
+package achievements
+
+import "context"
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateRuleFunc         func(model *RuleStoreModel) error
+	GetRuleFunc            func(id string) (*RuleStoreModel, error)
+	ListRulesFunc          func() ([]RuleStoreModel, error)
+	DeleteRuleFunc         func(id string) error
+	ListProgressFunc       func(clientID string) ([]ProgressStoreModel, error)
+	UpdateProgressFunc     func(clientID, ruleID string, fn ProgressFunc) (*ProgressStoreModel, error)
+	DeleteRuleProgressFunc func(ruleID string) error
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateRuleFunc: func(model *RuleStoreModel) error {
+			return nil
+		},
+		GetRuleFunc: func(id string) (*RuleStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListRulesFunc: func() ([]RuleStoreModel, error) {
+			return []RuleStoreModel{}, nil
+		},
+		DeleteRuleFunc: func(id string) error {
+			return nil
+		},
+		ListProgressFunc: func(clientID string) ([]ProgressStoreModel, error) {
+			return []ProgressStoreModel{}, nil
+		},
+		UpdateProgressFunc: func(clientID, ruleID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+			p := ProgressStoreModel{RuleID: ruleID}
+			if err := fn(&p); err != nil {
+				return nil, err
+			}
+			return &p, nil
+		},
+		DeleteRuleProgressFunc: func(ruleID string) error {
+			return nil
+		},
+	}
+}
+
+func (m *MockStore) CreateRule(ctx context.Context, model *RuleStoreModel) error {
+	return m.CreateRuleFunc(model)
+}
+
+func (m *MockStore) GetRule(ctx context.Context, id string) (*RuleStoreModel, error) {
+	return m.GetRuleFunc(id)
+}
+
+func (m *MockStore) ListRules(ctx context.Context) ([]RuleStoreModel, error) {
+	return m.ListRulesFunc()
+}
+
+func (m *MockStore) DeleteRule(ctx context.Context, id string) error {
+	return m.DeleteRuleFunc(id)
+}
+
+func (m *MockStore) ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error) {
+	return m.ListProgressFunc(clientID)
+}
+
+func (m *MockStore) UpdateProgress(ctx context.Context, clientID, ruleID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+	return m.UpdateProgressFunc(clientID, ruleID, fn)
+}
+
+func (m *MockStore) DeleteRuleProgress(ctx context.Context, ruleID string) error {
+	return m.DeleteRuleProgressFunc(ruleID)
+}
@@ -0,0 +1,126 @@
+package achievements
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"geogame/internal/locations"
+)
+
+type EventType string
+
+const (
+	EventCheckIn EventType = "checkin"
+	EventMove    EventType = "move"
+)
+
+type Metric string
+
+const (
+	// MetricCount counts the matching events
+	MetricCount Metric = "count"
+	// MetricDistinctLocations counts the different locations of the matching events
+	MetricDistinctLocations Metric = "distinct_locations"
+	// MetricDistinctRegions counts the different grid cells of RegionSize degrees of the matching events
+	MetricDistinctRegions Metric = "distinct_regions"
+	// MetricDistance sums the meters moved
+	MetricDistance Metric = "distance"
+)
+
+type Filter struct {
+	LocationType string   `json:"locationType,omitempty"`
+	LocationIDs  []string `json:"locationIds,omitempty"`
+}
+
+// Criteria is the declarative part of a rule, the achievement unlocks when the metric reaches the target
+type Criteria struct {
+	Event      EventType `json:"event"`
+	Metric     Metric    `json:"metric"`
+	Filter     Filter    `json:"filter"`
+	Target     float64   `json:"target"`
+	RegionSize float64   `json:"regionSize,omitempty"`
+}
+
+// Value enables serialization to SQL
+func (c Criteria) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan enables deserialization from SQL
+func (c *Criteria) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return fmt.Errorf("unsupported criteria type %T", src)
+}
+
+type RuleStoreModel struct {
+	ID          string    `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	Criteria    Criteria  `db:"criteria" json:"criteria"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+type ProgressStoreModel struct {
+	ClientID uuid.UUID `db:"client_id"`
+	RuleID   string    `db:"rule_id"`
+	Value    float64   `db:"value"`
+	// Keys are the locations or regions already counted by the distinct metrics
+	Keys       pq.StringArray `db:"keys"`
+	UnlockedAt sql.NullTime   `db:"unlocked_at"`
+	UpdatedAt  time.Time      `db:"updated_at"`
+}
+
+// Event is a player activity the rules are evaluated against
+type Event struct {
+	Type         EventType
+	ClientID     string
+	LocationID   string
+	LocationType string
+	GeoPoint     locations.GeoPoint
+	// Distance in meters of a move event
+	Distance float64
+	At       time.Time
+}
+
+type Achievement struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Value       float64    `json:"value"`
+	Target      float64    `json:"target"`
+	UnlockedAt  *time.Time `json:"unlockedAt,omitempty"`
+}
+
+type BackfillResult struct {
+	Events   int `json:"events"`
+	Players  int `json:"players"`
+	Unlocked int `json:"unlocked"`
+}
+
+func toAchievement(rule *RuleStoreModel, p *ProgressStoreModel) Achievement {
+	a := Achievement{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Target:      rule.Criteria.Target,
+	}
+	if p != nil {
+		a.Value = p.Value
+		if p.UnlockedAt.Valid {
+			unlockedAt := p.UnlockedAt.Time
+			a.UnlockedAt = &unlockedAt
+		}
+	}
+	return a
+}
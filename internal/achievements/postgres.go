@@ -0,0 +1,156 @@
+package achievements
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	rulesAllCols    = "id, name, description, criteria, created_at"
+	rulesTable      = "achievement_rules"
+	progressAllCols = "client_id, rule_id, value, keys, unlocked_at, updated_at"
+	progressTable   = "achievement_progress"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.achievements.store"),
+	}
+}
+
+func (p Postgres) CreateRule(ctx context.Context, model *RuleStoreModel) error {
+	stmt := `INSERT INTO achievement_rules (
+	id,
+	name,
+	description,
+	criteria,
+	created_at
+	) VALUES (
+	:id,
+	:name,
+	:description,
+	:criteria,
+	:created_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok {
+			if pgErr.Code == "23505" {
+				return ErrRuleExists
+			}
+		}
+		p.logger.Error("CreateRule: failed to insert rule to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) GetRule(ctx context.Context, id string) (*RuleStoreModel, error) {
+	stmt := "SELECT " + rulesAllCols + " FROM " + rulesTable + " WHERE id=$1"
+	var rule RuleStoreModel
+	if err := p.db.GetContext(ctx, &rule, stmt, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetRule: failed to get rule from db", zap.Error(err))
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (p Postgres) ListRules(ctx context.Context) ([]RuleStoreModel, error) {
+	stmt := "SELECT " + rulesAllCols + " FROM " + rulesTable + " ORDER BY id"
+	res := make([]RuleStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("ListRules: failed to list rules from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) DeleteRule(ctx context.Context, id string) error {
+	stmt := "DELETE FROM " + rulesTable + " WHERE id=$1"
+	res, err := p.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		p.logger.Error("DeleteRule: failed to delete rule from db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error) {
+	stmt := "SELECT " + progressAllCols + " FROM " + progressTable + " WHERE client_id=$1"
+	res := make([]ProgressStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID); err != nil {
+		p.logger.Error("ListProgress: failed to list progress from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateProgress creates the missing progress row and locks it with FOR UPDATE
+func (p Postgres) UpdateProgress(ctx context.Context, clientID, ruleID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("UpdateProgress: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	insert := `INSERT INTO achievement_progress (client_id, rule_id, value, keys, updated_at)
+	VALUES ($1, $2, 0, '{}', now())
+	ON CONFLICT (client_id, rule_id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, insert, clientID, ruleID); err != nil {
+		p.logger.Error("UpdateProgress: failed to insert progress", zap.Error(err))
+		return nil, err
+	}
+	var progress ProgressStoreModel
+	stmt := "SELECT " + progressAllCols + " FROM " + progressTable + " WHERE client_id=$1 AND rule_id=$2 FOR UPDATE"
+	if err := tx.GetContext(ctx, &progress, stmt, clientID, ruleID); err != nil {
+		p.logger.Error("UpdateProgress: failed to lock progress", zap.Error(err))
+		return nil, err
+	}
+	if err := fn(&progress); err != nil {
+		return nil, err
+	}
+	update := `UPDATE achievement_progress SET
+	value=:value,
+	keys=:keys,
+	unlocked_at=:unlocked_at,
+	updated_at=:updated_at
+	WHERE client_id=:client_id AND rule_id=:rule_id
+	`
+	if _, err := tx.NamedExecContext(ctx, update, progress); err != nil {
+		p.logger.Error("UpdateProgress: failed to update progress", zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+func (p Postgres) DeleteRuleProgress(ctx context.Context, ruleID string) error {
+	stmt := "DELETE FROM " + progressTable + " WHERE rule_id=$1"
+	if _, err := p.db.ExecContext(ctx, stmt, ruleID); err != nil {
+		p.logger.Error("DeleteRuleProgress: failed to delete progress from db", zap.Error(err))
+		return err
+	}
+	return nil
+}
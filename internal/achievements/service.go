@@ -0,0 +1,368 @@
+package achievements
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/players"
+)
+
+var (
+	ErrInvalidRule          = errors.New("invalid achievement rule")
+	ErrRuleNotFound         = errors.New("achievement rule not found")
+	ErrBackfillNotSupported = errors.New("movement is not kept in the history, move rules can not be backfilled")
+)
+
+type Service interface {
+	CreateRule(ctx context.Context, rule RuleStoreModel) (*RuleStoreModel, error)
+	ListRules(ctx context.Context) ([]RuleStoreModel, error)
+	DeleteRule(ctx context.Context, id string) error
+	Backfill(ctx context.Context, id string) (*BackfillResult, error)
+	Earned(ctx context.Context, clientID string) ([]Achievement, error)
+	InProgress(ctx context.Context, clientID string) ([]Achievement, error)
+	checkins.Listener
+	players.LocationListener
+}
+
+// History replays the past check-ins for a backfill
+type History interface {
+	Replay(ctx context.Context, until time.Time, fn func(checkins.CheckIn) error) error
+}
+
+type Config struct {
+	// MaxSpeed in meters per second, faster moves are not counted by the distance rules
+	MaxSpeed float64 `env:"ACHIEVEMENT_MAX_SPEED" envDefault:"12"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxSpeed: 12,
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	history   History
+	config    Config
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, history History, config Config, dbTimeOut time.Duration) *DefaultService {
+	return &DefaultService{
+		logger:    logger,
+		store:     store,
+		history:   history,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+}
+
+func (d *DefaultService) CreateRule(ctx context.Context, rule RuleStoreModel) (*RuleStoreModel, error) {
+	if err := validate(&rule); err != nil {
+		return nil, err
+	}
+	rule.CreatedAt = time.Now().UTC()
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateRule(dbCtx, &rule); err != nil {
+		if err == ErrRuleExists {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRule, err.Error())
+		}
+		d.logger.Error("CreateRule: failed to create rule", zap.String("ruleID", rule.ID), zap.Error(err))
+		return nil, errors.New("failed to create rule:" + err.Error())
+	}
+	return &rule, nil
+}
+
+func validate(rule *RuleStoreModel) error {
+	if strings.TrimSpace(rule.ID) == "" || strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("%w: id and name are required", ErrInvalidRule)
+	}
+	c := &rule.Criteria
+	switch c.Event {
+	case EventCheckIn:
+		if c.Metric == MetricDistance {
+			return fmt.Errorf("%w: check-in rules can not measure a distance", ErrInvalidRule)
+		}
+	case EventMove:
+		if c.Metric != MetricDistance {
+			return fmt.Errorf("%w: move rules measure the distance", ErrInvalidRule)
+		}
+		if c.Filter.LocationType != "" || len(c.Filter.LocationIDs) > 0 {
+			return fmt.Errorf("%w: move rules can not be filtered by location", ErrInvalidRule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown event %q", ErrInvalidRule, c.Event)
+	}
+	switch c.Metric {
+	case MetricCount, MetricDistinctLocations, MetricDistance:
+	case MetricDistinctRegions:
+		if c.RegionSize < 0 {
+			return fmt.Errorf("%w: negative region size", ErrInvalidRule)
+		}
+		if c.RegionSize == 0 {
+			c.RegionSize = 1
+		}
+	default:
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalidRule, c.Metric)
+	}
+	if c.Target <= 0 {
+		return fmt.Errorf("%w: target has to be positive", ErrInvalidRule)
+	}
+	return nil
+}
+
+func (d *DefaultService) ListRules(ctx context.Context) ([]RuleStoreModel, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	res, err := d.store.ListRules(dbCtx)
+	if err != nil {
+		d.logger.Error("ListRules: failed to list rules", zap.Error(err))
+		return nil, errors.New("failed to list rules:" + err.Error())
+	}
+	return res, nil
+}
+
+func (d *DefaultService) DeleteRule(ctx context.Context, id string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.DeleteRule(dbCtx, id); err != nil {
+		if err == ErrNotFound {
+			return ErrRuleNotFound
+		}
+		d.logger.Error("DeleteRule: failed to delete rule", zap.String("ruleID", id), zap.Error(err))
+		return errors.New("failed to delete rule:" + err.Error())
+	}
+	return nil
+}
+
+// Backfill rebuilds the progress of the rule from the check-in history
+func (d *DefaultService) Backfill(ctx context.Context, id string) (*BackfillResult, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	rule, err := d.store.GetRule(dbCtx, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrRuleNotFound
+		}
+		d.logger.Error("Backfill: failed to get rule", zap.String("ruleID", id), zap.Error(err))
+		return nil, errors.New("failed to backfill rule:" + err.Error())
+	}
+	if rule.Criteria.Event != EventCheckIn {
+		return nil, ErrBackfillNotSupported
+	}
+	// later check-ins are evaluated by the listener
+	until := time.Now().UTC()
+	if err := d.store.DeleteRuleProgress(dbCtx, id); err != nil {
+		d.logger.Error("Backfill: failed to reset progress", zap.String("ruleID", id), zap.Error(err))
+		return nil, errors.New("failed to backfill rule:" + err.Error())
+	}
+	res := &BackfillResult{}
+	seen := make(map[string]bool)
+	err = d.history.Replay(ctx, until, func(c checkins.CheckIn) error {
+		e := checkInEvent(c)
+		if !matches(rule, &e) {
+			return nil
+		}
+		res.Events++
+		if !seen[e.ClientID] {
+			seen[e.ClientID] = true
+			res.Players++
+		}
+		unlocked, err := d.apply(ctx, rule, &e)
+		if unlocked {
+			res.Unlocked++
+		}
+		return err
+	})
+	if err != nil {
+		d.logger.Error("Backfill: failed to replay history", zap.String("ruleID", id), zap.Error(err))
+		return nil, errors.New("failed to backfill rule:" + err.Error())
+	}
+	d.logger.Info("Backfill: rule backfilled", zap.String("ruleID", id), zap.Int("events", res.Events), zap.Int("unlocked", res.Unlocked))
+	return res, nil
+}
+
+// Earned returns the unlocked achievements of the player
+func (d *DefaultService) Earned(ctx context.Context, clientID string) ([]Achievement, error) {
+	return d.achievements(ctx, clientID, true)
+}
+
+// InProgress returns the locked achievements of the player with the current progress
+func (d *DefaultService) InProgress(ctx context.Context, clientID string) ([]Achievement, error) {
+	return d.achievements(ctx, clientID, false)
+}
+
+func (d *DefaultService) achievements(ctx context.Context, clientID string, unlocked bool) ([]Achievement, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("achievements: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	rules, err := d.ListRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	progress, err := d.store.ListProgress(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("achievements: failed to list progress", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list achievements:" + err.Error())
+	}
+	byRule := make(map[string]*ProgressStoreModel, len(progress))
+	for i := range progress {
+		byRule[progress[i].RuleID] = &progress[i]
+	}
+	res := make([]Achievement, 0)
+	for i := range rules {
+		a := toAchievement(&rules[i], byRule[rules[i].ID])
+		if (a.UnlockedAt != nil) == unlocked {
+			res = append(res, a)
+		}
+	}
+	return res, nil
+}
+
+// OnCheckIn evaluates the check-in rules
+func (d *DefaultService) OnCheckIn(ctx context.Context, checkIn checkins.CheckIn) error {
+	return d.handle(ctx, checkInEvent(checkIn))
+}
+
+// OnLocationUpdate evaluates the move rules, moves faster than the max speed are ignored
+func (d *DefaultService) OnLocationUpdate(ctx context.Context, update players.LocationUpdate) error {
+	if update.Previous == nil {
+		return nil
+	}
+	elapsed := update.At.Sub(update.PreviousAt).Seconds()
+	distance := geo.Distance(
+		orb.Point{update.Previous.Longitude, update.Previous.Latitude},
+		orb.Point{update.GeoPoint.Longitude, update.GeoPoint.Latitude},
+	)
+	if distance == 0 || elapsed <= 0 || distance/elapsed > d.config.MaxSpeed {
+		return nil
+	}
+	return d.handle(ctx, Event{
+		Type:     EventMove,
+		ClientID: update.ClientID,
+		GeoPoint: update.GeoPoint,
+		Distance: distance,
+		At:       update.At,
+	})
+}
+
+func (d *DefaultService) handle(ctx context.Context, e Event) error {
+	rules, err := d.ListRules(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		if !matches(&rules[i], &e) {
+			continue
+		}
+		if _, err := d.apply(ctx, &rules[i], &e); err != nil {
+			d.logger.Error("handle: failed to update progress", zap.String("clientID", e.ClientID), zap.String("ruleID", rules[i].ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// apply adds the event to the progress of the player, it returns true when the event unlocked the achievement
+func (d *DefaultService) apply(ctx context.Context, rule *RuleStoreModel, e *Event) (bool, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	unlocked := false
+	_, err := d.store.UpdateProgress(dbCtx, e.ClientID, rule.ID, func(p *ProgressStoreModel) error {
+		unlocked = evaluate(&rule.Criteria, p, e)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if unlocked {
+		d.logger.Info("apply: achievement unlocked", zap.String("clientID", e.ClientID), zap.String("ruleID", rule.ID))
+	}
+	return unlocked, nil
+}
+
+// evaluate updates the progress incrementally, it returns true when the target was reached
+func evaluate(c *Criteria, p *ProgressStoreModel, e *Event) bool {
+	if p.UnlockedAt.Valid {
+		return false
+	}
+	switch c.Metric {
+	case MetricCount:
+		p.Value++
+	case MetricDistance:
+		p.Value += e.Distance
+	case MetricDistinctLocations, MetricDistinctRegions:
+		key := e.LocationID
+		if c.Metric == MetricDistinctRegions {
+			key = region(e, c.RegionSize)
+		}
+		for _, k := range p.Keys {
+			if k == key {
+				return false
+			}
+		}
+		p.Keys = append(p.Keys, key)
+		p.Value = float64(len(p.Keys))
+	}
+	p.UpdatedAt = e.At
+	if p.Value < c.Target {
+		return false
+	}
+	p.UnlockedAt = sql.NullTime{Time: e.At, Valid: true}
+	return true
+}
+
+func matches(rule *RuleStoreModel, e *Event) bool {
+	c := &rule.Criteria
+	if c.Event != e.Type {
+		return false
+	}
+	if c.Filter.LocationType != "" && !strings.EqualFold(c.Filter.LocationType, e.LocationType) {
+		return false
+	}
+	if len(c.Filter.LocationIDs) > 0 {
+		for _, id := range c.Filter.LocationIDs {
+			if id == e.LocationID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// region returns the key of the grid cell of the given size in degrees
+func region(e *Event, size float64) string {
+	if size <= 0 {
+		size = 1
+	}
+	return fmt.Sprintf("%d:%d", int(math.Floor(e.GeoPoint.Latitude/size)), int(math.Floor(e.GeoPoint.Longitude/size)))
+}
+
+func checkInEvent(c checkins.CheckIn) Event {
+	return Event{
+		Type:         EventCheckIn,
+		ClientID:     c.ClientID,
+		LocationID:   c.LocationID,
+		LocationType: c.LocationType,
+		GeoPoint:     c.GeoPoint,
+		At:           c.CreatedAt,
+	}
+}
@@ -0,0 +1,155 @@
+package achievements
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+type historyFunc func(until time.Time, fn func(checkins.CheckIn) error) error
+
+func (f historyFunc) Replay(ctx context.Context, until time.Time, fn func(checkins.CheckIn) error) error {
+	return f(until, fn)
+}
+
+func checkIn(clientID, locationID, locationType string, lon, lat float64) checkins.CheckIn {
+	return checkins.CheckIn{
+		ClientID:     clientID,
+		LocationID:   locationID,
+		LocationType: locationType,
+		GeoPoint:     locations.GeoPoint{Longitude: lon, Latitude: lat},
+		CreatedAt:    time.Now().UTC(),
+	}
+}
+
+func TestDefaultService_CreateRule(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), nil, DefaultConfig(), time.Second)
+	tests := []struct {
+		name    string
+		rule    RuleStoreModel
+		wantErr bool
+	}{
+		{name: "stations", rule: RuleStoreModel{ID: "stations", Name: "Stations", Criteria: Criteria{Event: EventCheckIn, Metric: MetricDistinctLocations, Filter: Filter{LocationType: "Station"}, Target: 10}}},
+		{name: "walker", rule: RuleStoreModel{ID: "walker", Name: "Walker", Criteria: Criteria{Event: EventMove, Metric: MetricDistance, Target: 100000}}},
+		{name: "duplicate", rule: RuleStoreModel{ID: "walker", Name: "Walker", Criteria: Criteria{Event: EventMove, Metric: MetricDistance, Target: 100000}}, wantErr: true},
+		{name: "no id", rule: RuleStoreModel{Name: "Walker", Criteria: Criteria{Event: EventMove, Metric: MetricDistance, Target: 1}}, wantErr: true},
+		{name: "unknown event", rule: RuleStoreModel{ID: "a", Name: "A", Criteria: Criteria{Event: "jump", Metric: MetricCount, Target: 1}}, wantErr: true},
+		{name: "unknown metric", rule: RuleStoreModel{ID: "a", Name: "A", Criteria: Criteria{Event: EventCheckIn, Metric: "speed", Target: 1}}, wantErr: true},
+		{name: "check-in distance", rule: RuleStoreModel{ID: "a", Name: "A", Criteria: Criteria{Event: EventCheckIn, Metric: MetricDistance, Target: 1}}, wantErr: true},
+		{name: "move filter", rule: RuleStoreModel{ID: "a", Name: "A", Criteria: Criteria{Event: EventMove, Metric: MetricDistance, Filter: Filter{LocationType: "city"}, Target: 1}}, wantErr: true},
+		{name: "no target", rule: RuleStoreModel{ID: "a", Name: "A", Criteria: Criteria{Event: EventCheckIn, Metric: MetricCount}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.CreateRule(context.TODO(), tt.rule)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestDefaultService_CheckInRules(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), nil, DefaultConfig(), time.Second)
+	_, err := d.CreateRule(context.TODO(), RuleStoreModel{ID: "stations", Name: "Stations", Criteria: Criteria{Event: EventCheckIn, Metric: MetricDistinctLocations, Filter: Filter{LocationType: "Station"}, Target: 2}})
+	assert.Nil(t, err)
+	_, err = d.CreateRule(context.TODO(), RuleStoreModel{ID: "regions", Name: "Regions", Criteria: Criteria{Event: EventCheckIn, Metric: MetricDistinctRegions, Target: 2}})
+	assert.Nil(t, err)
+	clientID := uuid.New().String()
+
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "1", "station", 18.07, 59.33)))
+	// the same location counts once
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "1", "station", 18.07, 59.33)))
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "2", "city", 18.5, 59.5)))
+
+	earned, err := d.Earned(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Empty(t, earned)
+	progress, err := d.InProgress(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Len(t, progress, 2)
+	assert.Equal(t, float64(1), progress[0].Value)
+	assert.Equal(t, float64(1), progress[1].Value)
+
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "3", "Station", 11.97, 57.71)))
+	earned, err = d.Earned(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Len(t, earned, 2)
+	assert.NotNil(t, earned[0].UnlockedAt)
+}
+
+func TestDefaultService_MoveRules(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), nil, DefaultConfig(), time.Second)
+	_, err := d.CreateRule(context.TODO(), RuleStoreModel{ID: "walker", Name: "Walker", Criteria: Criteria{Event: EventMove, Metric: MetricDistance, Target: 1000}})
+	assert.Nil(t, err)
+	clientID := uuid.New().String()
+	now := time.Now().UTC()
+	move := func(fromLat, toLat float64, elapsed time.Duration) players.LocationUpdate {
+		return players.LocationUpdate{
+			ClientID:   clientID,
+			Previous:   &locations.GeoPoint{Longitude: 18, Latitude: fromLat},
+			PreviousAt: now.Add(-elapsed),
+			GeoPoint:   locations.GeoPoint{Longitude: 18, Latitude: toLat},
+			At:         now,
+		}
+	}
+
+	// about 556m in 10 minutes
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), move(59, 59.005, time.Minute*10)))
+	// too fast, not counted
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), move(59, 59.5, time.Minute)))
+	progress, err := d.InProgress(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.InDelta(t, 556, progress[0].Value, 1)
+
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), move(59.005, 59.01, time.Minute*10)))
+	earned, err := d.Earned(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Len(t, earned, 1)
+}
+
+func TestDefaultService_Backfill(t *testing.T) {
+	playerA, playerB := uuid.New().String(), uuid.New().String()
+	past := []checkins.CheckIn{
+		checkIn(playerA, "1", "station", 18.07, 59.33),
+		checkIn(playerA, "2", "station", 18.08, 59.33),
+		checkIn(playerB, "1", "station", 18.07, 59.33),
+		checkIn(playerB, "4", "city", 18.07, 59.33),
+	}
+	history := historyFunc(func(until time.Time, fn func(checkins.CheckIn) error) error {
+		for _, c := range past {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), history, DefaultConfig(), time.Second)
+	_, err := d.CreateRule(context.TODO(), RuleStoreModel{ID: "stations", Name: "Stations", Criteria: Criteria{Event: EventCheckIn, Metric: MetricDistinctLocations, Filter: Filter{LocationType: "station"}, Target: 2}})
+	assert.Nil(t, err)
+	_, err = d.CreateRule(context.TODO(), RuleStoreModel{ID: "walker", Name: "Walker", Criteria: Criteria{Event: EventMove, Metric: MetricDistance, Target: 1000}})
+	assert.Nil(t, err)
+
+	_, err = d.Backfill(context.TODO(), "walker")
+	assert.Equal(t, ErrBackfillNotSupported, err)
+	_, err = d.Backfill(context.TODO(), "unknown")
+	assert.Equal(t, ErrRuleNotFound, err)
+
+	res, err := d.Backfill(context.TODO(), "stations")
+	assert.Nil(t, err)
+	assert.Equal(t, &BackfillResult{Events: 3, Players: 2, Unlocked: 1}, res)
+	// a second backfill starts over and gives the same result
+	res, err = d.Backfill(context.TODO(), "stations")
+	assert.Nil(t, err)
+	assert.Equal(t, &BackfillResult{Events: 3, Players: 2, Unlocked: 1}, res)
+}
@@ -0,0 +1,27 @@
+package achievements
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNotFound is returned by the stores when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrRuleExists is returned when a rule id is already taken
+	ErrRuleExists = errors.New("achievement rule already exists")
+)
+
+// ProgressFunc changes the locked progress, a missing progress is handed over empty
+type ProgressFunc func(p *ProgressStoreModel) error
+
+type Store interface {
+	CreateRule(ctx context.Context, model *RuleStoreModel) error
+	GetRule(ctx context.Context, id string) (*RuleStoreModel, error)
+	ListRules(ctx context.Context) ([]RuleStoreModel, error)
+	DeleteRule(ctx context.Context, id string) error
+	ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error)
+	// UpdateProgress runs fn on the locked progress of the player, concurrent events are serialized
+	UpdateProgress(ctx context.Context, clientID, ruleID string, fn ProgressFunc) (*ProgressStoreModel, error)
+	DeleteRuleProgress(ctx context.Context, ruleID string) error
+}
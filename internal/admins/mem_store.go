@@ -0,0 +1,131 @@
+package admins
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu     sync.RWMutex
+	admins map[string]*AdminStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		admins: make(map[string]*AdminStoreModel),
+	}
+}
+
+func (m *MemStore) Create(ctx context.Context, model *AdminStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.admins {
+		if strings.EqualFold(a.Email, model.Email) {
+			return ErrDuplicateEmail
+		}
+	}
+	c := *model
+	m.admins[model.ID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) GetByEmail(ctx context.Context, email string) (*AdminStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, a := range m.admins {
+		if strings.EqualFold(a.Email, email) {
+			c := *a
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemStore) GetByID(ctx context.Context, id string) (*AdminStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.admins[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *a
+	return &c, nil
+}
+
+func (m *MemStore) List(ctx context.Context) ([]AdminStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]AdminStoreModel, 0, len(m.admins))
+	for _, a := range m.admins {
+		res = append(res, *a)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Email < res[j].Email
+	})
+	return res, nil
+}
+
+// lastOfRole must be called with the lock held
+func (m *MemStore) lastOfRole(a *AdminStoreModel, keep string) bool {
+	if a.Role != keep {
+		return false
+	}
+	for _, other := range m.admins {
+		if other.ID != a.ID && other.Role == keep {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MemStore) UpdateRole(ctx context.Context, id, role, keep string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.admins[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if role != keep && m.lastOfRole(a, keep) {
+		return ErrLastOfRole
+	}
+	a.Role = role
+	a.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MemStore) UpdatePassword(ctx context.Context, id, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.admins[id]
+	if !ok {
+		return ErrNotFound
+	}
+	a.Password = hash
+	a.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MemStore) Delete(ctx context.Context, id, keep string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.admins[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if m.lastOfRole(a, keep) {
+		return ErrLastOfRole
+	}
+	delete(m.admins, id)
+	return nil
+}
+
+func (m *MemStore) Count(ctx context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.admins), nil
+}
@@ -0,0 +1,77 @@
+package admins
+
+import "context"
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateFunc         func(model *AdminStoreModel) error
+	GetByEmailFunc     func(email string) (*AdminStoreModel, error)
+	GetByIDFunc        func(id string) (*AdminStoreModel, error)
+	ListFunc           func() ([]AdminStoreModel, error)
+	UpdateRoleFunc     func(id, role, keep string) error
+	UpdatePasswordFunc func(id, hash string) error
+	DeleteFunc         func(id, keep string) error
+	CountFunc          func() (int, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateFunc: func(model *AdminStoreModel) error {
+			return nil
+		},
+		GetByEmailFunc: func(email string) (*AdminStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		GetByIDFunc: func(id string) (*AdminStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListFunc: func() ([]AdminStoreModel, error) {
+			return []AdminStoreModel{}, nil
+		},
+		UpdateRoleFunc: func(id, role, keep string) error {
+			return ErrNotFound
+		},
+		UpdatePasswordFunc: func(id, hash string) error {
+			return ErrNotFound
+		},
+		DeleteFunc: func(id, keep string) error {
+			return ErrNotFound
+		},
+		CountFunc: func() (int, error) {
+			return 0, nil
+		},
+	}
+}
+
+func (m *MockStore) Create(ctx context.Context, model *AdminStoreModel) error {
+	return m.CreateFunc(model)
+}
+
+func (m *MockStore) GetByEmail(ctx context.Context, email string) (*AdminStoreModel, error) {
+	return m.GetByEmailFunc(email)
+}
+
+func (m *MockStore) GetByID(ctx context.Context, id string) (*AdminStoreModel, error) {
+	return m.GetByIDFunc(id)
+}
+
+func (m *MockStore) List(ctx context.Context) ([]AdminStoreModel, error) {
+	return m.ListFunc()
+}
+
+func (m *MockStore) UpdateRole(ctx context.Context, id, role, keep string) error {
+	return m.UpdateRoleFunc(id, role, keep)
+}
+
+func (m *MockStore) UpdatePassword(ctx context.Context, id, hash string) error {
+	return m.UpdatePasswordFunc(id, hash)
+}
+
+func (m *MockStore) Delete(ctx context.Context, id, keep string) error {
+	return m.DeleteFunc(id, keep)
+}
+
+func (m *MockStore) Count(ctx context.Context) (int, error) {
+	return m.CountFunc()
+}
@@ -0,0 +1,63 @@
+package admins
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"geogame/internal/totp"
+)
+
+// AdminStoreModel is an operator of the game, admins are kept apart from the players
+type AdminStoreModel struct {
+	ID        uuid.UUID `db:"id"`
+	Email     string    `db:"email"`
+	Password  string    `db:"password"`
+	Role      string    `db:"role"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type AdminPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type RolePayload struct {
+	Role string `json:"role"`
+}
+
+type LoginPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	RemoteIP string `json:"-"`
+}
+
+type Admin struct {
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// TokenResponse is the result of a login, a login waiting for the second factor only has the challenge
+// and, when the policy forces the admin to enroll, the setup of the authenticator
+type TokenResponse struct {
+	Token          string           `json:"token,omitempty"`
+	Role           string           `json:"role,omitempty"`
+	Permissions    []string         `json:"permissions,omitempty"`
+	Challenge      string           `json:"challenge,omitempty"`
+	TwoFactorSetup *totp.Enrollment `json:"twoFactorSetup,omitempty"`
+	RecoveryCodes  []string         `json:"recoveryCodes,omitempty"`
+}
+
+type TwoFactorPayload struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+
+type CodePayload struct {
+	Code string `json:"code"`
+}
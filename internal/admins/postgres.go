@@ -0,0 +1,183 @@
+package admins
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	adminsAllCols = "id, email, password, role, created_at, updated_at"
+	adminsTable   = "admins"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.admins.store"),
+	}
+}
+
+func (p Postgres) Create(ctx context.Context, model *AdminStoreModel) error {
+	stmt := `INSERT INTO admins (
+	id,
+	email,
+	password,
+	role,
+	created_at,
+	updated_at
+	) VALUES (
+	:id,
+	:email,
+	:password,
+	:role,
+	:created_at,
+	:updated_at
+	)`
+	if _, err := p.db.NamedExecContext(ctx, stmt, model); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return ErrDuplicateEmail
+		}
+		p.logger.Error("Create: failed to insert admin to db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) GetByEmail(ctx context.Context, email string) (*AdminStoreModel, error) {
+	stmt := "SELECT " + adminsAllCols + " FROM " + adminsTable + " WHERE lower(email)=lower($1)"
+	var res AdminStoreModel
+	if err := p.db.GetContext(ctx, &res, stmt, email); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetByEmail: failed to get admin from db", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p Postgres) GetByID(ctx context.Context, id string) (*AdminStoreModel, error) {
+	stmt := "SELECT " + adminsAllCols + " FROM " + adminsTable + " WHERE id=$1"
+	var res AdminStoreModel
+	if err := p.db.GetContext(ctx, &res, stmt, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetByID: failed to get admin from db", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p Postgres) List(ctx context.Context) ([]AdminStoreModel, error) {
+	stmt := "SELECT " + adminsAllCols + " FROM " + adminsTable + " ORDER BY email"
+	res := make([]AdminStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("List: failed to list admins from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// lockRole locks the admins of the role and returns the admin with the id
+func (p Postgres) lockRole(ctx context.Context, tx *sqlx.Tx, id, keep string) (*AdminStoreModel, int, error) {
+	stmt := "SELECT " + adminsAllCols + " FROM " + adminsTable + " WHERE role=$1 OR id=$2 FOR UPDATE"
+	locked := make([]AdminStoreModel, 0)
+	if err := tx.SelectContext(ctx, &locked, stmt, keep, id); err != nil {
+		return nil, 0, err
+	}
+	var admin *AdminStoreModel
+	kept := 0
+	for i := range locked {
+		if locked[i].ID.String() == id {
+			admin = &locked[i]
+		}
+		if locked[i].Role == keep {
+			kept++
+		}
+	}
+	if admin == nil {
+		return nil, 0, ErrNotFound
+	}
+	return admin, kept, nil
+}
+
+func (p Postgres) UpdateRole(ctx context.Context, id, role, keep string) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("UpdateRole: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+	admin, kept, err := p.lockRole(ctx, tx, id, keep)
+	if err != nil {
+		return err
+	}
+	if admin.Role == keep && role != keep && kept == 1 {
+		return ErrLastOfRole
+	}
+	stmt := "UPDATE " + adminsTable + " SET role=$2, updated_at=now() WHERE id=$1"
+	if _, err := tx.ExecContext(ctx, stmt, id, role); err != nil {
+		p.logger.Error("UpdateRole: failed to update admin in db", zap.Error(err))
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) UpdatePassword(ctx context.Context, id, hash string) error {
+	stmt := "UPDATE " + adminsTable + " SET password=$2, updated_at=now() WHERE id=$1"
+	res, err := p.db.ExecContext(ctx, stmt, id, hash)
+	if err != nil {
+		p.logger.Error("UpdatePassword: failed to update admin in db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) Delete(ctx context.Context, id, keep string) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("Delete: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+	admin, kept, err := p.lockRole(ctx, tx, id, keep)
+	if err != nil {
+		return err
+	}
+	if admin.Role == keep && kept == 1 {
+		return ErrLastOfRole
+	}
+	stmt := "DELETE FROM " + adminsTable + " WHERE id=$1"
+	if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+		p.logger.Error("Delete: failed to delete admin from db", zap.Error(err))
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) Count(ctx context.Context) (int, error) {
+	stmt := "SELECT count(*) FROM " + adminsTable
+	var n int
+	if err := p.db.GetContext(ctx, &n, stmt); err != nil {
+		p.logger.Error("Count: failed to count admins in db", zap.Error(err))
+		return 0, err
+	}
+	return n, nil
+}
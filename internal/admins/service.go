@@ -0,0 +1,481 @@
+package admins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"geogame/internal/lockout"
+	"geogame/internal/middleware"
+	"geogame/internal/passwords"
+	"geogame/internal/totp"
+)
+
+// minPasswordLength is the floor of the admin passwords, the player policy may be weaker
+const minPasswordLength = 12
+
+var (
+	ErrInvalidAdmin        = errors.New("invalid admin")
+	ErrAdminExists         = errors.New("admin already exists")
+	ErrAdminNotFound       = errors.New("admin not found")
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrLastSuperAdmin      = errors.New("the last superadmin can not be removed or demoted")
+	ErrAlreadyBootstrapped = errors.New("admins already exist")
+	// ErrTwoFactorRequired is returned when the policy does not allow the role to disable the second factor
+	ErrTwoFactorRequired    = errors.New("two-factor authentication is required for the role")
+	ErrTwoFactorUnavailable = errors.New("two-factor authentication is not configured")
+)
+
+type Service interface {
+	Login(ctx context.Context, payload LoginPayload) (*TokenResponse, error)
+	Create(ctx context.Context, payload AdminPayload) (*Admin, error)
+	List(ctx context.Context) ([]Admin, error)
+	UpdateRole(ctx context.Context, id string, payload RolePayload) error
+	Delete(ctx context.Context, id string) error
+	// Bootstrap creates the first superadmin, it fails once any admin exists
+	Bootstrap(ctx context.Context, email, password string) (*Admin, error)
+	// VerifyTwoFactor completes a login waiting for the second factor
+	VerifyTwoFactor(ctx context.Context, payload TwoFactorPayload) (*TokenResponse, error)
+	EnrollTwoFactor(ctx context.Context, id string) (*totp.Enrollment, error)
+	ConfirmTwoFactor(ctx context.Context, id string, payload CodePayload) (*totp.RecoveryCodes, error)
+	DisableTwoFactor(ctx context.Context, id string, payload CodePayload) error
+	// AdminRole implements the middleware.AdminRoleChecker
+	AdminRole(ctx context.Context, id string) (string, error)
+}
+
+type Config struct {
+	// BootstrapEmail and BootstrapPassword create the first superadmin at startup when no admin exists
+	BootstrapEmail    string `env:"ADMIN_BOOTSTRAP_EMAIL"`
+	BootstrapPassword string `env:"ADMIN_BOOTSTRAP_PASSWORD"`
+	// TwoFactorRoles must use a second factor, their admins enroll at the next login
+	TwoFactorRoles []string `env:"ADMIN_2FA_REQUIRED_ROLES" envSeparator:","`
+	// RoleCacheTTL is how long the role of an admin token is trusted before it is loaded again
+	RoleCacheTTL time.Duration `env:"ADMIN_ROLE_CACHE_TTL" envDefault:"30s"`
+}
+
+type Option func(*DefaultService)
+
+// WithLockout throttles the failed admin logins
+func WithLockout(l lockout.Service) Option {
+	return func(d *DefaultService) {
+		d.lockout = l
+	}
+}
+
+// WithTwoFactor enables the second factor, the admins of the required roles can't login without it
+func WithTwoFactor(t totp.Service, requiredRoles []string) Option {
+	return func(d *DefaultService) {
+		d.twoFactor = t
+		d.twoFactorRoles = requiredRoles
+	}
+}
+
+// WithHasher sets the hasher of the new passwords, the stored hashes of other parameters are upgraded at login
+func WithHasher(h passwords.Hasher) Option {
+	return func(d *DefaultService) {
+		d.hasher = h
+	}
+}
+
+// WithPasswordPolicy sets the rules of the new passwords, the minimum length is at least 12 characters
+func WithPasswordPolicy(c passwords.PolicyConfig) Option {
+	return func(d *DefaultService) {
+		if c.MinLength < minPasswordLength {
+			c.MinLength = minPasswordLength
+		}
+		d.policy = passwords.NewPolicy(c)
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	issuer    middleware.AdminIssuer
+	lockout   lockout.Service
+	hasher    passwords.Hasher
+	policy    *passwords.Policy
+	dbTimeOut time.Duration
+
+	dummyOnce sync.Once
+	dummyHash string
+
+	twoFactor      totp.Service
+	twoFactorRoles []string
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, issuer middleware.AdminIssuer, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		issuer:    issuer,
+		hasher:    passwords.NewDefaultHasher(),
+		dbTimeOut: dbTimeOut,
+	}
+	WithPasswordPolicy(passwords.DefaultPolicyConfig())(d)
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+func (d *DefaultService) Login(ctx context.Context, payload LoginPayload) (*TokenResponse, error) {
+	keys := []string{lockout.AdminKey(payload.Email)}
+	if payload.RemoteIP != "" {
+		keys = append(keys, lockout.IPKey(payload.RemoteIP))
+	}
+	if d.lockout != nil {
+		if err := d.lockout.Check(ctx, keys...); err != nil {
+			d.logger.Warn("Login: admin login attempt throttled", zap.String("email", payload.Email), zap.String("ip", payload.RemoteIP), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	admin, err := d.store.GetByEmail(dbCtx, payload.Email)
+	if err != nil {
+		if err != ErrNotFound {
+			d.logger.Error("Login: failed to get admin", zap.String("email", payload.Email), zap.Error(err))
+			return nil, errors.New("failed to login:" + err.Error())
+		}
+		_, _ = d.hasher.Verify(d.dummy(), payload.Password)
+		return nil, d.loginFailed(ctx, keys)
+	}
+	rehash, err := d.hasher.Verify(admin.Password, payload.Password)
+	if err != nil {
+		d.logger.Warn("Login: wrong admin password", zap.String("email", payload.Email), zap.Error(err))
+		return nil, d.loginFailed(ctx, keys)
+	}
+	if rehash {
+		d.rehash(ctx, admin, payload.Password)
+	}
+	if d.twoFactor != nil {
+		res, err := d.twoFactorChallenge(ctx, admin)
+		if err != nil || res != nil {
+			return res, err
+		}
+	}
+	d.resetAttempts(ctx, keys[0])
+	return d.issueToken(admin)
+}
+
+// twoFactorChallenge asks for the second factor when the admin enabled it or when the policy requires it,
+// an admin of a required role without a second factor gets a new authenticator confirmed by the challenge
+func (d *DefaultService) twoFactorChallenge(ctx context.Context, admin *AdminStoreModel) (*TokenResponse, error) {
+	key := totp.AdminKey(admin.ID.String())
+	enabled, err := d.twoFactor.Enabled(ctx, key)
+	if err != nil {
+		d.logger.Error("Login: failed to check the second factor", zap.String("adminID", admin.ID.String()), zap.Error(err))
+		return nil, errors.New("failed to login:" + err.Error())
+	}
+	res := &TokenResponse{}
+	if !enabled {
+		if !d.twoFactorRequired(admin.Role) {
+			return nil, nil
+		}
+		res.TwoFactorSetup, err = d.twoFactor.Enroll(ctx, key, admin.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res.Challenge, err = d.twoFactor.NewChallenge(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (d *DefaultService) twoFactorRequired(role string) bool {
+	for _, r := range d.twoFactorRoles {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DefaultService) VerifyTwoFactor(ctx context.Context, payload TwoFactorPayload) (*TokenResponse, error) {
+	if d.twoFactor == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+	key, err := d.twoFactor.ChallengeKey(ctx, payload.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := totp.AdminIDOf(key)
+	if !ok {
+		return nil, totp.ErrInvalidChallenge
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	admin, err := d.store.GetByID(dbCtx, id)
+	if err != nil {
+		d.logger.Error("VerifyTwoFactor: failed to get admin", zap.String("adminID", id), zap.Error(err))
+		return nil, totp.ErrInvalidChallenge
+	}
+
+	lockoutKey := lockout.AdminKey(admin.Email)
+	if d.lockout != nil {
+		if err := d.lockout.Check(ctx, lockoutKey); err != nil {
+			d.logger.Warn("VerifyTwoFactor: attempt throttled", zap.String("adminID", id), zap.Error(err))
+			return nil, err
+		}
+	}
+	challenge, err := d.twoFactor.CompleteChallenge(ctx, payload.Challenge, payload.Code)
+	if err != nil {
+		if err == totp.ErrInvalidCode && d.lockout != nil {
+			if err := d.lockout.Fail(ctx, lockoutKey); err != nil {
+				d.logger.Error("VerifyTwoFactor: failed to record failed attempt", zap.Error(err))
+			}
+		}
+		return nil, err
+	}
+	d.resetAttempts(ctx, lockoutKey)
+
+	res, err := d.issueToken(admin)
+	if err != nil {
+		return nil, err
+	}
+	res.RecoveryCodes = challenge.RecoveryCodes
+	return res, nil
+}
+
+func (d *DefaultService) EnrollTwoFactor(ctx context.Context, id string) (*totp.Enrollment, error) {
+	if d.twoFactor == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	admin, err := d.store.GetByID(dbCtx, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrAdminNotFound
+		}
+		d.logger.Error("EnrollTwoFactor: failed to get admin", zap.String("adminID", id), zap.Error(err))
+		return nil, errors.New("failed to enroll:" + err.Error())
+	}
+	return d.twoFactor.Enroll(ctx, totp.AdminKey(id), admin.Email)
+}
+
+func (d *DefaultService) ConfirmTwoFactor(ctx context.Context, id string, payload CodePayload) (*totp.RecoveryCodes, error) {
+	if d.twoFactor == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+	return d.twoFactor.Confirm(ctx, totp.AdminKey(id), payload.Code)
+}
+
+func (d *DefaultService) DisableTwoFactor(ctx context.Context, id string, payload CodePayload) error {
+	if d.twoFactor == nil {
+		return ErrTwoFactorUnavailable
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	admin, err := d.store.GetByID(dbCtx, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return ErrAdminNotFound
+		}
+		d.logger.Error("DisableTwoFactor: failed to get admin", zap.String("adminID", id), zap.Error(err))
+		return errors.New("failed to disable two-factor authentication:" + err.Error())
+	}
+	if d.twoFactorRequired(admin.Role) {
+		return ErrTwoFactorRequired
+	}
+	return d.twoFactor.Disable(ctx, totp.AdminKey(id), payload.Code)
+}
+
+func (d *DefaultService) resetAttempts(ctx context.Context, key string) {
+	if d.lockout == nil {
+		return
+	}
+	if err := d.lockout.Reset(ctx, key); err != nil {
+		d.logger.Error("Login: failed to reset failed attempts", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (d *DefaultService) issueToken(admin *AdminStoreModel) (*TokenResponse, error) {
+	token, err := d.issuer.GenerateAdminToken(admin.ID.String(), admin.Role)
+	if err != nil {
+		d.logger.Error("Login: failed to issue admin token", zap.String("email", admin.Email), zap.Error(err))
+		return nil, errors.New("failed to login:" + err.Error())
+	}
+	d.logger.Info("Login: admin logged in", zap.String("adminID", admin.ID.String()), zap.String("role", admin.Role))
+	return &TokenResponse{
+		Token:       token,
+		Role:        admin.Role,
+		Permissions: middleware.RolePermissions(admin.Role),
+	}, nil
+}
+
+// rehash replaces a hash of outdated parameters, a failed upgrade is retried at the next login
+func (d *DefaultService) rehash(ctx context.Context, admin *AdminStoreModel, password string) {
+	hash, err := d.hasher.Hash(password)
+	if err != nil {
+		d.logger.Error("Login: failed to rehash the password", zap.String("id", admin.ID.String()), zap.Error(err))
+		return
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdatePassword(dbCtx, admin.ID.String(), hash); err != nil {
+		d.logger.Error("Login: failed to store the rehashed password", zap.String("id", admin.ID.String()), zap.Error(err))
+		return
+	}
+	admin.Password = hash
+}
+
+func (d *DefaultService) dummy() string {
+	d.dummyOnce.Do(func() {
+		d.dummyHash, _ = d.hasher.Hash("geogame-dummy-admin-password")
+	})
+	return d.dummyHash
+}
+
+func (d *DefaultService) loginFailed(ctx context.Context, keys []string) error {
+	if d.lockout != nil {
+		if err := d.lockout.Fail(ctx, keys...); err != nil {
+			d.logger.Error("Login: failed to record failed attempt", zap.Error(err))
+		}
+	}
+	return ErrInvalidCredentials
+}
+
+func (d *DefaultService) Create(ctx context.Context, payload AdminPayload) (*Admin, error) {
+	email := strings.TrimSpace(payload.Email)
+	if !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("%w: email is not valid", ErrInvalidAdmin)
+	}
+	if !middleware.ValidRole(payload.Role) {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidAdmin, payload.Role)
+	}
+	if err := d.policy.Validate(payload.Password, email, ""); err != nil {
+		return nil, err
+	}
+	hash, err := d.hasher.Hash(payload.Password)
+	if err != nil {
+		d.logger.Error("Create: failed to hash the password", zap.Error(err))
+		return nil, errors.New("failed to create admin:" + err.Error())
+	}
+	now := time.Now().UTC()
+	model := AdminStoreModel{
+		ID:        uuid.New(),
+		Email:     email,
+		Password:  hash,
+		Role:      payload.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.Create(dbCtx, &model); err != nil {
+		if err == ErrDuplicateEmail {
+			return nil, ErrAdminExists
+		}
+		d.logger.Error("Create: failed to create admin", zap.String("email", email), zap.Error(err))
+		return nil, errors.New("failed to create admin:" + err.Error())
+	}
+	res := toAdmin(&model)
+	return &res, nil
+}
+
+// AdminRole returns the current role of the admin, an empty role once the admin is deleted
+func (d *DefaultService) AdminRole(ctx context.Context, id string) (string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", nil
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	admin, err := d.store.GetByID(dbCtx, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return "", nil
+		}
+		d.logger.Error("AdminRole: failed to get admin from db", zap.String("id", id), zap.Error(err))
+		return "", errors.New("failed to get admin role:" + err.Error())
+	}
+	return admin.Role, nil
+}
+
+func (d *DefaultService) List(ctx context.Context) ([]Admin, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.List(dbCtx)
+	if err != nil {
+		d.logger.Error("List: failed to list admins", zap.Error(err))
+		return nil, errors.New("failed to list admins:" + err.Error())
+	}
+	res := make([]Admin, 0, len(models))
+	for i := range models {
+		res = append(res, toAdmin(&models[i]))
+	}
+	return res, nil
+}
+
+func (d *DefaultService) UpdateRole(ctx context.Context, id string, payload RolePayload) error {
+	if !middleware.ValidRole(payload.Role) {
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidAdmin, payload.Role)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrAdminNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdateRole(dbCtx, id, payload.Role, middleware.RoleSuperAdmin); err != nil {
+		return d.storeError("UpdateRole", id, err)
+	}
+	return nil
+}
+
+func (d *DefaultService) Delete(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrAdminNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.Delete(dbCtx, id, middleware.RoleSuperAdmin); err != nil {
+		return d.storeError("Delete", id, err)
+	}
+	return nil
+}
+
+func (d *DefaultService) storeError(method, id string, err error) error {
+	switch err {
+	case ErrNotFound:
+		return ErrAdminNotFound
+	case ErrLastOfRole:
+		return ErrLastSuperAdmin
+	}
+	d.logger.Error(method+": failed to update admin", zap.String("adminID", id), zap.Error(err))
+	return errors.New("failed to update admin:" + err.Error())
+}
+
+func (d *DefaultService) Bootstrap(ctx context.Context, email, password string) (*Admin, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	n, err := d.store.Count(dbCtx)
+	if err != nil {
+		d.logger.Error("Bootstrap: failed to count admins", zap.Error(err))
+		return nil, errors.New("failed to bootstrap admin:" + err.Error())
+	}
+	if n > 0 {
+		return nil, ErrAlreadyBootstrapped
+	}
+	return d.Create(ctx, AdminPayload{Email: email, Password: password, Role: middleware.RoleSuperAdmin})
+}
+
+func toAdmin(m *AdminStoreModel) Admin {
+	return Admin{
+		ID:          m.ID.String(),
+		Email:       m.Email,
+		Role:        m.Role,
+		Permissions: middleware.RolePermissions(m.Role),
+		CreatedAt:   m.CreatedAt,
+	}
+}
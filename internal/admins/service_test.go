@@ -0,0 +1,164 @@
+package admins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/middleware"
+	"geogame/internal/passwords"
+	"geogame/internal/totp"
+)
+
+func TestDefaultService_Bootstrap(t *testing.T) {
+	key := middleware.NewJwtKey("secret")
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), key, time.Second)
+
+	_, err := d.Bootstrap(context.TODO(), "root@example.com", "short")
+	assert.True(t, errors.Is(err, passwords.ErrWeakPassword))
+	admin, err := d.Bootstrap(context.TODO(), "root@example.com", "correct horse battery")
+	assert.Nil(t, err)
+	assert.Equal(t, middleware.RoleSuperAdmin, admin.Role)
+	_, err = d.Bootstrap(context.TODO(), "other@example.com", "correct horse battery")
+	assert.Equal(t, ErrAlreadyBootstrapped, err)
+
+	_, err = d.Login(context.TODO(), LoginPayload{Email: "root@example.com", Password: "wrong"})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = d.Login(context.TODO(), LoginPayload{Email: "nobody@example.com", Password: "wrong"})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	res, err := d.Login(context.TODO(), LoginPayload{Email: "ROOT@example.com", Password: "correct horse battery"})
+	assert.Nil(t, err)
+	assert.Contains(t, res.Permissions, middleware.PermAdminsManage)
+
+	claims := &middleware.AdminToken{}
+	assert.Nil(t, key.RequireLogin(claims, res.Token))
+	assert.Equal(t, admin.ID, claims.AdminID)
+	assert.Equal(t, middleware.RoleSuperAdmin, claims.Role)
+	assert.Equal(t, middleware.AdminAudience, claims.Audience)
+}
+
+func TestDefaultService_Roles(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), middleware.NewJwtKey("secret"), time.Second)
+	root, err := d.Bootstrap(context.TODO(), "root@example.com", "correct horse battery")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		payload AdminPayload
+		wantErr error
+	}{
+		{name: "editor", payload: AdminPayload{Email: "editor@example.com", Password: "correct horse battery", Role: middleware.RoleEditor}},
+		{name: "duplicate", payload: AdminPayload{Email: "Editor@example.com", Password: "correct horse battery", Role: middleware.RoleViewer}, wantErr: ErrAdminExists},
+		{name: "unknown role", payload: AdminPayload{Email: "a@example.com", Password: "correct horse battery", Role: "owner"}, wantErr: ErrInvalidAdmin},
+		{name: "bad email", payload: AdminPayload{Email: "a", Password: "correct horse battery", Role: middleware.RoleViewer}, wantErr: ErrInvalidAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.Create(context.TODO(), tt.payload)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+
+	// the last superadmin stays
+	assert.Equal(t, ErrLastSuperAdmin, d.UpdateRole(context.TODO(), root.ID, RolePayload{Role: middleware.RoleViewer}))
+	assert.Equal(t, ErrLastSuperAdmin, d.Delete(context.TODO(), root.ID))
+
+	list, err := d.List(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	editor := list[0]
+	assert.Equal(t, "editor@example.com", editor.Email)
+	assert.Nil(t, d.UpdateRole(context.TODO(), editor.ID, RolePayload{Role: middleware.RoleSuperAdmin}))
+	assert.Nil(t, d.Delete(context.TODO(), root.ID))
+	assert.Equal(t, ErrAdminNotFound, d.Delete(context.TODO(), root.ID))
+}
+
+func TestDefaultService_TwoFactor(t *testing.T) {
+	twoFactor := totp.NewDefaultService(zap.NewNop(), totp.NewMemStore(), totp.DefaultConfig(), time.Second)
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), middleware.NewJwtKey("secret"), time.Second,
+		WithTwoFactor(twoFactor, []string{middleware.RoleSuperAdmin}))
+	root, err := d.Bootstrap(context.TODO(), "root@example.com", "correct horse battery")
+	assert.Nil(t, err)
+	viewer, err := d.Create(context.TODO(), AdminPayload{Email: "viewer@example.com", Password: "correct horse battery", Role: middleware.RoleViewer})
+	assert.Nil(t, err)
+
+	// the policy makes the superadmin set up an authenticator before the first token
+	res, err := d.Login(context.TODO(), LoginPayload{Email: "root@example.com", Password: "correct horse battery"})
+	assert.Nil(t, err)
+	assert.Empty(t, res.Token)
+	assert.NotNil(t, res.TwoFactorSetup)
+	code, err := totp.Code(res.TwoFactorSetup.Secret, totp.Step(time.Now()))
+	assert.Nil(t, err)
+	_, err = d.VerifyTwoFactor(context.TODO(), TwoFactorPayload{Challenge: res.Challenge, Code: "000000"})
+	assert.Equal(t, totp.ErrInvalidCode, err)
+	verified, err := d.VerifyTwoFactor(context.TODO(), TwoFactorPayload{Challenge: res.Challenge, Code: code})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, verified.Token)
+	assert.Len(t, verified.RecoveryCodes, 10)
+	assert.Equal(t, ErrTwoFactorRequired, d.DisableTwoFactor(context.TODO(), root.ID, CodePayload{Code: verified.RecoveryCodes[0]}))
+
+	// the next login asks for a code of the enrolled authenticator
+	res, err = d.Login(context.TODO(), LoginPayload{Email: "root@example.com", Password: "correct horse battery"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Challenge)
+	assert.Nil(t, res.TwoFactorSetup)
+
+	// other roles opt in
+	res, err = d.Login(context.TODO(), LoginPayload{Email: "viewer@example.com", Password: "correct horse battery"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+	enrollment, err := d.EnrollTwoFactor(context.TODO(), viewer.ID)
+	assert.Nil(t, err)
+	code, err = totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	assert.Nil(t, err)
+	recovery, err := d.ConfirmTwoFactor(context.TODO(), viewer.ID, CodePayload{Code: code})
+	assert.Nil(t, err)
+	res, err = d.Login(context.TODO(), LoginPayload{Email: "viewer@example.com", Password: "correct horse battery"})
+	assert.Nil(t, err)
+	assert.Empty(t, res.Token)
+	assert.Nil(t, d.DisableTwoFactor(context.TODO(), viewer.ID, CodePayload{Code: recovery.Codes[0]}))
+}
+
+func TestDefaultService_AdminRole(t *testing.T) {
+	key := middleware.NewJwtKey("secret")
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), key, time.Second)
+	_, err := d.Bootstrap(context.TODO(), "root@example.com", "correct horse battery")
+	assert.Nil(t, err)
+	editor, err := d.Create(context.TODO(), AdminPayload{Email: "editor@example.com", Password: "correct horse battery", Role: middleware.RoleEditor})
+	assert.Nil(t, err)
+	token, err := key.GenerateAdminToken(editor.ID, middleware.RoleEditor)
+	assert.Nil(t, err)
+
+	// without a ttl every request loads the role again
+	roles := middleware.NewAdminRoleCache(d, 0)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := middleware.IsAdminAllowed(key, nil, roles)(middleware.RequirePermission(middleware.PermLocationsWrite)(ok))
+	serve := func() int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+	// the issued token follows the role of the admin
+	assert.Nil(t, d.UpdateRole(context.TODO(), editor.ID, RolePayload{Role: middleware.RoleViewer}))
+	assert.Equal(t, http.StatusForbidden, serve())
+	assert.Nil(t, d.Delete(context.TODO(), editor.ID))
+	assert.Equal(t, http.StatusUnauthorized, serve())
+
+	role, err := d.AdminRole(context.TODO(), editor.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "", role)
+}
@@ -0,0 +1,28 @@
+package admins
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicateEmail is returned when an admin with the email already exists
+var ErrDuplicateEmail = errors.New("email already taken")
+
+// ErrLastOfRole is returned when a change would leave no admin with the kept role
+var ErrLastOfRole = errors.New("last admin of the role")
+
+type Store interface {
+	Create(ctx context.Context, model *AdminStoreModel) error
+	GetByEmail(ctx context.Context, email string) (*AdminStoreModel, error)
+	GetByID(ctx context.Context, id string) (*AdminStoreModel, error)
+	List(ctx context.Context) ([]AdminStoreModel, error)
+	// UpdateRole changes the role unless it would leave no admin with the keep role
+	UpdateRole(ctx context.Context, id, role, keep string) error
+	UpdatePassword(ctx context.Context, id, hash string) error
+	// Delete removes the admin unless it would leave no admin with the keep role
+	Delete(ctx context.Context, id, keep string) error
+	Count(ctx context.Context) (int, error)
+}
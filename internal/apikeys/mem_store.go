@@ -0,0 +1,80 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu   sync.RWMutex
+	keys map[string]*KeyStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		keys: make(map[string]*KeyStoreModel),
+	}
+}
+
+func (m *MemStore) Create(ctx context.Context, model *KeyStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *model
+	m.keys[model.ID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) GetByHash(ctx context.Context, hash string) (*KeyStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.Hash == hash {
+			c := *k
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemStore) List(ctx context.Context) ([]KeyStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]KeyStoreModel, 0, len(m.keys))
+	for _, k := range m.keys {
+		res = append(res, *k)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.After(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+func (m *MemStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if !k.RevokedAt.Valid {
+		k.RevokedAt = sql.NullTime{Time: at, Valid: true}
+	}
+	return nil
+}
+
+func (m *MemStore) Touch(ctx context.Context, id string, at time.Time, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	k.LastUsedAt = sql.NullTime{Time: at, Valid: true}
+	k.LastUsedIP = ip
+	return nil
+}
@@ -0,0 +1,56 @@
+package apikeys
+
+import (
+	"context"
+	"time"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateFunc    func(model *KeyStoreModel) error
+	GetByHashFunc func(hash string) (*KeyStoreModel, error)
+	ListFunc      func() ([]KeyStoreModel, error)
+	RevokeFunc    func(id string, at time.Time) error
+	TouchFunc     func(id string, at time.Time, ip string) error
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateFunc: func(model *KeyStoreModel) error {
+			return nil
+		},
+		GetByHashFunc: func(hash string) (*KeyStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListFunc: func() ([]KeyStoreModel, error) {
+			return []KeyStoreModel{}, nil
+		},
+		RevokeFunc: func(id string, at time.Time) error {
+			return ErrNotFound
+		},
+		TouchFunc: func(id string, at time.Time, ip string) error {
+			return nil
+		},
+	}
+}
+
+func (m *MockStore) Create(ctx context.Context, model *KeyStoreModel) error {
+	return m.CreateFunc(model)
+}
+
+func (m *MockStore) GetByHash(ctx context.Context, hash string) (*KeyStoreModel, error) {
+	return m.GetByHashFunc(hash)
+}
+
+func (m *MockStore) List(ctx context.Context) ([]KeyStoreModel, error) {
+	return m.ListFunc()
+}
+
+func (m *MockStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	return m.RevokeFunc(id, at)
+}
+
+func (m *MockStore) Touch(ctx context.Context, id string, at time.Time, ip string) error {
+	return m.TouchFunc(id, at, ip)
+}
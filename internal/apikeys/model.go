@@ -0,0 +1,54 @@
+package apikeys
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// KeyStoreModel is an API key of an internal tool, only the hash of the secret is kept
+type KeyStoreModel struct {
+	ID         uuid.UUID      `db:"id"`
+	Name       string         `db:"name"`
+	Prefix     string         `db:"prefix"`
+	Hash       string         `db:"hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	AllowedIPs pq.StringArray `db:"allowed_ips"`
+	CreatedBy  string         `db:"created_by"`
+	ExpiresAt  sql.NullTime   `db:"expires_at"`
+	RevokedAt  sql.NullTime   `db:"revoked_at"`
+	LastUsedAt sql.NullTime   `db:"last_used_at"`
+	LastUsedIP string         `db:"last_used_ip"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+type KeyPayload struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// AllowedIPs are addresses or CIDR ranges, an empty list allows any caller
+	AllowedIPs []string   `json:"allowedIps"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	CreatedBy  string     `json:"-"`
+}
+
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowedIps"`
+	CreatedBy  string     `json:"createdBy"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `json:"lastUsedIp,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// CreatedKey carries the secret, it is only returned once
+type CreatedKey struct {
+	Key
+	Secret string `json:"key"`
+}
@@ -0,0 +1,107 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	keysAllCols = "id, name, prefix, hash, scopes, allowed_ips, created_by, expires_at, revoked_at, last_used_at, last_used_ip, created_at"
+	keysTable   = "api_keys"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.apikeys.store"),
+	}
+}
+
+func (p Postgres) Create(ctx context.Context, model *KeyStoreModel) error {
+	stmt := `INSERT INTO api_keys (
+	id,
+	name,
+	prefix,
+	hash,
+	scopes,
+	allowed_ips,
+	created_by,
+	expires_at,
+	last_used_ip,
+	created_at
+	) VALUES (
+	:id,
+	:name,
+	:prefix,
+	:hash,
+	:scopes,
+	:allowed_ips,
+	:created_by,
+	:expires_at,
+	:last_used_ip,
+	:created_at
+	)`
+	if _, err := p.db.NamedExecContext(ctx, stmt, model); err != nil {
+		p.logger.Error("Create: failed to insert api key to db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) GetByHash(ctx context.Context, hash string) (*KeyStoreModel, error) {
+	stmt := "SELECT " + keysAllCols + " FROM " + keysTable + " WHERE hash=$1"
+	var res KeyStoreModel
+	if err := p.db.GetContext(ctx, &res, stmt, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetByHash: failed to get api key from db", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p Postgres) List(ctx context.Context) ([]KeyStoreModel, error) {
+	stmt := "SELECT " + keysAllCols + " FROM " + keysTable + " ORDER BY created_at DESC"
+	res := make([]KeyStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("List: failed to list api keys from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) Revoke(ctx context.Context, id string, at time.Time) error {
+	stmt := "UPDATE " + keysTable + " SET revoked_at=COALESCE(revoked_at, $2) WHERE id=$1"
+	res, err := p.db.ExecContext(ctx, stmt, id, at)
+	if err != nil {
+		p.logger.Error("Revoke: failed to revoke api key in db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) Touch(ctx context.Context, id string, at time.Time, ip string) error {
+	stmt := "UPDATE " + keysTable + " SET last_used_at=$2, last_used_ip=$3 WHERE id=$1"
+	if _, err := p.db.ExecContext(ctx, stmt, id, at, ip); err != nil {
+		p.logger.Error("Touch: failed to update api key in db", zap.Error(err))
+		return err
+	}
+	return nil
+}
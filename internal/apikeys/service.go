@@ -0,0 +1,254 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"geogame/internal/middleware"
+	"geogame/pkg"
+)
+
+// keyPrefix marks the API keys so leaked keys are easy to find in logs and repositories
+const keyPrefix = "ggk_"
+
+var (
+	ErrInvalidKey  = errors.New("invalid api key")
+	ErrKeyNotFound = errors.New("api key not found")
+	// ErrKeyRejected is returned for unknown, revoked and expired keys and for callers outside of the allowlist
+	ErrKeyRejected = errors.New("api key rejected")
+)
+
+type Service interface {
+	Create(ctx context.Context, payload KeyPayload) (*CreatedKey, error)
+	List(ctx context.Context) ([]Key, error)
+	Revoke(ctx context.Context, id string) error
+	middleware.APIKeyAuthenticator
+}
+
+type Config struct {
+	// TouchInterval limits how often the last use of a key is written
+	TouchInterval time.Duration `env:"API_KEY_TOUCH_INTERVAL" envDefault:"1m"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		TouchInterval: time.Minute,
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	config    Config
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, config Config, dbTimeOut time.Duration) *DefaultService {
+	return &DefaultService{
+		logger:    logger,
+		store:     store,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+}
+
+func (d *DefaultService) Create(ctx context.Context, payload KeyPayload) (*CreatedKey, error) {
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidKey)
+	}
+	if len(payload.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidKey)
+	}
+	scopes := make(pq.StringArray, 0, len(payload.Scopes))
+	for _, s := range payload.Scopes {
+		// keys can't manage the admins, they would be able to mint keys of their own
+		if !middleware.ValidPermission(s) || s == middleware.PermAdminsManage {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidKey, s)
+		}
+		scopes = append(scopes, s)
+	}
+	allowed := make(pq.StringArray, 0, len(payload.AllowedIPs))
+	for _, ip := range payload.AllowedIPs {
+		network, err := parseAllowedIP(ip)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		allowed = append(allowed, network.String())
+	}
+	now := time.Now().UTC()
+	var expiresAt sql.NullTime
+	if payload.ExpiresAt != nil {
+		if !payload.ExpiresAt.After(now) {
+			return nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidKey)
+		}
+		expiresAt = sql.NullTime{Time: payload.ExpiresAt.UTC(), Valid: true}
+	}
+
+	token, err := pkg.RandomToken(32)
+	if err != nil {
+		d.logger.Error("Create: failed to generate api key", zap.Error(err))
+		return nil, errors.New("failed to create api key:" + err.Error())
+	}
+	secret := keyPrefix + token
+	model := KeyStoreModel{
+		ID:         uuid.New(),
+		Name:       name,
+		Prefix:     secret[:len(keyPrefix)+6],
+		Hash:       pkg.HashToken(secret),
+		Scopes:     scopes,
+		AllowedIPs: allowed,
+		CreatedBy:  payload.CreatedBy,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.Create(dbCtx, &model); err != nil {
+		d.logger.Error("Create: failed to create api key", zap.String("name", name), zap.Error(err))
+		return nil, errors.New("failed to create api key:" + err.Error())
+	}
+	d.logger.Info("Create: api key created", zap.String("keyID", model.ID.String()), zap.String("name", name),
+		zap.Strings("scopes", scopes), zap.String("createdBy", payload.CreatedBy))
+	return &CreatedKey{Key: toKey(&model), Secret: secret}, nil
+}
+
+func (d *DefaultService) List(ctx context.Context) ([]Key, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.List(dbCtx)
+	if err != nil {
+		d.logger.Error("List: failed to list api keys", zap.Error(err))
+		return nil, errors.New("failed to list api keys:" + err.Error())
+	}
+	res := make([]Key, 0, len(models))
+	for i := range models {
+		res = append(res, toKey(&models[i]))
+	}
+	return res, nil
+}
+
+func (d *DefaultService) Revoke(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrKeyNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.Revoke(dbCtx, id, time.Now().UTC()); err != nil {
+		if err == ErrNotFound {
+			return ErrKeyNotFound
+		}
+		d.logger.Error("Revoke: failed to revoke api key", zap.String("keyID", id), zap.Error(err))
+		return errors.New("failed to revoke api key:" + err.Error())
+	}
+	d.logger.Info("Revoke: api key revoked", zap.String("keyID", id))
+	return nil
+}
+
+// AuthenticateAPIKey resolves the key of a request, the last use is written at most once per touch interval
+func (d *DefaultService) AuthenticateAPIKey(ctx context.Context, key, remoteIP string) (*middleware.APIKey, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, ErrKeyRejected
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	model, err := d.store.GetByHash(dbCtx, pkg.HashToken(key))
+	if err != nil {
+		if err != ErrNotFound {
+			d.logger.Error("AuthenticateAPIKey: failed to get api key", zap.Error(err))
+		}
+		return nil, ErrKeyRejected
+	}
+	now := time.Now().UTC()
+	switch {
+	case model.RevokedAt.Valid:
+		d.logger.Warn("AuthenticateAPIKey: revoked api key used", zap.String("keyID", model.ID.String()), zap.String("ip", remoteIP))
+		return nil, ErrKeyRejected
+	case model.ExpiresAt.Valid && !now.Before(model.ExpiresAt.Time):
+		return nil, ErrKeyRejected
+	case !ipAllowed(model.AllowedIPs, remoteIP):
+		d.logger.Warn("AuthenticateAPIKey: api key used outside of its allowlist", zap.String("keyID", model.ID.String()), zap.String("ip", remoteIP))
+		return nil, ErrKeyRejected
+	}
+
+	if !model.LastUsedAt.Valid || now.Sub(model.LastUsedAt.Time) >= d.config.TouchInterval || model.LastUsedIP != remoteIP {
+		if err := d.store.Touch(dbCtx, model.ID.String(), now, remoteIP); err != nil {
+			d.logger.Error("AuthenticateAPIKey: failed to record api key use", zap.String("keyID", model.ID.String()), zap.Error(err))
+		}
+	}
+	return &middleware.APIKey{
+		ID:     model.ID.String(),
+		Name:   model.Name,
+		Scopes: append([]string(nil), model.Scopes...),
+	}, nil
+}
+
+func parseAllowedIP(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		return network, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", s)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func ipAllowed(allowed []string, remoteIP string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, a := range allowed {
+		if _, network, err := net.ParseCIDR(a); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func toKey(m *KeyStoreModel) Key {
+	k := Key{
+		ID:         m.ID.String(),
+		Name:       m.Name,
+		Prefix:     m.Prefix,
+		Scopes:     append([]string{}, m.Scopes...),
+		AllowedIPs: append([]string{}, m.AllowedIPs...),
+		CreatedBy:  m.CreatedBy,
+		LastUsedIP: m.LastUsedIP,
+		CreatedAt:  m.CreatedAt,
+	}
+	if m.ExpiresAt.Valid {
+		k.ExpiresAt = &m.ExpiresAt.Time
+	}
+	if m.RevokedAt.Valid {
+		k.RevokedAt = &m.RevokedAt.Time
+	}
+	if m.LastUsedAt.Valid {
+		k.LastUsedAt = &m.LastUsedAt.Time
+	}
+	return k
+}
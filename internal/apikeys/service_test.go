@@ -0,0 +1,81 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/middleware"
+)
+
+func TestDefaultService_Create(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), DefaultConfig(), time.Second)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		payload KeyPayload
+		wantErr error
+	}{
+		{name: "valid", payload: KeyPayload{Name: "content pipeline", Scopes: []string{middleware.PermLocationsRead}, AllowedIPs: []string{"10.0.0.0/8", "192.168.1.10"}}},
+		{name: "no name", payload: KeyPayload{Scopes: []string{middleware.PermLocationsRead}}, wantErr: ErrInvalidKey},
+		{name: "no scope", payload: KeyPayload{Name: "tool"}, wantErr: ErrInvalidKey},
+		{name: "unknown scope", payload: KeyPayload{Name: "tool", Scopes: []string{"everything"}}, wantErr: ErrInvalidKey},
+		{name: "admin scope", payload: KeyPayload{Name: "tool", Scopes: []string{middleware.PermAdminsManage}}, wantErr: ErrInvalidKey},
+		{name: "bad ip", payload: KeyPayload{Name: "tool", Scopes: []string{middleware.PermLocationsRead}, AllowedIPs: []string{"10.0.0"}}, wantErr: ErrInvalidKey},
+		{name: "expired", payload: KeyPayload{Name: "tool", Scopes: []string{middleware.PermLocationsRead}, ExpiresAt: &past}, wantErr: ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := d.Create(context.TODO(), tt.payload)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(res.Secret, res.Prefix))
+			assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.10/32"}, res.AllowedIPs)
+		})
+	}
+}
+
+func TestDefaultService_AuthenticateAPIKey(t *testing.T) {
+	store := NewMemStore()
+	d := NewDefaultService(zap.NewNop(), store, DefaultConfig(), time.Second)
+	created, err := d.Create(context.TODO(), KeyPayload{Name: "support", Scopes: []string{middleware.PermPlayersRead}, AllowedIPs: []string{"10.0.0.0/8"}, CreatedBy: "admin:1"})
+	assert.Nil(t, err)
+
+	key, err := d.AuthenticateAPIKey(context.TODO(), created.Secret, "10.1.2.3")
+	assert.Nil(t, err)
+	assert.True(t, key.HasPermission(middleware.PermPlayersRead))
+	assert.False(t, key.HasPermission(middleware.PermPlayersWrite))
+
+	_, err = d.AuthenticateAPIKey(context.TODO(), created.Secret, "192.168.1.1")
+	assert.Equal(t, ErrKeyRejected, err)
+	_, err = d.AuthenticateAPIKey(context.TODO(), created.Secret+"x", "10.1.2.3")
+	assert.Equal(t, ErrKeyRejected, err)
+
+	// the use is recorded without the secret
+	list, err := d.List(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+	assert.NotNil(t, list[0].LastUsedAt)
+	assert.Equal(t, "10.1.2.3", list[0].LastUsedIP)
+	assert.NotEqual(t, created.Secret, store.keys[created.ID].Hash)
+
+	store.keys[created.ID].ExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true}
+	_, err = d.AuthenticateAPIKey(context.TODO(), created.Secret, "10.1.2.3")
+	assert.Equal(t, ErrKeyRejected, err)
+	store.keys[created.ID].ExpiresAt = sql.NullTime{}
+
+	assert.Nil(t, d.Revoke(context.TODO(), created.ID))
+	_, err = d.AuthenticateAPIKey(context.TODO(), created.Secret, "10.1.2.3")
+	assert.Equal(t, ErrKeyRejected, err)
+	assert.Equal(t, ErrKeyNotFound, d.Revoke(context.TODO(), "unknown"))
+}
@@ -0,0 +1,20 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+type Store interface {
+	Create(ctx context.Context, model *KeyStoreModel) error
+	GetByHash(ctx context.Context, hash string) (*KeyStoreModel, error)
+	List(ctx context.Context) ([]KeyStoreModel, error)
+	// Revoke keeps the first revocation time of the key
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// Touch records the last use of the key
+	Touch(ctx context.Context, id string, at time.Time, ip string) error
+}
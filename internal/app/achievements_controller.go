@@ -0,0 +1,91 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/achievements"
+)
+
+// achievement endpoints
+func (c *Controller) CreateAchievement(w http.ResponseWriter, r *http.Request) {
+	var payload achievements.RuleStoreModel
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.achievements.CreateRule(r.Context(), payload)
+	if err != nil {
+		writeAchievementError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListAchievements(w http.ResponseWriter, r *http.Request) {
+	res, err := c.achievements.ListRules(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) DeleteAchievement(w http.ResponseWriter, r *http.Request) {
+	if err := c.achievements.DeleteRule(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeAchievementError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) BackfillAchievement(w http.ResponseWriter, r *http.Request) {
+	res, err := c.achievements.Backfill(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeAchievementError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) EarnedAchievements(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.achievements.Earned(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) AchievementProgress(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.achievements.InProgress(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func writeAchievementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, achievements.ErrInvalidRule), errors.Is(err, achievements.ErrBackfillNotSupported):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, achievements.ErrRuleNotFound):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -1,65 +1,115 @@
 package app
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
 	"github.com/go-chi/chi"
-	"go.uber.org/zap"
 
 	"geogame/internal/locations"
-	"geogame/internal/middleware"
 	"geogame/internal/players"
-	"geogame/pkg"
 )
 
-// type assert the main controller which extend the chi controller
-var _ pkg.Controller = (*Controller)(nil)
+// admin endpoints
+func (c *Controller) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	var payload locations.Location
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
 
-type Controller struct {
-	logger    *zap.Logger
-	locations locations.Service
-	players   players.Service
-	jwtAuther middleware.JwtAuther
+	if err := c.locations.Create(r.Context(), payload); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
 }
 
-func NewController(logger *zap.Logger, locations locations.Service, players players.Service, jwtAuther middleware.JwtAuther) *Controller {
-	return &Controller{
-		logger:    logger,
-		locations: locations,
-		players:   players,
-		jwtAuther: jwtAuther,
+func (c *Controller) ImportLocations(w http.ResponseWriter, r *http.Request) {
+	var payload []locations.Location
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	imported, err := c.locations.Import(r.Context(), payload)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
 	}
+	writeResponse(w, http.StatusOK, ImportResponse{Imported: imported})
 }
 
-// extend the chi controller init
-func (c *Controller) Init(logger *zap.Logger) error {
-	c.logger = logger
-	return nil
+func (c *Controller) GetLocation(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	res, err := c.locations.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
 }
 
-// extend the chi controller
-// Setup the chi routes
-func (c *Controller) SetupRouter(router chi.Router) error {
+func (c *Controller) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	var payload locations.Location
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	// Register admin endpoints
-	router.Route("/admin/loc", func(r chi.Router) {
-		r.Post("/create", c.CreateLocation)
-		r.Get("/{id}", c.GetLocation)
-		r.Put("/update", c.UpdateLocation)
-		r.Delete("/{id}/delete", c.DeleteLocation)
-	})
+	if err := c.locations.Update(r.Context(), payload); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
 
-	// Register client endpoints
-	router.Route("/client", func(r chi.Router) {
-		r.Post("/register", c.Register)
-		r.Post("/login", c.Login)
-		r.With(middleware.IsClientAllowed(c.jwtAuther)).Post("/loc/send", c.SendLocation)
-		r.With(middleware.IsClientAllowed(c.jwtAuther)).Put("/update-name", c.UpdateName)
-		r.With(middleware.IsClientAllowed(c.jwtAuther)).Get("/loc/get", c.GetClientLocation)
-	})
+func (c *Controller) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := c.locations.Delete(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
 
-	return nil
+func (c *Controller) UnlockPlayer(w http.ResponseWriter, r *http.Request) {
+	var payload players.UnlockPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.Email == "" && payload.IP == "" {
+		writeError(w, http.StatusBadRequest, errors.New("empty email id and ip"))
+		return
+	}
+	if err := c.players.Unlock(r.Context(), payload); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
 }
 
-// extend the chi controller init
-func (c *Controller) Terminate() error {
-	return nil
+// GetPlayerLocation returns the position of a player as other players see it,
+// override=true with a reason returns the exact position and is audited
+func (c *Controller) GetPlayerLocation(w http.ResponseWriter, r *http.Request) {
+	payload := players.AdminLocationPayload{
+		Override: r.URL.Query().Get("override") == "true",
+		Reason:   r.URL.Query().Get("reason"),
+		Actor:    adminActor(r),
+	}
+	res, err := c.players.AdminGetLocation(r.Context(), payload, chi.URLParam(r, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, players.ErrOverrideReasonRequired):
+			writeError(w, http.StatusBadRequest, err)
+		case errors.Is(err, players.ErrNotFound), errors.Is(err, players.ErrLocationHidden):
+			writeError(w, http.StatusNotFound, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
 }
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/admins"
+	"geogame/internal/lockout"
+)
+
+// admin account endpoints
+func (c *Controller) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	var payload admins.LoginPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.Email == "" || payload.Password == "" {
+		writeError(w, http.StatusBadRequest, errors.New("empty email or password"))
+		return
+	}
+	payload.RemoteIP = remoteIP(r)
+	res, err := c.admins.Login(r.Context(), payload)
+	if err != nil {
+		var locked *lockout.LockedError
+		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, err)
+		case errors.Is(err, admins.ErrInvalidCredentials):
+			writeError(w, http.StatusUnauthorized, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) CreateAdmin(w http.ResponseWriter, r *http.Request) {
+	var payload admins.AdminPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.admins.Create(r.Context(), payload)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListAdmins(w http.ResponseWriter, r *http.Request) {
+	res, err := c.admins.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) UpdateAdminRole(w http.ResponseWriter, r *http.Request) {
+	var payload admins.RolePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := c.admins.UpdateRole(r.Context(), id, payload); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	c.adminRoles.MarkRole(id, payload.Role)
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) DeleteAdmin(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := c.admins.Delete(r.Context(), id); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	c.adminRoles.MarkRole(id, "")
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	if writePasswordError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, admins.ErrInvalidAdmin):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, admins.ErrAdminNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, admins.ErrAdminExists),
+		errors.Is(err, admins.ErrLastSuperAdmin):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/apikeys"
+)
+
+// api key endpoints
+func (c *Controller) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var payload apikeys.KeyPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	payload.CreatedBy = adminActor(r)
+	res, err := c.apiKeys.Create(r.Context(), payload)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	res, err := c.apiKeys.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := c.apiKeys.Revoke(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apikeys.ErrInvalidKey):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, apikeys.ErrKeyNotFound):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
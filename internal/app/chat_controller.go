@@ -0,0 +1,106 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/chat"
+)
+
+// chat endpoints
+func (c *Controller) ListReportedMessages(w http.ResponseWriter, r *http.Request) {
+	res, err := c.chat.Reported(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) DeleteChatMessage(w http.ResponseWriter, r *http.Request) {
+	if err := c.chat.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) PostChatMessage(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var payload chat.MessagePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.chat.Post(r.Context(), token.UserID, chi.URLParam(r, "id"), payload)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+// ListChatMessages long-polls when the wait query parameter is given with a cursor
+func (c *Controller) ListChatMessages(w http.ResponseWriter, r *http.Request) {
+	var wait time.Duration
+	if s := r.URL.Query().Get("wait"); s != "" {
+		var err error
+		if wait, err = time.ParseDuration(s); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	res, err := c.chat.List(r.Context(), chi.URLParam(r, "id"), r.URL.Query().Get("cursor"), wait)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ReportChatMessage(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var payload chat.ReportPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.chat.Report(r.Context(), token.UserID, chi.URLParam(r, "id"), payload); err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func writeChatError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, chat.ErrInvalidMessage),
+		errors.Is(err, chat.ErrInvalidCursor),
+		errors.Is(err, chat.ErrInvalidReport),
+		errors.Is(err, chat.ErrRejected):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, chat.ErrNotPresent):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, chat.ErrRoomNotFound),
+		errors.Is(err, chat.ErrMessageNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, chat.ErrAlreadyReported):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, chat.ErrRateLimited):
+		writeError(w, http.StatusTooManyRequests, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -1 +1,323 @@
 package app
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+	"geogame/internal/lockout"
+	"geogame/internal/players"
+)
+
+// client endpoints
+func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
+	var payload players.RegisterPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.Email == "" {
+		c.logger.Error("Register: failed to register", zap.Error(errors.New("empty email id")))
+		writeError(w, http.StatusBadRequest, errors.New("empty email id"))
+		return
+	}
+	if payload.Password == "" {
+		c.logger.Error("Register: failed to register", zap.Error(errors.New("empty password")))
+		writeError(w, http.StatusBadRequest, errors.New("empty password id"))
+		return
+	}
+	if payload.Name == "" {
+		c.logger.Error("Register: failed to register", zap.Error(errors.New("empty name")))
+		writeError(w, http.StatusBadRequest, errors.New("empty name"))
+		return
+	}
+	if err := c.players.Register(r.Context(), payload); err != nil {
+		if writePasswordError(w, err) {
+			return
+		}
+		writeHandleError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, &SuccessResponse{Ok: "success"})
+
+}
+
+func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
+	var payload players.LoginPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.Email == "" {
+		c.logger.Error("Login: failed to register", zap.Error(errors.New("empty email id")))
+		writeError(w, http.StatusBadRequest, errors.New("empty email id"))
+		return
+	}
+	if payload.Password == "" {
+		c.logger.Error("Login: failed to register", zap.Error(errors.New("empty password")))
+		writeError(w, http.StatusBadRequest, errors.New("empty password id"))
+		return
+	}
+	payload.RemoteIP = remoteIP(r)
+	res, err := c.players.Login(r.Context(), payload)
+	if err != nil {
+		var locked *lockout.LockedError
+		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, err)
+		case errors.Is(err, players.ErrInvalidCredentials):
+			writeError(w, http.StatusUnauthorized, err)
+		case errors.Is(err, players.ErrEmailNotVerified), isRestrictedError(err):
+			writeError(w, http.StatusForbidden, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	// c.logger.Info("res",zap.Any("jwt",res))
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var payload players.RefreshPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.players.Refresh(r.Context(), payload)
+	if err != nil {
+		if errors.Is(err, players.ErrInvalidRefreshToken) || errors.Is(err, players.ErrRefreshTokenReused) {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if isRestrictedError(err) {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) Logout(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := c.players.Logout(r.Context(), token.Id); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	c.revocations.MarkRevoked(token.Id)
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) SendLocation(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p locations.Location
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := c.players.UpdateLocation(r.Context(), p, token.UserID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) UpdateName(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.UpdatePayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if p.Name == "" {
+		writeError(w, http.StatusBadRequest, errors.New("empty name"))
+		return
+	}
+
+	if err := c.players.UpdateName(r.Context(), p, token.UserID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) GetClientLocation(w http.ResponseWriter, r *http.Request) {
+
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.players.GetLocation(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.ChangePasswordPayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if p.CurrentPassword == "" || p.NewPassword == "" {
+		writeError(w, http.StatusBadRequest, errors.New("empty password"))
+		return
+	}
+	revoked, err := c.players.ChangePassword(r.Context(), p, token.UserID, token.Id)
+	if err != nil {
+		writeCredentialError(w, err)
+		return
+	}
+	for _, id := range revoked {
+		c.revocations.MarkRevoked(id)
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.ChangeEmailPayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if p.Email == "" || p.Password == "" {
+		writeError(w, http.StatusBadRequest, errors.New("empty email id or password"))
+		return
+	}
+	if err := c.players.ChangeEmail(r.Context(), p, token.UserID); err != nil {
+		writeCredentialError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	if err := c.players.ConfirmEmailChange(r.Context(), r.URL.Query().Get("token")); err != nil {
+		writeCredentialError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func writeCredentialError(w http.ResponseWriter, err error) {
+	if writePasswordError(w, err) {
+		return
+	}
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, err)
+	case errors.Is(err, players.ErrWrongPassword), errors.Is(err, players.ErrGuestAccount):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, players.ErrNotGuest):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, players.ErrInvalidEmail),
+		errors.Is(err, players.ErrInvalidVerificationToken):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, players.ErrEmailTaken):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func (c *Controller) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if err := c.players.Verify(r.Context(), token); err != nil {
+		if errors.Is(err, players.ErrInvalidVerificationToken) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var payload players.ResendVerificationPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.Email == "" {
+		writeError(w, http.StatusBadRequest, errors.New("empty email id"))
+		return
+	}
+	if err := c.players.ResendVerification(r.Context(), payload); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) GetPrivacy(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.players.GetPrivacy(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) UpdatePrivacy(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.PrivacySettings
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.players.UpdatePrivacy(r.Context(), p, token.UserID); err != nil {
+		if errors.Is(err, players.ErrInvalidPrivacySettings) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
@@ -3,14 +3,29 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
 
+	"geogame/internal/achievements"
+	"geogame/internal/admins"
+	"geogame/internal/apikeys"
+	"geogame/internal/chat"
+	"geogame/internal/checkins"
+	"geogame/internal/encounters"
+	"geogame/internal/factions"
+	"geogame/internal/geofences"
+	"geogame/internal/items"
 	"geogame/internal/locations"
 	"geogame/internal/middleware"
+	"geogame/internal/notifications"
+	"geogame/internal/oidc"
+	"geogame/internal/passwords"
 	"geogame/internal/players"
+	"geogame/internal/quests"
+	"geogame/internal/realtime"
 	"geogame/pkg"
 )
 
@@ -21,218 +36,463 @@ const (
 
 // type assert the main controller which extend the chi controller
 var _ pkg.Controller = (*Controller)(nil)
+var _ pkg.RootController = (*Controller)(nil)
+var _ pkg.Drainer = (*Controller)(nil)
 
 type Controller struct {
-	logger    *zap.Logger
-	locations locations.Service
-	players   players.Service
-	jwtAuther middleware.JwtAuther
+	logger        *zap.Logger
+	locations     locations.Service
+	players       players.Service
+	jwtAuther     middleware.JwtAuther
+	revocations   *middleware.RevocationCache
+	standings     *middleware.StandingCache
+	adminRoles    *middleware.AdminRoleCache
+	checkIns      checkins.Service
+	factions      factions.Service
+	quests        quests.Service
+	items         items.Service
+	achievements  achievements.Service
+	encounters    encounters.Service
+	geofences     geofences.Service
+	gateway       *realtime.Gateway
+	notifications notifications.Service
+	chat          chat.Service
+	admins        admins.Service
+	apiKeys       apikeys.Service
+	oidc          oidc.Service
+	clientCerts   bool
+	certDenyList  *middleware.CertDenyList
 }
 
-func NewController(logger *zap.Logger, locations locations.Service, players players.Service, jwtAuther middleware.JwtAuther) *Controller {
-	return &Controller{
-		logger:    logger,
-		locations: locations,
-		players:   players,
-		jwtAuther: jwtAuther,
+type Option func(*Controller)
+
+// WithRevocationCache enables the session revocation check on the client endpoints
+func WithRevocationCache(cache *middleware.RevocationCache) Option {
+	return func(c *Controller) {
+		c.revocations = cache
 	}
 }
 
-// extend the chi controller init
-func (c *Controller) Init(logger *zap.Logger) error {
-	c.logger = logger
-	return nil
+// WithStandingCache rejects the suspended or banned players on the client endpoints
+func WithStandingCache(cache *middleware.StandingCache) Option {
+	return func(c *Controller) {
+		c.standings = cache
+	}
 }
 
-// extend the chi controller
-// Setup the chi routes
-func (c *Controller) SetupRouter(router chi.Router) error {
-
-	// Register admin endpoints
-	router.Route("/admin/loc", func(r chi.Router) {
-		r.Post("/create", c.CreateLocation)
-		r.Get("/{id}", c.GetLocation)
-		r.Put("/update", c.UpdateLocation)
-		r.Delete("/{id}/delete", c.DeleteLocation)
-	})
-
-	// Register client endpoints
-	router.Route("/client", func(r chi.Router) {
-		r.Post("/register", c.Register)
-		r.Post("/login", c.Login)
-		r.With(middleware.IsClientAllowed(c.jwtAuther)).Post("/loc/send", c.SendLocation)
-		r.With(middleware.IsClientAllowed(c.jwtAuther)).Put("/update-name", c.UpdateName)
-		r.With(middleware.IsClientAllowed(c.jwtAuther)).Get("/loc/get", c.GetClientLocation)
-	})
-
-	return nil
+// WithAdminRoleCache applies the role changes and deletions of the admins to their issued tokens
+func WithAdminRoleCache(cache *middleware.AdminRoleCache) Option {
+	return func(c *Controller) {
+		c.adminRoles = cache
+	}
 }
 
-// extend the chi controller init
-func (c *Controller) Terminate() error {
-	return nil
+// WithCheckIns enables the location check-in endpoint
+func WithCheckIns(s checkins.Service) Option {
+	return func(c *Controller) {
+		c.checkIns = s
+	}
 }
 
-// admin endpoints
-func (c *Controller) CreateLocation(w http.ResponseWriter, r *http.Request) {
-	var payload locations.Location
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+// WithFactions enables the faction and territory endpoints
+func WithFactions(s factions.Service) Option {
+	return func(c *Controller) {
+		c.factions = s
 	}
+}
 
-	if err := c.locations.Create(r.Context(), payload); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// WithQuests enables the quest endpoints
+func WithQuests(s quests.Service) Option {
+	return func(c *Controller) {
+		c.quests = s
 	}
-	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
 }
 
-func (c *Controller) GetLocation(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	res, err := c.locations.Get(r.Context(), id)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// WithItems enables the item spawn and inventory endpoints
+func WithItems(s items.Service) Option {
+	return func(c *Controller) {
+		c.items = s
 	}
-	writeResponse(w, http.StatusOK, res)
 }
 
-func (c *Controller) UpdateLocation(w http.ResponseWriter, r *http.Request) {
-	var payload locations.Location
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+// WithAchievements enables the achievement endpoints
+func WithAchievements(s achievements.Service) Option {
+	return func(c *Controller) {
+		c.achievements = s
 	}
+}
 
-	if err := c.locations.Update(r.Context(), payload); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// WithEncounters enables the encounter endpoints
+func WithEncounters(s encounters.Service) Option {
+	return func(c *Controller) {
+		c.encounters = s
 	}
-	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
 }
 
-func (c *Controller) DeleteLocation(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if err := c.locations.Delete(r.Context(), id); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// WithGeofences enables the geofence endpoints
+func WithGeofences(s geofences.Service) Option {
+	return func(c *Controller) {
+		c.geofences = s
 	}
-	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
 }
 
-// client endpoints
-
-func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
-	var payload players.RegisterPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeResponse(w, http.StatusBadRequest, err)
-		return
+// WithNotifications enables the notification inbox and device endpoints
+func WithNotifications(s notifications.Service) Option {
+	return func(c *Controller) {
+		c.notifications = s
 	}
-	if payload.Email == "" {
-		c.logger.Error("Register: failed to register", zap.Error(errors.New("empty email id")))
-		writeError(w, http.StatusBadRequest, errors.New("empty email id"))
-		return
-	}
-	if payload.Password == "" {
-		c.logger.Error("Register: failed to register", zap.Error(errors.New("empty password")))
-		writeError(w, http.StatusBadRequest, errors.New("empty password id"))
-		return
-	}
-	if payload.Name == "" {
-		c.logger.Error("Register: failed to register", zap.Error(errors.New("empty name")))
-		writeError(w, http.StatusBadRequest, errors.New("empty name"))
-		return
-	}
-	if err := c.players.Register(r.Context(), payload); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	writeResponse(w, http.StatusOK, &SuccessResponse{Ok: "success"})
-
 }
 
-func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
-	var payload players.LoginPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
-	}
-	if payload.Email == "" {
-		c.logger.Error("Login: failed to register", zap.Error(errors.New("empty email id")))
-		writeError(w, http.StatusBadRequest, errors.New("empty email id"))
-		return
+// WithChat enables the location chat endpoints
+func WithChat(s chat.Service) Option {
+	return func(c *Controller) {
+		c.chat = s
 	}
-	if payload.Password == "" {
-		c.logger.Error("Login: failed to register", zap.Error(errors.New("empty password")))
-		writeError(w, http.StatusBadRequest, errors.New("empty password id"))
-		return
+}
+
+// WithAdmins enables the admin login and admin account endpoints
+func WithAdmins(s admins.Service) Option {
+	return func(c *Controller) {
+		c.admins = s
 	}
-	res, err := c.players.Login(r.Context(), payload)
-	if err != nil {
+}
 
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// WithAPIKeys enables the API key endpoints and accepts the X-API-Key header on the admin routes
+func WithAPIKeys(s apikeys.Service) Option {
+	return func(c *Controller) {
+		c.apiKeys = s
 	}
-	// c.logger.Info("res",zap.Any("jwt",res))
-	writeResponse(w, http.StatusOK, *res)
 }
 
-func (c *Controller) SendLocation(w http.ResponseWriter, r *http.Request) {
-	token, err := extractTokenFromContext(r)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// WithOIDC enables the login with an OpenID Connect provider
+func WithOIDC(s oidc.Service) Option {
+	return func(c *Controller) {
+		c.oidc = s
 	}
-	var p locations.Location
-	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+}
+
+// WithClientCerts requires a verified client certificate, not on the deny list, on every admin route
+func WithClientCerts(denyList *middleware.CertDenyList) Option {
+	return func(c *Controller) {
+		c.clientCerts = true
+		c.certDenyList = denyList
 	}
+}
 
-	if err := c.players.UpdateLocation(r.Context(), p, token.UserID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// WithGateway enables the websocket endpoint
+func WithGateway(g *realtime.Gateway) Option {
+	return func(c *Controller) {
+		c.gateway = g
 	}
-	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
 }
 
-func (c *Controller) UpdateName(w http.ResponseWriter, r *http.Request) {
-	token, err := extractTokenFromContext(r)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+func NewController(logger *zap.Logger, locations locations.Service, players players.Service, jwtAuther middleware.JwtAuther, options ...Option) *Controller {
+	c := &Controller{
+		logger:    logger,
+		locations: locations,
+		players:   players,
+		jwtAuther: jwtAuther,
 	}
-	var p players.UpdatePayload
-	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+	for _, opt := range options {
+		opt(c)
 	}
-	if p.Name == "" {
-		writeError(w, http.StatusBadRequest, errors.New("empty name"))
-		return
+	return c
+}
+
+// extend the chi controller init
+func (c *Controller) Init(logger *zap.Logger) error {
+	c.logger = logger
+	return nil
+}
+
+// extend the chi controller
+// Setup the chi routes
+func (c *Controller) SetupRouter(router chi.Router) error {
+
+	// Register admin endpoints, every admin route requires an admin token or an API key granting the permission
+	var roles middleware.AdminRoleChecker
+	if c.adminRoles != nil {
+		roles = c.adminRoles
 	}
+	adminAuth := middleware.IsAdminAllowed(c.jwtAuther, c.apiKeys, roles)
+	can := middleware.RequirePermission
+	router.Group(func(router chi.Router) {
+		if c.clientCerts {
+			router.Use(middleware.RequireClientCert(c.certDenyList), c.auditAdmin)
+		}
+		if c.admins != nil {
+			router.Post("/admin/login", c.AdminLogin)
+			router.Post("/admin/login/2fa", c.AdminVerifyTwoFactor)
+			router.Route("/admin/2fa", func(r chi.Router) {
+				r.Use(adminAuth)
+				r.Post("/enroll", c.AdminEnrollTwoFactor)
+				r.Post("/confirm", c.AdminConfirmTwoFactor)
+				r.Post("/disable", c.AdminDisableTwoFactor)
+			})
+			router.Route("/admin/admins", func(r chi.Router) {
+				r.Use(adminAuth, can(middleware.PermAdminsManage))
+				r.Post("/create", c.CreateAdmin)
+				r.Get("/", c.ListAdmins)
+				r.Put("/{id}/role", c.UpdateAdminRole)
+				r.Delete("/{id}/delete", c.DeleteAdmin)
+			})
+		}
+		if c.apiKeys != nil {
+			router.Route("/admin/apikeys", func(r chi.Router) {
+				r.Use(adminAuth, can(middleware.PermAdminsManage))
+				r.Post("/create", c.CreateAPIKey)
+				r.Get("/", c.ListAPIKeys)
+				r.Post("/{id}/revoke", c.RevokeAPIKey)
+			})
+		}
+		router.Route("/admin/loc", func(r chi.Router) {
+			r.Use(adminAuth)
+			r.With(can(middleware.PermLocationsWrite)).Post("/create", c.CreateLocation)
+			r.With(can(middleware.PermLocationsImport)).Post("/import", c.ImportLocations)
+			r.With(can(middleware.PermLocationsRead)).Get("/{id}", c.GetLocation)
+			r.With(can(middleware.PermLocationsWrite)).Put("/update", c.UpdateLocation)
+			r.With(can(middleware.PermLocationsWrite)).Delete("/{id}/delete", c.DeleteLocation)
+		})
+		router.Route("/admin/players", func(r chi.Router) {
+			r.Use(adminAuth)
+			r.With(can(middleware.PermPlayersWrite)).Post("/unlock", c.UnlockPlayer)
+			r.With(can(middleware.PermPlayersRead)).Get("/", c.SearchPlayers)
+			r.With(can(middleware.PermPlayersRead)).Get("/{id}", c.GetPlayer)
+			r.With(can(middleware.PermPlayersRead)).Get("/{id}/location", c.GetPlayerLocation)
+			r.With(can(middleware.PermPlayersRead)).Get("/{id}/audit", c.GetPlayerAudit)
+			r.With(can(middleware.PermPlayersWrite)).Post("/{id}/notes", c.AddPlayerNote)
+			r.With(can(middleware.PermPlayersWrite)).Post("/{id}/logout", c.ForceLogoutPlayer)
+			r.With(can(middleware.PermPlayersWrite)).Post("/{id}/suspend", c.SuspendPlayer)
+			r.With(can(middleware.PermPlayersBan)).Post("/{id}/ban", c.BanPlayer)
+			r.With(can(middleware.PermPlayersBan)).Post("/{id}/reinstate", c.ReinstatePlayer)
+		})
+		if c.quests != nil {
+			router.Route("/admin/quests", func(r chi.Router) {
+				r.Use(adminAuth)
+				r.With(can(middleware.PermContentWrite)).Post("/create", c.CreateQuest)
+				r.With(can(middleware.PermContentRead)).Get("/", c.ListQuests)
+				r.With(can(middleware.PermContentRead)).Get("/{id}", c.GetQuest)
+				r.With(can(middleware.PermContentWrite)).Put("/update", c.UpdateQuest)
+				r.With(can(middleware.PermContentWrite)).Delete("/{id}/delete", c.DeleteQuest)
+			})
+		}
+		if c.items != nil {
+			router.Route("/admin/items", func(r chi.Router) {
+				r.Use(adminAuth)
+				r.With(can(middleware.PermContentWrite)).Post("/create", c.CreateItem)
+				r.With(can(middleware.PermContentRead)).Get("/", c.ListItems)
+				r.With(can(middleware.PermContentWrite)).Post("/rules/create", c.CreateSpawnRule)
+				r.With(can(middleware.PermContentRead)).Get("/rules", c.ListSpawnRules)
+				r.With(can(middleware.PermContentWrite)).Delete("/rules/{id}/delete", c.DeleteSpawnRule)
+			})
+		}
+		if c.achievements != nil {
+			router.Route("/admin/achievements", func(r chi.Router) {
+				r.Use(adminAuth)
+				r.With(can(middleware.PermContentWrite)).Post("/create", c.CreateAchievement)
+				r.With(can(middleware.PermContentRead)).Get("/", c.ListAchievements)
+				r.With(can(middleware.PermContentWrite)).Delete("/{id}/delete", c.DeleteAchievement)
+				r.With(can(middleware.PermContentWrite)).Post("/{id}/backfill", c.BackfillAchievement)
+			})
+		}
+		if c.geofences != nil {
+			router.Route("/admin/geofences", func(r chi.Router) {
+				r.Use(adminAuth)
+				r.With(can(middleware.PermContentWrite)).Post("/create", c.CreateGeofence)
+				r.With(can(middleware.PermContentRead)).Get("/", c.ListGeofences)
+				r.With(can(middleware.PermContentWrite)).Delete("/{id}/delete", c.DeleteGeofence)
+				r.With(can(middleware.PermPlayersRead)).Get("/players/{id}/events", c.GetPlayerGeofenceEvents)
+			})
+		}
+		if c.notifications != nil {
+			router.Route("/admin/notifications", func(r chi.Router) {
+				r.Use(adminAuth, can(middleware.PermNotificationSend))
+				r.Post("/send", c.SendNotification)
+				r.Get("/dead", c.ListDeadNotifications)
+				r.Post("/dead/{id}/retry", c.RetryNotification)
+			})
+		}
+		if c.chat != nil {
+			router.Route("/admin/chat", func(r chi.Router) {
+				r.Use(adminAuth, can(middleware.PermChatModerate))
+				r.Get("/reported", c.ListReportedMessages)
+				r.Delete("/messages/{id}/delete", c.DeleteChatMessage)
+			})
+		}
+	})
 
-	if err := c.players.UpdateName(r.Context(), p, token.UserID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	// Register client endpoints
+	clientAuth := middleware.IsClientAllowed(c.jwtAuther, c.revocations, c.standings)
+	router.Route("/client", func(r chi.Router) {
+		r.Post("/register", c.Register)
+		r.Post("/login", c.Login)
+		r.Post("/login/2fa", c.VerifyTwoFactor)
+		r.Get("/verify", c.VerifyEmail)
+		r.Get("/email/verify", c.ConfirmEmailChange)
+		r.Post("/verify/resend", c.ResendVerification)
+		r.Post("/token/refresh", c.RefreshToken)
+		r.Post("/guest", c.CreateGuest)
+		r.Post("/guest/login", c.GuestLogin)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeGuest)).Post("/upgrade", c.UpgradeGuest)
+		if c.oidc != nil {
+			r.Get("/oidc/{provider}/start", c.StartOIDCLogin)
+			r.Get("/oidc/callback", c.OIDCCallback)
+			r.With(clientAuth, middleware.RequireScope(middleware.ScopeGuest)).Get("/upgrade/oidc/{provider}/start", c.StartOIDCUpgrade)
+		}
+		r.With(clientAuth).Post("/logout", c.Logout)
+		r.Route("/2fa", func(r chi.Router) {
+			r.Use(clientAuth, middleware.RequireScope(middleware.ScopeProfile))
+			r.Post("/enroll", c.EnrollTwoFactor)
+			r.Post("/confirm", c.ConfirmTwoFactor)
+			r.Post("/disable", c.DisableTwoFactor)
+		})
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopePlay)).Post("/loc/send", c.SendLocation)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Put("/update-name", c.UpdateName)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Put("/password", c.ChangePassword)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Put("/email", c.ChangeEmail)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Get("/handle", c.GetHandle)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Put("/handle", c.ChangeHandle)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopePlay)).Get("/players/{handle}", c.GetPublicProfile)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Get("/loc/get", c.GetClientLocation)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Get("/privacy", c.GetPrivacy)
+		r.With(clientAuth, middleware.RequireScope(middleware.ScopeProfile)).Put("/privacy", c.UpdatePrivacy)
+		if c.checkIns != nil {
+			r.With(clientAuth, middleware.RequireScope(middleware.ScopePlay)).Post("/loc/{id}/checkin", c.CheckIn)
+			r.With(clientAuth, middleware.RequireScope(middleware.ScopePlay)).Get("/checkins", c.ListCheckIns)
+		}
+		if c.factions != nil {
+			r.Route("/factions", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Get("/", c.ListFactions)
+				r.Post("/join", c.JoinFaction)
+				r.Get("/me", c.GetMembership)
+				r.Get("/standings", c.GetStandings)
+				r.Get("/map", c.GetControlMap)
+			})
+		}
+		if c.quests != nil {
+			r.Route("/quests", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Get("/", c.ListPlayerQuests)
+				r.Post("/{id}/start", c.StartQuest)
+				r.Get("/{id}", c.GetQuestProgress)
+			})
+		}
+		if c.items != nil {
+			r.Route("/items", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Get("/nearby", c.NearbyItems)
+				r.Post("/{id}/pickup", c.PickUpItem)
+			})
+			r.Route("/inventory", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Get("/", c.GetInventory)
+				r.Post("/{id}/drop", c.DropItem)
+				r.Post("/{id}/consume", c.ConsumeItem)
+			})
+		}
+		if c.achievements != nil {
+			r.Route("/achievements", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopeProfile))
+				r.Get("/", c.EarnedAchievements)
+				r.Get("/progress", c.AchievementProgress)
+			})
+		}
+		if c.encounters != nil {
+			r.Route("/encounters", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Get("/", c.ListEncounters)
+			})
+		}
+		if c.gateway != nil {
+			r.With(middleware.QueryToken("access_token"), clientAuth, middleware.RequireScope(middleware.ScopePlay)).Get("/ws", c.ServeWebSocket)
+		}
+		if c.geofences != nil {
+			r.With(clientAuth, middleware.RequireScope(middleware.ScopePlay)).Get("/geofences/events", c.ListGeofenceEvents)
+		}
+		if c.notifications != nil {
+			r.Route("/notifications", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Get("/", c.ListNotifications)
+				r.Post("/{id}/read", c.ReadNotification)
+				r.Post("/read-all", c.ReadAllNotifications)
+				r.Get("/quiet-hours", c.GetQuietHours)
+				r.Put("/quiet-hours", c.UpdateQuietHours)
+			})
+			r.Route("/devices", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Post("/", c.RegisterDevice)
+				r.Delete("/{token}", c.UnregisterDevice)
+			})
+		}
+		if c.chat != nil {
+			r.Route("/chat", func(r chi.Router) {
+				r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+				r.Get("/{id}/messages", c.ListChatMessages)
+				r.Post("/{id}/messages", c.PostChatMessage)
+				r.Post("/messages/{id}/report", c.ReportChatMessage)
+			})
+		}
+		r.Route("/friends", func(r chi.Router) {
+			r.Use(clientAuth, middleware.RequireScope(middleware.ScopePlay))
+			r.Get("/", c.ListFriends)
+			r.Get("/locations", c.GetFriendLocations)
+			r.Put("/sharing", c.UpdateLocationSharing)
+			r.Post("/request", c.RequestFriend)
+			r.Post("/{id}/accept", c.AcceptFriend)
+			r.Post("/{id}/decline", c.DeclineFriend)
+			r.Delete("/{id}", c.RemoveFriend)
+			r.Post("/{id}/block", c.BlockPlayer)
+			r.Delete("/{id}/block", c.UnblockPlayer)
+		})
+	})
+
+	return nil
+}
+
+// Setup the routes served outside of the /v1 prefix
+func (c *Controller) SetupRootRouter(router chi.Router) error {
+	if _, ok := c.jwtAuther.(middleware.JWKSProvider); ok {
+		router.Get("/.well-known/jwks.json", c.JWKS)
 	}
-	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+	return nil
 }
 
-func (c *Controller) GetClientLocation(w http.ResponseWriter, r *http.Request) {
+// extend the chi controller init
+func (c *Controller) Terminate() error {
+	return nil
+}
 
-	token, err := extractTokenFromContext(r)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// JWKS publishes the public keys verifying the player tokens
+func (c *Controller) JWKS(w http.ResponseWriter, r *http.Request) {
+	provider := c.jwtAuther.(middleware.JWKSProvider)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeResponse(w, http.StatusOK, provider.JWKS())
+}
+
+// auditAdmin logs the admin requests with the identity of the client certificate
+func (c *Controller) auditAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cert, ok := r.Context().Value("AdminCert").(*middleware.ClientCert); ok {
+			c.logger.Info("admin request", zap.String("identity", cert.Identity), zap.String("serial", cert.Serial),
+				zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.String("ip", remoteIP(r)))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminActor names the admin in the audit records, the certificate identity is preferred over the admin account
+func adminActor(r *http.Request) string {
+	if cert, ok := r.Context().Value("AdminCert").(*middleware.ClientCert); ok && cert != nil {
+		return cert.Identity
 	}
-	res, err := c.players.GetLocation(r.Context(), token.UserID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	if token, ok := r.Context().Value("AdminToken").(*middleware.AdminToken); ok && token != nil {
+		return "admin:" + token.AdminID
 	}
-	writeResponse(w, http.StatusOK, res)
+	if key, ok := r.Context().Value("APIKey").(*middleware.APIKey); ok && key != nil {
+		return "apikey:" + key.ID
+	}
+	return remoteIP(r)
 }
 
 func extractTokenFromContext(r *http.Request) (*middleware.AccessToken, error) {
@@ -243,6 +503,15 @@ func extractTokenFromContext(r *http.Request) (*middleware.AccessToken, error) {
 	return token, nil
 }
 
+// remoteIP is the address of the peer, proxies in front of the service are expected to rewrite it
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func writeResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set(HTTPContentType, HTTPApplicationJSON)
 	w.WriteHeader(statusCode)
@@ -268,6 +537,27 @@ type SuccessResponse struct {
 	Ok string
 }
 
+// ImportResponse tells how many locations a bulk import created
+type ImportResponse struct {
+	Imported int `json:"imported"`
+}
+
 type ErrorResponse struct {
 	Error string
 }
+
+// ValidationErrorResponse lists the rules the submitted password breaks
+type ValidationErrorResponse struct {
+	Error      string
+	Violations []passwords.Violation
+}
+
+// writePasswordError writes the rejected password errors, it reports whether err was one
+func writePasswordError(w http.ResponseWriter, err error) bool {
+	var weak *passwords.ValidationError
+	if !errors.As(err, &weak) {
+		return false
+	}
+	writeResponse(w, http.StatusBadRequest, ValidationErrorResponse{Error: passwords.ErrWeakPassword.Error(), Violations: weak.Violations})
+	return true
+}
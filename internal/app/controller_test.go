@@ -2,25 +2,45 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
 
+	"geogame/internal/achievements"
+	"geogame/internal/admins"
+	"geogame/internal/apikeys"
+	"geogame/internal/chat"
+	"geogame/internal/checkins"
+	"geogame/internal/encounters"
+	"geogame/internal/factions"
+	"geogame/internal/geofences"
+	"geogame/internal/items"
 	"geogame/internal/locations"
 	"geogame/internal/middleware"
+	"geogame/internal/notifications"
+	"geogame/internal/oidc"
+	"geogame/internal/passwords"
 	"geogame/internal/players"
+	"geogame/internal/push"
+	"geogame/internal/quests"
+	"geogame/internal/realtime"
 )
 
 type testControllerSuite struct {
 	suite.Suite
 	recorder *httptest.ResponseRecorder
 	router   chi.Router
+	auther   *middleware.MockAuther
+	apiKeys  *apikeys.DefaultService
 }
 
 func TestControllerSuite(t *testing.T) {
@@ -36,11 +56,49 @@ func (suite *testControllerSuite) SetupTest() {
 	playersStore := players.NewMockStore()
 	playersSvc := players.NewDefaultService(zap.NewNop(), playersStore, time.Second*3, "")
 
-	mockAuther := middleware.NewMockAuther()
-	controller := NewController(zap.NewNop(), locationsSvc, playersSvc, mockAuther)
+	factionsSvc := factions.NewDefaultService(zap.NewNop(), factions.NewMockStore(), factions.DefaultConfig(), time.Second*3)
+	itemsSvc := items.NewDefaultService(zap.NewNop(), items.NewMockStore(), playersSvc, locationsSvc, items.DefaultConfig(), time.Second*3)
+	achievementsSvc := achievements.NewDefaultService(zap.NewNop(), achievements.NewMockStore(), checkins.NewHistory(zap.NewNop(), checkins.NewMockStore(), time.Second*3), achievements.DefaultConfig(), time.Second*3)
+	questsSvc := quests.NewDefaultService(zap.NewNop(), quests.NewMockStore(), locationsSvc, time.Second*3)
+	encountersSvc := encounters.NewDefaultService(zap.NewNop(), encounters.NewMockStore(), playersStore, encounters.DefaultConfig(), time.Second*3)
+	geofencesSvc := geofences.NewDefaultService(zap.NewNop(), geofences.NewMockStore(), locationsSvc, geofences.DefaultConfig(), time.Second*3)
+	checkInsSvc := checkins.NewDefaultService(zap.NewNop(), checkins.NewMockStore(), playersSvc, locationsSvc, checkins.DefaultConfig(), time.Second*3)
+	chatSvc := chat.NewDefaultService(zap.NewNop(), chat.NewMockStore(), playersStore, checkins.NewMockStore(), locationsSvc, chat.DefaultConfig(), time.Second*3)
+	notificationsSvc := notifications.NewDefaultService(zap.NewNop(), notifications.NewMockStore(), push.NewMockSender(), notifications.DefaultConfig(), time.Second*3)
+
+	adminsSvc := admins.NewDefaultService(zap.NewNop(), admins.NewMockStore(), middleware.NewJwtKey("secret"), time.Second*3)
+
+	suite.apiKeys = apikeys.NewDefaultService(zap.NewNop(), apikeys.NewMemStore(), apikeys.DefaultConfig(), time.Second*3)
+
+	oidcSvc := oidc.NewDefaultService(zap.NewNop(), oidc.NewMockStore(), playersSvc, oidc.DefaultConfig(), time.Second*3)
+
+	suite.auther = middleware.NewMockAdminAuther(middleware.RoleSuperAdmin)
+	mockAuther := suite.auther
+	controller := NewController(zap.NewNop(), locationsSvc, playersSvc, mockAuther,
+		WithCheckIns(checkInsSvc),
+		WithFactions(factionsSvc),
+		WithQuests(questsSvc),
+		WithItems(itemsSvc),
+		WithAchievements(achievementsSvc),
+		WithEncounters(encountersSvc),
+		WithGeofences(geofencesSvc),
+		WithGateway(realtime.NewGateway(zap.NewNop(), realtime.DefaultConfig())),
+		WithNotifications(notificationsSvc),
+		WithChat(chatSvc),
+		WithAdmins(adminsSvc),
+		WithAPIKeys(suite.apiKeys),
+		WithOIDC(oidcSvc),
+	)
 	controller.SetupRouter(suite.router)
 }
 
+// adminRequest builds a request carrying an admin token, the mock auther gives it the role of the suite
+func (suite *testControllerSuite) adminRequest(method, target string, body io.Reader) *http.Request {
+	request := httptest.NewRequest(method, target, body)
+	request.Header.Set("Authorization", "Bearer admin-token")
+	return request
+}
+
 func (suite *testControllerSuite) TestController_CreateLocation() {
 	req := suite.Require()
 	payload := locations.Location{
@@ -58,7 +116,7 @@ func (suite *testControllerSuite) TestController_CreateLocation() {
 	req.NoError(err)
 
 	buffer := bytes.NewBuffer(bs)
-	request := httptest.NewRequest("POST", "/admin/loc/create", buffer)
+	request := suite.adminRequest("POST", "/admin/loc/create", buffer)
 
 	suite.router.ServeHTTP(suite.recorder, request)
 	response := suite.recorder.Result()
@@ -68,7 +126,7 @@ func (suite *testControllerSuite) TestController_CreateLocation() {
 func (suite *testControllerSuite) TestController_GetLocation() {
 	req := suite.Require()
 
-	request := httptest.NewRequest("GET", "/admin/loc/1", nil)
+	request := suite.adminRequest("GET", "/admin/loc/1", nil)
 
 	suite.router.ServeHTTP(suite.recorder, request)
 	response := suite.recorder.Result()
@@ -92,7 +150,7 @@ func (suite *testControllerSuite) TestController_UpdateLocation() {
 	req.NoError(err)
 
 	buffer := bytes.NewBuffer(bs)
-	request := httptest.NewRequest("PUT", "/admin/loc/update", buffer)
+	request := suite.adminRequest("PUT", "/admin/loc/update", buffer)
 
 	suite.router.ServeHTTP(suite.recorder, request)
 	response := suite.recorder.Result()
@@ -102,7 +160,7 @@ func (suite *testControllerSuite) TestController_UpdateLocation() {
 func (suite *testControllerSuite) TestController_DeleteLocation() {
 	req := suite.Require()
 
-	request := httptest.NewRequest("DELETE", "/admin/loc/1/delete", nil)
+	request := suite.adminRequest("DELETE", "/admin/loc/1/delete", nil)
 
 	suite.router.ServeHTTP(suite.recorder, request)
 	response := suite.recorder.Result()
@@ -127,6 +185,24 @@ func (suite *testControllerSuite) TestController_RegisterClient() {
 	req.Equal(http.StatusOK, response.StatusCode)
 }
 
+func (suite *testControllerSuite) TestController_RegisterClient_WeakPassword() {
+	req := suite.Require()
+	bs, err := json.Marshal(players.RegisterPayload{Name: "dummyname", Email: "weak@mail.com", Password: "qwerty"})
+	req.NoError(err)
+
+	request := httptest.NewRequest("POST", "/client/register", bytes.NewBuffer(bs))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+	var res ValidationErrorResponse
+	req.NoError(json.NewDecoder(response.Body).Decode(&res))
+	req.Equal(passwords.ErrWeakPassword.Error(), res.Error)
+	req.Len(res.Violations, 2)
+	req.Equal(passwords.CodeTooShort, res.Violations[0].Code)
+	req.Equal(passwords.CodeCommon, res.Violations[1].Code)
+}
+
 func (suite *testControllerSuite) TestController_LoginClient() {
 	req := suite.Require()
 	payload := players.LoginPayload{
@@ -141,7 +217,7 @@ func (suite *testControllerSuite) TestController_LoginClient() {
 
 	suite.router.ServeHTTP(suite.recorder, request)
 	response := suite.recorder.Result()
-	req.Equal(http.StatusInternalServerError, response.StatusCode)
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
 }
 
 func (suite *testControllerSuite) TestController_SendLocation() {
@@ -167,3 +243,535 @@ func (suite *testControllerSuite) TestController_SendLocation() {
 	response := suite.recorder.Result()
 	req.Equal(http.StatusNotFound, response.StatusCode)
 }
+
+func (suite *testControllerSuite) TestController_VerifyEmail() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/verify?token=unknown", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_ChangePassword() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("PUT", "/client/password", bytes.NewReader([]byte(`{"currentPassword":"walking in the rain","newPassword":"running in the sun"}`)))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_ConfirmEmailChange() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/email/verify?token=unknown", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_CreateGuest() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("POST", "/client/guest", bytes.NewReader([]byte(`{"deviceId":""}`)))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_GuestLogin() {
+	req := suite.Require()
+	bs, err := json.Marshal(players.GuestLoginPayload{GuestID: uuid.New().String(), DeviceID: "device", Secret: "secret"})
+	req.NoError(err)
+
+	request := httptest.NewRequest("POST", "/client/guest/login", bytes.NewBuffer(bs))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_UpgradeGuest() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("POST", "/client/upgrade", bytes.NewReader([]byte(`{"email":"walker@example.com","password":"walking in the rain"}`)))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_ChangeHandle() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("PUT", "/client/handle", bytes.NewReader([]byte(`{"handle":"walker"}`)))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_GetPublicProfile() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/players/walker", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_RefreshToken() {
+	req := suite.Require()
+	bs, err := json.Marshal(players.RefreshPayload{RefreshToken: "unknown"})
+	req.NoError(err)
+
+	request := httptest.NewRequest("POST", "/client/token/refresh", bytes.NewBuffer(bs))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_JWKS() {
+	req := suite.Require()
+	key, err := middleware.GenerateSigningKey(middleware.AlgEdDSA)
+	req.NoError(err)
+	controller := NewController(zap.NewNop(), nil, nil, middleware.NewJwtKeyRing(key))
+	router := chi.NewRouter()
+	req.NoError(controller.SetupRootRouter(router))
+
+	request := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+
+	router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusOK, response.StatusCode)
+	var jwks middleware.JWKSet
+	req.NoError(json.NewDecoder(response.Body).Decode(&jwks))
+	req.Len(jwks.Keys, 1)
+	req.Equal(key.ID, jwks.Keys[0].Kid)
+}
+
+func (suite *testControllerSuite) TestController_UnlockPlayer() {
+	req := suite.Require()
+	bs, err := json.Marshal(players.UnlockPayload{Email: "dummy@mail.com"})
+	req.NoError(err)
+
+	request := suite.adminRequest("POST", "/admin/players/unlock", bytes.NewBuffer(bs))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusOK, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_SearchPlayers() {
+	req := suite.Require()
+
+	request := suite.adminRequest("GET", "/admin/players/?q=", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_SuspendPlayer() {
+	req := suite.Require()
+	bs, err := json.Marshal(players.SuspendPayload{Until: time.Now().Add(time.Hour)})
+	req.NoError(err)
+
+	request := suite.adminRequest("POST", "/admin/players/"+uuid.New().String()+"/suspend", bytes.NewBuffer(bs))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_GetPlayerAudit() {
+	req := suite.Require()
+
+	request := suite.adminRequest("GET", "/admin/players/"+uuid.New().String()+"/audit", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusOK, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_GetFriendLocations() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/friends/locations", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_CheckIn() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("POST", "/client/loc/1/checkin", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_GetStandings() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/factions/standings?bbox=18,59,19,60", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_CreateQuest() {
+	req := suite.Require()
+	payload := quests.QuestPayload{Title: "tour"}
+	body, err := json.Marshal(payload)
+	req.NoError(err)
+
+	request := suite.adminRequest("POST", "/admin/quests/create", bytes.NewReader(body))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_StartQuest() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("POST", "/client/quests/1/start", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_CreateItem() {
+	req := suite.Require()
+	payload := items.ItemStoreModel{ID: "gem", Name: "Gem"}
+	body, err := json.Marshal(payload)
+	req.NoError(err)
+
+	request := suite.adminRequest("POST", "/admin/items/create", bytes.NewReader(body))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_PickUpItem() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("POST", "/client/items/1/pickup", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_BackfillAchievement() {
+	req := suite.Require()
+
+	request := suite.adminRequest("POST", "/admin/achievements/unknown/backfill", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusNotFound, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_EarnedAchievements() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/achievements", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_ListEncounters() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/encounters", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_DeleteGeofence() {
+	req := suite.Require()
+
+	request := suite.adminRequest("DELETE", "/admin/geofences/unknown/delete", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusNotFound, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_WebSocket() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/ws?access_token=unknown", nil)
+	request.Header.Set("Upgrade", "websocket")
+
+	// the mock auther accepts the query token, it grants no scope
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusForbidden, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_SendNotification() {
+	req := suite.Require()
+	payload := notifications.NotificationPayload{
+		ClientID: "unknown",
+		Kind:     "news",
+		Title:    "Hello",
+	}
+	body, err := json.Marshal(payload)
+	req.Nil(err)
+
+	request := suite.adminRequest("POST", "/admin/notifications/send", bytes.NewReader(body))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_ListNotifications() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/notifications?unread=true", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_DeleteChatMessage() {
+	req := suite.Require()
+
+	request := suite.adminRequest("DELETE", "/admin/chat/messages/unknown/delete", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusNotFound, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_ListChatMessages() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/chat/1/messages?wait=10s", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_AdminUnauthorized() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/admin/loc/1", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_AdminForbidden() {
+	req := suite.Require()
+	suite.auther.AdminRole = middleware.RoleViewer
+
+	request := suite.adminRequest("GET", "/admin/loc/1", nil)
+	suite.router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusOK, suite.recorder.Result().StatusCode)
+
+	suite.recorder = httptest.NewRecorder()
+	request = suite.adminRequest("DELETE", "/admin/loc/1/delete", nil)
+	suite.router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusForbidden, suite.recorder.Result().StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_AdminLogin() {
+	req := suite.Require()
+	body, err := json.Marshal(admins.LoginPayload{Email: "root@example.com", Password: "correct horse battery"})
+	req.NoError(err)
+
+	request := httptest.NewRequest("POST", "/admin/login", bytes.NewReader(body))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_CreateAdmin() {
+	req := suite.Require()
+	body, err := json.Marshal(admins.AdminPayload{Email: "editor@example.com", Password: "short", Role: middleware.RoleEditor})
+	req.NoError(err)
+
+	request := suite.adminRequest("POST", "/admin/admins/create", bytes.NewReader(body))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_AdminClientCert() {
+	req := suite.Require()
+	router := chi.NewRouter()
+	controller := NewController(zap.NewNop(), locations.NewDefaultService(zap.NewNop(), locations.NewMockStore()), nil, suite.auther,
+		WithClientCerts(nil),
+	)
+	req.NoError(controller.SetupRouter(router))
+
+	request := suite.adminRequest("GET", "/admin/loc/1", nil)
+
+	router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_APIKey() {
+	req := suite.Require()
+	key, err := suite.apiKeys.Create(context.TODO(), apikeys.KeyPayload{Name: "pipeline", Scopes: []string{middleware.PermLocationsRead}})
+	req.NoError(err)
+
+	request := httptest.NewRequest("GET", "/admin/loc/1", nil)
+	request.Header.Set(middleware.APIKeyHeader, key.Secret)
+	suite.router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusOK, suite.recorder.Result().StatusCode)
+
+	suite.recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("DELETE", "/admin/loc/1/delete", nil)
+	request.Header.Set(middleware.APIKeyHeader, key.Secret)
+	suite.router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusForbidden, suite.recorder.Result().StatusCode)
+
+	suite.recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("GET", "/admin/loc/1", nil)
+	request.Header.Set(middleware.APIKeyHeader, "ggk_unknown")
+	suite.router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusUnauthorized, suite.recorder.Result().StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_ImportLocations() {
+	req := suite.Require()
+	key, err := suite.apiKeys.Create(context.TODO(), apikeys.KeyPayload{Name: "pipeline", Scopes: []string{middleware.PermLocationsImport}})
+	req.NoError(err)
+
+	request := httptest.NewRequest("POST", "/admin/loc/import", bytes.NewBufferString(`[{"id":"1"},{"id":"2"}]`))
+	request.Header.Set(middleware.APIKeyHeader, key.Secret)
+	suite.router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusOK, suite.recorder.Result().StatusCode)
+	var res ImportResponse
+	req.NoError(json.NewDecoder(suite.recorder.Body).Decode(&res))
+	req.Equal(2, res.Imported)
+
+	suite.recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("POST", "/admin/loc/create", bytes.NewBufferString(`{"id":"3"}`))
+	request.Header.Set(middleware.APIKeyHeader, key.Secret)
+	suite.router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusForbidden, suite.recorder.Result().StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_RevokeAPIKey() {
+	req := suite.Require()
+
+	request := suite.adminRequest("POST", "/admin/apikeys/unknown/revoke", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusNotFound, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_StartOIDCLogin() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/oidc/unknown/start", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusNotFound, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_OIDCCallback() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("GET", "/client/oidc/callback?state=forged&code=code", nil)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_OIDCBinding() {
+	req := suite.Require()
+	fake, err := oidc.NewFakeProvider("geogame", "secret")
+	req.NoError(err)
+	defer fake.Close()
+	fake.SetUser(oidc.FakeUser{Subject: "alice-sub", Email: "alice@mail.com", EmailVerified: true})
+	playersSvc := players.NewDefaultService(zap.NewNop(), players.NewMemStore(make(map[interface{}]*players.ClientStoreModel)), time.Second*3, "secret")
+	oidcSvc := oidc.NewDefaultService(zap.NewNop(), oidc.NewMemStore(), playersSvc, oidc.DefaultConfig(), time.Second*3,
+		oidc.WithProvider(oidc.NewProvider(fake.Config(), nil)))
+	router := chi.NewRouter()
+	NewController(zap.NewNop(), locations.NewDefaultService(zap.NewNop(), locations.NewMockStore()), playersSvc, suite.auther,
+		WithOIDC(oidcSvc)).SetupRouter(router)
+
+	router.ServeHTTP(suite.recorder, httptest.NewRequest("GET", "/client/oidc/fake/start", nil))
+	response := suite.recorder.Result()
+	req.Equal(http.StatusOK, response.StatusCode)
+	var start oidc.StartResponse
+	req.NoError(json.NewDecoder(response.Body).Decode(&start))
+	cookies := response.Cookies()
+	req.Len(cookies, 1)
+	req.Equal(oidcBindingCookie, cookies[0].Name)
+	req.True(cookies[0].HttpOnly)
+	req.True(cookies[0].Secure)
+	req.Equal(http.SameSiteLaxMode, cookies[0].SameSite)
+
+	payload, err := fake.Authorize(start.URL)
+	req.NoError(err)
+	target := "/client/oidc/callback?state=" + payload.State + "&code=" + payload.Code
+
+	// the callback URL alone does not complete the login in another browser
+	suite.recorder = httptest.NewRecorder()
+	router.ServeHTTP(suite.recorder, httptest.NewRequest("GET", target, nil))
+	req.Equal(http.StatusBadRequest, suite.recorder.Result().StatusCode)
+
+	suite.recorder = httptest.NewRecorder()
+	request := httptest.NewRequest("GET", target, nil)
+	request.AddCookie(&http.Cookie{Name: oidcBindingCookie, Value: cookies[0].Value})
+	router.ServeHTTP(suite.recorder, request)
+	req.Equal(http.StatusOK, suite.recorder.Result().StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_VerifyTwoFactor() {
+	req := suite.Require()
+
+	request := httptest.NewRequest("POST", "/client/login/2fa", bytes.NewReader([]byte(`{"challenge":"unknown","code":"123456"}`)))
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (suite *testControllerSuite) TestController_AdminTwoFactorAPIKey() {
+	req := suite.Require()
+	key, err := suite.apiKeys.Create(context.TODO(), apikeys.KeyPayload{Name: "pipeline", Scopes: []string{middleware.PermLocationsRead}})
+	req.NoError(err)
+
+	request := httptest.NewRequest("POST", "/admin/2fa/enroll", nil)
+	request.Header.Set(middleware.APIKeyHeader, key.Secret)
+
+	suite.router.ServeHTTP(suite.recorder, request)
+	response := suite.recorder.Result()
+	req.Equal(http.StatusForbidden, response.StatusCode)
+}
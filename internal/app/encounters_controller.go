@@ -0,0 +1,20 @@
+package app
+
+import (
+	"net/http"
+)
+
+// encounter endpoints
+func (c *Controller) ListEncounters(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.encounters.List(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
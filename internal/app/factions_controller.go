@@ -0,0 +1,131 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/checkins"
+	"geogame/internal/factions"
+	"geogame/internal/locations"
+)
+
+// check-in and faction endpoints
+func (c *Controller) CheckIn(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.checkIns.CheckIn(r.Context(), token.UserID, chi.URLParam(r, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, checkins.ErrLocationNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, checkins.ErrOutOfRange), errors.Is(err, checkins.ErrNoPosition):
+			writeError(w, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, checkins.ErrCheckInCooldown):
+			writeError(w, http.StatusTooManyRequests, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListCheckIns(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.checkIns.History(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListFactions(w http.ResponseWriter, r *http.Request) {
+	res, err := c.factions.ListFactions(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) JoinFaction(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p factions.JoinPayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.factions.Join(r.Context(), p, token.UserID); err != nil {
+		switch {
+		case errors.Is(err, factions.ErrUnknownFaction):
+			writeError(w, http.StatusBadRequest, err)
+		case errors.Is(err, factions.ErrSwitchCooldown):
+			writeError(w, http.StatusConflict, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) GetMembership(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.factions.Membership(r.Context(), token.UserID)
+	if err != nil {
+		if errors.Is(err, factions.ErrNotMember) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) GetStandings(w http.ResponseWriter, r *http.Request) {
+	bbox, err := locations.ParseBBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.factions.Standings(r.Context(), bbox)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) GetControlMap(w http.ResponseWriter, r *http.Request) {
+	bbox, err := locations.ParseBBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.factions.ControlMap(r.Context(), bbox)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
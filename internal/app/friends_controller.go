@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/players"
+)
+
+// friends endpoints
+func (c *Controller) ListFriends(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.players.ListFriends(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) GetFriendLocations(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.players.FriendLocations(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) RequestFriend(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.FriendPayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.players.RequestFriend(r.Context(), p, token.UserID); err != nil {
+		writeFriendError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) AcceptFriend(w http.ResponseWriter, r *http.Request) {
+	c.friendAction(w, r, c.players.AcceptFriend)
+}
+
+func (c *Controller) DeclineFriend(w http.ResponseWriter, r *http.Request) {
+	c.friendAction(w, r, c.players.DeclineFriend)
+}
+
+func (c *Controller) RemoveFriend(w http.ResponseWriter, r *http.Request) {
+	c.friendAction(w, r, c.players.RemoveFriend)
+}
+
+func (c *Controller) BlockPlayer(w http.ResponseWriter, r *http.Request) {
+	c.friendAction(w, r, c.players.BlockPlayer)
+}
+
+func (c *Controller) UnblockPlayer(w http.ResponseWriter, r *http.Request) {
+	c.friendAction(w, r, c.players.UnblockPlayer)
+}
+
+func (c *Controller) UpdateLocationSharing(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.SharingPayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.players.UpdateLocationSharing(r.Context(), p, token.UserID); err != nil {
+		writeFriendError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+// friendAction runs an action on the friend given by the {id} url param
+func (c *Controller) friendAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, friendID, clientID string) error) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := action(r.Context(), chi.URLParam(r, "id"), token.UserID); err != nil {
+		writeFriendError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func writeFriendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, players.ErrInvalidFriend), errors.Is(err, players.ErrInvalidLocationSharing):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, players.ErrFriendNotFound), errors.Is(err, players.ErrFriendRequestNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, players.ErrAlreadyFriends), errors.Is(err, players.ErrPlayerBlocked):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
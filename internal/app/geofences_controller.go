@@ -0,0 +1,77 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/geofences"
+)
+
+// geofence endpoints
+func (c *Controller) CreateGeofence(w http.ResponseWriter, r *http.Request) {
+	var payload geofences.FencePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.geofences.CreateFence(r.Context(), payload)
+	if err != nil {
+		writeGeofenceError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListGeofences(w http.ResponseWriter, r *http.Request) {
+	res, err := c.geofences.ListFences(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) DeleteGeofence(w http.ResponseWriter, r *http.Request) {
+	if err := c.geofences.DeleteFence(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeGeofenceError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) GetPlayerGeofenceEvents(w http.ResponseWriter, r *http.Request) {
+	res, err := c.geofences.Events(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListGeofenceEvents(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.geofences.Events(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func writeGeofenceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, geofences.ErrInvalidFence):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, geofences.ErrFenceNotFound):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"geogame/internal/players"
+)
+
+// guest endpoints
+func (c *Controller) CreateGuest(w http.ResponseWriter, r *http.Request) {
+	var payload players.GuestPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.players.CreateGuest(r.Context(), payload)
+	if err != nil {
+		if errors.Is(err, players.ErrInvalidDevice) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) GuestLogin(w http.ResponseWriter, r *http.Request) {
+	var payload players.GuestLoginPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.players.GuestLogin(r.Context(), payload)
+	if err != nil {
+		if errors.Is(err, players.ErrInvalidGuest) {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if isRestrictedError(err) {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) UpgradeGuest(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.UpgradePayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if p.Email == "" || p.Password == "" {
+		writeError(w, http.StatusBadRequest, errors.New("empty email id or password"))
+		return
+	}
+	if err := c.players.Upgrade(r.Context(), p, token.UserID); err != nil {
+		writeCredentialError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
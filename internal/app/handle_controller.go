@@ -0,0 +1,74 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"geogame/internal/players"
+
+	"github.com/go-chi/chi"
+)
+
+// handle endpoints
+func (c *Controller) GetHandle(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.players.GetHandle(r.Context(), token.UserID)
+	if err != nil {
+		writeHandleError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) ChangeHandle(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p players.HandlePayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.players.ChangeHandle(r.Context(), p, token.UserID)
+	if err != nil {
+		writeHandleError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) GetPublicProfile(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.players.PublicProfile(r.Context(), chi.URLParam(r, "handle"), token.UserID)
+	if err != nil {
+		writeHandleError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func writeHandleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, players.ErrInvalidHandle), errors.Is(err, players.ErrHandleReserved):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, players.ErrHandleTaken):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, players.ErrHandleCooldown):
+		writeError(w, http.StatusTooManyRequests, err)
+	case errors.Is(err, players.ErrPlayerNotFound):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/items"
+)
+
+// item catalog, spawn and inventory endpoints
+func (c *Controller) CreateItem(w http.ResponseWriter, r *http.Request) {
+	var payload items.ItemStoreModel
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.items.CreateItem(r.Context(), payload); err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ListItems(w http.ResponseWriter, r *http.Request) {
+	res, err := c.items.ListItems(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) CreateSpawnRule(w http.ResponseWriter, r *http.Request) {
+	var payload items.SpawnRulePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.items.CreateRule(r.Context(), payload)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListSpawnRules(w http.ResponseWriter, r *http.Request) {
+	res, err := c.items.ListRules(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) DeleteSpawnRule(w http.ResponseWriter, r *http.Request) {
+	if err := c.items.DeleteRule(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) NearbyItems(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.items.Nearby(r.Context(), token.UserID)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) PickUpItem(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.items.PickUp(r.Context(), token.UserID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) GetInventory(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.items.Inventory(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) DropItem(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p items.QuantityPayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.items.Drop(r.Context(), token.UserID, chi.URLParam(r, "id"), p.Quantity)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ConsumeItem(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var p items.QuantityPayload
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.items.Consume(r.Context(), token.UserID, chi.URLParam(r, "id"), p.Quantity)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func writeItemError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, items.ErrInvalidItem), errors.Is(err, items.ErrInvalidSpawnRule), errors.Is(err, items.ErrInvalidQuantity):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, items.ErrRuleNotFound), errors.Is(err, items.ErrItemNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, items.ErrSpawnGone):
+		writeError(w, http.StatusGone, err)
+	case errors.Is(err, items.ErrStackLimit), errors.Is(err, items.ErrNotEnoughItems):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, items.ErrOutOfRange), errors.Is(err, items.ErrNoPosition):
+		writeError(w, http.StatusUnprocessableEntity, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/players"
+)
+
+// admin player management endpoints, every action is recorded in the player audit
+func (c *Controller) SearchPlayers(w http.ResponseWriter, r *http.Request) {
+	res, err := c.players.SearchPlayers(r.Context(), r.URL.Query().Get("q"), adminActor(r))
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) GetPlayer(w http.ResponseWriter, r *http.Request) {
+	res, err := c.players.AdminGetPlayer(r.Context(), chi.URLParam(r, "id"), adminActor(r))
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) GetPlayerAudit(w http.ResponseWriter, r *http.Request) {
+	res, err := c.players.ListAudit(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) AddPlayerNote(w http.ResponseWriter, r *http.Request) {
+	var payload players.NotePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	payload.Actor = adminActor(r)
+	res, err := c.players.AddNote(r.Context(), payload, chi.URLParam(r, "id"))
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) ForceLogoutPlayer(w http.ResponseWriter, r *http.Request) {
+	revoked, err := c.players.ForceLogout(r.Context(), chi.URLParam(r, "id"), adminActor(r))
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	for _, id := range revoked {
+		c.revocations.MarkRevoked(id)
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) SuspendPlayer(w http.ResponseWriter, r *http.Request) {
+	var payload players.SuspendPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	payload.Actor = adminActor(r)
+	id := chi.URLParam(r, "id")
+	if err := c.players.SuspendPlayer(r.Context(), payload, id); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	c.standings.MarkRestricted(id, true)
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) BanPlayer(w http.ResponseWriter, r *http.Request) {
+	var payload players.ModerationPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	payload.Actor = adminActor(r)
+	id := chi.URLParam(r, "id")
+	revoked, err := c.players.BanPlayer(r.Context(), payload, id)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	c.standings.MarkRestricted(id, true)
+	for _, sessionID := range revoked {
+		c.revocations.MarkRevoked(sessionID)
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ReinstatePlayer(w http.ResponseWriter, r *http.Request) {
+	var payload players.ModerationPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	payload.Actor = adminActor(r)
+	id := chi.URLParam(r, "id")
+	if err := c.players.ReinstatePlayer(r.Context(), payload, id); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	c.standings.MarkRestricted(id, false)
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func writeModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, players.ErrEmptySearch), errors.Is(err, players.ErrEmptyNote),
+		errors.Is(err, players.ErrReasonRequired), errors.Is(err, players.ErrInvalidSuspension):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, players.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, players.ErrAccountBanned):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// isRestrictedError tells the logins rejected because the player is suspended or banned
+func isRestrictedError(err error) bool {
+	return errors.Is(err, players.ErrAccountSuspended) || errors.Is(err, players.ErrAccountBanned)
+}
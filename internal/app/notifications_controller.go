@@ -0,0 +1,162 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/notifications"
+)
+
+// notification endpoints
+func (c *Controller) SendNotification(w http.ResponseWriter, r *http.Request) {
+	var payload notifications.NotificationPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.notifications.Notify(r.Context(), payload.ClientID, payload)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListDeadNotifications(w http.ResponseWriter, r *http.Request) {
+	res, err := c.notifications.DeadLetters(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) RetryNotification(w http.ResponseWriter, r *http.Request) {
+	if err := c.notifications.Retry(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.notifications.Inbox(r.Context(), token.UserID, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ReadNotification(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := c.notifications.MarkRead(r.Context(), token.UserID, chi.URLParam(r, "id")); err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := c.notifications.MarkAllRead(r.Context(), token.UserID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) GetQuietHours(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.notifications.QuietHours(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) UpdateQuietHours(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var payload notifications.QuietHours
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.notifications.UpdateQuietHours(r.Context(), token.UserID, payload)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var payload notifications.DevicePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.notifications.RegisterDevice(r.Context(), token.UserID, payload); err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) UnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := c.notifications.UnregisterDevice(r.Context(), token.UserID, chi.URLParam(r, "token")); err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func writeNotificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notifications.ErrInvalidNotification),
+		errors.Is(err, notifications.ErrInvalidDevice),
+		errors.Is(err, notifications.ErrInvalidQuietHours):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, notifications.ErrNotificationNotFound),
+		errors.Is(err, notifications.ErrDeviceNotFound),
+		errors.Is(err, notifications.ErrDeadLetterNotFound):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
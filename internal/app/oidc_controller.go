@@ -0,0 +1,95 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/oidc"
+	"geogame/internal/players"
+)
+
+// oidcBindingCookie binds a login to the user agent which started it, the callback is refused without it
+const oidcBindingCookie = "geogame_oidc_binding"
+
+// openid connect login endpoints
+func (c *Controller) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	res, err := c.oidc.Start(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+	setOIDCBinding(w, res)
+	writeResponse(w, http.StatusOK, res)
+}
+
+// StartOIDCUpgrade links the provider to the guest of the token, the callback is the one of the login
+func (c *Controller) StartOIDCUpgrade(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.oidc.StartUpgrade(r.Context(), chi.URLParam(r, "provider"), token.UserID)
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+	setOIDCBinding(w, res)
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var binding string
+	if cookie, err := r.Cookie(oidcBindingCookie); err == nil {
+		binding = cookie.Value
+	}
+	// the binding is good for one callback whatever its outcome
+	http.SetCookie(w, &http.Cookie{Name: oidcBindingCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+	res, err := c.oidc.Callback(r.Context(), oidc.CallbackPayload{
+		State:            q.Get("state"),
+		Binding:          binding,
+		Code:             q.Get("code"),
+		Error:            q.Get("error"),
+		ErrorDescription: q.Get("error_description"),
+	})
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+// setOIDCBinding keeps the binding of the flow in the user agent, lax so the redirect of the provider carries it
+func setOIDCBinding(w http.ResponseWriter, res *oidc.StartResponse) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcBindingCookie,
+		Value:    res.Binding,
+		Path:     "/",
+		Expires:  res.ExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func writeOIDCError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, oidc.ErrUnknownProvider):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, oidc.ErrInvalidState):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrLoginDenied):
+		writeError(w, http.StatusUnauthorized, err)
+	case errors.Is(err, players.ErrEmailNotVerified), isRestrictedError(err):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, players.ErrNotGuest), errors.Is(err, players.ErrEmailTaken), errors.Is(err, players.ErrIdentityLinked):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, oidc.ErrProvider):
+		writeError(w, http.StatusBadGateway, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"geogame/internal/quests"
+)
+
+// quest endpoints
+func (c *Controller) CreateQuest(w http.ResponseWriter, r *http.Request) {
+	var payload quests.QuestPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.quests.CreateQuest(r.Context(), payload)
+	if err != nil {
+		writeQuestError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ListQuests(w http.ResponseWriter, r *http.Request) {
+	res, err := c.quests.ListQuests(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) GetQuest(w http.ResponseWriter, r *http.Request) {
+	res, err := c.quests.GetQuest(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeQuestError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) UpdateQuest(w http.ResponseWriter, r *http.Request) {
+	var payload quests.QuestPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.quests.UpdateQuest(r.Context(), payload)
+	if err != nil {
+		writeQuestError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) DeleteQuest(w http.ResponseWriter, r *http.Request) {
+	if err := c.quests.DeleteQuest(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeQuestError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+func (c *Controller) ListPlayerQuests(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.quests.PlayerQuests(r.Context(), token.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) StartQuest(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.quests.Start(r.Context(), token.UserID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeQuestError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) GetQuestProgress(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.quests.Progress(r.Context(), token.UserID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeQuestError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func writeQuestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, quests.ErrInvalidQuest):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, quests.ErrQuestNotFound), errors.Is(err, quests.ErrQuestNotStarted):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, quests.ErrQuestAlreadyStarted), errors.Is(err, quests.ErrPrerequisitesMissing):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
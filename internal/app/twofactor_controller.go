@@ -0,0 +1,177 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"geogame/internal/admins"
+	"geogame/internal/lockout"
+	"geogame/internal/middleware"
+	"geogame/internal/players"
+	"geogame/internal/totp"
+)
+
+// two-factor endpoints of the players
+func (c *Controller) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload players.TwoFactorPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.players.VerifyTwoFactor(r.Context(), payload)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := c.players.EnrollTwoFactor(r.Context(), token.UserID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var payload players.TwoFactorCodePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.players.ConfirmTwoFactor(r.Context(), payload, token.UserID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var payload players.TwoFactorCodePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.players.DisableTwoFactor(r.Context(), payload, token.UserID); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+// two-factor endpoints of the admins
+func (c *Controller) AdminVerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload admins.TwoFactorPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.admins.VerifyTwoFactor(r.Context(), payload)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, *res)
+}
+
+func (c *Controller) AdminEnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	admin, ok := adminFromContext(w, r)
+	if !ok {
+		return
+	}
+	res, err := c.admins.EnrollTwoFactor(r.Context(), admin.AdminID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) AdminConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	admin, ok := adminFromContext(w, r)
+	if !ok {
+		return
+	}
+	var payload admins.CodePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := c.admins.ConfirmTwoFactor(r.Context(), admin.AdminID, payload)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, res)
+}
+
+func (c *Controller) AdminDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	admin, ok := adminFromContext(w, r)
+	if !ok {
+		return
+	}
+	var payload admins.CodePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.admins.DisableTwoFactor(r.Context(), admin.AdminID, payload); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, SuccessResponse{Ok: "success"})
+}
+
+// adminFromContext returns the admin of the token, API keys have no second factor to manage
+func adminFromContext(w http.ResponseWriter, r *http.Request) (*middleware.AdminToken, bool) {
+	token, ok := r.Context().Value("AdminToken").(*middleware.AdminToken)
+	if !ok || token == nil {
+		writeError(w, http.StatusForbidden, errors.New("an admin token is required"))
+		return nil, false
+	}
+	return token, true
+}
+
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, err)
+	case errors.Is(err, totp.ErrInvalidCode), errors.Is(err, totp.ErrInvalidChallenge):
+		writeError(w, http.StatusUnauthorized, err)
+	case errors.Is(err, totp.ErrAlreadyEnabled), errors.Is(err, admins.ErrTwoFactorRequired):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, players.ErrGuestAccount), isRestrictedError(err):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, totp.ErrNotEnrolled):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, admins.ErrAdminNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, admins.ErrTwoFactorUnavailable):
+		writeError(w, http.StatusNotImplemented, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"geogame/internal/realtime"
+)
+
+// ServeWebSocket streams the positions of the player upstream and the server events downstream
+func (c *Controller) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	token, err := extractTokenFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var expiresAt time.Time
+	if token.ExpiresAt > 0 {
+		expiresAt = time.Unix(token.ExpiresAt, 0)
+	}
+	if err := c.gateway.Serve(w, r, token.UserID, expiresAt); err != nil {
+		if err == realtime.ErrShuttingDown {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// Drain closes the websocket connections on shutdown
+func (c *Controller) Drain(ctx context.Context) error {
+	if c.gateway == nil {
+		return nil
+	}
+	return c.gateway.Drain(ctx)
+}
@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrRejected is returned, possibly wrapped, by a content filter refusing a message
+var ErrRejected = errors.New("message rejected")
+
+// ContentFilter checks a message before it is posted, it returns the body to post
+type ContentFilter interface {
+	Filter(ctx context.Context, clientID, body string) (string, error)
+}
+
+// FilterFunc adapts a function to the ContentFilter interface
+type FilterFunc func(ctx context.Context, clientID, body string) (string, error)
+
+func (f FilterFunc) Filter(ctx context.Context, clientID, body string) (string, error) {
+	return f(ctx, clientID, body)
+}
+
+var _ ContentFilter = (*WordFilter)(nil)
+
+// WordFilter masks the blocked words with asterisks, matching whole words regardless of case
+type WordFilter struct {
+	pattern *regexp.Regexp
+}
+
+func NewWordFilter(words []string) *WordFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return &WordFilter{}
+	}
+	return &WordFilter{
+		pattern: regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+func (f *WordFilter) Filter(ctx context.Context, clientID, body string) (string, error) {
+	if f.pattern == nil {
+		return body, nil
+	}
+	return f.pattern.ReplaceAllStringFunc(body, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	}), nil
+}
@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter allows every player a number of messages per sliding period
+type limiter struct {
+	mu     sync.Mutex
+	limit  int
+	period time.Duration
+	posts  map[string][]time.Time
+}
+
+func newLimiter(limit int, period time.Duration) *limiter {
+	return &limiter{
+		limit:  limit,
+		period: period,
+		posts:  make(map[string][]time.Time),
+	}
+}
+
+// allow records the message when the player is below the limit
+func (l *limiter) allow(clientID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	posts := recent(l.posts[clientID], now.Add(-l.period))
+	if len(posts) >= l.limit {
+		l.posts[clientID] = posts
+		return false
+	}
+	l.posts[clientID] = append(posts, now)
+	return true
+}
+
+// evict forgets the players without messages in the period
+func (l *limiter) evict(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for clientID, posts := range l.posts {
+		if posts = recent(posts, now.Add(-l.period)); len(posts) == 0 {
+			delete(l.posts, clientID)
+		} else {
+			l.posts[clientID] = posts
+		}
+	}
+}
+
+func recent(posts []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(posts) && !posts[i].After(since) {
+		i++
+	}
+	return posts[i:]
+}
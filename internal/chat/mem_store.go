@@ -0,0 +1,156 @@
+package chat
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu       sync.RWMutex
+	messages map[string]*MessageStoreModel
+	reports  map[string]map[string]ReportStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		messages: make(map[string]*MessageStoreModel),
+		reports:  make(map[string]map[string]ReportStoreModel),
+	}
+}
+
+func (m *MemStore) Create(ctx context.Context, model *MessageStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *model
+	m.messages[model.ID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) Get(ctx context.Context, id string) (*MessageStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	msg, ok := m.messages[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *msg
+	return &c, nil
+}
+
+func (m *MemStore) visible(locationID string, now time.Time) []MessageStoreModel {
+	res := make([]MessageStoreModel, 0)
+	for _, msg := range m.messages {
+		if msg.LocationID == locationID && !msg.Hidden && msg.ExpiresAt.After(now) {
+			res = append(res, *msg)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return before(cursorOf(&res[i]), cursorOf(&res[j]))
+	})
+	return res
+}
+
+// before orders the messages of a room by creation and id like the Postgres queries
+func before(a, b Cursor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+func (m *MemStore) ListLatest(ctx context.Context, locationID string, now time.Time, limit int) ([]MessageStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	all := m.visible(locationID, now)
+	res := make([]MessageStoreModel, 0, limit)
+	for i := len(all) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, all[i])
+	}
+	return res, nil
+}
+
+func (m *MemStore) ListAfter(ctx context.Context, locationID string, after Cursor, now time.Time, limit int) ([]MessageStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]MessageStoreModel, 0)
+	for _, msg := range m.visible(locationID, now) {
+		if before(after, cursorOf(&msg)) && len(res) < limit {
+			res = append(res, msg)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) CreateReport(ctx context.Context, model *ReportStoreModel, hideAt int) (*MessageStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg, ok := m.messages[model.MessageID.String()]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	reports, ok := m.reports[msg.ID.String()]
+	if !ok {
+		reports = make(map[string]ReportStoreModel)
+		m.reports[msg.ID.String()] = reports
+	}
+	if _, ok := reports[model.ClientID.String()]; ok {
+		return nil, ErrDuplicateReport
+	}
+	reports[model.ClientID.String()] = *model
+	msg.Reports++
+	if msg.Reports >= hideAt {
+		msg.Hidden = true
+	}
+	c := *msg
+	return &c, nil
+}
+
+func (m *MemStore) ListReported(ctx context.Context, now time.Time, limit int) ([]MessageStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]MessageStoreModel, 0)
+	for _, msg := range m.messages {
+		if msg.Reports > 0 && msg.ExpiresAt.After(now) {
+			res = append(res, *msg)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Reports != res[j].Reports {
+			return res[i].Reports > res[j].Reports
+		}
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (m *MemStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.messages[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.messages, id)
+	delete(m.reports, id)
+	return nil
+}
+
+func (m *MemStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, msg := range m.messages {
+		if !msg.ExpiresAt.After(now) {
+			delete(m.messages, id)
+			delete(m.reports, id)
+			n++
+		}
+	}
+	return n, nil
+}
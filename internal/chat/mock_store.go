@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"context"
+	"time"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateFunc        func(model *MessageStoreModel) error
+	GetFunc           func(id string) (*MessageStoreModel, error)
+	ListLatestFunc    func(locationID string, now time.Time, limit int) ([]MessageStoreModel, error)
+	ListAfterFunc     func(locationID string, after Cursor, now time.Time, limit int) ([]MessageStoreModel, error)
+	CreateReportFunc  func(model *ReportStoreModel, hideAt int) (*MessageStoreModel, error)
+	ListReportedFunc  func(now time.Time, limit int) ([]MessageStoreModel, error)
+	DeleteFunc        func(id string) error
+	DeleteExpiredFunc func(now time.Time) (int64, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateFunc: func(model *MessageStoreModel) error {
+			return nil
+		},
+		GetFunc: func(id string) (*MessageStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListLatestFunc: func(locationID string, now time.Time, limit int) ([]MessageStoreModel, error) {
+			return []MessageStoreModel{}, nil
+		},
+		ListAfterFunc: func(locationID string, after Cursor, now time.Time, limit int) ([]MessageStoreModel, error) {
+			return []MessageStoreModel{}, nil
+		},
+		CreateReportFunc: func(model *ReportStoreModel, hideAt int) (*MessageStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListReportedFunc: func(now time.Time, limit int) ([]MessageStoreModel, error) {
+			return []MessageStoreModel{}, nil
+		},
+		DeleteFunc: func(id string) error {
+			return ErrNotFound
+		},
+		DeleteExpiredFunc: func(now time.Time) (int64, error) {
+			return 0, nil
+		},
+	}
+}
+
+func (m *MockStore) Create(ctx context.Context, model *MessageStoreModel) error {
+	return m.CreateFunc(model)
+}
+
+func (m *MockStore) Get(ctx context.Context, id string) (*MessageStoreModel, error) {
+	return m.GetFunc(id)
+}
+
+func (m *MockStore) ListLatest(ctx context.Context, locationID string, now time.Time, limit int) ([]MessageStoreModel, error) {
+	return m.ListLatestFunc(locationID, now, limit)
+}
+
+func (m *MockStore) ListAfter(ctx context.Context, locationID string, after Cursor, now time.Time, limit int) ([]MessageStoreModel, error) {
+	return m.ListAfterFunc(locationID, after, now, limit)
+}
+
+func (m *MockStore) CreateReport(ctx context.Context, model *ReportStoreModel, hideAt int) (*MessageStoreModel, error) {
+	return m.CreateReportFunc(model, hideAt)
+}
+
+func (m *MockStore) ListReported(ctx context.Context, now time.Time, limit int) ([]MessageStoreModel, error) {
+	return m.ListReportedFunc(now, limit)
+}
+
+func (m *MockStore) Delete(ctx context.Context, id string) error {
+	return m.DeleteFunc(id)
+}
+
+func (m *MockStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return m.DeleteExpiredFunc(now)
+}
@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageStoreModel is a message posted to the room of a location
+type MessageStoreModel struct {
+	ID         uuid.UUID `db:"id"`
+	LocationID string    `db:"loc_id"`
+	ClientID   uuid.UUID `db:"client_id"`
+	Handle     string    `db:"handle"`
+	Body       string    `db:"body"`
+	Reports    int       `db:"reports"`
+	// Hidden is set once the message was reported often enough
+	Hidden    bool      `db:"hidden"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type ReportStoreModel struct {
+	MessageID uuid.UUID `db:"message_id"`
+	ClientID  uuid.UUID `db:"client_id"`
+	Reason    string    `db:"reason"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type MessagePayload struct {
+	Body string `json:"body"`
+}
+
+type ReportPayload struct {
+	Reason string `json:"reason"`
+}
+
+type Message struct {
+	ID         string    `json:"id"`
+	LocationID string    `json:"locationId"`
+	PlayerID   string    `json:"playerId"`
+	Handle     string    `json:"handle"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Page is a slice of the room, Cursor is passed back to get the messages after it
+type Page struct {
+	Messages []Message `json:"messages"`
+	Cursor   string    `json:"cursor,omitempty"`
+}
+
+// ReportedMessage is a message in the moderation queue
+type ReportedMessage struct {
+	Message
+	Reports int  `json:"reports"`
+	Hidden  bool `json:"hidden"`
+}
+
+func toMessage(m *MessageStoreModel) Message {
+	return Message{
+		ID:         m.ID.String(),
+		LocationID: m.LocationID,
+		PlayerID:   m.ClientID.String(),
+		Handle:     m.Handle,
+		Body:       m.Body,
+		CreatedAt:  m.CreatedAt,
+		ExpiresAt:  m.ExpiresAt,
+	}
+}
+
+// Cursor is the position of a message in its room
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func cursorOf(m *MessageStoreModel) Cursor {
+	return Cursor{CreatedAt: m.CreatedAt, ID: m.ID.String()}
+}
+
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + "_" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, err
+	}
+	parts := strings.SplitN(string(raw), "_", 2)
+	if len(parts) != 2 {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Cursor{}, err
+	}
+	if _, err := uuid.Parse(parts[1]); err != nil {
+		return Cursor{}, err
+	}
+	return Cursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: parts[1]}, nil
+}
@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	messagesAllCols = "id, loc_id, client_id, handle, body, reports, hidden, created_at, expires_at"
+	messagesTable   = "chat_messages"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.chat.store"),
+	}
+}
+
+func (p Postgres) Create(ctx context.Context, model *MessageStoreModel) error {
+	stmt := `INSERT INTO chat_messages (
+	id,
+	loc_id,
+	client_id,
+	handle,
+	body,
+	reports,
+	hidden,
+	created_at,
+	expires_at
+	) VALUES (
+	:id,
+	:loc_id,
+	:client_id,
+	:handle,
+	:body,
+	:reports,
+	:hidden,
+	:created_at,
+	:expires_at
+	)`
+	if _, err := p.db.NamedExecContext(ctx, stmt, model); err != nil {
+		p.logger.Error("Create: failed to insert message to db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) Get(ctx context.Context, id string) (*MessageStoreModel, error) {
+	stmt := "SELECT " + messagesAllCols + " FROM " + messagesTable + " WHERE id=$1"
+	var res MessageStoreModel
+	if err := p.db.GetContext(ctx, &res, stmt, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("Get: failed to get message from db", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p Postgres) ListLatest(ctx context.Context, locationID string, now time.Time, limit int) ([]MessageStoreModel, error) {
+	stmt := "SELECT " + messagesAllCols + " FROM " + messagesTable + `
+	WHERE loc_id=$1 AND NOT hidden AND expires_at > $2
+	ORDER BY created_at DESC, id DESC
+	LIMIT $3`
+	res := make([]MessageStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, locationID, now, limit); err != nil {
+		p.logger.Error("ListLatest: failed to list messages from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) ListAfter(ctx context.Context, locationID string, after Cursor, now time.Time, limit int) ([]MessageStoreModel, error) {
+	stmt := "SELECT " + messagesAllCols + " FROM " + messagesTable + `
+	WHERE loc_id=$1 AND NOT hidden AND expires_at > $2 AND (created_at, id) > ($3, $4::uuid)
+	ORDER BY created_at, id
+	LIMIT $5`
+	res := make([]MessageStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, locationID, now, after.CreatedAt, after.ID, limit); err != nil {
+		p.logger.Error("ListAfter: failed to list messages from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) CreateReport(ctx context.Context, model *ReportStoreModel, hideAt int) (*MessageStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("CreateReport: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO chat_reports (
+	message_id,
+	client_id,
+	reason,
+	created_at
+	) SELECT
+	:message_id,
+	:client_id,
+	:reason,
+	:created_at
+	WHERE EXISTS (SELECT 1 FROM chat_messages WHERE id=:message_id)
+	ON CONFLICT (message_id, client_id) DO NOTHING`
+	res, err := tx.NamedExecContext(ctx, stmt, model)
+	if err != nil {
+		p.logger.Error("CreateReport: failed to insert report to db", zap.Error(err))
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := p.Get(ctx, model.MessageID.String()); err != nil {
+			return nil, err
+		}
+		return nil, ErrDuplicateReport
+	}
+
+	var msg MessageStoreModel
+	stmt = "UPDATE " + messagesTable + " SET reports=reports+1, hidden=hidden OR reports+1 >= $2 WHERE id=$1 RETURNING " + messagesAllCols
+	if err := tx.GetContext(ctx, &msg, stmt, model.MessageID, hideAt); err != nil {
+		p.logger.Error("CreateReport: failed to count report in db", zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		p.logger.Error("CreateReport: failed to commit transaction", zap.Error(err))
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (p Postgres) ListReported(ctx context.Context, now time.Time, limit int) ([]MessageStoreModel, error) {
+	stmt := "SELECT " + messagesAllCols + " FROM " + messagesTable + `
+	WHERE reports > 0 AND expires_at > $1
+	ORDER BY reports DESC, created_at
+	LIMIT $2`
+	res := make([]MessageStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, now, limit); err != nil {
+		p.logger.Error("ListReported: failed to list messages from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) Delete(ctx context.Context, id string) error {
+	stmt := "DELETE FROM " + messagesTable + " WHERE id=$1"
+	res, err := p.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		p.logger.Error("Delete: failed to delete message from db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	stmt := "DELETE FROM " + messagesTable + " WHERE expires_at <= $1"
+	res, err := p.db.ExecContext(ctx, stmt, now)
+	if err != nil {
+		p.logger.Error("DeleteExpired: failed to delete messages from db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,70 @@
+package chat
+
+import "sync"
+
+// rooms wakes the long-polling readers and tracks the streaming followers of every room.
+// Both only see the messages posted to this instance, long-polling readers of other instances
+// get them once their wait ran out.
+type rooms struct {
+	mu        sync.Mutex
+	waiters   map[string]chan struct{}
+	followers map[string]map[string]struct{}
+}
+
+func newRooms() *rooms {
+	return &rooms{
+		waiters:   make(map[string]chan struct{}),
+		followers: make(map[string]map[string]struct{}),
+	}
+}
+
+// wait returns a channel closed on the next message of the room
+func (r *rooms) wait(locationID string) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.waiters[locationID]
+	if !ok {
+		ch = make(chan struct{})
+		r.waiters[locationID] = ch
+	}
+	return ch
+}
+
+func (r *rooms) notify(locationID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ch, ok := r.waiters[locationID]; ok {
+		close(ch)
+		delete(r.waiters, locationID)
+	}
+}
+
+func (r *rooms) follow(locationID, clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	followers, ok := r.followers[locationID]
+	if !ok {
+		followers = make(map[string]struct{})
+		r.followers[locationID] = followers
+	}
+	followers[clientID] = struct{}{}
+}
+
+func (r *rooms) unfollow(locationID, clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.followers[locationID], clientID)
+	if len(r.followers[locationID]) == 0 {
+		delete(r.followers, locationID)
+	}
+}
+
+func (r *rooms) listFollowers(locationID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]string, 0, len(r.followers[locationID]))
+	for clientID := range r.followers[locationID] {
+		res = append(res, clientID)
+	}
+	return res
+}
@@ -0,0 +1,466 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+// StreamType is the message type of the chat messages pushed to the followers of a room
+const StreamType = "chat"
+
+const (
+	reportedLimit   = 100
+	maxReasonLength = 200
+)
+
+var (
+	ErrRoomNotFound    = errors.New("chat room not found")
+	ErrMessageNotFound = errors.New("message not found")
+	ErrInvalidMessage  = errors.New("invalid message")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrInvalidReport   = errors.New("invalid report")
+	ErrAlreadyReported = errors.New("message already reported")
+	ErrNotPresent      = errors.New("check in or come closer to post in this room")
+	ErrRateLimited     = errors.New("too many messages, slow down")
+)
+
+type Service interface {
+	Post(ctx context.Context, clientID, locationID string, payload MessagePayload) (*Message, error)
+	// List returns the latest messages of the room or, with a cursor, the messages after it.
+	// With a cursor and no newer message it waits up to wait for one.
+	List(ctx context.Context, locationID, cursor string, wait time.Duration) (*Page, error)
+	Report(ctx context.Context, clientID, messageID string, payload ReportPayload) error
+	// Follow streams the messages of the room to the player until the stream closes
+	Follow(ctx context.Context, clientID, locationID string) error
+	Unfollow(ctx context.Context, clientID, locationID string) error
+	Reported(ctx context.Context) ([]ReportedMessage, error)
+	Delete(ctx context.Context, id string) error
+	// Cleanup removes the expired messages, it is the task of the cleanup worker
+	Cleanup(ctx context.Context) error
+}
+
+// PlayerFinder returns the players posting, players.Store satisfies it
+type PlayerFinder interface {
+	GetClientByID(ctx context.Context, id string) (*players.ClientStoreModel, error)
+}
+
+// CheckInFinder returns the latest check-in of a player at a location, checkins.Store satisfies it
+type CheckInFinder interface {
+	Last(ctx context.Context, clientID, locationID string) (*checkins.CheckInStoreModel, error)
+}
+
+// LocationProvider returns the game locations the rooms are attached to
+type LocationProvider interface {
+	Get(ctx context.Context, id string) (*locations.Location, error)
+}
+
+// Streamer pushes to the open streaming connections of a player and returns how many got it,
+// realtime.Gateway satisfies it
+type Streamer interface {
+	Send(clientID, messageType string, data interface{}) (int, error)
+}
+
+type Config struct {
+	// Range is the distance in meters within which a player may post without checking in
+	Range float64 `env:"CHAT_RANGE" envDefault:"100"`
+	// CheckInValidity is how long a check-in at the location allows the player to post
+	CheckInValidity time.Duration `env:"CHAT_CHECKIN_VALIDITY" envDefault:"1h"`
+	MessageTTL      time.Duration `env:"CHAT_MESSAGE_TTL" envDefault:"24h"`
+	MaxLength       int           `env:"CHAT_MAX_LENGTH" envDefault:"500"`
+	// RateLimit is the number of messages a player may post per RatePeriod
+	RateLimit  int           `env:"CHAT_RATE_LIMIT" envDefault:"5"`
+	RatePeriod time.Duration `env:"CHAT_RATE_PERIOD" envDefault:"30s"`
+	// ReportThreshold is the number of reports hiding a message
+	ReportThreshold int           `env:"CHAT_REPORT_THRESHOLD" envDefault:"3"`
+	PageSize        int           `env:"CHAT_PAGE_SIZE" envDefault:"50"`
+	MaxWait         time.Duration `env:"CHAT_MAX_WAIT" envDefault:"30s"`
+	CleanupInterval time.Duration `env:"CHAT_CLEANUP_INTERVAL" envDefault:"10m"`
+	// BlockedWords are masked in the messages
+	BlockedWords []string `env:"CHAT_BLOCKED_WORDS" envSeparator:","`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Range:           100,
+		CheckInValidity: time.Hour,
+		MessageTTL:      time.Hour * 24,
+		MaxLength:       500,
+		RateLimit:       5,
+		RatePeriod:      time.Second * 30,
+		ReportThreshold: 3,
+		PageSize:        50,
+		MaxWait:         time.Second * 30,
+		CleanupInterval: time.Minute * 10,
+	}
+}
+
+type Option func(*DefaultService)
+
+// WithContentFilter adds a filter every message passes before it is posted, filters run in order
+func WithContentFilter(f ContentFilter) Option {
+	return func(d *DefaultService) {
+		d.filters = append(d.filters, f)
+	}
+}
+
+// WithStreamer enables the delivery to the followers of the rooms
+func WithStreamer(s Streamer) Option {
+	return func(d *DefaultService) {
+		d.streamer = s
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	players   PlayerFinder
+	checkIns  CheckInFinder
+	locations LocationProvider
+	config    Config
+	dbTimeOut time.Duration
+	filters   []ContentFilter
+	streamer  Streamer
+	limiter   *limiter
+	rooms     *rooms
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, players PlayerFinder, checkIns CheckInFinder, locations LocationProvider, config Config, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		players:   players,
+		checkIns:  checkIns,
+		locations: locations,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+		limiter:   newLimiter(config.RateLimit, config.RatePeriod),
+		rooms:     newRooms(),
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+func (d *DefaultService) room(ctx context.Context, locationID string) (*locations.Location, error) {
+	loc, err := d.locations.Get(ctx, locationID)
+	if err != nil {
+		if errors.Is(err, locations.ErrNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, errors.New("failed to get chat room:" + err.Error())
+	}
+	return loc, nil
+}
+
+func (d *DefaultService) Post(ctx context.Context, clientID, locationID string, payload MessagePayload) (*Message, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		d.logger.Error("Post: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	body := strings.TrimSpace(payload.Body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidMessage)
+	}
+	if utf8.RuneCountInString(body) > d.config.MaxLength {
+		return nil, fmt.Errorf("%w: body is longer than %d characters", ErrInvalidMessage, d.config.MaxLength)
+	}
+	loc, err := d.room(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	player, err := d.players.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("Post: failed to get player", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to post message:" + err.Error())
+	}
+	now := time.Now().UTC()
+	present, err := d.present(dbCtx, player, loc, now)
+	if err != nil {
+		d.logger.Error("Post: failed to get last check-in", zap.String("clientID", clientID), zap.String("locationID", locationID), zap.Error(err))
+		return nil, errors.New("failed to post message:" + err.Error())
+	}
+	if !present {
+		return nil, ErrNotPresent
+	}
+	if !d.limiter.allow(clientID, now) {
+		return nil, ErrRateLimited
+	}
+	for _, f := range d.filters {
+		if body, err = f.Filter(ctx, clientID, body); err != nil {
+			if errors.Is(err, ErrRejected) {
+				return nil, err
+			}
+			d.logger.Error("Post: content filter failed", zap.String("clientID", clientID), zap.Error(err))
+			return nil, errors.New("failed to post message:" + err.Error())
+		}
+	}
+
+	// the cursors round trip through Postgres, which keeps microseconds
+	createdAt := now.Truncate(time.Microsecond)
+	model := MessageStoreModel{
+		ID:         uuid.New(),
+		LocationID: loc.ID,
+		ClientID:   id,
+		Handle:     player.Handle,
+		Body:       body,
+		CreatedAt:  createdAt,
+		ExpiresAt:  createdAt.Add(d.config.MessageTTL),
+	}
+	if err := d.store.Create(dbCtx, &model); err != nil {
+		d.logger.Error("Post: failed to create message", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to post message:" + err.Error())
+	}
+
+	msg := toMessage(&model)
+	d.rooms.notify(loc.ID)
+	d.stream(loc.ID, msg)
+	return &msg, nil
+}
+
+// present tells whether the player checked in at the location recently or is in range of it
+func (d *DefaultService) present(ctx context.Context, player *players.ClientStoreModel, loc *locations.Location, now time.Time) (bool, error) {
+	last, err := d.checkIns.Last(ctx, player.ID.String(), loc.ID)
+	if err != nil && err != checkins.ErrNotFound {
+		return false, err
+	}
+	if last != nil && now.Sub(last.CreatedAt) <= d.config.CheckInValidity {
+		return true, nil
+	}
+	if player.Point.Lon() == 0 && player.Point.Lat() == 0 {
+		return false, nil
+	}
+	distance := geo.Distance(
+		orb.Point{player.Point.Lon(), player.Point.Lat()},
+		orb.Point{loc.GeoPoint.Longitude, loc.GeoPoint.Latitude},
+	)
+	return distance <= d.config.Range, nil
+}
+
+func (d *DefaultService) stream(locationID string, msg Message) {
+	if d.streamer == nil {
+		return
+	}
+	for _, clientID := range d.rooms.listFollowers(locationID) {
+		n, err := d.streamer.Send(clientID, StreamType, msg)
+		if err != nil {
+			d.logger.Error("stream: failed to send message", zap.String("clientID", clientID), zap.Error(err))
+			continue
+		}
+		// the stream of the player closed
+		if n == 0 {
+			d.rooms.unfollow(locationID, clientID)
+		}
+	}
+}
+
+func (d *DefaultService) List(ctx context.Context, locationID, cursor string, wait time.Duration) (*Page, error) {
+	if _, err := d.room(ctx, locationID); err != nil {
+		return nil, err
+	}
+	if cursor == "" {
+		return d.latest(ctx, locationID)
+	}
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if wait > d.config.MaxWait {
+		wait = d.config.MaxWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		// taken before listing so a message posted in between wakes the wait
+		posted := d.rooms.wait(locationID)
+		models, err := d.listAfter(ctx, locationID, after)
+		if err != nil {
+			return nil, err
+		}
+		if len(models) > 0 || wait <= 0 {
+			return toPage(models, after), nil
+		}
+		select {
+		case <-posted:
+		case <-timer.C:
+			wait = 0
+		case <-ctx.Done():
+			return toPage(nil, after), nil
+		}
+	}
+}
+
+func (d *DefaultService) latest(ctx context.Context, locationID string) (*Page, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	now := time.Now().UTC()
+	models, err := d.store.ListLatest(dbCtx, locationID, now, d.config.PageSize)
+	if err != nil {
+		d.logger.Error("List: failed to list messages", zap.String("locationID", locationID), zap.Error(err))
+		return nil, errors.New("failed to list messages:" + err.Error())
+	}
+	for i, j := 0, len(models)-1; i < j; i, j = i+1, j-1 {
+		models[i], models[j] = models[j], models[i]
+	}
+	// an empty room hands out a cursor at the current time to wait on
+	return toPage(models, Cursor{CreatedAt: now.Truncate(time.Microsecond), ID: uuid.Nil.String()}), nil
+}
+
+func (d *DefaultService) listAfter(ctx context.Context, locationID string, after Cursor) ([]MessageStoreModel, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListAfter(dbCtx, locationID, after, time.Now().UTC(), d.config.PageSize)
+	if err != nil {
+		d.logger.Error("List: failed to list messages", zap.String("locationID", locationID), zap.Error(err))
+		return nil, errors.New("failed to list messages:" + err.Error())
+	}
+	return models, nil
+}
+
+// toPage returns the messages with the cursor of the last one, or the given cursor when there is none
+func toPage(models []MessageStoreModel, cursor Cursor) *Page {
+	res := &Page{Messages: make([]Message, 0, len(models))}
+	for i := range models {
+		res.Messages = append(res.Messages, toMessage(&models[i]))
+		cursor = cursorOf(&models[i])
+	}
+	res.Cursor = cursor.String()
+	return res
+}
+
+func (d *DefaultService) Report(ctx context.Context, clientID, messageID string, payload ReportPayload) error {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		d.logger.Error("Report: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return err
+	}
+	msgID, err := uuid.Parse(messageID)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	reason := strings.TrimSpace(payload.Reason)
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		return fmt.Errorf("%w: reason is longer than %d characters", ErrInvalidReport, maxReasonLength)
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	now := time.Now().UTC()
+	msg, err := d.store.Get(dbCtx, messageID)
+	if err != nil {
+		if err == ErrNotFound {
+			return ErrMessageNotFound
+		}
+		d.logger.Error("Report: failed to get message", zap.String("messageID", messageID), zap.Error(err))
+		return errors.New("failed to report message:" + err.Error())
+	}
+	if !msg.ExpiresAt.After(now) {
+		return ErrMessageNotFound
+	}
+	if msg.ClientID == id {
+		return fmt.Errorf("%w: players cannot report their own messages", ErrInvalidReport)
+	}
+	report := ReportStoreModel{
+		MessageID: msgID,
+		ClientID:  id,
+		Reason:    reason,
+		CreatedAt: now,
+	}
+	msg, err = d.store.CreateReport(dbCtx, &report, d.config.ReportThreshold)
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			return ErrMessageNotFound
+		case ErrDuplicateReport:
+			return ErrAlreadyReported
+		}
+		d.logger.Error("Report: failed to create report", zap.String("messageID", messageID), zap.Error(err))
+		return errors.New("failed to report message:" + err.Error())
+	}
+	if msg.Hidden && msg.Reports == d.config.ReportThreshold {
+		d.logger.Info("Report: message hidden", zap.String("messageID", messageID), zap.Int("reports", msg.Reports))
+	}
+	return nil
+}
+
+func (d *DefaultService) Follow(ctx context.Context, clientID, locationID string) error {
+	if _, err := d.room(ctx, locationID); err != nil {
+		return err
+	}
+	d.rooms.follow(locationID, clientID)
+	return nil
+}
+
+func (d *DefaultService) Unfollow(ctx context.Context, clientID, locationID string) error {
+	d.rooms.unfollow(locationID, clientID)
+	return nil
+}
+
+func (d *DefaultService) Reported(ctx context.Context) ([]ReportedMessage, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListReported(dbCtx, time.Now().UTC(), reportedLimit)
+	if err != nil {
+		d.logger.Error("Reported: failed to list reported messages", zap.Error(err))
+		return nil, errors.New("failed to list reported messages:" + err.Error())
+	}
+	res := make([]ReportedMessage, 0, len(models))
+	for i := range models {
+		res = append(res, ReportedMessage{
+			Message: toMessage(&models[i]),
+			Reports: models[i].Reports,
+			Hidden:  models[i].Hidden,
+		})
+	}
+	return res, nil
+}
+
+func (d *DefaultService) Delete(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrMessageNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.Delete(dbCtx, id); err != nil {
+		if err == ErrNotFound {
+			return ErrMessageNotFound
+		}
+		d.logger.Error("Delete: failed to delete message", zap.String("messageID", id), zap.Error(err))
+		return errors.New("failed to delete message:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) Cleanup(ctx context.Context) error {
+	now := time.Now().UTC()
+	d.limiter.evict(now)
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	n, err := d.store.DeleteExpired(dbCtx, now)
+	if err != nil {
+		d.logger.Error("Cleanup: failed to delete expired messages", zap.Error(err))
+		return err
+	}
+	if n > 0 {
+		d.logger.Info("Cleanup: deleted expired messages", zap.Int64("count", n))
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+type playerFunc func(id string) (*players.ClientStoreModel, error)
+
+func (f playerFunc) GetClientByID(ctx context.Context, id string) (*players.ClientStoreModel, error) {
+	return f(id)
+}
+
+type checkInFunc func(clientID, locationID string) (*checkins.CheckInStoreModel, error)
+
+func (f checkInFunc) Last(ctx context.Context, clientID, locationID string) (*checkins.CheckInStoreModel, error) {
+	return f(clientID, locationID)
+}
+
+type locationFunc func(id string) (*locations.Location, error)
+
+func (f locationFunc) Get(ctx context.Context, id string) (*locations.Location, error) {
+	return f(id)
+}
+
+type streamFunc func(clientID, messageType string, data interface{}) (int, error)
+
+func (f streamFunc) Send(clientID, messageType string, data interface{}) (int, error) {
+	return f(clientID, messageType, data)
+}
+
+// the room "1" is at 18.07, 59.33, the positions of the players are keyed by id
+func newTestService(positions map[string][2]float64, checkedIn map[string]bool, options ...Option) *DefaultService {
+	players := playerFunc(func(id string) (*players.ClientStoreModel, error) {
+		pos := positions[id]
+		return &players.ClientStoreModel{ID: uuid.MustParse(id), Name: "player", Handle: "player", Point: locations.NewPoint(pos[0], pos[1])}, nil
+	})
+	checkIns := checkInFunc(func(clientID, locationID string) (*checkins.CheckInStoreModel, error) {
+		if checkedIn[clientID] {
+			return &checkins.CheckInStoreModel{CreatedAt: time.Now().UTC().Add(-time.Minute)}, nil
+		}
+		return nil, checkins.ErrNotFound
+	})
+	locs := locationFunc(func(id string) (*locations.Location, error) {
+		if id != "1" {
+			return nil, locations.ErrNotFound
+		}
+		return &locations.Location{ID: "1", GeoPoint: locations.GeoPoint{Longitude: 18.07, Latitude: 59.33}}, nil
+	})
+	config := DefaultConfig()
+	config.RateLimit = 2
+	config.ReportThreshold = 2
+	return NewDefaultService(zap.NewNop(), NewMemStore(), players, checkIns, locs, config, time.Second, options...)
+}
+
+func TestDefaultService_Post(t *testing.T) {
+	near, far, visitor := uuid.New().String(), uuid.New().String(), uuid.New().String()
+	d := newTestService(
+		map[string][2]float64{near: {18.0701, 59.3301}, far: {18.1, 59.4}, visitor: {18.1, 59.4}},
+		map[string]bool{visitor: true},
+		WithContentFilter(NewWordFilter([]string{"darn"})),
+		WithContentFilter(FilterFunc(func(ctx context.Context, clientID, body string) (string, error) {
+			if strings.Contains(body, "http") {
+				return "", ErrRejected
+			}
+			return body, nil
+		})),
+	)
+	tests := []struct {
+		name     string
+		clientID string
+		location string
+		body     string
+		want     string
+		wantErr  error
+	}{
+		{name: "in range", clientID: near, location: "1", body: " hello ", want: "hello"},
+		{name: "checked in", clientID: visitor, location: "1", body: "Darn it", want: "**** it"},
+		{name: "out of range", clientID: far, location: "1", body: "hello", wantErr: ErrNotPresent},
+		{name: "unknown room", clientID: near, location: "2", body: "hello", wantErr: ErrRoomNotFound},
+		{name: "empty", clientID: near, location: "1", body: "  ", wantErr: ErrInvalidMessage},
+		{name: "too long", clientID: near, location: "1", body: strings.Repeat("a", 501), wantErr: ErrInvalidMessage},
+		{name: "rejected", clientID: near, location: "1", body: "see http://spam", wantErr: ErrRejected},
+		{name: "rate limited", clientID: near, location: "1", body: "hello", wantErr: ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := d.Post(context.TODO(), tt.clientID, tt.location, MessagePayload{Body: tt.body})
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, res.Body)
+		})
+	}
+}
+
+func TestDefaultService_List(t *testing.T) {
+	clientID := uuid.New().String()
+	d := newTestService(map[string][2]float64{clientID: {18.07, 59.33}}, nil)
+	d.limiter = newLimiter(10, time.Minute)
+	d.config.PageSize = 2
+
+	page, err := d.List(context.TODO(), "1", "", 0)
+	assert.Nil(t, err)
+	assert.Empty(t, page.Messages)
+	empty := page.Cursor
+	_, err = d.List(context.TODO(), "1", "nonsense", 0)
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	for _, body := range []string{"one", "two", "three"} {
+		_, err := d.Post(context.TODO(), clientID, "1", MessagePayload{Body: body})
+		assert.Nil(t, err)
+	}
+	page, err = d.List(context.TODO(), "1", "", 0)
+	assert.Nil(t, err)
+	assert.Len(t, page.Messages, 2)
+	assert.Equal(t, "two", page.Messages[0].Body)
+	assert.Equal(t, "three", page.Messages[1].Body)
+
+	page, err = d.List(context.TODO(), "1", empty, 0)
+	assert.Nil(t, err)
+	assert.Len(t, page.Messages, 2)
+	assert.Equal(t, "one", page.Messages[0].Body)
+	page, err = d.List(context.TODO(), "1", page.Cursor, 0)
+	assert.Nil(t, err)
+	assert.Len(t, page.Messages, 1)
+	assert.Equal(t, "three", page.Messages[0].Body)
+
+	// a long-polling reader is woken by the next message
+	cursor := page.Cursor
+	done := make(chan *Page)
+	go func() {
+		page, err := d.List(context.TODO(), "1", cursor, time.Second*5)
+		assert.Nil(t, err)
+		done <- page
+	}()
+	time.Sleep(time.Millisecond * 50)
+	_, err = d.Post(context.TODO(), clientID, "1", MessagePayload{Body: "four"})
+	assert.Nil(t, err)
+	select {
+	case page = <-done:
+		assert.Len(t, page.Messages, 1)
+		assert.Equal(t, "four", page.Messages[0].Body)
+	case <-time.After(time.Second * 2):
+		t.Fatal("long-polling reader was not woken")
+	}
+
+	// no message within the wait
+	page, err = d.List(context.TODO(), "1", page.Cursor, time.Millisecond*20)
+	assert.Nil(t, err)
+	assert.Empty(t, page.Messages)
+}
+
+func TestDefaultService_Report(t *testing.T) {
+	author, a, b := uuid.New().String(), uuid.New().String(), uuid.New().String()
+	d := newTestService(map[string][2]float64{author: {18.07, 59.33}}, nil)
+	msg, err := d.Post(context.TODO(), author, "1", MessagePayload{Body: "hello"})
+	assert.Nil(t, err)
+
+	assert.True(t, errors.Is(d.Report(context.TODO(), author, msg.ID, ReportPayload{}), ErrInvalidReport))
+	assert.Equal(t, ErrMessageNotFound, d.Report(context.TODO(), a, uuid.New().String(), ReportPayload{}))
+	assert.Nil(t, d.Report(context.TODO(), a, msg.ID, ReportPayload{Reason: "rude"}))
+	assert.Equal(t, ErrAlreadyReported, d.Report(context.TODO(), a, msg.ID, ReportPayload{Reason: "rude"}))
+
+	page, err := d.List(context.TODO(), "1", "", 0)
+	assert.Nil(t, err)
+	assert.Len(t, page.Messages, 1)
+	// the second report hides the message
+	assert.Nil(t, d.Report(context.TODO(), b, msg.ID, ReportPayload{}))
+	page, err = d.List(context.TODO(), "1", "", 0)
+	assert.Nil(t, err)
+	assert.Empty(t, page.Messages)
+
+	reported, err := d.Reported(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, reported, 1)
+	assert.Equal(t, 2, reported[0].Reports)
+	assert.True(t, reported[0].Hidden)
+
+	assert.Nil(t, d.Delete(context.TODO(), msg.ID))
+	assert.Equal(t, ErrMessageNotFound, d.Delete(context.TODO(), msg.ID))
+}
+
+func TestDefaultService_Follow(t *testing.T) {
+	author, follower := uuid.New().String(), uuid.New().String()
+	connected := true
+	received := make([]string, 0)
+	streamer := streamFunc(func(clientID, messageType string, data interface{}) (int, error) {
+		if !connected {
+			return 0, nil
+		}
+		received = append(received, clientID+":"+data.(Message).Body)
+		return 1, nil
+	})
+	d := newTestService(map[string][2]float64{author: {18.07, 59.33}}, nil, WithStreamer(streamer))
+
+	assert.Equal(t, ErrRoomNotFound, d.Follow(context.TODO(), follower, "2"))
+	assert.Nil(t, d.Follow(context.TODO(), follower, "1"))
+	_, err := d.Post(context.TODO(), author, "1", MessagePayload{Body: "hello"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{follower + ":hello"}, received)
+
+	// a closed stream drops the follower
+	connected = false
+	_, err = d.Post(context.TODO(), author, "1", MessagePayload{Body: "again"})
+	assert.Nil(t, err)
+	assert.Empty(t, d.rooms.listFollowers("1"))
+}
+
+func TestDefaultService_Cleanup(t *testing.T) {
+	clientID := uuid.New().String()
+	d := newTestService(map[string][2]float64{clientID: {18.07, 59.33}}, nil)
+	d.config.MessageTTL = -time.Second
+	_, err := d.Post(context.TODO(), clientID, "1", MessagePayload{Body: "gone"})
+	assert.Nil(t, err)
+	page, err := d.List(context.TODO(), "1", "", 0)
+	assert.Nil(t, err)
+	assert.Empty(t, page.Messages)
+
+	assert.Nil(t, d.Cleanup(context.TODO()))
+	assert.Empty(t, d.store.(*MemStore).messages)
+}
@@ -0,0 +1,28 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicateReport is returned when the player already reported the message
+var ErrDuplicateReport = errors.New("message already reported")
+
+type Store interface {
+	Create(ctx context.Context, model *MessageStoreModel) error
+	Get(ctx context.Context, id string) (*MessageStoreModel, error)
+	// ListLatest returns the newest visible messages of the room, newest first
+	ListLatest(ctx context.Context, locationID string, now time.Time, limit int) ([]MessageStoreModel, error)
+	// ListAfter returns the visible messages of the room posted after the cursor, oldest first
+	ListAfter(ctx context.Context, locationID string, after Cursor, now time.Time, limit int) ([]MessageStoreModel, error)
+	// CreateReport records the report and counts it on the message, the message is hidden once it has hideAt reports
+	CreateReport(ctx context.Context, model *ReportStoreModel, hideAt int) (*MessageStoreModel, error)
+	// ListReported returns the unexpired messages with reports, the most reported first
+	ListReported(ctx context.Context, now time.Time, limit int) ([]MessageStoreModel, error)
+	Delete(ctx context.Context, id string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,52 @@
+package checkins
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const replayPageSize = 500
+
+// History replays the stored check-ins, it only needs the store so it can be built before the services using it
+type History struct {
+	logger    *zap.Logger
+	store     Store
+	dbTimeOut time.Duration
+}
+
+func NewHistory(logger *zap.Logger, store Store, dbTimeOut time.Duration) *History {
+	return &History{
+		logger:    logger,
+		store:     store,
+		dbTimeOut: dbTimeOut,
+	}
+}
+
+// Replay hands every check-in up to the given time to fn, the oldest first
+func (h *History) Replay(ctx context.Context, until time.Time, fn func(CheckIn) error) error {
+	var after CheckInStoreModel
+	for {
+		dbCtx, cancel := context.WithTimeout(ctx, h.dbTimeOut)
+		page, err := h.store.ListAfter(dbCtx, after.CreatedAt, after.ID.String(), replayPageSize)
+		cancel()
+		if err != nil {
+			h.logger.Error("Replay: failed to list check-ins", zap.Error(err))
+			return errors.New("failed to replay check-ins:" + err.Error())
+		}
+		for i := range page {
+			if page[i].CreatedAt.After(until) {
+				return nil
+			}
+			if err := fn(toCheckIn(&page[i])); err != nil {
+				return err
+			}
+		}
+		if len(page) < replayPageSize {
+			return nil
+		}
+		after = page[len(page)-1]
+	}
+}
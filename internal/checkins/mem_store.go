@@ -0,0 +1,76 @@
+package checkins
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu       sync.RWMutex
+	checkIns []*CheckInStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{}
+}
+
+func (m *MemStore) Create(ctx context.Context, model *CheckInStoreModel, since time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.checkIns {
+		if c.ClientID == model.ClientID && c.LocationID == model.LocationID && c.CreatedAt.After(since) {
+			return ErrRecentCheckIn
+		}
+	}
+	m.checkIns = append(m.checkIns, model)
+	return nil
+}
+
+func (m *MemStore) Last(ctx context.Context, clientID, locationID string) (*CheckInStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := len(m.checkIns) - 1; i >= 0; i-- {
+		c := m.checkIns[i]
+		if c.ClientID.String() == clientID && c.LocationID == locationID {
+			return c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemStore) ListByClient(ctx context.Context, clientID string, limit int) ([]CheckInStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]CheckInStoreModel, 0)
+	for i := len(m.checkIns) - 1; i >= 0 && len(res) < limit; i-- {
+		if m.checkIns[i].ClientID.String() == clientID {
+			res = append(res, *m.checkIns[i])
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) ListAfter(ctx context.Context, createdAt time.Time, id string, limit int) ([]CheckInStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]CheckInStoreModel, 0)
+	for _, c := range m.checkIns {
+		if c.CreatedAt.After(createdAt) || (c.CreatedAt.Equal(createdAt) && c.ID.String() > id) {
+			res = append(res, *c)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.Before(res[j].CreatedAt)
+		}
+		return res[i].ID.String() < res[j].ID.String()
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package checkins
+
+import (
+	"context"
+	"time"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateFunc       func(model *CheckInStoreModel, since time.Time) error
+	LastFunc         func(clientID, locationID string) (*CheckInStoreModel, error)
+	ListByClientFunc func(clientID string, limit int) ([]CheckInStoreModel, error)
+	ListAfterFunc    func(createdAt time.Time, id string, limit int) ([]CheckInStoreModel, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateFunc: func(model *CheckInStoreModel, since time.Time) error {
+			return nil
+		},
+		LastFunc: func(clientID, locationID string) (*CheckInStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListByClientFunc: func(clientID string, limit int) ([]CheckInStoreModel, error) {
+			return []CheckInStoreModel{}, nil
+		},
+		ListAfterFunc: func(createdAt time.Time, id string, limit int) ([]CheckInStoreModel, error) {
+			return []CheckInStoreModel{}, nil
+		},
+	}
+}
+
+func (m *MockStore) Create(ctx context.Context, model *CheckInStoreModel, since time.Time) error {
+	return m.CreateFunc(model, since)
+}
+
+func (m *MockStore) Last(ctx context.Context, clientID, locationID string) (*CheckInStoreModel, error) {
+	return m.LastFunc(clientID, locationID)
+}
+
+func (m *MockStore) ListByClient(ctx context.Context, clientID string, limit int) ([]CheckInStoreModel, error) {
+	return m.ListByClientFunc(clientID, limit)
+}
+
+func (m *MockStore) ListAfter(ctx context.Context, createdAt time.Time, id string, limit int) ([]CheckInStoreModel, error) {
+	return m.ListAfterFunc(createdAt, id, limit)
+}
@@ -0,0 +1,42 @@
+package checkins
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"geogame/internal/locations"
+)
+
+type CheckInStoreModel struct {
+	ID           uuid.UUID       `db:"id"`
+	ClientID     uuid.UUID       `db:"client_id"`
+	LocationID   string          `db:"loc_id"`
+	LocationType string          `db:"loc_type"`
+	Point        locations.Point `db:"point"`
+	CreatedAt    time.Time       `db:"created_at"`
+}
+
+// CheckIn is handed to the listeners and returned to the player
+type CheckIn struct {
+	ID           string             `json:"id"`
+	ClientID     string             `json:"-"`
+	LocationID   string             `json:"locationId"`
+	LocationType string             `json:"locationType"`
+	GeoPoint     locations.GeoPoint `json:"geoPoint"`
+	CreatedAt    time.Time          `json:"createdAt"`
+}
+
+func toCheckIn(m *CheckInStoreModel) CheckIn {
+	return CheckIn{
+		ID:           m.ID.String(),
+		ClientID:     m.ClientID.String(),
+		LocationID:   m.LocationID,
+		LocationType: m.LocationType,
+		GeoPoint: locations.GeoPoint{
+			Longitude: m.Point.Lon(),
+			Latitude:  m.Point.Lat(),
+		},
+		CreatedAt: m.CreatedAt,
+	}
+}
@@ -0,0 +1,118 @@
+package checkins
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	checkInsAllCols = "id, client_id, loc_id, loc_type, ST_AsBinary(point) AS point, created_at"
+	checkInsTable   = "checkins"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.checkins.store"),
+	}
+}
+
+func (p Postgres) Create(ctx context.Context, model *CheckInStoreModel, since time.Time) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("Create: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	// the lock is held until the end of the transaction, a concurrent check-in waits and then sees this one
+	clientID, locationID := model.ClientID.String(), model.LocationID
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1 || '/' || $2))", clientID, locationID); err != nil {
+		p.logger.Error("Create: failed to lock check-ins", zap.Error(err))
+		return err
+	}
+	var recent bool
+	stmt := "SELECT EXISTS (SELECT 1 FROM " + checkInsTable + " WHERE client_id=$1 AND loc_id=$2 AND created_at > $3)"
+	if err := tx.GetContext(ctx, &recent, stmt, clientID, locationID, since); err != nil {
+		p.logger.Error("Create: failed to get recent check-in from db", zap.Error(err))
+		return err
+	}
+	if recent {
+		return ErrRecentCheckIn
+	}
+	insert := `INSERT INTO checkins (
+	id,
+	client_id,
+	loc_id,
+	loc_type,
+	point,
+	created_at
+	) VALUES (
+	:id,
+	:client_id,
+	:loc_id,
+	:loc_type,
+	:point,
+	:created_at
+	)`
+	if _, err := tx.NamedExecContext(ctx, insert, *model); err != nil {
+		p.logger.Error("Create: failed to insert check-in to db", zap.Error(err))
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) Last(ctx context.Context, clientID, locationID string) (*CheckInStoreModel, error) {
+	stmt := "SELECT " + checkInsAllCols + " FROM " + checkInsTable + `
+	WHERE client_id=$1 AND loc_id=$2
+	ORDER BY created_at DESC
+	LIMIT 1`
+	var c CheckInStoreModel
+	if err := p.db.GetContext(ctx, &c, stmt, clientID, locationID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("Last: failed to get check-in from db", zap.Error(err))
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (p Postgres) ListByClient(ctx context.Context, clientID string, limit int) ([]CheckInStoreModel, error) {
+	stmt := "SELECT " + checkInsAllCols + " FROM " + checkInsTable + `
+	WHERE client_id=$1
+	ORDER BY created_at DESC
+	LIMIT $2`
+	res := make([]CheckInStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID, limit); err != nil {
+		p.logger.Error("ListByClient: failed to list check-ins from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) ListAfter(ctx context.Context, createdAt time.Time, id string, limit int) ([]CheckInStoreModel, error) {
+	stmt := "SELECT " + checkInsAllCols + " FROM " + checkInsTable + `
+	WHERE (created_at, id::text) > ($1, $2)
+	ORDER BY created_at, id::text
+	LIMIT $3`
+	res := make([]CheckInStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, createdAt, id, limit); err != nil {
+		p.logger.Error("ListAfter: failed to list check-ins from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
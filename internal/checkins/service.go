@@ -0,0 +1,178 @@
+package checkins
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+)
+
+var (
+	ErrLocationNotFound = errors.New("location not found")
+	ErrOutOfRange       = errors.New("player is too far away from the location")
+	ErrNoPosition       = errors.New("player position is unknown, send a location first")
+	ErrCheckInCooldown  = errors.New("already checked in at this location recently")
+)
+
+type Service interface {
+	CheckIn(ctx context.Context, clientID, locationID string) (*CheckIn, error)
+	History(ctx context.Context, clientID string) ([]CheckIn, error)
+}
+
+// Listener is notified in-process after every successful check-in
+type Listener interface {
+	OnCheckIn(ctx context.Context, checkIn CheckIn) error
+}
+
+// ListenerFunc adapts a function to the Listener interface
+type ListenerFunc func(ctx context.Context, checkIn CheckIn) error
+
+func (f ListenerFunc) OnCheckIn(ctx context.Context, checkIn CheckIn) error {
+	return f(ctx, checkIn)
+}
+
+// PositionProvider returns the last known position of a player
+type PositionProvider interface {
+	GetLocation(ctx context.Context, clientID string) (*locations.Location, error)
+}
+
+// LocationProvider returns the game locations
+type LocationProvider interface {
+	Get(ctx context.Context, id string) (*locations.Location, error)
+}
+
+type Config struct {
+	// Range is the maximum distance in meters between the player and the location
+	Range float64 `env:"CHECKIN_RANGE" envDefault:"100"`
+	// Cooldown is the minimum time between two check-ins of a player at the same location
+	Cooldown time.Duration `env:"CHECKIN_COOLDOWN" envDefault:"10m"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Range:    100,
+		Cooldown: time.Minute * 10,
+	}
+}
+
+type Option func(*DefaultService)
+
+// WithListener registers a listener notified after every check-in
+func WithListener(l Listener) Option {
+	return func(d *DefaultService) {
+		d.listeners = append(d.listeners, l)
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	positions PositionProvider
+	locations LocationProvider
+	config    Config
+	dbTimeOut time.Duration
+	listeners []Listener
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, positions PositionProvider, locations LocationProvider, config Config, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		positions: positions,
+		locations: locations,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+// CheckIn records the player at the location when the last known position is in range
+func (d *DefaultService) CheckIn(ctx context.Context, clientID, locationID string) (*CheckIn, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		d.logger.Error("CheckIn: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	loc, err := d.locations.Get(ctx, locationID)
+	if err != nil {
+		if errors.Is(err, locations.ErrNotFound) {
+			return nil, ErrLocationNotFound
+		}
+		return nil, errors.New("failed to check in:" + err.Error())
+	}
+	pos, err := d.positions.GetLocation(ctx, clientID)
+	if err != nil {
+		d.logger.Error("CheckIn: failed to get player position", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to check in:" + err.Error())
+	}
+	if pos.GeoPoint.Longitude == 0 && pos.GeoPoint.Latitude == 0 {
+		return nil, ErrNoPosition
+	}
+	distance := geo.Distance(
+		orb.Point{pos.GeoPoint.Longitude, pos.GeoPoint.Latitude},
+		orb.Point{loc.GeoPoint.Longitude, loc.GeoPoint.Latitude},
+	)
+	if distance > d.config.Range {
+		return nil, ErrOutOfRange
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	now := time.Now().UTC()
+	model := CheckInStoreModel{
+		ID:           uuid.New(),
+		ClientID:     id,
+		LocationID:   loc.ID,
+		LocationType: loc.MetaData.LocationType,
+		Point:        locations.NewPoint(loc.GeoPoint.Longitude, loc.GeoPoint.Latitude),
+		CreatedAt:    now,
+	}
+	// the cooldown is checked by the store together with the insert, concurrent requests can't both pass it
+	if err := d.store.Create(dbCtx, &model, now.Add(-d.config.Cooldown)); err != nil {
+		if err == ErrRecentCheckIn {
+			return nil, ErrCheckInCooldown
+		}
+		d.logger.Error("CheckIn: failed to create check-in", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to check in:" + err.Error())
+	}
+
+	checkIn := toCheckIn(&model)
+	for _, l := range d.listeners {
+		// the check-in is recorded, a failing listener must not hide it from the others
+		if err := l.OnCheckIn(ctx, checkIn); err != nil {
+			d.logger.Error("CheckIn: listener failed", zap.String("clientID", clientID), zap.String("locationID", locationID), zap.Error(err))
+		}
+	}
+	return &checkIn, nil
+}
+
+// History returns the latest check-ins of the player
+func (d *DefaultService) History(ctx context.Context, clientID string) ([]CheckIn, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("History: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListByClient(dbCtx, clientID, 100)
+	if err != nil {
+		d.logger.Error("History: failed to list check-ins", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list check-ins:" + err.Error())
+	}
+	res := make([]CheckIn, 0, len(models))
+	for i := range models {
+		res = append(res, toCheckIn(&models[i]))
+	}
+	return res, nil
+}
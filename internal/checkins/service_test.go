@@ -0,0 +1,112 @@
+package checkins
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+)
+
+type positionFunc func(clientID string) (*locations.Location, error)
+
+func (f positionFunc) GetLocation(ctx context.Context, clientID string) (*locations.Location, error) {
+	return f(clientID)
+}
+
+func TestDefaultService_CheckIn(t *testing.T) {
+	locStore := locations.NewMemStore(make(map[interface{}]locations.LocationStoreModel))
+	locSvc := locations.NewDefaultService(zap.NewNop(), locStore)
+	assert.Nil(t, locSvc.Create(context.TODO(), locations.Location{
+		ID:       "1",
+		GeoPoint: locations.GeoPoint{Longitude: 18.0686, Latitude: 59.3293},
+		MetaData: locations.MetaData{LocationName: "Stockholm", LocationType: "city"},
+	}))
+	position := locations.GeoPoint{Longitude: 18.0690, Latitude: 59.3295}
+	positions := positionFunc(func(clientID string) (*locations.Location, error) {
+		return &locations.Location{GeoPoint: position}, nil
+	})
+
+	var notified []CheckIn
+	listener := ListenerFunc(func(ctx context.Context, c CheckIn) error {
+		notified = append(notified, c)
+		return errors.New("listener failures are logged only")
+	})
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), positions, locSvc, DefaultConfig(), time.Second, WithListener(listener))
+	clientID := uuid.New().String()
+
+	_, err := d.CheckIn(context.TODO(), clientID, "unknown")
+	assert.Equal(t, ErrLocationNotFound, err)
+
+	c, err := d.CheckIn(context.TODO(), clientID, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "city", c.LocationType)
+	assert.Len(t, notified, 1)
+	assert.Equal(t, clientID, notified[0].ClientID)
+
+	_, err = d.CheckIn(context.TODO(), clientID, "1")
+	assert.Equal(t, ErrCheckInCooldown, err)
+
+	// concurrent check-ins of another player, only one of them is credited
+	other := uuid.New().String()
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := d.CheckIn(context.TODO(), other, "1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	checkedIn := 0
+	for err := range errs {
+		if err == nil {
+			checkedIn++
+		} else {
+			assert.Equal(t, ErrCheckInCooldown, err)
+		}
+	}
+	assert.Equal(t, 1, checkedIn)
+
+	position = locations.GeoPoint{Longitude: 18.08, Latitude: 59.33}
+	_, err = d.CheckIn(context.TODO(), uuid.New().String(), "1")
+	assert.Equal(t, ErrOutOfRange, err)
+
+	history, err := d.History(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestHistory_Replay(t *testing.T) {
+	store := NewMemStore()
+	h := NewHistory(zap.NewNop(), store, time.Second)
+	now := time.Now().UTC()
+	for i := 0; i < 1200; i++ {
+		assert.Nil(t, store.Create(context.TODO(), &CheckInStoreModel{
+			ID:         uuid.New(),
+			ClientID:   uuid.New(),
+			LocationID: "1",
+			CreatedAt:  now.Add(time.Duration(i%600) * time.Second),
+		}, now))
+	}
+
+	var replayed []CheckIn
+	err := h.Replay(context.TODO(), now.Add(time.Second*299), func(c CheckIn) error {
+		replayed = append(replayed, c)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, replayed, 600)
+	for i := 1; i < len(replayed); i++ {
+		assert.False(t, replayed[i].CreatedAt.Before(replayed[i-1].CreatedAt))
+	}
+}
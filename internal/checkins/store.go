@@ -0,0 +1,25 @@
+package checkins
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by the stores when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrRecentCheckIn is returned by Create when the player already checked in at the location since the given time
+	ErrRecentCheckIn = errors.New("recent check-in at the location")
+)
+
+type Store interface {
+	// Create stores the check-in unless the player checked in at the location after since,
+	// concurrent check-ins of a player at a location are serialized so only one of them is stored
+	Create(ctx context.Context, model *CheckInStoreModel, since time.Time) error
+	// Last returns the latest check-in of the player at the location
+	Last(ctx context.Context, clientID, locationID string) (*CheckInStoreModel, error)
+	ListByClient(ctx context.Context, clientID string, limit int) ([]CheckInStoreModel, error)
+	// ListAfter pages through all check-ins ordered by creation, the page starts after the given check-in
+	ListAfter(ctx context.Context, createdAt time.Time, id string, limit int) ([]CheckInStoreModel, error)
+}
@@ -0,0 +1,131 @@
+package encounters
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+
+	"geogame/internal/locations"
+)
+
+const metersPerDegree = 111320.0
+
+// Position is a recent position of a visible player
+type Position struct {
+	ClientID string
+	Handle   string
+	Point    orb.Point
+	// Exposed is the position with the privacy settings of the player applied
+	Exposed locations.GeoPoint
+	At      time.Time
+}
+
+type cell struct {
+	x, y int
+}
+
+// Index is an in-memory grid of the recent player positions, positions older than the ttl are ignored and evicted
+type Index struct {
+	mu        sync.RWMutex
+	cellSize  float64
+	ttl       time.Duration
+	cells     map[cell]map[string]*Position
+	positions map[string]*Position
+}
+
+// NewIndex creates an index with cells of the given size in meters, it should be close to the query radius
+func NewIndex(cellMeters float64, ttl time.Duration) *Index {
+	return &Index{
+		cellSize:  math.Max(cellMeters, 1) / metersPerDegree,
+		ttl:       ttl,
+		cells:     make(map[cell]map[string]*Position),
+		positions: make(map[string]*Position),
+	}
+}
+
+func (i *Index) cellOf(p orb.Point) cell {
+	return cell{x: int(math.Floor(p.Lon() / i.cellSize)), y: int(math.Floor(p.Lat() / i.cellSize))}
+}
+
+// Put stores the position of the player, it replaces the previous one
+func (i *Index) Put(p Position) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(p.ClientID)
+	c := i.cellOf(p.Point)
+	if _, ok := i.cells[c]; !ok {
+		i.cells[c] = make(map[string]*Position)
+	}
+	i.cells[c][p.ClientID] = &p
+	i.positions[p.ClientID] = &p
+}
+
+// Remove drops the position of the player
+func (i *Index) Remove(clientID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(clientID)
+}
+
+// remove drops the position, the caller holds the lock
+func (i *Index) remove(clientID string) {
+	p, ok := i.positions[clientID]
+	if !ok {
+		return
+	}
+	c := i.cellOf(p.Point)
+	delete(i.cells[c], clientID)
+	if len(i.cells[c]) == 0 {
+		delete(i.cells, c)
+	}
+	delete(i.positions, clientID)
+}
+
+// Nearby returns the positions within the radius in meters that are not older than the ttl
+func (i *Index) Nearby(point orb.Point, meters float64, now time.Time) []Position {
+	latSpan := meters / metersPerDegree
+	lonSpan := meters / (metersPerDegree * math.Max(math.Cos(point.Lat()*math.Pi/180), 0.01))
+	min := i.cellOf(orb.Point{point.Lon() - lonSpan, point.Lat() - latSpan})
+	max := i.cellOf(orb.Point{point.Lon() + lonSpan, point.Lat() + latSpan})
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	res := make([]Position, 0)
+	for x := min.x; x <= max.x; x++ {
+		for y := min.y; y <= max.y; y++ {
+			for _, p := range i.cells[cell{x: x, y: y}] {
+				if now.Sub(p.At) > i.ttl {
+					continue
+				}
+				if geo.Distance(point, p.Point) <= meters {
+					res = append(res, *p)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// Evict drops the positions older than the ttl and returns how many were dropped
+func (i *Index) Evict(now time.Time) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	count := 0
+	for id, p := range i.positions {
+		if now.Sub(p.At) > i.ttl {
+			i.remove(id)
+			count++
+		}
+	}
+	return count
+}
+
+// Len returns the number of indexed players
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.positions)
+}
@@ -0,0 +1,36 @@
+package encounters
+
+import (
+	"context"
+	"sync"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu         sync.RWMutex
+	encounters []EncounterStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{}
+}
+
+func (m *MemStore) Create(ctx context.Context, models []EncounterStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.encounters = append(m.encounters, models...)
+	return nil
+}
+
+func (m *MemStore) ListByClient(ctx context.Context, clientID string, limit int) ([]EncounterStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]EncounterStoreModel, 0)
+	for i := len(m.encounters) - 1; i >= 0 && len(res) < limit; i-- {
+		if m.encounters[i].ClientID.String() == clientID {
+			res = append(res, m.encounters[i])
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,29 @@
+package encounters
+
+import "context"
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateFunc       func(models []EncounterStoreModel) error
+	ListByClientFunc func(clientID string, limit int) ([]EncounterStoreModel, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateFunc: func(models []EncounterStoreModel) error {
+			return nil
+		},
+		ListByClientFunc: func(clientID string, limit int) ([]EncounterStoreModel, error) {
+			return []EncounterStoreModel{}, nil
+		},
+	}
+}
+
+func (m *MockStore) Create(ctx context.Context, models []EncounterStoreModel) error {
+	return m.CreateFunc(models)
+}
+
+func (m *MockStore) ListByClient(ctx context.Context, clientID string, limit int) ([]EncounterStoreModel, error) {
+	return m.ListByClientFunc(clientID, limit)
+}
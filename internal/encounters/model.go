@@ -0,0 +1,41 @@
+package encounters
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"geogame/internal/locations"
+)
+
+// EncounterStoreModel is recorded for both players, OtherID is the player met
+type EncounterStoreModel struct {
+	ID          uuid.UUID       `db:"id"`
+	ClientID    uuid.UUID       `db:"client_id"`
+	OtherID     uuid.UUID       `db:"other_id"`
+	OtherHandle string          `db:"other_handle"`
+	Point       locations.Point `db:"point"`
+	CreatedAt   time.Time       `db:"created_at"`
+}
+
+type Encounter struct {
+	ID       string `json:"id"`
+	PlayerID string `json:"playerId"`
+	Handle   string `json:"handle"`
+	// GeoPoint is where the other player was, as far as the privacy settings of the player tell
+	GeoPoint  locations.GeoPoint `json:"geoPoint"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+func toEncounter(m *EncounterStoreModel) Encounter {
+	return Encounter{
+		ID:       m.ID.String(),
+		PlayerID: m.OtherID.String(),
+		Handle:   m.OtherHandle,
+		GeoPoint: locations.GeoPoint{
+			Longitude: m.Point.Lon(),
+			Latitude:  m.Point.Lat(),
+		},
+		CreatedAt: m.CreatedAt,
+	}
+}
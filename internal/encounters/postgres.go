@@ -0,0 +1,73 @@
+package encounters
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	encountersAllCols = "id, client_id, other_id, other_handle, ST_AsBinary(point) AS point, created_at"
+	encountersTable   = "encounters"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.encounters.store"),
+	}
+}
+
+func (p Postgres) Create(ctx context.Context, models []EncounterStoreModel) error {
+	stmt := `INSERT INTO encounters (
+	id,
+	client_id,
+	other_id,
+	other_handle,
+	point,
+	created_at
+	) VALUES (
+	:id,
+	:client_id,
+	:other_id,
+	:other_handle,
+	:point,
+	:created_at
+	)`
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("Create: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+	for _, m := range models {
+		if _, err := tx.NamedExecContext(ctx, stmt, m); err != nil {
+			p.logger.Error("Create: failed to insert encounter to db", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) ListByClient(ctx context.Context, clientID string, limit int) ([]EncounterStoreModel, error) {
+	stmt := "SELECT " + encountersAllCols + " FROM " + encountersTable + `
+	WHERE client_id=$1
+	ORDER BY created_at DESC
+	LIMIT $2`
+	res := make([]EncounterStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID, limit); err != nil {
+		p.logger.Error("ListByClient: failed to list encounters from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
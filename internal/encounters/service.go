@@ -0,0 +1,253 @@
+package encounters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+const listLimit = 100
+
+type Service interface {
+	List(ctx context.Context, clientID string) ([]Encounter, error)
+	// Evict drops the positions and pairs that fell out of the window
+	Evict(ctx context.Context) error
+	players.LocationListener
+}
+
+// Listener is notified in-process of every recorded encounter, once per player
+type Listener interface {
+	OnEncounter(ctx context.Context, clientID string, encounter Encounter) error
+}
+
+// BlockChecker tells whether a player blocked another one, players.Store satisfies it
+type BlockChecker interface {
+	IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error)
+}
+
+type Config struct {
+	// Distance in meters under which two players meet
+	Distance float64 `env:"ENCOUNTER_DISTANCE" envDefault:"50"`
+	// Window is how long a position counts as active
+	Window time.Duration `env:"ENCOUNTER_WINDOW" envDefault:"5m"`
+	// Cooldown is the minimum time between two encounters of the same players
+	Cooldown time.Duration `env:"ENCOUNTER_COOLDOWN" envDefault:"1h"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Distance: 50,
+		Window:   5 * time.Minute,
+		Cooldown: time.Hour,
+	}
+}
+
+type Option func(*DefaultService)
+
+// WithListener registers a listener notified of every encounter
+func WithListener(l Listener) Option {
+	return func(d *DefaultService) {
+		d.listeners = append(d.listeners, l)
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	blocks    BlockChecker
+	index     *Index
+	config    Config
+	dbTimeOut time.Duration
+	listeners []Listener
+
+	mu     sync.Mutex
+	recent map[[2]string]time.Time
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, blocks BlockChecker, config Config, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		blocks:    blocks,
+		index:     NewIndex(config.Distance, config.Window),
+		config:    config,
+		dbTimeOut: dbTimeOut,
+		recent:    make(map[[2]string]time.Time),
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+func (d *DefaultService) List(ctx context.Context, clientID string) ([]Encounter, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListByClient(dbCtx, clientID, listLimit)
+	if err != nil {
+		d.logger.Error("List: failed to list encounters", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list encounters:" + err.Error())
+	}
+	res := make([]Encounter, 0, len(models))
+	for i := range models {
+		res = append(res, toEncounter(&models[i]))
+	}
+	return res, nil
+}
+
+// OnLocationUpdate indexes the position and records an encounter with every player nearby who shares
+// the exact position. An encounter tells the other player where the player was within the encounter distance,
+// so the players blurring their position in the grid, offset or city mode are treated like hidden ones
+func (d *DefaultService) OnLocationUpdate(ctx context.Context, update players.LocationUpdate) error {
+	if update.Exposed == nil || update.Mode != players.PrivacyExact {
+		// hidden and blurred players neither meet nor are met
+		d.index.Remove(update.ClientID)
+		return nil
+	}
+	point := orb.Point{update.GeoPoint.Longitude, update.GeoPoint.Latitude}
+	self := Position{
+		ClientID: update.ClientID,
+		Handle:   update.Handle,
+		Point:    point,
+		Exposed:  update.Exposed.GeoPoint,
+		At:       update.At,
+	}
+	d.index.Put(self)
+
+	models := make([]EncounterStoreModel, 0)
+	for _, other := range d.index.Nearby(point, d.config.Distance, update.At) {
+		if other.ClientID == update.ClientID {
+			continue
+		}
+		ok, err := d.allowed(ctx, update.ClientID, other.ClientID)
+		if err != nil {
+			return err
+		}
+		if !ok || !d.claimPair(update.ClientID, other.ClientID, update.At) {
+			continue
+		}
+		pair, err := newPair(self, other, update.At)
+		if err != nil {
+			d.logger.Error("OnLocationUpdate: invalid player id", zap.String("clientID", update.ClientID), zap.Error(err))
+			continue
+		}
+		models = append(models, pair...)
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.Create(dbCtx, models); err != nil {
+		d.logger.Error("OnLocationUpdate: failed to store encounters", zap.String("clientID", update.ClientID), zap.Error(err))
+		return errors.New("failed to store encounters:" + err.Error())
+	}
+	for i := range models {
+		encounter := toEncounter(&models[i])
+		clientID := models[i].ClientID.String()
+		for _, l := range d.listeners {
+			// the encounter is recorded, a failing listener must not hide it from the others
+			if err := l.OnEncounter(ctx, clientID, encounter); err != nil {
+				d.logger.Error("OnLocationUpdate: listener failed", zap.String("clientID", clientID), zap.Error(err))
+			}
+		}
+	}
+	return nil
+}
+
+// allowed reports whether neither of the players blocked the other
+func (d *DefaultService) allowed(ctx context.Context, a, b string) (bool, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	for _, ids := range [][2]string{{a, b}, {b, a}} {
+		blocked, err := d.blocks.IsBlocked(dbCtx, ids[0], ids[1])
+		if err != nil {
+			d.logger.Error("allowed: failed to check block", zap.String("blockerID", ids[0]), zap.String("blockedID", ids[1]), zap.Error(err))
+			return false, errors.New("failed to check block:" + err.Error())
+		}
+		if blocked {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// claimPair reserves the encounter of the two players, false while the pair is in cooldown
+func (d *DefaultService) claimPair(a, b string, at time.Time) bool {
+	key := pairKey(a, b)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.recent[key]; ok && at.Sub(last) < d.config.Cooldown {
+		return false
+	}
+	d.recent[key] = at
+	return true
+}
+
+func pairKey(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// newPair builds the records of both players, each one sees where the other was exposed
+func newPair(self, other Position, at time.Time) ([]EncounterStoreModel, error) {
+	selfID, err := uuid.Parse(self.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	otherID, err := uuid.Parse(other.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	at = at.UTC()
+	return []EncounterStoreModel{
+		{
+			ID:          uuid.New(),
+			ClientID:    selfID,
+			OtherID:     otherID,
+			OtherHandle: other.Handle,
+			Point:       toPoint(other.Exposed),
+			CreatedAt:   at,
+		},
+		{
+			ID:          uuid.New(),
+			ClientID:    otherID,
+			OtherID:     selfID,
+			OtherHandle: self.Handle,
+			Point:       toPoint(self.Exposed),
+			CreatedAt:   at,
+		},
+	}, nil
+}
+
+func toPoint(g locations.GeoPoint) locations.Point {
+	return locations.Point{Point: orb.Point{g.Longitude, g.Latitude}}
+}
+
+func (d *DefaultService) Evict(ctx context.Context) error {
+	now := time.Now()
+	evicted := d.index.Evict(now)
+	d.mu.Lock()
+	for key, at := range d.recent {
+		if now.Sub(at) >= d.config.Cooldown {
+			delete(d.recent, key)
+		}
+	}
+	d.mu.Unlock()
+	if evicted > 0 {
+		d.logger.Debug("Evict: dropped stale positions", zap.Int("count", evicted))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package encounters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+type blockFunc func(blockerID, blockedID string) (bool, error)
+
+func (f blockFunc) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	return f(blockerID, blockedID)
+}
+
+var noBlocks = blockFunc(func(blockerID, blockedID string) (bool, error) {
+	return false, nil
+})
+
+func update(clientID, name string, lon, lat float64, at time.Time, exposed bool) players.LocationUpdate {
+	u := players.LocationUpdate{
+		ClientID: clientID,
+		Handle:   name,
+		GeoPoint: locations.GeoPoint{Longitude: lon, Latitude: lat},
+		At:       at,
+	}
+	if exposed {
+		u.Exposed = &locations.Location{GeoPoint: u.GeoPoint}
+		u.Mode = players.PrivacyExact
+	}
+	return u
+}
+
+func TestIndex_Nearby(t *testing.T) {
+	now := time.Now()
+	i := NewIndex(50, time.Minute)
+	i.Put(Position{ClientID: "a", Point: orb.Point{18.0700, 59.3300}, At: now})
+	// about 33m north
+	i.Put(Position{ClientID: "b", Point: orb.Point{18.0700, 59.3303}, At: now})
+	// about 28m east, in the next cell
+	i.Put(Position{ClientID: "c", Point: orb.Point{18.0705, 59.3300}, At: now})
+	// about 111m north
+	i.Put(Position{ClientID: "d", Point: orb.Point{18.0700, 59.3310}, At: now})
+	// too old
+	i.Put(Position{ClientID: "e", Point: orb.Point{18.0700, 59.3300}, At: now.Add(-time.Minute * 2)})
+
+	tests := []struct {
+		name   string
+		point  orb.Point
+		meters float64
+		want   []string
+	}{
+		{name: "close", point: orb.Point{18.07, 59.33}, meters: 50, want: []string{"a", "b", "c"}},
+		{name: "wider", point: orb.Point{18.07, 59.33}, meters: 150, want: []string{"a", "b", "c", "d"}},
+		{name: "nobody", point: orb.Point{19, 60}, meters: 50, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, p := range i.Nearby(tt.point, tt.meters, now) {
+				got = append(got, p.ClientID)
+			}
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+
+	// moving replaces the previous position
+	i.Put(Position{ClientID: "a", Point: orb.Point{19, 60}, At: now})
+	assert.Len(t, i.Nearby(orb.Point{19, 60}, 50, now), 1)
+	assert.Equal(t, 5, i.Len())
+
+	assert.Equal(t, 1, i.Evict(now))
+	assert.Equal(t, 4, i.Len())
+	i.Remove("b")
+	assert.Equal(t, 3, i.Len())
+}
+
+func TestDefaultService_OnLocationUpdate(t *testing.T) {
+	playerA, playerB, playerC, playerD := uuid.New().String(), uuid.New().String(), uuid.New().String(), uuid.New().String()
+	blocks := blockFunc(func(blockerID, blockedID string) (bool, error) {
+		return blockerID == playerD && blockedID == playerA, nil
+	})
+	store := NewMemStore()
+	d := NewDefaultService(zap.NewNop(), store, blocks, DefaultConfig(), time.Second)
+	now := time.Now()
+
+	// C hides and D blocked A, neither is met
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerC, "c", 18.0701, 59.33, now, false)))
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerD, "d", 18.0701, 59.33, now, true)))
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerB, "b", 18.07, 59.3302, now, true)))
+	// B and D meet
+	got, err := d.List(context.TODO(), playerD)
+	assert.Nil(t, err)
+	assert.Len(t, got, 1)
+
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerA, "a", 18.07, 59.33, now, true)))
+	got, err = d.List(context.TODO(), playerA)
+	assert.Nil(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, playerB, got[0].PlayerID)
+		assert.Equal(t, "b", got[0].Handle)
+		assert.Equal(t, locations.GeoPoint{Longitude: 18.07, Latitude: 59.3302}, got[0].GeoPoint)
+	}
+	got, err = d.List(context.TODO(), playerB)
+	assert.Nil(t, err)
+	assert.Len(t, got, 2)
+
+	// same pair in cooldown
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerA, "a", 18.07, 59.33, now.Add(time.Minute), true)))
+	got, err = d.List(context.TODO(), playerA)
+	assert.Nil(t, err)
+	assert.Len(t, got, 1)
+
+	// going hidden removes the player from the index
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerB, "b", 18.07, 59.3302, now, false)))
+	assert.Equal(t, 2, d.index.Len())
+}
+
+func TestDefaultService_OnLocationUpdate_Blurred(t *testing.T) {
+	store := NewMemStore()
+	d := NewDefaultService(zap.NewNop(), store, noBlocks, DefaultConfig(), time.Second)
+	playerA, playerB := uuid.New().String(), uuid.New().String()
+	now := time.Now()
+
+	// B shares its position on city level only, meeting it at 50m would reveal much more
+	city := update(playerB, "b", 18.07, 59.3302, now, true)
+	city.Mode = players.PrivacyCity
+	city.Exposed = &locations.Location{GeoPoint: locations.GeoPoint{Longitude: 18.05, Latitude: 59.35}}
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), city))
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerA, "a", 18.07, 59.33, now, true)))
+
+	for _, id := range []string{playerA, playerB} {
+		got, err := d.List(context.TODO(), id)
+		assert.Nil(t, err)
+		assert.Empty(t, got)
+	}
+	assert.Equal(t, 1, d.index.Len())
+}
+
+func TestDefaultService_Evict(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMockStore(), noBlocks, DefaultConfig(), time.Second)
+	playerA, playerB := uuid.New().String(), uuid.New().String()
+	past := time.Now().Add(-time.Hour * 2)
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerA, "a", 18.07, 59.33, past, true)))
+	assert.Nil(t, d.OnLocationUpdate(context.TODO(), update(playerB, "b", 18.07, 59.33, past, true)))
+	assert.Len(t, d.recent, 1)
+
+	assert.Nil(t, d.Evict(context.TODO()))
+	assert.Equal(t, 0, d.index.Len())
+	assert.Empty(t, d.recent)
+}
@@ -0,0 +1,11 @@
+package encounters
+
+import (
+	"context"
+)
+
+type Store interface {
+	// Create stores the encounter records of both players
+	Create(ctx context.Context, models []EncounterStoreModel) error
+	ListByClient(ctx context.Context, clientID string, limit int) ([]EncounterStoreModel, error)
+}
@@ -0,0 +1,85 @@
+package factions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"geogame/internal/locations"
+)
+
+var _ Store = (*MemStore)(nil)
+
+// DefaultFactions are the teams seeded by the migrations
+var DefaultFactions = []FactionStoreModel{
+	{ID: "aurora", Name: "Aurora", Color: "#3d7eff"},
+	{ID: "ember", Name: "Ember", Color: "#ff5a36"},
+	{ID: "verdant", Name: "Verdant", Color: "#2fbf71"},
+}
+
+type MemStore struct {
+	mu           sync.Mutex
+	factions     []FactionStoreModel
+	memberMap    map[string]*MemberStoreModel
+	territoryMap map[string]*TerritoryStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		factions:     DefaultFactions,
+		memberMap:    make(map[string]*MemberStoreModel),
+		territoryMap: make(map[string]*TerritoryStoreModel),
+	}
+}
+
+func (m *MemStore) ListFactions(ctx context.Context) ([]FactionStoreModel, error) {
+	return append([]FactionStoreModel(nil), m.factions...), nil
+}
+
+func (m *MemStore) GetMember(ctx context.Context, clientID string) (*MemberStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	member, ok := m.memberMap[clientID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *member
+	return &c, nil
+}
+
+func (m *MemStore) SaveMember(ctx context.Context, model *MemberStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *model
+	m.memberMap[model.ClientID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) UpdateTerritory(ctx context.Context, locationID string, point locations.Point, fn TerritoryFunc) (*TerritoryStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.territoryMap[locationID]
+	if !ok {
+		t = &TerritoryStoreModel{LocationID: locationID, Point: point, UpdatedAt: time.Now().UTC()}
+	}
+	// fn works on a copy so a failing update leaves the territory untouched
+	next := *t
+	if err := fn(&next); err != nil {
+		return nil, err
+	}
+	m.territoryMap[locationID] = &next
+	res := next
+	return &res, nil
+}
+
+func (m *MemStore) ListTerritories(ctx context.Context, bbox locations.BBox) ([]TerritoryStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]TerritoryStoreModel, 0)
+	for _, t := range m.territoryMap {
+		if bbox.Contains(t.Point) {
+			res = append(res, *t)
+		}
+	}
+	return res, nil
+}
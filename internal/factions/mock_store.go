@@ -0,0 +1,58 @@
+package factions
+
+import (
+	"context"
+
+	"geogame/internal/locations"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	ListFactionsFunc    func() ([]FactionStoreModel, error)
+	GetMemberFunc       func(clientID string) (*MemberStoreModel, error)
+	SaveMemberFunc      func(model *MemberStoreModel) error
+	UpdateTerritoryFunc func(locationID string, point locations.Point, fn TerritoryFunc) (*TerritoryStoreModel, error)
+	ListTerritoriesFunc func(bbox locations.BBox) ([]TerritoryStoreModel, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		ListFactionsFunc: func() ([]FactionStoreModel, error) {
+			return DefaultFactions, nil
+		},
+		GetMemberFunc: func(clientID string) (*MemberStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		SaveMemberFunc: func(model *MemberStoreModel) error {
+			return nil
+		},
+		UpdateTerritoryFunc: func(locationID string, point locations.Point, fn TerritoryFunc) (*TerritoryStoreModel, error) {
+			t := &TerritoryStoreModel{LocationID: locationID, Point: point}
+			return t, fn(t)
+		},
+		ListTerritoriesFunc: func(bbox locations.BBox) ([]TerritoryStoreModel, error) {
+			return []TerritoryStoreModel{}, nil
+		},
+	}
+}
+
+func (m *MockStore) ListFactions(ctx context.Context) ([]FactionStoreModel, error) {
+	return m.ListFactionsFunc()
+}
+
+func (m *MockStore) GetMember(ctx context.Context, clientID string) (*MemberStoreModel, error) {
+	return m.GetMemberFunc(clientID)
+}
+
+func (m *MockStore) SaveMember(ctx context.Context, model *MemberStoreModel) error {
+	return m.SaveMemberFunc(model)
+}
+
+func (m *MockStore) UpdateTerritory(ctx context.Context, locationID string, point locations.Point, fn TerritoryFunc) (*TerritoryStoreModel, error) {
+	return m.UpdateTerritoryFunc(locationID, point, fn)
+}
+
+func (m *MockStore) ListTerritories(ctx context.Context, bbox locations.BBox) ([]TerritoryStoreModel, error) {
+	return m.ListTerritoriesFunc(bbox)
+}
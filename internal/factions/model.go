@@ -0,0 +1,57 @@
+package factions
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"geogame/internal/locations"
+)
+
+type FactionStoreModel struct {
+	ID    string `db:"id" json:"id"`
+	Name  string `db:"name" json:"name"`
+	Color string `db:"color" json:"color"`
+}
+
+type MemberStoreModel struct {
+	ClientID  uuid.UUID `db:"client_id"`
+	FactionID string    `db:"faction_id"`
+	JoinedAt  time.Time `db:"joined_at"`
+}
+
+// TerritoryStoreModel is the control state of a location, the strength is the value at UpdatedAt
+type TerritoryStoreModel struct {
+	LocationID string          `db:"loc_id"`
+	FactionID  sql.NullString  `db:"faction_id"`
+	Strength   float64         `db:"strength"`
+	Point      locations.Point `db:"point"`
+	UpdatedAt  time.Time       `db:"updated_at"`
+	CapturedAt sql.NullTime    `db:"captured_at"`
+}
+
+type JoinPayload struct {
+	FactionID string `json:"factionId"`
+}
+
+type Membership struct {
+	FactionID string    `json:"factionId"`
+	JoinedAt  time.Time `json:"joinedAt"`
+}
+
+// Territory is the control state of a location with the decay applied
+type Territory struct {
+	LocationID string             `json:"locationId"`
+	FactionID  string             `json:"factionId,omitempty"`
+	Strength   float64            `json:"strength"`
+	GeoPoint   locations.GeoPoint `json:"geoPoint"`
+	CapturedAt *time.Time         `json:"capturedAt,omitempty"`
+}
+
+type Standing struct {
+	FactionID string  `json:"factionId"`
+	Name      string  `json:"name"`
+	Locations int     `json:"locations"`
+	Strength  float64 `json:"strength"`
+}
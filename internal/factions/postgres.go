@@ -0,0 +1,131 @@
+package factions
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	factionsAllCols    = "id, name, color"
+	factionsTable      = "factions"
+	membersAllCols     = "client_id, faction_id, joined_at"
+	membersTable       = "faction_members"
+	territoriesAllCols = "loc_id, faction_id, strength, ST_AsBinary(point) AS point, updated_at, captured_at"
+	territoriesTable   = "territories"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.factions.store"),
+	}
+}
+
+func (p Postgres) ListFactions(ctx context.Context) ([]FactionStoreModel, error) {
+	stmt := "SELECT " + factionsAllCols + " FROM " + factionsTable + " ORDER BY id"
+	res := make([]FactionStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("ListFactions: failed to list factions from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) GetMember(ctx context.Context, clientID string) (*MemberStoreModel, error) {
+	stmt := "SELECT " + membersAllCols + " FROM " + membersTable + " WHERE client_id=$1"
+	var m MemberStoreModel
+	if err := p.db.GetContext(ctx, &m, stmt, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetMember: failed to get member from db", zap.Error(err))
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (p Postgres) SaveMember(ctx context.Context, model *MemberStoreModel) error {
+	stmt := `INSERT INTO faction_members (
+	client_id,
+	faction_id,
+	joined_at
+	) VALUES (
+	:client_id,
+	:faction_id,
+	:joined_at
+	) ON CONFLICT (client_id) DO UPDATE SET
+	faction_id=EXCLUDED.faction_id,
+	joined_at=EXCLUDED.joined_at`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("SaveMember: failed to save member to db", zap.Error(err))
+	}
+	return err
+}
+
+// UpdateTerritory locks the territory row with FOR UPDATE, concurrent captures of a location wait for each other
+func (p Postgres) UpdateTerritory(ctx context.Context, locationID string, point locations.Point, fn TerritoryFunc) (*TerritoryStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("UpdateTerritory: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	insert := `INSERT INTO territories (loc_id, strength, point, updated_at)
+	VALUES ($1, 0, $2, now())
+	ON CONFLICT (loc_id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, insert, locationID, point); err != nil {
+		p.logger.Error("UpdateTerritory: failed to insert territory", zap.Error(err))
+		return nil, err
+	}
+	var t TerritoryStoreModel
+	stmt := "SELECT " + territoriesAllCols + " FROM " + territoriesTable + " WHERE loc_id=$1 FOR UPDATE"
+	if err := tx.GetContext(ctx, &t, stmt, locationID); err != nil {
+		p.logger.Error("UpdateTerritory: failed to lock territory", zap.Error(err))
+		return nil, err
+	}
+	if err := fn(&t); err != nil {
+		return nil, err
+	}
+	update := `UPDATE territories SET
+	faction_id=$1,
+	strength=$2,
+	updated_at=$3,
+	captured_at=$4
+	WHERE loc_id=$5
+	`
+	if _, err := tx.ExecContext(ctx, update, t.FactionID, t.Strength, t.UpdatedAt, t.CapturedAt, locationID); err != nil {
+		p.logger.Error("UpdateTerritory: failed to update territory", zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (p Postgres) ListTerritories(ctx context.Context, bbox locations.BBox) ([]TerritoryStoreModel, error) {
+	stmt := "SELECT " + territoriesAllCols + " FROM " + territoriesTable + `
+	WHERE ST_Intersects(point, ST_MakeEnvelope($1, $2, $3, $4, 4326)::geography)`
+	res := make([]TerritoryStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, bbox.MinLon, bbox.MinLat, bbox.MaxLon, bbox.MaxLat); err != nil {
+		p.logger.Error("ListTerritories: failed to list territories from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,272 @@
+package factions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/locations"
+)
+
+var (
+	ErrUnknownFaction = errors.New("unknown faction")
+	ErrNotMember      = errors.New("player has not joined a faction")
+	ErrSwitchCooldown = errors.New("faction was changed recently, try again later")
+)
+
+type Service interface {
+	ListFactions(ctx context.Context) ([]FactionStoreModel, error)
+	Join(ctx context.Context, payload JoinPayload, clientID string) error
+	Membership(ctx context.Context, clientID string) (*Membership, error)
+	ControlMap(ctx context.Context, bbox locations.BBox) ([]Territory, error)
+	Standings(ctx context.Context, bbox locations.BBox) ([]Standing, error)
+	checkins.Listener
+}
+
+// Config is the territory control policy
+type Config struct {
+	// CaptureGain is the strength a check-in adds for the own faction or removes from another one
+	CaptureGain float64 `env:"FACTION_CAPTURE_GAIN" envDefault:"10"`
+	MaxStrength float64 `env:"FACTION_MAX_STRENGTH" envDefault:"100"`
+	// DecayPerHour is the strength lost every hour without a check-in of the owning faction
+	DecayPerHour float64 `env:"FACTION_DECAY_PER_HOUR" envDefault:"1"`
+	// SwitchCooldown is the minimum time in a faction before joining another one
+	SwitchCooldown time.Duration `env:"FACTION_SWITCH_COOLDOWN" envDefault:"168h"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		CaptureGain:    10,
+		MaxStrength:    100,
+		DecayPerHour:   1,
+		SwitchCooldown: time.Hour * 24 * 7,
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	config    Config
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, config Config, dbTimeOut time.Duration) *DefaultService {
+	return &DefaultService{
+		logger:    logger,
+		store:     store,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+}
+
+func (d *DefaultService) ListFactions(ctx context.Context) ([]FactionStoreModel, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	factions, err := d.store.ListFactions(dbCtx)
+	if err != nil {
+		d.logger.Error("ListFactions: failed to list factions", zap.Error(err))
+		return nil, errors.New("failed to list factions:" + err.Error())
+	}
+	return factions, nil
+}
+
+func (d *DefaultService) Join(ctx context.Context, payload JoinPayload, clientID string) error {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		d.logger.Error("Join: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return err
+	}
+	factions, err := d.ListFactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsFaction(factions, payload.FactionID) {
+		return ErrUnknownFaction
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	member, err := d.store.GetMember(dbCtx, clientID)
+	if err != nil && err != ErrNotFound {
+		d.logger.Error("Join: failed to get member", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to join faction:" + err.Error())
+	}
+	if member != nil {
+		if member.FactionID == payload.FactionID {
+			return nil
+		}
+		if time.Since(member.JoinedAt) < d.config.SwitchCooldown {
+			return ErrSwitchCooldown
+		}
+	}
+	model := MemberStoreModel{
+		ClientID:  id,
+		FactionID: payload.FactionID,
+		JoinedAt:  time.Now().UTC(),
+	}
+	if err := d.store.SaveMember(dbCtx, &model); err != nil {
+		d.logger.Error("Join: failed to save member", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to join faction:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) Membership(ctx context.Context, clientID string) (*Membership, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	member, err := d.store.GetMember(dbCtx, clientID)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrNotMember
+		}
+		d.logger.Error("Membership: failed to get member", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to get membership:" + err.Error())
+	}
+	return &Membership{FactionID: member.FactionID, JoinedAt: member.JoinedAt}, nil
+}
+
+// OnCheckIn strengthens the location for the faction of the player or weakens the owning faction
+func (d *DefaultService) OnCheckIn(ctx context.Context, checkIn checkins.CheckIn) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	member, err := d.store.GetMember(dbCtx, checkIn.ClientID)
+	if err != nil {
+		if err == ErrNotFound {
+			// players without a faction do not take part in the territory control
+			return nil
+		}
+		return err
+	}
+	point := locations.NewPoint(checkIn.GeoPoint.Longitude, checkIn.GeoPoint.Latitude)
+	_, err = d.store.UpdateTerritory(dbCtx, checkIn.LocationID, point, func(t *TerritoryStoreModel) error {
+		d.capture(t, member.FactionID, checkIn.CreatedAt)
+		return nil
+	})
+	if err != nil {
+		d.logger.Error("OnCheckIn: failed to update territory", zap.String("locationID", checkIn.LocationID), zap.Error(err))
+	}
+	return err
+}
+
+// capture applies a check-in of the faction to the locked territory
+func (d *DefaultService) capture(t *TerritoryStoreModel, factionID string, now time.Time) {
+	strength := d.decayed(t, now)
+	switch {
+	case strength == 0 || t.FactionID.String == factionID:
+		if t.FactionID.String != factionID {
+			t.CapturedAt = sql.NullTime{Time: now, Valid: true}
+		}
+		t.FactionID = sql.NullString{String: factionID, Valid: true}
+		strength = math.Min(strength+d.config.CaptureGain, d.config.MaxStrength)
+	default:
+		strength -= d.config.CaptureGain
+		if strength < 0 {
+			// the attack broke the control, the rest goes to the attacking faction
+			t.FactionID = sql.NullString{String: factionID, Valid: true}
+			t.CapturedAt = sql.NullTime{Time: now, Valid: true}
+			strength = -strength
+		}
+	}
+	if strength == 0 {
+		t.FactionID = sql.NullString{}
+		t.CapturedAt = sql.NullTime{}
+	}
+	t.Strength = strength
+	t.UpdatedAt = now
+}
+
+// decayed returns the strength of the territory at the given time
+func (d *DefaultService) decayed(t *TerritoryStoreModel, now time.Time) float64 {
+	if !t.FactionID.Valid {
+		return 0
+	}
+	hours := now.Sub(t.UpdatedAt).Hours()
+	if hours < 0 {
+		hours = 0
+	}
+	return math.Max(t.Strength-hours*d.config.DecayPerHour, 0)
+}
+
+// ControlMap returns the controlled locations inside the bbox, decayed territories are neutral
+func (d *DefaultService) ControlMap(ctx context.Context, bbox locations.BBox) ([]Territory, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListTerritories(dbCtx, bbox)
+	if err != nil {
+		d.logger.Error("ControlMap: failed to list territories", zap.Error(err))
+		return nil, errors.New("failed to get control map:" + err.Error())
+	}
+	now := time.Now()
+	res := make([]Territory, 0, len(models))
+	for i := range models {
+		m := &models[i]
+		t := Territory{
+			LocationID: m.LocationID,
+			Strength:   d.decayed(m, now),
+			GeoPoint: locations.GeoPoint{
+				Longitude: m.Point.Lon(),
+				Latitude:  m.Point.Lat(),
+			},
+		}
+		if t.Strength > 0 {
+			t.FactionID = m.FactionID.String
+			if m.CapturedAt.Valid {
+				capturedAt := m.CapturedAt.Time
+				t.CapturedAt = &capturedAt
+			}
+		}
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].LocationID < res[j].LocationID
+	})
+	return res, nil
+}
+
+// Standings sums the controlled locations and strength of every faction inside the bbox
+func (d *DefaultService) Standings(ctx context.Context, bbox locations.BBox) ([]Standing, error) {
+	factions, err := d.ListFactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	territories, err := d.ControlMap(ctx, bbox)
+	if err != nil {
+		return nil, err
+	}
+	byFaction := make(map[string]*Standing, len(factions))
+	res := make([]Standing, len(factions))
+	for i, f := range factions {
+		res[i] = Standing{FactionID: f.ID, Name: f.Name}
+		byFaction[f.ID] = &res[i]
+	}
+	for _, t := range territories {
+		if s, ok := byFaction[t.FactionID]; ok {
+			s.Locations++
+			s.Strength += t.Strength
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Locations != res[j].Locations {
+			return res[i].Locations > res[j].Locations
+		}
+		return res[i].Strength > res[j].Strength
+	})
+	return res, nil
+}
+
+func containsFaction(factions []FactionStoreModel, id string) bool {
+	for _, f := range factions {
+		if f.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package factions
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/locations"
+)
+
+func TestDefaultService_Join(t *testing.T) {
+	store := NewMemStore()
+	d := NewDefaultService(zap.NewNop(), store, DefaultConfig(), time.Second)
+	clientID := uuid.New().String()
+
+	assert.Equal(t, ErrUnknownFaction, d.Join(context.TODO(), JoinPayload{FactionID: "unknown"}, clientID))
+	_, err := d.Membership(context.TODO(), clientID)
+	assert.Equal(t, ErrNotMember, err)
+
+	assert.Nil(t, d.Join(context.TODO(), JoinPayload{FactionID: "aurora"}, clientID))
+	assert.Equal(t, ErrSwitchCooldown, d.Join(context.TODO(), JoinPayload{FactionID: "ember"}, clientID))
+	m, err := d.Membership(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Equal(t, "aurora", m.FactionID)
+}
+
+func TestDefaultService_Capture(t *testing.T) {
+	config := DefaultConfig()
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), config, time.Second)
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		territory    TerritoryStoreModel
+		faction      string
+		wantFaction  string
+		wantStrength float64
+	}{
+		{
+			name:         "neutral is captured",
+			territory:    TerritoryStoreModel{},
+			faction:      "aurora",
+			wantFaction:  "aurora",
+			wantStrength: 10,
+		},
+		{
+			name:         "defended up to the maximum",
+			territory:    territory("aurora", 95, now),
+			faction:      "aurora",
+			wantFaction:  "aurora",
+			wantStrength: 100,
+		},
+		{
+			name:         "attack weakens the owner",
+			territory:    territory("aurora", 25, now),
+			faction:      "ember",
+			wantFaction:  "aurora",
+			wantStrength: 15,
+		},
+		{
+			name:         "attack breaks the control",
+			territory:    territory("aurora", 4, now),
+			faction:      "ember",
+			wantFaction:  "ember",
+			wantStrength: 6,
+		},
+		{
+			name:         "decayed control is neutral",
+			territory:    territory("aurora", 20, now.Add(-time.Hour*30)),
+			faction:      "ember",
+			wantFaction:  "ember",
+			wantStrength: 10,
+		},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tt.territory
+			d.capture(&tr, tt.faction, now)
+			assert.Equal(t, tt.wantFaction, tr.FactionID.String)
+			assert.Equal(t, tt.wantStrength, tr.Strength)
+		})
+	}
+}
+
+func TestDefaultService_ConcurrentCheckIns(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxStrength = 1000
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), config, time.Second)
+	var players []string
+	for i := 0; i < 40; i++ {
+		id := uuid.New().String()
+		faction := "aurora"
+		if i%4 == 0 {
+			faction = "ember"
+		}
+		assert.Nil(t, d.Join(context.TODO(), JoinPayload{FactionID: faction}, id))
+		players = append(players, id)
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range players {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			assert.Nil(t, d.OnCheckIn(context.TODO(), checkins.CheckIn{
+				ClientID:   id,
+				LocationID: "1",
+				GeoPoint:   locations.GeoPoint{Longitude: 18.06, Latitude: 59.33},
+				CreatedAt:  time.Now(),
+			}))
+		}(id)
+	}
+	wg.Wait()
+
+	// without the cap the control is a signed counter, every check-in applied exactly once leaves 30*10 - 10*10
+	bbox := locations.BBox{MinLon: 18, MinLat: 59, MaxLon: 19, MaxLat: 60}
+	standings, err := d.Standings(context.TODO(), bbox)
+	assert.Nil(t, err)
+	assert.Equal(t, "aurora", standings[0].FactionID)
+	assert.Equal(t, 1, standings[0].Locations)
+	assert.InDelta(t, 200, standings[0].Strength, 0.01)
+}
+
+func territory(faction string, strength float64, updatedAt time.Time) TerritoryStoreModel {
+	t := TerritoryStoreModel{Strength: strength, UpdatedAt: updatedAt}
+	t.FactionID.String, t.FactionID.Valid = faction, true
+	return t
+}
@@ -0,0 +1,24 @@
+package factions
+
+import (
+	"context"
+	"errors"
+
+	"geogame/internal/locations"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// TerritoryFunc changes the territory while it is locked
+type TerritoryFunc func(t *TerritoryStoreModel) error
+
+type Store interface {
+	ListFactions(ctx context.Context) ([]FactionStoreModel, error)
+	GetMember(ctx context.Context, clientID string) (*MemberStoreModel, error)
+	SaveMember(ctx context.Context, model *MemberStoreModel) error
+	// UpdateTerritory runs fn on the locked territory of the location, a neutral territory is created when missing.
+	// Concurrent updates of the same location are serialized.
+	UpdateTerritory(ctx context.Context, locationID string, point locations.Point, fn TerritoryFunc) (*TerritoryStoreModel, error)
+	ListTerritories(ctx context.Context, bbox locations.BBox) ([]TerritoryStoreModel, error)
+}
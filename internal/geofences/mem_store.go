@@ -0,0 +1,140 @@
+package geofences
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu     sync.RWMutex
+	fences map[string]FenceStoreModel
+	// states by client and fence id
+	states map[string]map[string]StateStoreModel
+	events []EventStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		fences: make(map[string]FenceStoreModel),
+		states: make(map[string]map[string]StateStoreModel),
+	}
+}
+
+func (m *MemStore) CreateFence(ctx context.Context, model *FenceStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fences[model.ID.String()] = *model
+	return nil
+}
+
+func (m *MemStore) GetFence(ctx context.Context, id string) (*FenceStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.fences[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &f, nil
+}
+
+func (m *MemStore) ListFences(ctx context.Context) ([]FenceStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]FenceStoreModel, 0, len(m.fences))
+	for _, f := range m.fences {
+		res = append(res, f)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+func (m *MemStore) DeleteFence(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.fences[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.fences, id)
+	for _, states := range m.states {
+		delete(states, id)
+	}
+	events := m.events[:0]
+	for _, e := range m.events {
+		if e.FenceID.String() != id {
+			events = append(events, e)
+		}
+	}
+	m.events = events
+	return nil
+}
+
+func (m *MemStore) ListInBounds(ctx context.Context, bound orb.Bound) ([]FenceStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]FenceStoreModel, 0)
+	for _, f := range m.fences {
+		if f.MinLon <= bound.Max.Lon() && f.MaxLon >= bound.Min.Lon() &&
+			f.MinLat <= bound.Max.Lat() && f.MaxLat >= bound.Min.Lat() {
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) ListInside(ctx context.Context, clientID string) ([]StateStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]StateStoreModel, 0)
+	for _, s := range m.states[clientID] {
+		if s.Inside {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) UpdateState(ctx context.Context, clientID, fenceID string, fn StateFunc) (*StateStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.states[clientID]; !ok {
+		m.states[clientID] = make(map[string]StateStoreModel)
+	}
+	s, ok := m.states[clientID][fenceID]
+	if !ok {
+		s.ClientID, _ = uuid.Parse(clientID)
+		s.FenceID, _ = uuid.Parse(fenceID)
+	}
+	// fn works on a copy so a failing update leaves the state untouched
+	if err := fn(&s); err != nil {
+		return nil, err
+	}
+	m.states[clientID][fenceID] = s
+	return &s, nil
+}
+
+func (m *MemStore) CreateEvents(ctx context.Context, models []EventStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, models...)
+	return nil
+}
+
+func (m *MemStore) ListEvents(ctx context.Context, clientID string, limit int) ([]EventStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]EventStoreModel, 0)
+	for i := len(m.events) - 1; i >= 0 && len(res) < limit; i-- {
+		if m.events[i].ClientID.String() == clientID {
+			res = append(res, m.events[i])
+		}
+	}
+	return res, nil
+}
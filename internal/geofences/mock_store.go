@@ -0,0 +1,93 @@
+package geofences
+
+import (
+	"context"
+
+	"github.com/paulmach/orb"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateFenceFunc  func(model *FenceStoreModel) error
+	GetFenceFunc     func(id string) (*FenceStoreModel, error)
+	ListFencesFunc   func() ([]FenceStoreModel, error)
+	DeleteFenceFunc  func(id string) error
+	ListInBoundsFunc func(bound orb.Bound) ([]FenceStoreModel, error)
+	ListInsideFunc   func(clientID string) ([]StateStoreModel, error)
+	UpdateStateFunc  func(clientID, fenceID string, fn StateFunc) (*StateStoreModel, error)
+	CreateEventsFunc func(models []EventStoreModel) error
+	ListEventsFunc   func(clientID string, limit int) ([]EventStoreModel, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateFenceFunc: func(model *FenceStoreModel) error {
+			return nil
+		},
+		GetFenceFunc: func(id string) (*FenceStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListFencesFunc: func() ([]FenceStoreModel, error) {
+			return []FenceStoreModel{}, nil
+		},
+		DeleteFenceFunc: func(id string) error {
+			return ErrNotFound
+		},
+		ListInBoundsFunc: func(bound orb.Bound) ([]FenceStoreModel, error) {
+			return []FenceStoreModel{}, nil
+		},
+		ListInsideFunc: func(clientID string) ([]StateStoreModel, error) {
+			return []StateStoreModel{}, nil
+		},
+		UpdateStateFunc: func(clientID, fenceID string, fn StateFunc) (*StateStoreModel, error) {
+			s := StateStoreModel{}
+			if err := fn(&s); err != nil {
+				return nil, err
+			}
+			return &s, nil
+		},
+		CreateEventsFunc: func(models []EventStoreModel) error {
+			return nil
+		},
+		ListEventsFunc: func(clientID string, limit int) ([]EventStoreModel, error) {
+			return []EventStoreModel{}, nil
+		},
+	}
+}
+
+func (m *MockStore) CreateFence(ctx context.Context, model *FenceStoreModel) error {
+	return m.CreateFenceFunc(model)
+}
+
+func (m *MockStore) GetFence(ctx context.Context, id string) (*FenceStoreModel, error) {
+	return m.GetFenceFunc(id)
+}
+
+func (m *MockStore) ListFences(ctx context.Context) ([]FenceStoreModel, error) {
+	return m.ListFencesFunc()
+}
+
+func (m *MockStore) DeleteFence(ctx context.Context, id string) error {
+	return m.DeleteFenceFunc(id)
+}
+
+func (m *MockStore) ListInBounds(ctx context.Context, bound orb.Bound) ([]FenceStoreModel, error) {
+	return m.ListInBoundsFunc(bound)
+}
+
+func (m *MockStore) ListInside(ctx context.Context, clientID string) ([]StateStoreModel, error) {
+	return m.ListInsideFunc(clientID)
+}
+
+func (m *MockStore) UpdateState(ctx context.Context, clientID, fenceID string, fn StateFunc) (*StateStoreModel, error) {
+	return m.UpdateStateFunc(clientID, fenceID, fn)
+}
+
+func (m *MockStore) CreateEvents(ctx context.Context, models []EventStoreModel) error {
+	return m.CreateEventsFunc(models)
+}
+
+func (m *MockStore) ListEvents(ctx context.Context, clientID string, limit int) ([]EventStoreModel, error) {
+	return m.ListEventsFunc(clientID, limit)
+}
@@ -0,0 +1,147 @@
+package geofences
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"geogame/internal/locations"
+)
+
+type EventType string
+
+const (
+	EventEnter EventType = "enter"
+	EventExit  EventType = "exit"
+	// EventDwell fires once per visit when the player stayed inside for the dwell time
+	EventDwell EventType = "dwell"
+)
+
+type Shape string
+
+const (
+	ShapeCircle  Shape = "circle"
+	ShapePolygon Shape = "polygon"
+)
+
+// Geometry is a circle around a location or a polygon ring of [longitude, latitude] pairs
+type Geometry struct {
+	Shape      Shape  `json:"shape"`
+	LocationID string `json:"locationId,omitempty"`
+	// Center is copied from the location when the fence is created
+	Center [2]float64   `json:"center,omitempty"`
+	Radius float64      `json:"radius,omitempty"`
+	Ring   [][2]float64 `json:"ring,omitempty"`
+}
+
+// Value enables serialization to SQL
+func (g Geometry) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+// Scan enables deserialization from SQL
+func (g *Geometry) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, g)
+	case string:
+		return json.Unmarshal([]byte(v), g)
+	}
+	return fmt.Errorf("unsupported geometry type %T", src)
+}
+
+type FenceStoreModel struct {
+	ID           uuid.UUID      `db:"id"`
+	Name         string         `db:"name"`
+	Geometry     Geometry       `db:"geometry"`
+	Triggers     pq.StringArray `db:"triggers"`
+	DwellSeconds int64          `db:"dwell_seconds"`
+	// the bounding box preselects the fences around a position
+	MinLon    float64   `db:"min_lon"`
+	MinLat    float64   `db:"min_lat"`
+	MaxLon    float64   `db:"max_lon"`
+	MaxLat    float64   `db:"max_lat"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// StateStoreModel tracks whether the player is inside the fence
+type StateStoreModel struct {
+	ClientID  uuid.UUID    `db:"client_id"`
+	FenceID   uuid.UUID    `db:"fence_id"`
+	Inside    bool         `db:"inside"`
+	EnteredAt sql.NullTime `db:"entered_at"`
+	// Dwelled is set once the dwell event of the current visit fired
+	Dwelled   bool      `db:"dwelled"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type EventStoreModel struct {
+	ID        uuid.UUID       `db:"id"`
+	ClientID  uuid.UUID       `db:"client_id"`
+	FenceID   uuid.UUID       `db:"fence_id"`
+	FenceName string          `db:"fence_name"`
+	Type      string          `db:"type"`
+	Point     locations.Point `db:"point"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+type FencePayload struct {
+	Name string `json:"name"`
+	// LocationID and Radius in meters define a circle
+	LocationID string  `json:"locationId"`
+	Radius     float64 `json:"radius"`
+	// Polygon is a ring of [longitude, latitude] pairs
+	Polygon      [][2]float64 `json:"polygon"`
+	Triggers     []EventType  `json:"triggers"`
+	DwellSeconds int64        `json:"dwellSeconds"`
+}
+
+type Fence struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Geometry     Geometry  `json:"geometry"`
+	Triggers     []string  `json:"triggers"`
+	DwellSeconds int64     `json:"dwellSeconds"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type Event struct {
+	ID        string             `json:"id"`
+	ClientID  string             `json:"clientId"`
+	FenceID   string             `json:"fenceId"`
+	FenceName string             `json:"fenceName"`
+	Type      EventType          `json:"type"`
+	GeoPoint  locations.GeoPoint `json:"geoPoint"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+func toFence(m *FenceStoreModel) Fence {
+	return Fence{
+		ID:           m.ID.String(),
+		Name:         m.Name,
+		Geometry:     m.Geometry,
+		Triggers:     []string(m.Triggers),
+		DwellSeconds: m.DwellSeconds,
+		CreatedAt:    m.CreatedAt,
+	}
+}
+
+func toEvent(m *EventStoreModel) Event {
+	return Event{
+		ID:        m.ID.String(),
+		ClientID:  m.ClientID.String(),
+		FenceID:   m.FenceID.String(),
+		FenceName: m.FenceName,
+		Type:      EventType(m.Type),
+		GeoPoint: locations.GeoPoint{
+			Longitude: m.Point.Lon(),
+			Latitude:  m.Point.Lat(),
+		},
+		CreatedAt: m.CreatedAt,
+	}
+}
@@ -0,0 +1,212 @@
+package geofences
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/paulmach/orb"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	fencesAllCols = "id, name, geometry, triggers, dwell_seconds, min_lon, min_lat, max_lon, max_lat, created_at"
+	fencesTable   = "geofences"
+	statesAllCols = "client_id, fence_id, inside, entered_at, dwelled, updated_at"
+	statesTable   = "geofence_states"
+	eventsAllCols = "id, client_id, fence_id, fence_name, type, ST_AsBinary(point) AS point, created_at"
+	eventsTable   = "geofence_events"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.geofences.store"),
+	}
+}
+
+func (p Postgres) CreateFence(ctx context.Context, model *FenceStoreModel) error {
+	stmt := `INSERT INTO geofences (
+	id,
+	name,
+	geometry,
+	triggers,
+	dwell_seconds,
+	min_lon,
+	min_lat,
+	max_lon,
+	max_lat,
+	created_at
+	) VALUES (
+	:id,
+	:name,
+	:geometry,
+	:triggers,
+	:dwell_seconds,
+	:min_lon,
+	:min_lat,
+	:max_lon,
+	:max_lat,
+	:created_at
+	)`
+	if _, err := p.db.NamedExecContext(ctx, stmt, *model); err != nil {
+		p.logger.Error("CreateFence: failed to insert fence to db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) GetFence(ctx context.Context, id string) (*FenceStoreModel, error) {
+	stmt := "SELECT " + fencesAllCols + " FROM " + fencesTable + " WHERE id=$1"
+	var fence FenceStoreModel
+	if err := p.db.GetContext(ctx, &fence, stmt, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetFence: failed to get fence from db", zap.Error(err))
+		return nil, err
+	}
+	return &fence, nil
+}
+
+func (p Postgres) ListFences(ctx context.Context) ([]FenceStoreModel, error) {
+	stmt := "SELECT " + fencesAllCols + " FROM " + fencesTable + " ORDER BY created_at"
+	res := make([]FenceStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("ListFences: failed to list fences from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteFence relies on the cascade of the states and events
+func (p Postgres) DeleteFence(ctx context.Context, id string) error {
+	stmt := "DELETE FROM " + fencesTable + " WHERE id=$1"
+	res, err := p.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		p.logger.Error("DeleteFence: failed to delete fence from db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) ListInBounds(ctx context.Context, bound orb.Bound) ([]FenceStoreModel, error) {
+	stmt := "SELECT " + fencesAllCols + " FROM " + fencesTable + `
+	WHERE min_lon <= $3 AND max_lon >= $1 AND min_lat <= $4 AND max_lat >= $2`
+	res := make([]FenceStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, bound.Min.Lon(), bound.Min.Lat(), bound.Max.Lon(), bound.Max.Lat()); err != nil {
+		p.logger.Error("ListInBounds: failed to list fences from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) ListInside(ctx context.Context, clientID string) ([]StateStoreModel, error) {
+	stmt := "SELECT " + statesAllCols + " FROM " + statesTable + " WHERE client_id=$1 AND inside"
+	res := make([]StateStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID); err != nil {
+		p.logger.Error("ListInside: failed to list states from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateState creates the missing state row and locks it with FOR UPDATE
+func (p Postgres) UpdateState(ctx context.Context, clientID, fenceID string, fn StateFunc) (*StateStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("UpdateState: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	insert := `INSERT INTO geofence_states (client_id, fence_id, inside, dwelled, updated_at)
+	VALUES ($1, $2, false, false, now())
+	ON CONFLICT (client_id, fence_id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, insert, clientID, fenceID); err != nil {
+		p.logger.Error("UpdateState: failed to insert state", zap.Error(err))
+		return nil, err
+	}
+	var state StateStoreModel
+	stmt := "SELECT " + statesAllCols + " FROM " + statesTable + " WHERE client_id=$1 AND fence_id=$2 FOR UPDATE"
+	if err := tx.GetContext(ctx, &state, stmt, clientID, fenceID); err != nil {
+		p.logger.Error("UpdateState: failed to lock state", zap.Error(err))
+		return nil, err
+	}
+	if err := fn(&state); err != nil {
+		return nil, err
+	}
+	update := `UPDATE geofence_states SET
+	inside=:inside,
+	entered_at=:entered_at,
+	dwelled=:dwelled,
+	updated_at=:updated_at
+	WHERE client_id=:client_id AND fence_id=:fence_id
+	`
+	if _, err := tx.NamedExecContext(ctx, update, state); err != nil {
+		p.logger.Error("UpdateState: failed to update state", zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (p Postgres) CreateEvents(ctx context.Context, models []EventStoreModel) error {
+	stmt := `INSERT INTO geofence_events (
+	id,
+	client_id,
+	fence_id,
+	fence_name,
+	type,
+	point,
+	created_at
+	) VALUES (
+	:id,
+	:client_id,
+	:fence_id,
+	:fence_name,
+	:type,
+	:point,
+	:created_at
+	)`
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("CreateEvents: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+	for _, m := range models {
+		if _, err := tx.NamedExecContext(ctx, stmt, m); err != nil {
+			p.logger.Error("CreateEvents: failed to insert event to db", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) ListEvents(ctx context.Context, clientID string, limit int) ([]EventStoreModel, error) {
+	stmt := "SELECT " + eventsAllCols + " FROM " + eventsTable + `
+	WHERE client_id=$1
+	ORDER BY created_at DESC
+	LIMIT $2`
+	res := make([]EventStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID, limit); err != nil {
+		p.logger.Error("ListEvents: failed to list events from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
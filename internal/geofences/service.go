@@ -0,0 +1,392 @@
+package geofences
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+const (
+	eventsLimit     = 100
+	metersPerDegree = 111320.0
+)
+
+var (
+	ErrInvalidFence  = errors.New("invalid geofence")
+	ErrFenceNotFound = errors.New("geofence not found")
+)
+
+type Service interface {
+	CreateFence(ctx context.Context, payload FencePayload) (*Fence, error)
+	ListFences(ctx context.Context) ([]Fence, error)
+	DeleteFence(ctx context.Context, id string) error
+	// Events returns the latest fence events of the player
+	Events(ctx context.Context, clientID string) ([]Event, error)
+	players.LocationListener
+}
+
+// Subscriber is notified in-process of every fence event
+type Subscriber interface {
+	OnGeofenceEvent(ctx context.Context, event Event) error
+}
+
+// SubscriberFunc adapts a function to the Subscriber interface
+type SubscriberFunc func(ctx context.Context, event Event) error
+
+func (f SubscriberFunc) OnGeofenceEvent(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// LocationProvider returns the game locations
+type LocationProvider interface {
+	Get(ctx context.Context, id string) (*locations.Location, error)
+}
+
+type Config struct {
+	// Hysteresis in meters a player has to leave a fence by before the exit fires
+	Hysteresis float64 `env:"GEOFENCE_HYSTERESIS" envDefault:"15"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Hysteresis: 15,
+	}
+}
+
+type Option func(*DefaultService)
+
+// WithSubscriber registers a subscriber notified of every fence event
+func WithSubscriber(s Subscriber) Option {
+	return func(d *DefaultService) {
+		d.subscribers = append(d.subscribers, s)
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger      *zap.Logger
+	store       Store
+	locations   LocationProvider
+	config      Config
+	dbTimeOut   time.Duration
+	subscribers []Subscriber
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, locations LocationProvider, config Config, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		locations: locations,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidFence, fmt.Sprintf(format, args...))
+}
+
+func (d *DefaultService) CreateFence(ctx context.Context, payload FencePayload) (*Fence, error) {
+	if strings.TrimSpace(payload.Name) == "" {
+		return nil, invalid("name is required")
+	}
+	triggers := make(pq.StringArray, 0, len(payload.Triggers))
+	for _, t := range payload.Triggers {
+		switch t {
+		case EventEnter, EventExit:
+		case EventDwell:
+			if payload.DwellSeconds <= 0 {
+				return nil, invalid("dwell trigger requires dwellSeconds")
+			}
+		default:
+			return nil, invalid("unknown trigger %q", t)
+		}
+		triggers = append(triggers, string(t))
+	}
+	if len(triggers) == 0 {
+		return nil, invalid("at least one trigger is required")
+	}
+
+	model := FenceStoreModel{
+		ID:           uuid.New(),
+		Name:         payload.Name,
+		Triggers:     triggers,
+		DwellSeconds: payload.DwellSeconds,
+		CreatedAt:    time.Now().UTC(),
+	}
+	var bound orb.Bound
+	switch {
+	case payload.LocationID != "" && len(payload.Polygon) == 0:
+		if payload.Radius <= 0 {
+			return nil, invalid("radius must be positive")
+		}
+		loc, err := d.locations.Get(ctx, payload.LocationID)
+		if err != nil {
+			if errors.Is(err, locations.ErrNotFound) {
+				return nil, invalid("unknown location %s", payload.LocationID)
+			}
+			return nil, errors.New("failed to create geofence:" + err.Error())
+		}
+		model.Geometry = Geometry{
+			Shape:      ShapeCircle,
+			LocationID: loc.ID,
+			Center:     [2]float64{loc.GeoPoint.Longitude, loc.GeoPoint.Latitude},
+			Radius:     payload.Radius,
+		}
+		bound = around(orb.Point(model.Geometry.Center), payload.Radius)
+	case payload.LocationID == "" && len(payload.Polygon) > 0:
+		ring := make(orb.Ring, 0, len(payload.Polygon)+1)
+		for _, p := range payload.Polygon {
+			ring = append(ring, orb.Point(p))
+		}
+		if !ring.Closed() {
+			ring = append(ring, ring[0])
+		}
+		if len(ring) < 4 {
+			return nil, invalid("polygon needs at least 3 points")
+		}
+		model.Geometry = Geometry{Shape: ShapePolygon}
+		for _, p := range ring {
+			model.Geometry.Ring = append(model.Geometry.Ring, [2]float64(p))
+		}
+		bound = ring.Bound()
+	default:
+		return nil, invalid("either a location with a radius or a polygon is required")
+	}
+	model.MinLon, model.MinLat = bound.Min.Lon(), bound.Min.Lat()
+	model.MaxLon, model.MaxLat = bound.Max.Lon(), bound.Max.Lat()
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateFence(dbCtx, &model); err != nil {
+		d.logger.Error("CreateFence: failed to create fence", zap.String("name", payload.Name), zap.Error(err))
+		return nil, errors.New("failed to create geofence:" + err.Error())
+	}
+	fence := toFence(&model)
+	return &fence, nil
+}
+
+func (d *DefaultService) ListFences(ctx context.Context) ([]Fence, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListFences(dbCtx)
+	if err != nil {
+		d.logger.Error("ListFences: failed to list fences", zap.Error(err))
+		return nil, errors.New("failed to list geofences:" + err.Error())
+	}
+	res := make([]Fence, 0, len(models))
+	for i := range models {
+		res = append(res, toFence(&models[i]))
+	}
+	return res, nil
+}
+
+func (d *DefaultService) DeleteFence(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrFenceNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.DeleteFence(dbCtx, id); err != nil {
+		if err == ErrNotFound {
+			return ErrFenceNotFound
+		}
+		d.logger.Error("DeleteFence: failed to delete fence", zap.String("fenceID", id), zap.Error(err))
+		return errors.New("failed to delete geofence:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) Events(ctx context.Context, clientID string) ([]Event, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListEvents(dbCtx, clientID, eventsLimit)
+	if err != nil {
+		d.logger.Error("Events: failed to list events", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list geofence events:" + err.Error())
+	}
+	res := make([]Event, 0, len(models))
+	for i := range models {
+		res = append(res, toEvent(&models[i]))
+	}
+	return res, nil
+}
+
+// OnLocationUpdate evaluates the position against the fences around it and the fences the player is inside
+func (d *DefaultService) OnLocationUpdate(ctx context.Context, update players.LocationUpdate) error {
+	clientID, err := uuid.Parse(update.ClientID)
+	if err != nil {
+		return err
+	}
+	point := orb.Point{update.GeoPoint.Longitude, update.GeoPoint.Latitude}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+
+	fences, err := d.store.ListInBounds(dbCtx, around(point, d.config.Hysteresis))
+	if err != nil {
+		d.logger.Error("OnLocationUpdate: failed to list fences", zap.String("clientID", update.ClientID), zap.Error(err))
+		return errors.New("failed to evaluate geofences:" + err.Error())
+	}
+	inside, err := d.store.ListInside(dbCtx, update.ClientID)
+	if err != nil {
+		d.logger.Error("OnLocationUpdate: failed to list states", zap.String("clientID", update.ClientID), zap.Error(err))
+		return errors.New("failed to evaluate geofences:" + err.Error())
+	}
+	isInside := make(map[string]bool, len(inside))
+	for _, s := range inside {
+		isInside[s.FenceID.String()] = true
+	}
+	seen := make(map[string]bool, len(fences))
+	for _, f := range fences {
+		seen[f.ID.String()] = true
+	}
+	// a fence far from the position still has to see the player leave
+	for id := range isInside {
+		if seen[id] {
+			continue
+		}
+		f, err := d.store.GetFence(dbCtx, id)
+		if err != nil {
+			if err == ErrNotFound {
+				continue
+			}
+			d.logger.Error("OnLocationUpdate: failed to get fence", zap.String("fenceID", id), zap.Error(err))
+			return errors.New("failed to evaluate geofences:" + err.Error())
+		}
+		fences = append(fences, *f)
+	}
+
+	events := make([]EventStoreModel, 0)
+	for i := range fences {
+		f := &fences[i]
+		distance := signedDistance(f.Geometry, point)
+		if distance > 0 && !isInside[f.ID.String()] {
+			// outside and not entering, nothing can change
+			continue
+		}
+		var fired []EventType
+		_, err := d.store.UpdateState(dbCtx, update.ClientID, f.ID.String(), func(s *StateStoreModel) error {
+			fired = transition(s, f, distance, d.config.Hysteresis, update.At)
+			return nil
+		})
+		if err != nil {
+			d.logger.Error("OnLocationUpdate: failed to update state", zap.String("clientID", update.ClientID), zap.String("fenceID", f.ID.String()), zap.Error(err))
+			return errors.New("failed to evaluate geofences:" + err.Error())
+		}
+		for _, t := range fired {
+			if !hasTrigger(f, t) {
+				continue
+			}
+			events = append(events, EventStoreModel{
+				ID:        uuid.New(),
+				ClientID:  clientID,
+				FenceID:   f.ID,
+				FenceName: f.Name,
+				Type:      string(t),
+				Point:     locations.Point{Point: point},
+				CreatedAt: update.At.UTC(),
+			})
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := d.store.CreateEvents(dbCtx, events); err != nil {
+		d.logger.Error("OnLocationUpdate: failed to store events", zap.String("clientID", update.ClientID), zap.Error(err))
+		return errors.New("failed to store geofence events:" + err.Error())
+	}
+	for i := range events {
+		event := toEvent(&events[i])
+		for _, s := range d.subscribers {
+			// the event is recorded, a failing subscriber must not hide it from the others
+			if err := s.OnGeofenceEvent(ctx, event); err != nil {
+				d.logger.Error("OnLocationUpdate: subscriber failed", zap.String("clientID", update.ClientID), zap.String("fenceID", event.FenceID), zap.Error(err))
+			}
+		}
+	}
+	return nil
+}
+
+// transition moves the state by the signed distance to the fence boundary, the player enters on the boundary
+// and only leaves once farther than the hysteresis so positions jittering around the boundary do not flap
+func transition(s *StateStoreModel, f *FenceStoreModel, distance, hysteresis float64, at time.Time) []EventType {
+	fired := make([]EventType, 0, 2)
+	switch {
+	case !s.Inside && distance <= 0:
+		s.Inside = true
+		s.EnteredAt = sql.NullTime{Time: at, Valid: true}
+		s.Dwelled = false
+		fired = append(fired, EventEnter)
+	case s.Inside && distance > hysteresis:
+		s.Inside = false
+		s.Dwelled = false
+		fired = append(fired, EventExit)
+	}
+	dwell := time.Duration(f.DwellSeconds) * time.Second
+	if s.Inside && !s.Dwelled && dwell > 0 && at.Sub(s.EnteredAt.Time) >= dwell {
+		s.Dwelled = true
+		fired = append(fired, EventDwell)
+	}
+	s.UpdatedAt = at
+	return fired
+}
+
+func hasTrigger(f *FenceStoreModel, t EventType) bool {
+	for _, trigger := range f.Triggers {
+		if trigger == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// signedDistance returns the distance in meters to the boundary of the fence, negative inside
+func signedDistance(g Geometry, point orb.Point) float64 {
+	if g.Shape == ShapeCircle {
+		return geo.Distance(orb.Point(g.Center), point) - g.Radius
+	}
+	// project the ring to meters around the point, fences are small enough for a flat approximation
+	scale := math.Cos(point.Lat() * math.Pi / 180)
+	ring := make(orb.Ring, 0, len(g.Ring))
+	for _, p := range g.Ring {
+		ring = append(ring, orb.Point{
+			(p[0] - point.Lon()) * metersPerDegree * scale,
+			(p[1] - point.Lat()) * metersPerDegree,
+		})
+	}
+	origin := orb.Point{0, 0}
+	distance := planar.DistanceFrom(orb.LineString(ring), origin)
+	if planar.RingContains(ring, origin) {
+		return -distance
+	}
+	return distance
+}
+
+// around returns the bound of the circle with the radius in meters
+func around(point orb.Point, meters float64) orb.Bound {
+	lat := meters / metersPerDegree
+	lon := meters / (metersPerDegree * math.Max(math.Cos(point.Lat()*math.Pi/180), 0.01))
+	return orb.Bound{
+		Min: orb.Point{point.Lon() - lon, point.Lat() - lat},
+		Max: orb.Point{point.Lon() + lon, point.Lat() + lat},
+	}
+}
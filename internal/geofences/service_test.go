@@ -0,0 +1,143 @@
+package geofences
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+type locationsFunc func(id string) (*locations.Location, error)
+
+func (f locationsFunc) Get(ctx context.Context, id string) (*locations.Location, error) {
+	return f(id)
+}
+
+var square = locationsFunc(func(id string) (*locations.Location, error) {
+	if id != "square" {
+		return nil, locations.ErrNotFound
+	}
+	return &locations.Location{ID: id, GeoPoint: locations.GeoPoint{Longitude: 18.07, Latitude: 59.33}}, nil
+})
+
+func move(clientID string, lon, lat float64, at time.Time) players.LocationUpdate {
+	return players.LocationUpdate{
+		ClientID: clientID,
+		GeoPoint: locations.GeoPoint{Longitude: lon, Latitude: lat},
+		At:       at,
+	}
+}
+
+func TestDefaultService_CreateFence(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), square, DefaultConfig(), time.Second)
+	polygon := [][2]float64{{18, 59}, {18.1, 59}, {18.1, 59.1}, {18, 59.1}}
+	tests := []struct {
+		name    string
+		payload FencePayload
+		wantErr bool
+	}{
+		{name: "circle", payload: FencePayload{Name: "square", LocationID: "square", Radius: 100, Triggers: []EventType{EventEnter}}},
+		{name: "polygon", payload: FencePayload{Name: "park", Polygon: polygon, Triggers: []EventType{EventEnter, EventExit, EventDwell}, DwellSeconds: 60}},
+		{name: "no name", payload: FencePayload{LocationID: "square", Radius: 100, Triggers: []EventType{EventEnter}}, wantErr: true},
+		{name: "no trigger", payload: FencePayload{Name: "square", LocationID: "square", Radius: 100}, wantErr: true},
+		{name: "unknown trigger", payload: FencePayload{Name: "square", LocationID: "square", Radius: 100, Triggers: []EventType{"jump"}}, wantErr: true},
+		{name: "dwell without time", payload: FencePayload{Name: "square", LocationID: "square", Radius: 100, Triggers: []EventType{EventDwell}}, wantErr: true},
+		{name: "unknown location", payload: FencePayload{Name: "square", LocationID: "unknown", Radius: 100, Triggers: []EventType{EventEnter}}, wantErr: true},
+		{name: "no radius", payload: FencePayload{Name: "square", LocationID: "square", Triggers: []EventType{EventEnter}}, wantErr: true},
+		{name: "both shapes", payload: FencePayload{Name: "square", LocationID: "square", Radius: 100, Polygon: polygon, Triggers: []EventType{EventEnter}}, wantErr: true},
+		{name: "short polygon", payload: FencePayload{Name: "park", Polygon: polygon[:2], Triggers: []EventType{EventEnter}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.CreateFence(context.TODO(), tt.payload)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestSignedDistance(t *testing.T) {
+	circle := Geometry{Shape: ShapeCircle, Center: [2]float64{18.07, 59.33}, Radius: 100}
+	polygon := Geometry{Shape: ShapePolygon, Ring: [][2]float64{{18, 59}, {18.01, 59}, {18.01, 59.01}, {18, 59.01}, {18, 59}}}
+	tests := []struct {
+		name  string
+		g     Geometry
+		point orb.Point
+		want  float64
+	}{
+		{name: "circle center", g: circle, point: orb.Point{18.07, 59.33}, want: -100},
+		// about 111m north of the center
+		{name: "circle outside", g: circle, point: orb.Point{18.07, 59.331}, want: 11},
+		// about 111m inside the southern edge
+		{name: "polygon inside", g: polygon, point: orb.Point{18.005, 59.001}, want: -111},
+		{name: "polygon outside", g: polygon, point: orb.Point{18.005, 58.999}, want: 111},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, signedDistance(tt.g, tt.point), 2)
+		})
+	}
+}
+
+func TestDefaultService_OnLocationUpdate(t *testing.T) {
+	var notified []Event
+	subscriber := SubscriberFunc(func(ctx context.Context, event Event) error {
+		notified = append(notified, event)
+		return nil
+	})
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), square, DefaultConfig(), time.Second, WithSubscriber(subscriber))
+	fence, err := d.CreateFence(context.TODO(), FencePayload{Name: "square", LocationID: "square", Radius: 100, Triggers: []EventType{EventEnter, EventExit, EventDwell}, DwellSeconds: 600})
+	assert.Nil(t, err)
+	clientID := uuid.New().String()
+	now := time.Now()
+
+	steps := []struct {
+		name string
+		lat  float64
+		at   time.Duration
+		want []EventType
+	}{
+		{name: "outside", lat: 59.3310, want: nil},
+		{name: "enter", lat: 59.3300, at: time.Minute, want: []EventType{EventEnter}},
+		// about 5m outside, within the hysteresis
+		{name: "jitter out", lat: 59.33094, at: time.Minute * 2, want: nil},
+		{name: "jitter in", lat: 59.3308, at: time.Minute * 3, want: nil},
+		{name: "dwell", lat: 59.3300, at: time.Minute * 11, want: []EventType{EventDwell}},
+		{name: "stay", lat: 59.3300, at: time.Minute * 20, want: nil},
+		{name: "exit", lat: 59.3400, at: time.Minute * 21, want: []EventType{EventExit}},
+		{name: "far away", lat: 59.3500, at: time.Minute * 22, want: nil},
+		{name: "enter again", lat: 59.3300, at: time.Minute * 23, want: []EventType{EventEnter}},
+	}
+	for _, step := range steps {
+		notified = nil
+		assert.Nil(t, d.OnLocationUpdate(context.TODO(), move(clientID, 18.07, step.lat, now.Add(step.at))), step.name)
+		got := make([]EventType, 0)
+		for _, e := range notified {
+			assert.Equal(t, fence.ID, e.FenceID)
+			got = append(got, e.Type)
+		}
+		assert.ElementsMatch(t, step.want, got, step.name)
+	}
+
+	events, err := d.Events(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Len(t, events, 4)
+	assert.Equal(t, EventEnter, events[0].Type)
+
+	// deleting the fence drops the events
+	assert.Nil(t, d.DeleteFence(context.TODO(), fence.ID))
+	events, err = d.Events(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+	assert.Equal(t, ErrFenceNotFound, d.DeleteFence(context.TODO(), fence.ID))
+}
@@ -0,0 +1,30 @@
+package geofences
+
+import (
+	"context"
+	"errors"
+
+	"github.com/paulmach/orb"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// StateFunc changes the locked state, a missing state is handed over outside
+type StateFunc func(s *StateStoreModel) error
+
+type Store interface {
+	CreateFence(ctx context.Context, model *FenceStoreModel) error
+	GetFence(ctx context.Context, id string) (*FenceStoreModel, error)
+	ListFences(ctx context.Context) ([]FenceStoreModel, error)
+	// DeleteFence removes the fence with the states and events of the players
+	DeleteFence(ctx context.Context, id string) error
+	// ListInBounds returns the fences whose bounding box intersects the bound
+	ListInBounds(ctx context.Context, bound orb.Bound) ([]FenceStoreModel, error)
+	// ListInside returns the states of the fences the player is inside
+	ListInside(ctx context.Context, clientID string) ([]StateStoreModel, error)
+	// UpdateState runs fn on the locked state of the player, concurrent positions are serialized
+	UpdateState(ctx context.Context, clientID, fenceID string, fn StateFunc) (*StateStoreModel, error)
+	CreateEvents(ctx context.Context, models []EventStoreModel) error
+	ListEvents(ctx context.Context, clientID string, limit int) ([]EventStoreModel, error)
+}
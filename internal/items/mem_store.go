@@ -0,0 +1,234 @@
+package items
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb/geo"
+
+	"geogame/internal/locations"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu           sync.Mutex
+	itemMap      map[string]ItemStoreModel
+	ruleMap      map[string]SpawnRuleStoreModel
+	spawnMap     map[string]SpawnStoreModel
+	inventoryMap map[string]map[string]InventoryStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		itemMap:      make(map[string]ItemStoreModel),
+		ruleMap:      make(map[string]SpawnRuleStoreModel),
+		spawnMap:     make(map[string]SpawnStoreModel),
+		inventoryMap: make(map[string]map[string]InventoryStoreModel),
+	}
+}
+
+func (m *MemStore) CreateItem(ctx context.Context, model *ItemStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.itemMap[model.ID]; ok {
+		return ErrItemExists
+	}
+	m.itemMap[model.ID] = *model
+	return nil
+}
+
+func (m *MemStore) GetItem(ctx context.Context, id string) (*ItemStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.itemMap[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+func (m *MemStore) ListItems(ctx context.Context) ([]ItemStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]ItemStoreModel, 0, len(m.itemMap))
+	for _, item := range m.itemMap {
+		res = append(res, item)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+func (m *MemStore) CreateRule(ctx context.Context, model *SpawnRuleStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ruleMap[model.ID.String()] = *model
+	return nil
+}
+
+func (m *MemStore) ListRules(ctx context.Context) ([]SpawnRuleStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]SpawnRuleStoreModel, 0, len(m.ruleMap))
+	for _, rule := range m.ruleMap {
+		res = append(res, rule)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+func (m *MemStore) DeleteRule(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.ruleMap[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.ruleMap, id)
+	return nil
+}
+
+func (m *MemStore) ClaimRule(ctx context.Context, id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rule, ok := m.ruleMap[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if rule.LastSpawnAt.Valid && rule.LastSpawnAt.Time.Add(time.Duration(rule.IntervalSeconds)*time.Second).After(now) {
+		return false, nil
+	}
+	rule.LastSpawnAt.Time, rule.LastSpawnAt.Valid = now, true
+	m.ruleMap[id] = rule
+	return true, nil
+}
+
+func (m *MemStore) CreateSpawns(ctx context.Context, models []SpawnStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range models {
+		m.spawnMap[s.ID.String()] = s
+	}
+	return nil
+}
+
+func (m *MemStore) CountActiveSpawns(ctx context.Context, ruleID string, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, s := range m.spawnMap {
+		if s.RuleID.String == ruleID && active(&s, now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemStore) ListSpawnsNear(ctx context.Context, point locations.Point, radius float64, now time.Time) ([]SpawnStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]SpawnStoreModel, 0)
+	for _, s := range m.spawnMap {
+		if active(&s, now) && geo.Distance(point.Point, s.Point.Point) <= radius {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) DeleteExpiredSpawns(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for id, s := range m.spawnMap {
+		if s.ExpiresAt.Before(before) || s.CollectedBy.Valid {
+			delete(m.spawnMap, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemStore) Collect(ctx context.Context, spawnID, clientID string, fn CollectFunc) (*InventoryStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	spawn, ok := m.spawnMap[spawnID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	inv := m.inventoryEntry(clientID, spawn.ItemID)
+	// fn works on copies so a failing pick up leaves the spawn and the inventory untouched
+	if err := fn(&spawn, &inv); err != nil {
+		return nil, err
+	}
+	m.spawnMap[spawnID] = spawn
+	m.inventoryMap[clientID][inv.ItemID] = inv
+	return &inv, nil
+}
+
+func (m *MemStore) ListInventory(ctx context.Context, clientID string) ([]InventoryStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]InventoryStoreModel, 0)
+	for _, inv := range m.inventoryMap[clientID] {
+		res = append(res, inv)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ItemID < res[j].ItemID
+	})
+	return res, nil
+}
+
+func (m *MemStore) UpdateInventory(ctx context.Context, clientID, itemID string, fn InventoryFunc) (*InventoryStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv := m.inventoryEntry(clientID, itemID)
+	if err := fn(&inv); err != nil {
+		return nil, err
+	}
+	if inv.Quantity <= 0 {
+		delete(m.inventoryMap[clientID], itemID)
+	} else {
+		m.inventoryMap[clientID][itemID] = inv
+	}
+	return &inv, nil
+}
+
+func (m *MemStore) Drop(ctx context.Context, clientID string, spawn *SpawnStoreModel, fn InventoryFunc) (*InventoryStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv := m.inventoryEntry(clientID, spawn.ItemID)
+	if err := fn(&inv); err != nil {
+		return nil, err
+	}
+	if inv.Quantity <= 0 {
+		delete(m.inventoryMap[clientID], inv.ItemID)
+	} else {
+		m.inventoryMap[clientID][inv.ItemID] = inv
+	}
+	m.spawnMap[spawn.ID.String()] = *spawn
+	return &inv, nil
+}
+
+// inventoryEntry returns a copy of the entry, the caller holds the lock
+func (m *MemStore) inventoryEntry(clientID, itemID string) InventoryStoreModel {
+	if _, ok := m.inventoryMap[clientID]; !ok {
+		m.inventoryMap[clientID] = make(map[string]InventoryStoreModel)
+	}
+	inv, ok := m.inventoryMap[clientID][itemID]
+	if !ok {
+		id, _ := uuid.Parse(clientID)
+		inv = InventoryStoreModel{ClientID: id, ItemID: itemID}
+	}
+	return inv
+}
+
+func active(s *SpawnStoreModel, now time.Time) bool {
+	return !s.CollectedBy.Valid && s.ExpiresAt.After(now)
+}
@@ -0,0 +1,146 @@
+package items
+
+import (
+	"context"
+	"time"
+
+	"geogame/internal/locations"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateItemFunc          func(model *ItemStoreModel) error
+	GetItemFunc             func(id string) (*ItemStoreModel, error)
+	ListItemsFunc           func() ([]ItemStoreModel, error)
+	CreateRuleFunc          func(model *SpawnRuleStoreModel) error
+	ListRulesFunc           func() ([]SpawnRuleStoreModel, error)
+	DeleteRuleFunc          func(id string) error
+	ClaimRuleFunc           func(id string, now time.Time) (bool, error)
+	CreateSpawnsFunc        func(models []SpawnStoreModel) error
+	CountActiveSpawnsFunc   func(ruleID string, now time.Time) (int, error)
+	ListSpawnsNearFunc      func(point locations.Point, radius float64, now time.Time) ([]SpawnStoreModel, error)
+	DeleteExpiredSpawnsFunc func(before time.Time) (int64, error)
+	CollectFunc             func(spawnID, clientID string, fn CollectFunc) (*InventoryStoreModel, error)
+	ListInventoryFunc       func(clientID string) ([]InventoryStoreModel, error)
+	UpdateInventoryFunc     func(clientID, itemID string, fn InventoryFunc) (*InventoryStoreModel, error)
+	DropFunc                func(clientID string, spawn *SpawnStoreModel, fn InventoryFunc) (*InventoryStoreModel, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateItemFunc: func(model *ItemStoreModel) error {
+			return nil
+		},
+		GetItemFunc: func(id string) (*ItemStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListItemsFunc: func() ([]ItemStoreModel, error) {
+			return []ItemStoreModel{}, nil
+		},
+		CreateRuleFunc: func(model *SpawnRuleStoreModel) error {
+			return nil
+		},
+		ListRulesFunc: func() ([]SpawnRuleStoreModel, error) {
+			return []SpawnRuleStoreModel{}, nil
+		},
+		DeleteRuleFunc: func(id string) error {
+			return nil
+		},
+		ClaimRuleFunc: func(id string, now time.Time) (bool, error) {
+			return false, nil
+		},
+		CreateSpawnsFunc: func(models []SpawnStoreModel) error {
+			return nil
+		},
+		CountActiveSpawnsFunc: func(ruleID string, now time.Time) (int, error) {
+			return 0, nil
+		},
+		ListSpawnsNearFunc: func(point locations.Point, radius float64, now time.Time) ([]SpawnStoreModel, error) {
+			return []SpawnStoreModel{}, nil
+		},
+		DeleteExpiredSpawnsFunc: func(before time.Time) (int64, error) {
+			return 0, nil
+		},
+		CollectFunc: func(spawnID, clientID string, fn CollectFunc) (*InventoryStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListInventoryFunc: func(clientID string) ([]InventoryStoreModel, error) {
+			return []InventoryStoreModel{}, nil
+		},
+		UpdateInventoryFunc: func(clientID, itemID string, fn InventoryFunc) (*InventoryStoreModel, error) {
+			inv := InventoryStoreModel{ItemID: itemID}
+			if err := fn(&inv); err != nil {
+				return nil, err
+			}
+			return &inv, nil
+		},
+		DropFunc: func(clientID string, spawn *SpawnStoreModel, fn InventoryFunc) (*InventoryStoreModel, error) {
+			inv := InventoryStoreModel{ItemID: spawn.ItemID}
+			if err := fn(&inv); err != nil {
+				return nil, err
+			}
+			return &inv, nil
+		},
+	}
+}
+
+func (m *MockStore) CreateItem(ctx context.Context, model *ItemStoreModel) error {
+	return m.CreateItemFunc(model)
+}
+
+func (m *MockStore) GetItem(ctx context.Context, id string) (*ItemStoreModel, error) {
+	return m.GetItemFunc(id)
+}
+
+func (m *MockStore) ListItems(ctx context.Context) ([]ItemStoreModel, error) {
+	return m.ListItemsFunc()
+}
+
+func (m *MockStore) CreateRule(ctx context.Context, model *SpawnRuleStoreModel) error {
+	return m.CreateRuleFunc(model)
+}
+
+func (m *MockStore) ListRules(ctx context.Context) ([]SpawnRuleStoreModel, error) {
+	return m.ListRulesFunc()
+}
+
+func (m *MockStore) DeleteRule(ctx context.Context, id string) error {
+	return m.DeleteRuleFunc(id)
+}
+
+func (m *MockStore) ClaimRule(ctx context.Context, id string, now time.Time) (bool, error) {
+	return m.ClaimRuleFunc(id, now)
+}
+
+func (m *MockStore) CreateSpawns(ctx context.Context, models []SpawnStoreModel) error {
+	return m.CreateSpawnsFunc(models)
+}
+
+func (m *MockStore) CountActiveSpawns(ctx context.Context, ruleID string, now time.Time) (int, error) {
+	return m.CountActiveSpawnsFunc(ruleID, now)
+}
+
+func (m *MockStore) ListSpawnsNear(ctx context.Context, point locations.Point, radius float64, now time.Time) ([]SpawnStoreModel, error) {
+	return m.ListSpawnsNearFunc(point, radius, now)
+}
+
+func (m *MockStore) DeleteExpiredSpawns(ctx context.Context, before time.Time) (int64, error) {
+	return m.DeleteExpiredSpawnsFunc(before)
+}
+
+func (m *MockStore) Collect(ctx context.Context, spawnID, clientID string, fn CollectFunc) (*InventoryStoreModel, error) {
+	return m.CollectFunc(spawnID, clientID, fn)
+}
+
+func (m *MockStore) ListInventory(ctx context.Context, clientID string) ([]InventoryStoreModel, error) {
+	return m.ListInventoryFunc(clientID)
+}
+
+func (m *MockStore) UpdateInventory(ctx context.Context, clientID, itemID string, fn InventoryFunc) (*InventoryStoreModel, error) {
+	return m.UpdateInventoryFunc(clientID, itemID, fn)
+}
+
+func (m *MockStore) Drop(ctx context.Context, clientID string, spawn *SpawnStoreModel, fn InventoryFunc) (*InventoryStoreModel, error) {
+	return m.DropFunc(clientID, spawn, fn)
+}
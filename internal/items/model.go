@@ -0,0 +1,146 @@
+package items
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"geogame/internal/locations"
+)
+
+// ItemStoreModel is an entry of the item catalog
+type ItemStoreModel struct {
+	ID          string `db:"id" json:"id"`
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+	// StackLimit is the maximum quantity of the item a player can carry
+	StackLimit int `db:"stack_limit" json:"stackLimit"`
+}
+
+// Area is a ring of [longitude, latitude] pairs
+type Area [][2]float64
+
+// Value enables serialization to SQL
+func (a Area) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan enables deserialization from SQL
+func (a *Area) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+	return fmt.Errorf("unsupported area type %T", src)
+}
+
+// SpawnRuleStoreModel spawns an item around a location or inside an area on a schedule
+type SpawnRuleStoreModel struct {
+	ID         uuid.UUID      `db:"id"`
+	ItemID     string         `db:"item_id"`
+	LocationID sql.NullString `db:"loc_id"`
+	// Radius in meters around the location
+	Radius          float64      `db:"radius"`
+	Area            Area         `db:"area"`
+	MaxActive       int          `db:"max_active"`
+	Quantity        int          `db:"quantity"`
+	IntervalSeconds int64        `db:"interval_seconds"`
+	LifetimeSeconds int64        `db:"lifetime_seconds"`
+	LastSpawnAt     sql.NullTime `db:"last_spawn_at"`
+	CreatedAt       time.Time    `db:"created_at"`
+}
+
+type SpawnStoreModel struct {
+	ID          uuid.UUID       `db:"id"`
+	RuleID      sql.NullString  `db:"rule_id"`
+	ItemID      string          `db:"item_id"`
+	Quantity    int             `db:"quantity"`
+	Point       locations.Point `db:"point"`
+	CreatedAt   time.Time       `db:"created_at"`
+	ExpiresAt   time.Time       `db:"expires_at"`
+	CollectedBy sql.NullString  `db:"collected_by"`
+	CollectedAt sql.NullTime    `db:"collected_at"`
+}
+
+type InventoryStoreModel struct {
+	ClientID  uuid.UUID `db:"client_id"`
+	ItemID    string    `db:"item_id"`
+	Quantity  int       `db:"quantity"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type SpawnRulePayload struct {
+	ItemID          string  `json:"itemId"`
+	LocationID      string  `json:"locationId,omitempty"`
+	Radius          float64 `json:"radius,omitempty"`
+	Area            Area    `json:"area,omitempty"`
+	MaxActive       int     `json:"maxActive"`
+	Quantity        int     `json:"quantity"`
+	IntervalSeconds int64   `json:"intervalSeconds"`
+	LifetimeSeconds int64   `json:"lifetimeSeconds"`
+}
+
+type QuantityPayload struct {
+	Quantity int `json:"quantity"`
+}
+
+type SpawnRule struct {
+	ID              string     `json:"id"`
+	ItemID          string     `json:"itemId"`
+	LocationID      string     `json:"locationId,omitempty"`
+	Radius          float64    `json:"radius,omitempty"`
+	Area            Area       `json:"area,omitempty"`
+	MaxActive       int        `json:"maxActive"`
+	Quantity        int        `json:"quantity"`
+	IntervalSeconds int64      `json:"intervalSeconds"`
+	LifetimeSeconds int64      `json:"lifetimeSeconds"`
+	LastSpawnAt     *time.Time `json:"lastSpawnAt,omitempty"`
+}
+
+type Spawn struct {
+	ID        string             `json:"id"`
+	ItemID    string             `json:"itemId"`
+	Quantity  int                `json:"quantity"`
+	GeoPoint  locations.GeoPoint `json:"geoPoint"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+	// Distance in meters from the player
+	Distance float64 `json:"distance"`
+}
+
+type InventoryItem struct {
+	ItemID     string `json:"itemId"`
+	Name       string `json:"name"`
+	Quantity   int    `json:"quantity"`
+	StackLimit int    `json:"stackLimit"`
+}
+
+func toSpawnRule(m *SpawnRuleStoreModel) SpawnRule {
+	r := SpawnRule{
+		ID:              m.ID.String(),
+		ItemID:          m.ItemID,
+		LocationID:      m.LocationID.String,
+		Radius:          m.Radius,
+		Area:            m.Area,
+		MaxActive:       m.MaxActive,
+		Quantity:        m.Quantity,
+		IntervalSeconds: m.IntervalSeconds,
+		LifetimeSeconds: m.LifetimeSeconds,
+	}
+	if m.LastSpawnAt.Valid {
+		lastSpawnAt := m.LastSpawnAt.Time
+		r.LastSpawnAt = &lastSpawnAt
+	}
+	return r
+}
@@ -0,0 +1,376 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	itemsAllCols     = "id, name, description, stack_limit"
+	itemsTable       = "items"
+	rulesAllCols     = "id, item_id, loc_id, radius, area, max_active, quantity, interval_seconds, lifetime_seconds, last_spawn_at, created_at"
+	rulesTable       = "spawn_rules"
+	spawnsAllCols    = "id, rule_id, item_id, quantity, ST_AsBinary(point) AS point, created_at, expires_at, collected_by, collected_at"
+	spawnsTable      = "spawns"
+	inventoryAllCols = "client_id, item_id, quantity, updated_at"
+	inventoryTable   = "inventory"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.items.store"),
+	}
+}
+
+func (p Postgres) CreateItem(ctx context.Context, model *ItemStoreModel) error {
+	stmt := `INSERT INTO items (
+	id,
+	name,
+	description,
+	stack_limit
+	) VALUES (
+	:id,
+	:name,
+	:description,
+	:stack_limit
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok {
+			if pgErr.Code == "23505" {
+				return ErrItemExists
+			}
+		}
+		p.logger.Error("CreateItem: failed to insert item to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) GetItem(ctx context.Context, id string) (*ItemStoreModel, error) {
+	stmt := "SELECT " + itemsAllCols + " FROM " + itemsTable + " WHERE id=$1"
+	var item ItemStoreModel
+	if err := p.db.GetContext(ctx, &item, stmt, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetItem: failed to get item from db", zap.Error(err))
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (p Postgres) ListItems(ctx context.Context) ([]ItemStoreModel, error) {
+	stmt := "SELECT " + itemsAllCols + " FROM " + itemsTable + " ORDER BY id"
+	res := make([]ItemStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("ListItems: failed to list items from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) CreateRule(ctx context.Context, model *SpawnRuleStoreModel) error {
+	stmt := `INSERT INTO spawn_rules (
+	id,
+	item_id,
+	loc_id,
+	radius,
+	area,
+	max_active,
+	quantity,
+	interval_seconds,
+	lifetime_seconds,
+	created_at
+	) VALUES (
+	:id,
+	:item_id,
+	:loc_id,
+	:radius,
+	:area,
+	:max_active,
+	:quantity,
+	:interval_seconds,
+	:lifetime_seconds,
+	:created_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("CreateRule: failed to insert spawn rule to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) ListRules(ctx context.Context) ([]SpawnRuleStoreModel, error) {
+	stmt := "SELECT " + rulesAllCols + " FROM " + rulesTable + " ORDER BY created_at"
+	res := make([]SpawnRuleStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("ListRules: failed to list spawn rules from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) DeleteRule(ctx context.Context, id string) error {
+	stmt := "DELETE FROM " + rulesTable + " WHERE id=$1"
+	res, err := p.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		p.logger.Error("DeleteRule: failed to delete spawn rule from db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimRule is a conditional update, only one of several spawner instances wins a rule per interval
+func (p Postgres) ClaimRule(ctx context.Context, id string, now time.Time) (bool, error) {
+	stmt := `UPDATE spawn_rules SET last_spawn_at=$2
+	WHERE id=$1 AND (last_spawn_at IS NULL OR last_spawn_at + interval_seconds * interval '1 second' <= $2)`
+	res, err := p.db.ExecContext(ctx, stmt, id, now)
+	if err != nil {
+		p.logger.Error("ClaimRule: failed to claim spawn rule", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// CreateSpawns inserts the spawns of a run in one transaction
+const insertSpawnStmt = `INSERT INTO spawns (
+	id,
+	rule_id,
+	item_id,
+	quantity,
+	point,
+	created_at,
+	expires_at
+	) VALUES (
+	:id,
+	:rule_id,
+	:item_id,
+	:quantity,
+	:point,
+	:created_at,
+	:expires_at
+	)`
+
+func (p Postgres) CreateSpawns(ctx context.Context, models []SpawnStoreModel) error {
+	if len(models) == 0 {
+		return nil
+	}
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("CreateSpawns: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+	for _, m := range models {
+		if _, err := tx.NamedExecContext(ctx, insertSpawnStmt, m); err != nil {
+			p.logger.Error("CreateSpawns: failed to insert spawn to db", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) CountActiveSpawns(ctx context.Context, ruleID string, now time.Time) (int, error) {
+	stmt := "SELECT count(*) FROM " + spawnsTable + " WHERE rule_id=$1 AND collected_by IS NULL AND expires_at > $2"
+	var count int
+	if err := p.db.GetContext(ctx, &count, stmt, ruleID, now); err != nil {
+		p.logger.Error("CountActiveSpawns: failed to count spawns", zap.Error(err))
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p Postgres) ListSpawnsNear(ctx context.Context, point locations.Point, radius float64, now time.Time) ([]SpawnStoreModel, error) {
+	stmt := "SELECT " + spawnsAllCols + " FROM " + spawnsTable + `
+	WHERE collected_by IS NULL AND expires_at > $1
+	AND ST_DWithin(point, ST_GeogFromText($2), $3)`
+	res := make([]SpawnStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, now, point, radius); err != nil {
+		p.logger.Error("ListSpawnsNear: failed to list spawns from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) DeleteExpiredSpawns(ctx context.Context, before time.Time) (int64, error) {
+	stmt := "DELETE FROM " + spawnsTable + " WHERE expires_at < $1 OR collected_by IS NOT NULL"
+	res, err := p.db.ExecContext(ctx, stmt, before)
+	if err != nil {
+		p.logger.Error("DeleteExpiredSpawns: failed to delete spawns from db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Collect locks the spawn with FOR UPDATE, a concurrent pick up waits and then sees the spawn as collected
+func (p Postgres) Collect(ctx context.Context, spawnID, clientID string, fn CollectFunc) (*InventoryStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("Collect: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var spawn SpawnStoreModel
+	stmt := "SELECT " + spawnsAllCols + " FROM " + spawnsTable + " WHERE id=$1 FOR UPDATE"
+	if err := tx.GetContext(ctx, &spawn, stmt, spawnID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("Collect: failed to lock spawn", zap.Error(err))
+		return nil, err
+	}
+	inv, err := p.lockInventory(ctx, tx, clientID, spawn.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(&spawn, inv); err != nil {
+		return nil, err
+	}
+	update := "UPDATE " + spawnsTable + " SET collected_by=$1, collected_at=$2 WHERE id=$3"
+	if _, err := tx.ExecContext(ctx, update, spawn.CollectedBy, spawn.CollectedAt, spawnID); err != nil {
+		p.logger.Error("Collect: failed to update spawn", zap.Error(err))
+		return nil, err
+	}
+	if err := p.saveInventory(ctx, tx, inv); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (p Postgres) ListInventory(ctx context.Context, clientID string) ([]InventoryStoreModel, error) {
+	stmt := "SELECT " + inventoryAllCols + " FROM " + inventoryTable + " WHERE client_id=$1 ORDER BY item_id"
+	res := make([]InventoryStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID); err != nil {
+		p.logger.Error("ListInventory: failed to list inventory from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) UpdateInventory(ctx context.Context, clientID, itemID string, fn InventoryFunc) (*InventoryStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("UpdateInventory: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inv, err := p.lockInventory(ctx, tx, clientID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(inv); err != nil {
+		return nil, err
+	}
+	if err := p.saveInventory(ctx, tx, inv); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (p Postgres) Drop(ctx context.Context, clientID string, spawn *SpawnStoreModel, fn InventoryFunc) (*InventoryStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("Drop: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inv, err := p.lockInventory(ctx, tx, clientID, spawn.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(inv); err != nil {
+		return nil, err
+	}
+	if err := p.saveInventory(ctx, tx, inv); err != nil {
+		return nil, err
+	}
+	if _, err := tx.NamedExecContext(ctx, insertSpawnStmt, *spawn); err != nil {
+		p.logger.Error("Drop: failed to insert spawn to db", zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// lockInventory returns the locked entry, an empty entry is inserted first when the player does not carry
+// the item so there is a row to lock. saveInventory deletes it again when the quantity stays 0
+func (p Postgres) lockInventory(ctx context.Context, tx *sqlx.Tx, clientID, itemID string) (*InventoryStoreModel, error) {
+	insert := `INSERT INTO inventory (client_id, item_id, quantity, updated_at)
+	VALUES ($1, $2, 0, now())
+	ON CONFLICT (client_id, item_id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, insert, clientID, itemID); err != nil {
+		p.logger.Error("lockInventory: failed to insert inventory", zap.Error(err))
+		return nil, err
+	}
+	var inv InventoryStoreModel
+	stmt := "SELECT " + inventoryAllCols + " FROM " + inventoryTable + " WHERE client_id=$1 AND item_id=$2 FOR UPDATE"
+	if err := tx.GetContext(ctx, &inv, stmt, clientID, itemID); err != nil {
+		p.logger.Error("lockInventory: failed to lock inventory", zap.Error(err))
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (p Postgres) saveInventory(ctx context.Context, tx *sqlx.Tx, inv *InventoryStoreModel) error {
+	if inv.Quantity <= 0 {
+		stmt := "DELETE FROM " + inventoryTable + " WHERE client_id=$1 AND item_id=$2"
+		if _, err := tx.ExecContext(ctx, stmt, inv.ClientID, inv.ItemID); err != nil {
+			p.logger.Error("saveInventory: failed to delete inventory", zap.Error(err))
+			return err
+		}
+		return nil
+	}
+	stmt := `INSERT INTO inventory (
+	client_id,
+	item_id,
+	quantity,
+	updated_at
+	) VALUES (
+	:client_id,
+	:item_id,
+	:quantity,
+	:updated_at
+	) ON CONFLICT (client_id, item_id) DO UPDATE SET
+	quantity=EXCLUDED.quantity,
+	updated_at=EXCLUDED.updated_at`
+	if _, err := tx.NamedExecContext(ctx, stmt, *inv); err != nil {
+		p.logger.Error("saveInventory: failed to save inventory", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package items
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+// testPostgres connects to the migrated database of PG_TEST_DSN, the test is skipped without it
+func testPostgres(t *testing.T) *sqlx.DB {
+	dsn := os.Getenv("PG_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PG_TEST_DSN is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPostgres_Collect_Concurrent(t *testing.T) {
+	db := testPostgres(t)
+	defer db.Close()
+	ctx := context.TODO()
+	store := NewPostgres(db, zap.NewNop())
+
+	clientID := uuid.New()
+	client := &players.ClientStoreModel{
+		ID:        clientID,
+		Name:      "collector",
+		Handle:    "collector-" + clientID.String()[:8],
+		Email:     clientID.String() + "@example.com",
+		Status:    players.StatusVerified,
+		CreatedAt: time.Now().UTC(),
+	}
+	assert.Nil(t, players.NewPostgres(db, zap.NewNop()).CreateClient(ctx, client))
+	defer db.Exec("DELETE FROM clients WHERE id=$1", clientID)
+	item := &ItemStoreModel{ID: "coin-" + uuid.New().String(), Name: "coin", StackLimit: 1000}
+	assert.Nil(t, store.CreateItem(ctx, item))
+	defer db.Exec("DELETE FROM items WHERE id=$1", item.ID)
+
+	// the player carries no coin yet, every pick up must still add to the same entry
+	const pickUps = 8
+	now := time.Now().UTC()
+	spawns := make([]SpawnStoreModel, pickUps)
+	for i := range spawns {
+		spawns[i] = SpawnStoreModel{
+			ID:        uuid.New(),
+			ItemID:    item.ID,
+			Quantity:  3,
+			Point:     locations.NewPoint(18.07, 59.33),
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Hour),
+		}
+	}
+	assert.Nil(t, store.CreateSpawns(ctx, spawns))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, pickUps)
+	for i := range spawns {
+		wg.Add(1)
+		go func(spawnID string) {
+			defer wg.Done()
+			_, err := store.Collect(ctx, spawnID, clientID.String(), func(spawn *SpawnStoreModel, inv *InventoryStoreModel) error {
+				inv.Quantity += spawn.Quantity
+				inv.UpdatedAt = time.Now().UTC()
+				spawn.CollectedBy.String, spawn.CollectedBy.Valid = clientID.String(), true
+				spawn.CollectedAt.Time, spawn.CollectedAt.Valid = time.Now().UTC(), true
+				return nil
+			})
+			errs <- err
+		}(spawns[i].ID.String())
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+
+	inventory, err := store.ListInventory(ctx, clientID.String())
+	assert.Nil(t, err)
+	assert.Len(t, inventory, 1)
+	assert.Equal(t, pickUps*3, inventory[0].Quantity)
+
+	// a failed change leaves no empty entry behind
+	other := &ItemStoreModel{ID: "gem-" + uuid.New().String(), Name: "gem", StackLimit: 1}
+	assert.Nil(t, store.CreateItem(ctx, other))
+	defer db.Exec("DELETE FROM items WHERE id=$1", other.ID)
+	_, err = store.UpdateInventory(ctx, clientID.String(), other.ID, func(inv *InventoryStoreModel) error {
+		return ErrNotEnoughItems
+	})
+	assert.Equal(t, ErrNotEnoughItems, err)
+	inventory, err = store.ListInventory(ctx, clientID.String())
+	assert.Nil(t, err)
+	assert.Len(t, inventory, 1)
+}
+
+func TestPostgres_Drop_Atomic(t *testing.T) {
+	db := testPostgres(t)
+	defer db.Close()
+	ctx := context.TODO()
+	store := NewPostgres(db, zap.NewNop())
+
+	clientID := uuid.New()
+	client := &players.ClientStoreModel{
+		ID:        clientID,
+		Name:      "dropper",
+		Handle:    "dropper-" + clientID.String()[:8],
+		Email:     clientID.String() + "@example.com",
+		Status:    players.StatusVerified,
+		CreatedAt: time.Now().UTC(),
+	}
+	assert.Nil(t, players.NewPostgres(db, zap.NewNop()).CreateClient(ctx, client))
+	defer db.Exec("DELETE FROM clients WHERE id=$1", clientID)
+	item := &ItemStoreModel{ID: "coin-" + uuid.New().String(), Name: "coin", StackLimit: 10}
+	assert.Nil(t, store.CreateItem(ctx, item))
+	defer db.Exec("DELETE FROM items WHERE id=$1", item.ID)
+	_, err := store.UpdateInventory(ctx, clientID.String(), item.ID, func(inv *InventoryStoreModel) error {
+		inv.Quantity = 5
+		return nil
+	})
+	assert.Nil(t, err)
+
+	now := time.Now().UTC()
+	spawn := SpawnStoreModel{
+		ID:        uuid.New(),
+		ItemID:    item.ID,
+		Quantity:  2,
+		Point:     locations.NewPoint(18.07, 59.33),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	take := func(inv *InventoryStoreModel) error {
+		inv.Quantity -= spawn.Quantity
+		return nil
+	}
+	_, err = store.Drop(ctx, clientID.String(), &spawn, take)
+	assert.Nil(t, err)
+
+	// the spawn can't be created twice, the items stay in the inventory
+	_, err = store.Drop(ctx, clientID.String(), &spawn, take)
+	assert.NotNil(t, err)
+	inventory, err := store.ListInventory(ctx, clientID.String())
+	assert.Nil(t, err)
+	assert.Len(t, inventory, 1)
+	assert.Equal(t, 3, inventory[0].Quantity)
+}
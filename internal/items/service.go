@@ -0,0 +1,603 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+)
+
+const metersPerDegree = 111320.0
+
+var (
+	ErrInvalidItem      = errors.New("invalid item")
+	ErrItemNotFound     = errors.New("item not found")
+	ErrInvalidSpawnRule = errors.New("invalid spawn rule")
+	ErrRuleNotFound     = errors.New("spawn rule not found")
+	ErrSpawnGone        = errors.New("item was already collected or has expired")
+	ErrOutOfRange       = errors.New("player is too far away from the item")
+	ErrNoPosition       = errors.New("player position is unknown, send a location first")
+	ErrStackLimit       = errors.New("stack limit of the item reached")
+	ErrInvalidQuantity  = errors.New("quantity has to be positive")
+	ErrNotEnoughItems   = errors.New("not enough items in the inventory")
+)
+
+type Service interface {
+	CreateItem(ctx context.Context, item ItemStoreModel) error
+	ListItems(ctx context.Context) ([]ItemStoreModel, error)
+	CreateRule(ctx context.Context, payload SpawnRulePayload) (*SpawnRule, error)
+	ListRules(ctx context.Context) ([]SpawnRule, error)
+	DeleteRule(ctx context.Context, id string) error
+	Nearby(ctx context.Context, clientID string) ([]Spawn, error)
+	PickUp(ctx context.Context, clientID, spawnID string) (*InventoryItem, error)
+	Inventory(ctx context.Context, clientID string) ([]InventoryItem, error)
+	Drop(ctx context.Context, clientID, itemID string, quantity int) (*Spawn, error)
+	Consume(ctx context.Context, clientID, itemID string, quantity int) (*InventoryItem, error)
+	Spawn(ctx context.Context) error
+}
+
+// PositionProvider returns the last known position of a player
+type PositionProvider interface {
+	GetLocation(ctx context.Context, clientID string) (*locations.Location, error)
+}
+
+// SpawnListener is notified in-process of the items placed on the map
+type SpawnListener interface {
+	OnSpawn(ctx context.Context, spawns []Spawn) error
+}
+
+// LocationProvider returns the game locations
+type LocationProvider interface {
+	Get(ctx context.Context, id string) (*locations.Location, error)
+}
+
+type Config struct {
+	// PickupRange is the maximum distance in meters between the player and a collected item
+	PickupRange float64 `env:"ITEM_PICKUP_RANGE" envDefault:"50"`
+	// NearbyRadius is the radius in meters of the spawns listed around the player
+	NearbyRadius float64 `env:"ITEM_NEARBY_RADIUS" envDefault:"500"`
+	// SpawnInterval is how often the spawner checks the spawn rules
+	SpawnInterval time.Duration `env:"ITEM_SPAWN_INTERVAL" envDefault:"1m"`
+	// DropLifetime is how long a dropped item stays on the map
+	DropLifetime time.Duration `env:"ITEM_DROP_LIFETIME" envDefault:"1h"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PickupRange:   50,
+		NearbyRadius:  500,
+		SpawnInterval: time.Minute,
+		DropLifetime:  time.Hour,
+	}
+}
+
+type Option func(*DefaultService)
+
+// WithSpawnListener registers a listener notified of the new spawns
+func WithSpawnListener(l SpawnListener) Option {
+	return func(d *DefaultService) {
+		d.listeners = append(d.listeners, l)
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	positions PositionProvider
+	locations LocationProvider
+	config    Config
+	dbTimeOut time.Duration
+	listeners []SpawnListener
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, positions PositionProvider, locations LocationProvider, config Config, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		positions: positions,
+		locations: locations,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+func (d *DefaultService) CreateItem(ctx context.Context, item ItemStoreModel) error {
+	if strings.TrimSpace(item.ID) == "" || strings.TrimSpace(item.Name) == "" {
+		return fmt.Errorf("%w: id and name are required", ErrInvalidItem)
+	}
+	if item.StackLimit <= 0 {
+		return fmt.Errorf("%w: stack limit has to be positive", ErrInvalidItem)
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateItem(dbCtx, &item); err != nil {
+		if err == ErrItemExists {
+			return fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+		}
+		d.logger.Error("CreateItem: failed to create item", zap.String("itemID", item.ID), zap.Error(err))
+		return errors.New("failed to create item:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) ListItems(ctx context.Context) ([]ItemStoreModel, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	res, err := d.store.ListItems(dbCtx)
+	if err != nil {
+		d.logger.Error("ListItems: failed to list items", zap.Error(err))
+		return nil, errors.New("failed to list items:" + err.Error())
+	}
+	return res, nil
+}
+
+// CreateRule validates the rule, a rule spawns either around a location or inside an area
+func (d *DefaultService) CreateRule(ctx context.Context, payload SpawnRulePayload) (*SpawnRule, error) {
+	if err := d.validateRule(ctx, &payload); err != nil {
+		return nil, err
+	}
+	model := SpawnRuleStoreModel{
+		ID:              uuid.New(),
+		ItemID:          payload.ItemID,
+		Radius:          payload.Radius,
+		Area:            payload.Area,
+		MaxActive:       payload.MaxActive,
+		Quantity:        payload.Quantity,
+		IntervalSeconds: payload.IntervalSeconds,
+		LifetimeSeconds: payload.LifetimeSeconds,
+		CreatedAt:       time.Now().UTC(),
+	}
+	if payload.LocationID != "" {
+		model.LocationID = sql.NullString{String: payload.LocationID, Valid: true}
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateRule(dbCtx, &model); err != nil {
+		d.logger.Error("CreateRule: failed to create spawn rule", zap.String("itemID", payload.ItemID), zap.Error(err))
+		return nil, errors.New("failed to create spawn rule:" + err.Error())
+	}
+	r := toSpawnRule(&model)
+	return &r, nil
+}
+
+func (d *DefaultService) validateRule(ctx context.Context, payload *SpawnRulePayload) error {
+	if _, err := d.item(ctx, payload.ItemID); err != nil {
+		if err == ErrItemNotFound {
+			return fmt.Errorf("%w: unknown item %s", ErrInvalidSpawnRule, payload.ItemID)
+		}
+		return err
+	}
+	if (payload.LocationID == "") == (len(payload.Area) == 0) {
+		return fmt.Errorf("%w: either a location or an area is required", ErrInvalidSpawnRule)
+	}
+	if payload.LocationID != "" {
+		if _, err := d.locations.Get(ctx, payload.LocationID); err != nil {
+			if errors.Is(err, locations.ErrNotFound) {
+				return fmt.Errorf("%w: unknown location %s", ErrInvalidSpawnRule, payload.LocationID)
+			}
+			return errors.New("failed to validate spawn rule:" + err.Error())
+		}
+		if payload.Radius < 0 {
+			return fmt.Errorf("%w: negative radius", ErrInvalidSpawnRule)
+		}
+	}
+	if len(payload.Area) > 0 {
+		if payload.Area[0] != payload.Area[len(payload.Area)-1] {
+			payload.Area = append(payload.Area, payload.Area[0])
+		}
+		if len(payload.Area) < 4 {
+			return fmt.Errorf("%w: an area needs at least 3 corners", ErrInvalidSpawnRule)
+		}
+	}
+	if payload.MaxActive <= 0 || payload.Quantity <= 0 {
+		return fmt.Errorf("%w: maxActive and quantity have to be positive", ErrInvalidSpawnRule)
+	}
+	if payload.IntervalSeconds <= 0 || payload.LifetimeSeconds <= 0 {
+		return fmt.Errorf("%w: intervalSeconds and lifetimeSeconds have to be positive", ErrInvalidSpawnRule)
+	}
+	return nil
+}
+
+func (d *DefaultService) ListRules(ctx context.Context) ([]SpawnRule, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListRules(dbCtx)
+	if err != nil {
+		d.logger.Error("ListRules: failed to list spawn rules", zap.Error(err))
+		return nil, errors.New("failed to list spawn rules:" + err.Error())
+	}
+	res := make([]SpawnRule, 0, len(models))
+	for i := range models {
+		res = append(res, toSpawnRule(&models[i]))
+	}
+	return res, nil
+}
+
+func (d *DefaultService) DeleteRule(ctx context.Context, id string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.DeleteRule(dbCtx, id); err != nil {
+		if err == ErrNotFound {
+			return ErrRuleNotFound
+		}
+		d.logger.Error("DeleteRule: failed to delete spawn rule", zap.String("ruleID", id), zap.Error(err))
+		return errors.New("failed to delete spawn rule:" + err.Error())
+	}
+	return nil
+}
+
+// Nearby returns the active spawns around the player, the closest first
+func (d *DefaultService) Nearby(ctx context.Context, clientID string) ([]Spawn, error) {
+	pos, err := d.position(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListSpawnsNear(dbCtx, locations.Point{Point: pos}, d.config.NearbyRadius, time.Now().UTC())
+	if err != nil {
+		d.logger.Error("Nearby: failed to list spawns", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list spawns:" + err.Error())
+	}
+	res := make([]Spawn, 0, len(models))
+	for i := range models {
+		res = append(res, toSpawn(&models[i], pos))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Distance < res[j].Distance
+	})
+	return res, nil
+}
+
+// PickUp moves the spawn into the inventory, the spawn is locked so it is collected at most once
+func (d *DefaultService) PickUp(ctx context.Context, clientID, spawnID string) (*InventoryItem, error) {
+	pos, err := d.position(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	// the catalog is read up front, the store must not be used again while the spawn is locked
+	catalog, err := d.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	var item *ItemStoreModel
+	inv, err := d.store.Collect(dbCtx, spawnID, clientID, func(spawn *SpawnStoreModel, inv *InventoryStoreModel) error {
+		now := time.Now().UTC()
+		if !active(spawn, now) {
+			return ErrSpawnGone
+		}
+		if geo.Distance(pos, spawn.Point.Point) > d.config.PickupRange {
+			return ErrOutOfRange
+		}
+		if item = catalog[spawn.ItemID]; item == nil {
+			return ErrItemNotFound
+		}
+		if inv.Quantity+spawn.Quantity > item.StackLimit {
+			return ErrStackLimit
+		}
+		inv.Quantity += spawn.Quantity
+		inv.UpdatedAt = now
+		spawn.CollectedBy = sql.NullString{String: clientID, Valid: true}
+		spawn.CollectedAt = sql.NullTime{Time: now, Valid: true}
+		return nil
+	})
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			return nil, ErrSpawnGone
+		case ErrSpawnGone, ErrOutOfRange, ErrStackLimit, ErrItemNotFound:
+			return nil, err
+		}
+		d.logger.Error("PickUp: failed to collect spawn", zap.String("clientID", clientID), zap.String("spawnID", spawnID), zap.Error(err))
+		return nil, errors.New("failed to pick up item:" + err.Error())
+	}
+	return toInventoryItem(inv, item), nil
+}
+
+func (d *DefaultService) Inventory(ctx context.Context, clientID string) ([]InventoryItem, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("Inventory: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListInventory(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("Inventory: failed to list inventory", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list inventory:" + err.Error())
+	}
+	catalog, err := d.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]InventoryItem, 0, len(models))
+	for i := range models {
+		res = append(res, *toInventoryItem(&models[i], catalog[models[i].ItemID]))
+	}
+	return res, nil
+}
+
+// Drop removes items from the inventory and places them at the position of the player,
+// the items are only removed together with the creation of the spawn
+func (d *DefaultService) Drop(ctx context.Context, clientID, itemID string, quantity int) (*Spawn, error) {
+	if err := d.checkTake("Drop", clientID, quantity); err != nil {
+		return nil, err
+	}
+	pos, err := d.position(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	spawn := SpawnStoreModel{
+		ID:        uuid.New(),
+		ItemID:    itemID,
+		Quantity:  quantity,
+		Point:     locations.Point{Point: pos},
+		CreatedAt: now,
+		ExpiresAt: now.Add(d.config.DropLifetime),
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if _, err := d.store.Drop(dbCtx, clientID, &spawn, takeQuantity(quantity)); err != nil {
+		if err == ErrNotEnoughItems {
+			return nil, err
+		}
+		d.logger.Error("Drop: failed to drop item", zap.String("clientID", clientID), zap.String("itemID", itemID), zap.Error(err))
+		return nil, errors.New("failed to drop item:" + err.Error())
+	}
+	res := toSpawn(&spawn, pos)
+	d.notify(ctx, []Spawn{res})
+	return &res, nil
+}
+
+// Consume uses up items of the inventory
+func (d *DefaultService) Consume(ctx context.Context, clientID, itemID string, quantity int) (*InventoryItem, error) {
+	inv, err := d.take(ctx, "Consume", clientID, itemID, quantity)
+	if err != nil {
+		return nil, err
+	}
+	item, err := d.item(ctx, itemID)
+	if err != nil && err != ErrItemNotFound {
+		return nil, err
+	}
+	return toInventoryItem(inv, item), nil
+}
+
+// take removes the quantity from the locked inventory entry
+func (d *DefaultService) take(ctx context.Context, method, clientID, itemID string, quantity int) (*InventoryStoreModel, error) {
+	if err := d.checkTake(method, clientID, quantity); err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	inv, err := d.store.UpdateInventory(dbCtx, clientID, itemID, takeQuantity(quantity))
+	if err != nil {
+		if err == ErrNotEnoughItems {
+			return nil, err
+		}
+		d.logger.Error(method+": failed to update inventory", zap.String("clientID", clientID), zap.String("itemID", itemID), zap.Error(err))
+		return nil, errors.New("failed to update inventory:" + err.Error())
+	}
+	return inv, nil
+}
+
+func (d *DefaultService) checkTake(method, clientID string, quantity int) error {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error(method+": failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return err
+	}
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	return nil
+}
+
+// takeQuantity removes the quantity from the inventory entry, it fails when the player carries less
+func takeQuantity(quantity int) InventoryFunc {
+	return func(inv *InventoryStoreModel) error {
+		if inv.Quantity < quantity {
+			return ErrNotEnoughItems
+		}
+		inv.Quantity -= quantity
+		inv.UpdatedAt = time.Now().UTC()
+		return nil
+	}
+}
+
+// Spawn runs every due spawn rule and removes the expired spawns, it is the task of the spawner worker
+func (d *DefaultService) Spawn(ctx context.Context) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	now := time.Now().UTC()
+	if _, err := d.store.DeleteExpiredSpawns(dbCtx, now); err != nil {
+		d.logger.Error("Spawn: failed to delete expired spawns", zap.Error(err))
+	}
+	rules, err := d.store.ListRules(dbCtx)
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := d.runRule(ctx, &rules[i], now); err != nil {
+			d.logger.Error("Spawn: failed to run spawn rule", zap.String("ruleID", rules[i].ID.String()), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// runRule tops the active spawns of the rule up to its maximum once per interval
+func (d *DefaultService) runRule(ctx context.Context, rule *SpawnRuleStoreModel, now time.Time) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	claimed, err := d.store.ClaimRule(dbCtx, rule.ID.String(), now)
+	if err != nil || !claimed {
+		return err
+	}
+	count, err := d.store.CountActiveSpawns(dbCtx, rule.ID.String(), now)
+	if err != nil {
+		return err
+	}
+	if count >= rule.MaxActive {
+		return nil
+	}
+	var place func() orb.Point
+	if rule.LocationID.Valid {
+		loc, err := d.locations.Get(ctx, rule.LocationID.String)
+		if err != nil {
+			return err
+		}
+		center := orb.Point{loc.GeoPoint.Longitude, loc.GeoPoint.Latitude}
+		place = func() orb.Point {
+			return randomAround(center, rule.Radius)
+		}
+	} else {
+		ring := toRing(rule.Area)
+		place = func() orb.Point {
+			return randomInside(ring)
+		}
+	}
+	spawns := make([]SpawnStoreModel, 0, rule.MaxActive-count)
+	for i := count; i < rule.MaxActive; i++ {
+		spawns = append(spawns, SpawnStoreModel{
+			ID:        uuid.New(),
+			RuleID:    sql.NullString{String: rule.ID.String(), Valid: true},
+			ItemID:    rule.ItemID,
+			Quantity:  rule.Quantity,
+			Point:     locations.Point{Point: place()},
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Duration(rule.LifetimeSeconds) * time.Second),
+		})
+	}
+	if err := d.store.CreateSpawns(dbCtx, spawns); err != nil {
+		return err
+	}
+	res := make([]Spawn, 0, len(spawns))
+	for i := range spawns {
+		res = append(res, toSpawn(&spawns[i], spawns[i].Point.Point))
+	}
+	d.notify(ctx, res)
+	return nil
+}
+
+// notify hands the new spawns to the listeners, the spawns are stored so failures are only logged
+func (d *DefaultService) notify(ctx context.Context, spawns []Spawn) {
+	for _, l := range d.listeners {
+		if err := l.OnSpawn(ctx, spawns); err != nil {
+			d.logger.Error("notify: spawn listener failed", zap.Int("spawns", len(spawns)), zap.Error(err))
+		}
+	}
+}
+
+func (d *DefaultService) item(ctx context.Context, id string) (*ItemStoreModel, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	item, err := d.store.GetItem(dbCtx, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrItemNotFound
+		}
+		d.logger.Error("item: failed to get item", zap.String("itemID", id), zap.Error(err))
+		return nil, errors.New("failed to get item:" + err.Error())
+	}
+	return item, nil
+}
+
+// catalog returns the items by id
+func (d *DefaultService) catalog(ctx context.Context) (map[string]*ItemStoreModel, error) {
+	items, err := d.ListItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*ItemStoreModel, len(items))
+	for i := range items {
+		res[items[i].ID] = &items[i]
+	}
+	return res, nil
+}
+
+func (d *DefaultService) position(ctx context.Context, clientID string) (orb.Point, error) {
+	loc, err := d.positions.GetLocation(ctx, clientID)
+	if err != nil {
+		d.logger.Error("position: failed to get player position", zap.String("clientID", clientID), zap.Error(err))
+		return orb.Point{}, errors.New("failed to get player position:" + err.Error())
+	}
+	if loc.GeoPoint.Longitude == 0 && loc.GeoPoint.Latitude == 0 {
+		return orb.Point{}, ErrNoPosition
+	}
+	return orb.Point{loc.GeoPoint.Longitude, loc.GeoPoint.Latitude}, nil
+}
+
+// randomAround returns a uniformly distributed point within the radius in meters
+func randomAround(center orb.Point, meters float64) orb.Point {
+	distance := meters * math.Sqrt(rand.Float64())
+	bearing := 2 * math.Pi * rand.Float64()
+	lat := center.Lat() + distance*math.Cos(bearing)/metersPerDegree
+	lon := center.Lon() + distance*math.Sin(bearing)/(metersPerDegree*math.Max(math.Cos(center.Lat()*math.Pi/180), 0.01))
+	return orb.Point{lon, lat}
+}
+
+// randomInside samples the bound of the ring until a point inside the ring is found
+func randomInside(ring orb.Ring) orb.Point {
+	bound := ring.Bound()
+	for i := 0; i < 100; i++ {
+		p := orb.Point{
+			bound.Min.Lon() + rand.Float64()*(bound.Max.Lon()-bound.Min.Lon()),
+			bound.Min.Lat() + rand.Float64()*(bound.Max.Lat()-bound.Min.Lat()),
+		}
+		if planar.RingContains(ring, p) {
+			return p
+		}
+	}
+	return bound.Center()
+}
+
+func toRing(area Area) orb.Ring {
+	ring := make(orb.Ring, 0, len(area))
+	for _, c := range area {
+		ring = append(ring, orb.Point{c[0], c[1]})
+	}
+	return ring
+}
+
+func toSpawn(m *SpawnStoreModel, from orb.Point) Spawn {
+	return Spawn{
+		ID:       m.ID.String(),
+		ItemID:   m.ItemID,
+		Quantity: m.Quantity,
+		GeoPoint: locations.GeoPoint{
+			Longitude: m.Point.Lon(),
+			Latitude:  m.Point.Lat(),
+		},
+		ExpiresAt: m.ExpiresAt,
+		Distance:  math.Round(geo.Distance(from, m.Point.Point)),
+	}
+}
+
+func toInventoryItem(m *InventoryStoreModel, item *ItemStoreModel) *InventoryItem {
+	res := &InventoryItem{ItemID: m.ItemID, Quantity: m.Quantity}
+	if item != nil {
+		res.Name = item.Name
+		res.StackLimit = item.StackLimit
+	}
+	return res
+}
@@ -0,0 +1,181 @@
+package items
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+)
+
+type positionFunc func(clientID string) (*locations.Location, error)
+
+func (f positionFunc) GetLocation(ctx context.Context, clientID string) (*locations.Location, error) {
+	return f(clientID)
+}
+
+var stockholm = locations.GeoPoint{Longitude: 18.0686, Latitude: 59.3293}
+
+func newTestService(t *testing.T, position *locations.GeoPoint) (*DefaultService, *MemStore) {
+	locSvc := locations.NewDefaultService(zap.NewNop(), locations.NewMemStore(make(map[interface{}]locations.LocationStoreModel)))
+	assert.Nil(t, locSvc.Create(context.TODO(), locations.Location{
+		ID:       "1",
+		GeoPoint: stockholm,
+		MetaData: locations.MetaData{LocationName: "Stockholm", LocationType: "city"},
+	}))
+	positions := positionFunc(func(clientID string) (*locations.Location, error) {
+		return &locations.Location{GeoPoint: *position}, nil
+	})
+	store := NewMemStore()
+	d := NewDefaultService(zap.NewNop(), store, positions, locSvc, DefaultConfig(), time.Second)
+	assert.Nil(t, d.CreateItem(context.TODO(), ItemStoreModel{ID: "gem", Name: "Gem", StackLimit: 3}))
+	return d, store
+}
+
+func TestDefaultService_CreateRule(t *testing.T) {
+	position := stockholm
+	d, _ := newTestService(t, &position)
+	area := Area{{18.0, 59.3}, {18.1, 59.3}, {18.1, 59.4}}
+
+	tests := []struct {
+		name    string
+		payload SpawnRulePayload
+		wantErr bool
+	}{
+		{name: "location", payload: SpawnRulePayload{ItemID: "gem", LocationID: "1", Radius: 50, MaxActive: 2, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600}},
+		{name: "area", payload: SpawnRulePayload{ItemID: "gem", Area: area, MaxActive: 2, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600}},
+		{name: "unknown item", payload: SpawnRulePayload{ItemID: "coin", LocationID: "1", MaxActive: 2, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600}, wantErr: true},
+		{name: "unknown location", payload: SpawnRulePayload{ItemID: "gem", LocationID: "2", MaxActive: 2, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600}, wantErr: true},
+		{name: "location and area", payload: SpawnRulePayload{ItemID: "gem", LocationID: "1", Area: area, MaxActive: 2, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600}, wantErr: true},
+		{name: "small area", payload: SpawnRulePayload{ItemID: "gem", Area: area[:2], MaxActive: 2, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600}, wantErr: true},
+		{name: "no schedule", payload: SpawnRulePayload{ItemID: "gem", LocationID: "1", MaxActive: 2, Quantity: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.CreateRule(context.TODO(), tt.payload)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestDefaultService_Spawn(t *testing.T) {
+	position := stockholm
+	d, _ := newTestService(t, &position)
+	_, err := d.CreateRule(context.TODO(), SpawnRulePayload{ItemID: "gem", LocationID: "1", Radius: 40, MaxActive: 3, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600})
+	assert.Nil(t, err)
+	_, err = d.CreateRule(context.TODO(), SpawnRulePayload{ItemID: "gem", Area: Area{{18.0, 59.3}, {18.1, 59.3}, {18.1, 59.4}, {18.0, 59.4}}, MaxActive: 2, Quantity: 1, IntervalSeconds: 60, LifetimeSeconds: 600})
+	assert.Nil(t, err)
+
+	assert.Nil(t, d.Spawn(context.TODO()))
+	// the interval of the rules has not passed, nothing is added
+	assert.Nil(t, d.Spawn(context.TODO()))
+
+	near, err := d.Nearby(context.TODO(), uuid.New().String())
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, len(near), 3)
+	assert.LessOrEqual(t, len(near), 5)
+	for _, s := range near {
+		assert.LessOrEqual(t, s.Distance, DefaultConfig().NearbyRadius)
+	}
+}
+
+func TestDefaultService_PickUp(t *testing.T) {
+	position := stockholm
+	d, store := newTestService(t, &position)
+	now := time.Now().UTC()
+	spawn := func(quantity int, point locations.GeoPoint, expiresAt time.Time) string {
+		s := SpawnStoreModel{
+			ID:        uuid.New(),
+			ItemID:    "gem",
+			Quantity:  quantity,
+			Point:     locations.NewPoint(point.Longitude, point.Latitude),
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		}
+		assert.Nil(t, store.CreateSpawns(context.TODO(), []SpawnStoreModel{s}))
+		return s.ID.String()
+	}
+	clientID := uuid.New().String()
+
+	_, err := d.PickUp(context.TODO(), clientID, spawn(1, stockholm, now.Add(-time.Second)))
+	assert.Equal(t, ErrSpawnGone, err)
+	_, err = d.PickUp(context.TODO(), clientID, spawn(1, locations.GeoPoint{Longitude: 18.08, Latitude: 59.33}, now.Add(time.Hour)))
+	assert.Equal(t, ErrOutOfRange, err)
+
+	// concurrent pick ups of one spawn, only one of them succeeds
+	id := spawn(2, stockholm, now.Add(time.Hour))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	collected := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.PickUp(context.TODO(), clientID, id); err == nil {
+				mu.Lock()
+				collected++
+				mu.Unlock()
+			} else {
+				assert.Equal(t, ErrSpawnGone, err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, collected)
+
+	_, err = d.PickUp(context.TODO(), clientID, spawn(2, stockholm, now.Add(time.Hour)))
+	assert.Equal(t, ErrStackLimit, err)
+
+	inventory, err := d.Inventory(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Equal(t, []InventoryItem{{ItemID: "gem", Name: "Gem", Quantity: 2, StackLimit: 3}}, inventory)
+}
+
+func TestDefaultService_DropConsume(t *testing.T) {
+	position := stockholm
+	d, store := newTestService(t, &position)
+	clientID := uuid.New().String()
+	_, err := store.UpdateInventory(context.TODO(), clientID, "gem", func(inv *InventoryStoreModel) error {
+		inv.Quantity = 3
+		return nil
+	})
+	assert.Nil(t, err)
+
+	_, err = d.Consume(context.TODO(), clientID, "gem", 0)
+	assert.Equal(t, ErrInvalidQuantity, err)
+	_, err = d.Consume(context.TODO(), clientID, "gem", 4)
+	assert.Equal(t, ErrNotEnoughItems, err)
+
+	inv, err := d.Consume(context.TODO(), clientID, "gem", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, inv.Quantity)
+
+	// a drop of more than the player carries places nothing on the map
+	_, err = d.Drop(context.TODO(), clientID, "gem", 3)
+	assert.Equal(t, ErrNotEnoughItems, err)
+	near, err := store.ListSpawnsNear(context.TODO(), locations.NewPoint(stockholm.Longitude, stockholm.Latitude), 10, time.Now())
+	assert.Nil(t, err)
+	assert.Empty(t, near)
+
+	dropped, err := d.Drop(context.TODO(), clientID, "gem", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, dropped.Quantity)
+
+	inventory, err := d.Inventory(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Empty(t, inventory)
+
+	// a dropped item can be picked up again
+	inv, err = d.PickUp(context.TODO(), uuid.New().String(), dropped.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, inv.Quantity)
+}
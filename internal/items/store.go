@@ -0,0 +1,46 @@
+package items
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"geogame/internal/locations"
+)
+
+var (
+	// ErrNotFound is returned by the stores when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrItemExists is returned when an item id is already in the catalog
+	ErrItemExists = errors.New("item already exists")
+)
+
+// CollectFunc validates the locked spawn and changes the locked inventory entry of the player,
+// the inventory entry has a zero quantity when the player does not carry the item yet
+type CollectFunc func(spawn *SpawnStoreModel, inventory *InventoryStoreModel) error
+
+// InventoryFunc changes the locked inventory entry, it is deleted when the quantity drops to 0
+type InventoryFunc func(inventory *InventoryStoreModel) error
+
+type Store interface {
+	CreateItem(ctx context.Context, model *ItemStoreModel) error
+	GetItem(ctx context.Context, id string) (*ItemStoreModel, error)
+	ListItems(ctx context.Context) ([]ItemStoreModel, error)
+	CreateRule(ctx context.Context, model *SpawnRuleStoreModel) error
+	ListRules(ctx context.Context) ([]SpawnRuleStoreModel, error)
+	DeleteRule(ctx context.Context, id string) error
+	// ClaimRule sets the last spawn time when the interval of the rule has passed,
+	// it returns false when another spawner claimed the rule first
+	ClaimRule(ctx context.Context, id string, now time.Time) (bool, error)
+	CreateSpawns(ctx context.Context, models []SpawnStoreModel) error
+	CountActiveSpawns(ctx context.Context, ruleID string, now time.Time) (int, error)
+	ListSpawnsNear(ctx context.Context, point locations.Point, radius float64, now time.Time) ([]SpawnStoreModel, error)
+	DeleteExpiredSpawns(ctx context.Context, before time.Time) (int64, error)
+	// Collect runs fn on the locked spawn and inventory entry, concurrent pick ups of a spawn are serialized
+	Collect(ctx context.Context, spawnID, clientID string, fn CollectFunc) (*InventoryStoreModel, error)
+	ListInventory(ctx context.Context, clientID string) ([]InventoryStoreModel, error)
+	UpdateInventory(ctx context.Context, clientID, itemID string, fn InventoryFunc) (*InventoryStoreModel, error)
+	// Drop runs fn on the locked inventory entry of the item of the spawn and creates the spawn
+	// in the same transaction, nothing is changed when either fails
+	Drop(ctx context.Context, clientID string, spawn *SpawnStoreModel, fn InventoryFunc) (*InventoryStoreModel, error)
+}
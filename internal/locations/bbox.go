@@ -0,0 +1,51 @@
+package locations
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// maxBBoxSpan limits the bounding box queries to an area of a few hundred kilometers
+const maxBBoxSpan = 5.0
+
+var (
+	ErrInvalidBBox  = errors.New("invalid bbox, expected minLon,minLat,maxLon,maxLat")
+	ErrBBoxTooLarge = errors.New("bbox is too large")
+)
+
+// BBox is a bounding box in degrees
+type BBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// ParseBBox parses the "minLon,minLat,maxLon,maxLat" query param
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, ErrInvalidBBox
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return BBox{}, ErrInvalidBBox
+		}
+		v[i] = f
+	}
+	b := BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat || b.MinLon < -180 || b.MaxLon > 180 || b.MinLat < -90 || b.MaxLat > 90 {
+		return BBox{}, ErrInvalidBBox
+	}
+	if b.MaxLon-b.MinLon > maxBBoxSpan || b.MaxLat-b.MinLat > maxBBoxSpan {
+		return BBox{}, ErrBBoxTooLarge
+	}
+	return b, nil
+}
+
+func (b BBox) Contains(p Point) bool {
+	return p.Lon() >= b.MinLon && p.Lon() <= b.MaxLon && p.Lat() >= b.MinLat && p.Lat() <= b.MaxLat
+}
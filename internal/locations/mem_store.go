@@ -25,7 +25,10 @@ func (m *MemStore) Update(ctx context.Context, id string, location LocationStore
 }
 
 func (m *MemStore) Get(ctx context.Context, id string) (*LocationStoreModel, error) {
-	l := m.locationMap[id]
+	l, ok := m.locationMap[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
 	return &l, nil
 }
 
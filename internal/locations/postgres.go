@@ -65,7 +65,7 @@ func (p Postgres) Get(ctx context.Context, id string) (*LocationStoreModel, erro
 	if err := p.db.GetContext(ctx, &c, stmt, id); err != nil {
 		if err == sql.ErrNoRows {
 			p.logger.Error("Get: location is not found for the provided id", zap.Error(err))
-			return nil, err
+			return nil, ErrNotFound
 		}
 		p.logger.Error("Get: failed to get location by id from db", zap.Error(err))
 		return nil, err
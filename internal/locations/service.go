@@ -11,6 +11,7 @@ type Service interface {
 	Update(ctx context.Context, location Location) error
 	Get(ctx context.Context, id string) (*Location, error)
 	Delete(ctx context.Context, id string) error
+	Import(ctx context.Context, locations []Location) (int, error)
 }
 
 var _ Service = (*DefaultService)(nil)
@@ -87,3 +88,15 @@ func (d *DefaultService) Delete(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// Import creates the locations in order and stops at the first failure,
+// returning how many were created before it
+func (d *DefaultService) Import(ctx context.Context, locations []Location) (int, error) {
+	for i, location := range locations {
+		if err := d.Create(ctx, location); err != nil {
+			d.logger.Error("Import: failed to import location", zap.Int("index", i), zap.Error(err))
+			return i, err
+		}
+	}
+	return len(locations), nil
+}
@@ -141,3 +141,37 @@ func TestDefaultService_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultService_Import(t *testing.T) {
+	failing := errors.New("failed to insert")
+	tests := []struct {
+		name         string
+		failAt       string
+		wantImported int
+		want         error
+	}{
+		{name: "success", wantImported: 3},
+		{name: "stops at the first failure", failAt: "2", wantImported: 1, want: failing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []string
+			d := &DefaultService{
+				logger: zap.NewNop(),
+				store: &MockStore{
+					CreateFunc: func(location LocationStoreModel) error {
+						if location.ID == tt.failAt {
+							return failing
+						}
+						created = append(created, location.ID)
+						return nil
+					},
+				},
+			}
+			imported, err := d.Import(context.TODO(), []Location{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+			assert.Equal(t, tt.want, err)
+			assert.Equal(t, tt.wantImported, imported)
+			assert.Equal(t, tt.wantImported, len(created))
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package locations
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by the stores when the location does not exist
+var ErrNotFound = errors.New("location not found")
 
 type Store interface {
 	Create(ctx context.Context, location LocationStoreModel) error
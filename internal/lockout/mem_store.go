@@ -0,0 +1,74 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu         sync.Mutex
+	attemptMap map[string]*AttemptStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		attemptMap: make(map[string]*AttemptStoreModel),
+	}
+}
+
+func (m *MemStore) Get(ctx context.Context, key string) (*AttemptStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attemptMap[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *a
+	return &c, nil
+}
+
+func (m *MemStore) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (*AttemptStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attemptMap[key]
+	if !ok || a.LastFailure.Before(windowStart) {
+		a = &AttemptStoreModel{Key: key}
+		m.attemptMap[key] = a
+	}
+	a.Failures++
+	a.LastFailure = now
+	c := *a
+	return &c, nil
+}
+
+func (m *MemStore) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.attemptMap[key]; ok && until.After(a.LockedUntil) {
+		a.LockedUntil = until
+	}
+	return nil
+}
+
+func (m *MemStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attemptMap, key)
+	return nil
+}
+
+func (m *MemStore) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for key, a := range m.attemptMap {
+		if a.LastFailure.Before(before) && a.LockedUntil.Before(before) {
+			delete(m.attemptMap, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,56 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	GetFunc           func(key string) (*AttemptStoreModel, error)
+	RecordFailureFunc func(key string, now, windowStart time.Time) (*AttemptStoreModel, error)
+	LockFunc          func(key string, until time.Time) error
+	ResetFunc         func(key string) error
+	DeleteStaleFunc   func(before time.Time) (int64, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		GetFunc: func(key string) (*AttemptStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		RecordFailureFunc: func(key string, now, windowStart time.Time) (*AttemptStoreModel, error) {
+			return &AttemptStoreModel{Key: key, Failures: 1, LastFailure: now}, nil
+		},
+		LockFunc: func(key string, until time.Time) error {
+			return nil
+		},
+		ResetFunc: func(key string) error {
+			return nil
+		},
+		DeleteStaleFunc: func(before time.Time) (int64, error) {
+			return 0, nil
+		},
+	}
+}
+
+func (m *MockStore) Get(ctx context.Context, key string) (*AttemptStoreModel, error) {
+	return m.GetFunc(key)
+}
+
+func (m *MockStore) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (*AttemptStoreModel, error) {
+	return m.RecordFailureFunc(key, now, windowStart)
+}
+
+func (m *MockStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return m.LockFunc(key, until)
+}
+
+func (m *MockStore) Reset(ctx context.Context, key string) error {
+	return m.ResetFunc(key)
+}
+
+func (m *MockStore) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	return m.DeleteStaleFunc(before)
+}
@@ -0,0 +1,44 @@
+package lockout
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AttemptStoreModel struct {
+	Key         string    `db:"key"`
+	Failures    int       `db:"failures"`
+	LastFailure time.Time `db:"last_failure"`
+	LockedUntil time.Time `db:"locked_until"`
+}
+
+const (
+	emailPrefix = "email:"
+	adminPrefix = "admin:"
+	ipPrefix    = "ip:"
+)
+
+// EmailKey is the counter key of a login email, emails are compared case-insensitively
+func EmailKey(email string) string {
+	return emailPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+// AdminKey is the counter key of an admin login email, kept apart from the players using the same email
+func AdminKey(email string) string {
+	return adminPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey is the counter key of a client address
+func IPKey(ip string) string {
+	return ipPrefix + ip
+}
+
+// LockedError is returned while a key has to wait before the next attempt
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed attempts, retry in %d seconds", int(e.RetryAfter.Seconds()+0.5))
+}
@@ -0,0 +1,98 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	attemptsAllCols = "key, failures, last_failure, locked_until"
+	attemptsTable   = "login_attempts"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.lockout.store"),
+	}
+}
+
+func (p Postgres) Get(ctx context.Context, key string) (*AttemptStoreModel, error) {
+	stmt := "SELECT " + attemptsAllCols + " FROM " + attemptsTable + " WHERE key=$1"
+	var a AttemptStoreModel
+	if err := p.db.GetContext(ctx, &a, stmt, key); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("Get: failed to get attempts from db", zap.Error(err))
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RecordFailure increments the counter atomically so concurrent instances never lose a failure
+func (p Postgres) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (*AttemptStoreModel, error) {
+	stmt := `INSERT INTO login_attempts (
+	key,
+	failures,
+	last_failure,
+	locked_until
+	) VALUES (
+	$1, 1, $2, 'epoch'
+	) ON CONFLICT (key) DO UPDATE SET
+	failures=CASE WHEN login_attempts.last_failure < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+	last_failure=EXCLUDED.last_failure
+	RETURNING ` + attemptsAllCols
+	var a AttemptStoreModel
+	if err := p.db.GetContext(ctx, &a, stmt, key, now, windowStart); err != nil {
+		p.logger.Error("RecordFailure: failed to record failure to db", zap.Error(err))
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (p Postgres) Lock(ctx context.Context, key string, until time.Time) error {
+	stmt := `UPDATE login_attempts SET
+	locked_until=GREATEST(locked_until, $1)
+	WHERE key=$2
+	`
+	_, err := p.db.ExecContext(ctx, stmt, until, key)
+	if err != nil {
+		p.logger.Error("Lock: failed to lock key in db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) Reset(ctx context.Context, key string) error {
+	stmt := `DELETE FROM login_attempts
+	WHERE key=$1`
+	_, err := p.db.ExecContext(ctx, stmt, key)
+	if err != nil {
+		p.logger.Error("Reset: failed to reset attempts in db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	stmt := `DELETE FROM login_attempts
+	WHERE last_failure < $1 AND locked_until < $1`
+	res, err := p.db.ExecContext(ctx, stmt, before)
+	if err != nil {
+		p.logger.Error("DeleteStale: failed to delete stale attempts from db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,148 @@
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Service interface {
+	// Check returns a LockedError when one of the keys has to wait before the next attempt
+	Check(ctx context.Context, keys ...string) error
+	Fail(ctx context.Context, keys ...string) error
+	Reset(ctx context.Context, keys ...string) error
+	Cleanup(ctx context.Context) (int64, error)
+}
+
+// Config is the backoff policy, per-IP limits are higher since many players can share an address
+type Config struct {
+	EmailFreeAttempts     int           `env:"LOGIN_EMAIL_FREE_ATTEMPTS" envDefault:"3"`
+	EmailLockoutThreshold int           `env:"LOGIN_EMAIL_LOCKOUT_THRESHOLD" envDefault:"10"`
+	IPFreeAttempts        int           `env:"LOGIN_IP_FREE_ATTEMPTS" envDefault:"20"`
+	IPLockoutThreshold    int           `env:"LOGIN_IP_LOCKOUT_THRESHOLD" envDefault:"100"`
+	BaseDelay             time.Duration `env:"LOGIN_BACKOFF_BASE" envDefault:"1s"`
+	MaxDelay              time.Duration `env:"LOGIN_BACKOFF_MAX" envDefault:"5m"`
+	LockoutDuration       time.Duration `env:"LOGIN_LOCKOUT_DURATION" envDefault:"1h"`
+	// Window is the time without failures after which the counters start over
+	Window time.Duration `env:"LOGIN_ATTEMPT_WINDOW" envDefault:"24h"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		EmailFreeAttempts:     3,
+		EmailLockoutThreshold: 10,
+		IPFreeAttempts:        20,
+		IPLockoutThreshold:    100,
+		BaseDelay:             time.Second,
+		MaxDelay:              time.Minute * 5,
+		LockoutDuration:       time.Hour,
+		Window:                time.Hour * 24,
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	config    Config
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, config Config, dbTimeOut time.Duration) *DefaultService {
+	return &DefaultService{
+		logger:    logger,
+		store:     store,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+}
+
+func (d *DefaultService) Check(ctx context.Context, keys ...string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		a, err := d.store.Get(dbCtx, key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			d.logger.Error("Check: failed to get attempts", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		if w := a.LockedUntil.Sub(now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (d *DefaultService) Fail(ctx context.Context, keys ...string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	now := time.Now().UTC()
+	for _, key := range keys {
+		a, err := d.store.RecordFailure(dbCtx, key, now, now.Add(-d.config.Window))
+		if err != nil {
+			d.logger.Error("Fail: failed to record failure", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		delay := d.delay(key, a.Failures)
+		if delay == 0 {
+			continue
+		}
+		if err := d.store.Lock(dbCtx, key, now.Add(delay)); err != nil {
+			d.logger.Error("Fail: failed to lock key", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		d.logger.Warn("Fail: key is throttled", zap.String("key", key), zap.Int("failures", a.Failures), zap.Duration("delay", delay))
+	}
+	return nil
+}
+
+func (d *DefaultService) Reset(ctx context.Context, keys ...string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	for _, key := range keys {
+		if err := d.store.Reset(dbCtx, key); err != nil {
+			d.logger.Error("Reset: failed to reset attempts", zap.String("key", key), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DefaultService) Cleanup(ctx context.Context) (int64, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	return d.store.DeleteStale(dbCtx, time.Now().Add(-d.config.Window))
+}
+
+// delay grows exponentially once the free attempts are used up and turns into a lockout at the threshold
+func (d *DefaultService) delay(key string, failures int) time.Duration {
+	free, threshold := d.config.EmailFreeAttempts, d.config.EmailLockoutThreshold
+	if strings.HasPrefix(key, ipPrefix) {
+		free, threshold = d.config.IPFreeAttempts, d.config.IPLockoutThreshold
+	}
+	if failures >= threshold {
+		return d.config.LockoutDuration
+	}
+	if failures <= free {
+		return 0
+	}
+	delay := d.config.BaseDelay
+	for i := free + 1; i < failures && delay < d.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.config.MaxDelay {
+		delay = d.config.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,55 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestDefaultService_Fail(t *testing.T) {
+	config := DefaultConfig()
+	config.EmailFreeAttempts = 2
+	config.EmailLockoutThreshold = 5
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), config, time.Second)
+	key := EmailKey("Dummy@mail.com")
+
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, d.Fail(context.TODO(), key))
+		assert.Nil(t, d.Check(context.TODO(), key))
+	}
+
+	assert.Nil(t, d.Fail(context.TODO(), key))
+	err := d.Check(context.TODO(), EmailKey("dummy@mail.com"))
+	locked, ok := err.(*LockedError)
+	assert.True(t, ok)
+	assert.True(t, locked.RetryAfter <= config.BaseDelay)
+
+	assert.Nil(t, d.Reset(context.TODO(), key))
+	assert.Nil(t, d.Check(context.TODO(), key))
+}
+
+func TestDefaultService_delay(t *testing.T) {
+	config := DefaultConfig()
+	d := NewDefaultService(zap.NewNop(), NewMockStore(), config, time.Second)
+	tests := []struct {
+		name     string
+		key      string
+		failures int
+		want     time.Duration
+	}{
+		{name: "free attempt", key: EmailKey("a"), failures: 3, want: 0},
+		{name: "first backoff", key: EmailKey("a"), failures: 4, want: time.Second},
+		{name: "exponential backoff", key: EmailKey("a"), failures: 6, want: time.Second * 4},
+		{name: "lockout", key: EmailKey("a"), failures: 10, want: time.Hour},
+		{name: "ip free attempt", key: IPKey("127.0.0.1"), failures: 10, want: 0},
+		{name: "ip capped backoff", key: IPKey("127.0.0.1"), failures: 99, want: time.Minute * 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, d.delay(tt.key, tt.failures))
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by the stores when no attempts are recorded for the key
+var ErrNotFound = errors.New("record not found")
+
+type Store interface {
+	Get(ctx context.Context, key string) (*AttemptStoreModel, error)
+	// RecordFailure counts a failure, counters whose last failure is before windowStart start over
+	RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (*AttemptStoreModel, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	DeleteStale(ctx context.Context, before time.Time) (int64, error)
+}
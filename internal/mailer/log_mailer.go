@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+var _ Mailer = (*LogMailer)(nil)
+
+// LogMailer only logs the emails, it is meant for local development
+type LogMailer struct {
+	logger *zap.Logger
+}
+
+func NewLogMailer(logger *zap.Logger) *LogMailer {
+	return &LogMailer{
+		logger: logger.Named("geo-game.mailer"),
+	}
+}
+
+func (l *LogMailer) Send(ctx context.Context, msg Message) error {
+	l.logger.Info("Send: email", zap.String("to", msg.To), zap.String("subject", msg.Subject), zap.String("body", msg.Body))
+	return nil
+}
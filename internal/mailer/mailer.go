@@ -0,0 +1,14 @@
+package mailer
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails to players
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,21 @@
+package mailer
+
+import "context"
+
+var _ Mailer = (*MockMailer)(nil)
+
+type MockMailer struct {
+	SendFunc func(msg Message) error
+}
+
+func NewMockMailer() *MockMailer {
+	return &MockMailer{
+		SendFunc: func(msg Message) error {
+			return nil
+		},
+	}
+}
+
+func (m *MockMailer) Send(ctx context.Context, msg Message) error {
+	return m.SendFunc(msg)
+}
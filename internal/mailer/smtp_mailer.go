@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+var _ Mailer = (*SMTPMailer)(nil)
+
+type Config struct {
+	Host string `env:"SMTP_HOST" envDefault:"localhost"`
+	Port int    `env:"SMTP_PORT" envDefault:"25"`
+	User string `env:"SMTP_USER"`
+	Pass string `env:"SMTP_PASS"`
+	From string `env:"SMTP_FROM" envDefault:"no-reply@geogame.local"`
+}
+
+type SMTPMailer struct {
+	config *Config
+}
+
+func NewSMTPMailer(config *Config) *SMTPMailer {
+	return &SMTPMailer{
+		config: config,
+	}
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.config.User != "" {
+		auth = smtp.PlainAuth("", s.config.User, s.config.Pass, s.config.Host)
+	}
+	body := strings.Join([]string{
+		fmt.Sprintf("From: %s", s.config.From),
+		fmt.Sprintf("To: %s", msg.To),
+		fmt.Sprintf("Subject: %s", msg.Subject),
+		"",
+		msg.Body,
+	}, "\r\n")
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	return smtp.SendMail(addr, auth, s.config.From, []string{msg.To}, []byte(body))
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AdminRoleChecker returns the current role of an admin, the role is empty once the admin is deleted
+type AdminRoleChecker interface {
+	AdminRole(ctx context.Context, adminID string) (string, error)
+}
+
+var _ AdminRoleChecker = (*AdminRoleCache)(nil)
+
+type adminRoleEntry struct {
+	role      string
+	expiresAt time.Time
+}
+
+// AdminRoleCache keeps the roles of the admins in memory for ttl, so the role changes and
+// deletions done by other instances apply to the issued admin tokens after at most ttl.
+type AdminRoleCache struct {
+	checker AdminRoleChecker
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]adminRoleEntry
+	lastSweep time.Time
+}
+
+func NewAdminRoleCache(checker AdminRoleChecker, ttl time.Duration) *AdminRoleCache {
+	return &AdminRoleCache{
+		checker:   checker,
+		ttl:       ttl,
+		entries:   make(map[string]adminRoleEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (c *AdminRoleCache) AdminRole(ctx context.Context, adminID string) (string, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[adminID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.role, nil
+	}
+
+	role, err := c.checker.AdminRole(ctx, adminID)
+	if err != nil {
+		return "", err
+	}
+	c.set(adminID, role, now)
+	return role, nil
+}
+
+// MarkRole records a role change done by this instance without waiting for the ttl, an empty role for a deletion
+func (c *AdminRoleCache) MarkRole(adminID, role string) {
+	if c == nil {
+		return
+	}
+	c.set(adminID, role, time.Now())
+}
+
+func (c *AdminRoleCache) set(adminID, role string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[adminID] = adminRoleEntry{role: role, expiresAt: now.Add(c.ttl)}
+	if now.Sub(c.lastSweep) > c.ttl {
+		for id, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		c.lastSweep = now
+	}
+}
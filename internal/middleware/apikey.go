@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// APIKeyHeader carries the API keys of the internal tools
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is the key of an internal tool, it is granted the permissions listed in its scopes
+type APIKey struct {
+	ID     string
+	Name   string
+	Scopes []string
+}
+
+// HasPermission reports whether the scopes of the key grant the permission
+func (k *APIKey) HasPermission(permission string) bool {
+	for _, s := range k.Scopes {
+		if s == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyAuthenticator resolves an API key, it fails for unknown, revoked and expired keys
+// and for callers outside of the IP allowlist of the key
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key, remoteIP string) (*APIKey, error)
+}
+
+func isAPIKeyValid(keys APIKeyAuthenticator, r *http.Request) (*http.Request, bool) {
+	if keys == nil {
+		return nil, false
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	key, err := keys.AuthenticateAPIKey(r.Context(), r.Header.Get(APIKeyHeader), ip)
+	if err != nil {
+		return nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), "APIKey", key)), true
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyConfig configures the asymmetric signing keys, when nothing is set the HS256 token secret is used
+type KeyConfig struct {
+	// Algorithm of the generated keys, one of RS256, ES256 or EdDSA
+	Algorithm string `env:"JWT_ALGORITHM"`
+	// PrivateKey is a PEM encoded private key
+	PrivateKey string `env:"JWT_PRIVATE_KEY"`
+	// PrivateKeyFile is the path of a PEM encoded private key
+	PrivateKeyFile string `env:"JWT_PRIVATE_KEY_FILE"`
+	// KeyDir holds *.pem private keys which can sign and public keys which only verify,
+	// instances sharing the directory share the keys
+	KeyDir string `env:"JWT_KEY_DIR"`
+	// RotationInterval is the age after which a new signing key is generated, 0 disables the rotation
+	RotationInterval time.Duration `env:"JWT_ROTATION_INTERVAL" envDefault:"0s"`
+	// KeyRetention is how long a replaced key still verifies tokens, it must exceed the token lifetime
+	KeyRetention time.Duration `env:"JWT_KEY_RETENTION" envDefault:"1h"`
+	// ReloadInterval is how often the keys are reloaded and rotated
+	ReloadInterval time.Duration `env:"JWT_KEY_RELOAD_INTERVAL" envDefault:"1m"`
+}
+
+// Enabled reports whether asymmetric keys are configured
+func (c *KeyConfig) Enabled() bool {
+	return c.Algorithm != "" || c.PrivateKey != "" || c.PrivateKeyFile != "" || c.KeyDir != ""
+}
+
+// KeyManager loads the keys of the ring and rotates them on schedule
+type KeyManager struct {
+	config *KeyConfig
+	ring   *JwtKey
+
+	mu        sync.Mutex
+	static    []*SigningKey
+	generated []*SigningKey
+}
+
+func NewKeyManager(config *KeyConfig) (*KeyManager, error) {
+	switch config.Algorithm {
+	case "", AlgRS256, AlgES256, AlgEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", config.Algorithm)
+	}
+	m := &KeyManager{
+		config: config,
+		ring:   NewJwtKeyRing(),
+	}
+	if config.PrivateKey != "" {
+		key, err := ParseSigningKeyPEM([]byte(config.PrivateKey), time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT_PRIVATE_KEY: %v", err)
+		}
+		m.static = append(m.static, key)
+	}
+	if config.PrivateKeyFile != "" {
+		key, err := loadKeyFile(config.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		m.static = append(m.static, key)
+	}
+	if err := m.Refresh(); err != nil {
+		return nil, err
+	}
+	if m.ring.signingKey() == nil {
+		return nil, fmt.Errorf("no JWT signing key configured, set JWT_ALGORITHM to generate one")
+	}
+	return m, nil
+}
+
+func (m *KeyManager) Ring() *JwtKey {
+	return m.ring
+}
+
+// Refresh reloads the key directory, generates a new signing key when the current one is due
+// and drops the keys which were replaced longer than the retention ago
+func (m *KeyManager) Refresh() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+
+	dirKeys, err := m.loadDir()
+	if err != nil {
+		return err
+	}
+	keys := append(append(append([]*SigningKey{}, m.static...), m.generated...), dirKeys...)
+
+	if m.rotationDue(keys, now) {
+		key, err := GenerateSigningKey(m.config.Algorithm)
+		if err != nil {
+			return err
+		}
+		if err := m.persist(key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	m.ring.setKeys(m.prune(keys, now))
+	return nil
+}
+
+func (m *KeyManager) rotationDue(keys []*SigningKey, now time.Time) bool {
+	if m.config.Algorithm == "" {
+		return false
+	}
+	newest := newestSigningKey(keys)
+	if newest == nil {
+		return true
+	}
+	return m.config.RotationInterval > 0 && now.Sub(newest.CreatedAt) >= m.config.RotationInterval
+}
+
+// persist writes the generated key to the shared directory, without a directory it is only kept in memory
+func (m *KeyManager) persist(key *SigningKey) error {
+	key.CreatedAt = key.CreatedAt.Truncate(time.Second)
+	if m.config.KeyDir == "" {
+		m.generated = append(m.generated, key)
+		return nil
+	}
+	data, err := MarshalPrivateKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.config.KeyDir, key.ID+".pem")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chtimes(path, key.CreatedAt, key.CreatedAt)
+}
+
+// prune keeps the public keys and every private key that is the newest one or was replaced recently
+func (m *KeyManager) prune(keys []*SigningKey, now time.Time) []*SigningKey {
+	private := make([]*SigningKey, 0, len(keys))
+	kept := make([]*SigningKey, 0, len(keys))
+	for _, k := range keys {
+		if k.Private == nil {
+			kept = append(kept, k)
+			continue
+		}
+		private = append(private, k)
+	}
+	sort.Slice(private, func(i, j int) bool {
+		return private[i].CreatedAt.Before(private[j].CreatedAt)
+	})
+	var generated []*SigningKey
+	for i, k := range private {
+		if i < len(private)-1 && now.Sub(private[i+1].CreatedAt) > m.config.KeyRetention {
+			m.remove(k)
+			continue
+		}
+		kept = append(kept, k)
+		for _, g := range m.generated {
+			if g == k {
+				generated = append(generated, k)
+			}
+		}
+	}
+	m.generated = generated
+	return kept
+}
+
+func (m *KeyManager) remove(key *SigningKey) {
+	if m.config.KeyDir == "" || m.config.RotationInterval == 0 {
+		return
+	}
+	_ = os.Remove(filepath.Join(m.config.KeyDir, key.ID+".pem"))
+}
+
+func (m *KeyManager) loadDir() ([]*SigningKey, error) {
+	if m.config.KeyDir == "" {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(m.config.KeyDir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*SigningKey
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".pem") {
+			continue
+		}
+		key, err := loadKeyFile(filepath.Join(m.config.KeyDir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func loadKeyFile(path string) (*SigningKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseSigningKeyPEM(data, info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %v", path, err)
+	}
+	return key, nil
+}
+
+func newestSigningKey(keys []*SigningKey) *SigningKey {
+	var newest *SigningKey
+	for _, k := range keys {
+		if k.Private != nil && (newest == nil || k.CreatedAt.After(newest.CreatedAt)) {
+			newest = k
+		}
+	}
+	return newest
+}
@@ -4,14 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
-// validate the client jwt token
-func IsClientAllowed(auther JwtAuther) func(next http.Handler) http.Handler {
+// validate the client jwt token, make sure its session is not revoked and the player is not suspended or banned
+func IsClientAllowed(auther JwtAuther, revocations RevocationChecker, standings StandingChecker) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			req, ok := isTokenValid(auther, r)
-			if ok {
+			if ok && !isRevoked(revocations, req) && !isRestricted(standings, req) {
 				next.ServeHTTP(w, req)
 				return
 			}
@@ -20,18 +21,77 @@ func IsClientAllowed(auther JwtAuther) func(next http.Handler) http.Handler {
 	}
 }
 
+// validate the client token carries the required scope, must be chained after IsClientAllowed
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value("AccessToken").(*AccessToken)
+			if ok && token != nil && token.HasScope(scope) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			forbidden(w)
+		})
+	}
+}
+
+// copy the token of the query parameter to the Authorization header of a websocket handshake,
+// browsers can not set headers when opening a websocket, must be chained before IsClientAllowed
+func QueryToken(param string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := r.URL.Query().Get(param); token != "" && r.Header.Get("Authorization") == "" &&
+				strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func isTokenValid(auther JwtAuther, r *http.Request) (*http.Request, bool) {
 	if jwtToken := r.Header.Get("Authorization"); len(jwtToken) >= 8 && jwtToken[:7] == "Bearer " {
 		token := &AccessToken{}
 		if err := auther.RequireLogin(token, jwtToken[7:]); err != nil {
 			return nil, false
 		}
+		// admin tokens are signed by the same keys
+		if token.Audience == AdminAudience {
+			return nil, false
+		}
 		req := updateContext(token, r)
 		return req, true
 	}
 	return nil, false
 }
 
+func isRevoked(revocations RevocationChecker, r *http.Request) bool {
+	if revocations == nil {
+		return false
+	}
+	token, ok := r.Context().Value("AccessToken").(*AccessToken)
+	if !ok || token == nil {
+		return true
+	}
+	revoked, err := revocations.IsRevoked(r.Context(), token.Id)
+	// fail closed, a token can not be trusted when its session can not be checked
+	return err != nil || revoked
+}
+
+// isRestricted rejects the suspended or banned players, their tokens stay valid until they expire
+func isRestricted(standings StandingChecker, r *http.Request) bool {
+	if standings == nil {
+		return false
+	}
+	token, ok := r.Context().Value("AccessToken").(*AccessToken)
+	if !ok || token == nil {
+		return true
+	}
+	restricted, err := standings.IsRestricted(r.Context(), token.UserID)
+	// fail closed like the revocation check
+	return err != nil || restricted
+}
+
 func updateContext(token *AccessToken, r *http.Request) *http.Request {
 	ctx := r.Context()
 	// updatedCtx := context.WithValue(ctx, "UserId", token.UserID)
@@ -48,3 +108,12 @@ func unAuthorized(w http.ResponseWriter) {
 		http.Error(w, "", http.StatusInternalServerError)
 	}
 }
+
+func forbidden(w http.ResponseWriter) {
+	code := http.StatusForbidden
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(http.StatusText(code)); err != nil {
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
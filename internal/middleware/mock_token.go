@@ -3,11 +3,24 @@ package middleware
 var _ JwtAuther = (*MockAuther)(nil)
 
 type MockAuther struct {
+	// AdminRole is given to the admin tokens, they are refused when it is empty
+	AdminRole string
 }
 
 func NewMockAuther() *MockAuther {
 	return &MockAuther{}
 }
+
+// NewMockAdminAuther accepts any token, admin tokens carry the role
+func NewMockAdminAuther(role string) *MockAuther {
+	return &MockAuther{AdminRole: role}
+}
+
 func (m MockAuther) RequireLogin(scg StandardClaimsGetter, tokenString string) error {
+	if token, ok := scg.(*AdminToken); ok && m.AdminRole != "" {
+		token.AdminID = "mock-admin"
+		token.Role = m.AdminRole
+		token.Audience = AdminAudience
+	}
 	return nil
 }
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientCertConfig configures the client certificate check of the admin routes,
+// the certificates are verified against HTTP_TLS_CLIENT_CA_FILE during the handshake
+type ClientCertConfig struct {
+	// Required rejects the admin requests made without a verified client certificate
+	Required bool `env:"ADMIN_CLIENT_CERT_REQUIRED" envDefault:"false"`
+	// DenyListFile is a CRL, PEM or DER, or a list of serial numbers and SHA-256 fingerprints in hex, one per line
+	DenyListFile string `env:"ADMIN_CERT_DENY_LIST_FILE"`
+	// ReloadInterval is how often the deny list file is checked for changes
+	ReloadInterval time.Duration `env:"ADMIN_CERT_DENY_LIST_RELOAD_INTERVAL" envDefault:"30s"`
+}
+
+// ClientCert is the verified certificate of the caller, the identity names the admin in the audit logs
+type ClientCert struct {
+	Identity    string
+	Serial      string
+	Fingerprint string
+}
+
+// CertIdentity maps a certificate to an admin identity, the SAN is preferred over the subject
+func CertIdentity(cert *x509.Certificate) string {
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	}
+	return cert.Subject.String()
+}
+
+// CertFingerprint is the hex SHA-256 of the DER encoded certificate
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// CertDenyList holds the revoked certificates, it is reloaded when the file changes
+type CertDenyList struct {
+	path string
+
+	mu      sync.RWMutex
+	entries map[string]struct{}
+	modTime time.Time
+}
+
+// NewCertDenyList loads the deny list file, an empty path denies nothing
+func NewCertDenyList(path string) (*CertDenyList, error) {
+	d := &CertDenyList{
+		path:    path,
+		entries: make(map[string]struct{}),
+	}
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Refresh reloads the file when it changed, the previous list is kept when the new one can't be read
+func (d *CertDenyList) Refresh() error {
+	if d == nil || d.path == "" {
+		return nil
+	}
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return errors.New("failed to stat certificate deny list:" + err.Error())
+	}
+	d.mu.RLock()
+	unchanged := info.ModTime().Equal(d.modTime)
+	d.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	data, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return errors.New("failed to read certificate deny list:" + err.Error())
+	}
+	entries, err := parseDenyList(data)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.entries = entries
+	d.modTime = info.ModTime()
+	d.mu.Unlock()
+	return nil
+}
+
+// IsDenied reports whether the serial number or the fingerprint of the certificate is listed
+func (d *CertDenyList) IsDenied(cert *x509.Certificate) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.entries[normalizeHex(cert.SerialNumber.Text(16))]; ok {
+		return true
+	}
+	_, ok := d.entries[CertFingerprint(cert)]
+	return ok
+}
+
+func parseDenyList(data []byte) (map[string]struct{}, error) {
+	entries := make(map[string]struct{})
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, errors.New("unexpected PEM block in certificate deny list: " + block.Type)
+		}
+		der = block.Bytes
+	}
+	if crl, err := x509.ParseDERCRL(der); err == nil {
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			entries[normalizeHex(revoked.SerialNumber.Text(16))] = struct{}{}
+		}
+		return entries, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		entry := normalizeHex(line)
+		if _, err := hex.DecodeString(padHex(entry)); err != nil {
+			return nil, errors.New("invalid entry in certificate deny list: " + line)
+		}
+		entries[entry] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("failed to parse certificate deny list:" + err.Error())
+	}
+	return entries, nil
+}
+
+// normalizeHex drops the separators so 0A:1B and 0a1b match,
+// serial numbers also drop the leading zeros while fingerprints keep their length
+func normalizeHex(s string) string {
+	s = strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(s))
+	s = strings.TrimPrefix(s, "0x")
+	if len(s) != sha256.Size*2 {
+		s = strings.TrimLeft(s, "0")
+	}
+	return s
+}
+
+func padHex(s string) string {
+	if len(s)%2 == 1 {
+		return "0" + s
+	}
+	return s
+}
+
+// RequireClientCert validates the caller presented a certificate verified against the client CA bundle
+// and not on the deny list, the certificate is put in the context as AdminCert
+func RequireClientCert(denyList *CertDenyList) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				unAuthorized(w)
+				return
+			}
+			cert := r.TLS.VerifiedChains[0][0]
+			if denyList.IsDenied(cert) {
+				forbidden(w)
+				return
+			}
+			clientCert := &ClientCert{
+				Identity:    CertIdentity(cert),
+				Serial:      normalizeHex(cert.SerialNumber.Text(16)),
+				Fingerprint: CertFingerprint(cert),
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "AdminCert", clientCert)))
+		})
+	}
+}
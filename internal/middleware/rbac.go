@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// AdminAudience marks the tokens issued to admins, they are not accepted on the client routes and vice versa
+const AdminAudience = "geo-game-admin"
+
+// admin roles, every role grants the permissions of the roles before it
+const (
+	RoleViewer     = "viewer"
+	RoleEditor     = "editor"
+	RoleSuperAdmin = "superadmin"
+)
+
+// admin permissions, the admin routes require one of them
+const (
+	PermLocationsRead    = "locations:read"
+	PermLocationsWrite   = "locations:write"
+	PermLocationsImport  = "locations:import"
+	PermPlayersRead      = "players:read"
+	PermPlayersWrite     = "players:write"
+	PermPlayersBan       = "players:ban"
+	PermContentRead      = "content:read"
+	PermContentWrite     = "content:write"
+	PermNotificationSend = "notifications:send"
+	PermChatModerate     = "chat:moderate"
+	PermAdminsManage     = "admins:manage"
+)
+
+var viewerPermissions = []string{
+	PermLocationsRead,
+	PermPlayersRead,
+	PermContentRead,
+}
+
+var editorPermissions = append([]string{
+	PermLocationsWrite,
+	PermLocationsImport,
+	PermPlayersWrite,
+	PermContentWrite,
+	PermNotificationSend,
+	PermChatModerate,
+}, viewerPermissions...)
+
+var rolePermissions = map[string][]string{
+	RoleViewer: viewerPermissions,
+	RoleEditor: editorPermissions,
+	RoleSuperAdmin: append([]string{
+		PermPlayersBan,
+		PermAdminsManage,
+	}, editorPermissions...),
+}
+
+// ValidRole reports whether the role is known
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns the permissions granted by the role
+func RolePermissions(role string) []string {
+	return append([]string(nil), rolePermissions[role]...)
+}
+
+// ValidPermission reports whether the permission is known
+func ValidPermission(permission string) bool {
+	return HasPermission(RoleSuperAdmin, permission)
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminIssuer signs the access tokens handed out to the admins
+type AdminIssuer interface {
+	GenerateAdminToken(adminID, role string) (string, error)
+}
+
+var _ AdminIssuer = (*JwtKey)(nil)
+
+// AdminToken is the access token of an admin, the permissions follow from the role
+type AdminToken struct {
+	AdminID string `json:"adminId"`
+	Role    string `json:"role"`
+	jwt.StandardClaims
+}
+
+func (at *AdminToken) GetStandardClaims() *jwt.StandardClaims {
+	return &at.StandardClaims
+}
+
+// HasPermission reports whether the role of the token grants the permission
+func (at *AdminToken) HasPermission(permission string) bool {
+	return HasPermission(at.Role, permission)
+}
+
+// GenerateAdminToken issues an access token carrying the role of the admin
+func (key *JwtKey) GenerateAdminToken(adminID, role string) (string, error) {
+	signingKey := key.signingKey()
+	if signingKey == nil {
+		return "", errors.New("no signing key available")
+	}
+	claims := key.GenerateClaim(adminID)
+	claims.Audience = AdminAudience
+	adminToken := AdminToken{
+		AdminID:        adminID,
+		Role:           role,
+		StandardClaims: claims,
+	}
+	token := jwt.NewWithClaims(signingKey.Method, adminToken)
+	token.Header["kid"] = signingKey.ID
+
+	signedToken, err := token.SignedString(signingKey.Private)
+	if err != nil {
+		return "", errors.New("failed to create signedTokenString")
+	}
+	return signedToken, nil
+}
+
+// validate the admin jwt token, the admin is put in the context as AdminToken.
+// With the role checker the role of the token is replaced by the current one and deleted admins are rejected.
+// With the authenticator an X-API-Key header is accepted instead, the key is put in the context as APIKey
+func IsAdminAllowed(auther JwtAuther, keys APIKeyAuthenticator, roles AdminRoleChecker) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(APIKeyHeader) != "" {
+				if req, ok := isAPIKeyValid(keys, r); ok {
+					next.ServeHTTP(w, req)
+					return
+				}
+				unAuthorized(w)
+				return
+			}
+			if req, ok := isAdminTokenValid(auther, roles, r); ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+			unAuthorized(w)
+		})
+	}
+}
+
+// validate the role of the admin or the scopes of the API key grant the permission, must be chained after IsAdminAllowed
+func RequirePermission(permission string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token, ok := r.Context().Value("AdminToken").(*AdminToken); ok && token != nil && token.HasPermission(permission) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if key, ok := r.Context().Value("APIKey").(*APIKey); ok && key != nil && key.HasPermission(permission) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			forbidden(w)
+		})
+	}
+}
+
+func isAdminTokenValid(auther JwtAuther, roles AdminRoleChecker, r *http.Request) (*http.Request, bool) {
+	if jwtToken := r.Header.Get("Authorization"); len(jwtToken) >= 8 && jwtToken[:7] == "Bearer " {
+		token := &AdminToken{}
+		if err := auther.RequireLogin(token, jwtToken[7:]); err != nil {
+			return nil, false
+		}
+		if token.Audience != AdminAudience {
+			return nil, false
+		}
+		if roles != nil {
+			// fail closed like the revocation check
+			role, err := roles.AdminRole(r.Context(), token.AdminID)
+			if err != nil {
+				return nil, false
+			}
+			token.Role = role
+		}
+		if !ValidRole(token.Role) {
+			return nil, false
+		}
+		return r.WithContext(context.WithValue(r.Context(), "AdminToken", token)), true
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationChecker tells whether the session behind an access token was revoked
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+var _ RevocationChecker = (*RevocationCache)(nil)
+
+type revocationEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+// RevocationCache keeps the result of the revocation lookups in memory.
+// Revoked sessions stay revoked so they are cached until the access token lifetime is over,
+// active sessions are only cached for ttl so revocations done by other instances are picked up.
+type RevocationCache struct {
+	checker    RevocationChecker
+	ttl        time.Duration
+	revokedTTL time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]revocationEntry
+	lastSweep time.Time
+}
+
+func NewRevocationCache(checker RevocationChecker, ttl time.Duration) *RevocationCache {
+	return &RevocationCache{
+		checker:    checker,
+		ttl:        ttl,
+		revokedTTL: AccessTokenTTL,
+		entries:    make(map[string]revocationEntry),
+		lastSweep:  time.Now(),
+	}
+}
+
+// IsRevoked is safe to call on a nil cache, which disables the revocation check
+func (c *RevocationCache) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[sessionID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.checker.IsRevoked(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	c.set(sessionID, revoked, now)
+	return revoked, nil
+}
+
+// MarkRevoked records a revocation done by this instance without waiting for the ttl
+func (c *RevocationCache) MarkRevoked(sessionID string) {
+	if c == nil {
+		return
+	}
+	c.set(sessionID, true, time.Now())
+}
+
+func (c *RevocationCache) set(sessionID string, revoked bool, now time.Time) {
+	ttl := c.ttl
+	if revoked {
+		ttl = c.revokedTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[sessionID] = revocationEntry{revoked: revoked, expiresAt: now.Add(ttl)}
+	if now.Sub(c.lastSweep) > c.ttl {
+		for id, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		c.lastSweep = now
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StandingChecker tells whether a player is suspended or banned
+type StandingChecker interface {
+	IsRestricted(ctx context.Context, clientID string) (bool, error)
+}
+
+var _ StandingChecker = (*StandingCache)(nil)
+
+type standingEntry struct {
+	restricted bool
+	expiresAt  time.Time
+}
+
+// StandingCache keeps the result of the standing lookups in memory for ttl,
+// so the restrictions done by other instances are picked up after at most ttl.
+type StandingCache struct {
+	checker StandingChecker
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]standingEntry
+	lastSweep time.Time
+}
+
+func NewStandingCache(checker StandingChecker, ttl time.Duration) *StandingCache {
+	return &StandingCache{
+		checker:   checker,
+		ttl:       ttl,
+		entries:   make(map[string]standingEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// IsRestricted is safe to call on a nil cache, which disables the standing check
+func (c *StandingCache) IsRestricted(ctx context.Context, clientID string) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[clientID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.restricted, nil
+	}
+
+	restricted, err := c.checker.IsRestricted(ctx, clientID)
+	if err != nil {
+		return false, err
+	}
+	c.set(clientID, restricted, now)
+	return restricted, nil
+}
+
+// MarkRestricted records a restriction change done by this instance without waiting for the ttl
+func (c *StandingCache) MarkRestricted(clientID string, restricted bool) {
+	if c == nil {
+		return
+	}
+	c.set(clientID, restricted, time.Now())
+}
+
+func (c *StandingCache) set(clientID string, restricted bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[clientID] = standingEntry{restricted: restricted, expiresAt: now.Add(c.ttl)}
+	if now.Sub(c.lastSweep) > c.ttl {
+		for id, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		c.lastSweep = now
+	}
+}
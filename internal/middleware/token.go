@@ -1,75 +1,169 @@
 package middleware
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"crypto/rand"
 	"errors"
 	"fmt"
-	"github.com/dgrijalva/jwt-go"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/dgrijalva/jwt-go"
 )
 
 const Issuer = "geo-game"
 
+// AccessTokenTTL is the lifetime of the access tokens, a revoked session has to stay revoked in the caches as long
+const AccessTokenTTL = time.Second * 900
+
+const (
+	// ScopeProfile allows a player to read and manage the own profile
+	ScopeProfile = "profile"
+	// ScopePlay allows a player to take part in the game, it requires a verified or a guest account
+	ScopePlay = "play"
+	// ScopeGuest marks the tokens of a guest account, it allows the upgrade to a full account
+	ScopeGuest = "guest"
+)
+
 type JwtAuther interface {
 	RequireLogin(scg StandardClaimsGetter, tokenString string) error
 }
 
+// TokenIssuer signs the access tokens handed out to the players
+type TokenIssuer interface {
+	GenerateToken(userID, sessionID string, scopes []string) (string, error)
+}
+
 var _ JwtAuther = (*JwtKey)(nil)
+var _ TokenIssuer = (*JwtKey)(nil)
 
+// JwtKey is the key ring used to sign and verify the tokens.
+// The newest key signs, every key in the ring verifies the tokens carrying its kid.
 type JwtKey struct {
-	keyID     string
-	method    jwt.SigningMethod
-	signKey   interface{}
-	verifyKey interface{}
+	mu      sync.RWMutex
+	current *SigningKey
+	keys    map[string]*SigningKey
 }
 
+// NewJwtKey creates a HS256 key ring from the shared secret, a random secret is used when it is empty
 func NewJwtKey(signKey string) *JwtKey {
-	return &JwtKey{
-		keyID:     "0",
-		method:    jwt.SigningMethodHS256,
-		signKey:   []byte("test_hmac"),
-		verifyKey: []byte("test_hmac"),
+	secret := []byte(signKey)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	key := &SigningKey{
+		ID:        "0",
+		Method:    jwt.SigningMethodHS256,
+		Private:   secret,
+		Public:    secret,
+		CreatedAt: time.Now(),
+	}
+	return NewJwtKeyRing(key)
+}
+
+// NewJwtKeyRing creates a key ring from the given keys, the newest private key is used for signing
+func NewJwtKeyRing(keys ...*SigningKey) *JwtKey {
+	key := &JwtKey{
+		keys: make(map[string]*SigningKey),
+	}
+	key.setKeys(keys)
+	return key
+}
+
+func (key *JwtKey) setKeys(keys []*SigningKey) {
+	ring := make(map[string]*SigningKey, len(keys))
+	for _, k := range keys {
+		ring[k.ID] = k
 	}
+	current := newestSigningKey(keys)
+	key.mu.Lock()
+	defer key.mu.Unlock()
+	key.keys = ring
+	key.current = current
+}
+
+// Keys returns the keys of the ring sorted from the oldest to the newest
+func (key *JwtKey) Keys() []*SigningKey {
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	keys := make([]*SigningKey, 0, len(key.keys))
+	for _, k := range key.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+func (key *JwtKey) signingKey() *SigningKey {
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	return key.current
 }
+
+func (key *JwtKey) verificationKey(kid string) (*SigningKey, bool) {
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	k, ok := key.keys[kid]
+	return k, ok
+}
+
 type StandardClaimsGetter interface {
 	jwt.Claims
 	GetStandardClaims() *jwt.StandardClaims
 }
 
 type AccessToken struct {
-	UserID string `json:"userId"`
+	UserID string   `json:"userId"`
+	Scopes []string `json:"scopes,omitempty"`
 	jwt.StandardClaims
 }
 
-
 func (at *AccessToken) GetStandardClaims() *jwt.StandardClaims {
 	return &at.StandardClaims
 }
 
+// HasScope reports whether the token grants the given scope
+func (at *AccessToken) HasScope(scope string) bool {
+	for _, s := range at.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
 
-func (key *JwtKey)GenerateToken(userID string)  (string,error){
-	claims:= key.GenerateClaim()
+// GenerateToken issues an access token bound to the session, the session id is used as jti
+func (key *JwtKey) GenerateToken(userID, sessionID string, scopes []string) (string, error) {
+	signingKey := key.signingKey()
+	if signingKey == nil {
+		return "", errors.New("no signing key available")
+	}
+	claims := key.GenerateClaim(sessionID)
 	accessToken := AccessToken{
 		UserID:         userID,
+		Scopes:         scopes,
 		StandardClaims: claims,
 	}
-	token := jwt.NewWithClaims(key.method, accessToken)
+	token := jwt.NewWithClaims(signingKey.Method, accessToken)
+	token.Header["kid"] = signingKey.ID
 
-	signedToken,err := token.SignedString(key.signKey)
-	// publicKey := loadPublicKey()
-	// signedToken,err := token.SignedString(publicKey)
-	if err!=nil {
+	signedToken, err := token.SignedString(signingKey.Private)
+	if err != nil {
 		return "", errors.New("failed to create signedTokenString")
 	}
-	return signedToken,nil
+	return signedToken, nil
 
 }
 
-func (key *JwtKey)GenerateClaim()  jwt.StandardClaims{
+func (key *JwtKey) GenerateClaim(sessionID string) jwt.StandardClaims {
 	claims := jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(time.Second * 900).Unix(),
-		Id:        key.keyID,
+		ExpiresAt: time.Now().Add(AccessTokenTTL).Unix(),
+		Id:        sessionID,
 		IssuedAt:  time.Now().Unix(),
 		Issuer:    Issuer,
 		NotBefore: time.Now().Unix() - 1,
@@ -77,17 +171,17 @@ func (key *JwtKey)GenerateClaim()  jwt.StandardClaims{
 	return claims
 }
 
-
 func (key *JwtKey) RequireLogin(scg StandardClaimsGetter, tokenString string) error {
 	_, err := jwt.ParseWithClaims(tokenString, scg, func(token *jwt.Token) (interface{}, error) {
-		if token.Header["alg"] != key.method.Alg() {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		if scg.GetStandardClaims().Id != key.keyID {
+		kid, _ := token.Header["kid"].(string)
+		verificationKey, ok := key.verificationKey(kid)
+		if !ok {
 			return nil, fmt.Errorf("the keyId is not known")
 		}
-		return key.verifyKey, nil
+		if token.Header["alg"] != verificationKey.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return verificationKey.Public, nil
 	})
 	if err != nil {
 		return err
@@ -106,17 +200,8 @@ func validateStandardClaims(stdClaims *jwt.StandardClaims) error {
 	if !stdClaims.VerifyNotBefore(now, false) {
 		return errors.New("jwt token is not valid yet")
 	}
+	if stdClaims.Issuer != Issuer {
+		return errors.New("jwt token issuer is not known")
+	}
 	return nil
 }
-
-func loadPublicKey() interface{} {
-	certPair, err := tls.LoadX509KeyPair("/Users/bhakiyarajkalimuthu/personal/secrets/server.crt", "/Users/bhakiyarajkalimuthu/personal/secrets/server.key")
-	if err != nil {
-		return nil
-	}
-	cert, err := x509.ParseCertificate(certPair.Certificate[0])
-	if err != nil {
-		return nil
-	}
-	return cert.PublicKey
-}
\ No newline at end of file
@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJwtKey_GenerateToken(t *testing.T) {
+	tests := []struct {
+		name string
+		alg  string
+	}{
+		{name: "rsa", alg: AlgRS256},
+		{name: "ecdsa", alg: AlgES256},
+		{name: "eddsa", alg: AlgEdDSA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := GenerateSigningKey(tt.alg)
+			assert.Nil(t, err)
+			ring := NewJwtKeyRing(key)
+
+			token, err := ring.GenerateToken("user", "session", []string{ScopeProfile})
+			assert.Nil(t, err)
+
+			claims := &AccessToken{}
+			assert.Nil(t, ring.RequireLogin(claims, token))
+			assert.Equal(t, "user", claims.UserID)
+			assert.Equal(t, "session", claims.Id)
+
+			// a ring without the key rejects the token
+			other, err := GenerateSigningKey(tt.alg)
+			assert.Nil(t, err)
+			assert.NotNil(t, NewJwtKeyRing(other).RequireLogin(&AccessToken{}, token))
+
+			jwks := ring.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, key.ID, jwks.Keys[0].Kid)
+			assert.Equal(t, tt.alg, jwks.Keys[0].Alg)
+			public, err := jwks.Keys[0].PublicKey()
+			assert.Nil(t, err)
+			assert.Equal(t, key.Public, public)
+		})
+	}
+}
+
+func TestJwtKey_HMAC(t *testing.T) {
+	ring := NewJwtKey("secret")
+	token, err := ring.GenerateToken("user", "session", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, NewJwtKey("secret").RequireLogin(&AccessToken{}, token))
+	assert.NotNil(t, NewJwtKey("other").RequireLogin(&AccessToken{}, token))
+	assert.Empty(t, ring.JWKS().Keys)
+}
+
+func TestKeyManager_Refresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwt-keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	manager, err := NewKeyManager(&KeyConfig{
+		Algorithm:        AlgES256,
+		KeyDir:           dir,
+		RotationInterval: time.Hour,
+		KeyRetention:     time.Hour,
+	})
+	assert.Nil(t, err)
+	first := manager.Ring().signingKey()
+	token, err := manager.Ring().GenerateToken("user", "session", nil)
+	assert.Nil(t, err)
+
+	// age the key beyond the rotation interval
+	old := time.Now().Add(-time.Hour * 2)
+	assert.Nil(t, os.Chtimes(dir+"/"+first.ID+".pem", old, old))
+	assert.Nil(t, manager.Refresh())
+	second := manager.Ring().signingKey()
+	assert.NotEqual(t, first.ID, second.ID)
+	// the replaced key still verifies during the retention
+	assert.Nil(t, manager.Ring().RequireLogin(&AccessToken{}, token))
+	assert.Len(t, manager.Ring().JWKS().Keys, 2)
+
+	// another instance sharing the directory picks up the same keys
+	other, err := NewKeyManager(&KeyConfig{KeyDir: dir})
+	assert.Nil(t, err)
+	assert.Equal(t, second.ID, other.Ring().signingKey().ID)
+}
+
+func TestRequirePermission(t *testing.T) {
+	ring := NewJwtKey("secret")
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	admin := IsAdminAllowed(ring, nil, nil)(RequirePermission(PermLocationsWrite)(ok))
+	client := IsClientAllowed(ring, nil, nil)(ok)
+
+	viewer, err := ring.GenerateAdminToken("1", RoleViewer)
+	assert.Nil(t, err)
+	editor, err := ring.GenerateAdminToken("2", RoleEditor)
+	assert.Nil(t, err)
+	player, err := ring.GenerateToken("3", "session", []string{ScopePlay})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		token   string
+		want    int
+	}{
+		{name: "editor", handler: admin, token: editor, want: http.StatusOK},
+		{name: "viewer", handler: admin, token: viewer, want: http.StatusForbidden},
+		{name: "player on admin route", handler: admin, token: player, want: http.StatusUnauthorized},
+		{name: "no token", handler: admin, want: http.StatusUnauthorized},
+		{name: "admin on client route", handler: client, token: editor, want: http.StatusUnauthorized},
+		{name: "player", handler: client, token: player, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	assert.True(t, HasPermission(RoleSuperAdmin, PermPlayersBan))
+	assert.False(t, HasPermission(RoleEditor, PermAdminsManage))
+}
+
+func TestRequireClientCert(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(0x0a1b),
+		Subject:        pkix.Name{CommonName: "ops"},
+		EmailAddresses: []string{"ops@example.com"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	assert.Equal(t, "ops@example.com", CertIdentity(cert))
+
+	dir, err := ioutil.TempDir("", "cert-deny-list")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deny.txt")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("# revoked\nff01\n"), 0600))
+	denyList, err := NewCertDenyList(path)
+	assert.Nil(t, err)
+
+	var identity string
+	handler := RequireClientCert(denyList)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = r.Context().Value("AdminCert").(*ClientCert).Identity
+	}))
+	serve := func(state *tls.ConnectionState) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.TLS = state
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(nil))
+	assert.Equal(t, http.StatusUnauthorized, serve(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}))
+	assert.Equal(t, http.StatusOK, serve(verified))
+	assert.Equal(t, "ops@example.com", identity)
+
+	// the serial number is added to the list
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, ioutil.WriteFile(path, []byte("ff01\n0A:1B\n"), 0600))
+	assert.Nil(t, os.Chtimes(path, later, later))
+	assert.Nil(t, denyList.Refresh())
+	assert.Equal(t, http.StatusForbidden, serve(verified))
+
+	// a broken file keeps the previous list
+	later = later.Add(time.Minute)
+	assert.Nil(t, ioutil.WriteFile(path, []byte("not hex\n"), 0600))
+	assert.Nil(t, os.Chtimes(path, later, later))
+	assert.NotNil(t, denyList.Refresh())
+	assert.Equal(t, http.StatusForbidden, serve(verified))
+
+	// a CRL replaces the list
+	crl, err := cert.CreateCRL(rand.Reader, key, []pkix.RevokedCertificate{{SerialNumber: big.NewInt(1), RevocationTime: time.Now()}}, time.Now(), time.Now().Add(time.Hour))
+	assert.Nil(t, err)
+	later = later.Add(time.Minute)
+	assert.Nil(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0600))
+	assert.Nil(t, os.Chtimes(path, later, later))
+	assert.Nil(t, denyList.Refresh())
+	assert.Equal(t, http.StatusOK, serve(verified))
+}
+
+type standingFunc func(clientID string) (bool, error)
+
+func (f standingFunc) IsRestricted(ctx context.Context, clientID string) (bool, error) {
+	return f(clientID)
+}
+
+func TestIsClientAllowed_Standing(t *testing.T) {
+	ring := NewJwtKey("secret")
+	restricted := map[string]bool{}
+	lookups := 0
+	cache := NewStandingCache(standingFunc(func(clientID string) (bool, error) {
+		lookups++
+		if clientID == "broken" {
+			return false, errors.New("db down")
+		}
+		return restricted[clientID], nil
+	}), time.Minute)
+	handler := IsClientAllowed(ring, nil, cache)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(clientID string) int {
+		token, err := ring.GenerateToken(clientID, "session", []string{ScopePlay})
+		assert.Nil(t, err)
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("1"))
+	// the valid token is rejected once this instance restricts the player
+	restricted["1"] = true
+	assert.Equal(t, http.StatusOK, serve("1"))
+	cache.MarkRestricted("1", true)
+	assert.Equal(t, http.StatusUnauthorized, serve("1"))
+	assert.Equal(t, 1, lookups)
+	// fail closed
+	assert.Equal(t, http.StatusUnauthorized, serve("broken"))
+}
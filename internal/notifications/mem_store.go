@@ -0,0 +1,202 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu            sync.RWMutex
+	notifications []*NotificationStoreModel
+	outbox        map[string]*OutboxStoreModel
+	devices       map[string]DeviceStoreModel
+	settings      map[string]SettingsStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		outbox:   make(map[string]*OutboxStoreModel),
+		devices:  make(map[string]DeviceStoreModel),
+		settings: make(map[string]SettingsStoreModel),
+	}
+}
+
+func (m *MemStore) CreateNotification(ctx context.Context, model *NotificationStoreModel, outbox *OutboxStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := *model
+	m.notifications = append(m.notifications, &n)
+	if outbox != nil {
+		o := *outbox
+		m.outbox[o.ID.String()] = &o
+	}
+	return nil
+}
+
+func (m *MemStore) ListNotifications(ctx context.Context, clientID string, unreadOnly bool, limit int) ([]NotificationStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]NotificationStoreModel, 0)
+	for i := len(m.notifications) - 1; i >= 0 && len(res) < limit; i-- {
+		n := m.notifications[i]
+		if n.ClientID.String() == clientID && (!unreadOnly || !n.ReadAt.Valid) {
+			res = append(res, *n)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) CountUnread(ctx context.Context, clientID string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	count := 0
+	for _, n := range m.notifications {
+		if n.ClientID.String() == clientID && !n.ReadAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemStore) MarkRead(ctx context.Context, clientID, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range m.notifications {
+		if n.ID.String() == id && n.ClientID.String() == clientID {
+			if !n.ReadAt.Valid {
+				n.ReadAt = sql.NullTime{Time: at, Valid: true}
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemStore) MarkAllRead(ctx context.Context, clientID string, at time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for _, n := range m.notifications {
+		if n.ClientID.String() == clientID && !n.ReadAt.Valid {
+			n.ReadAt = sql.NullTime{Time: at, Valid: true}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemStore) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	due := make([]*OutboxStoreModel, 0)
+	for _, o := range m.outbox {
+		if o.Status == StatusPending && !o.NextAttemptAt.After(now) {
+			due = append(due, o)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	res := make([]OutboxStoreModel, 0, len(due))
+	for _, o := range due {
+		o.NextAttemptAt = now.Add(lease)
+		o.UpdatedAt = now
+		res = append(res, *o)
+	}
+	return res, nil
+}
+
+func (m *MemStore) GetOutbox(ctx context.Context, id string) (*OutboxStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.outbox[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	res := *o
+	return &res, nil
+}
+
+func (m *MemStore) UpdateOutbox(ctx context.Context, model *OutboxStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.outbox[model.ID.String()]; !ok {
+		return ErrNotFound
+	}
+	o := *model
+	m.outbox[o.ID.String()] = &o
+	return nil
+}
+
+func (m *MemStore) ListOutbox(ctx context.Context, status string, limit int) ([]OutboxStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]OutboxStoreModel, 0)
+	for _, o := range m.outbox {
+		if o.Status == status {
+			res = append(res, *o)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.After(res[j].CreatedAt)
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (m *MemStore) UpsertDevice(ctx context.Context, model *DeviceStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices[model.Token] = *model
+	return nil
+}
+
+func (m *MemStore) DeleteDevice(ctx context.Context, clientID, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.devices[token]
+	if !ok || d.ClientID.String() != clientID {
+		return ErrNotFound
+	}
+	delete(m.devices, token)
+	return nil
+}
+
+func (m *MemStore) ListDevices(ctx context.Context, clientID string) ([]DeviceStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]DeviceStoreModel, 0)
+	for _, d := range m.devices {
+		if d.ClientID.String() == clientID {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) GetSettings(ctx context.Context, clientID string) (*SettingsStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.settings[clientID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *MemStore) UpsertSettings(ctx context.Context, model *SettingsStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[model.ClientID.String()] = *model
+	return nil
+}
@@ -0,0 +1,128 @@
+package notifications
+
+import (
+	"context"
+	"time"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateNotificationFunc func(model *NotificationStoreModel, outbox *OutboxStoreModel) error
+	ListNotificationsFunc  func(clientID string, unreadOnly bool, limit int) ([]NotificationStoreModel, error)
+	CountUnreadFunc        func(clientID string) (int, error)
+	MarkReadFunc           func(clientID, id string, at time.Time) error
+	MarkAllReadFunc        func(clientID string, at time.Time) (int64, error)
+	ClaimOutboxFunc        func(now time.Time, lease time.Duration, limit int) ([]OutboxStoreModel, error)
+	GetOutboxFunc          func(id string) (*OutboxStoreModel, error)
+	UpdateOutboxFunc       func(model *OutboxStoreModel) error
+	ListOutboxFunc         func(status string, limit int) ([]OutboxStoreModel, error)
+	UpsertDeviceFunc       func(model *DeviceStoreModel) error
+	DeleteDeviceFunc       func(clientID, token string) error
+	ListDevicesFunc        func(clientID string) ([]DeviceStoreModel, error)
+	GetSettingsFunc        func(clientID string) (*SettingsStoreModel, error)
+	UpsertSettingsFunc     func(model *SettingsStoreModel) error
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateNotificationFunc: func(model *NotificationStoreModel, outbox *OutboxStoreModel) error {
+			return nil
+		},
+		ListNotificationsFunc: func(clientID string, unreadOnly bool, limit int) ([]NotificationStoreModel, error) {
+			return []NotificationStoreModel{}, nil
+		},
+		CountUnreadFunc: func(clientID string) (int, error) {
+			return 0, nil
+		},
+		MarkReadFunc: func(clientID, id string, at time.Time) error {
+			return ErrNotFound
+		},
+		MarkAllReadFunc: func(clientID string, at time.Time) (int64, error) {
+			return 0, nil
+		},
+		ClaimOutboxFunc: func(now time.Time, lease time.Duration, limit int) ([]OutboxStoreModel, error) {
+			return []OutboxStoreModel{}, nil
+		},
+		GetOutboxFunc: func(id string) (*OutboxStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		UpdateOutboxFunc: func(model *OutboxStoreModel) error {
+			return nil
+		},
+		ListOutboxFunc: func(status string, limit int) ([]OutboxStoreModel, error) {
+			return []OutboxStoreModel{}, nil
+		},
+		UpsertDeviceFunc: func(model *DeviceStoreModel) error {
+			return nil
+		},
+		DeleteDeviceFunc: func(clientID, token string) error {
+			return ErrNotFound
+		},
+		ListDevicesFunc: func(clientID string) ([]DeviceStoreModel, error) {
+			return []DeviceStoreModel{}, nil
+		},
+		GetSettingsFunc: func(clientID string) (*SettingsStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		UpsertSettingsFunc: func(model *SettingsStoreModel) error {
+			return nil
+		},
+	}
+}
+
+func (m *MockStore) CreateNotification(ctx context.Context, model *NotificationStoreModel, outbox *OutboxStoreModel) error {
+	return m.CreateNotificationFunc(model, outbox)
+}
+
+func (m *MockStore) ListNotifications(ctx context.Context, clientID string, unreadOnly bool, limit int) ([]NotificationStoreModel, error) {
+	return m.ListNotificationsFunc(clientID, unreadOnly, limit)
+}
+
+func (m *MockStore) CountUnread(ctx context.Context, clientID string) (int, error) {
+	return m.CountUnreadFunc(clientID)
+}
+
+func (m *MockStore) MarkRead(ctx context.Context, clientID, id string, at time.Time) error {
+	return m.MarkReadFunc(clientID, id, at)
+}
+
+func (m *MockStore) MarkAllRead(ctx context.Context, clientID string, at time.Time) (int64, error) {
+	return m.MarkAllReadFunc(clientID, at)
+}
+
+func (m *MockStore) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxStoreModel, error) {
+	return m.ClaimOutboxFunc(now, lease, limit)
+}
+
+func (m *MockStore) GetOutbox(ctx context.Context, id string) (*OutboxStoreModel, error) {
+	return m.GetOutboxFunc(id)
+}
+
+func (m *MockStore) UpdateOutbox(ctx context.Context, model *OutboxStoreModel) error {
+	return m.UpdateOutboxFunc(model)
+}
+
+func (m *MockStore) ListOutbox(ctx context.Context, status string, limit int) ([]OutboxStoreModel, error) {
+	return m.ListOutboxFunc(status, limit)
+}
+
+func (m *MockStore) UpsertDevice(ctx context.Context, model *DeviceStoreModel) error {
+	return m.UpsertDeviceFunc(model)
+}
+
+func (m *MockStore) DeleteDevice(ctx context.Context, clientID, token string) error {
+	return m.DeleteDeviceFunc(clientID, token)
+}
+
+func (m *MockStore) ListDevices(ctx context.Context, clientID string) ([]DeviceStoreModel, error) {
+	return m.ListDevicesFunc(clientID)
+}
+
+func (m *MockStore) GetSettings(ctx context.Context, clientID string) (*SettingsStoreModel, error) {
+	return m.GetSettingsFunc(clientID)
+}
+
+func (m *MockStore) UpsertSettings(ctx context.Context, model *SettingsStoreModel) error {
+	return m.UpsertSettingsFunc(model)
+}
@@ -0,0 +1,165 @@
+package notifications
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// outbox statuses, pending entries are picked up by the delivery worker
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	// StatusSkipped is set when the player has no device to push to
+	StatusSkipped = "skipped"
+	// StatusDead is set once the delivery failed too often, the entry waits for a manual retry
+	StatusDead = "dead"
+)
+
+// Data is the free form payload of a notification
+type Data map[string]string
+
+// Value enables serialization to SQL
+func (d Data) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(d)
+}
+
+// Scan enables deserialization from SQL
+func (d *Data) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	}
+	return fmt.Errorf("unsupported data type %T", src)
+}
+
+// NotificationStoreModel is an entry of the inbox of a player
+type NotificationStoreModel struct {
+	ID        uuid.UUID    `db:"id"`
+	ClientID  uuid.UUID    `db:"client_id"`
+	Kind      string       `db:"kind"`
+	Title     string       `db:"title"`
+	Body      string       `db:"body"`
+	Data      Data         `db:"data"`
+	ReadAt    sql.NullTime `db:"read_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+// OutboxStoreModel is a push waiting for delivery, it is written with the inbox entry and carries its content
+type OutboxStoreModel struct {
+	ID             uuid.UUID `db:"id"`
+	NotificationID uuid.UUID `db:"notification_id"`
+	ClientID       uuid.UUID `db:"client_id"`
+	Title          string    `db:"title"`
+	Body           string    `db:"body"`
+	Data           Data      `db:"data"`
+	Status         string    `db:"status"`
+	Attempts       int       `db:"attempts"`
+	NextAttemptAt  time.Time `db:"next_attempt_at"`
+	LastError      string    `db:"last_error"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+type DeviceStoreModel struct {
+	Token     string    `db:"token"`
+	ClientID  uuid.UUID `db:"client_id"`
+	Platform  string    `db:"platform"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// SettingsStoreModel holds the quiet hours of a player in minutes of the day, start equal to end disables them
+type SettingsStoreModel struct {
+	ClientID   uuid.UUID `db:"client_id"`
+	QuietStart int       `db:"quiet_start"`
+	QuietEnd   int       `db:"quiet_end"`
+	Timezone   string    `db:"timezone"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+type NotificationPayload struct {
+	ClientID string `json:"clientId,omitempty"`
+	Kind     string `json:"kind"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	Data     Data   `json:"data,omitempty"`
+	// Push also delivers the notification to the devices of the player
+	Push bool `json:"push"`
+}
+
+type DevicePayload struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+// QuietHours are "HH:MM" times in the timezone of the player, pushes are held back between start and end
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
+
+type Notification struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Data      Data      `json:"data,omitempty"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Inbox struct {
+	Unread        int            `json:"unread"`
+	Notifications []Notification `json:"notifications"`
+}
+
+type OutboxEntry struct {
+	ID             string    `json:"id"`
+	NotificationID string    `json:"notificationId"`
+	ClientID       string    `json:"clientId"`
+	Title          string    `json:"title"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time `json:"nextAttemptAt"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func toNotification(m *NotificationStoreModel) Notification {
+	return Notification{
+		ID:        m.ID.String(),
+		Kind:      m.Kind,
+		Title:     m.Title,
+		Body:      m.Body,
+		Data:      m.Data,
+		Read:      m.ReadAt.Valid,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func toOutboxEntry(m *OutboxStoreModel) OutboxEntry {
+	return OutboxEntry{
+		ID:             m.ID.String(),
+		NotificationID: m.NotificationID.String(),
+		ClientID:       m.ClientID.String(),
+		Title:          m.Title,
+		Status:         m.Status,
+		Attempts:       m.Attempts,
+		LastError:      m.LastError,
+		NextAttemptAt:  m.NextAttemptAt,
+		CreatedAt:      m.CreatedAt,
+	}
+}
@@ -0,0 +1,296 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	notificationsAllCols = "id, client_id, kind, title, body, data, read_at, created_at"
+	notificationsTable   = "notifications"
+	outboxAllCols        = "id, notification_id, client_id, title, body, data, status, attempts, next_attempt_at, last_error, created_at, updated_at"
+	outboxTable          = "notification_outbox"
+	devicesAllCols       = "token, client_id, platform, created_at"
+	devicesTable         = "notification_devices"
+	settingsAllCols      = "client_id, quiet_start, quiet_end, timezone, updated_at"
+	settingsTable        = "notification_settings"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.notifications.store"),
+	}
+}
+
+func (p Postgres) CreateNotification(ctx context.Context, model *NotificationStoreModel, outbox *OutboxStoreModel) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("CreateNotification: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO notifications (
+	id,
+	client_id,
+	kind,
+	title,
+	body,
+	data,
+	created_at
+	) VALUES (
+	:id,
+	:client_id,
+	:kind,
+	:title,
+	:body,
+	:data,
+	:created_at
+	)`
+	if _, err := tx.NamedExecContext(ctx, stmt, *model); err != nil {
+		p.logger.Error("CreateNotification: failed to insert notification to db", zap.Error(err))
+		return err
+	}
+	if outbox != nil {
+		stmt := `INSERT INTO notification_outbox (
+		id,
+		notification_id,
+		client_id,
+		title,
+		body,
+		data,
+		status,
+		attempts,
+		next_attempt_at,
+		last_error,
+		created_at,
+		updated_at
+		) VALUES (
+		:id,
+		:notification_id,
+		:client_id,
+		:title,
+		:body,
+		:data,
+		:status,
+		:attempts,
+		:next_attempt_at,
+		:last_error,
+		:created_at,
+		:updated_at
+		)`
+		if _, err := tx.NamedExecContext(ctx, stmt, *outbox); err != nil {
+			p.logger.Error("CreateNotification: failed to insert outbox entry to db", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) ListNotifications(ctx context.Context, clientID string, unreadOnly bool, limit int) ([]NotificationStoreModel, error) {
+	stmt := "SELECT " + notificationsAllCols + " FROM " + notificationsTable + `
+	WHERE client_id=$1 AND (NOT $2 OR read_at IS NULL)
+	ORDER BY created_at DESC
+	LIMIT $3`
+	res := make([]NotificationStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID, unreadOnly, limit); err != nil {
+		p.logger.Error("ListNotifications: failed to list notifications from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) CountUnread(ctx context.Context, clientID string) (int, error) {
+	stmt := "SELECT count(*) FROM " + notificationsTable + " WHERE client_id=$1 AND read_at IS NULL"
+	var count int
+	if err := p.db.GetContext(ctx, &count, stmt, clientID); err != nil {
+		p.logger.Error("CountUnread: failed to count notifications in db", zap.Error(err))
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p Postgres) MarkRead(ctx context.Context, clientID, id string, at time.Time) error {
+	stmt := "UPDATE " + notificationsTable + " SET read_at=COALESCE(read_at, $3) WHERE id=$1 AND client_id=$2"
+	res, err := p.db.ExecContext(ctx, stmt, id, clientID, at)
+	if err != nil {
+		p.logger.Error("MarkRead: failed to update notification in db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) MarkAllRead(ctx context.Context, clientID string, at time.Time) (int64, error) {
+	stmt := "UPDATE " + notificationsTable + " SET read_at=$2 WHERE client_id=$1 AND read_at IS NULL"
+	res, err := p.db.ExecContext(ctx, stmt, clientID, at)
+	if err != nil {
+		p.logger.Error("MarkAllRead: failed to update notifications in db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ClaimOutbox leases the entries with SKIP LOCKED so several workers share the outbox
+func (p Postgres) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxStoreModel, error) {
+	stmt := "UPDATE " + outboxTable + ` SET next_attempt_at=$2, updated_at=$1
+	WHERE id IN (
+		SELECT id FROM ` + outboxTable + `
+		WHERE status='` + StatusPending + `' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + outboxAllCols
+	res := make([]OutboxStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, now, now.Add(lease), limit); err != nil {
+		p.logger.Error("ClaimOutbox: failed to claim outbox entries", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) GetOutbox(ctx context.Context, id string) (*OutboxStoreModel, error) {
+	stmt := "SELECT " + outboxAllCols + " FROM " + outboxTable + " WHERE id=$1"
+	var outbox OutboxStoreModel
+	if err := p.db.GetContext(ctx, &outbox, stmt, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetOutbox: failed to get outbox entry from db", zap.Error(err))
+		return nil, err
+	}
+	return &outbox, nil
+}
+
+func (p Postgres) UpdateOutbox(ctx context.Context, model *OutboxStoreModel) error {
+	stmt := `UPDATE notification_outbox SET
+	status=:status,
+	attempts=:attempts,
+	next_attempt_at=:next_attempt_at,
+	last_error=:last_error,
+	updated_at=:updated_at
+	WHERE id=:id
+	`
+	res, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("UpdateOutbox: failed to update outbox entry in db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) ListOutbox(ctx context.Context, status string, limit int) ([]OutboxStoreModel, error) {
+	stmt := "SELECT " + outboxAllCols + " FROM " + outboxTable + `
+	WHERE status=$1
+	ORDER BY created_at DESC
+	LIMIT $2`
+	res := make([]OutboxStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, status, limit); err != nil {
+		p.logger.Error("ListOutbox: failed to list outbox entries from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) UpsertDevice(ctx context.Context, model *DeviceStoreModel) error {
+	stmt := `INSERT INTO notification_devices (
+	token,
+	client_id,
+	platform,
+	created_at
+	) VALUES (
+	:token,
+	:client_id,
+	:platform,
+	:created_at
+	) ON CONFLICT (token) DO UPDATE SET
+	client_id=EXCLUDED.client_id,
+	platform=EXCLUDED.platform,
+	created_at=EXCLUDED.created_at`
+	if _, err := p.db.NamedExecContext(ctx, stmt, *model); err != nil {
+		p.logger.Error("UpsertDevice: failed to upsert device in db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) DeleteDevice(ctx context.Context, clientID, token string) error {
+	stmt := "DELETE FROM " + devicesTable + " WHERE client_id=$1 AND token=$2"
+	res, err := p.db.ExecContext(ctx, stmt, clientID, token)
+	if err != nil {
+		p.logger.Error("DeleteDevice: failed to delete device from db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) ListDevices(ctx context.Context, clientID string) ([]DeviceStoreModel, error) {
+	stmt := "SELECT " + devicesAllCols + " FROM " + devicesTable + " WHERE client_id=$1"
+	res := make([]DeviceStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID); err != nil {
+		p.logger.Error("ListDevices: failed to list devices from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) GetSettings(ctx context.Context, clientID string) (*SettingsStoreModel, error) {
+	stmt := "SELECT " + settingsAllCols + " FROM " + settingsTable + " WHERE client_id=$1"
+	var settings SettingsStoreModel
+	if err := p.db.GetContext(ctx, &settings, stmt, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetSettings: failed to get settings from db", zap.Error(err))
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (p Postgres) UpsertSettings(ctx context.Context, model *SettingsStoreModel) error {
+	stmt := `INSERT INTO notification_settings (
+	client_id,
+	quiet_start,
+	quiet_end,
+	timezone,
+	updated_at
+	) VALUES (
+	:client_id,
+	:quiet_start,
+	:quiet_end,
+	:timezone,
+	:updated_at
+	) ON CONFLICT (client_id) DO UPDATE SET
+	quiet_start=EXCLUDED.quiet_start,
+	quiet_end=EXCLUDED.quiet_end,
+	timezone=EXCLUDED.timezone,
+	updated_at=EXCLUDED.updated_at`
+	if _, err := p.db.NamedExecContext(ctx, stmt, *model); err != nil {
+		p.logger.Error("UpsertSettings: failed to upsert settings in db", zap.Error(err))
+		return err
+	}
+	return nil
+}
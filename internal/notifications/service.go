@@ -0,0 +1,463 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"geogame/internal/encounters"
+	"geogame/internal/geofences"
+	"geogame/internal/push"
+)
+
+const inboxLimit = 100
+
+var (
+	ErrInvalidNotification  = errors.New("invalid notification")
+	ErrNotificationNotFound = errors.New("notification not found")
+	ErrInvalidDevice        = errors.New("invalid device")
+	ErrDeviceNotFound       = errors.New("device not found")
+	ErrInvalidQuietHours    = errors.New("invalid quiet hours")
+	ErrDeadLetterNotFound   = errors.New("dead letter not found")
+)
+
+type Service interface {
+	// Notify writes to the inbox of the player and queues the push when asked for
+	Notify(ctx context.Context, clientID string, payload NotificationPayload) (*Notification, error)
+	Inbox(ctx context.Context, clientID string, unreadOnly bool) (*Inbox, error)
+	MarkRead(ctx context.Context, clientID, id string) error
+	MarkAllRead(ctx context.Context, clientID string) error
+	RegisterDevice(ctx context.Context, clientID string, payload DevicePayload) error
+	UnregisterDevice(ctx context.Context, clientID, token string) error
+	QuietHours(ctx context.Context, clientID string) (*QuietHours, error)
+	UpdateQuietHours(ctx context.Context, clientID string, payload QuietHours) (*QuietHours, error)
+	// Deliver pushes the due outbox entries, it is the task of the delivery worker
+	Deliver(ctx context.Context) error
+	DeadLetters(ctx context.Context) ([]OutboxEntry, error)
+	Retry(ctx context.Context, id string) error
+	encounters.Listener
+	geofences.Subscriber
+}
+
+type Config struct {
+	DeliveryInterval time.Duration `env:"NOTIFICATION_DELIVERY_INTERVAL" envDefault:"10s"`
+	BatchSize        int           `env:"NOTIFICATION_BATCH_SIZE" envDefault:"100"`
+	// Lease hides a claimed entry from the other workers while it is delivered
+	Lease       time.Duration `env:"NOTIFICATION_LEASE" envDefault:"1m"`
+	MaxAttempts int           `env:"NOTIFICATION_MAX_ATTEMPTS" envDefault:"5"`
+	// RetryBackoff is the wait after the first failed attempt, it doubles with every attempt
+	RetryBackoff time.Duration `env:"NOTIFICATION_RETRY_BACKOFF" envDefault:"30s"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DeliveryInterval: 10 * time.Second,
+		BatchSize:        100,
+		Lease:            time.Minute,
+		MaxAttempts:      5,
+		RetryBackoff:     30 * time.Second,
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	sender    push.PushSender
+	config    Config
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, sender push.PushSender, config Config, dbTimeOut time.Duration) *DefaultService {
+	return &DefaultService{
+		logger:    logger,
+		store:     store,
+		sender:    sender,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+}
+
+func (d *DefaultService) Notify(ctx context.Context, clientID string, payload NotificationPayload) (*Notification, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown player %s", ErrInvalidNotification, clientID)
+	}
+	if strings.TrimSpace(payload.Kind) == "" || strings.TrimSpace(payload.Title) == "" {
+		return nil, fmt.Errorf("%w: kind and title are required", ErrInvalidNotification)
+	}
+	now := time.Now().UTC()
+	model := NotificationStoreModel{
+		ID:        uuid.New(),
+		ClientID:  id,
+		Kind:      payload.Kind,
+		Title:     payload.Title,
+		Body:      payload.Body,
+		Data:      payload.Data,
+		CreatedAt: now,
+	}
+	var outbox *OutboxStoreModel
+	if payload.Push {
+		outbox = &OutboxStoreModel{
+			ID:             uuid.New(),
+			NotificationID: model.ID,
+			ClientID:       id,
+			Title:          payload.Title,
+			Body:           payload.Body,
+			Data:           payload.Data,
+			Status:         StatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateNotification(dbCtx, &model, outbox); err != nil {
+		d.logger.Error("Notify: failed to create notification", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to create notification:" + err.Error())
+	}
+	res := toNotification(&model)
+	return &res, nil
+}
+
+func (d *DefaultService) Inbox(ctx context.Context, clientID string, unreadOnly bool) (*Inbox, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListNotifications(dbCtx, clientID, unreadOnly, inboxLimit)
+	if err != nil {
+		d.logger.Error("Inbox: failed to list notifications", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list notifications:" + err.Error())
+	}
+	unread, err := d.store.CountUnread(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("Inbox: failed to count unread notifications", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list notifications:" + err.Error())
+	}
+	res := &Inbox{Unread: unread, Notifications: make([]Notification, 0, len(models))}
+	for i := range models {
+		res.Notifications = append(res.Notifications, toNotification(&models[i]))
+	}
+	return res, nil
+}
+
+func (d *DefaultService) MarkRead(ctx context.Context, clientID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotificationNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.MarkRead(dbCtx, clientID, id, time.Now().UTC()); err != nil {
+		if err == ErrNotFound {
+			return ErrNotificationNotFound
+		}
+		d.logger.Error("MarkRead: failed to mark notification read", zap.String("clientID", clientID), zap.String("notificationID", id), zap.Error(err))
+		return errors.New("failed to mark notification read:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) MarkAllRead(ctx context.Context, clientID string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if _, err := d.store.MarkAllRead(dbCtx, clientID, time.Now().UTC()); err != nil {
+		d.logger.Error("MarkAllRead: failed to mark notifications read", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to mark notifications read:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) RegisterDevice(ctx context.Context, clientID string, payload DevicePayload) error {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(payload.Token) == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidDevice)
+	}
+	switch push.Platform(payload.Platform) {
+	case push.PlatformFCM, push.PlatformAPNs:
+	default:
+		return fmt.Errorf("%w: unknown platform %q", ErrInvalidDevice, payload.Platform)
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	model := DeviceStoreModel{
+		Token:     payload.Token,
+		ClientID:  id,
+		Platform:  payload.Platform,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.UpsertDevice(dbCtx, &model); err != nil {
+		d.logger.Error("RegisterDevice: failed to register device", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to register device:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) UnregisterDevice(ctx context.Context, clientID, token string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.DeleteDevice(dbCtx, clientID, token); err != nil {
+		if err == ErrNotFound {
+			return ErrDeviceNotFound
+		}
+		d.logger.Error("UnregisterDevice: failed to delete device", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to unregister device:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) QuietHours(ctx context.Context, clientID string) (*QuietHours, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	settings, err := d.store.GetSettings(dbCtx, clientID)
+	if err != nil {
+		if err == ErrNotFound {
+			return &QuietHours{Start: "00:00", End: "00:00", Timezone: "UTC"}, nil
+		}
+		d.logger.Error("QuietHours: failed to get settings", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to get quiet hours:" + err.Error())
+	}
+	return toQuietHours(settings), nil
+}
+
+func (d *DefaultService) UpdateQuietHours(ctx context.Context, clientID string, payload QuietHours) (*QuietHours, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, err
+	}
+	start, err := parseClock(payload.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(payload.End)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Timezone == "" {
+		payload.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(payload.Timezone); err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidQuietHours, payload.Timezone)
+	}
+	settings := SettingsStoreModel{
+		ClientID:   id,
+		QuietStart: start,
+		QuietEnd:   end,
+		Timezone:   payload.Timezone,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpsertSettings(dbCtx, &settings); err != nil {
+		d.logger.Error("UpdateQuietHours: failed to update settings", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to update quiet hours:" + err.Error())
+	}
+	return toQuietHours(&settings), nil
+}
+
+// parseClock returns the minutes of the day of a "HH:MM" time
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a HH:MM time", ErrInvalidQuietHours, s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func toQuietHours(s *SettingsStoreModel) *QuietHours {
+	return &QuietHours{
+		Start:    fmt.Sprintf("%02d:%02d", s.QuietStart/60, s.QuietStart%60),
+		End:      fmt.Sprintf("%02d:%02d", s.QuietEnd/60, s.QuietEnd%60),
+		Timezone: s.Timezone,
+	}
+}
+
+// quietUntil returns the end of the quiet hours when now is inside them
+func quietUntil(s *SettingsStoreModel, now time.Time) (time.Time, bool) {
+	if s == nil || s.QuietStart == s.QuietEnd {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	quiet := minute >= s.QuietStart && minute < s.QuietEnd
+	if s.QuietStart > s.QuietEnd {
+		// the quiet hours span midnight
+		quiet = minute >= s.QuietStart || minute < s.QuietEnd
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+	end := time.Date(local.Year(), local.Month(), local.Day(), s.QuietEnd/60, s.QuietEnd%60, 0, 0, loc)
+	if !end.After(local) {
+		end = time.Date(local.Year(), local.Month(), local.Day()+1, s.QuietEnd/60, s.QuietEnd%60, 0, 0, loc)
+	}
+	return end, true
+}
+
+func (d *DefaultService) Deliver(ctx context.Context) error {
+	now := time.Now().UTC()
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	entries, err := d.store.ClaimOutbox(dbCtx, now, d.config.Lease, d.config.BatchSize)
+	if err != nil {
+		d.logger.Error("Deliver: failed to claim outbox", zap.Error(err))
+		return err
+	}
+	for i := range entries {
+		// a failed entry stays leased and is picked up again once the lease ran out
+		if err := d.deliver(ctx, &entries[i], now); err != nil {
+			d.logger.Error("Deliver: failed to deliver outbox entry", zap.String("outboxID", entries[i].ID.String()), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (d *DefaultService) deliver(ctx context.Context, o *OutboxStoreModel, now time.Time) error {
+	clientID := o.ClientID.String()
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	settings, err := d.store.GetSettings(dbCtx, clientID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	o.UpdatedAt = now
+	if until, quiet := quietUntil(settings, now); quiet {
+		// held back without counting an attempt
+		o.NextAttemptAt = until.UTC()
+		return d.store.UpdateOutbox(dbCtx, o)
+	}
+	devices, err := d.store.ListDevices(dbCtx, clientID)
+	if err != nil {
+		return err
+	}
+
+	delivered := 0
+	var lastErr error
+	for _, device := range devices {
+		err := d.sender.Send(ctx, push.Message{
+			Token:    device.Token,
+			Platform: push.Platform(device.Platform),
+			Title:    o.Title,
+			Body:     o.Body,
+			Data:     o.Data,
+		})
+		switch {
+		case err == nil:
+			delivered++
+		case errors.Is(err, push.ErrInvalidToken):
+			if err := d.store.DeleteDevice(dbCtx, clientID, device.Token); err != nil && err != ErrNotFound {
+				d.logger.Error("deliver: failed to delete invalid device", zap.String("clientID", clientID), zap.Error(err))
+			}
+		case errors.Is(err, push.ErrUnsupportedPlatform):
+			d.logger.Warn("deliver: no sender for the platform", zap.String("clientID", clientID), zap.String("platform", device.Platform))
+		default:
+			lastErr = err
+		}
+	}
+
+	switch {
+	case delivered > 0:
+		// a retry would push twice to the devices that got it
+		o.Status = StatusSent
+	case lastErr == nil:
+		o.Status = StatusSkipped
+	default:
+		o.Attempts++
+		o.LastError = lastErr.Error()
+		if o.Attempts >= d.config.MaxAttempts {
+			o.Status = StatusDead
+			d.logger.Warn("deliver: giving up on outbox entry", zap.String("outboxID", o.ID.String()), zap.Int("attempts", o.Attempts), zap.Error(lastErr))
+		} else {
+			o.NextAttemptAt = now.Add(d.config.RetryBackoff << uint(o.Attempts-1))
+		}
+	}
+	return d.store.UpdateOutbox(dbCtx, o)
+}
+
+func (d *DefaultService) DeadLetters(ctx context.Context) ([]OutboxEntry, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListOutbox(dbCtx, StatusDead, inboxLimit)
+	if err != nil {
+		d.logger.Error("DeadLetters: failed to list outbox", zap.Error(err))
+		return nil, errors.New("failed to list dead letters:" + err.Error())
+	}
+	res := make([]OutboxEntry, 0, len(models))
+	for i := range models {
+		res = append(res, toOutboxEntry(&models[i]))
+	}
+	return res, nil
+}
+
+// Retry puts a dead letter back into the outbox with fresh attempts
+func (d *DefaultService) Retry(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrDeadLetterNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	o, err := d.store.GetOutbox(dbCtx, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return ErrDeadLetterNotFound
+		}
+		d.logger.Error("Retry: failed to get outbox entry", zap.String("outboxID", id), zap.Error(err))
+		return errors.New("failed to retry dead letter:" + err.Error())
+	}
+	if o.Status != StatusDead {
+		return ErrDeadLetterNotFound
+	}
+	now := time.Now().UTC()
+	o.Status = StatusPending
+	o.Attempts = 0
+	o.NextAttemptAt = now
+	o.UpdatedAt = now
+	if err := d.store.UpdateOutbox(dbCtx, o); err != nil {
+		d.logger.Error("Retry: failed to update outbox entry", zap.String("outboxID", id), zap.Error(err))
+		return errors.New("failed to retry dead letter:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) OnEncounter(ctx context.Context, clientID string, encounter encounters.Encounter) error {
+	_, err := d.Notify(ctx, clientID, NotificationPayload{
+		Kind:  "encounter",
+		Title: "You met " + encounter.Handle,
+		Data:  Data{"encounterId": encounter.ID, "playerId": encounter.PlayerID},
+		Push:  true,
+	})
+	return err
+}
+
+func (d *DefaultService) OnGeofenceEvent(ctx context.Context, event geofences.Event) error {
+	var title string
+	switch event.Type {
+	case geofences.EventEnter:
+		title = "You entered " + event.FenceName
+	case geofences.EventExit:
+		title = "You left " + event.FenceName
+	case geofences.EventDwell:
+		title = "You are spending time at " + event.FenceName
+	default:
+		return nil
+	}
+	_, err := d.Notify(ctx, event.ClientID, NotificationPayload{
+		Kind:  "geofence",
+		Title: title,
+		Data:  Data{"fenceId": event.FenceID, "type": string(event.Type)},
+		Push:  true,
+	})
+	return err
+}
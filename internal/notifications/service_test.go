@@ -0,0 +1,173 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/encounters"
+	"geogame/internal/geofences"
+	"geogame/internal/push"
+)
+
+func newTestService(sender push.PushSender) *DefaultService {
+	config := DefaultConfig()
+	config.MaxAttempts = 2
+	// failed entries are due again right away
+	config.RetryBackoff = 0
+	return NewDefaultService(zap.NewNop(), NewMemStore(), sender, config, time.Second)
+}
+
+func TestDefaultService_Inbox(t *testing.T) {
+	d := newTestService(push.NewMockSender())
+	clientID := uuid.New().String()
+
+	_, err := d.Notify(context.TODO(), clientID, NotificationPayload{Kind: "news"})
+	assert.True(t, errors.Is(err, ErrInvalidNotification))
+	first, err := d.Notify(context.TODO(), clientID, NotificationPayload{Kind: "news", Title: "Hello"})
+	assert.Nil(t, err)
+	assert.Nil(t, d.OnEncounter(context.TODO(), clientID, encounters.Encounter{ID: "1", PlayerID: "2", Handle: "Bob"}))
+	assert.Nil(t, d.OnGeofenceEvent(context.TODO(), geofences.Event{ClientID: clientID, FenceID: "3", FenceName: "Park", Type: geofences.EventEnter}))
+
+	inbox, err := d.Inbox(context.TODO(), clientID, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, inbox.Unread)
+	assert.Len(t, inbox.Notifications, 3)
+
+	assert.Nil(t, d.MarkRead(context.TODO(), clientID, first.ID))
+	assert.Equal(t, ErrNotificationNotFound, d.MarkRead(context.TODO(), uuid.New().String(), first.ID))
+	assert.Equal(t, ErrNotificationNotFound, d.MarkRead(context.TODO(), clientID, "unknown"))
+	inbox, err = d.Inbox(context.TODO(), clientID, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, inbox.Unread)
+	assert.Len(t, inbox.Notifications, 2)
+
+	assert.Nil(t, d.MarkAllRead(context.TODO(), clientID))
+	inbox, err = d.Inbox(context.TODO(), clientID, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, inbox.Unread)
+	assert.Empty(t, inbox.Notifications)
+}
+
+func TestDefaultService_Deliver(t *testing.T) {
+	sent := make([]push.Message, 0)
+	sender := push.NewMockSender()
+	sender.SendFunc = func(msg push.Message) error {
+		switch msg.Token {
+		case "gone":
+			return push.ErrInvalidToken
+		case "down":
+			return errors.New("unavailable")
+		}
+		sent = append(sent, msg)
+		return nil
+	}
+	d := newTestService(sender)
+	clientID := uuid.New().String()
+
+	assert.True(t, errors.Is(d.RegisterDevice(context.TODO(), clientID, DevicePayload{Token: "a", Platform: "web"}), ErrInvalidDevice))
+	assert.Nil(t, d.RegisterDevice(context.TODO(), clientID, DevicePayload{Token: "ok", Platform: "fcm"}))
+	assert.Nil(t, d.RegisterDevice(context.TODO(), clientID, DevicePayload{Token: "gone", Platform: "apns"}))
+
+	_, err := d.Notify(context.TODO(), clientID, NotificationPayload{Kind: "news", Title: "Hello", Push: true})
+	assert.Nil(t, err)
+	// inbox only
+	_, err = d.Notify(context.TODO(), clientID, NotificationPayload{Kind: "news", Title: "Quiet"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, d.Deliver(context.TODO()))
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "Hello", sent[0].Title)
+	// the invalid token is forgotten
+	devices, err := d.store.ListDevices(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Len(t, devices, 1)
+	// nothing left to deliver
+	assert.Nil(t, d.Deliver(context.TODO()))
+	assert.Len(t, sent, 1)
+}
+
+func TestDefaultService_DeadLetters(t *testing.T) {
+	d := newTestService(push.NewMockSender())
+	d.sender.(*push.MockSender).SendFunc = func(msg push.Message) error {
+		return errors.New("unavailable")
+	}
+	clientID := uuid.New().String()
+	assert.Nil(t, d.RegisterDevice(context.TODO(), clientID, DevicePayload{Token: "down", Platform: "fcm"}))
+	_, err := d.Notify(context.TODO(), clientID, NotificationPayload{Kind: "news", Title: "Hello", Push: true})
+	assert.Nil(t, err)
+
+	assert.Nil(t, d.Deliver(context.TODO()))
+	dead, err := d.DeadLetters(context.TODO())
+	assert.Nil(t, err)
+	assert.Empty(t, dead)
+
+	time.Sleep(time.Millisecond)
+	assert.Nil(t, d.Deliver(context.TODO()))
+	dead, err = d.DeadLetters(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, "unavailable", dead[0].LastError)
+
+	d.sender.(*push.MockSender).SendFunc = func(msg push.Message) error {
+		return nil
+	}
+	assert.Equal(t, ErrDeadLetterNotFound, d.Retry(context.TODO(), uuid.New().String()))
+	assert.Nil(t, d.Retry(context.TODO(), dead[0].ID))
+	assert.Nil(t, d.Deliver(context.TODO()))
+	dead, err = d.DeadLetters(context.TODO())
+	assert.Nil(t, err)
+	assert.Empty(t, dead)
+	// only dead letters can be retried
+	sent, err := d.store.ListOutbox(context.TODO(), StatusSent, 10)
+	assert.Nil(t, err)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, ErrDeadLetterNotFound, d.Retry(context.TODO(), sent[0].ID.String()))
+}
+
+func TestDefaultService_QuietHours(t *testing.T) {
+	d := newTestService(push.NewMockSender())
+	clientID := uuid.New().String()
+
+	res, err := d.QuietHours(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Equal(t, &QuietHours{Start: "00:00", End: "00:00", Timezone: "UTC"}, res)
+
+	_, err = d.UpdateQuietHours(context.TODO(), clientID, QuietHours{Start: "25:00", End: "07:00"})
+	assert.True(t, errors.Is(err, ErrInvalidQuietHours))
+	_, err = d.UpdateQuietHours(context.TODO(), clientID, QuietHours{Start: "22:00", End: "07:00", Timezone: "Mars/Olympus"})
+	assert.True(t, errors.Is(err, ErrInvalidQuietHours))
+	res, err = d.UpdateQuietHours(context.TODO(), clientID, QuietHours{Start: "22:00", End: "07:30", Timezone: "Europe/Stockholm"})
+	assert.Nil(t, err)
+	assert.Equal(t, &QuietHours{Start: "22:00", End: "07:30", Timezone: "Europe/Stockholm"}, res)
+
+	settings, err := d.store.GetSettings(context.TODO(), clientID)
+	assert.Nil(t, err)
+	loc, _ := time.LoadLocation("Europe/Stockholm")
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{name: "evening", now: time.Date(2020, 6, 1, 21, 59, 0, 0, loc)},
+		{name: "night", now: time.Date(2020, 6, 1, 23, 0, 0, 0, loc), wantQuiet: true, wantUntil: time.Date(2020, 6, 2, 7, 30, 0, 0, loc)},
+		{name: "early morning", now: time.Date(2020, 6, 2, 6, 0, 0, 0, loc), wantQuiet: true, wantUntil: time.Date(2020, 6, 2, 7, 30, 0, 0, loc)},
+		{name: "morning", now: time.Date(2020, 6, 2, 7, 30, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := quietUntil(settings, tt.now.UTC())
+			assert.Equal(t, tt.wantQuiet, quiet)
+			if tt.wantQuiet {
+				assert.True(t, tt.wantUntil.Equal(until))
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+type Store interface {
+	// CreateNotification writes the inbox entry and, when given, its outbox entry in one transaction
+	CreateNotification(ctx context.Context, model *NotificationStoreModel, outbox *OutboxStoreModel) error
+	ListNotifications(ctx context.Context, clientID string, unreadOnly bool, limit int) ([]NotificationStoreModel, error)
+	CountUnread(ctx context.Context, clientID string) (int, error)
+	MarkRead(ctx context.Context, clientID, id string, at time.Time) error
+	MarkAllRead(ctx context.Context, clientID string, at time.Time) (int64, error)
+	// ClaimOutbox leases the due pending entries by moving their next attempt by the lease, concurrent workers skip them
+	ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxStoreModel, error)
+	GetOutbox(ctx context.Context, id string) (*OutboxStoreModel, error)
+	UpdateOutbox(ctx context.Context, model *OutboxStoreModel) error
+	ListOutbox(ctx context.Context, status string, limit int) ([]OutboxStoreModel, error)
+	// UpsertDevice registers the token, a token registered by another player moves to the new one
+	UpsertDevice(ctx context.Context, model *DeviceStoreModel) error
+	DeleteDevice(ctx context.Context, clientID, token string) error
+	ListDevices(ctx context.Context, clientID string) ([]DeviceStoreModel, error)
+	GetSettings(ctx context.Context, clientID string) (*SettingsStoreModel, error)
+	UpsertSettings(ctx context.Context, model *SettingsStoreModel) error
+}
@@ -0,0 +1,223 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"geogame/internal/middleware"
+	"geogame/pkg"
+)
+
+// FakeUser is the account the fake provider signs in
+type FakeUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// FakeProvider is an in-process OpenID Connect provider for the tests,
+// it approves every authorization request for the current user
+type FakeProvider struct {
+	server *httptest.Server
+	key    *middleware.SigningKey
+	config ProviderConfig
+
+	mu    sync.Mutex
+	user  FakeUser
+	nonce string
+	codes map[string]fakeGrant
+}
+
+type fakeGrant struct {
+	user      FakeUser
+	nonce     string
+	challenge string
+	redirect  string
+}
+
+func NewFakeProvider(clientID, clientSecret string) (*FakeProvider, error) {
+	key, err := middleware.GenerateSigningKey(middleware.AlgRS256)
+	if err != nil {
+		return nil, err
+	}
+	f := &FakeProvider{
+		key:   key,
+		codes: make(map[string]fakeGrant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", f.discovery)
+	mux.HandleFunc("/jwks", f.jwks)
+	mux.HandleFunc("/authorize", f.authorize)
+	mux.HandleFunc("/token", f.token)
+	f.server = httptest.NewServer(mux)
+	f.config = ProviderConfig{
+		Name:         "fake",
+		Issuer:       f.server.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  "http://localhost:8080/v1/client/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+	return f, nil
+}
+
+// Config is the provider config of a client registered at the fake provider
+func (f *FakeProvider) Config() ProviderConfig {
+	return f.config
+}
+
+func (f *FakeProvider) Close() {
+	f.server.Close()
+}
+
+// SetUser changes the account signed in by the next authorization requests
+func (f *FakeProvider) SetUser(user FakeUser) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.user = user
+}
+
+// SetNonce overrides the nonce of the next ID tokens, an empty nonce echoes the one of the request
+func (f *FakeProvider) SetNonce(nonce string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nonce = nonce
+}
+
+// Authorize plays the browser of the player: it follows the authorization URL
+// and returns the parameters the provider redirects back with
+func (f *FakeProvider) Authorize(authURL string) (CallbackPayload, error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return CallbackPayload{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return CallbackPayload{}, errors.New("authorization request failed: " + resp.Status)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return CallbackPayload{}, err
+	}
+	q := location.Query()
+	return CallbackPayload{
+		State:            q.Get("state"),
+		Code:             q.Get("code"),
+		Error:            q.Get("error"),
+		ErrorDescription: q.Get("error_description"),
+	}, nil
+}
+
+func (f *FakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Discovery{
+		Issuer:                f.server.URL,
+		AuthorizationEndpoint: f.server.URL + "/authorize",
+		TokenEndpoint:         f.server.URL + "/token",
+		JWKSURI:               f.server.URL + "/jwks",
+		SigningAlgs:           []string{f.key.Method.Alg()},
+	})
+}
+
+func (f *FakeProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, middleware.NewJwtKeyRing(f.key).JWKS())
+}
+
+func (f *FakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != f.config.ClientID || q.Get("redirect_uri") != f.config.RedirectURL {
+		http.Error(w, "invalid client", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("state", q.Get("state"))
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		params.Set("error", "invalid_request")
+	} else {
+		code, err := pkg.RandomToken(16)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.mu.Lock()
+		f.codes[code] = fakeGrant{
+			user:      f.user,
+			nonce:     q.Get("nonce"),
+			challenge: q.Get("code_challenge"),
+			redirect:  q.Get("redirect_uri"),
+		}
+		f.mu.Unlock()
+		params.Set("code", code)
+	}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (f *FakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, tokenResponse{Error: "invalid_request"})
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != f.config.ClientID || secret != f.config.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, tokenResponse{Error: "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	f.mu.Lock()
+	grant, ok := f.codes[code]
+	delete(f.codes, code)
+	nonce := f.nonce
+	f.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != grant.redirect ||
+		CodeChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, tokenResponse{Error: "invalid_grant"})
+		return
+	}
+	if nonce == "" {
+		nonce = grant.nonce
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(f.key.Method, jwt.MapClaims{
+		"iss":            f.server.URL,
+		"sub":            grant.user.Subject,
+		"aud":            f.config.ClientID,
+		"exp":            now.Add(time.Minute * 5).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          grant.user.Email,
+		"email_verified": grant.user.EmailVerified,
+		"name":           grant.user.Name,
+	})
+	token.Header["kid"] = f.key.ID
+	idToken, err := token.SignedString(f.key.Private)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, tokenResponse{Error: "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, tokenResponse{AccessToken: code, TokenType: "Bearer", IDToken: idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,52 @@
+package oidc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu    sync.Mutex
+	flows map[string]*FlowStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		flows: make(map[string]*FlowStoreModel),
+	}
+}
+
+func (m *MemStore) CreateFlow(ctx context.Context, model *FlowStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *model
+	m.flows[model.State] = &c
+	return nil
+}
+
+func (m *MemStore) TakeFlow(ctx context.Context, state string) (*FlowStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	flow, ok := m.flows[state]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(m.flows, state)
+	return flow, nil
+}
+
+func (m *MemStore) DeleteExpiredFlows(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for state, flow := range m.flows {
+		if flow.ExpiresAt.Before(before) {
+			delete(m.flows, state)
+			n++
+		}
+	}
+	return n, nil
+}
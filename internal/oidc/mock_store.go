@@ -0,0 +1,40 @@
+package oidc
+
+import (
+	"context"
+	"time"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateFlowFunc         func(model *FlowStoreModel) error
+	TakeFlowFunc           func(state string) (*FlowStoreModel, error)
+	DeleteExpiredFlowsFunc func(before time.Time) (int64, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateFlowFunc: func(model *FlowStoreModel) error {
+			return nil
+		},
+		TakeFlowFunc: func(state string) (*FlowStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		DeleteExpiredFlowsFunc: func(before time.Time) (int64, error) {
+			return 0, nil
+		},
+	}
+}
+
+func (m *MockStore) CreateFlow(ctx context.Context, model *FlowStoreModel) error {
+	return m.CreateFlowFunc(model)
+}
+
+func (m *MockStore) TakeFlow(ctx context.Context, state string) (*FlowStoreModel, error) {
+	return m.TakeFlowFunc(state)
+}
+
+func (m *MockStore) DeleteExpiredFlows(ctx context.Context, before time.Time) (int64, error) {
+	return m.DeleteExpiredFlowsFunc(before)
+}
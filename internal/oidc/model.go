@@ -0,0 +1,120 @@
+package oidc
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// FlowStoreModel is an authorization request waiting for the provider to redirect the player back
+type FlowStoreModel struct {
+	State    string `db:"state"`
+	Provider string `db:"provider"`
+	Nonce    string `db:"nonce"`
+	Verifier string `db:"verifier"`
+	// ClientID is the guest upgraded by the flow, empty for a login
+	ClientID string `db:"client_id"`
+	// BindingHash is the hash of the secret kept by the user agent which started the flow
+	BindingHash string    `db:"binding_hash"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+type StartResponse struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+	// Binding is the secret the user agent has to present at the callback, it is sent in a cookie only
+	Binding   string    `json:"-"`
+	ExpiresAt time.Time `json:"-"`
+}
+
+// CallbackPayload holds the query parameters of the redirect from the provider
+type CallbackPayload struct {
+	State            string
+	Binding          string
+	Code             string
+	Error            string
+	ErrorDescription string
+}
+
+// Discovery is the subset of the provider metadata used by the login flow
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// IDTokenClaims are the claims of the ID token, the checks are done by the provider after parsing
+type IDTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	AuthorizedBy  string   `json:"azp"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+}
+
+// Valid is left to VerifyIDToken which knows the expected issuer, audience and nonce
+func (c *IDTokenClaims) Valid() error {
+	return nil
+}
+
+// audience is either a single string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool accepts the "true" strings sent by some providers for email_verified
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v bool
+	if err := json.Unmarshal(data, &v); err == nil {
+		*b = flexBool(v)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = flexBool(v)
+	return nil
+}
@@ -0,0 +1,81 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	flowsAllCols = "state, provider, nonce, verifier, client_id, binding_hash, created_at, expires_at"
+	flowsTable   = "oidc_flows"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.oidc.store"),
+	}
+}
+
+func (p Postgres) CreateFlow(ctx context.Context, model *FlowStoreModel) error {
+	stmt := `INSERT INTO oidc_flows (
+	state,
+	provider,
+	nonce,
+	verifier,
+	client_id,
+	binding_hash,
+	created_at,
+	expires_at
+	) VALUES (
+	:state,
+	:provider,
+	:nonce,
+	:verifier,
+	:client_id,
+	:binding_hash,
+	:created_at,
+	:expires_at
+	)`
+	if _, err := p.db.NamedExecContext(ctx, stmt, model); err != nil {
+		p.logger.Error("CreateFlow: failed to insert flow to db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) TakeFlow(ctx context.Context, state string) (*FlowStoreModel, error) {
+	stmt := "DELETE FROM " + flowsTable + " WHERE state=$1 RETURNING " + flowsAllCols
+	var res FlowStoreModel
+	if err := p.db.GetContext(ctx, &res, stmt, state); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("TakeFlow: failed to delete flow from db", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p Postgres) DeleteExpiredFlows(ctx context.Context, before time.Time) (int64, error) {
+	stmt := "DELETE FROM " + flowsTable + " WHERE expires_at < $1"
+	res, err := p.db.ExecContext(ctx, stmt, before)
+	if err != nil {
+		p.logger.Error("DeleteExpiredFlows: failed to delete flows from db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,214 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"geogame/internal/players"
+	"geogame/pkg"
+)
+
+var (
+	// ErrInvalidState is returned for unknown, used and expired states, and for the
+	// callbacks not coming from the user agent which started the flow
+	ErrInvalidState = errors.New("invalid or expired login state")
+	// ErrLoginDenied is returned when the player or the provider cancelled the authorization
+	ErrLoginDenied = errors.New("login was denied by the identity provider")
+)
+
+// IdentityLinker signs the players in with the identity asserted by a provider
+type IdentityLinker interface {
+	LoginWithIdentity(ctx context.Context, identity players.ExternalIdentity) (*players.APIResponse, error)
+	UpgradeWithIdentity(ctx context.Context, clientID string, identity players.ExternalIdentity) (*players.APIResponse, error)
+}
+
+type Service interface {
+	// Start creates the authorization request of the provider
+	Start(ctx context.Context, provider string) (*StartResponse, error)
+	// StartUpgrade creates the authorization request linking the provider to the guest
+	StartUpgrade(ctx context.Context, provider, clientID string) (*StartResponse, error)
+	// Callback completes the flow and issues the game tokens
+	Callback(ctx context.Context, payload CallbackPayload) (*players.APIResponse, error)
+	Cleanup(ctx context.Context) error
+}
+
+type Config struct {
+	// StateTTL is how long the player has to complete the login at the provider
+	StateTTL        time.Duration `env:"OIDC_STATE_TTL" envDefault:"10m"`
+	CleanupInterval time.Duration `env:"OIDC_CLEANUP_INTERVAL" envDefault:"1h"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		StateTTL:        time.Minute * 10,
+		CleanupInterval: time.Hour,
+	}
+}
+
+type Option func(*DefaultService)
+
+// WithProvider allows the players to sign in with the provider
+func WithProvider(p *Provider) Option {
+	return func(d *DefaultService) {
+		d.providers[p.Name()] = p
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	linker    IdentityLinker
+	config    Config
+	providers map[string]*Provider
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, linker IdentityLinker, config Config, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		linker:    linker,
+		config:    config,
+		providers: make(map[string]*Provider),
+		dbTimeOut: dbTimeOut,
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+func (d *DefaultService) Start(ctx context.Context, provider string) (*StartResponse, error) {
+	return d.start(ctx, provider, "")
+}
+
+func (d *DefaultService) StartUpgrade(ctx context.Context, provider, clientID string) (*StartResponse, error) {
+	return d.start(ctx, provider, clientID)
+}
+
+func (d *DefaultService) start(ctx context.Context, provider, clientID string) (*StartResponse, error) {
+	p, ok := d.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	state, err := pkg.RandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to start login:" + err.Error())
+	}
+	nonce, err := pkg.RandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to start login:" + err.Error())
+	}
+	verifier, err := pkg.RandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to start login:" + err.Error())
+	}
+	binding, err := pkg.RandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to start login:" + err.Error())
+	}
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		d.logger.Error("Start: failed to build the authorization request", zap.String("provider", provider), zap.Error(err))
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	flow := FlowStoreModel{
+		State:       state,
+		Provider:    provider,
+		Nonce:       nonce,
+		Verifier:    verifier,
+		ClientID:    clientID,
+		BindingHash: pkg.HashToken(binding),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(d.config.StateTTL),
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateFlow(dbCtx, &flow); err != nil {
+		d.logger.Error("Start: failed to store flow", zap.String("provider", provider), zap.Error(err))
+		return nil, errors.New("failed to start login:" + err.Error())
+	}
+	return &StartResponse{URL: authURL, State: state, Binding: binding, ExpiresAt: flow.ExpiresAt}, nil
+}
+
+func (d *DefaultService) Callback(ctx context.Context, payload CallbackPayload) (*players.APIResponse, error) {
+	// a callback without the binding can't come from the user agent which started the flow
+	if payload.State == "" || payload.Binding == "" {
+		return nil, ErrInvalidState
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	// the flow is consumed first so a state can't be replayed, even after an error
+	flow, err := d.store.TakeFlow(dbCtx, payload.State)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrInvalidState
+		}
+		d.logger.Error("Callback: failed to get flow", zap.Error(err))
+		return nil, errors.New("failed to login:" + err.Error())
+	}
+	if time.Now().After(flow.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+	if subtle.ConstantTimeCompare([]byte(flow.BindingHash), []byte(pkg.HashToken(payload.Binding))) != 1 {
+		d.logger.Warn("Callback: state used by another user agent", zap.String("provider", flow.Provider))
+		return nil, ErrInvalidState
+	}
+	if payload.Error != "" {
+		d.logger.Info("Callback: authorization denied", zap.String("provider", flow.Provider), zap.String("error", payload.Error), zap.String("description", payload.ErrorDescription))
+		return nil, fmt.Errorf("%w: %s", ErrLoginDenied, payload.Error)
+	}
+	if payload.Code == "" {
+		return nil, fmt.Errorf("%w: missing authorization code", ErrProvider)
+	}
+	p, ok := d.providers[flow.Provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	rawIDToken, err := p.Exchange(ctx, payload.Code, flow.Verifier)
+	if err != nil {
+		d.logger.Error("Callback: failed to exchange the code", zap.String("provider", flow.Provider), zap.Error(err))
+		return nil, err
+	}
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, flow.Nonce)
+	if err != nil {
+		d.logger.Warn("Callback: rejected id token", zap.String("provider", flow.Provider), zap.Error(err))
+		return nil, err
+	}
+
+	identity := players.ExternalIdentity{
+		Provider:      flow.Provider,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}
+	if flow.ClientID != "" {
+		return d.linker.UpgradeWithIdentity(ctx, flow.ClientID, identity)
+	}
+	return d.linker.LoginWithIdentity(ctx, identity)
+}
+
+func (d *DefaultService) Cleanup(ctx context.Context) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	n, err := d.store.DeleteExpiredFlows(dbCtx, time.Now().UTC())
+	if err != nil {
+		d.logger.Error("Cleanup: failed to delete expired flows", zap.Error(err))
+		return err
+	}
+	if n > 0 {
+		d.logger.Info("Cleanup: deleted expired flows", zap.Int64("count", n))
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/players"
+)
+
+func newTestService(t *testing.T) (*DefaultService, *FakeProvider, *players.MemStore) {
+	fake, err := NewFakeProvider("geogame", "secret")
+	assert.Nil(t, err)
+	store := players.NewMemStore(make(map[interface{}]*players.ClientStoreModel))
+	playersSvc := players.NewDefaultService(zap.NewNop(), store, time.Second, "secret")
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), playersSvc, DefaultConfig(), time.Second,
+		WithProvider(NewProvider(fake.Config(), nil)))
+	return d, fake, store
+}
+
+func login(t *testing.T, d *DefaultService, fake *FakeProvider) (*players.APIResponse, error) {
+	start, err := d.Start(context.TODO(), "fake")
+	assert.Nil(t, err)
+	payload, err := fake.Authorize(start.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, start.State, payload.State)
+	payload.Binding = start.Binding
+	return d.Callback(context.TODO(), payload)
+}
+
+func TestDefaultService_Callback(t *testing.T) {
+	d, fake, store := newTestService(t)
+	defer fake.Close()
+	existing := &players.ClientStoreModel{ID: uuid.New(), Name: "alice", Email: "alice@mail.com", Status: players.StatusVerified}
+	assert.Nil(t, store.CreateClient(context.TODO(), existing))
+
+	// the verified email links the existing account
+	fake.SetUser(FakeUser{Subject: "alice-sub", Email: existing.Email, EmailVerified: true})
+	res, err := login(t, d, fake)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+	assert.NotEmpty(t, res.RefreshToken)
+	identity, err := store.GetIdentity(context.TODO(), "fake", "alice-sub")
+	assert.Nil(t, err)
+	assert.Equal(t, existing.ID, identity.ClientID)
+
+	// an unknown email registers a new account
+	fake.SetUser(FakeUser{Subject: "bob-sub", Email: "bob@mail.com", EmailVerified: true, Name: "Bob"})
+	_, err = login(t, d, fake)
+	assert.Nil(t, err)
+	created, err := store.GetClientByEmail(context.TODO(), "bob@mail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "Bob", created.Name)
+
+	// an unverified email is not trusted for linking
+	fake.SetUser(FakeUser{Subject: "eve-sub", Email: existing.Email})
+	_, err = login(t, d, fake)
+	assert.Equal(t, players.ErrEmailNotVerified, err)
+}
+
+func TestDefaultService_Callback_Rejected(t *testing.T) {
+	d, fake, _ := newTestService(t)
+	defer fake.Close()
+	fake.SetUser(FakeUser{Subject: "alice-sub", Email: "alice@mail.com", EmailVerified: true})
+
+	_, err := d.Start(context.TODO(), "unknown")
+	assert.Equal(t, ErrUnknownProvider, err)
+
+	// the state can be used once
+	start, err := d.Start(context.TODO(), "fake")
+	assert.Nil(t, err)
+	payload, err := fake.Authorize(start.URL)
+	assert.Nil(t, err)
+	payload.Binding = start.Binding
+	_, err = d.Callback(context.TODO(), payload)
+	assert.Nil(t, err)
+	_, err = d.Callback(context.TODO(), payload)
+	assert.Equal(t, ErrInvalidState, err)
+
+	_, err = d.Callback(context.TODO(), CallbackPayload{State: "forged", Binding: start.Binding, Code: payload.Code})
+	assert.Equal(t, ErrInvalidState, err)
+
+	// only the user agent which started the login can complete it
+	start, err = d.Start(context.TODO(), "fake")
+	assert.Nil(t, err)
+	payload, err = fake.Authorize(start.URL)
+	assert.Nil(t, err)
+	_, err = d.Callback(context.TODO(), payload)
+	assert.Equal(t, ErrInvalidState, err)
+	payload.Binding = "other"
+	_, err = d.Callback(context.TODO(), payload)
+	assert.Equal(t, ErrInvalidState, err)
+
+	// an ID token of another login request is refused
+	fake.SetNonce("replayed")
+	_, err = login(t, d, fake)
+	assert.True(t, errors.Is(err, ErrInvalidIDToken))
+	fake.SetNonce("")
+
+	start, err = d.Start(context.TODO(), "fake")
+	assert.Nil(t, err)
+	_, err = d.Callback(context.TODO(), CallbackPayload{State: start.State, Binding: start.Binding, Error: "access_denied"})
+	assert.True(t, errors.Is(err, ErrLoginDenied))
+}
+
+func TestDefaultService_Callback_Upgrade(t *testing.T) {
+	d, fake, store := newTestService(t)
+	defer fake.Close()
+	guest := &players.ClientStoreModel{ID: uuid.New(), Name: "Guest-1", Status: players.StatusGuest}
+	assert.Nil(t, store.CreateGuest(context.TODO(), guest, &players.GuestStoreModel{ClientID: guest.ID, DeviceID: "device"}))
+
+	// the flow started by the guest upgrades it instead of registering a new account
+	fake.SetUser(FakeUser{Subject: "bob-sub", Email: "bob@mail.com", EmailVerified: true})
+	start, err := d.StartUpgrade(context.TODO(), "fake", guest.ID.String())
+	assert.Nil(t, err)
+	payload, err := fake.Authorize(start.URL)
+	assert.Nil(t, err)
+
+	// somebody else opening the callback URL can't link an account to the guest
+	_, err = d.Callback(context.TODO(), payload)
+	assert.Equal(t, ErrInvalidState, err)
+	stillGuest, err := store.GetClientByID(context.TODO(), guest.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, players.StatusGuest, stillGuest.Status)
+
+	payload.Binding = start.Binding
+	res, err := d.Callback(context.TODO(), payload)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+	upgraded, err := store.GetClientByEmail(context.TODO(), "bob@mail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, guest.ID, upgraded.ID)
+	assert.Equal(t, players.StatusVerified, upgraded.Status)
+	identity, err := store.GetIdentity(context.TODO(), "fake", "bob-sub")
+	assert.Nil(t, err)
+	assert.Equal(t, guest.ID, identity.ClientID)
+
+	// the identity signs in the former guest from now on
+	res, err = login(t, d, fake)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+}
+
+func TestProvider_VerifyIDToken_Issuer(t *testing.T) {
+	fake, err := NewFakeProvider("geogame", "")
+	assert.Nil(t, err)
+	defer fake.Close()
+	config := fake.Config()
+	config.Issuer += "/"
+	_, err = NewProvider(config, nil).Discover(context.TODO())
+	assert.True(t, errors.Is(err, ErrProvider))
+}
+
+func TestDefaultService_Cleanup(t *testing.T) {
+	store := NewMemStore()
+	d := NewDefaultService(zap.NewNop(), store, nil, DefaultConfig(), time.Second)
+	assert.Nil(t, store.CreateFlow(context.TODO(), &FlowStoreModel{State: "old", ExpiresAt: time.Now().Add(-time.Minute)}))
+	assert.Nil(t, store.CreateFlow(context.TODO(), &FlowStoreModel{State: "new", ExpiresAt: time.Now().Add(time.Minute)}))
+	assert.Nil(t, d.Cleanup(context.TODO()))
+	_, err := store.TakeFlow(context.TODO(), "old")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.TakeFlow(context.TODO(), "new")
+	assert.Nil(t, err)
+}
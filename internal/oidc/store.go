@@ -0,0 +1,17 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+type Store interface {
+	CreateFlow(ctx context.Context, model *FlowStoreModel) error
+	// TakeFlow deletes and returns the flow so a state can be used once
+	TakeFlow(ctx context.Context, state string) (*FlowStoreModel, error)
+	DeleteExpiredFlows(ctx context.Context, before time.Time) (int64, error)
+}
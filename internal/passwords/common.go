@@ -0,0 +1,58 @@
+package passwords
+
+// commonPasswords is the bundled deny list, the most used passwords of the public breach
+// compilations, they are compared lowercased
+var commonPasswords = map[string]struct{}{
+	"123456": {}, "password": {}, "12345678": {}, "qwerty": {}, "123456789": {}, "12345": {}, "1234": {},
+	"111111": {}, "1234567": {}, "dragon": {}, "123123": {}, "baseball": {}, "abc123": {}, "football": {},
+	"monkey": {}, "letmein": {}, "696969": {}, "shadow": {}, "master": {}, "666666": {}, "qwertyuiop": {},
+	"123321": {}, "mustang": {}, "1234567890": {}, "michael": {}, "654321": {}, "superman": {}, "1qaz2wsx": {},
+	"7777777": {}, "121212": {}, "000000": {}, "qazwsx": {}, "123qwe": {}, "killer": {}, "trustno1": {},
+	"jordan": {}, "jennifer": {}, "zxcvbnm": {}, "asdfgh": {}, "hunter": {}, "buster": {}, "soccer": {},
+	"harley": {}, "batman": {}, "andrew": {}, "tigger": {}, "sunshine": {}, "iloveyou": {}, "2000": {},
+	"charlie": {}, "robert": {}, "thomas": {}, "hockey": {}, "ranger": {}, "daniel": {}, "starwars": {},
+	"klaster": {}, "112233": {}, "george": {}, "computer": {}, "michelle": {}, "jessica": {}, "pepper": {},
+	"1111": {}, "zxcvbn": {}, "555555": {}, "11111111": {}, "131313": {}, "freedom": {}, "777777": {},
+	"pass": {}, "maggie": {}, "159753": {}, "aaaaaa": {}, "ginger": {}, "princess": {}, "joshua": {},
+	"cheese": {}, "amanda": {}, "summer": {}, "love": {}, "ashley": {}, "nicole": {}, "chelsea": {},
+	"biteme": {}, "matthew": {}, "access": {}, "yankees": {}, "987654321": {}, "dallas": {}, "austin": {},
+	"thunder": {}, "taylor": {}, "matrix": {}, "minecraft": {}, "william": {}, "corvette": {}, "hello": {},
+	"martin": {}, "heather": {}, "secret": {}, "merlin": {}, "diamond": {}, "1234qwer": {}, "gfhjkm": {},
+	"hammer": {}, "silver": {}, "222222": {}, "88888888": {}, "anthony": {}, "justin": {}, "test": {},
+	"bailey": {}, "q1w2e3r4t5": {}, "patrick": {}, "internet": {}, "scooter": {}, "orange": {}, "11111": {},
+	"golfer": {}, "cookie": {}, "richard": {}, "samantha": {}, "bigdog": {}, "guitar": {}, "jackson": {},
+	"whatever": {}, "mickey": {}, "chicken": {}, "sparky": {}, "snoopy": {}, "maverick": {}, "phoenix": {},
+	"camaro": {}, "peanut": {}, "morgan": {}, "welcome": {}, "falcon": {}, "cowboy": {}, "ferrari": {},
+	"samsung": {}, "andrea": {}, "smokey": {}, "steelers": {}, "joseph": {}, "mercedes": {}, "dakota": {},
+	"arsenal": {}, "eagles": {}, "melissa": {}, "boomer": {}, "booboo": {}, "spider": {}, "nascar": {},
+	"monster": {}, "tigers": {}, "yellow": {}, "xxxxxx": {}, "123123123": {}, "gateway": {}, "marina": {},
+	"diablo": {}, "bulldog": {}, "qwer1234": {}, "compaq": {}, "purple": {}, "hardcore": {}, "banana": {},
+	"junior": {}, "hannah": {}, "123654": {}, "porsche": {}, "lakers": {}, "iceman": {}, "money": {},
+	"cowboys": {}, "987654": {}, "london": {}, "tennis": {}, "999999": {}, "ncc1701": {}, "coffee": {},
+	"scooby": {}, "0000": {}, "miller": {}, "boston": {}, "q1w2e3r4": {}, "brandon": {}, "yamaha": {},
+	"chester": {}, "mother": {}, "forever": {}, "johnny": {}, "edward": {}, "333333": {}, "oliver": {},
+	"redsox": {}, "player": {}, "nikita": {}, "knight": {}, "fender": {}, "barney": {}, "midnight": {},
+	"please": {}, "brandy": {}, "chicago": {}, "badboy": {}, "slayer": {}, "rangers": {}, "charles": {},
+	"angel": {}, "flower": {}, "bigdaddy": {}, "rabbit": {}, "wizard": {}, "jasper": {}, "enter": {},
+	"rachel": {}, "chris": {}, "steven": {}, "winner": {}, "adidas": {}, "victoria": {}, "natasha": {},
+	"1q2w3e4r": {}, "jasmine": {}, "winter": {}, "prince": {}, "panties": {}, "marine": {}, "ghbdtn": {},
+	"fishing": {}, "cocacola": {}, "casper": {}, "james": {}, "232323": {}, "raiders": {}, "888888": {},
+	"marlboro": {}, "gandalf": {}, "asdfasdf": {}, "crystal": {}, "87654321": {}, "12344321": {}, "golden": {},
+	"8675309": {}, "lovely": {}, "password1": {}, "password12": {}, "password123": {}, "password1234": {},
+	"passw0rd": {}, "p@ssword": {}, "p@ssw0rd": {}, "passwort": {}, "motdepasse": {}, "contrasena": {},
+	"qwerty123": {}, "qwerty1234": {}, "qwertyuiop123": {}, "1q2w3e4r5t": {}, "1q2w3e4r5t6y": {},
+	"1qaz2wsx3edc": {}, "zaq12wsx": {}, "zaq1zaq1": {}, "asdfghjkl": {}, "zxcvbnm123": {}, "qazwsxedc": {},
+	"iloveyou1": {}, "iloveyou2": {}, "letmein1": {}, "letmein123": {}, "welcome1": {}, "welcome123": {},
+	"welcome2024": {}, "admin": {}, "admin123": {}, "admin1234": {}, "administrator": {}, "root": {},
+	"toor": {}, "changeme": {}, "changeme123": {}, "default": {}, "guest": {}, "user": {}, "abcdefgh": {},
+	"abcdefghi": {}, "abcdefghij": {}, "abcd1234": {}, "abc12345": {}, "abc123456": {}, "aa123456": {},
+	"a1b2c3d4": {}, "1234512345": {}, "0123456789": {}, "9876543210": {}, "1122334455": {}, "11223344": {},
+	"123454321": {}, "12341234": {}, "123456123456": {}, "1111111111": {}, "0000000000": {}, "00000000": {},
+	"99999999": {}, "55555555": {}, "66666666": {}, "12121212": {}, "123abc123": {}, "sunshine1": {},
+	"princess1": {}, "football1": {}, "baseball1": {}, "superman1": {}, "batman123": {}, "monkey123": {},
+	"dragon123": {}, "shadow123": {}, "master123": {}, "michael1": {}, "jennifer1": {}, "jordan23": {},
+	"starwars1": {}, "pokemon": {}, "pokemon123": {}, "minecraft1": {}, "fortnite": {}, "roblox": {},
+	"geogame": {}, "geogame123": {}, "loveme": {}, "lovelove": {}, "iloveu": {}, "babygirl": {}, "baby123": {},
+	"mypassword": {}, "secret123": {}, "secretpassword": {}, "letmeinplease": {}, "qwertyqwerty": {},
+	"asdf1234": {}, "zxcv1234": {},
+}
@@ -0,0 +1,181 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	// ErrMismatch is returned when the password doesn't match the hash
+	ErrMismatch = errors.New("password does not match")
+	// ErrUnknownFormat is returned for hashes of an algorithm the hasher doesn't know
+	ErrUnknownFormat = errors.New("unknown password hash format")
+)
+
+// Hasher hashes the passwords with the configured algorithm and verifies the hashes of every supported one
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify compares the password to the hash, rehash is true when the hash was made
+	// with another algorithm or other parameters than the configured ones
+	Verify(hash, password string) (rehash bool, err error)
+}
+
+// Config selects the algorithm of the new hashes, the stored hashes keep their parameters until the next login
+type Config struct {
+	Algorithm  string `env:"PASSWORD_HASHER" envDefault:"bcrypt"`
+	BcryptCost int    `env:"BCRYPT_COST" envDefault:"10"`
+	// Argon2Memory is in KiB
+	Argon2Memory      int `env:"ARGON2_MEMORY" envDefault:"65536"`
+	Argon2Iterations  int `env:"ARGON2_ITERATIONS" envDefault:"3"`
+	Argon2Parallelism int `env:"ARGON2_PARALLELISM" envDefault:"2"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Algorithm:         AlgBcrypt,
+		BcryptCost:        bcrypt.DefaultCost,
+		Argon2Memory:      64 * 1024,
+		Argon2Iterations:  3,
+		Argon2Parallelism: 2,
+	}
+}
+
+// argon2Params are the parameters encoded in an argon2id hash
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+var _ Hasher = (*DefaultHasher)(nil)
+
+type DefaultHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+}
+
+func NewHasher(config Config) (*DefaultHasher, error) {
+	switch config.Algorithm {
+	case AlgBcrypt:
+		if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgArgon2id:
+		if config.Argon2Memory < 8*config.Argon2Parallelism || config.Argon2Iterations < 1 ||
+			config.Argon2Parallelism < 1 || config.Argon2Parallelism > 255 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", config.Algorithm)
+	}
+	return newHasher(config), nil
+}
+
+// NewDefaultHasher is the hasher of the default config
+func NewDefaultHasher() *DefaultHasher {
+	return newHasher(DefaultConfig())
+}
+
+func newHasher(config Config) *DefaultHasher {
+	return &DefaultHasher{
+		algorithm:  config.Algorithm,
+		bcryptCost: config.BcryptCost,
+		argon2: argon2Params{
+			memory:      uint32(config.Argon2Memory),
+			iterations:  uint32(config.Argon2Iterations),
+			parallelism: uint8(config.Argon2Parallelism),
+		},
+	}
+}
+
+func (h *DefaultHasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgArgon2id {
+		return hashArgon2id([]byte(password), h.argon2)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *DefaultHasher) Verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, ErrMismatch
+		}
+		return h.algorithm != AlgArgon2id || params != h.argon2, nil
+	case strings.HasPrefix(hash, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if err == bcrypt.ErrMismatchedHashAndPassword {
+				return false, ErrMismatch
+			}
+			return false, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+		}
+		return h.algorithm != AlgBcrypt || cost != h.bcryptCost, nil
+	}
+	return false, ErrUnknownFormat
+}
+
+// hashArgon2id encodes the hash in the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func hashArgon2id(password []byte, params argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(password, salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: unsupported argon2 version", ErrUnknownFormat)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil ||
+		params.iterations < 1 || params.parallelism < 1 {
+		return params, nil, nil, fmt.Errorf("%w: invalid argon2 parameters", ErrUnknownFormat)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: invalid argon2 salt", ErrUnknownFormat)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("%w: invalid argon2 key", ErrUnknownFormat)
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,136 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testArgon2Config() Config {
+	config := DefaultConfig()
+	config.Algorithm = AlgArgon2id
+	config.Argon2Memory = 1024
+	config.Argon2Iterations = 1
+	config.Argon2Parallelism = 1
+	return config
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(c *Config)
+		wantErr bool
+	}{
+		{name: "default", config: func(c *Config) {}},
+		{name: "argon2id", config: func(c *Config) { *c = testArgon2Config() }},
+		{name: "unknown algorithm", config: func(c *Config) { c.Algorithm = "md5" }, wantErr: true},
+		{name: "bcrypt cost", config: func(c *Config) { c.BcryptCost = 40 }, wantErr: true},
+		{name: "argon2 parallelism", config: func(c *Config) { *c = testArgon2Config(); c.Argon2Parallelism = 0 }, wantErr: true},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.config(&config)
+			_, err := NewHasher(config)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestDefaultHasher_Verify(t *testing.T) {
+	bcryptConfig := DefaultConfig()
+	bcryptConfig.BcryptCost = bcrypt.MinCost
+	bcryptHasher, err := NewHasher(bcryptConfig)
+	assert.Nil(t, err)
+	argonHasher, err := NewHasher(testArgon2Config())
+	assert.Nil(t, err)
+
+	bcryptHash, err := bcryptHasher.Hash("correct horse")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(bcryptHash, "$2a$04$"))
+	argonHash, err := argonHasher.Hash("correct horse")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	other, err := argonHasher.Hash("correct horse")
+	assert.Nil(t, err)
+	assert.NotEqual(t, argonHash, other)
+
+	stronger := testArgon2Config()
+	stronger.Argon2Iterations = 2
+	strongerHasher, err := NewHasher(stronger)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name       string
+		hasher     Hasher
+		hash       string
+		password   string
+		wantRehash bool
+		wantErr    error
+	}{
+		{name: "bcrypt", hasher: bcryptHasher, hash: bcryptHash, password: "correct horse"},
+		{name: "bcrypt mismatch", hasher: bcryptHasher, hash: bcryptHash, password: "wrong horse", wantErr: ErrMismatch},
+		{name: "bcrypt cost changed", hasher: NewDefaultHasher(), hash: bcryptHash, password: "correct horse", wantRehash: true},
+		{name: "bcrypt to argon2id", hasher: argonHasher, hash: bcryptHash, password: "correct horse", wantRehash: true},
+		{name: "argon2id", hasher: argonHasher, hash: argonHash, password: "correct horse"},
+		{name: "argon2id mismatch", hasher: argonHasher, hash: argonHash, password: "wrong horse", wantErr: ErrMismatch},
+		{name: "argon2id parameters changed", hasher: strongerHasher, hash: argonHash, password: "correct horse", wantRehash: true},
+		{name: "argon2id to bcrypt", hasher: bcryptHasher, hash: argonHash, password: "correct horse", wantRehash: true},
+		{name: "plain text", hasher: bcryptHasher, hash: "correct horse", password: "correct horse", wantErr: ErrUnknownFormat},
+		{name: "corrupted argon2id", hasher: argonHasher, hash: "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5", password: "correct horse", wantErr: ErrUnknownFormat},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			rehash, err := tt.hasher.Verify(tt.hash, tt.password)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRehash, rehash)
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := NewPolicy(DefaultPolicyConfig())
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "valid", password: "walking in the rain"},
+		{name: "too short", password: "abc", want: []string{CodeTooShort}},
+		{name: "too long", password: strings.Repeat("a", 129), want: []string{CodeTooLong}},
+		{name: "common", password: "Password123", want: []string{CodeCommon}},
+		{name: "short and common", password: "dragon", want: []string{CodeTooShort, CodeCommon}},
+		{name: "email", password: "Walker.Sky@Example.com", want: []string{CodeMatchesEmail}},
+		{name: "email local part", password: "walker.sky", want: []string{CodeMatchesEmail}},
+		{name: "name", password: "Night Walker", want: []string{CodeMatchesName}},
+		{name: "name without spaces", password: "nightwalker", want: []string{CodeMatchesName}},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "walker.sky@example.com", "Night Walker")
+			if len(tt.want) == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrWeakPassword))
+			var weak *ValidationError
+			assert.True(t, errors.As(err, &weak))
+			var codes []string
+			for _, v := range weak.Violations {
+				assert.Equal(t, "password", v.Field)
+				codes = append(codes, v.Code)
+			}
+			assert.Equal(t, tt.want, codes)
+		})
+	}
+}
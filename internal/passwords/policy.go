@@ -0,0 +1,106 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword matches every ValidationError with errors.Is
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+const (
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeCommon       = "common"
+	CodeMatchesEmail = "matches_email"
+	CodeMatchesName  = "matches_name"
+)
+
+// Violation is a rule of the policy the password breaks
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every violation so the player can fix them at once
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return ErrWeakPassword.Error() + ": " + strings.Join(messages, ", ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+type PolicyConfig struct {
+	MinLength int `env:"PASSWORD_MIN_LENGTH" envDefault:"10"`
+	// MaxLength bounds the hashing work of a single request
+	MaxLength int `env:"PASSWORD_MAX_LENGTH" envDefault:"128"`
+}
+
+func DefaultPolicyConfig() PolicyConfig {
+	return PolicyConfig{
+		MinLength: 10,
+		MaxLength: 128,
+	}
+}
+
+// Policy checks the new passwords of register, reset and change-password
+type Policy struct {
+	config PolicyConfig
+}
+
+func NewPolicy(config PolicyConfig) *Policy {
+	return &Policy{config: config}
+}
+
+// MinLength is the configured minimum number of characters
+func (p *Policy) MinLength() int {
+	return p.config.MinLength
+}
+
+// Validate returns a ValidationError when the password is too short, too long, common
+// or the same as the email or the name of the account, the name may be empty
+func (p *Policy) Validate(password, email, name string) error {
+	var violations []Violation
+	length := utf8.RuneCountInString(password)
+	if length < p.config.MinLength {
+		violations = append(violations, Violation{Field: "password", Code: CodeTooShort,
+			Message: fmt.Sprintf("password must have at least %d characters", p.config.MinLength)})
+	}
+	if p.config.MaxLength > 0 && length > p.config.MaxLength {
+		violations = append(violations, Violation{Field: "password", Code: CodeTooLong,
+			Message: fmt.Sprintf("password must have at most %d characters", p.config.MaxLength)})
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(password))
+	if _, ok := commonPasswords[normalized]; ok {
+		violations = append(violations, Violation{Field: "password", Code: CodeCommon,
+			Message: "password is too common"})
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email != "" && (normalized == email || normalized == strings.SplitN(email, "@", 2)[0]) {
+		violations = append(violations, Violation{Field: "password", Code: CodeMatchesEmail,
+			Message: "password must not be the email address"})
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name != "" && (normalized == name || normalized == strings.Join(strings.Fields(name), "")) {
+		violations = append(violations, Violation{Field: "password", Code: CodeMatchesName,
+			Message: "password must not be the name"})
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package players
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"geogame/internal/lockout"
+	"geogame/internal/mailer"
+	"geogame/pkg"
+)
+
+var (
+	// ErrWrongPassword is returned when the current password of a credential change does not match
+	ErrWrongPassword = errors.New("current password is wrong")
+	ErrInvalidEmail  = errors.New("invalid email address")
+)
+
+// ChangePassword replaces the password and signs out every other session of the player
+func (d *DefaultService) ChangePassword(ctx context.Context, payload ChangePasswordPayload, clientID, sessionID string) ([]string, error) {
+	client, err := d.currentClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.Status == StatusGuest {
+		return nil, ErrGuestAccount
+	}
+	if err := d.checkCurrentPassword(ctx, client, payload.CurrentPassword); err != nil {
+		return nil, err
+	}
+	if err := d.policy.Validate(payload.NewPassword, client.Email, client.Name); err != nil {
+		return nil, err
+	}
+	hash, err := d.hasher.Hash(payload.NewPassword)
+	if err != nil {
+		d.logger.Error("ChangePassword: failed to generate hash from password", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to change password:" + err.Error())
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdatePassword(dbCtx, clientID, hash); err != nil {
+		d.logger.Error("ChangePassword: failed to update password", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to change password:" + err.Error())
+	}
+	// the refresh tokens of the current login stay valid, an unknown session keeps none
+	keep := uuid.Nil.String()
+	if session, err := d.store.GetSession(dbCtx, sessionID); err == nil && session.ClientID == client.ID {
+		keep = session.FamilyID.String()
+	}
+	revoked, err := d.store.RevokeClientSessions(dbCtx, clientID, keep)
+	if err != nil {
+		d.logger.Error("ChangePassword: failed to revoke the other sessions", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to revoke sessions:" + err.Error())
+	}
+	d.logger.Info("ChangePassword: password changed", zap.String("clientID", clientID), zap.Int("revokedSessions", len(revoked)))
+	return revoked, nil
+}
+
+// ChangeEmail sends a verification link to the new address, the email is only replaced once it is opened
+func (d *DefaultService) ChangeEmail(ctx context.Context, payload ChangeEmailPayload, clientID string) error {
+	email := strings.TrimSpace(payload.Email)
+	if !strings.Contains(email, "@") {
+		return ErrInvalidEmail
+	}
+	client, err := d.currentClient(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if client.Status == StatusGuest {
+		return ErrGuestAccount
+	}
+	if err := d.checkCurrentPassword(ctx, client, payload.Password); err != nil {
+		return err
+	}
+	if email == client.Email {
+		return ErrInvalidEmail
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	// checked early for a clear error, UpdateEmail enforces it when the change is confirmed
+	if _, err := d.store.GetClientByEmail(dbCtx, email); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, ErrNotFound) {
+		d.logger.Error("ChangeEmail: failed to get client by email", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to change email:" + err.Error())
+	}
+	return d.requestEmailChange(ctx, client, email)
+}
+
+// requestEmailChange stores the pending email and sends the verification link to it
+func (d *DefaultService) requestEmailChange(ctx context.Context, client *ClientStoreModel, email string) error {
+	clientID := client.ID.String()
+	token, err := pkg.RandomToken(32)
+	if err != nil {
+		return errors.New("failed to change email:" + err.Error())
+	}
+	now := time.Now().UTC()
+	change := EmailChangeStoreModel{
+		ClientID:  client.ID,
+		NewEmail:  email,
+		TokenHash: pkg.HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(d.verification.TokenTTL),
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.SaveEmailChange(dbCtx, &change); err != nil {
+		d.logger.Error("requestEmailChange: failed to save email change", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to change email:" + err.Error())
+	}
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Confirm your new geogame email address",
+		Body:    "Hi " + client.Name + ",\n\nplease confirm your new email address by opening " + d.verification.EmailChangeURL + "?token=" + token,
+	}
+	if err := d.mailer.Send(ctx, msg); err != nil {
+		d.logger.Error("requestEmailChange: failed to send verification", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to send verification:" + err.Error())
+	}
+	return nil
+}
+
+// ConfirmEmailChange replaces the email with the verified new one and notifies the old address,
+// a guest has none and becomes a verified player
+func (d *DefaultService) ConfirmEmailChange(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidVerificationToken
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	change, err := d.store.GetEmailChangeByTokenHash(dbCtx, pkg.HashToken(token))
+	if err != nil {
+		if err == ErrNotFound {
+			return ErrInvalidVerificationToken
+		}
+		d.logger.Error("ConfirmEmailChange: failed to get email change from db", zap.Error(err))
+		return errors.New("failed to change email:" + err.Error())
+	}
+	if time.Now().After(change.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+	clientID := change.ClientID.String()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("ConfirmEmailChange: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return ErrInvalidVerificationToken
+	}
+	oldEmail := client.Email
+	if err := d.store.UpdateEmail(dbCtx, clientID, change.NewEmail); err != nil {
+		if err == ErrEmailTaken {
+			return err
+		}
+		d.logger.Error("ConfirmEmailChange: failed to update email", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to change email:" + err.Error())
+	}
+	if err := d.store.DeleteEmailChange(dbCtx, clientID); err != nil {
+		d.logger.Error("ConfirmEmailChange: failed to delete email change", zap.String("clientID", clientID), zap.Error(err))
+	}
+	// opening the link proves the new address, a pending registration is verified with it
+	switch client.Status {
+	case StatusUnverified:
+		if err := d.store.UpdateStatus(dbCtx, clientID, StatusVerified); err != nil {
+			d.logger.Error("ConfirmEmailChange: failed to update status", zap.String("clientID", clientID), zap.Error(err))
+		}
+		if err := d.store.DeleteVerification(dbCtx, clientID); err != nil {
+			d.logger.Error("ConfirmEmailChange: failed to delete verification", zap.String("clientID", clientID), zap.Error(err))
+		}
+	case StatusGuest:
+		if err := d.store.UpdateStatus(dbCtx, clientID, StatusVerified); err != nil {
+			d.logger.Error("ConfirmEmailChange: failed to upgrade guest", zap.String("clientID", clientID), zap.Error(err))
+			return errors.New("failed to upgrade:" + err.Error())
+		}
+		if err := d.finishUpgrade(dbCtx, clientID); err != nil {
+			return err
+		}
+		d.logger.Info("ConfirmEmailChange: guest upgraded", zap.String("clientID", clientID))
+		return nil
+	}
+
+	msg := mailer.Message{
+		To:      oldEmail,
+		Subject: "Your geogame email address was changed",
+		Body: "Hi " + client.Name + ",\n\nthe email address of your account was changed to " + change.NewEmail +
+			". If you did not request this change, please contact the support.",
+	}
+	// the change is done, a failed notification must not report it as failed
+	if err := d.mailer.Send(ctx, msg); err != nil {
+		d.logger.Error("ConfirmEmailChange: failed to notify the old address", zap.String("clientID", clientID), zap.Error(err))
+	}
+	d.logger.Info("ConfirmEmailChange: email changed", zap.String("clientID", clientID))
+	return nil
+}
+
+func (d *DefaultService) currentClient(ctx context.Context, clientID string) (*ClientStoreModel, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("currentClient: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to get client:" + err.Error())
+	}
+	return client, nil
+}
+
+// checkCurrentPassword counts the wrong passwords as failed logins of the email so a stolen token
+// can't be used to guess the password
+func (d *DefaultService) checkCurrentPassword(ctx context.Context, client *ClientStoreModel, password string) error {
+	key := lockout.EmailKey(client.Email)
+	if err := d.lockout.Check(ctx, key); err != nil {
+		return err
+	}
+	if err := d.checkPassword(ctx, client, password); err != nil {
+		if err := d.lockout.Fail(ctx, key); err != nil {
+			d.logger.Error("checkCurrentPassword: failed to record failed attempt", zap.String("clientID", client.ID.String()), zap.Error(err))
+		}
+		return ErrWrongPassword
+	}
+	if err := d.lockout.Reset(ctx, key); err != nil {
+		d.logger.Error("checkCurrentPassword: failed to reset failed attempts", zap.String("clientID", client.ID.String()), zap.Error(err))
+	}
+	return nil
+}
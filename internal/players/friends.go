@@ -0,0 +1,281 @@
+package players
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidFriend          = errors.New("invalid friend id")
+	ErrFriendNotFound         = errors.New("player not found")
+	ErrAlreadyFriends         = errors.New("friend request already exists")
+	ErrFriendRequestNotFound  = errors.New("friend request not found")
+	ErrPlayerBlocked          = errors.New("player is blocked, unblock the player first")
+	ErrInvalidLocationSharing = errors.New("invalid location sharing, expected friends or nobody")
+)
+
+// RequestFriend sends a friend request, a pending request of the other player is accepted instead.
+// Requests to a player who blocked the caller are dropped silently so the block is not revealed.
+func (d *DefaultService) RequestFriend(ctx context.Context, payload FriendPayload, clientID string) error {
+	if payload.FriendID == "" && payload.Handle != "" {
+		dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+		friend, err := d.store.GetClientByHandle(dbCtx, payload.Handle)
+		cancel()
+		if err != nil {
+			return ErrFriendNotFound
+		}
+		payload.FriendID = friend.ID.String()
+	}
+	friendID, err := d.parseFriendID(payload.FriendID, clientID)
+	if err != nil {
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if _, err := d.store.GetClientByID(dbCtx, friendID); err != nil {
+		return ErrFriendNotFound
+	}
+	blocked, err := d.store.IsBlocked(dbCtx, clientID, friendID)
+	if err != nil {
+		d.logger.Error("RequestFriend: failed to check block", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to request friend:" + err.Error())
+	}
+	if blocked {
+		return ErrPlayerBlocked
+	}
+	blockedBy, err := d.store.IsBlocked(dbCtx, friendID, clientID)
+	if err != nil {
+		d.logger.Error("RequestFriend: failed to check block", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to request friend:" + err.Error())
+	}
+	if blockedBy {
+		return nil
+	}
+
+	existing, err := d.store.GetFriendship(dbCtx, clientID, friendID)
+	switch {
+	case err == ErrNotFound:
+	case err != nil:
+		d.logger.Error("RequestFriend: failed to get friendship", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to request friend:" + err.Error())
+	case existing.Status == FriendshipPending && existing.RequesterID.String() == friendID:
+		return d.AcceptFriend(ctx, friendID, clientID)
+	default:
+		return ErrAlreadyFriends
+	}
+
+	now := time.Now().UTC()
+	friendship := FriendshipStoreModel{
+		RequesterID: uuid.MustParse(clientID),
+		AddresseeID: uuid.MustParse(friendID),
+		Status:      FriendshipPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := d.store.CreateFriendship(dbCtx, &friendship); err != nil {
+		if err == ErrFriendshipExists {
+			return ErrAlreadyFriends
+		}
+		d.logger.Error("RequestFriend: failed to create friendship", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to request friend:" + err.Error())
+	}
+	return nil
+}
+
+// AcceptFriend accepts the pending request the friend sent to the caller
+func (d *DefaultService) AcceptFriend(ctx context.Context, friendID, clientID string) error {
+	if _, err := d.parseFriendID(friendID, clientID); err != nil {
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.AcceptFriendship(dbCtx, friendID, clientID); err != nil {
+		if err == ErrNotFound {
+			return ErrFriendRequestNotFound
+		}
+		d.logger.Error("AcceptFriend: failed to accept friendship", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to accept friend:" + err.Error())
+	}
+	return nil
+}
+
+// DeclineFriend removes the pending request the friend sent to the caller
+func (d *DefaultService) DeclineFriend(ctx context.Context, friendID, clientID string) error {
+	if _, err := d.parseFriendID(friendID, clientID); err != nil {
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	existing, err := d.store.GetFriendship(dbCtx, clientID, friendID)
+	if err != nil {
+		if err == ErrNotFound {
+			return ErrFriendRequestNotFound
+		}
+		d.logger.Error("DeclineFriend: failed to get friendship", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to decline friend:" + err.Error())
+	}
+	if existing.Status != FriendshipPending || existing.AddresseeID.String() != clientID {
+		return ErrFriendRequestNotFound
+	}
+	if err := d.store.DeleteFriendship(dbCtx, clientID, friendID); err != nil {
+		d.logger.Error("DeclineFriend: failed to delete friendship", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to decline friend:" + err.Error())
+	}
+	return nil
+}
+
+// RemoveFriend ends a friendship or cancels an own pending request
+func (d *DefaultService) RemoveFriend(ctx context.Context, friendID, clientID string) error {
+	if _, err := d.parseFriendID(friendID, clientID); err != nil {
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.DeleteFriendship(dbCtx, clientID, friendID); err != nil {
+		d.logger.Error("RemoveFriend: failed to delete friendship", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to remove friend:" + err.Error())
+	}
+	return nil
+}
+
+// BlockPlayer removes any friendship with the player and rejects the future requests of the player
+func (d *DefaultService) BlockPlayer(ctx context.Context, friendID, clientID string) error {
+	if _, err := d.parseFriendID(friendID, clientID); err != nil {
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if _, err := d.store.GetClientByID(dbCtx, friendID); err != nil {
+		return ErrFriendNotFound
+	}
+	if err := d.store.DeleteFriendship(dbCtx, clientID, friendID); err != nil {
+		d.logger.Error("BlockPlayer: failed to delete friendship", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to block player:" + err.Error())
+	}
+	block := BlockStoreModel{
+		BlockerID: uuid.MustParse(clientID),
+		BlockedID: uuid.MustParse(friendID),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.CreateBlock(dbCtx, &block); err != nil {
+		d.logger.Error("BlockPlayer: failed to create block", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to block player:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) UnblockPlayer(ctx context.Context, friendID, clientID string) error {
+	if _, err := d.parseFriendID(friendID, clientID); err != nil {
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.DeleteBlock(dbCtx, clientID, friendID); err != nil {
+		d.logger.Error("UnblockPlayer: failed to delete block", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to unblock player:" + err.Error())
+	}
+	return nil
+}
+
+// ListFriends returns the friends and the pending requests in both directions
+func (d *DefaultService) ListFriends(ctx context.Context, clientID string) ([]Friend, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		d.logger.Error("ListFriends: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	friendships, err := d.store.ListFriendships(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("ListFriends: failed to list friendships", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list friends:" + err.Error())
+	}
+	friends := make([]Friend, 0, len(friendships))
+	for i := range friendships {
+		f := &friendships[i]
+		other, err := d.store.GetClientByID(dbCtx, f.Other(id).String())
+		if err != nil {
+			continue
+		}
+		state := FriendStateAccepted
+		if f.Status == FriendshipPending {
+			state = FriendStateOutgoing
+			if f.AddresseeID == id {
+				state = FriendStateIncoming
+			}
+		}
+		friends = append(friends, Friend{
+			ID:     other.ID.String(),
+			Handle: other.Handle,
+			State:  state,
+			Since:  f.UpdatedAt,
+		})
+	}
+	return friends, nil
+}
+
+// FriendLocations returns the last known position of the friends sharing their location
+func (d *DefaultService) FriendLocations(ctx context.Context, clientID string) ([]FriendLocation, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("FriendLocations: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	clients, err := d.store.ListFriendLocations(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("FriendLocations: failed to list friend locations", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to get friend locations:" + err.Error())
+	}
+	now := time.Now()
+	res := make([]FriendLocation, 0, len(clients))
+	for i := range clients {
+		c := &clients[i]
+		loc, ok := exposeLocation(c, now)
+		if !ok {
+			continue
+		}
+		res = append(res, FriendLocation{
+			ID:        c.ID.String(),
+			Handle:    c.Handle,
+			Location:  *loc,
+			UpdatedAt: c.LocationUpdatedAt.Time,
+		})
+	}
+	return res, nil
+}
+
+func (d *DefaultService) UpdateLocationSharing(ctx context.Context, payload SharingPayload, clientID string) error {
+	if !payload.Sharing.Valid() {
+		return ErrInvalidLocationSharing
+	}
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("UpdateLocationSharing: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdateLocationSharing(dbCtx, clientID, payload.Sharing); err != nil {
+		d.logger.Error("UpdateLocationSharing: failed to update location sharing", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to update location sharing:" + err.Error())
+	}
+	return nil
+}
+
+// parseFriendID validates both ids, a player can not befriend or block themselves
+func (d *DefaultService) parseFriendID(friendID, clientID string) (string, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("parseFriendID: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return "", err
+	}
+	id, err := uuid.Parse(friendID)
+	if err != nil || id.String() == clientID {
+		return "", ErrInvalidFriend
+	}
+	return id.String(), nil
+}
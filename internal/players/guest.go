@@ -0,0 +1,271 @@
+package players
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"geogame/pkg"
+)
+
+const maxDeviceIDLength = 200
+
+var (
+	ErrInvalidDevice = errors.New("invalid device id")
+	// ErrInvalidGuest is returned for unknown guests, upgraded guests and wrong device secrets alike
+	ErrInvalidGuest = errors.New("invalid guest credentials")
+	ErrNotGuest     = errors.New("account is not a guest account")
+	// ErrGuestAccount is returned for the credential changes of a guest, it has to upgrade first
+	ErrGuestAccount = errors.New("guest accounts have to be upgraded first")
+)
+
+// CreateGuest creates an anonymous player bound to the device, the secret is only returned here
+func (d *DefaultService) CreateGuest(ctx context.Context, payload GuestPayload) (*GuestResponse, error) {
+	deviceID := strings.TrimSpace(payload.DeviceID)
+	if deviceID == "" || len(deviceID) > maxDeviceIDLength {
+		return nil, ErrInvalidDevice
+	}
+	secret, err := pkg.RandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to create guest:" + err.Error())
+	}
+	password, err := d.unusablePassword()
+	if err != nil {
+		return nil, errors.New("failed to create guest:" + err.Error())
+	}
+
+	now := time.Now().UTC()
+	clientID := uuid.New()
+	client := ClientStoreModel{
+		ID:              clientID,
+		Name:            "Guest-" + clientID.String()[:8],
+		Handle:          generatedHandle(guestHandlePrefix, clientID),
+		Password:        password,
+		LocationID:      toNullString(""),
+		LocationName:    toNullString(""),
+		LocationType:    toNullString(""),
+		Status:          StatusGuest,
+		CreatedAt:       now,
+		LocationSharing: SharingFriends,
+	}
+	guest := GuestStoreModel{
+		ClientID:   clientID,
+		DeviceID:   deviceID,
+		SecretHash: pkg.HashToken(secret),
+		CreatedAt:  now,
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateGuest(dbCtx, &client, &guest); err != nil {
+		d.logger.Error("CreateGuest: failed to create guest", zap.Error(err))
+		return nil, errors.New("failed to create guest:" + err.Error())
+	}
+
+	res, err := d.startSession(ctx, &client)
+	if err != nil {
+		d.logger.Error("CreateGuest: failed to start session", zap.String("clientID", clientID.String()), zap.Error(err))
+		return nil, err
+	}
+	d.logger.Info("CreateGuest: guest created", zap.String("clientID", clientID.String()))
+	return &GuestResponse{
+		GuestID:      clientID.String(),
+		Secret:       secret,
+		Token:        res.Token,
+		RefreshToken: res.RefreshToken,
+	}, nil
+}
+
+// GuestLogin starts a new session of a guest with the secret of its device
+func (d *DefaultService) GuestLogin(ctx context.Context, payload GuestLoginPayload) (*APIResponse, error) {
+	if _, err := uuid.Parse(payload.GuestID); err != nil || payload.Secret == "" {
+		return nil, ErrInvalidGuest
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	guest, err := d.store.GetGuest(dbCtx, payload.GuestID)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrInvalidGuest
+		}
+		d.logger.Error("GuestLogin: failed to get guest from db", zap.String("clientID", payload.GuestID), zap.Error(err))
+		return nil, errors.New("failed to login:" + err.Error())
+	}
+	if subtle.ConstantTimeCompare([]byte(guest.SecretHash), []byte(pkg.HashToken(payload.Secret))) != 1 ||
+		guest.DeviceID != strings.TrimSpace(payload.DeviceID) {
+		d.logger.Warn("GuestLogin: wrong device or secret", zap.String("clientID", payload.GuestID))
+		return nil, ErrInvalidGuest
+	}
+	client, err := d.store.GetClientByID(dbCtx, payload.GuestID)
+	if err != nil {
+		d.logger.Error("GuestLogin: failed to get client from db", zap.String("clientID", payload.GuestID), zap.Error(err))
+		return nil, ErrInvalidGuest
+	}
+	if client.Status != StatusGuest {
+		return nil, ErrInvalidGuest
+	}
+	res, err := d.startSession(ctx, client)
+	if err != nil {
+		d.logger.Error("GuestLogin: failed to start session", zap.String("clientID", payload.GuestID), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// Upgrade sets the password and the name of a guest and sends the verification of the email, the guest
+// becomes a verified player with all its progress once the link is opened
+func (d *DefaultService) Upgrade(ctx context.Context, payload UpgradePayload, clientID string) error {
+	email := strings.TrimSpace(payload.Email)
+	if !strings.Contains(email, "@") {
+		return ErrInvalidEmail
+	}
+	client, err := d.currentClient(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if client.Status != StatusGuest {
+		return ErrNotGuest
+	}
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		name = client.Name
+	}
+	if err := d.policy.Validate(payload.Password, email, name); err != nil {
+		return err
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if _, err := d.store.GetClientByEmail(dbCtx, email); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, ErrNotFound) {
+		d.logger.Error("Upgrade: failed to get client by email", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to upgrade:" + err.Error())
+	}
+	hash, err := d.hasher.Hash(payload.Password)
+	if err != nil {
+		d.logger.Error("Upgrade: failed to generate hash from password", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to upgrade:" + err.Error())
+	}
+	if err := d.store.UpdatePassword(dbCtx, clientID, hash); err != nil {
+		d.logger.Error("Upgrade: failed to update password", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to upgrade:" + err.Error())
+	}
+	if name != client.Name {
+		if err := d.store.UpdateName(dbCtx, clientID, name); err != nil {
+			d.logger.Error("Upgrade: failed to update name", zap.String("clientID", clientID), zap.Error(err))
+			return errors.New("failed to upgrade:" + err.Error())
+		}
+		client.Name = name
+	}
+	return d.requestEmailChange(ctx, client, email)
+}
+
+// UpgradeWithIdentity links the identity to a guest and makes it a verified player,
+// the provider has to verify the email because no link is sent
+func (d *DefaultService) UpgradeWithIdentity(ctx context.Context, clientID string, identity ExternalIdentity) (*APIResponse, error) {
+	if identity.Provider == "" || identity.Subject == "" {
+		return nil, errors.New("failed to upgrade: identity without provider or subject")
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		d.logger.Warn("UpgradeWithIdentity: email of the identity is not verified", zap.String("provider", identity.Provider), zap.String("clientID", clientID))
+		return nil, ErrEmailNotVerified
+	}
+	client, err := d.currentClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.Status != StatusGuest {
+		return nil, ErrNotGuest
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if _, err := d.store.GetIdentity(dbCtx, identity.Provider, identity.Subject); err == nil {
+		return nil, ErrIdentityLinked
+	} else if err != ErrNotFound {
+		d.logger.Error("UpgradeWithIdentity: failed to get identity from db", zap.String("provider", identity.Provider), zap.Error(err))
+		return nil, errors.New("failed to upgrade:" + err.Error())
+	}
+	if err := d.store.UpdateEmail(dbCtx, clientID, identity.Email); err != nil {
+		if err == ErrEmailTaken {
+			return nil, err
+		}
+		d.logger.Error("UpgradeWithIdentity: failed to update email", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to upgrade:" + err.Error())
+	}
+	model := IdentityStoreModel{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		ClientID:  client.ID,
+		Email:     identity.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.CreateIdentity(dbCtx, &model); err != nil {
+		d.logger.Error("UpgradeWithIdentity: failed to link identity", zap.String("provider", identity.Provider), zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to link identity:" + err.Error())
+	}
+	if err := d.store.UpdateStatus(dbCtx, clientID, StatusVerified); err != nil {
+		d.logger.Error("UpgradeWithIdentity: failed to update status", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to upgrade:" + err.Error())
+	}
+	client.Email = identity.Email
+	client.Status = StatusVerified
+	if err := d.finishUpgrade(dbCtx, clientID); err != nil {
+		return nil, err
+	}
+
+	res, err := d.startSession(ctx, client)
+	if err != nil {
+		d.logger.Error("UpgradeWithIdentity: failed to start session", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	d.logger.Info("UpgradeWithIdentity: guest upgraded", zap.String("provider", identity.Provider), zap.String("clientID", clientID))
+	return res, nil
+}
+
+// CleanupGuests removes the guests without login, refresh or position for the configured time
+func (d *DefaultService) CleanupGuests(ctx context.Context) (int64, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	deleted, err := d.store.DeleteInactiveGuests(dbCtx, time.Now().Add(-d.guests.MaxInactiveAge))
+	if err != nil {
+		d.logger.Error("CleanupGuests: failed to delete inactive guests", zap.Error(err))
+		return 0, err
+	}
+	if deleted > 0 {
+		d.logger.Info("CleanupGuests: deleted inactive guests", zap.Int64("count", deleted))
+	}
+	return deleted, nil
+}
+
+// finishUpgrade signs out every guest session of a former guest, so no refresh token issued to the
+// guest gets the scopes of the full account, then removes the device binding and a pending email upgrade
+func (d *DefaultService) finishUpgrade(ctx context.Context, clientID string) error {
+	revoked, err := d.store.RevokeClientSessions(ctx, clientID, uuid.Nil.String())
+	if err != nil {
+		d.logger.Error("finishUpgrade: failed to revoke the guest sessions", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to revoke sessions:" + err.Error())
+	}
+	d.logger.Info("finishUpgrade: guest sessions revoked", zap.String("clientID", clientID), zap.Int("revokedSessions", len(revoked)))
+	if err := d.store.DeleteGuest(ctx, clientID); err != nil {
+		d.logger.Error("finishUpgrade: failed to delete guest", zap.String("clientID", clientID), zap.Error(err))
+	}
+	if err := d.store.DeleteEmailChange(ctx, clientID); err != nil {
+		d.logger.Error("finishUpgrade: failed to delete email change", zap.String("clientID", clientID), zap.Error(err))
+	}
+	return nil
+}
+
+// unusablePassword is the hash of a random password nobody knows, for the accounts without password login
+func (d *DefaultService) unusablePassword() (string, error) {
+	password, err := pkg.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return d.hasher.Hash(password)
+}
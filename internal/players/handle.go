@@ -0,0 +1,202 @@
+package players
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	minHandleLength = 3
+	maxHandleLength = 20
+
+	// the generated handles use prefixes the players can't choose
+	playerHandlePrefix = "player_"
+	guestHandlePrefix  = "guest_"
+)
+
+var (
+	ErrInvalidHandle  = errors.New("invalid handle")
+	ErrHandleReserved = errors.New("handle is reserved")
+	ErrHandleCooldown = errors.New("handle was changed recently")
+	ErrPlayerNotFound = errors.New("player not found")
+)
+
+// handlePattern allows ASCII letters, digits and underscores so look-alike characters can't impersonate a player
+var handlePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// reservedHandles can't be chosen in any case
+var reservedHandles = map[string]struct{}{
+	"admin": {}, "administrator": {}, "anonymous": {}, "api": {}, "everyone": {}, "guest": {}, "help": {},
+	"me": {}, "mod": {}, "moderator": {}, "null": {}, "player": {}, "players": {}, "root": {}, "security": {},
+	"staff": {}, "support": {}, "system": {}, "undefined": {},
+}
+
+// reservedHandleParts can't appear anywhere in a handle
+var reservedHandleParts = []string{"admin", "geogame", "moderator", "official"}
+
+// HandleConfig controls how often the players may change their handle
+type HandleConfig struct {
+	// ChangeCooldown is the minimum time between two chosen handles, the first change is free
+	ChangeCooldown time.Duration `env:"HANDLE_CHANGE_COOLDOWN" envDefault:"720h"`
+	// HoldPeriod is how long a released handle can only be taken back by its former owner
+	HoldPeriod time.Duration `env:"HANDLE_HOLD_PERIOD" envDefault:"2160h"`
+}
+
+// GetHandle returns the handle of the player and when it may change again
+func (d *DefaultService) GetHandle(ctx context.Context, clientID string) (*HandleResponse, error) {
+	client, err := d.currentClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return d.handleResponse(client, time.Now()), nil
+}
+
+// ChangeHandle replaces the handle, the old one stays in the history of the player
+func (d *DefaultService) ChangeHandle(ctx context.Context, payload HandlePayload, clientID string) (*HandleResponse, error) {
+	handle := strings.TrimSpace(payload.Handle)
+	if err := validateHandle(handle); err != nil {
+		return nil, err
+	}
+	client, err := d.currentClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if handle == client.Handle {
+		return d.handleResponse(client, now), nil
+	}
+	if next := d.nextHandleChange(client); now.Before(next) {
+		return nil, fmt.Errorf("%w: the next change is possible after %s", ErrHandleCooldown, next.Format(time.RFC3339))
+	}
+	if err := d.checkHandleFree(ctx, handle, client.ID); err != nil {
+		return nil, err
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdateHandle(dbCtx, clientID, handle, now); err != nil {
+		if err == ErrHandleTaken {
+			return nil, err
+		}
+		d.logger.Error("ChangeHandle: failed to update handle", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to change handle:" + err.Error())
+	}
+	client.Handle = handle
+	client.HandleChangedAt.Time, client.HandleChangedAt.Valid = now, true
+	d.logger.Info("ChangeHandle: handle changed", zap.String("clientID", clientID), zap.String("handle", handle))
+	return d.handleResponse(client, now), nil
+}
+
+// PublicProfile returns the profile of the player with the handle, a player who blocked the viewer is not found
+func (d *DefaultService) PublicProfile(ctx context.Context, handle, viewerID string) (*PublicProfile, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByHandle(dbCtx, strings.TrimSpace(handle))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrPlayerNotFound
+		}
+		d.logger.Error("PublicProfile: failed to get client by handle", zap.String("handle", handle), zap.Error(err))
+		return nil, errors.New("failed to get profile:" + err.Error())
+	}
+	blocked, err := d.store.IsBlocked(dbCtx, client.ID.String(), viewerID)
+	if err != nil {
+		d.logger.Error("PublicProfile: failed to check block", zap.String("clientID", viewerID), zap.Error(err))
+		return nil, errors.New("failed to get profile:" + err.Error())
+	}
+	if blocked {
+		return nil, ErrPlayerNotFound
+	}
+	history, err := d.store.ListHandleHistory(dbCtx, client.ID.String())
+	if err != nil {
+		d.logger.Error("PublicProfile: failed to list handle history", zap.String("handle", handle), zap.Error(err))
+		return nil, errors.New("failed to get profile:" + err.Error())
+	}
+	previous := make([]string, 0, len(history))
+	seen := map[string]bool{strings.ToLower(client.Handle): true}
+	for _, h := range history {
+		if key := strings.ToLower(h.Handle); !seen[key] {
+			seen[key] = true
+			previous = append(previous, h.Handle)
+		}
+	}
+	return &PublicProfile{
+		Handle:          client.Handle,
+		PreviousHandles: previous,
+		Guest:           client.Status == StatusGuest,
+		MemberSince:     client.CreatedAt,
+	}, nil
+}
+
+// checkHandleFree rejects the handles of other players, also the ones they released during the hold period
+func (d *DefaultService) checkHandleFree(ctx context.Context, handle string, clientID uuid.UUID) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	other, err := d.store.GetClientByHandle(dbCtx, handle)
+	switch {
+	case err == nil && other.ID != clientID:
+		return ErrHandleTaken
+	case err != nil && !errors.Is(err, ErrNotFound):
+		d.logger.Error("checkHandleFree: failed to get client by handle", zap.String("handle", handle), zap.Error(err))
+		return errors.New("failed to check handle:" + err.Error())
+	}
+	held, err := d.store.IsHandleHeld(dbCtx, handle, clientID.String(), time.Now().Add(-d.handles.HoldPeriod))
+	if err != nil {
+		d.logger.Error("checkHandleFree: failed to check handle history", zap.String("handle", handle), zap.Error(err))
+		return errors.New("failed to check handle:" + err.Error())
+	}
+	if held {
+		return ErrHandleTaken
+	}
+	return nil
+}
+
+func (d *DefaultService) nextHandleChange(client *ClientStoreModel) time.Time {
+	if !client.HandleChangedAt.Valid {
+		return time.Time{}
+	}
+	return client.HandleChangedAt.Time.Add(d.handles.ChangeCooldown)
+}
+
+func (d *DefaultService) handleResponse(client *ClientStoreModel, now time.Time) *HandleResponse {
+	res := &HandleResponse{Handle: client.Handle}
+	if next := d.nextHandleChange(client); now.Before(next) {
+		res.NextChangeAt = &next
+	}
+	return res
+}
+
+// validateHandle checks the length, the characters and the reserved words, the uniqueness is checked by the store
+func validateHandle(handle string) error {
+	if len(handle) < minHandleLength || len(handle) > maxHandleLength {
+		return fmt.Errorf("%w: a handle has %d to %d characters", ErrInvalidHandle, minHandleLength, maxHandleLength)
+	}
+	if !handlePattern.MatchString(handle) {
+		return fmt.Errorf("%w: a handle starts with a letter and has only letters, digits and underscores", ErrInvalidHandle)
+	}
+	lower := strings.ToLower(handle)
+	if _, ok := reservedHandles[lower]; ok {
+		return ErrHandleReserved
+	}
+	if strings.HasPrefix(lower, playerHandlePrefix) || strings.HasPrefix(lower, guestHandlePrefix) {
+		return ErrHandleReserved
+	}
+	for _, part := range reservedHandleParts {
+		if strings.Contains(lower, part) {
+			return ErrHandleReserved
+		}
+	}
+	return nil
+}
+
+// generatedHandle is unique through the id of the client
+func generatedHandle(prefix string, clientID uuid.UUID) string {
+	return prefix + strings.Replace(clientID.String(), "-", "", -1)[:12]
+}
@@ -0,0 +1,164 @@
+package players
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ExternalIdentity is a player as an external identity provider knows it
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// IdentityStoreModel links the account of an identity provider to a client
+type IdentityStoreModel struct {
+	Provider  string    `db:"provider"`
+	Subject   string    `db:"subject"`
+	ClientID  uuid.UUID `db:"client_id"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// LoginWithIdentity starts a session for the client linked to the identity. An unknown identity is linked
+// to the client with the same email, or to a new client, only when the provider verified the email
+func (d *DefaultService) LoginWithIdentity(ctx context.Context, identity ExternalIdentity) (*APIResponse, error) {
+	if identity.Provider == "" || identity.Subject == "" {
+		return nil, errors.New("failed to login: identity without provider or subject")
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	linked, err := d.store.GetIdentity(dbCtx, identity.Provider, identity.Subject)
+	if err != nil && err != ErrNotFound {
+		d.logger.Error("LoginWithIdentity: failed to get identity from db", zap.String("provider", identity.Provider), zap.Error(err))
+		return nil, errors.New("failed to login:" + err.Error())
+	}
+
+	var client *ClientStoreModel
+	if linked != nil {
+		client, err = d.store.GetClientByID(dbCtx, linked.ClientID.String())
+		if err != nil {
+			d.logger.Error("LoginWithIdentity: failed to get linked client from db", zap.String("clientID", linked.ClientID.String()), zap.Error(err))
+			return nil, errors.New("failed to login:" + err.Error())
+		}
+	} else {
+		client, err = d.linkIdentity(dbCtx, identity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := d.startLogin(ctx, client)
+	if err != nil {
+		d.logger.Error("LoginWithIdentity: failed to start session", zap.String("clientID", client.ID.String()), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (d *DefaultService) linkIdentity(ctx context.Context, identity ExternalIdentity) (*ClientStoreModel, error) {
+	if identity.Email == "" || !identity.EmailVerified {
+		d.logger.Warn("LoginWithIdentity: email of the identity is not verified", zap.String("provider", identity.Provider), zap.String("email", identity.Email))
+		return nil, ErrEmailNotVerified
+	}
+	client, err := d.store.GetClientByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// the provider verified the email so the account is verified as well. Whoever registered
+		// it never proved owning the email, so its password and sessions are dropped first
+		if client.Status == StatusUnverified {
+			if err := d.dropUnverifiedCredentials(ctx, client); err != nil {
+				return nil, err
+			}
+			if err := d.store.UpdateStatus(ctx, client.ID.String(), StatusVerified); err != nil {
+				d.logger.Error("LoginWithIdentity: failed to verify client", zap.String("clientID", client.ID.String()), zap.Error(err))
+				return nil, errors.New("failed to login:" + err.Error())
+			}
+			client.Status = StatusVerified
+			if err := d.store.DeleteVerification(ctx, client.ID.String()); err != nil {
+				d.logger.Error("LoginWithIdentity: failed to delete verification", zap.String("clientID", client.ID.String()), zap.Error(err))
+			}
+		}
+	case errors.Is(err, ErrNotFound):
+		client, err = d.createExternalClient(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		d.logger.Error("LoginWithIdentity: failed to get client from db", zap.String("email", identity.Email), zap.Error(err))
+		return nil, errors.New("failed to login:" + err.Error())
+	}
+
+	model := IdentityStoreModel{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		ClientID:  client.ID,
+		Email:     identity.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.CreateIdentity(ctx, &model); err != nil {
+		d.logger.Error("LoginWithIdentity: failed to link identity", zap.String("provider", identity.Provider), zap.String("clientID", client.ID.String()), zap.Error(err))
+		return nil, errors.New("failed to link identity:" + err.Error())
+	}
+	d.logger.Info("LoginWithIdentity: identity linked", zap.String("provider", identity.Provider), zap.String("clientID", client.ID.String()))
+	return client, nil
+}
+
+// dropUnverifiedCredentials replaces the password of the client with an unusable one and revokes all of its sessions
+func (d *DefaultService) dropUnverifiedCredentials(ctx context.Context, client *ClientStoreModel) error {
+	hash, err := d.unusablePassword()
+	if err != nil {
+		return errors.New("failed to login:" + err.Error())
+	}
+	if err := d.store.UpdatePassword(ctx, client.ID.String(), hash); err != nil {
+		d.logger.Error("LoginWithIdentity: failed to replace the unverified password", zap.String("clientID", client.ID.String()), zap.Error(err))
+		return errors.New("failed to login:" + err.Error())
+	}
+	client.Password = hash
+	revoked, err := d.store.RevokeClientSessions(ctx, client.ID.String(), uuid.Nil.String())
+	if err != nil {
+		d.logger.Error("LoginWithIdentity: failed to revoke the unverified sessions", zap.String("clientID", client.ID.String()), zap.Error(err))
+		return errors.New("failed to revoke sessions:" + err.Error())
+	}
+	d.logger.Info("LoginWithIdentity: unverified credentials dropped", zap.String("clientID", client.ID.String()), zap.Int("revokedSessions", len(revoked)))
+	return nil
+}
+
+// createExternalClient registers a player signing in with a provider, it has no password to login with
+func (d *DefaultService) createExternalClient(ctx context.Context, identity ExternalIdentity) (*ClientStoreModel, error) {
+	hash, err := d.unusablePassword()
+	if err != nil {
+		return nil, errors.New("failed to create client:" + err.Error())
+	}
+	name := identity.Name
+	if name == "" {
+		name = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	clientID := uuid.New()
+	client := ClientStoreModel{
+		ID:              clientID,
+		Name:            name,
+		Handle:          generatedHandle(playerHandlePrefix, clientID),
+		Email:           identity.Email,
+		Password:        hash,
+		LocationID:      toNullString(""),
+		LocationName:    toNullString(""),
+		LocationType:    toNullString(""),
+		Status:          StatusVerified,
+		CreatedAt:       time.Now().UTC(),
+		LocationSharing: SharingFriends,
+	}
+	if err := d.store.CreateClient(ctx, &client); err != nil {
+		d.logger.Error("LoginWithIdentity: failed to create client", zap.String("email", identity.Email), zap.Error(err))
+		return nil, errors.New("failed to create client:" + err.Error())
+	}
+	return &client, nil
+}
@@ -2,6 +2,12 @@ package players
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"geogame/internal/locations"
 )
@@ -9,40 +15,654 @@ import (
 var _ Store = (*MemStore)(nil)
 
 type MemStore struct {
-	clientMap map[interface{}]*ClientStoreModel
+	mu              sync.RWMutex
+	clientMap       map[interface{}]*ClientStoreModel
+	verificationMap map[string]*VerificationStoreModel
+	sessionMap      map[string]*SessionStoreModel
+	friendships     []*FriendshipStoreModel
+	blockMap        map[string]*BlockStoreModel
+	locationAudits  []*LocationAuditStoreModel
+	identityMap     map[string]*IdentityStoreModel
+	emailChangeMap  map[string]*EmailChangeStoreModel
+	guestMap        map[string]*GuestStoreModel
+	handleHistory   []*HandleHistoryStoreModel
+	notes           []*NoteStoreModel
+	audits          []*ModerationAuditStoreModel
 }
 
 func NewMemStore(clientMap map[interface{}]*ClientStoreModel) *MemStore {
 	return &MemStore{
-		clientMap: clientMap,
+		clientMap:       clientMap,
+		verificationMap: make(map[string]*VerificationStoreModel),
+		sessionMap:      make(map[string]*SessionStoreModel),
+		blockMap:        make(map[string]*BlockStoreModel),
+		identityMap:     make(map[string]*IdentityStoreModel),
+		emailChangeMap:  make(map[string]*EmailChangeStoreModel),
+		guestMap:        make(map[string]*GuestStoreModel),
 	}
 }
 
 func (m *MemStore) CreateClient(ctx context.Context, model *ClientStoreModel) error {
-	m.clientMap[model.Email] = model
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createClient(model)
+}
+
+// createClient keys the client by id and by email, a guest has no email
+func (m *MemStore) createClient(model *ClientStoreModel) error {
+	if _, ok := m.clientMap[model.Email]; ok && model.Email != "" {
+		return ErrEmailTaken
+	}
+	if model.Handle != "" && m.clientByHandle(model.Handle) != nil {
+		return ErrHandleTaken
+	}
+	if model.LocationSharing == "" {
+		model.LocationSharing = SharingFriends
+	}
+	if model.Email != "" {
+		m.clientMap[model.Email] = model
+	}
 	m.clientMap[model.ID.String()] = model
 	return nil
 }
 
 func (m *MemStore) UpdateName(ctx context.Context, userID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	client := m.clientMap[userID]
 	client.Name = name
 	return nil
 }
 
+func (m *MemStore) UpdatePassword(ctx context.Context, clientID, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return ErrNotFound
+	}
+	client.Password = hash
+	return nil
+}
+
+func (m *MemStore) UpdateEmail(ctx context.Context, clientID, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return ErrNotFound
+	}
+	if other, ok := m.clientMap[email]; ok && other != client {
+		return ErrEmailTaken
+	}
+	if client.Email != "" {
+		delete(m.clientMap, client.Email)
+	}
+	client.Email = email
+	m.clientMap[email] = client
+	return nil
+}
+
 func (m *MemStore) UpdateLocation(ctx context.Context, userID string, point locations.LocationStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	client := m.clientMap[userID]
 	client.LocationID = toNullString(point.ID)
 	client.Point = point.Point
 	client.LocationType = toNullString(point.LocationType.String())
 	client.LocationName = toNullString(point.LocationName)
+	client.LocationUpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
 	return nil
 }
 
 func (m *MemStore) GetClientByEmail(ctx context.Context, emailID string) (*ClientStoreModel, error) {
-	return m.clientMap[emailID], nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client, ok := m.clientMap[emailID]
+	if !ok {
+		return nil, fmt.Errorf("%w: emailID %s is not registered", ErrNotFound, emailID)
+	}
+	return client, nil
 }
 
 func (m *MemStore) GetClientByID(ctx context.Context, id string) (*ClientStoreModel, error) {
-	return m.clientMap[id], nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client, ok := m.clientMap[id]
+	if !ok {
+		return nil, errors.New(" id :" + id + "is not registered")
+	}
+	return client, nil
+}
+
+func (m *MemStore) GetClientByHandle(ctx context.Context, handle string) (*ClientStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client := m.clientByHandle(handle)
+	if client == nil {
+		return nil, ErrNotFound
+	}
+	return client, nil
+}
+
+func (m *MemStore) clientByHandle(handle string) *ClientStoreModel {
+	for key, client := range m.clientMap {
+		if key == client.ID.String() && client.Handle != "" && strings.EqualFold(client.Handle, handle) {
+			return client
+		}
+	}
+	return nil
+}
+
+func (m *MemStore) UpdateHandle(ctx context.Context, clientID, handle string, changedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return ErrNotFound
+	}
+	if other := m.clientByHandle(handle); other != nil && other != client {
+		return ErrHandleTaken
+	}
+	m.handleHistory = append(m.handleHistory, &HandleHistoryStoreModel{ClientID: client.ID, Handle: client.Handle, ReleasedAt: changedAt})
+	client.Handle = handle
+	client.HandleChangedAt = sql.NullTime{Time: changedAt, Valid: true}
+	return nil
+}
+
+func (m *MemStore) IsHandleHeld(ctx context.Context, handle, clientID string, since time.Time) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, h := range m.handleHistory {
+		if strings.EqualFold(h.Handle, handle) && h.ClientID.String() != clientID && !h.ReleasedAt.Before(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemStore) ListHandleHistory(ctx context.Context, clientID string) ([]HandleHistoryStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	history := make([]HandleHistoryStoreModel, 0)
+	for i := len(m.handleHistory) - 1; i >= 0; i-- {
+		if m.handleHistory[i].ClientID.String() == clientID {
+			history = append(history, *m.handleHistory[i])
+		}
+	}
+	return history, nil
+}
+
+func (m *MemStore) UpdateStatus(ctx context.Context, clientID string, status AccountStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return ErrNotFound
+	}
+	client.Status = status
+	return nil
+}
+
+func (m *MemStore) DeleteUnverifiedClients(ctx context.Context, createdBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for key, client := range m.clientMap {
+		if key != client.Email {
+			continue
+		}
+		if client.Status == StatusUnverified && client.CreatedAt.Before(createdBefore) {
+			delete(m.clientMap, client.Email)
+			delete(m.clientMap, client.ID.String())
+			delete(m.verificationMap, client.ID.String())
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemStore) SaveVerification(ctx context.Context, model *VerificationStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.verificationMap[model.ClientID.String()] = model
+	return nil
+}
+
+func (m *MemStore) GetVerificationByTokenHash(ctx context.Context, tokenHash string) (*VerificationStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, v := range m.verificationMap {
+		if v.TokenHash == tokenHash {
+			return v, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemStore) GetVerificationByClientID(ctx context.Context, clientID string) (*VerificationStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.verificationMap[clientID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (m *MemStore) DeleteVerification(ctx context.Context, clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.verificationMap, clientID)
+	return nil
+}
+
+func (m *MemStore) CreateSession(ctx context.Context, model *SessionStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionMap[model.ID.String()] = model
+	return nil
+}
+
+func (m *MemStore) GetSession(ctx context.Context, id string) (*SessionStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, ok := m.sessionMap[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+func (m *MemStore) GetSessionByRefreshHash(ctx context.Context, refreshHash string) (*SessionStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, session := range m.sessionMap {
+		if session.RefreshTokenHash == refreshHash {
+			return session, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemStore) RotateSession(ctx context.Context, oldID string, model *SessionStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.sessionMap[oldID]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.RevokedAt.Valid {
+		return ErrSessionRotated
+	}
+	old.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	old.ReplacedBy = toNullString(model.ID.String())
+	m.sessionMap[model.ID.String()] = model
+	return nil
+}
+
+func (m *MemStore) RevokeSession(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessionMap[id]; ok && !session.RevokedAt.Valid {
+		session.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	return nil
+}
+
+func (m *MemStore) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	for _, session := range m.sessionMap {
+		if session.FamilyID.String() == familyID && !session.RevokedAt.Valid {
+			session.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *MemStore) RevokeClientSessions(ctx context.Context, clientID, keepFamilyID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	var revoked []string
+	for id, session := range m.sessionMap {
+		if session.ClientID.String() == clientID && session.FamilyID.String() != keepFamilyID && !session.RevokedAt.Valid {
+			session.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			revoked = append(revoked, id)
+		}
+	}
+	return revoked, nil
+}
+
+func (m *MemStore) SaveEmailChange(ctx context.Context, model *EmailChangeStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *model
+	m.emailChangeMap[model.ClientID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) GetEmailChangeByTokenHash(ctx context.Context, tokenHash string) (*EmailChangeStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, change := range m.emailChangeMap {
+		if change.TokenHash == tokenHash {
+			c := *change
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemStore) DeleteEmailChange(ctx context.Context, clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.emailChangeMap, clientID)
+	return nil
+}
+
+func (m *MemStore) UpdateLocationSharing(ctx context.Context, clientID string, sharing LocationSharing) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return ErrNotFound
+	}
+	client.LocationSharing = sharing
+	return nil
+}
+
+func (m *MemStore) CreateFriendship(ctx context.Context, model *FriendshipStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.findFriendship(model.RequesterID.String(), model.AddresseeID.String()) >= 0 {
+		return ErrFriendshipExists
+	}
+	m.friendships = append(m.friendships, model)
+	return nil
+}
+
+func (m *MemStore) GetFriendship(ctx context.Context, clientID, otherID string) (*FriendshipStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.findFriendship(clientID, otherID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return m.friendships[i], nil
+}
+
+func (m *MemStore) AcceptFriendship(ctx context.Context, requesterID, addresseeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.findFriendship(requesterID, addresseeID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	f := m.friendships[i]
+	if f.RequesterID.String() != requesterID || f.Status != FriendshipPending {
+		return ErrNotFound
+	}
+	f.Status = FriendshipAccepted
+	f.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MemStore) DeleteFriendship(ctx context.Context, clientID, otherID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.findFriendship(clientID, otherID); i >= 0 {
+		m.friendships = append(m.friendships[:i], m.friendships[i+1:]...)
+	}
+	return nil
+}
+
+func (m *MemStore) ListFriendships(ctx context.Context, clientID string) ([]FriendshipStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	friendships := make([]FriendshipStoreModel, 0)
+	for _, f := range m.friendships {
+		if f.RequesterID.String() == clientID || f.AddresseeID.String() == clientID {
+			friendships = append(friendships, *f)
+		}
+	}
+	return friendships, nil
+}
+
+func (m *MemStore) ListFriendLocations(ctx context.Context, clientID string) ([]ClientStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	clients := make([]ClientStoreModel, 0)
+	for _, f := range m.friendships {
+		if f.Status != FriendshipAccepted {
+			continue
+		}
+		var friendID string
+		switch clientID {
+		case f.RequesterID.String():
+			friendID = f.AddresseeID.String()
+		case f.AddresseeID.String():
+			friendID = f.RequesterID.String()
+		default:
+			continue
+		}
+		friend, ok := m.clientMap[friendID]
+		if !ok || friend.LocationSharing != SharingFriends || !friend.LocationUpdatedAt.Valid {
+			continue
+		}
+		clients = append(clients, *friend)
+	}
+	return clients, nil
+}
+
+func (m *MemStore) CreateBlock(ctx context.Context, model *BlockStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := model.BlockerID.String() + ":" + model.BlockedID.String()
+	if _, ok := m.blockMap[key]; !ok {
+		m.blockMap[key] = model
+	}
+	return nil
+}
+
+func (m *MemStore) DeleteBlock(ctx context.Context, blockerID, blockedID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blockMap, blockerID+":"+blockedID)
+	return nil
+}
+
+func (m *MemStore) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.blockMap[blockerID+":"+blockedID]
+	return ok, nil
+}
+
+// findFriendship returns the index of the friendship between the two players in any direction, -1 if there is none
+func (m *MemStore) findFriendship(clientID, otherID string) int {
+	for i, f := range m.friendships {
+		requester, addressee := f.RequesterID.String(), f.AddresseeID.String()
+		if (requester == clientID && addressee == otherID) || (requester == otherID && addressee == clientID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemStore) UpdatePrivacy(ctx context.Context, clientID string, settings PrivacySettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return ErrNotFound
+	}
+	client.Privacy = settings
+	return nil
+}
+
+func (m *MemStore) CreateLocationAudit(ctx context.Context, model *LocationAuditStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	model.ID = int64(len(m.locationAudits) + 1)
+	m.locationAudits = append(m.locationAudits, model)
+	return nil
+}
+
+func (m *MemStore) GetIdentity(ctx context.Context, provider, subject string) (*IdentityStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	identity, ok := m.identityMap[provider+"/"+subject]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *identity
+	return &c, nil
+}
+
+func (m *MemStore) CreateIdentity(ctx context.Context, model *IdentityStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := model.Provider + "/" + model.Subject
+	if _, ok := m.identityMap[key]; ok {
+		return ErrIdentityLinked
+	}
+	c := *model
+	m.identityMap[key] = &c
+	return nil
+}
+
+func (m *MemStore) CreateGuest(ctx context.Context, client *ClientStoreModel, guest *GuestStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.createClient(client); err != nil {
+		return err
+	}
+	c := *guest
+	m.guestMap[guest.ClientID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) GetGuest(ctx context.Context, clientID string) (*GuestStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	guest, ok := m.guestMap[clientID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *guest
+	return &c, nil
+}
+
+func (m *MemStore) DeleteGuest(ctx context.Context, clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.guestMap, clientID)
+	return nil
+}
+
+func (m *MemStore) DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	active := make(map[string]bool)
+	for _, session := range m.sessionMap {
+		if !session.CreatedAt.Before(inactiveSince) {
+			active[session.ClientID.String()] = true
+		}
+	}
+	var deleted int64
+	for key, client := range m.clientMap {
+		id := client.ID.String()
+		if key != id || client.Status != StatusGuest || active[id] || !client.CreatedAt.Before(inactiveSince) {
+			continue
+		}
+		if client.LocationUpdatedAt.Valid && !client.LocationUpdatedAt.Time.Before(inactiveSince) {
+			continue
+		}
+		delete(m.clientMap, id)
+		delete(m.guestMap, id)
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (m *MemStore) SearchClients(ctx context.Context, pattern string, limit int) ([]ClientStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	prefix := strings.ToLower(strings.NewReplacer(`\\`, `\`, `\%`, `%`, `\_`, `_`).Replace(pattern))
+	res := make([]ClientStoreModel, 0)
+	for key, client := range m.clientMap {
+		if key != client.ID.String() || len(res) == limit {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(client.Email), prefix) || strings.HasPrefix(strings.ToLower(client.Handle), prefix) {
+			res = append(res, *client)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) GetRestriction(ctx context.Context, clientID string) (*RestrictionStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	restriction := client.RestrictionStoreModel
+	return &restriction, nil
+}
+
+func (m *MemStore) UpdateRestriction(ctx context.Context, clientID string, restriction RestrictionStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clientMap[clientID]
+	if !ok {
+		return ErrNotFound
+	}
+	client.RestrictionStoreModel = restriction
+	return nil
+}
+
+func (m *MemStore) CreateNote(ctx context.Context, model *NoteStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := *model
+	m.notes = append(m.notes, &n)
+	return nil
+}
+
+func (m *MemStore) ListNotes(ctx context.Context, clientID string) ([]NoteStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	notes := make([]NoteStoreModel, 0)
+	for i := len(m.notes) - 1; i >= 0; i-- {
+		if m.notes[i].ClientID.String() == clientID {
+			notes = append(notes, *m.notes[i])
+		}
+	}
+	return notes, nil
+}
+
+func (m *MemStore) CreateModerationAudit(ctx context.Context, model *ModerationAuditStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	model.ID = int64(len(m.audits) + 1)
+	a := *model
+	m.audits = append(m.audits, &a)
+	return nil
+}
+
+func (m *MemStore) ListModerationAudits(ctx context.Context, clientID string) ([]ModerationAuditStoreModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	audits := make([]ModerationAuditStoreModel, 0)
+	for i := len(m.audits) - 1; i >= 0; i-- {
+		if m.audits[i].ClientID == clientID {
+			audits = append(audits, *m.audits[i])
+		}
+	}
+	return audits, nil
 }
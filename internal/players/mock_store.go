@@ -2,6 +2,8 @@ package players
 
 import (
 	"context"
+	"time"
+
 	"geogame/internal/locations"
 )
 
@@ -10,9 +12,64 @@ var _ Store = (*MockStore)(nil)
 type MockStore struct {
 	CreateClientFunc     func(model *ClientStoreModel) error
 	UpdateNameFunc       func(clientID, name string) error
+	UpdatePasswordFunc   func(clientID, hash string) error
+	UpdateEmailFunc      func(clientID, email string) error
 	UpdateLocationFunc   func(clientID string, point locations.LocationStoreModel) error
 	GetClientByEmailFunc func(emailID string) (*ClientStoreModel, error)
 	GetClientByIDFunc    func(id string) (*ClientStoreModel, error)
+
+	GetClientByHandleFunc func(handle string) (*ClientStoreModel, error)
+	UpdateHandleFunc      func(clientID, handle string, changedAt time.Time) error
+	IsHandleHeldFunc      func(handle, clientID string, since time.Time) (bool, error)
+	ListHandleHistoryFunc func(clientID string) ([]HandleHistoryStoreModel, error)
+
+	UpdateStatusFunc               func(clientID string, status AccountStatus) error
+	DeleteUnverifiedClientsFunc    func(createdBefore time.Time) (int64, error)
+	SaveVerificationFunc           func(model *VerificationStoreModel) error
+	GetVerificationByTokenHashFunc func(tokenHash string) (*VerificationStoreModel, error)
+	GetVerificationByClientIDFunc  func(clientID string) (*VerificationStoreModel, error)
+	DeleteVerificationFunc         func(clientID string) error
+	SaveEmailChangeFunc            func(model *EmailChangeStoreModel) error
+	GetEmailChangeByTokenHashFunc  func(tokenHash string) (*EmailChangeStoreModel, error)
+	DeleteEmailChangeFunc          func(clientID string) error
+
+	CreateSessionFunc           func(model *SessionStoreModel) error
+	GetSessionFunc              func(id string) (*SessionStoreModel, error)
+	GetSessionByRefreshHashFunc func(refreshHash string) (*SessionStoreModel, error)
+	RotateSessionFunc           func(oldID string, model *SessionStoreModel) error
+	RevokeSessionFunc           func(id string) error
+	RevokeSessionFamilyFunc     func(familyID string) error
+	RevokeClientSessionsFunc    func(clientID, keepFamilyID string) ([]string, error)
+
+	UpdateLocationSharingFunc func(clientID string, sharing LocationSharing) error
+	CreateFriendshipFunc      func(model *FriendshipStoreModel) error
+	GetFriendshipFunc         func(clientID, otherID string) (*FriendshipStoreModel, error)
+	AcceptFriendshipFunc      func(requesterID, addresseeID string) error
+	DeleteFriendshipFunc      func(clientID, otherID string) error
+	ListFriendshipsFunc       func(clientID string) ([]FriendshipStoreModel, error)
+	ListFriendLocationsFunc   func(clientID string) ([]ClientStoreModel, error)
+	CreateBlockFunc           func(model *BlockStoreModel) error
+	DeleteBlockFunc           func(blockerID, blockedID string) error
+	IsBlockedFunc             func(blockerID, blockedID string) (bool, error)
+
+	UpdatePrivacyFunc       func(clientID string, settings PrivacySettings) error
+	CreateLocationAuditFunc func(model *LocationAuditStoreModel) error
+
+	GetIdentityFunc    func(provider, subject string) (*IdentityStoreModel, error)
+	CreateIdentityFunc func(model *IdentityStoreModel) error
+
+	CreateGuestFunc          func(client *ClientStoreModel, guest *GuestStoreModel) error
+	GetGuestFunc             func(clientID string) (*GuestStoreModel, error)
+	DeleteGuestFunc          func(clientID string) error
+	DeleteInactiveGuestsFunc func(inactiveSince time.Time) (int64, error)
+
+	SearchClientsFunc         func(pattern string, limit int) ([]ClientStoreModel, error)
+	GetRestrictionFunc        func(clientID string) (*RestrictionStoreModel, error)
+	UpdateRestrictionFunc     func(clientID string, restriction RestrictionStoreModel) error
+	CreateNoteFunc            func(model *NoteStoreModel) error
+	ListNotesFunc             func(clientID string) ([]NoteStoreModel, error)
+	CreateModerationAuditFunc func(model *ModerationAuditStoreModel) error
+	ListModerationAuditsFunc  func(clientID string) ([]ModerationAuditStoreModel, error)
 }
 
 func NewMockStore() *MockStore {
@@ -23,6 +80,12 @@ func NewMockStore() *MockStore {
 		UpdateNameFunc: func(clientID, name string) error {
 			return nil
 		},
+		UpdatePasswordFunc: func(clientID, hash string) error {
+			return nil
+		},
+		UpdateEmailFunc: func(clientID, email string) error {
+			return nil
+		},
 		UpdateLocationFunc: func(clientID string, point locations.LocationStoreModel) error {
 			return nil
 		},
@@ -32,6 +95,141 @@ func NewMockStore() *MockStore {
 		GetClientByIDFunc: func(id string) (model *ClientStoreModel, e error) {
 			return &ClientStoreModel{}, nil
 		},
+		GetClientByHandleFunc: func(handle string) (*ClientStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		UpdateHandleFunc: func(clientID, handle string, changedAt time.Time) error {
+			return nil
+		},
+		IsHandleHeldFunc: func(handle, clientID string, since time.Time) (bool, error) {
+			return false, nil
+		},
+		ListHandleHistoryFunc: func(clientID string) ([]HandleHistoryStoreModel, error) {
+			return nil, nil
+		},
+		UpdateStatusFunc: func(clientID string, status AccountStatus) error {
+			return nil
+		},
+		DeleteUnverifiedClientsFunc: func(createdBefore time.Time) (int64, error) {
+			return 0, nil
+		},
+		SaveVerificationFunc: func(model *VerificationStoreModel) error {
+			return nil
+		},
+		GetVerificationByTokenHashFunc: func(tokenHash string) (*VerificationStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		GetVerificationByClientIDFunc: func(clientID string) (*VerificationStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		DeleteVerificationFunc: func(clientID string) error {
+			return nil
+		},
+		SaveEmailChangeFunc: func(model *EmailChangeStoreModel) error {
+			return nil
+		},
+		GetEmailChangeByTokenHashFunc: func(tokenHash string) (*EmailChangeStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		DeleteEmailChangeFunc: func(clientID string) error {
+			return nil
+		},
+		CreateSessionFunc: func(model *SessionStoreModel) error {
+			return nil
+		},
+		GetSessionFunc: func(id string) (*SessionStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		GetSessionByRefreshHashFunc: func(refreshHash string) (*SessionStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		RotateSessionFunc: func(oldID string, model *SessionStoreModel) error {
+			return nil
+		},
+		RevokeSessionFunc: func(id string) error {
+			return nil
+		},
+		RevokeSessionFamilyFunc: func(familyID string) error {
+			return nil
+		},
+		RevokeClientSessionsFunc: func(clientID, keepFamilyID string) ([]string, error) {
+			return nil, nil
+		},
+		UpdateLocationSharingFunc: func(clientID string, sharing LocationSharing) error {
+			return nil
+		},
+		CreateFriendshipFunc: func(model *FriendshipStoreModel) error {
+			return nil
+		},
+		GetFriendshipFunc: func(clientID, otherID string) (*FriendshipStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		AcceptFriendshipFunc: func(requesterID, addresseeID string) error {
+			return nil
+		},
+		DeleteFriendshipFunc: func(clientID, otherID string) error {
+			return nil
+		},
+		ListFriendshipsFunc: func(clientID string) ([]FriendshipStoreModel, error) {
+			return []FriendshipStoreModel{}, nil
+		},
+		ListFriendLocationsFunc: func(clientID string) ([]ClientStoreModel, error) {
+			return []ClientStoreModel{}, nil
+		},
+		CreateBlockFunc: func(model *BlockStoreModel) error {
+			return nil
+		},
+		DeleteBlockFunc: func(blockerID, blockedID string) error {
+			return nil
+		},
+		IsBlockedFunc: func(blockerID, blockedID string) (bool, error) {
+			return false, nil
+		},
+		UpdatePrivacyFunc: func(clientID string, settings PrivacySettings) error {
+			return nil
+		},
+		CreateLocationAuditFunc: func(model *LocationAuditStoreModel) error {
+			return nil
+		},
+		GetIdentityFunc: func(provider, subject string) (*IdentityStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		CreateIdentityFunc: func(model *IdentityStoreModel) error {
+			return nil
+		},
+		CreateGuestFunc: func(client *ClientStoreModel, guest *GuestStoreModel) error {
+			return nil
+		},
+		GetGuestFunc: func(clientID string) (*GuestStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		DeleteGuestFunc: func(clientID string) error {
+			return nil
+		},
+		DeleteInactiveGuestsFunc: func(inactiveSince time.Time) (int64, error) {
+			return 0, nil
+		},
+		SearchClientsFunc: func(pattern string, limit int) ([]ClientStoreModel, error) {
+			return nil, nil
+		},
+		GetRestrictionFunc: func(clientID string) (*RestrictionStoreModel, error) {
+			return &RestrictionStoreModel{}, nil
+		},
+		UpdateRestrictionFunc: func(clientID string, restriction RestrictionStoreModel) error {
+			return nil
+		},
+		CreateNoteFunc: func(model *NoteStoreModel) error {
+			return nil
+		},
+		ListNotesFunc: func(clientID string) ([]NoteStoreModel, error) {
+			return nil, nil
+		},
+		CreateModerationAuditFunc: func(model *ModerationAuditStoreModel) error {
+			return nil
+		},
+		ListModerationAuditsFunc: func(clientID string) ([]ModerationAuditStoreModel, error) {
+			return nil, nil
+		},
 	}
 }
 
@@ -43,6 +241,14 @@ func (m *MockStore) UpdateName(ctx context.Context, clientID, name string) error
 	return m.UpdateNameFunc(clientID, name)
 }
 
+func (m *MockStore) UpdatePassword(ctx context.Context, clientID, hash string) error {
+	return m.UpdatePasswordFunc(clientID, hash)
+}
+
+func (m *MockStore) UpdateEmail(ctx context.Context, clientID, email string) error {
+	return m.UpdateEmailFunc(clientID, email)
+}
+
 func (m *MockStore) UpdateLocation(ctx context.Context, clientID string, point locations.LocationStoreModel) error {
 	return m.UpdateLocationFunc(clientID, point)
 }
@@ -54,3 +260,183 @@ func (m *MockStore) GetClientByEmail(ctx context.Context, emailID string) (*Clie
 func (m *MockStore) GetClientByID(ctx context.Context, id string) (*ClientStoreModel, error) {
 	return m.GetClientByIDFunc(id)
 }
+
+func (m *MockStore) UpdateStatus(ctx context.Context, clientID string, status AccountStatus) error {
+	return m.UpdateStatusFunc(clientID, status)
+}
+
+func (m *MockStore) DeleteUnverifiedClients(ctx context.Context, createdBefore time.Time) (int64, error) {
+	return m.DeleteUnverifiedClientsFunc(createdBefore)
+}
+
+func (m *MockStore) SaveVerification(ctx context.Context, model *VerificationStoreModel) error {
+	return m.SaveVerificationFunc(model)
+}
+
+func (m *MockStore) GetVerificationByTokenHash(ctx context.Context, tokenHash string) (*VerificationStoreModel, error) {
+	return m.GetVerificationByTokenHashFunc(tokenHash)
+}
+
+func (m *MockStore) GetVerificationByClientID(ctx context.Context, clientID string) (*VerificationStoreModel, error) {
+	return m.GetVerificationByClientIDFunc(clientID)
+}
+
+func (m *MockStore) DeleteVerification(ctx context.Context, clientID string) error {
+	return m.DeleteVerificationFunc(clientID)
+}
+
+func (m *MockStore) SaveEmailChange(ctx context.Context, model *EmailChangeStoreModel) error {
+	return m.SaveEmailChangeFunc(model)
+}
+
+func (m *MockStore) GetEmailChangeByTokenHash(ctx context.Context, tokenHash string) (*EmailChangeStoreModel, error) {
+	return m.GetEmailChangeByTokenHashFunc(tokenHash)
+}
+
+func (m *MockStore) DeleteEmailChange(ctx context.Context, clientID string) error {
+	return m.DeleteEmailChangeFunc(clientID)
+}
+
+func (m *MockStore) CreateSession(ctx context.Context, model *SessionStoreModel) error {
+	return m.CreateSessionFunc(model)
+}
+
+func (m *MockStore) GetSession(ctx context.Context, id string) (*SessionStoreModel, error) {
+	return m.GetSessionFunc(id)
+}
+
+func (m *MockStore) GetSessionByRefreshHash(ctx context.Context, refreshHash string) (*SessionStoreModel, error) {
+	return m.GetSessionByRefreshHashFunc(refreshHash)
+}
+
+func (m *MockStore) RotateSession(ctx context.Context, oldID string, model *SessionStoreModel) error {
+	return m.RotateSessionFunc(oldID, model)
+}
+
+func (m *MockStore) RevokeSession(ctx context.Context, id string) error {
+	return m.RevokeSessionFunc(id)
+}
+
+func (m *MockStore) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	return m.RevokeSessionFamilyFunc(familyID)
+}
+
+func (m *MockStore) RevokeClientSessions(ctx context.Context, clientID, keepFamilyID string) ([]string, error) {
+	return m.RevokeClientSessionsFunc(clientID, keepFamilyID)
+}
+
+func (m *MockStore) UpdateLocationSharing(ctx context.Context, clientID string, sharing LocationSharing) error {
+	return m.UpdateLocationSharingFunc(clientID, sharing)
+}
+
+func (m *MockStore) CreateFriendship(ctx context.Context, model *FriendshipStoreModel) error {
+	return m.CreateFriendshipFunc(model)
+}
+
+func (m *MockStore) GetFriendship(ctx context.Context, clientID, otherID string) (*FriendshipStoreModel, error) {
+	return m.GetFriendshipFunc(clientID, otherID)
+}
+
+func (m *MockStore) AcceptFriendship(ctx context.Context, requesterID, addresseeID string) error {
+	return m.AcceptFriendshipFunc(requesterID, addresseeID)
+}
+
+func (m *MockStore) DeleteFriendship(ctx context.Context, clientID, otherID string) error {
+	return m.DeleteFriendshipFunc(clientID, otherID)
+}
+
+func (m *MockStore) ListFriendships(ctx context.Context, clientID string) ([]FriendshipStoreModel, error) {
+	return m.ListFriendshipsFunc(clientID)
+}
+
+func (m *MockStore) ListFriendLocations(ctx context.Context, clientID string) ([]ClientStoreModel, error) {
+	return m.ListFriendLocationsFunc(clientID)
+}
+
+func (m *MockStore) CreateBlock(ctx context.Context, model *BlockStoreModel) error {
+	return m.CreateBlockFunc(model)
+}
+
+func (m *MockStore) DeleteBlock(ctx context.Context, blockerID, blockedID string) error {
+	return m.DeleteBlockFunc(blockerID, blockedID)
+}
+
+func (m *MockStore) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	return m.IsBlockedFunc(blockerID, blockedID)
+}
+
+func (m *MockStore) UpdatePrivacy(ctx context.Context, clientID string, settings PrivacySettings) error {
+	return m.UpdatePrivacyFunc(clientID, settings)
+}
+
+func (m *MockStore) CreateLocationAudit(ctx context.Context, model *LocationAuditStoreModel) error {
+	return m.CreateLocationAuditFunc(model)
+}
+
+func (m *MockStore) GetIdentity(ctx context.Context, provider, subject string) (*IdentityStoreModel, error) {
+	return m.GetIdentityFunc(provider, subject)
+}
+
+func (m *MockStore) CreateIdentity(ctx context.Context, model *IdentityStoreModel) error {
+	return m.CreateIdentityFunc(model)
+}
+
+func (m *MockStore) CreateGuest(ctx context.Context, client *ClientStoreModel, guest *GuestStoreModel) error {
+	return m.CreateGuestFunc(client, guest)
+}
+
+func (m *MockStore) GetGuest(ctx context.Context, clientID string) (*GuestStoreModel, error) {
+	return m.GetGuestFunc(clientID)
+}
+
+func (m *MockStore) DeleteGuest(ctx context.Context, clientID string) error {
+	return m.DeleteGuestFunc(clientID)
+}
+
+func (m *MockStore) DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	return m.DeleteInactiveGuestsFunc(inactiveSince)
+}
+
+func (m *MockStore) GetClientByHandle(ctx context.Context, handle string) (*ClientStoreModel, error) {
+	return m.GetClientByHandleFunc(handle)
+}
+
+func (m *MockStore) UpdateHandle(ctx context.Context, clientID, handle string, changedAt time.Time) error {
+	return m.UpdateHandleFunc(clientID, handle, changedAt)
+}
+
+func (m *MockStore) IsHandleHeld(ctx context.Context, handle, clientID string, since time.Time) (bool, error) {
+	return m.IsHandleHeldFunc(handle, clientID, since)
+}
+
+func (m *MockStore) ListHandleHistory(ctx context.Context, clientID string) ([]HandleHistoryStoreModel, error) {
+	return m.ListHandleHistoryFunc(clientID)
+}
+
+func (m *MockStore) SearchClients(ctx context.Context, pattern string, limit int) ([]ClientStoreModel, error) {
+	return m.SearchClientsFunc(pattern, limit)
+}
+
+func (m *MockStore) GetRestriction(ctx context.Context, clientID string) (*RestrictionStoreModel, error) {
+	return m.GetRestrictionFunc(clientID)
+}
+
+func (m *MockStore) UpdateRestriction(ctx context.Context, clientID string, restriction RestrictionStoreModel) error {
+	return m.UpdateRestrictionFunc(clientID, restriction)
+}
+
+func (m *MockStore) CreateNote(ctx context.Context, model *NoteStoreModel) error {
+	return m.CreateNoteFunc(model)
+}
+
+func (m *MockStore) ListNotes(ctx context.Context, clientID string) ([]NoteStoreModel, error) {
+	return m.ListNotesFunc(clientID)
+}
+
+func (m *MockStore) CreateModerationAudit(ctx context.Context, model *ModerationAuditStoreModel) error {
+	return m.CreateModerationAuditFunc(model)
+}
+
+func (m *MockStore) ListModerationAudits(ctx context.Context, clientID string) ([]ModerationAuditStoreModel, error) {
+	return m.ListModerationAuditsFunc(clientID)
+}
@@ -2,6 +2,7 @@ package players
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -12,28 +13,271 @@ type RegisterPayload struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Handle is the public name of the player, a generated one is used when it is empty
+	Handle string `json:"handle"`
 }
 
 type LoginPayload struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// RemoteIP is set by the controller for the per-IP attempt tracking
+	RemoteIP string `json:"-"`
+}
+
+type UnlockPayload struct {
+	Email string `json:"email"`
+	IP    string `json:"ip"`
 }
 
 type UpdatePayload struct {
 	Name string `json:"name"`
 }
 
+type HandlePayload struct {
+	Handle string `json:"handle"`
+}
+
+type HandleResponse struct {
+	Handle string `json:"handle"`
+	// NextChangeAt is set while the change cooldown is running
+	NextChangeAt *time.Time `json:"nextChangeAt,omitempty"`
+}
+
+// PublicProfile is what every player may see of another player, it never contains the email or the name
+type PublicProfile struct {
+	Handle string `json:"handle"`
+	// PreviousHandles are the handles the player used before, the latest first
+	PreviousHandles []string  `json:"previousHandles"`
+	Guest           bool      `json:"guest"`
+	MemberSince     time.Time `json:"memberSince"`
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type ChangeEmailPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type GuestPayload struct {
+	DeviceID string `json:"deviceId"`
+}
+
+type GuestLoginPayload struct {
+	GuestID  string `json:"guestId"`
+	DeviceID string `json:"deviceId"`
+	Secret   string `json:"secret"`
+}
+
+// GuestResponse is returned once for a new guest, the secret is never shown again
+type GuestResponse struct {
+	GuestID      string `json:"guestId"`
+	Secret       string `json:"secret"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// UpgradePayload turns a guest into a full account, the name is optional
+type UpgradePayload struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ResendVerificationPayload struct {
+	Email string `json:"email"`
+}
+
+type RefreshPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// APIResponse holds the tokens of a login, a login waiting for the second factor only has the challenge
 type APIResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	Challenge    string `json:"challenge,omitempty"`
+}
+
+type TwoFactorPayload struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code"`
+}
+
+// LocationUpdate is handed to the location listeners after a player sent a position
+type LocationUpdate struct {
+	ClientID string
+	Handle   string
+	// Previous is the last stored position, nil for the first position of the player
+	Previous   *locations.GeoPoint
+	PreviousAt time.Time
+	GeoPoint   locations.GeoPoint
+	// Exposed is the position as other players see it, nil when the privacy settings hide the player
+	Exposed *locations.Location
+	// Mode is the privacy mode Exposed was made with
+	Mode PrivacyMode
+	At   time.Time
 }
 
 type ClientStoreModel struct {
 	ID           uuid.UUID       `db:"id"`
 	Name         string          `db:"name"`
+	Handle       string          `db:"handle"`
 	Email        string          `db:"email"`
 	Password     string          `db:"password"`
-	LocationID   sql.NullString  `db:"loc_id""`
+	LocationID   sql.NullString  `db:"loc_id"`
 	Point        locations.Point `db:"point"`
 	LocationName sql.NullString  `db:"loc_name"`
 	LocationType sql.NullString  `db:"loc_type"`
+	Status       AccountStatus   `db:"status"`
+	CreatedAt    time.Time       `db:"created_at"`
+	// LocationUpdatedAt is when the point was last sent by the client
+	LocationUpdatedAt sql.NullTime    `db:"loc_updated_at"`
+	LocationSharing   LocationSharing `db:"location_sharing"`
+	Privacy           PrivacySettings `db:"privacy"`
+	// HandleChangedAt is when the player last chose a handle, null for the generated one
+	HandleChangedAt sql.NullTime `db:"handle_changed_at"`
+	RestrictionStoreModel
+}
+
+type AccountStatus string
+
+const (
+	StatusUnverified AccountStatus = "unverified"
+	StatusVerified   AccountStatus = "verified"
+	// StatusGuest is an anonymous player without email, it signs in with the secret of its device
+	StatusGuest AccountStatus = "guest"
+)
+
+func (a AccountStatus) String() string {
+	return string(a)
+}
+
+type VerificationStoreModel struct {
+	ClientID  uuid.UUID `db:"client_id"`
+	TokenHash string    `db:"token_hash"`
+	SentAt    time.Time `db:"sent_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// EmailChangeStoreModel is a new email waiting to be verified, the client keeps the old one until then
+type EmailChangeStoreModel struct {
+	ClientID  uuid.UUID `db:"client_id"`
+	NewEmail  string    `db:"new_email"`
+	TokenHash string    `db:"token_hash"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// HandleHistoryStoreModel is a handle the client used before, it stays held for the client for a while
+type HandleHistoryStoreModel struct {
+	ClientID   uuid.UUID `db:"client_id"`
+	Handle     string    `db:"handle"`
+	ReleasedAt time.Time `db:"released_at"`
+}
+
+// GuestStoreModel binds a guest account to the device that created it
+type GuestStoreModel struct {
+	ClientID   uuid.UUID `db:"client_id"`
+	DeviceID   string    `db:"device_id"`
+	SecretHash string    `db:"secret_hash"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// SessionStoreModel is a login session, every refresh rotates it into a new session of the same family
+type SessionStoreModel struct {
+	ID               uuid.UUID      `db:"id"`
+	ClientID         uuid.UUID      `db:"client_id"`
+	FamilyID         uuid.UUID      `db:"family_id"`
+	RefreshTokenHash string         `db:"refresh_token_hash"`
+	CreatedAt        time.Time      `db:"created_at"`
+	ExpiresAt        time.Time      `db:"expires_at"`
+	RevokedAt        sql.NullTime   `db:"revoked_at"`
+	ReplacedBy       sql.NullString `db:"replaced_by"`
+}
+
+// LocationSharing decides who may see the last known position of a player
+type LocationSharing string
+
+const (
+	SharingFriends LocationSharing = "friends"
+	SharingNobody  LocationSharing = "nobody"
+)
+
+func (l LocationSharing) String() string {
+	return string(l)
+}
+
+func (l LocationSharing) Valid() bool {
+	return l == SharingFriends || l == SharingNobody
+}
+
+type FriendshipStatus string
+
+const (
+	FriendshipPending  FriendshipStatus = "pending"
+	FriendshipAccepted FriendshipStatus = "accepted"
+)
+
+// FriendshipStoreModel is a friend request from the requester to the addressee, it becomes a friendship once accepted
+type FriendshipStoreModel struct {
+	RequesterID uuid.UUID        `db:"requester_id"`
+	AddresseeID uuid.UUID        `db:"addressee_id"`
+	Status      FriendshipStatus `db:"status"`
+	CreatedAt   time.Time        `db:"created_at"`
+	UpdatedAt   time.Time        `db:"updated_at"`
+}
+
+// Other returns the id of the player on the other side of the friendship
+func (f *FriendshipStoreModel) Other(clientID uuid.UUID) uuid.UUID {
+	if f.RequesterID == clientID {
+		return f.AddresseeID
+	}
+	return f.RequesterID
+}
+
+type BlockStoreModel struct {
+	BlockerID uuid.UUID `db:"blocker_id"`
+	BlockedID uuid.UUID `db:"blocked_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// FriendPayload names the other player by id or by handle
+type FriendPayload struct {
+	FriendID string `json:"friendId"`
+	Handle   string `json:"handle"`
+}
+
+type SharingPayload struct {
+	Sharing LocationSharing `json:"sharing"`
+}
+
+// FriendState is the relation of a friend entry seen from the caller
+type FriendState string
+
+const (
+	FriendStateAccepted FriendState = "accepted"
+	FriendStateIncoming FriendState = "incoming"
+	FriendStateOutgoing FriendState = "outgoing"
+)
+
+type Friend struct {
+	ID     string      `json:"id"`
+	Handle string      `json:"handle"`
+	State  FriendState `json:"state"`
+	Since  time.Time   `json:"since"`
+}
+
+type FriendLocation struct {
+	ID        string             `json:"id"`
+	Handle    string             `json:"handle"`
+	Location  locations.Location `json:"location"`
+	UpdatedAt time.Time          `json:"updatedAt"`
 }
@@ -0,0 +1,421 @@
+package players
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// searchLimit caps the players returned by a search
+const searchLimit = 50
+
+var (
+	ErrAccountSuspended  = errors.New("account is suspended")
+	ErrAccountBanned     = errors.New("account is banned")
+	ErrEmptySearch       = errors.New("empty search")
+	ErrEmptyNote         = errors.New("empty note")
+	ErrReasonRequired    = errors.New("a reason is required")
+	ErrInvalidSuspension = errors.New("suspension must end in the future")
+)
+
+type Standing string
+
+const (
+	StandingActive    Standing = "active"
+	StandingSuspended Standing = "suspended"
+	StandingBanned    Standing = "banned"
+)
+
+// the actions recorded in the moderation audit
+const (
+	AuditSearch       = "search"
+	AuditView         = "view"
+	AuditViewPosition = "view_position"
+	AuditSuspend      = "suspend"
+	AuditBan          = "ban"
+	AuditReinstate    = "reinstate"
+	AuditLogout       = "logout"
+	AuditNote         = "note"
+)
+
+// RestrictionStoreModel is the suspension or ban of a player, a ban wins over a suspension
+type RestrictionStoreModel struct {
+	SuspendedUntil sql.NullTime `db:"suspended_until"`
+	BannedAt       sql.NullTime `db:"banned_at"`
+	Reason         string       `db:"restriction_reason"`
+}
+
+func (r RestrictionStoreModel) standing(now time.Time) Standing {
+	switch {
+	case r.BannedAt.Valid:
+		return StandingBanned
+	case r.SuspendedUntil.Valid && now.Before(r.SuspendedUntil.Time):
+		return StandingSuspended
+	}
+	return StandingActive
+}
+
+// err tells the player why the login is rejected
+func (r RestrictionStoreModel) err(now time.Time) error {
+	switch r.standing(now) {
+	case StandingBanned:
+		return ErrAccountBanned
+	case StandingSuspended:
+		return fmt.Errorf("%w: until %s", ErrAccountSuspended, r.SuspendedUntil.Time.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// NoteStoreModel is a moderator note on a player, only admins see them
+type NoteStoreModel struct {
+	ID        uuid.UUID `db:"id"`
+	ClientID  uuid.UUID `db:"client_id"`
+	Author    string    `db:"author"`
+	Body      string    `db:"body"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ModerationAuditStoreModel records an admin action on the players, ClientID is empty for searches
+type ModerationAuditStoreModel struct {
+	ID        int64     `db:"id"`
+	ClientID  string    `db:"client_id"`
+	Actor     string    `db:"actor"`
+	Action    string    `db:"action"`
+	Detail    string    `db:"detail"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type SuspendPayload struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+	Actor  string    `json:"-"`
+}
+
+// ModerationPayload bans or reinstates a player
+type ModerationPayload struct {
+	Reason string `json:"reason"`
+	Actor  string `json:"-"`
+}
+
+type NotePayload struct {
+	Body  string `json:"body"`
+	Actor string `json:"-"`
+}
+
+type PlayerSummary struct {
+	ID       string        `json:"id"`
+	Handle   string        `json:"handle"`
+	Email    string        `json:"email,omitempty"`
+	Status   AccountStatus `json:"status"`
+	Standing Standing      `json:"standing"`
+}
+
+// AdminPlayer is the profile of a player as the admins see it
+type AdminPlayer struct {
+	PlayerSummary
+	Name              string     `json:"name"`
+	SuspendedUntil    *time.Time `json:"suspendedUntil,omitempty"`
+	BannedAt          *time.Time `json:"bannedAt,omitempty"`
+	RestrictionReason string     `json:"restrictionReason,omitempty"`
+	LocationUpdatedAt *time.Time `json:"locationUpdatedAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	Notes             []Note     `json:"notes"`
+}
+
+type Note struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AuditEntry struct {
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SearchPlayers finds the players by id, or by the start of their email or handle
+func (d *DefaultService) SearchPlayers(ctx context.Context, query, actor string) ([]PlayerSummary, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySearch
+	}
+	if err := d.audit(ctx, "", actor, AuditSearch, query); err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	now := time.Now()
+	res := make([]PlayerSummary, 0)
+	if _, err := uuid.Parse(query); err == nil {
+		if client, err := d.store.GetClientByID(dbCtx, query); err == nil {
+			res = append(res, toPlayerSummary(client, now))
+		}
+		return res, nil
+	}
+	clients, err := d.store.SearchClients(dbCtx, escapeLike(query), searchLimit)
+	if err != nil {
+		d.logger.Error("SearchPlayers: failed to search clients", zap.String("query", query), zap.Error(err))
+		return nil, errors.New("failed to search players:" + err.Error())
+	}
+	for i := range clients {
+		res = append(res, toPlayerSummary(&clients[i], now))
+	}
+	return res, nil
+}
+
+// AdminGetPlayer returns the profile, the standing and the notes of the player
+func (d *DefaultService) AdminGetPlayer(ctx context.Context, clientID, actor string) (*AdminPlayer, error) {
+	client, err := d.moderatedClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.audit(ctx, clientID, actor, AuditView, ""); err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	notes, err := d.store.ListNotes(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("AdminGetPlayer: failed to list notes", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to get player:" + err.Error())
+	}
+	res := &AdminPlayer{
+		PlayerSummary:     toPlayerSummary(client, time.Now()),
+		Name:              client.Name,
+		SuspendedUntil:    nullTimePtr(client.SuspendedUntil),
+		BannedAt:          nullTimePtr(client.BannedAt),
+		RestrictionReason: client.Reason,
+		LocationUpdatedAt: nullTimePtr(client.LocationUpdatedAt),
+		CreatedAt:         client.CreatedAt,
+		Notes:             make([]Note, 0, len(notes)),
+	}
+	for _, n := range notes {
+		res.Notes = append(res.Notes, Note{ID: n.ID.String(), Author: n.Author, Body: n.Body, CreatedAt: n.CreatedAt})
+	}
+	return res, nil
+}
+
+// SuspendPlayer rejects the player until the end of the suspension, the sessions resume afterwards
+func (d *DefaultService) SuspendPlayer(ctx context.Context, payload SuspendPayload, clientID string) error {
+	if payload.Reason == "" {
+		return ErrReasonRequired
+	}
+	if !payload.Until.After(time.Now()) {
+		return ErrInvalidSuspension
+	}
+	client, err := d.moderatedClient(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if client.BannedAt.Valid {
+		return ErrAccountBanned
+	}
+	until := payload.Until.UTC()
+	detail := "until " + until.Format(time.RFC3339) + ": " + payload.Reason
+	if err := d.audit(ctx, clientID, payload.Actor, AuditSuspend, detail); err != nil {
+		return err
+	}
+	restriction := RestrictionStoreModel{SuspendedUntil: sql.NullTime{Time: until, Valid: true}, Reason: payload.Reason}
+	return d.updateRestriction(ctx, clientID, restriction)
+}
+
+// BanPlayer rejects the player until reinstated and revokes all its sessions, it returns their ids
+func (d *DefaultService) BanPlayer(ctx context.Context, payload ModerationPayload, clientID string) ([]string, error) {
+	if payload.Reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if _, err := d.moderatedClient(ctx, clientID); err != nil {
+		return nil, err
+	}
+	if err := d.audit(ctx, clientID, payload.Actor, AuditBan, payload.Reason); err != nil {
+		return nil, err
+	}
+	restriction := RestrictionStoreModel{BannedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, Reason: payload.Reason}
+	if err := d.updateRestriction(ctx, clientID, restriction); err != nil {
+		return nil, err
+	}
+	return d.revokeAllSessions(ctx, clientID)
+}
+
+// ReinstatePlayer lifts the suspension or the ban
+func (d *DefaultService) ReinstatePlayer(ctx context.Context, payload ModerationPayload, clientID string) error {
+	if payload.Reason == "" {
+		return ErrReasonRequired
+	}
+	if _, err := d.moderatedClient(ctx, clientID); err != nil {
+		return err
+	}
+	if err := d.audit(ctx, clientID, payload.Actor, AuditReinstate, payload.Reason); err != nil {
+		return err
+	}
+	return d.updateRestriction(ctx, clientID, RestrictionStoreModel{})
+}
+
+// ForceLogout revokes all the sessions of the player and returns their ids
+func (d *DefaultService) ForceLogout(ctx context.Context, clientID, actor string) ([]string, error) {
+	if _, err := d.moderatedClient(ctx, clientID); err != nil {
+		return nil, err
+	}
+	if err := d.audit(ctx, clientID, actor, AuditLogout, ""); err != nil {
+		return nil, err
+	}
+	return d.revokeAllSessions(ctx, clientID)
+}
+
+func (d *DefaultService) AddNote(ctx context.Context, payload NotePayload, clientID string) (*Note, error) {
+	body := strings.TrimSpace(payload.Body)
+	if body == "" {
+		return nil, ErrEmptyNote
+	}
+	client, err := d.moderatedClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	note := NoteStoreModel{
+		ID:        uuid.New(),
+		ClientID:  client.ID,
+		Author:    payload.Actor,
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.audit(ctx, clientID, payload.Actor, AuditNote, note.ID.String()); err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateNote(dbCtx, &note); err != nil {
+		d.logger.Error("AddNote: failed to create note", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to add note:" + err.Error())
+	}
+	return &Note{ID: note.ID.String(), Author: note.Author, Body: note.Body, CreatedAt: note.CreatedAt}, nil
+}
+
+// ListAudit returns the admin actions on the player, the newest first
+func (d *DefaultService) ListAudit(ctx context.Context, clientID string) ([]AuditEntry, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		return nil, ErrNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	audits, err := d.store.ListModerationAudits(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("ListAudit: failed to list audits", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list audit:" + err.Error())
+	}
+	res := make([]AuditEntry, 0, len(audits))
+	for _, a := range audits {
+		res = append(res, AuditEntry{Actor: a.Actor, Action: a.Action, Detail: a.Detail, CreatedAt: a.CreatedAt})
+	}
+	return res, nil
+}
+
+// IsRestricted implements the middleware.StandingChecker, unknown players count as restricted
+func (d *DefaultService) IsRestricted(ctx context.Context, clientID string) (bool, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		return true, nil
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	restriction, err := d.store.GetRestriction(dbCtx, clientID)
+	if err != nil {
+		if err == ErrNotFound {
+			return true, nil
+		}
+		return false, err
+	}
+	return restriction.standing(time.Now()) != StandingActive, nil
+}
+
+// checkStanding rejects the logins and refreshes of suspended or banned players
+func (d *DefaultService) checkStanding(client *ClientStoreModel) error {
+	if err := client.RestrictionStoreModel.err(time.Now()); err != nil {
+		d.logger.Warn("checkStanding: restricted player rejected", zap.String("clientID", client.ID.String()), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// audit records the admin action before it is done, an action that can't be audited is not done
+func (d *DefaultService) audit(ctx context.Context, clientID, actor, action, detail string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	audit := ModerationAuditStoreModel{
+		ClientID:  clientID,
+		Actor:     actor,
+		Action:    action,
+		Detail:    detail,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.CreateModerationAudit(dbCtx, &audit); err != nil {
+		d.logger.Error("audit: failed to write moderation audit", zap.String("clientID", clientID), zap.String("action", action), zap.Error(err))
+		return errors.New("failed to audit " + action + ":" + err.Error())
+	}
+	d.logger.Info("admin player action", zap.String("clientID", clientID), zap.String("actor", actor), zap.String("action", action))
+	return nil
+}
+
+func (d *DefaultService) moderatedClient(ctx context.Context, clientID string) (*ClientStoreModel, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		return nil, ErrNotFound
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("moderatedClient: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return nil, ErrNotFound
+	}
+	return client, nil
+}
+
+func (d *DefaultService) updateRestriction(ctx context.Context, clientID string, restriction RestrictionStoreModel) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdateRestriction(dbCtx, clientID, restriction); err != nil {
+		d.logger.Error("updateRestriction: failed to update restriction", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to update restriction:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) revokeAllSessions(ctx context.Context, clientID string) ([]string, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	revoked, err := d.store.RevokeClientSessions(dbCtx, clientID, uuid.Nil.String())
+	if err != nil {
+		d.logger.Error("revokeAllSessions: failed to revoke sessions", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to revoke sessions:" + err.Error())
+	}
+	return revoked, nil
+}
+
+func toPlayerSummary(client *ClientStoreModel, now time.Time) PlayerSummary {
+	return PlayerSummary{
+		ID:       client.ID.String(),
+		Handle:   client.Handle,
+		Email:    client.Email,
+		Status:   client.Status,
+		Standing: client.RestrictionStoreModel.standing(now),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// escapeLike makes the wildcards of LIKE match literally, the handles often have underscores
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
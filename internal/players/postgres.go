@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/lib/pq"
 
@@ -16,8 +18,29 @@ import (
 var _ Store = (*Postgres)(nil)
 
 const (
-	clientsAllCols = "id, name, email, password, loc_id, ST_AsBinary(point) AS point, loc_name, loc_type"
-	clientsTable   = "clients"
+	clientsAllCols       = "id, name, handle, COALESCE(email, '') AS email, password, loc_id, ST_AsBinary(point) AS point, loc_name, loc_type, status, created_at, loc_updated_at, location_sharing, privacy, handle_changed_at, suspended_until, banned_at, restriction_reason"
+	clientsTable         = "clients"
+	verificationsAllCols = "client_id, token_hash, sent_at, expires_at"
+	verificationsTable   = "email_verifications"
+	sessionsAllCols      = "id, client_id, family_id, refresh_token_hash, created_at, expires_at, revoked_at, replaced_by"
+	sessionsTable        = "sessions"
+	friendshipsAllCols   = "requester_id, addressee_id, status, created_at, updated_at"
+	friendshipsTable     = "friendships"
+	identitiesAllCols    = "provider, subject, client_id, email, created_at"
+	identitiesTable      = "client_identities"
+	emailChangesAllCols  = "client_id, new_email, token_hash, created_at, expires_at"
+	emailChangesTable    = "email_changes"
+	guestsAllCols        = "client_id, device_id, secret_hash, created_at"
+	guestsTable          = "guests"
+	handleHistoryAllCols = "client_id, handle, released_at"
+	handleHistoryTable   = "handle_history"
+	restrictionAllCols   = "suspended_until, banned_at, restriction_reason"
+	notesAllCols         = "id, client_id, author, body, created_at"
+	notesTable           = "player_notes"
+	playerAuditAllCols   = "id, client_id, actor, action, detail, created_at"
+	playerAuditTable     = "player_audit"
+	// handleIndex is the unique index on the lower case handle
+	handleIndex = "clients_handle_idx"
 )
 
 // Postgres holds the Postgres repository.
@@ -35,35 +58,47 @@ func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
 }
 
 func (p Postgres) CreateClient(ctx context.Context, model *ClientStoreModel) error {
-	stmt := `INSERT INTO clients (
+	_, err := p.db.NamedExecContext(ctx, insertClientStmt, *model)
+	return uniqueClientError(err)
+}
+
+// uniqueClientError tells a taken handle from a taken email
+func uniqueClientError(err error) error {
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+		if pgErr.Constraint == handleIndex {
+			return ErrHandleTaken
+		}
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// insertClientStmt stores the empty email of a guest as NULL so the unique index allows many guests
+const insertClientStmt = `INSERT INTO clients (
 	id,
 	name,
+	handle,
 	email,
 	password,
 	loc_id,
 	point,
 	loc_name,
-	loc_type
+	loc_type,
+	status,
+	created_at
 	) VALUES (
 	:id,
 	:name,
-	:email,
+	:handle,
+	NULLIF(:email, ''),
 	:password,
 	:loc_id,
 	:point,
 	:loc_name,
-	:loc_type
+	:loc_type,
+	:status,
+	:created_at
 	)`
-	_, err := p.db.NamedExecContext(ctx, stmt, *model)
-	if err != nil {
-		if pgErr, ok := err.(*pq.Error); ok {
-			if pgErr.Code == "23505" {
-				return errors.New("emailID already registered")
-			}
-		}
-	}
-	return err
-}
 
 func (p Postgres) UpdateName(ctx context.Context, clientID, name string) error {
 	stmt := `UPDATE clients SET
@@ -77,12 +112,46 @@ func (p Postgres) UpdateName(ctx context.Context, clientID, name string) error {
 	return err
 }
 
+func (p Postgres) UpdatePassword(ctx context.Context, clientID, hash string) error {
+	stmt := `UPDATE clients SET
+	password=$1
+	WHERE id=$2
+	`
+	_, err := p.db.ExecContext(ctx, stmt, hash, clientID)
+	if err != nil {
+		p.logger.Error("UpdatePassword: failed to update password to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) UpdateEmail(ctx context.Context, clientID, email string) error {
+	stmt := `UPDATE clients SET
+	email=$1
+	WHERE id=$2
+	`
+	res, err := p.db.ExecContext(ctx, stmt, email, clientID)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok {
+			if pgErr.Code == "23505" {
+				return ErrEmailTaken
+			}
+		}
+		p.logger.Error("UpdateEmail: failed to update email to db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (p Postgres) UpdateLocation(ctx context.Context, clientID string, point locations.LocationStoreModel) error {
 	stmt := `UPDATE clients SET
 	loc_id=$1,
 	point=$2,
 	loc_name=$3,
-	loc_type=$4
+	loc_type=$4,
+	loc_updated_at=now()
 	WHERE id=$5
 	`
 	_, err := p.db.ExecContext(ctx, stmt, point.ID, point.Point, point.LocationName, point.LocationType, clientID)
@@ -99,7 +168,7 @@ func (p Postgres) GetClientByEmail(ctx context.Context, emailID string) (*Client
 	if err := p.db.GetContext(ctx, &c, stmt, emailID); err != nil {
 		if err == sql.ErrNoRows {
 			p.logger.Error("GetClientByEmail: client is not found for the provided email", zap.Error(err))
-			return nil, errors.New(" emailID :" + emailID + "is not registered")
+			return nil, fmt.Errorf("%w: emailID %s is not registered", ErrNotFound, emailID)
 		}
 		p.logger.Error("GetClientByEmail: failed to get client by email from db", zap.Error(err))
 		return nil, err
@@ -120,3 +189,693 @@ func (p Postgres) GetClientByID(ctx context.Context, id string) (*ClientStoreMod
 	}
 	return &c, nil
 }
+
+func (p Postgres) GetClientByHandle(ctx context.Context, handle string) (*ClientStoreModel, error) {
+	stmt := "SELECT " + clientsAllCols + " FROM " + clientsTable + " WHERE lower(handle)=lower($1)"
+	var c ClientStoreModel
+	if err := p.db.GetContext(ctx, &c, stmt, handle); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetClientByHandle: failed to get client by handle from db", zap.Error(err))
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (p Postgres) UpdateHandle(ctx context.Context, clientID, handle string, changedAt time.Time) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("UpdateHandle: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO handle_history (client_id, handle, released_at)
+	SELECT id, handle, $2 FROM clients WHERE id=$1`
+	res, err := tx.ExecContext(ctx, stmt, clientID, changedAt)
+	if err != nil {
+		p.logger.Error("UpdateHandle: failed to insert handle history", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	stmt = `UPDATE clients SET
+	handle=$1,
+	handle_changed_at=$2
+	WHERE id=$3
+	`
+	if _, err := tx.ExecContext(ctx, stmt, handle, changedAt, clientID); err != nil {
+		if err := uniqueClientError(err); err == ErrHandleTaken {
+			return err
+		}
+		p.logger.Error("UpdateHandle: failed to update handle to db", zap.Error(err))
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) IsHandleHeld(ctx context.Context, handle, clientID string, since time.Time) (bool, error) {
+	stmt := `SELECT EXISTS (SELECT 1 FROM handle_history
+	WHERE lower(handle)=lower($1) AND client_id<>$2 AND released_at >= $3)`
+	var held bool
+	if err := p.db.GetContext(ctx, &held, stmt, handle, clientID, since); err != nil {
+		p.logger.Error("IsHandleHeld: failed to check handle history", zap.Error(err))
+		return false, err
+	}
+	return held, nil
+}
+
+func (p Postgres) ListHandleHistory(ctx context.Context, clientID string) ([]HandleHistoryStoreModel, error) {
+	stmt := "SELECT " + handleHistoryAllCols + " FROM " + handleHistoryTable + " WHERE client_id=$1 ORDER BY released_at DESC"
+	history := make([]HandleHistoryStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &history, stmt, clientID); err != nil {
+		p.logger.Error("ListHandleHistory: failed to list handle history from db", zap.Error(err))
+		return nil, err
+	}
+	return history, nil
+}
+
+func (p Postgres) UpdateStatus(ctx context.Context, clientID string, status AccountStatus) error {
+	stmt := `UPDATE clients SET
+	status=$1
+	WHERE id=$2
+	`
+	_, err := p.db.ExecContext(ctx, stmt, status, clientID)
+	if err != nil {
+		p.logger.Error("UpdateStatus: failed to update status to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) DeleteUnverifiedClients(ctx context.Context, createdBefore time.Time) (int64, error) {
+	stmt := `DELETE FROM clients
+	WHERE status=$1 AND created_at < $2`
+	res, err := p.db.ExecContext(ctx, stmt, StatusUnverified, createdBefore)
+	if err != nil {
+		p.logger.Error("DeleteUnverifiedClients: failed to delete clients from db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (p Postgres) SaveVerification(ctx context.Context, model *VerificationStoreModel) error {
+	stmt := `INSERT INTO email_verifications (
+	client_id,
+	token_hash,
+	sent_at,
+	expires_at
+	) VALUES (
+	:client_id,
+	:token_hash,
+	:sent_at,
+	:expires_at
+	) ON CONFLICT (client_id) DO UPDATE SET
+	token_hash=EXCLUDED.token_hash,
+	sent_at=EXCLUDED.sent_at,
+	expires_at=EXCLUDED.expires_at`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("SaveVerification: failed to save verification to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) GetVerificationByTokenHash(ctx context.Context, tokenHash string) (*VerificationStoreModel, error) {
+	stmt := "SELECT " + verificationsAllCols + " FROM " + verificationsTable + " WHERE token_hash=$1"
+	return p.getVerification(ctx, stmt, tokenHash)
+}
+
+func (p Postgres) GetVerificationByClientID(ctx context.Context, clientID string) (*VerificationStoreModel, error) {
+	stmt := "SELECT " + verificationsAllCols + " FROM " + verificationsTable + " WHERE client_id=$1"
+	return p.getVerification(ctx, stmt, clientID)
+}
+
+func (p Postgres) getVerification(ctx context.Context, stmt string, arg interface{}) (*VerificationStoreModel, error) {
+	var v VerificationStoreModel
+	if err := p.db.GetContext(ctx, &v, stmt, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("getVerification: failed to get verification from db", zap.Error(err))
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (p Postgres) DeleteVerification(ctx context.Context, clientID string) error {
+	stmt := `DELETE FROM email_verifications
+	WHERE client_id=$1`
+	_, err := p.db.ExecContext(ctx, stmt, clientID)
+	if err != nil {
+		p.logger.Error("DeleteVerification: failed to delete verification from db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) SaveEmailChange(ctx context.Context, model *EmailChangeStoreModel) error {
+	stmt := `INSERT INTO email_changes (
+	client_id,
+	new_email,
+	token_hash,
+	created_at,
+	expires_at
+	) VALUES (
+	:client_id,
+	:new_email,
+	:token_hash,
+	:created_at,
+	:expires_at
+	) ON CONFLICT (client_id) DO UPDATE SET
+	new_email=EXCLUDED.new_email,
+	token_hash=EXCLUDED.token_hash,
+	created_at=EXCLUDED.created_at,
+	expires_at=EXCLUDED.expires_at`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("SaveEmailChange: failed to save email change to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) GetEmailChangeByTokenHash(ctx context.Context, tokenHash string) (*EmailChangeStoreModel, error) {
+	stmt := "SELECT " + emailChangesAllCols + " FROM " + emailChangesTable + " WHERE token_hash=$1"
+	var c EmailChangeStoreModel
+	if err := p.db.GetContext(ctx, &c, stmt, tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetEmailChangeByTokenHash: failed to get email change from db", zap.Error(err))
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (p Postgres) DeleteEmailChange(ctx context.Context, clientID string) error {
+	stmt := `DELETE FROM email_changes
+	WHERE client_id=$1`
+	_, err := p.db.ExecContext(ctx, stmt, clientID)
+	if err != nil {
+		p.logger.Error("DeleteEmailChange: failed to delete email change from db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) CreateSession(ctx context.Context, model *SessionStoreModel) error {
+	_, err := p.db.NamedExecContext(ctx, insertSessionStmt, *model)
+	if err != nil {
+		p.logger.Error("CreateSession: failed to insert session to db", zap.Error(err))
+	}
+	return err
+}
+
+const insertSessionStmt = `INSERT INTO sessions (
+	id,
+	client_id,
+	family_id,
+	refresh_token_hash,
+	created_at,
+	expires_at
+	) VALUES (
+	:id,
+	:client_id,
+	:family_id,
+	:refresh_token_hash,
+	:created_at,
+	:expires_at
+	)`
+
+func (p Postgres) GetSession(ctx context.Context, id string) (*SessionStoreModel, error) {
+	stmt := "SELECT " + sessionsAllCols + " FROM " + sessionsTable + " WHERE id=$1"
+	return p.getSession(ctx, stmt, id)
+}
+
+func (p Postgres) GetSessionByRefreshHash(ctx context.Context, refreshHash string) (*SessionStoreModel, error) {
+	stmt := "SELECT " + sessionsAllCols + " FROM " + sessionsTable + " WHERE refresh_token_hash=$1"
+	return p.getSession(ctx, stmt, refreshHash)
+}
+
+func (p Postgres) getSession(ctx context.Context, stmt string, arg interface{}) (*SessionStoreModel, error) {
+	var session SessionStoreModel
+	if err := p.db.GetContext(ctx, &session, stmt, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("getSession: failed to get session from db", zap.Error(err))
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateSession revokes the old session and inserts its replacement in one transaction,
+// only one of several concurrent rotations of the same session can succeed
+func (p Postgres) RotateSession(ctx context.Context, oldID string, model *SessionStoreModel) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("RotateSession: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE sessions SET
+	revoked_at=now(),
+	replaced_by=$1
+	WHERE id=$2 AND revoked_at IS NULL
+	`
+	res, err := tx.ExecContext(ctx, stmt, model.ID.String(), oldID)
+	if err != nil {
+		p.logger.Error("RotateSession: failed to revoke session", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrSessionRotated
+	}
+	if _, err := tx.NamedExecContext(ctx, insertSessionStmt, *model); err != nil {
+		p.logger.Error("RotateSession: failed to insert session", zap.Error(err))
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) RevokeSession(ctx context.Context, id string) error {
+	stmt := `UPDATE sessions SET
+	revoked_at=now()
+	WHERE id=$1 AND revoked_at IS NULL
+	`
+	_, err := p.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		p.logger.Error("RevokeSession: failed to revoke session", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	stmt := `UPDATE sessions SET
+	revoked_at=now()
+	WHERE family_id=$1 AND revoked_at IS NULL
+	`
+	_, err := p.db.ExecContext(ctx, stmt, familyID)
+	if err != nil {
+		p.logger.Error("RevokeSessionFamily: failed to revoke session family", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) RevokeClientSessions(ctx context.Context, clientID, keepFamilyID string) ([]string, error) {
+	stmt := `UPDATE sessions SET
+	revoked_at=now()
+	WHERE client_id=$1 AND family_id<>$2 AND revoked_at IS NULL
+	RETURNING id
+	`
+	var ids []string
+	if err := p.db.SelectContext(ctx, &ids, stmt, clientID, keepFamilyID); err != nil {
+		p.logger.Error("RevokeClientSessions: failed to revoke sessions", zap.Error(err))
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (p Postgres) UpdateLocationSharing(ctx context.Context, clientID string, sharing LocationSharing) error {
+	stmt := `UPDATE clients SET
+	location_sharing=$1
+	WHERE id=$2
+	`
+	_, err := p.db.ExecContext(ctx, stmt, sharing, clientID)
+	if err != nil {
+		p.logger.Error("UpdateLocationSharing: failed to update location sharing to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) CreateFriendship(ctx context.Context, model *FriendshipStoreModel) error {
+	stmt := `INSERT INTO friendships (
+	requester_id,
+	addressee_id,
+	status,
+	created_at,
+	updated_at
+	) VALUES (
+	:requester_id,
+	:addressee_id,
+	:status,
+	:created_at,
+	:updated_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok {
+			if pgErr.Code == "23505" {
+				return ErrFriendshipExists
+			}
+		}
+		p.logger.Error("CreateFriendship: failed to insert friendship to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) GetFriendship(ctx context.Context, clientID, otherID string) (*FriendshipStoreModel, error) {
+	stmt := "SELECT " + friendshipsAllCols + " FROM " + friendshipsTable + `
+	WHERE (requester_id=$1 AND addressee_id=$2) OR (requester_id=$2 AND addressee_id=$1)`
+	var f FriendshipStoreModel
+	if err := p.db.GetContext(ctx, &f, stmt, clientID, otherID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetFriendship: failed to get friendship from db", zap.Error(err))
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (p Postgres) AcceptFriendship(ctx context.Context, requesterID, addresseeID string) error {
+	stmt := `UPDATE friendships SET
+	status=$1,
+	updated_at=now()
+	WHERE requester_id=$2 AND addressee_id=$3 AND status=$4
+	`
+	res, err := p.db.ExecContext(ctx, stmt, FriendshipAccepted, requesterID, addresseeID, FriendshipPending)
+	if err != nil {
+		p.logger.Error("AcceptFriendship: failed to accept friendship", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) DeleteFriendship(ctx context.Context, clientID, otherID string) error {
+	stmt := `DELETE FROM friendships
+	WHERE (requester_id=$1 AND addressee_id=$2) OR (requester_id=$2 AND addressee_id=$1)`
+	_, err := p.db.ExecContext(ctx, stmt, clientID, otherID)
+	if err != nil {
+		p.logger.Error("DeleteFriendship: failed to delete friendship from db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) ListFriendships(ctx context.Context, clientID string) ([]FriendshipStoreModel, error) {
+	stmt := "SELECT " + friendshipsAllCols + " FROM " + friendshipsTable + `
+	WHERE requester_id=$1 OR addressee_id=$1
+	ORDER BY created_at`
+	friendships := make([]FriendshipStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &friendships, stmt, clientID); err != nil {
+		p.logger.Error("ListFriendships: failed to list friendships from db", zap.Error(err))
+		return nil, err
+	}
+	return friendships, nil
+}
+
+// ListFriendLocations returns the accepted friends sharing their location with friends
+func (p Postgres) ListFriendLocations(ctx context.Context, clientID string) ([]ClientStoreModel, error) {
+	stmt := "SELECT " + clientsAllCols + " FROM " + clientsTable + `
+	WHERE location_sharing=$2 AND point IS NOT NULL AND id IN (
+		SELECT addressee_id FROM friendships WHERE requester_id=$1 AND status=$3
+		UNION
+		SELECT requester_id FROM friendships WHERE addressee_id=$1 AND status=$3
+	)`
+	clients := make([]ClientStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &clients, stmt, clientID, SharingFriends, FriendshipAccepted); err != nil {
+		p.logger.Error("ListFriendLocations: failed to list friend locations from db", zap.Error(err))
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (p Postgres) CreateBlock(ctx context.Context, model *BlockStoreModel) error {
+	stmt := `INSERT INTO blocks (
+	blocker_id,
+	blocked_id,
+	created_at
+	) VALUES (
+	:blocker_id,
+	:blocked_id,
+	:created_at
+	) ON CONFLICT (blocker_id, blocked_id) DO NOTHING`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("CreateBlock: failed to insert block to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) DeleteBlock(ctx context.Context, blockerID, blockedID string) error {
+	stmt := `DELETE FROM blocks
+	WHERE blocker_id=$1 AND blocked_id=$2`
+	_, err := p.db.ExecContext(ctx, stmt, blockerID, blockedID)
+	if err != nil {
+		p.logger.Error("DeleteBlock: failed to delete block from db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	stmt := `SELECT EXISTS (SELECT 1 FROM blocks WHERE blocker_id=$1 AND blocked_id=$2)`
+	var blocked bool
+	if err := p.db.GetContext(ctx, &blocked, stmt, blockerID, blockedID); err != nil {
+		p.logger.Error("IsBlocked: failed to get block from db", zap.Error(err))
+		return false, err
+	}
+	return blocked, nil
+}
+
+func (p Postgres) UpdatePrivacy(ctx context.Context, clientID string, settings PrivacySettings) error {
+	stmt := `UPDATE clients SET
+	privacy=$1
+	WHERE id=$2
+	`
+	_, err := p.db.ExecContext(ctx, stmt, settings, clientID)
+	if err != nil {
+		p.logger.Error("UpdatePrivacy: failed to update privacy to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) CreateLocationAudit(ctx context.Context, model *LocationAuditStoreModel) error {
+	stmt := `INSERT INTO location_access_audit (
+	client_id,
+	actor,
+	reason,
+	created_at
+	) VALUES (
+	:client_id,
+	:actor,
+	:reason,
+	:created_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("CreateLocationAudit: failed to insert location audit to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) GetIdentity(ctx context.Context, provider, subject string) (*IdentityStoreModel, error) {
+	stmt := "SELECT " + identitiesAllCols + " FROM " + identitiesTable + " WHERE provider=$1 AND subject=$2"
+	var identity IdentityStoreModel
+	if err := p.db.GetContext(ctx, &identity, stmt, provider, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetIdentity: failed to get identity from db", zap.Error(err))
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (p Postgres) CreateIdentity(ctx context.Context, model *IdentityStoreModel) error {
+	stmt := `INSERT INTO client_identities (
+	provider,
+	subject,
+	client_id,
+	email,
+	created_at
+	) VALUES (
+	:provider,
+	:subject,
+	:client_id,
+	:email,
+	:created_at
+	)`
+	if _, err := p.db.NamedExecContext(ctx, stmt, *model); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return ErrIdentityLinked
+		}
+		p.logger.Error("CreateIdentity: failed to insert identity to db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) CreateGuest(ctx context.Context, client *ClientStoreModel, guest *GuestStoreModel) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("CreateGuest: failed to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, insertClientStmt, *client); err != nil {
+		p.logger.Error("CreateGuest: failed to insert client", zap.Error(err))
+		return uniqueClientError(err)
+	}
+	stmt := `INSERT INTO guests (
+	client_id,
+	device_id,
+	secret_hash,
+	created_at
+	) VALUES (
+	:client_id,
+	:device_id,
+	:secret_hash,
+	:created_at
+	)`
+	if _, err := tx.NamedExecContext(ctx, stmt, *guest); err != nil {
+		p.logger.Error("CreateGuest: failed to insert guest", zap.Error(err))
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p Postgres) GetGuest(ctx context.Context, clientID string) (*GuestStoreModel, error) {
+	stmt := "SELECT " + guestsAllCols + " FROM " + guestsTable + " WHERE client_id=$1"
+	var guest GuestStoreModel
+	if err := p.db.GetContext(ctx, &guest, stmt, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetGuest: failed to get guest from db", zap.Error(err))
+		return nil, err
+	}
+	return &guest, nil
+}
+
+func (p Postgres) DeleteGuest(ctx context.Context, clientID string) error {
+	stmt := `DELETE FROM guests
+	WHERE client_id=$1`
+	_, err := p.db.ExecContext(ctx, stmt, clientID)
+	if err != nil {
+		p.logger.Error("DeleteGuest: failed to delete guest from db", zap.Error(err))
+	}
+	return err
+}
+
+// DeleteInactiveGuests relies on the rotation of the sessions, every refresh creates a new one
+func (p Postgres) DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	stmt := `DELETE FROM clients c
+	WHERE c.status=$1 AND c.created_at < $2
+	AND (c.loc_updated_at IS NULL OR c.loc_updated_at < $2)
+	AND NOT EXISTS (SELECT 1 FROM sessions s WHERE s.client_id=c.id AND s.created_at >= $2)`
+	res, err := p.db.ExecContext(ctx, stmt, StatusGuest, inactiveSince)
+	if err != nil {
+		p.logger.Error("DeleteInactiveGuests: failed to delete guests from db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (p Postgres) SearchClients(ctx context.Context, pattern string, limit int) ([]ClientStoreModel, error) {
+	stmt := "SELECT " + clientsAllCols + " FROM " + clientsTable +
+		` WHERE lower(email) LIKE lower($1) || '%' OR lower(handle) LIKE lower($1) || '%'
+	ORDER BY handle LIMIT $2`
+	clients := make([]ClientStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &clients, stmt, pattern, limit); err != nil {
+		p.logger.Error("SearchClients: failed to search clients in db", zap.Error(err))
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (p Postgres) GetRestriction(ctx context.Context, clientID string) (*RestrictionStoreModel, error) {
+	stmt := "SELECT " + restrictionAllCols + " FROM " + clientsTable + " WHERE id=$1"
+	var restriction RestrictionStoreModel
+	if err := p.db.GetContext(ctx, &restriction, stmt, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetRestriction: failed to get restriction from db", zap.Error(err))
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+func (p Postgres) UpdateRestriction(ctx context.Context, clientID string, restriction RestrictionStoreModel) error {
+	stmt := `UPDATE clients SET
+	suspended_until=$1,
+	banned_at=$2,
+	restriction_reason=$3
+	WHERE id=$4
+	`
+	res, err := p.db.ExecContext(ctx, stmt, restriction.SuspendedUntil, restriction.BannedAt, restriction.Reason, clientID)
+	if err != nil {
+		p.logger.Error("UpdateRestriction: failed to update restriction to db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) CreateNote(ctx context.Context, model *NoteStoreModel) error {
+	stmt := `INSERT INTO player_notes (
+	id,
+	client_id,
+	author,
+	body,
+	created_at
+	) VALUES (
+	:id,
+	:client_id,
+	:author,
+	:body,
+	:created_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("CreateNote: failed to insert note to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) ListNotes(ctx context.Context, clientID string) ([]NoteStoreModel, error) {
+	stmt := "SELECT " + notesAllCols + " FROM " + notesTable + " WHERE client_id=$1 ORDER BY created_at DESC"
+	notes := make([]NoteStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &notes, stmt, clientID); err != nil {
+		p.logger.Error("ListNotes: failed to list notes from db", zap.Error(err))
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (p Postgres) CreateModerationAudit(ctx context.Context, model *ModerationAuditStoreModel) error {
+	stmt := `INSERT INTO player_audit (
+	client_id,
+	actor,
+	action,
+	detail,
+	created_at
+	) VALUES (
+	:client_id,
+	:actor,
+	:action,
+	:detail,
+	:created_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("CreateModerationAudit: failed to insert player audit to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) ListModerationAudits(ctx context.Context, clientID string) ([]ModerationAuditStoreModel, error) {
+	stmt := "SELECT " + playerAuditAllCols + " FROM " + playerAuditTable + " WHERE client_id=$1 ORDER BY created_at DESC, id DESC"
+	audits := make([]ModerationAuditStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &audits, stmt, clientID); err != nil {
+		p.logger.Error("ListModerationAudits: failed to list player audit from db", zap.Error(err))
+		return nil, err
+	}
+	return audits, nil
+}
@@ -0,0 +1,397 @@
+package players
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+	"go.uber.org/zap"
+
+	"geogame/internal/locations"
+)
+
+var (
+	ErrInvalidPrivacySettings = errors.New("invalid privacy settings")
+	ErrLocationHidden         = errors.New("location is hidden by the privacy settings of the player")
+	ErrOverrideReasonRequired = errors.New("a reason is required to override the privacy settings")
+)
+
+// PrivacyMode decides how precise the position of a player is exposed to other players
+type PrivacyMode string
+
+const (
+	// PrivacyExact exposes the position as sent
+	PrivacyExact PrivacyMode = "exact"
+	// PrivacyGrid exposes the centre of the grid cell containing the position
+	PrivacyGrid PrivacyMode = "grid"
+	// PrivacyOffset exposes the position moved by a random offset, stable for each sent position
+	PrivacyOffset PrivacyMode = "offset"
+	// PrivacyCity exposes the position on city level only
+	PrivacyCity PrivacyMode = "city"
+	// PrivacyGhost hides the position
+	PrivacyGhost PrivacyMode = "ghost"
+)
+
+const (
+	defaultGridPrecision   = 1000
+	defaultOffsetPrecision = 500
+	cityPrecision          = 10000
+	minPrecision           = 100
+	maxPrecision           = 50000
+	maxPrivacyZones        = 10
+	metersPerDegree        = 111320
+)
+
+func (m PrivacyMode) Valid() bool {
+	switch m {
+	case PrivacyExact, PrivacyGrid, PrivacyOffset, PrivacyCity, PrivacyGhost:
+		return true
+	}
+	return false
+}
+
+// PrivacySettings are stored as json with the client
+type PrivacySettings struct {
+	Mode PrivacyMode `json:"mode"`
+	// Precision is the grid cell size or the maximum offset in meters
+	Precision float64 `json:"precision,omitempty"`
+	// Timezone is the IANA zone the schedule is evaluated in, UTC when empty
+	Timezone string            `json:"timezone,omitempty"`
+	Schedule []PrivacySchedule `json:"schedule,omitempty"`
+	Zones    []PrivacyZone     `json:"zones,omitempty"`
+}
+
+// PrivacySchedule overrides the mode between Start and End, a window ending before it starts wraps midnight
+type PrivacySchedule struct {
+	Mode  PrivacyMode    `json:"mode"`
+	Days  []time.Weekday `json:"days,omitempty"`
+	Start string         `json:"start"`
+	End   string         `json:"end"`
+}
+
+// PrivacyZone is an area, e.g. around the home of the player, in which positions are never stored at full precision
+type PrivacyZone struct {
+	Name string `json:"name"`
+	// Polygon is the ring of [longitude, latitude] pairs
+	Polygon [][2]float64 `json:"polygon"`
+}
+
+type AdminLocationPayload struct {
+	// Override returns the exact position, it requires a reason and is audited
+	Override bool
+	Reason   string
+	Actor    string
+}
+
+// LocationAuditStoreModel records an admin reading the exact position of a player
+type LocationAuditStoreModel struct {
+	ID        int64     `db:"id"`
+	ClientID  string    `db:"client_id"`
+	Actor     string    `db:"actor"`
+	Reason    string    `db:"reason"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Value enables serialization to SQL
+func (s PrivacySettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan enables deserialization from SQL
+func (s *PrivacySettings) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = PrivacySettings{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("unsupported privacy settings type %T", src)
+}
+
+func (s *PrivacySettings) Validate() error {
+	if s.Mode == "" {
+		s.Mode = PrivacyExact
+	}
+	if !s.Mode.Valid() {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidPrivacySettings, s.Mode)
+	}
+	if s.Precision != 0 && (s.Precision < minPrecision || s.Precision > maxPrecision) {
+		return fmt.Errorf("%w: precision must be between %d and %d meters", ErrInvalidPrivacySettings, minPrecision, maxPrecision)
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPrivacySettings, s.Timezone)
+	}
+	for _, sc := range s.Schedule {
+		if !sc.Mode.Valid() {
+			return fmt.Errorf("%w: unknown schedule mode %q", ErrInvalidPrivacySettings, sc.Mode)
+		}
+		if _, err := parseClock(sc.Start); err != nil {
+			return fmt.Errorf("%w: schedule start must be HH:MM", ErrInvalidPrivacySettings)
+		}
+		if _, err := parseClock(sc.End); err != nil {
+			return fmt.Errorf("%w: schedule end must be HH:MM", ErrInvalidPrivacySettings)
+		}
+	}
+	if len(s.Zones) > maxPrivacyZones {
+		return fmt.Errorf("%w: at most %d privacy zones", ErrInvalidPrivacySettings, maxPrivacyZones)
+	}
+	for i := range s.Zones {
+		ring := s.Zones[i].Polygon
+		if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+			s.Zones[i].Polygon = append(ring, ring[0])
+		}
+		if len(s.Zones[i].Polygon) < 4 {
+			return fmt.Errorf("%w: privacy zone %q needs at least 3 corners", ErrInvalidPrivacySettings, s.Zones[i].Name)
+		}
+	}
+	return nil
+}
+
+// ModeAt returns the mode in effect at the given time, the first matching schedule wins
+func (s *PrivacySettings) ModeAt(t time.Time) PrivacyMode {
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		t = t.In(loc)
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, sc := range s.Schedule {
+		start, err1 := parseClock(sc.Start)
+		end, err2 := parseClock(sc.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		day := t.Weekday()
+		inWindow := minute >= start && minute < end
+		if start > end {
+			inWindow = minute >= start || minute < end
+			if minute < end {
+				// the window started the day before
+				day = (day + 6) % 7
+			}
+		}
+		if inWindow && scheduledOn(sc.Days, day) {
+			return sc.Mode
+		}
+	}
+	if s.Mode == "" {
+		return PrivacyExact
+	}
+	return s.Mode
+}
+
+// zoneAt returns the privacy zone containing the point
+func (s *PrivacySettings) zoneAt(p orb.Point) (*PrivacyZone, orb.Ring) {
+	for i := range s.Zones {
+		ring := make(orb.Ring, 0, len(s.Zones[i].Polygon))
+		for _, c := range s.Zones[i].Polygon {
+			ring = append(ring, orb.Point{c[0], c[1]})
+		}
+		if planar.RingContains(ring, p) {
+			return &s.Zones[i], ring
+		}
+	}
+	return nil, nil
+}
+
+// protectPoint replaces a position inside a privacy zone by the centre of the zone
+func (s *PrivacySettings) protectPoint(p locations.Point) (locations.Point, bool) {
+	zone, ring := s.zoneAt(p.Point)
+	if zone == nil {
+		return p, false
+	}
+	c := ring.Bound().Center()
+	return locations.NewPoint(c.Lon(), c.Lat()), true
+}
+
+// exposeLocation applies the privacy settings of the owner to the last known position,
+// it returns false when the position must not be shown
+func exposeLocation(owner *ClientStoreModel, now time.Time) (*locations.Location, bool) {
+	if !owner.LocationUpdatedAt.Valid && owner.Point.Lat() == 0 && owner.Point.Lon() == 0 {
+		return nil, false
+	}
+	loc := &locations.Location{
+		ID: owner.LocationID.String,
+		GeoPoint: locations.GeoPoint{
+			Longitude: owner.Point.Lon(),
+			Latitude:  owner.Point.Lat(),
+		},
+		MetaData: locations.MetaData{
+			LocationName: owner.LocationName.String,
+			LocationType: owner.LocationType.String,
+		},
+	}
+	settings := owner.Privacy
+	mode := settings.ModeAt(now)
+	switch mode {
+	case PrivacyGhost:
+		return nil, false
+	case PrivacyExact:
+		return loc, true
+	case PrivacyGrid:
+		loc.GeoPoint = snapToGrid(loc.GeoPoint, precisionOr(settings.Precision, defaultGridPrecision))
+	case PrivacyOffset:
+		seed := fnv.New64a()
+		_, _ = seed.Write([]byte(owner.ID.String() + owner.LocationUpdatedAt.Time.UTC().String()))
+		loc.GeoPoint = randomOffset(loc.GeoPoint, precisionOr(settings.Precision, defaultOffsetPrecision), int64(seed.Sum64()))
+	case PrivacyCity:
+		loc.GeoPoint = snapToGrid(loc.GeoPoint, cityPrecision)
+	}
+	// the game location would reveal the exact position
+	cityName := ""
+	if mode == PrivacyCity && loc.MetaData.LocationType == locations.City.String() {
+		cityName = loc.MetaData.LocationName
+	}
+	loc.ID = ""
+	loc.MetaData = locations.MetaData{LocationName: cityName}
+	if cityName != "" {
+		loc.MetaData.LocationType = locations.City.String()
+	}
+	return loc, true
+}
+
+func (d *DefaultService) GetPrivacy(ctx context.Context, clientID string) (*PrivacySettings, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("GetPrivacy: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("GetPrivacy: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to get privacy:" + err.Error())
+	}
+	settings := client.Privacy
+	if settings.Mode == "" {
+		settings.Mode = PrivacyExact
+	}
+	return &settings, nil
+}
+
+func (d *DefaultService) UpdatePrivacy(ctx context.Context, payload PrivacySettings, clientID string) error {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("UpdatePrivacy: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return err
+	}
+	if err := payload.Validate(); err != nil {
+		return err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdatePrivacy(dbCtx, clientID, payload); err != nil {
+		d.logger.Error("UpdatePrivacy: failed to update privacy to db", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to update privacy:" + err.Error())
+	}
+	return nil
+}
+
+// AdminGetLocation applies the privacy settings of the player unless the support override is used,
+// every view is recorded in the moderation audit and every override also in the location access audit
+func (d *DefaultService) AdminGetLocation(ctx context.Context, payload AdminLocationPayload, clientID string) (*locations.Location, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("AdminGetLocation: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	if payload.Override && payload.Reason == "" {
+		return nil, ErrOverrideReasonRequired
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("AdminGetLocation: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return nil, ErrNotFound
+	}
+	detail := ""
+	if payload.Override {
+		detail = "override: " + payload.Reason
+	}
+	if err := d.audit(ctx, clientID, payload.Actor, AuditViewPosition, detail); err != nil {
+		return nil, err
+	}
+	if !payload.Override {
+		loc, ok := exposeLocation(client, time.Now())
+		if !ok {
+			return nil, ErrLocationHidden
+		}
+		return loc, nil
+	}
+
+	audit := LocationAuditStoreModel{
+		ClientID:  clientID,
+		Actor:     payload.Actor,
+		Reason:    payload.Reason,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.CreateLocationAudit(dbCtx, &audit); err != nil {
+		d.logger.Error("AdminGetLocation: failed to write location audit", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to audit location access:" + err.Error())
+	}
+	d.logger.Warn("AdminGetLocation: privacy override", zap.String("clientID", clientID), zap.String("actor", payload.Actor), zap.String("reason", payload.Reason))
+	exact := *client
+	exact.Privacy = PrivacySettings{Mode: PrivacyExact}
+	loc, ok := exposeLocation(&exact, time.Now())
+	if !ok {
+		return nil, ErrLocationHidden
+	}
+	return loc, nil
+}
+
+// snapToGrid returns the centre of the grid cell of the given size in meters
+func snapToGrid(g locations.GeoPoint, meters float64) locations.GeoPoint {
+	latStep := meters / metersPerDegree
+	lat := math.Floor(g.Latitude/latStep)*latStep + latStep/2
+	lonStep := meters / (metersPerDegree * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+	lon := math.Floor(g.Longitude/lonStep)*lonStep + lonStep/2
+	return locations.GeoPoint{Longitude: lon, Latitude: lat}
+}
+
+// randomOffset moves the point uniformly within the radius, the seed keeps the offset stable
+// so repeated reads of the same position can not be averaged out
+func randomOffset(g locations.GeoPoint, meters float64, seed int64) locations.GeoPoint {
+	r := rand.New(rand.NewSource(seed))
+	distance := meters * math.Sqrt(r.Float64())
+	bearing := 2 * math.Pi * r.Float64()
+	lat := g.Latitude + distance*math.Cos(bearing)/metersPerDegree
+	lon := g.Longitude + distance*math.Sin(bearing)/(metersPerDegree*math.Max(math.Cos(g.Latitude*math.Pi/180), 0.01))
+	return locations.GeoPoint{Longitude: lon, Latitude: lat}
+}
+
+func precisionOr(p, def float64) float64 {
+	if p == 0 {
+		return def
+	}
+	return p
+}
+
+func scheduledOn(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock returns the minutes since midnight of a HH:MM time
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
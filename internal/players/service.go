@@ -4,14 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 
 	"geogame/internal/locations"
+	"geogame/internal/lockout"
+	"geogame/internal/mailer"
 	"geogame/internal/middleware"
+	"geogame/internal/passwords"
+	"geogame/internal/totp"
+	"geogame/pkg"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailNotVerified         = errors.New("email address is not verified")
+	ErrInvalidCredentials       = errors.New("invalid email or password")
 )
 
 type Service interface {
@@ -20,79 +32,397 @@ type Service interface {
 	UpdateName(ctx context.Context, payload UpdatePayload, clientID string) error
 	UpdateLocation(ctx context.Context, payload locations.Location, clientID string) error
 	GetLocation(ctx context.Context, clientID string) (*locations.Location, error)
+	Verify(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, payload ResendVerificationPayload) error
+	CleanupUnverified(ctx context.Context) (int64, error)
+	Refresh(ctx context.Context, payload RefreshPayload) (*APIResponse, error)
+	Logout(ctx context.Context, sessionID string) error
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+	Unlock(ctx context.Context, payload UnlockPayload) error
+	RequestFriend(ctx context.Context, payload FriendPayload, clientID string) error
+	AcceptFriend(ctx context.Context, friendID, clientID string) error
+	DeclineFriend(ctx context.Context, friendID, clientID string) error
+	RemoveFriend(ctx context.Context, friendID, clientID string) error
+	BlockPlayer(ctx context.Context, friendID, clientID string) error
+	UnblockPlayer(ctx context.Context, friendID, clientID string) error
+	ListFriends(ctx context.Context, clientID string) ([]Friend, error)
+	FriendLocations(ctx context.Context, clientID string) ([]FriendLocation, error)
+	UpdateLocationSharing(ctx context.Context, payload SharingPayload, clientID string) error
+	GetPrivacy(ctx context.Context, clientID string) (*PrivacySettings, error)
+	UpdatePrivacy(ctx context.Context, payload PrivacySettings, clientID string) error
+	AdminGetLocation(ctx context.Context, payload AdminLocationPayload, clientID string) (*locations.Location, error)
+	LoginWithIdentity(ctx context.Context, identity ExternalIdentity) (*APIResponse, error)
+	VerifyTwoFactor(ctx context.Context, payload TwoFactorPayload) (*APIResponse, error)
+	EnrollTwoFactor(ctx context.Context, clientID string) (*totp.Enrollment, error)
+	ConfirmTwoFactor(ctx context.Context, payload TwoFactorCodePayload, clientID string) (*totp.RecoveryCodes, error)
+	DisableTwoFactor(ctx context.Context, payload TwoFactorCodePayload, clientID string) error
+	// ChangePassword returns the ids of the revoked sessions, the session changing the password stays active
+	ChangePassword(ctx context.Context, payload ChangePasswordPayload, clientID, sessionID string) ([]string, error)
+	ChangeEmail(ctx context.Context, payload ChangeEmailPayload, clientID string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	CreateGuest(ctx context.Context, payload GuestPayload) (*GuestResponse, error)
+	GuestLogin(ctx context.Context, payload GuestLoginPayload) (*APIResponse, error)
+	Upgrade(ctx context.Context, payload UpgradePayload, clientID string) error
+	UpgradeWithIdentity(ctx context.Context, clientID string, identity ExternalIdentity) (*APIResponse, error)
+	CleanupGuests(ctx context.Context) (int64, error)
+	GetHandle(ctx context.Context, clientID string) (*HandleResponse, error)
+	ChangeHandle(ctx context.Context, payload HandlePayload, clientID string) (*HandleResponse, error)
+	PublicProfile(ctx context.Context, handle, viewerID string) (*PublicProfile, error)
+	SearchPlayers(ctx context.Context, query, actor string) ([]PlayerSummary, error)
+	AdminGetPlayer(ctx context.Context, clientID, actor string) (*AdminPlayer, error)
+	SuspendPlayer(ctx context.Context, payload SuspendPayload, clientID string) error
+	// BanPlayer and ForceLogout return the ids of the revoked sessions
+	BanPlayer(ctx context.Context, payload ModerationPayload, clientID string) ([]string, error)
+	ReinstatePlayer(ctx context.Context, payload ModerationPayload, clientID string) error
+	ForceLogout(ctx context.Context, clientID, actor string) ([]string, error)
+	AddNote(ctx context.Context, payload NotePayload, clientID string) (*Note, error)
+	ListAudit(ctx context.Context, clientID string) ([]AuditEntry, error)
+	IsRestricted(ctx context.Context, clientID string) (bool, error)
+}
+
+// VerificationConfig controls the email verification flow
+type VerificationConfig struct {
+	// VerifyURL is the link sent to the player, the token is appended as query param
+	VerifyURL string `env:"VERIFY_URL" envDefault:"http://localhost:8080/v1/client/verify"`
+	// EmailChangeURL is the link sent to the new address of an email change
+	EmailChangeURL string `env:"EMAIL_CHANGE_URL" envDefault:"http://localhost:8080/v1/client/email/verify"`
+	// TokenTTL is how long a verification token stays valid
+	TokenTTL time.Duration `env:"VERIFY_TOKEN_TTL" envDefault:"24h"`
+	// ResendInterval is the minimum time between two verification emails
+	ResendInterval time.Duration `env:"VERIFY_RESEND_INTERVAL" envDefault:"5m"`
+	// AllowUnverifiedLogin lets unverified players login with limited scopes
+	AllowUnverifiedLogin bool `env:"ALLOW_UNVERIFIED_LOGIN" envDefault:"false"`
+	// MaxUnverifiedAge is the age after which unverified accounts are removed
+	MaxUnverifiedAge time.Duration `env:"UNVERIFIED_MAX_AGE" envDefault:"168h"`
+	// CleanupInterval is how often the unverified accounts cleanup runs
+	CleanupInterval time.Duration `env:"UNVERIFIED_CLEANUP_INTERVAL" envDefault:"1h"`
+}
+
+// SessionConfig controls the refresh token lifetime
+type SessionConfig struct {
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" envDefault:"720h"`
+	// RevocationCacheTTL is how long an active session is trusted before it is checked again
+	RevocationCacheTTL time.Duration `env:"REVOCATION_CACHE_TTL" envDefault:"30s"`
+	// StandingCacheTTL is how long a player is trusted not to be suspended or banned before it is checked again
+	StandingCacheTTL time.Duration `env:"STANDING_CACHE_TTL" envDefault:"30s"`
+}
+
+// GuestConfig controls the removal of the abandoned guest accounts
+type GuestConfig struct {
+	// MaxInactiveAge is the time without login, refresh or position after which a guest is removed
+	MaxInactiveAge  time.Duration `env:"GUEST_MAX_INACTIVE_AGE" envDefault:"720h"`
+	CleanupInterval time.Duration `env:"GUEST_CLEANUP_INTERVAL" envDefault:"1h"`
+}
+
+// LocationListener is notified in-process after every stored position
+type LocationListener interface {
+	OnLocationUpdate(ctx context.Context, update LocationUpdate) error
+}
+
+type Option func(*DefaultService)
+
+func WithMailer(m mailer.Mailer) Option {
+	return func(d *DefaultService) {
+		d.mailer = m
+	}
+}
+
+func WithVerificationConfig(c VerificationConfig) Option {
+	return func(d *DefaultService) {
+		d.verification = c
+	}
+}
+
+// WithTokenIssuer sets the key ring signing the access tokens, it must be the one verifying them
+func WithTokenIssuer(issuer middleware.TokenIssuer) Option {
+	return func(d *DefaultService) {
+		d.issuer = issuer
+	}
+}
+
+// WithLockout sets the failed login tracking, it defaults to an in-memory one
+func WithLockout(l lockout.Service) Option {
+	return func(d *DefaultService) {
+		d.lockout = l
+	}
+}
+
+// WithTwoFactor sets the second factor store, it defaults to an in-memory one
+func WithTwoFactor(t totp.Service) Option {
+	return func(d *DefaultService) {
+		d.twoFactor = t
+	}
+}
+
+// WithHasher sets the hasher of the new passwords, the stored hashes of other parameters are upgraded at login
+func WithHasher(h passwords.Hasher) Option {
+	return func(d *DefaultService) {
+		d.hasher = h
+	}
+}
+
+// WithPasswordPolicy sets the rules of the new passwords
+func WithPasswordPolicy(c passwords.PolicyConfig) Option {
+	return func(d *DefaultService) {
+		d.policy = passwords.NewPolicy(c)
+	}
+}
+
+func WithSessionConfig(c SessionConfig) Option {
+	return func(d *DefaultService) {
+		d.session = c
+	}
+}
+
+func WithGuestConfig(c GuestConfig) Option {
+	return func(d *DefaultService) {
+		d.guests = c
+	}
+}
+
+func WithHandleConfig(c HandleConfig) Option {
+	return func(d *DefaultService) {
+		d.handles = c
+	}
+}
+
+// WithLocationListener registers a listener notified after every position sent by a player
+func WithLocationListener(l LocationListener) Option {
+	return func(d *DefaultService) {
+		d.locationListeners = append(d.locationListeners, l)
+	}
 }
 
 var _ Service = (*DefaultService)(nil)
 
 type DefaultService struct {
-	logger      *zap.Logger
-	store       Store
-	dbTimeOut   time.Duration
-	tokenSecret string
+	logger       *zap.Logger
+	store        Store
+	dbTimeOut    time.Duration
+	tokenSecret  string
+	mailer       mailer.Mailer
+	verification VerificationConfig
+	session      SessionConfig
+	guests       GuestConfig
+	handles      HandleConfig
+	issuer       middleware.TokenIssuer
+	lockout      lockout.Service
+	twoFactor    totp.Service
+	hasher       passwords.Hasher
+	policy       *passwords.Policy
+
+	// dummyHash is verified when the email is unknown so both cases take the same time
+	dummyOnce sync.Once
+	dummyHash string
+
+	locationListeners []LocationListener
 }
 
-func NewDefaultService(logger *zap.Logger, store Store, dbTimeOut time.Duration, tokenSecret string) *DefaultService {
-	return &DefaultService{
+func NewDefaultService(logger *zap.Logger, store Store, dbTimeOut time.Duration, tokenSecret string, options ...Option) *DefaultService {
+	d := &DefaultService{
 		logger:      logger,
 		store:       store,
 		dbTimeOut:   dbTimeOut,
 		tokenSecret: tokenSecret,
+		mailer:      mailer.NewLogMailer(logger),
+		issuer:      middleware.NewJwtKey(tokenSecret),
+		lockout:     lockout.NewDefaultService(logger, lockout.NewMemStore(), lockout.DefaultConfig(), dbTimeOut),
+		twoFactor:   totp.NewDefaultService(logger, totp.NewMemStore(), totp.DefaultConfig(), dbTimeOut),
+		hasher:      passwords.NewDefaultHasher(),
+		policy:      passwords.NewPolicy(passwords.DefaultPolicyConfig()),
+		verification: VerificationConfig{
+			VerifyURL:        "http://localhost:8080/v1/client/verify",
+			EmailChangeURL:   "http://localhost:8080/v1/client/email/verify",
+			TokenTTL:         time.Hour * 24,
+			ResendInterval:   time.Minute * 5,
+			MaxUnverifiedAge: time.Hour * 24 * 7,
+			CleanupInterval:  time.Hour,
+		},
+		session: SessionConfig{
+			RefreshTokenTTL:    time.Hour * 24 * 30,
+			RevocationCacheTTL: time.Second * 30,
+			StandingCacheTTL:   time.Second * 30,
+		},
+		guests: GuestConfig{
+			MaxInactiveAge:  time.Hour * 24 * 30,
+			CleanupInterval: time.Hour,
+		},
+		handles: HandleConfig{
+			ChangeCooldown: time.Hour * 24 * 30,
+			HoldPeriod:     time.Hour * 24 * 90,
+		},
+	}
+	for _, opt := range options {
+		opt(d)
 	}
+	return d
 }
 
 func (d *DefaultService) Register(ctx context.Context, payload RegisterPayload) error {
 	d.logger.Named("Register").With(zap.String("email", payload.Email))
+	if err := d.policy.Validate(payload.Password, payload.Email, payload.Name); err != nil {
+		return err
+	}
+	// unique clientID
+	clientID := uuid.New()
+	handle := strings.TrimSpace(payload.Handle)
+	var handleChangedAt sql.NullTime
+	if handle == "" {
+		handle = generatedHandle(playerHandlePrefix, clientID)
+	} else {
+		if err := validateHandle(handle); err != nil {
+			return err
+		}
+		if err := d.checkHandleFree(ctx, handle, clientID); err != nil {
+			return err
+		}
+		handleChangedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
 	// generate hash from password
-	hash, err := bcrypt.GenerateFromPassword([]byte(payload.Password), 10)
+	hash, err := d.hasher.Hash(payload.Password)
 	if err != nil {
 		d.logger.Error("Register: failed to generate hash from password", zap.String("email", payload.Email), zap.Error(err))
 		return err
 	}
 
-	// unique clientID
-	clientID := uuid.New()
-
 	client := ClientStoreModel{
-		ID:           clientID,
-		Name:         payload.Name,
-		Email:        payload.Email,
-		Password:     string(hash),
-		LocationID:   toNullString(""),
-		LocationName: toNullString(""),
-		LocationType: toNullString(""),
+		ID:              clientID,
+		Name:            payload.Name,
+		Handle:          handle,
+		HandleChangedAt: handleChangedAt,
+		Email:           payload.Email,
+		Password:        hash,
+		LocationID:      toNullString(""),
+		LocationName:    toNullString(""),
+		LocationType:    toNullString(""),
+		Status:          StatusUnverified,
+		CreatedAt:       time.Now().UTC(),
+		LocationSharing: SharingFriends,
 	}
 	if err := d.store.CreateClient(ctx, &client); err != nil {
+		if err == ErrHandleTaken {
+			return err
+		}
 		d.logger.Error("Register: failed to create client", zap.String("email", payload.Email), zap.Error(err))
 		return errors.New("failed to create client:" + err.Error())
 	}
 	d.logger.Info("client store model : ", zap.Any("client", client))
+	if err := d.sendVerification(ctx, &client); err != nil {
+		d.logger.Error("Register: failed to send verification", zap.String("email", payload.Email), zap.Error(err))
+		return errors.New("failed to send verification:" + err.Error())
+	}
 	return nil
 }
 
 func (d *DefaultService) Login(ctx context.Context, payload LoginPayload) (*APIResponse, error) {
 	d.logger.Named("Login").With(zap.String("email", payload.Email))
+	keys := []string{lockout.EmailKey(payload.Email)}
+	if payload.RemoteIP != "" {
+		keys = append(keys, lockout.IPKey(payload.RemoteIP))
+	}
+	if err := d.lockout.Check(ctx, keys...); err != nil {
+		d.logger.Warn("Login: login attempt throttled", zap.String("email", payload.Email), zap.String("ip", payload.RemoteIP), zap.Error(err))
+		return nil, err
+	}
+
 	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
 	defer cancel()
 	client, err := d.store.GetClientByEmail(dbCtx, payload.Email)
 	if err != nil {
 		d.logger.Error("Login: failed to get client from db", zap.String("email", payload.Email), zap.Error(err))
+		_, _ = d.hasher.Verify(d.dummy(), payload.Password)
+		return nil, d.loginFailed(ctx, keys)
+	}
+	if err := d.checkPassword(ctx, client, payload.Password); err != nil {
+		d.logger.Error("Login: failed to verify the password", zap.String("email", payload.Email), zap.Error(err))
+		return nil, d.loginFailed(ctx, keys)
+	}
+	twoFactor, err := d.twoFactor.Enabled(ctx, totp.ClientKey(client.ID.String()))
+	if err != nil {
+		d.logger.Error("Login: failed to check the second factor", zap.String("email", payload.Email), zap.Error(err))
 		return nil, errors.New("failed to login:" + err.Error())
 	}
-	// un-hash the hashed password by using password
-	if err := bcrypt.CompareHashAndPassword([]byte(client.Password), []byte(payload.Password)); err != nil {
-		d.logger.Error("Login: failed to un-hash the password", zap.String("email", payload.Email), zap.Error(err))
+	// only the email counter is reset, the per-IP counter decays with the attempt window,
+	// with a second factor it is reset once the code is verified
+	if !twoFactor {
+		if err := d.lockout.Reset(ctx, keys[0]); err != nil {
+			d.logger.Error("Login: failed to reset failed attempts", zap.String("email", payload.Email), zap.Error(err))
+		}
+	}
+
+	if client.Status == StatusUnverified && !d.verification.AllowUnverifiedLogin {
+		d.logger.Error("Login: client is not verified", zap.String("email", payload.Email))
+		return nil, ErrEmailNotVerified
+	}
+	if err := d.checkStanding(client); err != nil {
 		return nil, err
 	}
+	if twoFactor {
+		return d.newChallenge(ctx, client)
+	}
 
-	// Generate jwt access token using token secret
-	key := middleware.NewJwtKey(d.tokenSecret)
-	token, err := key.GenerateToken(client.ID.String())
+	res, err := d.startSession(ctx, client)
 	if err != nil {
-		d.logger.Error("Login: failed to generate token", zap.String("email", payload.Email), zap.Error(err))
+		d.logger.Error("Login: failed to start session", zap.String("email", payload.Email), zap.Error(err))
 		return nil, err
 	}
-	return &APIResponse{Token: token}, nil
+	return res, nil
+}
+
+// checkPassword verifies the password of the client, a hash of outdated parameters is replaced
+// by one of the current hasher, a failed upgrade is retried at the next login
+func (d *DefaultService) checkPassword(ctx context.Context, client *ClientStoreModel, password string) error {
+	rehash, err := d.hasher.Verify(client.Password, password)
+	if err != nil {
+		return err
+	}
+	if !rehash {
+		return nil
+	}
+	hash, err := d.hasher.Hash(password)
+	if err != nil {
+		d.logger.Error("checkPassword: failed to rehash the password", zap.String("clientID", client.ID.String()), zap.Error(err))
+		return nil
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.UpdatePassword(dbCtx, client.ID.String(), hash); err != nil {
+		d.logger.Error("checkPassword: failed to store the rehashed password", zap.String("clientID", client.ID.String()), zap.Error(err))
+		return nil
+	}
+	client.Password = hash
+	return nil
+}
 
+func (d *DefaultService) dummy() string {
+	d.dummyOnce.Do(func() {
+		d.dummyHash, _ = d.hasher.Hash("geogame-dummy-password")
+	})
+	return d.dummyHash
+}
+
+func (d *DefaultService) loginFailed(ctx context.Context, keys []string) error {
+	if err := d.lockout.Fail(ctx, keys...); err != nil {
+		d.logger.Error("Login: failed to record failed attempt", zap.Error(err))
+	}
+	return ErrInvalidCredentials
+}
+
+// Unlock clears the failed attempts of an email and/or an IP address
+func (d *DefaultService) Unlock(ctx context.Context, payload UnlockPayload) error {
+	var keys []string
+	if payload.Email != "" {
+		keys = append(keys, lockout.EmailKey(payload.Email))
+	}
+	if payload.IP != "" {
+		keys = append(keys, lockout.IPKey(payload.IP))
+	}
+	if err := d.lockout.Reset(ctx, keys...); err != nil {
+		d.logger.Error("Unlock: failed to reset failed attempts", zap.String("email", payload.Email), zap.String("ip", payload.IP), zap.Error(err))
+		return errors.New("failed to unlock:" + err.Error())
+	}
+	d.logger.Info("Unlock: failed attempts cleared", zap.String("email", payload.Email), zap.String("ip", payload.IP))
+	return nil
 }
 
 func (d *DefaultService) UpdateName(ctx context.Context, payload UpdatePayload, clientID string) error {
@@ -127,11 +457,47 @@ func (d *DefaultService) UpdateLocation(ctx context.Context, payload locations.L
 
 	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
 	defer cancel()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("UpdateLocation: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to update location:" + err.Error())
+	}
+	// positions inside a privacy zone are never stored at full precision
+	if protected, ok := client.Privacy.protectPoint(point.Point); ok {
+		point = locations.LocationStoreModel{Point: protected}
+	}
+	// the listeners get the previous position, it is copied before the store changes it
+	current := *client
 	if err := d.store.UpdateLocation(dbCtx, clientID, point); err != nil {
 		d.logger.Error("UpdateLocation: failed to update location to db", zap.String("clientID", clientID), zap.Error(err))
 		return errors.New("failed to update location:" + err.Error())
 	}
 
+	update := LocationUpdate{
+		ClientID: clientID,
+		Handle:   current.Handle,
+		GeoPoint: locations.GeoPoint{Longitude: point.Point.Lon(), Latitude: point.Point.Lat()},
+		At:       time.Now().UTC(),
+	}
+	if current.LocationUpdatedAt.Valid {
+		update.Previous = &locations.GeoPoint{Longitude: current.Point.Lon(), Latitude: current.Point.Lat()}
+		update.PreviousAt = current.LocationUpdatedAt.Time
+	}
+	current.Point = point.Point
+	current.LocationID = sql.NullString{String: point.ID, Valid: point.ID != ""}
+	current.LocationName = sql.NullString{String: point.LocationName, Valid: point.LocationName != ""}
+	current.LocationType = sql.NullString{String: string(point.LocationType), Valid: point.LocationType != ""}
+	current.LocationUpdatedAt = sql.NullTime{Time: update.At, Valid: true}
+	if exposed, ok := exposeLocation(&current, update.At); ok {
+		update.Exposed = exposed
+		update.Mode = current.Privacy.ModeAt(update.At)
+	}
+	for _, l := range d.locationListeners {
+		// the position is stored, a failing listener must not fail the request
+		if err := l.OnLocationUpdate(ctx, update); err != nil {
+			d.logger.Error("UpdateLocation: listener failed", zap.String("clientID", clientID), zap.Error(err))
+		}
+	}
 	return nil
 }
 
@@ -162,6 +528,98 @@ func (d *DefaultService) GetLocation(ctx context.Context, clientID string) (*loc
 	return loc, nil
 }
 
+func (d *DefaultService) Verify(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidVerificationToken
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	v, err := d.store.GetVerificationByTokenHash(dbCtx, pkg.HashToken(token))
+	if err != nil {
+		if err == ErrNotFound {
+			return ErrInvalidVerificationToken
+		}
+		d.logger.Error("Verify: failed to get verification from db", zap.Error(err))
+		return errors.New("failed to verify:" + err.Error())
+	}
+	if time.Now().After(v.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+	clientID := v.ClientID.String()
+	if err := d.store.UpdateStatus(dbCtx, clientID, StatusVerified); err != nil {
+		d.logger.Error("Verify: failed to update status to db", zap.String("clientID", clientID), zap.Error(err))
+		return errors.New("failed to verify:" + err.Error())
+	}
+	if err := d.store.DeleteVerification(dbCtx, clientID); err != nil {
+		d.logger.Error("Verify: failed to delete verification from db", zap.String("clientID", clientID), zap.Error(err))
+	}
+	return nil
+}
+
+// ResendVerification does not reveal whether the email is registered or already verified,
+// a throttled resend is only logged for the same reason
+func (d *DefaultService) ResendVerification(ctx context.Context, payload ResendVerificationPayload) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByEmail(dbCtx, payload.Email)
+	if err != nil || client.Status != StatusUnverified {
+		return nil
+	}
+	v, err := d.store.GetVerificationByClientID(dbCtx, client.ID.String())
+	if err != nil && err != ErrNotFound {
+		d.logger.Error("ResendVerification: failed to get verification from db", zap.String("email", payload.Email), zap.Error(err))
+		return errors.New("failed to resend verification:" + err.Error())
+	}
+	if v != nil && time.Since(v.SentAt) < d.verification.ResendInterval {
+		d.logger.Warn("ResendVerification: verification email was sent recently", zap.String("email", payload.Email))
+		return nil
+	}
+	if err := d.sendVerification(ctx, client); err != nil {
+		d.logger.Error("ResendVerification: failed to send verification", zap.String("email", payload.Email), zap.Error(err))
+		return errors.New("failed to resend verification:" + err.Error())
+	}
+	return nil
+}
+
+func (d *DefaultService) CleanupUnverified(ctx context.Context) (int64, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	deleted, err := d.store.DeleteUnverifiedClients(dbCtx, time.Now().Add(-d.verification.MaxUnverifiedAge))
+	if err != nil {
+		d.logger.Error("CleanupUnverified: failed to delete unverified clients", zap.Error(err))
+		return 0, err
+	}
+	if deleted > 0 {
+		d.logger.Info("CleanupUnverified: deleted unverified clients", zap.Int64("count", deleted))
+	}
+	return deleted, nil
+}
+
+func (d *DefaultService) sendVerification(ctx context.Context, client *ClientStoreModel) error {
+	token, err := pkg.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	v := VerificationStoreModel{
+		ClientID:  client.ID,
+		TokenHash: pkg.HashToken(token),
+		SentAt:    now,
+		ExpiresAt: now.Add(d.verification.TokenTTL),
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.SaveVerification(dbCtx, &v); err != nil {
+		return err
+	}
+	msg := mailer.Message{
+		To:      client.Email,
+		Subject: "Verify your geogame account",
+		Body:    "Hi " + client.Name + ",\n\nplease verify your email address by opening " + d.verification.VerifyURL + "?token=" + token,
+	}
+	return d.mailer.Send(ctx, msg)
+}
+
 func toNullString(s string) sql.NullString {
 	return sql.NullString{
 		String: s,
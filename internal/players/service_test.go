@@ -2,17 +2,28 @@ package players
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
 
 	"github.com/stretchr/testify/assert"
 
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 
 	"geogame/internal/locations"
+	"geogame/internal/lockout"
+	"geogame/internal/mailer"
+	"geogame/internal/middleware"
+	"geogame/internal/passwords"
+	"geogame/internal/totp"
+	"geogame/pkg"
 )
 
 func TestDefaultService_Register(t *testing.T) {
@@ -40,6 +51,9 @@ func TestDefaultService_Register(t *testing.T) {
 				store:       NewMemStore(make(map[interface{}]*ClientStoreModel)),
 				dbTimeOut:   time.Second * 10,
 				tokenSecret: "",
+				mailer:      mailer.NewMockMailer(),
+				hasher:      passwords.NewDefaultHasher(),
+				policy:      passwords.NewPolicy(passwords.DefaultPolicyConfig()),
 			}
 			err := d.Register(context.TODO(), tt.payload)
 			assert.Nil(t, err)
@@ -63,7 +77,7 @@ func TestDefaultService_Login(t *testing.T) {
 				Password: "dummypassword",
 			},
 			wantErr: true,
-			err:     errors.New("crypto/bcrypt: hashedSecret too short to be a bcrypted password"),
+			err:     ErrInvalidCredentials,
 		},
 		// {
 		// 	name: "success",
@@ -93,6 +107,8 @@ func TestDefaultService_Login(t *testing.T) {
 				store:       m,
 				dbTimeOut:   time.Second * 10,
 				tokenSecret: "",
+				lockout:     lockout.NewDefaultService(zap.NewNop(), lockout.NewMockStore(), lockout.DefaultConfig(), time.Second),
+				hasher:      passwords.NewDefaultHasher(),
 			}
 			got, err := d.Login(context.TODO(), tt.payload)
 			if tt.wantErr {
@@ -198,6 +214,9 @@ func TestDefaultService_UpdateLocation(t *testing.T) {
 					UpdateLocationFunc: func(clientID string, point locations.LocationStoreModel) error {
 						return nil
 					},
+					GetClientByIDFunc: func(id string) (*ClientStoreModel, error) {
+						return &ClientStoreModel{}, nil
+					},
 				},
 				dbTimeOut:   time.Second * 10,
 				tokenSecret: "",
@@ -208,3 +227,978 @@ func TestDefaultService_UpdateLocation(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultService_Verify(t *testing.T) {
+	clientID := uuid.New()
+	tests := []struct {
+		name         string
+		token        string
+		verification *VerificationStoreModel
+		want         error
+		wantStatus   AccountStatus
+	}{
+		{
+			name:  "success",
+			token: "token",
+			verification: &VerificationStoreModel{
+				ClientID:  clientID,
+				TokenHash: pkg.HashToken("token"),
+				SentAt:    time.Now(),
+				ExpiresAt: time.Now().Add(time.Hour),
+			},
+			want:       nil,
+			wantStatus: StatusVerified,
+		},
+		{
+			name:  "expired token",
+			token: "token",
+			verification: &VerificationStoreModel{
+				ClientID:  clientID,
+				TokenHash: pkg.HashToken("token"),
+				SentAt:    time.Now().Add(-time.Hour * 2),
+				ExpiresAt: time.Now().Add(-time.Hour),
+			},
+			want:       ErrInvalidVerificationToken,
+			wantStatus: StatusUnverified,
+		},
+		{
+			name:       "unknown token",
+			token:      "unknown",
+			want:       ErrInvalidVerificationToken,
+			wantStatus: StatusUnverified,
+		},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+			client := &ClientStoreModel{ID: clientID, Email: "dummy@mail.com", Status: StatusUnverified}
+			assert.Nil(t, store.CreateClient(context.TODO(), client))
+			if tt.verification != nil {
+				assert.Nil(t, store.SaveVerification(context.TODO(), tt.verification))
+			}
+			d := NewDefaultService(zap.NewNop(), store, time.Second*10, "", WithMailer(mailer.NewMockMailer()))
+			err := d.Verify(context.TODO(), tt.token)
+			assert.Equal(t, tt.want, err)
+			assert.Equal(t, tt.wantStatus, client.Status)
+		})
+	}
+}
+
+func TestDefaultService_ResendVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		sentAt    time.Time
+		want      error
+		wantSends int
+	}{
+		{
+			name:      "success",
+			sentAt:    time.Now().Add(-time.Hour),
+			want:      nil,
+			wantSends: 1,
+		},
+		{
+			name:      "throttled",
+			sentAt:    time.Now(),
+			want:      nil,
+			wantSends: 0,
+		},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+			client := &ClientStoreModel{ID: uuid.New(), Email: "dummy@mail.com", Status: StatusUnverified}
+			assert.Nil(t, store.CreateClient(context.TODO(), client))
+			assert.Nil(t, store.SaveVerification(context.TODO(), &VerificationStoreModel{
+				ClientID: client.ID,
+				SentAt:   tt.sentAt,
+			}))
+			sends := 0
+			m := &mailer.MockMailer{
+				SendFunc: func(msg mailer.Message) error {
+					sends++
+					return nil
+				},
+			}
+			d := NewDefaultService(zap.NewNop(), store, time.Second*10, "", WithMailer(m))
+			err := d.ResendVerification(context.TODO(), ResendVerificationPayload{Email: client.Email})
+			assert.Equal(t, tt.want, err)
+			assert.Equal(t, tt.wantSends, sends)
+		})
+	}
+}
+
+func TestDefaultService_Login_Unverified(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummypassword"), bcrypt.MinCost)
+	assert.Nil(t, err)
+	m := NewMockStore()
+	m.GetClientByEmailFunc = func(emailID string) (model *ClientStoreModel, e error) {
+		return &ClientStoreModel{
+			ID:       uuid.New(),
+			Email:    "dummy@mail.com",
+			Password: string(hash),
+			Status:   StatusUnverified,
+		}, nil
+	}
+	payload := LoginPayload{Email: "dummy@mail.com", Password: "dummypassword"}
+
+	d := NewDefaultService(zap.NewNop(), m, time.Second*10, "")
+	_, err = d.Login(context.TODO(), payload)
+	assert.Equal(t, ErrEmailNotVerified, err)
+
+	d = NewDefaultService(zap.NewNop(), m, time.Second*10, "", WithVerificationConfig(VerificationConfig{AllowUnverifiedLogin: true}))
+	res, err := d.Login(context.TODO(), payload)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+}
+
+func TestDefaultService_Refresh(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummypassword"), bcrypt.MinCost)
+	assert.Nil(t, err)
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	client := &ClientStoreModel{ID: uuid.New(), Email: "dummy@mail.com", Password: string(hash), Status: StatusVerified}
+	assert.Nil(t, store.CreateClient(context.TODO(), client))
+	d := NewDefaultService(zap.NewNop(), store, time.Second*10, "")
+
+	login, err := d.Login(context.TODO(), LoginPayload{Email: client.Email, Password: "dummypassword"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, login.RefreshToken)
+
+	// rotation issues a new refresh token
+	refreshed, err := d.Refresh(context.TODO(), RefreshPayload{RefreshToken: login.RefreshToken})
+	assert.Nil(t, err)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	// presenting the rotated token again kills the whole family
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: login.RefreshToken})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: "unknown"})
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+}
+
+func TestDefaultService_Logout(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second*10, "")
+	session := &SessionStoreModel{ID: uuid.New(), ClientID: uuid.New(), FamilyID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+	assert.Nil(t, store.CreateSession(context.TODO(), session))
+
+	revoked, err := d.IsRevoked(context.TODO(), session.ID.String())
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	assert.Nil(t, d.Logout(context.TODO(), session.ID.String()))
+	revoked, err = d.IsRevoked(context.TODO(), session.ID.String())
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = d.IsRevoked(context.TODO(), "0")
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+}
+
+func TestDefaultService_Login_Lockout(t *testing.T) {
+	config := lockout.DefaultConfig()
+	config.EmailFreeAttempts = 1
+	limiter := lockout.NewDefaultService(zap.NewNop(), lockout.NewMemStore(), config, time.Second)
+	d := NewDefaultService(zap.NewNop(), NewMemStore(make(map[interface{}]*ClientStoreModel)), time.Second*10, "", WithLockout(limiter))
+	payload := LoginPayload{Email: "unknown@mail.com", Password: "wrong", RemoteIP: "127.0.0.1"}
+
+	_, err := d.Login(context.TODO(), payload)
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = d.Login(context.TODO(), payload)
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = d.Login(context.TODO(), payload)
+	_, ok := err.(*lockout.LockedError)
+	assert.True(t, ok)
+
+	assert.Nil(t, d.Unlock(context.TODO(), UnlockPayload{Email: payload.Email}))
+	_, err = d.Login(context.TODO(), payload)
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestDefaultService_Friends(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	alice := &ClientStoreModel{ID: uuid.New(), Name: "alice", Handle: "alice", Email: "alice@mail.com", Status: StatusVerified}
+	bob := &ClientStoreModel{ID: uuid.New(), Name: "bob", Handle: "bob", Email: "bob@mail.com", Status: StatusVerified}
+	assert.Nil(t, store.CreateClient(context.TODO(), alice))
+	assert.Nil(t, store.CreateClient(context.TODO(), bob))
+	aliceID, bobID := alice.ID.String(), bob.ID.String()
+	d := NewDefaultService(zap.NewNop(), store, time.Second*10, "")
+	loc := locations.Location{ID: "1", GeoPoint: locations.GeoPoint{Longitude: 13.4, Latitude: 52.5}}
+	assert.Nil(t, d.UpdateLocation(context.TODO(), loc, bobID))
+
+	assert.Equal(t, ErrInvalidFriend, d.RequestFriend(context.TODO(), FriendPayload{FriendID: aliceID}, aliceID))
+	assert.Equal(t, ErrFriendNotFound, d.RequestFriend(context.TODO(), FriendPayload{FriendID: uuid.New().String()}, aliceID))
+	assert.Nil(t, d.RequestFriend(context.TODO(), FriendPayload{FriendID: bobID}, aliceID))
+	assert.Equal(t, ErrAlreadyFriends, d.RequestFriend(context.TODO(), FriendPayload{FriendID: bobID}, aliceID))
+
+	friends, err := d.ListFriends(context.TODO(), bobID)
+	assert.Nil(t, err)
+	assert.Equal(t, []Friend{{ID: aliceID, Handle: "alice", State: FriendStateIncoming, Since: friends[0].Since}}, friends)
+
+	// pending requests do not share locations
+	res, err := d.FriendLocations(context.TODO(), aliceID)
+	assert.Nil(t, err)
+	assert.Empty(t, res)
+
+	assert.Equal(t, ErrFriendRequestNotFound, d.AcceptFriend(context.TODO(), bobID, aliceID))
+	assert.Nil(t, d.AcceptFriend(context.TODO(), aliceID, bobID))
+	res, err = d.FriendLocations(context.TODO(), aliceID)
+	assert.Nil(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, loc.GeoPoint, res[0].Location.GeoPoint)
+
+	assert.Nil(t, d.UpdateLocationSharing(context.TODO(), SharingPayload{Sharing: SharingNobody}, bobID))
+	res, err = d.FriendLocations(context.TODO(), aliceID)
+	assert.Nil(t, err)
+	assert.Empty(t, res)
+	assert.Equal(t, ErrInvalidLocationSharing, d.UpdateLocationSharing(context.TODO(), SharingPayload{Sharing: "everyone"}, bobID))
+
+	// blocking ends the friendship, requests of the blocked player are dropped silently
+	assert.Nil(t, d.BlockPlayer(context.TODO(), aliceID, bobID))
+	friends, err = d.ListFriends(context.TODO(), aliceID)
+	assert.Nil(t, err)
+	assert.Empty(t, friends)
+	assert.Nil(t, d.RequestFriend(context.TODO(), FriendPayload{FriendID: bobID}, aliceID))
+	friends, err = d.ListFriends(context.TODO(), bobID)
+	assert.Nil(t, err)
+	assert.Empty(t, friends)
+	assert.Equal(t, ErrPlayerBlocked, d.RequestFriend(context.TODO(), FriendPayload{FriendID: aliceID}, bobID))
+
+	assert.Nil(t, d.UnblockPlayer(context.TODO(), aliceID, bobID))
+	assert.Nil(t, d.RequestFriend(context.TODO(), FriendPayload{FriendID: aliceID}, bobID))
+	// a request to a player who already asked accepts the pending request
+	assert.Nil(t, d.RequestFriend(context.TODO(), FriendPayload{FriendID: bobID}, aliceID))
+	friends, err = d.ListFriends(context.TODO(), aliceID)
+	assert.Nil(t, err)
+	assert.Equal(t, FriendStateAccepted, friends[0].State)
+
+	assert.Nil(t, d.RemoveFriend(context.TODO(), bobID, aliceID))
+	friends, err = d.ListFriends(context.TODO(), aliceID)
+	assert.Nil(t, err)
+	assert.Empty(t, friends)
+}
+
+func TestPrivacySettings_ModeAt(t *testing.T) {
+	settings := PrivacySettings{
+		Mode: PrivacyGrid,
+		Schedule: []PrivacySchedule{
+			{Mode: PrivacyGhost, Start: "22:00", End: "07:00", Days: []time.Weekday{time.Friday}},
+		},
+	}
+	assert.Nil(t, settings.Validate())
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want PrivacyMode
+	}{
+		{name: "outside the window", at: time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC), want: PrivacyGrid},
+		{name: "friday night", at: time.Date(2020, 5, 1, 23, 0, 0, 0, time.UTC), want: PrivacyGhost},
+		{name: "window started on friday", at: time.Date(2020, 5, 2, 6, 0, 0, 0, time.UTC), want: PrivacyGhost},
+		{name: "saturday night", at: time.Date(2020, 5, 2, 23, 0, 0, 0, time.UTC), want: PrivacyGrid},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, settings.ModeAt(tt.at))
+		})
+	}
+}
+
+func TestExposeLocation(t *testing.T) {
+	client := &ClientStoreModel{
+		ID:                uuid.New(),
+		Point:             locations.NewPoint(13.404954, 52.520008),
+		LocationID:        toNullString("1"),
+		LocationName:      toNullString("Berlin"),
+		LocationType:      toNullString(locations.City.String()),
+		LocationUpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	exact := orb.Point{13.404954, 52.520008}
+	tests := []struct {
+		name        string
+		settings    PrivacySettings
+		hidden      bool
+		maxDistance float64
+		minDistance float64
+		wantName    string
+	}{
+		{name: "exact", settings: PrivacySettings{Mode: PrivacyExact}, wantName: "Berlin"},
+		{name: "grid", settings: PrivacySettings{Mode: PrivacyGrid, Precision: 1000}, maxDistance: 750},
+		{name: "offset", settings: PrivacySettings{Mode: PrivacyOffset, Precision: 500}, maxDistance: 500},
+		{name: "city", settings: PrivacySettings{Mode: PrivacyCity}, maxDistance: 7500, wantName: "Berlin"},
+		{name: "ghost", settings: PrivacySettings{Mode: PrivacyGhost}, hidden: true},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			c := *client
+			c.Privacy = tt.settings
+			loc, ok := exposeLocation(&c, time.Now())
+			if tt.hidden {
+				assert.False(t, ok)
+				return
+			}
+			assert.True(t, ok)
+			distance := geo.Distance(exact, orb.Point{loc.GeoPoint.Longitude, loc.GeoPoint.Latitude})
+			assert.True(t, distance <= tt.maxDistance, "distance %f", distance)
+			assert.Equal(t, tt.wantName, loc.MetaData.LocationName)
+			// fuzzing is stable for the same position
+			again, _ := exposeLocation(&c, time.Now())
+			assert.Equal(t, loc, again)
+		})
+	}
+}
+
+func TestDefaultService_Privacy(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	client := &ClientStoreModel{ID: uuid.New(), Email: "dummy@mail.com", Status: StatusVerified}
+	assert.Nil(t, store.CreateClient(context.TODO(), client))
+	clientID := client.ID.String()
+	d := NewDefaultService(zap.NewNop(), store, time.Second*10, "")
+
+	err := d.UpdatePrivacy(context.TODO(), PrivacySettings{Mode: "blurry"}, clientID)
+	assert.True(t, errors.Is(err, ErrInvalidPrivacySettings))
+
+	home := PrivacyZone{Name: "home", Polygon: [][2]float64{{13.40, 52.51}, {13.41, 52.51}, {13.41, 52.53}, {13.40, 52.53}}}
+	assert.Nil(t, d.UpdatePrivacy(context.TODO(), PrivacySettings{Mode: PrivacyGhost, Zones: []PrivacyZone{home}}, clientID))
+
+	// positions inside a privacy zone are stored as the centre of the zone
+	loc := locations.Location{ID: "1", GeoPoint: locations.GeoPoint{Longitude: 13.404954, Latitude: 52.520008}}
+	assert.Nil(t, d.UpdateLocation(context.TODO(), loc, clientID))
+	stored, err := d.GetLocation(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.InDelta(t, 13.405, stored.GeoPoint.Longitude, 1e-9)
+	assert.InDelta(t, 52.52, stored.GeoPoint.Latitude, 1e-9)
+	assert.Equal(t, "", stored.ID)
+
+	_, err = d.AdminGetLocation(context.TODO(), AdminLocationPayload{}, clientID)
+	assert.Equal(t, ErrLocationHidden, err)
+	_, err = d.AdminGetLocation(context.TODO(), AdminLocationPayload{Override: true}, clientID)
+	assert.Equal(t, ErrOverrideReasonRequired, err)
+	res, err := d.AdminGetLocation(context.TODO(), AdminLocationPayload{Override: true, Reason: "ticket 42", Actor: "support"}, clientID)
+	assert.Nil(t, err)
+	assert.Equal(t, stored.GeoPoint, res.GeoPoint)
+	assert.Len(t, store.locationAudits, 1)
+}
+
+type locationListenerFunc func(update LocationUpdate) error
+
+func (f locationListenerFunc) OnLocationUpdate(ctx context.Context, update LocationUpdate) error {
+	return f(update)
+}
+
+func TestDefaultService_LocationListener(t *testing.T) {
+	var updates []LocationUpdate
+	listener := locationListenerFunc(func(update LocationUpdate) error {
+		updates = append(updates, update)
+		return errors.New("listener failures are logged only")
+	})
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "secret", WithLocationListener(listener))
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "walker", Handle: "walker", Email: "walker@example.com", Password: "Secret123!"}))
+	client, err := store.GetClientByEmail(context.TODO(), "walker@example.com")
+	assert.Nil(t, err)
+	clientID := client.ID.String()
+	send := func(lon, lat float64) {
+		assert.Nil(t, d.UpdateLocation(context.TODO(), locations.Location{GeoPoint: locations.GeoPoint{Longitude: lon, Latitude: lat}}, clientID))
+	}
+
+	send(18.07, 59.33)
+	send(18.08, 59.33)
+	assert.Nil(t, d.UpdatePrivacy(context.TODO(), PrivacySettings{Mode: PrivacyGhost}, clientID))
+	send(18.09, 59.33)
+
+	assert.Len(t, updates, 3)
+	assert.Nil(t, updates[0].Previous)
+	assert.Equal(t, "walker", updates[1].Handle)
+	assert.Equal(t, &locations.GeoPoint{Longitude: 18.07, Latitude: 59.33}, updates[1].Previous)
+	assert.Equal(t, locations.GeoPoint{Longitude: 18.08, Latitude: 59.33}, updates[1].Exposed.GeoPoint)
+	assert.Equal(t, PrivacyExact, updates[1].Mode)
+	assert.Nil(t, updates[2].Exposed)
+}
+
+func TestDefaultService_LoginWithIdentity(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummypassword"), bcrypt.MinCost)
+	assert.Nil(t, err)
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	existing := &ClientStoreModel{ID: uuid.New(), Name: "alice", Email: "alice@mail.com", Password: string(hash), Status: StatusUnverified}
+	assert.Nil(t, store.CreateClient(context.TODO(), existing))
+	d := NewDefaultService(zap.NewNop(), store, time.Second*10, "secret", WithVerificationConfig(VerificationConfig{AllowUnverifiedLogin: true}))
+	password := LoginPayload{Email: existing.Email, Password: "dummypassword"}
+	squatted, err := d.Login(context.TODO(), password)
+	assert.Nil(t, err)
+
+	// an unverified email can't be linked
+	_, err = d.LoginWithIdentity(context.TODO(), ExternalIdentity{Provider: "oidc", Subject: "1", Email: existing.Email})
+	assert.Equal(t, ErrEmailNotVerified, err)
+
+	// a verified email links the existing client and verifies it
+	res, err := d.LoginWithIdentity(context.TODO(), ExternalIdentity{Provider: "oidc", Subject: "1", Email: existing.Email, EmailVerified: true})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+	assert.Equal(t, StatusVerified, existing.Status)
+	identity, err := store.GetIdentity(context.TODO(), "oidc", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, existing.ID, identity.ClientID)
+
+	// whoever registered the unverified account loses its password and sessions
+	_, err = d.Login(context.TODO(), password)
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: squatted.RefreshToken})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+
+	// the linked identity logs in even when the provider changed the email
+	res, err = d.LoginWithIdentity(context.TODO(), ExternalIdentity{Provider: "oidc", Subject: "1", Email: "other@mail.com"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+
+	// an unknown email registers a new verified client
+	_, err = d.LoginWithIdentity(context.TODO(), ExternalIdentity{Provider: "oidc", Subject: "2", Email: "bob@mail.com", EmailVerified: true})
+	assert.Nil(t, err)
+	created, err := store.GetClientByEmail(context.TODO(), "bob@mail.com")
+	assert.Nil(t, err)
+	identity, err = store.GetIdentity(context.TODO(), "oidc", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, created.ID, identity.ClientID)
+	assert.Equal(t, "bob", created.Name)
+	assert.Equal(t, StatusVerified, created.Status)
+}
+
+func TestDefaultService_Login_TwoFactor(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummypassword"), bcrypt.MinCost)
+	assert.Nil(t, err)
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	client := &ClientStoreModel{ID: uuid.New(), Email: "dummy@mail.com", Password: string(hash), Status: StatusVerified}
+	assert.Nil(t, store.CreateClient(context.TODO(), client))
+	d := NewDefaultService(zap.NewNop(), store, time.Second*10, "secret")
+	payload := LoginPayload{Email: client.Email, Password: "dummypassword"}
+
+	enrollment, err := d.EnrollTwoFactor(context.TODO(), client.ID.String())
+	assert.Nil(t, err)
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now())-1)
+	assert.Nil(t, err)
+	recovery, err := d.ConfirmTwoFactor(context.TODO(), TwoFactorCodePayload{Code: code}, client.ID.String())
+	assert.Nil(t, err)
+
+	// the password only returns a challenge
+	res, err := d.Login(context.TODO(), payload)
+	assert.Nil(t, err)
+	assert.Empty(t, res.Token)
+	assert.NotEmpty(t, res.Challenge)
+
+	_, err = d.VerifyTwoFactor(context.TODO(), TwoFactorPayload{Challenge: res.Challenge, Code: "000000"})
+	assert.Equal(t, totp.ErrInvalidCode, err)
+	code, err = totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	assert.Nil(t, err)
+	session, err := d.VerifyTwoFactor(context.TODO(), TwoFactorPayload{Challenge: res.Challenge, Code: code})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, session.Token)
+	assert.NotEmpty(t, session.RefreshToken)
+
+	// a recovery code replaces the authenticator once
+	res, err = d.Login(context.TODO(), payload)
+	assert.Nil(t, err)
+	_, err = d.VerifyTwoFactor(context.TODO(), TwoFactorPayload{Challenge: res.Challenge, Code: recovery.Codes[0]})
+	assert.Nil(t, err)
+
+	assert.Nil(t, d.DisableTwoFactor(context.TODO(), TwoFactorCodePayload{Code: recovery.Codes[1]}, client.ID.String()))
+	res, err = d.Login(context.TODO(), payload)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+}
+
+func TestDefaultService_Register_PasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "short and common", password: "qwerty", want: []string{passwords.CodeTooShort, passwords.CodeCommon}},
+		{name: "email", password: "walker@example.com", want: []string{passwords.CodeMatchesEmail}},
+		{name: "name", password: "Night Walker", want: []string{passwords.CodeMatchesName}},
+		{name: "valid", password: "walking in the rain"},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefaultService(zap.NewNop(), NewMemStore(make(map[interface{}]*ClientStoreModel)), time.Second, "",
+				WithMailer(mailer.NewMockMailer()))
+			err := d.Register(context.TODO(), RegisterPayload{Name: "Night Walker", Email: "walker@example.com", Password: tt.password})
+			if len(tt.want) == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, passwords.ErrWeakPassword))
+			var weak *passwords.ValidationError
+			assert.True(t, errors.As(err, &weak))
+			var codes []string
+			for _, v := range weak.Violations {
+				codes = append(codes, v.Code)
+			}
+			assert.Equal(t, tt.want, codes)
+		})
+	}
+}
+
+func TestDefaultService_Login_Rehash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummypassword"), bcrypt.MinCost)
+	assert.Nil(t, err)
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	client := &ClientStoreModel{ID: uuid.New(), Email: "dummy@mail.com", Password: string(hash), Status: StatusVerified}
+	assert.Nil(t, store.CreateClient(context.TODO(), client))
+
+	config := passwords.DefaultConfig()
+	config.Algorithm = passwords.AlgArgon2id
+	config.Argon2Memory = 1024
+	config.Argon2Iterations = 1
+	hasher, err := passwords.NewHasher(config)
+	assert.Nil(t, err)
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "", WithHasher(hasher))
+
+	// the bcrypt hash still logs in and is upgraded to the configured argon2id
+	_, err = d.Login(context.TODO(), LoginPayload{Email: client.Email, Password: "dummypassword"})
+	assert.Nil(t, err)
+	stored, err := store.GetClientByID(context.TODO(), client.ID.String())
+	assert.Nil(t, err)
+	assert.Contains(t, stored.Password, "$argon2id$v=19$m=1024,t=1,p=2$")
+	rehash, err := hasher.Verify(stored.Password, "dummypassword")
+	assert.Nil(t, err)
+	assert.False(t, rehash)
+
+	upgraded := stored.Password
+	_, err = d.Login(context.TODO(), LoginPayload{Email: client.Email, Password: "dummypassword"})
+	assert.Nil(t, err)
+	assert.Equal(t, upgraded, stored.Password)
+
+	_, err = d.Login(context.TODO(), LoginPayload{Email: client.Email, Password: "wrongpassword"})
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestDefaultService_ChangePassword(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "", WithMailer(mailer.NewMockMailer()))
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "walker", Handle: "walker", Email: "walker@example.com", Password: "walking in the rain"}))
+	client, err := store.GetClientByEmail(context.TODO(), "walker@example.com")
+	assert.Nil(t, err)
+	assert.Nil(t, store.UpdateStatus(context.TODO(), client.ID.String(), StatusVerified))
+
+	login := LoginPayload{Email: "walker@example.com", Password: "walking in the rain"}
+	_, err = d.Login(context.TODO(), login)
+	assert.Nil(t, err)
+	_, err = d.Login(context.TODO(), login)
+	assert.Nil(t, err)
+	var current, other *SessionStoreModel
+	for _, s := range store.sessionMap {
+		if current == nil {
+			current = s
+		} else {
+			other = s
+		}
+	}
+
+	_, err = d.ChangePassword(context.TODO(), ChangePasswordPayload{CurrentPassword: "wrong password", NewPassword: "running in the sun"}, client.ID.String(), current.ID.String())
+	assert.Equal(t, ErrWrongPassword, err)
+	_, err = d.ChangePassword(context.TODO(), ChangePasswordPayload{CurrentPassword: "walking in the rain", NewPassword: "qwerty"}, client.ID.String(), current.ID.String())
+	assert.True(t, errors.Is(err, passwords.ErrWeakPassword))
+
+	revoked, err := d.ChangePassword(context.TODO(), ChangePasswordPayload{CurrentPassword: "walking in the rain", NewPassword: "running in the sun"}, client.ID.String(), current.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{other.ID.String()}, revoked)
+	revokedCurrent, err := d.IsRevoked(context.TODO(), current.ID.String())
+	assert.Nil(t, err)
+	assert.False(t, revokedCurrent)
+	revokedOther, err := d.IsRevoked(context.TODO(), other.ID.String())
+	assert.Nil(t, err)
+	assert.True(t, revokedOther)
+
+	_, err = d.Login(context.TODO(), login)
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = d.Login(context.TODO(), LoginPayload{Email: "walker@example.com", Password: "running in the sun"})
+	assert.Nil(t, err)
+}
+
+func TestDefaultService_ChangeEmail(t *testing.T) {
+	var sent []mailer.Message
+	m := mailer.NewMockMailer()
+	m.SendFunc = func(msg mailer.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "", WithMailer(m))
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "walker", Handle: "walker", Email: "walker@example.com", Password: "walking in the rain"}))
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "runner", Email: "runner@example.com", Password: "running in the sun"}))
+	client, err := store.GetClientByEmail(context.TODO(), "walker@example.com")
+	assert.Nil(t, err)
+	clientID := client.ID.String()
+
+	tests := []struct {
+		name    string
+		payload ChangeEmailPayload
+		want    error
+	}{
+		{name: "invalid", payload: ChangeEmailPayload{Email: "walker", Password: "walking in the rain"}, want: ErrInvalidEmail},
+		{name: "wrong password", payload: ChangeEmailPayload{Email: "new@example.com", Password: "running in the sun"}, want: ErrWrongPassword},
+		{name: "taken", payload: ChangeEmailPayload{Email: "runner@example.com", Password: "walking in the rain"}, want: ErrEmailTaken},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, d.ChangeEmail(context.TODO(), tt.payload, clientID), tt.name)
+	}
+
+	sent = nil
+	assert.Nil(t, d.ChangeEmail(context.TODO(), ChangeEmailPayload{Email: "new@example.com", Password: "walking in the rain"}, clientID))
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "new@example.com", sent[0].To)
+	token := sent[0].Body[strings.Index(sent[0].Body, "?token=")+len("?token="):]
+
+	// the old email stays until the new one is confirmed
+	_, err = store.GetClientByEmail(context.TODO(), "walker@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, ErrInvalidVerificationToken, d.ConfirmEmailChange(context.TODO(), "unknown"))
+
+	assert.Nil(t, d.ConfirmEmailChange(context.TODO(), token))
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "walker@example.com", sent[1].To)
+	_, err = store.GetClientByEmail(context.TODO(), "walker@example.com")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	changed, err := store.GetClientByEmail(context.TODO(), "new@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, client.ID, changed.ID)
+	assert.Equal(t, StatusVerified, changed.Status)
+	assert.Equal(t, ErrInvalidVerificationToken, d.ConfirmEmailChange(context.TODO(), token))
+
+	_, err = d.Login(context.TODO(), LoginPayload{Email: "new@example.com", Password: "walking in the rain"})
+	assert.Nil(t, err)
+}
+
+func TestDefaultService_Guest(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "secret")
+
+	_, err := d.CreateGuest(context.TODO(), GuestPayload{DeviceID: " "})
+	assert.Equal(t, ErrInvalidDevice, err)
+
+	guest, err := d.CreateGuest(context.TODO(), GuestPayload{DeviceID: "device-1"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, guest.Token)
+	assert.NotEmpty(t, guest.RefreshToken)
+	client, err := store.GetClientByID(context.TODO(), guest.GuestID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusGuest, client.Status)
+	assert.Empty(t, client.Email)
+	assert.True(t, strings.HasPrefix(client.Name, "Guest-"))
+	// many guests share the empty email
+	_, err = d.CreateGuest(context.TODO(), GuestPayload{DeviceID: "device-2"})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		payload GuestLoginPayload
+		want    error
+	}{
+		{name: "valid", payload: GuestLoginPayload{GuestID: guest.GuestID, DeviceID: "device-1", Secret: guest.Secret}},
+		{name: "wrong secret", payload: GuestLoginPayload{GuestID: guest.GuestID, DeviceID: "device-1", Secret: "secret"}, want: ErrInvalidGuest},
+		{name: "other device", payload: GuestLoginPayload{GuestID: guest.GuestID, DeviceID: "device-2", Secret: guest.Secret}, want: ErrInvalidGuest},
+		{name: "unknown guest", payload: GuestLoginPayload{GuestID: uuid.New().String(), DeviceID: "device-1", Secret: guest.Secret}, want: ErrInvalidGuest},
+	}
+	for _, tt := range tests {
+		res, err := d.GuestLogin(context.TODO(), tt.payload)
+		assert.Equal(t, tt.want, err, tt.name)
+		if tt.want == nil {
+			assert.NotEmpty(t, res.Token, tt.name)
+		}
+	}
+
+	// the credentials of a guest can only be set by the upgrade
+	_, err = d.ChangePassword(context.TODO(), ChangePasswordPayload{NewPassword: "walking in the rain"}, guest.GuestID, "")
+	assert.Equal(t, ErrGuestAccount, err)
+	assert.Equal(t, ErrGuestAccount, d.ChangeEmail(context.TODO(), ChangeEmailPayload{Email: "walker@example.com"}, guest.GuestID))
+	_, err = d.EnrollTwoFactor(context.TODO(), guest.GuestID)
+	assert.Equal(t, ErrGuestAccount, err)
+
+	assert.Equal(t, []string{middleware.ScopeProfile, middleware.ScopePlay, middleware.ScopeGuest}, scopesFor(client))
+}
+
+func TestDefaultService_Upgrade(t *testing.T) {
+	var sent []mailer.Message
+	m := mailer.NewMockMailer()
+	m.SendFunc = func(msg mailer.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "secret", WithMailer(m))
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "runner", Email: "runner@example.com", Password: "running in the sun"}))
+	guest, err := d.CreateGuest(context.TODO(), GuestPayload{DeviceID: "device-1"})
+	assert.Nil(t, err)
+	assert.Nil(t, d.UpdateLocation(context.TODO(), locations.Location{GeoPoint: locations.GeoPoint{Longitude: 13.4, Latitude: 52.5}}, guest.GuestID))
+
+	tests := []struct {
+		name    string
+		payload UpgradePayload
+		want    error
+	}{
+		{name: "invalid", payload: UpgradePayload{Email: "walker", Password: "walking in the rain"}, want: ErrInvalidEmail},
+		{name: "taken", payload: UpgradePayload{Email: "runner@example.com", Password: "walking in the rain"}, want: ErrEmailTaken},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, d.Upgrade(context.TODO(), tt.payload, guest.GuestID), tt.name)
+	}
+	err = d.Upgrade(context.TODO(), UpgradePayload{Email: "walker@example.com", Password: "short"}, guest.GuestID)
+	assert.True(t, errors.Is(err, passwords.ErrWeakPassword))
+
+	sent = nil
+	assert.Nil(t, d.Upgrade(context.TODO(), UpgradePayload{Name: "walker", Email: "walker@example.com", Password: "walking in the rain"}, guest.GuestID))
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "walker@example.com", sent[0].To)
+	token := sent[0].Body[strings.Index(sent[0].Body, "?token=")+len("?token="):]
+
+	// the guest keeps playing with its device until the email is confirmed
+	playing, err := d.GuestLogin(context.TODO(), GuestLoginPayload{GuestID: guest.GuestID, DeviceID: "device-1", Secret: guest.Secret})
+	assert.Nil(t, err)
+
+	assert.Nil(t, d.ConfirmEmailChange(context.TODO(), token))
+	assert.Len(t, sent, 1)
+	client, err := store.GetClientByEmail(context.TODO(), "walker@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, guest.GuestID, client.ID.String())
+	assert.Equal(t, StatusVerified, client.Status)
+	assert.Equal(t, "walker", client.Name)
+	assert.Equal(t, 13.4, client.Point.Lon())
+
+	_, err = d.GuestLogin(context.TODO(), GuestLoginPayload{GuestID: guest.GuestID, DeviceID: "device-1", Secret: guest.Secret})
+	assert.Equal(t, ErrInvalidGuest, err)
+	// the guest sessions end with the upgrade, the player signs in again with the password
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: guest.RefreshToken})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: playing.RefreshToken})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	_, err = d.Login(context.TODO(), LoginPayload{Email: "walker@example.com", Password: "walking in the rain"})
+	assert.Nil(t, err)
+	assert.Equal(t, ErrNotGuest, d.Upgrade(context.TODO(), UpgradePayload{Email: "other@example.com", Password: "walking in the rain"}, guest.GuestID))
+}
+
+func TestDefaultService_UpgradeWithIdentity(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "secret")
+	assert.Nil(t, store.CreateClient(context.TODO(), &ClientStoreModel{ID: uuid.New(), Name: "alice", Email: "alice@mail.com", Status: StatusVerified}))
+	guest, err := d.CreateGuest(context.TODO(), GuestPayload{DeviceID: "device-1"})
+	assert.Nil(t, err)
+
+	_, err = d.UpgradeWithIdentity(context.TODO(), guest.GuestID, ExternalIdentity{Provider: "oidc", Subject: "1", Email: "bob@mail.com"})
+	assert.Equal(t, ErrEmailNotVerified, err)
+	_, err = d.UpgradeWithIdentity(context.TODO(), guest.GuestID, ExternalIdentity{Provider: "oidc", Subject: "1", Email: "alice@mail.com", EmailVerified: true})
+	assert.Equal(t, ErrEmailTaken, err)
+
+	res, err := d.UpgradeWithIdentity(context.TODO(), guest.GuestID, ExternalIdentity{Provider: "oidc", Subject: "1", Email: "bob@mail.com", EmailVerified: true})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+	client, err := store.GetClientByEmail(context.TODO(), "bob@mail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, guest.GuestID, client.ID.String())
+	assert.Equal(t, StatusVerified, client.Status)
+	_, err = store.GetGuest(context.TODO(), guest.GuestID)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: guest.RefreshToken})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: res.RefreshToken})
+	assert.Nil(t, err)
+
+	// the identity now signs in the former guest
+	_, err = d.LoginWithIdentity(context.TODO(), ExternalIdentity{Provider: "oidc", Subject: "1"})
+	assert.Nil(t, err)
+	_, err = d.UpgradeWithIdentity(context.TODO(), guest.GuestID, ExternalIdentity{Provider: "oidc", Subject: "2", Email: "carol@mail.com", EmailVerified: true})
+	assert.Equal(t, ErrNotGuest, err)
+}
+
+func TestDefaultService_CleanupGuests(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "secret", WithGuestConfig(GuestConfig{MaxInactiveAge: time.Hour}))
+	old := time.Now().Add(-2 * time.Hour)
+
+	newGuest := func(lastSession time.Time, lastPosition sql.NullTime) string {
+		client := ClientStoreModel{ID: uuid.New(), Status: StatusGuest, CreatedAt: old, LocationUpdatedAt: lastPosition}
+		assert.Nil(t, store.CreateGuest(context.TODO(), &client, &GuestStoreModel{ClientID: client.ID, DeviceID: "device", CreatedAt: old}))
+		assert.Nil(t, store.CreateSession(context.TODO(), &SessionStoreModel{ID: uuid.New(), ClientID: client.ID, FamilyID: uuid.New(), CreatedAt: lastSession}))
+		return client.ID.String()
+	}
+	inactive := newGuest(old, sql.NullTime{})
+	refreshed := newGuest(time.Now(), sql.NullTime{})
+	playing := newGuest(old, sql.NullTime{Time: time.Now(), Valid: true})
+	fresh, err := d.CreateGuest(context.TODO(), GuestPayload{DeviceID: "device"})
+	assert.Nil(t, err)
+	player := &ClientStoreModel{ID: uuid.New(), Email: "alice@mail.com", Status: StatusVerified, CreatedAt: old}
+	assert.Nil(t, store.CreateClient(context.TODO(), player))
+
+	deleted, err := d.CleanupGuests(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = store.GetClientByID(context.TODO(), inactive)
+	assert.NotNil(t, err)
+	_, err = store.GetGuest(context.TODO(), inactive)
+	assert.Equal(t, ErrNotFound, err)
+	for _, id := range []string{refreshed, playing, fresh.GuestID, player.ID.String()} {
+		_, err = store.GetClientByID(context.TODO(), id)
+		assert.Nil(t, err)
+	}
+}
+
+func TestValidateHandle(t *testing.T) {
+	assert.Nil(t, validateHandle("walker_42"))
+	for _, handle := range []string{"ab", "a_very_long_handle_of_walkers", "42walker", "walker-42", "wälker", "_walker"} {
+		assert.True(t, errors.Is(validateHandle(handle), ErrInvalidHandle), handle)
+	}
+	for _, handle := range []string{"Admin", "support", "TheAdminTeam", "geogame_news", "player_1234", "Guest_abc"} {
+		assert.Equal(t, ErrHandleReserved, validateHandle(handle), handle)
+	}
+}
+
+func TestDefaultService_Handle(t *testing.T) {
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	d := NewDefaultService(zap.NewNop(), store, time.Second, "secret", WithHandleConfig(HandleConfig{ChangeCooldown: time.Hour, HoldPeriod: time.Hour}))
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "Alice", Handle: "Alice", Email: "alice@example.com", Password: "Secret123!"}))
+	assert.Equal(t, ErrHandleTaken, d.Register(context.TODO(), RegisterPayload{Name: "Alice", Handle: "ALICE", Email: "alice2@example.com", Password: "Secret123!"}))
+	assert.Equal(t, ErrHandleReserved, d.Register(context.TODO(), RegisterPayload{Name: "Admin", Handle: "admin", Email: "admin@example.com", Password: "Secret123!"}))
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "Bob", Email: "bob@example.com", Password: "Secret123!"}))
+	alice, err := store.GetClientByEmail(context.TODO(), "alice@example.com")
+	assert.Nil(t, err)
+	bob, err := store.GetClientByEmail(context.TODO(), "bob@example.com")
+	assert.Nil(t, err)
+	aliceID, bobID := alice.ID.String(), bob.ID.String()
+
+	// a generated handle can be replaced right away
+	res, err := d.GetHandle(context.TODO(), bobID)
+	assert.Nil(t, err)
+	assert.Equal(t, generatedHandle(playerHandlePrefix, bob.ID), res.Handle)
+	assert.Nil(t, res.NextChangeAt)
+	_, err = d.ChangeHandle(context.TODO(), HandlePayload{Handle: "alice"}, bobID)
+	assert.Equal(t, ErrHandleTaken, err)
+	res, err = d.ChangeHandle(context.TODO(), HandlePayload{Handle: "Bobby"}, bobID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bobby", res.Handle)
+	assert.NotNil(t, res.NextChangeAt)
+	_, err = d.ChangeHandle(context.TODO(), HandlePayload{Handle: "Robert"}, bobID)
+	assert.True(t, errors.Is(err, ErrHandleCooldown))
+
+	// the released handle is held for its former owner
+	alice.HandleChangedAt = sql.NullTime{}
+	_, err = d.ChangeHandle(context.TODO(), HandlePayload{Handle: "Alicia"}, aliceID)
+	assert.Nil(t, err)
+	bob.HandleChangedAt = sql.NullTime{}
+	_, err = d.ChangeHandle(context.TODO(), HandlePayload{Handle: "alice"}, bobID)
+	assert.Equal(t, ErrHandleTaken, err)
+	alice.HandleChangedAt = sql.NullTime{}
+	_, err = d.ChangeHandle(context.TODO(), HandlePayload{Handle: "Alice"}, aliceID)
+	assert.Nil(t, err)
+
+	profile, err := d.PublicProfile(context.TODO(), "alice", bobID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", profile.Handle)
+	assert.Equal(t, []string{"Alicia"}, profile.PreviousHandles)
+	assert.False(t, profile.Guest)
+	_, err = d.PublicProfile(context.TODO(), "nobody", bobID)
+	assert.Equal(t, ErrPlayerNotFound, err)
+
+	assert.Nil(t, d.BlockPlayer(context.TODO(), bobID, aliceID))
+	_, err = d.PublicProfile(context.TODO(), "alice", bobID)
+	assert.Equal(t, ErrPlayerNotFound, err)
+
+	// friend requests find the players by handle
+	assert.Nil(t, d.Register(context.TODO(), RegisterPayload{Name: "Carol", Handle: "carol", Email: "carol@example.com", Password: "Secret123!"}))
+	assert.Nil(t, d.RequestFriend(context.TODO(), FriendPayload{Handle: "CAROL"}, bobID))
+	assert.Equal(t, ErrFriendNotFound, d.RequestFriend(context.TODO(), FriendPayload{Handle: "nobody"}, bobID))
+}
+
+func TestDefaultService_Moderation(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummypassword"), bcrypt.MinCost)
+	assert.Nil(t, err)
+	store := NewMemStore(make(map[interface{}]*ClientStoreModel))
+	client := &ClientStoreModel{ID: uuid.New(), Handle: "walker_42", Email: "walker@mail.com", Password: string(hash), Status: StatusVerified}
+	other := &ClientStoreModel{ID: uuid.New(), Handle: "walker42", Email: "other@mail.com", Status: StatusVerified}
+	assert.Nil(t, store.CreateClient(context.TODO(), client))
+	assert.Nil(t, store.CreateClient(context.TODO(), other))
+	clientID := client.ID.String()
+	d := NewDefaultService(zap.NewNop(), store, time.Second*10, "")
+	login := func() (*APIResponse, error) {
+		return d.Login(context.TODO(), LoginPayload{Email: client.Email, Password: "dummypassword"})
+	}
+
+	_, err = d.SearchPlayers(context.TODO(), " ", "ops")
+	assert.Equal(t, ErrEmptySearch, err)
+	found, err := d.SearchPlayers(context.TODO(), "Walker_", "ops")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlayerSummary{{ID: clientID, Handle: "walker_42", Email: "walker@mail.com", Status: StatusVerified, Standing: StandingActive}}, found)
+	found, err = d.SearchPlayers(context.TODO(), "other@", "ops")
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+	found, err = d.SearchPlayers(context.TODO(), clientID, "ops")
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+
+	session, err := login()
+	assert.Nil(t, err)
+
+	// suspended players can't login or refresh until the suspension ends
+	assert.Equal(t, ErrReasonRequired, d.SuspendPlayer(context.TODO(), SuspendPayload{Until: time.Now().Add(time.Hour), Actor: "ops"}, clientID))
+	assert.Equal(t, ErrInvalidSuspension, d.SuspendPlayer(context.TODO(), SuspendPayload{Until: time.Now().Add(-time.Hour), Reason: "spam", Actor: "ops"}, clientID))
+	assert.Nil(t, d.SuspendPlayer(context.TODO(), SuspendPayload{Until: time.Now().Add(time.Hour), Reason: "spam", Actor: "ops"}, clientID))
+	restricted, err := d.IsRestricted(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.True(t, restricted)
+	_, err = login()
+	assert.True(t, errors.Is(err, ErrAccountSuspended))
+	_, err = d.Refresh(context.TODO(), RefreshPayload{RefreshToken: session.RefreshToken})
+	assert.True(t, errors.Is(err, ErrAccountSuspended))
+	client.SuspendedUntil.Time = time.Now().Add(-time.Second)
+	restricted, err = d.IsRestricted(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.False(t, restricted)
+
+	// a ban revokes the sessions and outlasts any suspension
+	revoked, err := d.BanPlayer(context.TODO(), ModerationPayload{Reason: "cheating", Actor: "ops"}, clientID)
+	assert.Nil(t, err)
+	assert.Len(t, revoked, 1)
+	_, err = login()
+	assert.Equal(t, ErrAccountBanned, err)
+	assert.Equal(t, ErrAccountBanned, d.SuspendPlayer(context.TODO(), SuspendPayload{Until: time.Now().Add(time.Hour), Reason: "spam", Actor: "ops"}, clientID))
+	assert.Nil(t, d.ReinstatePlayer(context.TODO(), ModerationPayload{Reason: "appeal", Actor: "lead"}, clientID))
+	_, err = login()
+	assert.Nil(t, err)
+
+	revoked, err = d.ForceLogout(context.TODO(), clientID, "ops")
+	assert.Nil(t, err)
+	assert.Len(t, revoked, 1)
+	_, err = d.ForceLogout(context.TODO(), uuid.New().String(), "ops")
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = d.AddNote(context.TODO(), NotePayload{Body: " ", Actor: "ops"}, clientID)
+	assert.Equal(t, ErrEmptyNote, err)
+	note, err := d.AddNote(context.TODO(), NotePayload{Body: "warned in chat", Actor: "ops"}, clientID)
+	assert.Nil(t, err)
+	player, err := d.AdminGetPlayer(context.TODO(), clientID, "lead")
+	assert.Nil(t, err)
+	assert.Equal(t, StandingActive, player.Standing)
+	assert.Nil(t, player.BannedAt)
+	assert.Equal(t, []Note{*note}, player.Notes)
+
+	_, err = d.AdminGetLocation(context.TODO(), AdminLocationPayload{Override: true, Reason: "support ticket", Actor: "lead"}, clientID)
+	assert.Equal(t, ErrLocationHidden, err)
+
+	audit, err := d.ListAudit(context.TODO(), clientID)
+	assert.Nil(t, err)
+	var actions []string
+	for _, a := range audit {
+		actions = append(actions, a.Action)
+	}
+	assert.Equal(t, []string{AuditViewPosition, AuditView, AuditNote, AuditLogout, AuditReinstate, AuditBan, AuditSuspend}, actions)
+	assert.Equal(t, "override: support ticket", audit[0].Detail)
+	assert.Equal(t, "lead", audit[0].Actor)
+}
@@ -0,0 +1,151 @@
+package players
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"geogame/internal/middleware"
+	"geogame/pkg"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, all sessions of this login are revoked")
+)
+
+func (d *DefaultService) Refresh(ctx context.Context, payload RefreshPayload) (*APIResponse, error) {
+	if payload.RefreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	session, err := d.store.GetSessionByRefreshHash(dbCtx, pkg.HashToken(payload.RefreshToken))
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrInvalidRefreshToken
+		}
+		d.logger.Error("Refresh: failed to get session from db", zap.Error(err))
+		return nil, errors.New("failed to refresh:" + err.Error())
+	}
+	if session.RevokedAt.Valid {
+		d.revokeFamily(dbCtx, session)
+		return nil, ErrRefreshTokenReused
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	client, err := d.store.GetClientByID(dbCtx, session.ClientID.String())
+	if err != nil {
+		d.logger.Error("Refresh: failed to get client from db", zap.String("clientID", session.ClientID.String()), zap.Error(err))
+		return nil, ErrInvalidRefreshToken
+	}
+	if err := d.checkStanding(client); err != nil {
+		return nil, err
+	}
+
+	next, refreshToken, err := d.newSession(client, session.FamilyID)
+	if err != nil {
+		d.logger.Error("Refresh: failed to create session", zap.Error(err))
+		return nil, err
+	}
+	if err := d.store.RotateSession(dbCtx, session.ID.String(), next); err != nil {
+		if err == ErrSessionRotated {
+			// a concurrent refresh won the race, the token was presented twice
+			d.revokeFamily(dbCtx, session)
+			return nil, ErrRefreshTokenReused
+		}
+		d.logger.Error("Refresh: failed to rotate session", zap.Error(err))
+		return nil, errors.New("failed to refresh:" + err.Error())
+	}
+	return d.issueToken(client, next, refreshToken)
+}
+
+func (d *DefaultService) Logout(ctx context.Context, sessionID string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.RevokeSession(dbCtx, sessionID); err != nil {
+		d.logger.Error("Logout: failed to revoke session", zap.String("sessionID", sessionID), zap.Error(err))
+		return errors.New("failed to logout:" + err.Error())
+	}
+	return nil
+}
+
+// IsRevoked implements the middleware.RevocationChecker, unknown sessions count as revoked
+func (d *DefaultService) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return true, nil
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	session, err := d.store.GetSession(dbCtx, sessionID)
+	if err != nil {
+		if err == ErrNotFound {
+			return true, nil
+		}
+		return false, err
+	}
+	return session.RevokedAt.Valid, nil
+}
+
+// startSession opens a new session family for a fresh login, suspended or banned players are rejected
+func (d *DefaultService) startSession(ctx context.Context, client *ClientStoreModel) (*APIResponse, error) {
+	if err := d.checkStanding(client); err != nil {
+		return nil, err
+	}
+	session, refreshToken, err := d.newSession(client, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateSession(dbCtx, session); err != nil {
+		return nil, errors.New("failed to create session:" + err.Error())
+	}
+	return d.issueToken(client, session, refreshToken)
+}
+
+func (d *DefaultService) newSession(client *ClientStoreModel, familyID uuid.UUID) (*SessionStoreModel, string, error) {
+	refreshToken, err := pkg.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().UTC()
+	session := &SessionStoreModel{
+		ID:               uuid.New(),
+		ClientID:         client.ID,
+		FamilyID:         familyID,
+		RefreshTokenHash: pkg.HashToken(refreshToken),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(d.session.RefreshTokenTTL),
+	}
+	return session, refreshToken, nil
+}
+
+func (d *DefaultService) issueToken(client *ClientStoreModel, session *SessionStoreModel, refreshToken string) (*APIResponse, error) {
+	token, err := d.issuer.GenerateToken(client.ID.String(), session.ID.String(), scopesFor(client))
+	if err != nil {
+		return nil, err
+	}
+	return &APIResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+func (d *DefaultService) revokeFamily(ctx context.Context, session *SessionStoreModel) {
+	d.logger.Warn("refresh token reuse detected", zap.String("clientID", session.ClientID.String()), zap.String("familyID", session.FamilyID.String()))
+	if err := d.store.RevokeSessionFamily(ctx, session.FamilyID.String()); err != nil {
+		d.logger.Error("revokeFamily: failed to revoke session family", zap.Error(err))
+	}
+}
+
+func scopesFor(client *ClientStoreModel) []string {
+	switch client.Status {
+	case StatusUnverified:
+		return []string{middleware.ScopeProfile}
+	case StatusGuest:
+		return []string{middleware.ScopeProfile, middleware.ScopePlay, middleware.ScopeGuest}
+	}
+	return []string{middleware.ScopeProfile, middleware.ScopePlay}
+}
@@ -2,14 +2,90 @@ package players
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"geogame/internal/locations"
 )
 
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrSessionRotated is returned when the session was already rotated or revoked
+var ErrSessionRotated = errors.New("session already rotated")
+
+// ErrFriendshipExists is returned when a friend request between the two players already exists in any direction
+var ErrFriendshipExists = errors.New("friendship already exists")
+
+// ErrEmailTaken is returned when another client already registered the email
+var ErrEmailTaken = errors.New("emailID already registered")
+
+// ErrHandleTaken is returned when another client has the handle in any case
+var ErrHandleTaken = errors.New("handle already taken")
+
+// ErrIdentityLinked is returned when the account of the identity provider is already linked to a client
+var ErrIdentityLinked = errors.New("identity already linked")
+
 type Store interface {
 	CreateClient(ctx context.Context, model *ClientStoreModel) error
 	UpdateName(ctx context.Context, clientID, name string) error
+	UpdatePassword(ctx context.Context, clientID, hash string) error
+	// UpdateEmail returns ErrEmailTaken when another client has the email
+	UpdateEmail(ctx context.Context, clientID, email string) error
 	UpdateLocation(ctx context.Context, clientID string, point locations.LocationStoreModel) error
 	GetClientByEmail(ctx context.Context, emailID string) (*ClientStoreModel, error)
 	GetClientByID(ctx context.Context, id string) (*ClientStoreModel, error)
+	// GetClientByHandle ignores the case of the handle
+	GetClientByHandle(ctx context.Context, handle string) (*ClientStoreModel, error)
+	// UpdateHandle moves the current handle to the history, it returns ErrHandleTaken when another client has the handle
+	UpdateHandle(ctx context.Context, clientID, handle string, changedAt time.Time) error
+	// IsHandleHeld reports whether another client released the handle since the time
+	IsHandleHeld(ctx context.Context, handle, clientID string, since time.Time) (bool, error)
+	ListHandleHistory(ctx context.Context, clientID string) ([]HandleHistoryStoreModel, error)
+	UpdateStatus(ctx context.Context, clientID string, status AccountStatus) error
+	DeleteUnverifiedClients(ctx context.Context, createdBefore time.Time) (int64, error)
+	SaveVerification(ctx context.Context, model *VerificationStoreModel) error
+	GetVerificationByTokenHash(ctx context.Context, tokenHash string) (*VerificationStoreModel, error)
+	GetVerificationByClientID(ctx context.Context, clientID string) (*VerificationStoreModel, error)
+	DeleteVerification(ctx context.Context, clientID string) error
+	CreateSession(ctx context.Context, model *SessionStoreModel) error
+	GetSession(ctx context.Context, id string) (*SessionStoreModel, error)
+	GetSessionByRefreshHash(ctx context.Context, refreshHash string) (*SessionStoreModel, error)
+	RotateSession(ctx context.Context, oldID string, model *SessionStoreModel) error
+	RevokeSession(ctx context.Context, id string) error
+	RevokeSessionFamily(ctx context.Context, familyID string) error
+	// RevokeClientSessions revokes the sessions of the client outside the kept family and returns their ids
+	RevokeClientSessions(ctx context.Context, clientID, keepFamilyID string) ([]string, error)
+	SaveEmailChange(ctx context.Context, model *EmailChangeStoreModel) error
+	GetEmailChangeByTokenHash(ctx context.Context, tokenHash string) (*EmailChangeStoreModel, error)
+	DeleteEmailChange(ctx context.Context, clientID string) error
+	UpdateLocationSharing(ctx context.Context, clientID string, sharing LocationSharing) error
+	CreateFriendship(ctx context.Context, model *FriendshipStoreModel) error
+	GetFriendship(ctx context.Context, clientID, otherID string) (*FriendshipStoreModel, error)
+	AcceptFriendship(ctx context.Context, requesterID, addresseeID string) error
+	DeleteFriendship(ctx context.Context, clientID, otherID string) error
+	ListFriendships(ctx context.Context, clientID string) ([]FriendshipStoreModel, error)
+	ListFriendLocations(ctx context.Context, clientID string) ([]ClientStoreModel, error)
+	CreateBlock(ctx context.Context, model *BlockStoreModel) error
+	DeleteBlock(ctx context.Context, blockerID, blockedID string) error
+	IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error)
+	UpdatePrivacy(ctx context.Context, clientID string, settings PrivacySettings) error
+	CreateLocationAudit(ctx context.Context, model *LocationAuditStoreModel) error
+	GetIdentity(ctx context.Context, provider, subject string) (*IdentityStoreModel, error)
+	CreateIdentity(ctx context.Context, model *IdentityStoreModel) error
+	// CreateGuest creates the client and its device binding together
+	CreateGuest(ctx context.Context, client *ClientStoreModel, guest *GuestStoreModel) error
+	GetGuest(ctx context.Context, clientID string) (*GuestStoreModel, error)
+	DeleteGuest(ctx context.Context, clientID string) error
+	// DeleteInactiveGuests removes the guests without session or position since the time
+	DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int64, error)
+	// SearchClients matches the start of the email or the handle regardless of the case,
+	// the pattern is escaped for LIKE
+	SearchClients(ctx context.Context, pattern string, limit int) ([]ClientStoreModel, error)
+	GetRestriction(ctx context.Context, clientID string) (*RestrictionStoreModel, error)
+	UpdateRestriction(ctx context.Context, clientID string, restriction RestrictionStoreModel) error
+	CreateNote(ctx context.Context, model *NoteStoreModel) error
+	ListNotes(ctx context.Context, clientID string) ([]NoteStoreModel, error)
+	CreateModerationAudit(ctx context.Context, model *ModerationAuditStoreModel) error
+	ListModerationAudits(ctx context.Context, clientID string) ([]ModerationAuditStoreModel, error)
 }
@@ -0,0 +1,98 @@
+package players
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"geogame/internal/lockout"
+	"geogame/internal/totp"
+)
+
+// startLogin opens the session, or asks for the second factor when the player enabled it
+func (d *DefaultService) startLogin(ctx context.Context, client *ClientStoreModel) (*APIResponse, error) {
+	enabled, err := d.twoFactor.Enabled(ctx, totp.ClientKey(client.ID.String()))
+	if err != nil {
+		return nil, errors.New("failed to check the second factor:" + err.Error())
+	}
+	if enabled {
+		return d.newChallenge(ctx, client)
+	}
+	return d.startSession(ctx, client)
+}
+
+func (d *DefaultService) newChallenge(ctx context.Context, client *ClientStoreModel) (*APIResponse, error) {
+	challenge, err := d.twoFactor.NewChallenge(ctx, totp.ClientKey(client.ID.String()))
+	if err != nil {
+		d.logger.Error("Login: failed to create two-factor challenge", zap.String("clientID", client.ID.String()), zap.Error(err))
+		return nil, err
+	}
+	return &APIResponse{Challenge: challenge}, nil
+}
+
+// VerifyTwoFactor completes a login with the code of the authenticator or a recovery code,
+// the wrong codes count as failed logins of the email
+func (d *DefaultService) VerifyTwoFactor(ctx context.Context, payload TwoFactorPayload) (*APIResponse, error) {
+	key, err := d.twoFactor.ChallengeKey(ctx, payload.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	clientID, ok := totp.ClientIDOf(key)
+	if !ok {
+		return nil, totp.ErrInvalidChallenge
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("VerifyTwoFactor: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return nil, totp.ErrInvalidChallenge
+	}
+
+	lockoutKey := lockout.EmailKey(client.Email)
+	if err := d.lockout.Check(ctx, lockoutKey); err != nil {
+		d.logger.Warn("VerifyTwoFactor: attempt throttled", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	if _, err := d.twoFactor.CompleteChallenge(ctx, payload.Challenge, payload.Code); err != nil {
+		if err == totp.ErrInvalidCode {
+			if err := d.lockout.Fail(ctx, lockoutKey); err != nil {
+				d.logger.Error("VerifyTwoFactor: failed to record failed attempt", zap.Error(err))
+			}
+		}
+		return nil, err
+	}
+	if err := d.lockout.Reset(ctx, lockoutKey); err != nil {
+		d.logger.Error("VerifyTwoFactor: failed to reset failed attempts", zap.String("clientID", clientID), zap.Error(err))
+	}
+
+	res, err := d.startSession(ctx, client)
+	if err != nil {
+		d.logger.Error("VerifyTwoFactor: failed to start session", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (d *DefaultService) EnrollTwoFactor(ctx context.Context, clientID string) (*totp.Enrollment, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	client, err := d.store.GetClientByID(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("EnrollTwoFactor: failed to get client from db", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to enroll:" + err.Error())
+	}
+	if client.Status == StatusGuest {
+		return nil, ErrGuestAccount
+	}
+	return d.twoFactor.Enroll(ctx, totp.ClientKey(clientID), client.Email)
+}
+
+func (d *DefaultService) ConfirmTwoFactor(ctx context.Context, payload TwoFactorCodePayload, clientID string) (*totp.RecoveryCodes, error) {
+	return d.twoFactor.Confirm(ctx, totp.ClientKey(clientID), payload.Code)
+}
+
+func (d *DefaultService) DisableTwoFactor(ctx context.Context, payload TwoFactorCodePayload, clientID string) error {
+	return d.twoFactor.Disable(ctx, totp.ClientKey(clientID), payload.Code)
+}
@@ -0,0 +1,133 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var _ PushSender = (*APNsSender)(nil)
+
+// apnsTokenTTL renews the provider token before APNs rejects it after an hour
+const apnsTokenTTL = 50 * time.Minute
+
+// APNsSender sends through the Apple Push Notification service with token based authentication
+type APNsSender struct {
+	endpoint string
+	topic    string
+	keyID    string
+	teamID   string
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func NewAPNsSender(config *Config) (*APNsSender, error) {
+	raw, err := ioutil.ReadFile(config.APNsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("apns key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("apns key is not an ECDSA key")
+	}
+	return &APNsSender{
+		endpoint: config.APNsEndpoint,
+		topic:    config.APNsTopic,
+		keyID:    config.APNsKeyID,
+		teamID:   config.APNsTeamID,
+		key:      key,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// providerToken returns the signed token, it is reused as APNs throttles frequent renewals
+func (a *APNsSender) providerToken(now time.Time) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && now.Sub(a.issuedAt) < apnsTokenTTL {
+		return a.token, nil
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": a.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = a.keyID
+	signed, err := token.SignedString(a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issuedAt = signed, now
+	return signed, nil
+}
+
+type apnsAlert struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func (a *APNsSender) Send(ctx context.Context, msg Message) error {
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": apnsAlert{Title: msg.Title, Body: msg.Body},
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	token, err := a.providerToken(time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/3/device/"+msg.Token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+token)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+	var reason struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(res.Body).Decode(&reason)
+	if res.StatusCode == http.StatusGone || reason.Reason == "BadDeviceToken" || reason.Reason == "Unregistered" {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("apns responded with status %d: %s", res.StatusCode, reason.Reason)
+}
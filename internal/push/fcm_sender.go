@@ -0,0 +1,83 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var _ PushSender = (*FCMSender)(nil)
+
+// FCMSender sends through the Firebase Cloud Messaging HTTP API with a server key
+type FCMSender struct {
+	endpoint  string
+	serverKey string
+	client    *http.Client
+}
+
+func NewFCMSender(config *Config) *FCMSender {
+	return &FCMSender{
+		endpoint:  config.FCMEndpoint,
+		serverKey: config.FCMServerKey,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type fcmRequest struct {
+	To           string            `json:"to"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmResponse struct {
+	Failure int `json:"failure"`
+	Results []struct {
+		Error string `json:"error"`
+	} `json:"results"`
+}
+
+func (f *FCMSender) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(fcmRequest{
+		To:           msg.Token,
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "key="+f.serverKey)
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fcm responded with status %d", res.StatusCode)
+	}
+	var result fcmResponse
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Failure == 0 || len(result.Results) == 0 {
+		return nil
+	}
+	switch result.Results[0].Error {
+	case "NotRegistered", "InvalidRegistration", "MismatchSenderId":
+		return ErrInvalidToken
+	default:
+		return fmt.Errorf("fcm failed: %s", result.Results[0].Error)
+	}
+}
@@ -0,0 +1,21 @@
+package push
+
+import "context"
+
+var _ PushSender = (*MockSender)(nil)
+
+type MockSender struct {
+	SendFunc func(msg Message) error
+}
+
+func NewMockSender() *MockSender {
+	return &MockSender{
+		SendFunc: func(msg Message) error {
+			return nil
+		},
+	}
+}
+
+func (m *MockSender) Send(ctx context.Context, msg Message) error {
+	return m.SendFunc(msg)
+}
@@ -0,0 +1,46 @@
+package push
+
+import (
+	"context"
+	"errors"
+)
+
+type Platform string
+
+const (
+	PlatformFCM  Platform = "fcm"
+	PlatformAPNs Platform = "apns"
+)
+
+var (
+	// ErrInvalidToken is returned when the provider rejected the device token for good, the token should be dropped
+	ErrInvalidToken = errors.New("push token is no longer valid")
+	// ErrUnsupportedPlatform is returned when no sender is configured for the platform of the device
+	ErrUnsupportedPlatform = errors.New("push platform is not supported")
+)
+
+type Message struct {
+	Token    string            `json:"token"`
+	Platform Platform          `json:"platform"`
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// PushSender delivers push notifications to the devices of the players
+type PushSender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Config struct {
+	FCMServerKey string `env:"FCM_SERVER_KEY"`
+	FCMEndpoint  string `env:"FCM_ENDPOINT" envDefault:"https://fcm.googleapis.com/fcm/send"`
+	// APNsKeyFile is the .p8 token signing key of the Apple developer account
+	APNsKeyFile  string `env:"APNS_KEY_FILE"`
+	APNsKeyID    string `env:"APNS_KEY_ID"`
+	APNsTeamID   string `env:"APNS_TEAM_ID"`
+	APNsTopic    string `env:"APNS_TOPIC"`
+	APNsEndpoint string `env:"APNS_ENDPOINT" envDefault:"https://api.push.apple.com"`
+	// FileSink is the file the pushes are appended to in the dev environment
+	FileSink string `env:"PUSH_FILE_SINK" envDefault:"push.log"`
+}
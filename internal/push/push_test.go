@@ -0,0 +1,113 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFCMSender_Send(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+		fails   bool
+	}{
+		{name: "sent", status: http.StatusOK, body: `{"success":1,"failure":0,"results":[{"message_id":"1"}]}`},
+		{name: "unregistered", status: http.StatusOK, body: `{"success":0,"failure":1,"results":[{"error":"NotRegistered"}]}`, wantErr: ErrInvalidToken},
+		{name: "unavailable", status: http.StatusOK, body: `{"success":0,"failure":1,"results":[{"error":"Unavailable"}]}`, fails: true},
+		{name: "server error", status: http.StatusInternalServerError, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "key=secret", r.Header.Get("Authorization"))
+				var req fcmRequest
+				assert.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+				assert.Equal(t, "device", req.To)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			sender := NewFCMSender(&Config{FCMEndpoint: server.URL, FCMServerKey: "secret"})
+			err := sender.Send(context.TODO(), Message{Token: "device", Platform: PlatformFCM, Title: "hi"})
+			switch {
+			case tt.wantErr != nil:
+				assert.Equal(t, tt.wantErr, err)
+			case tt.fails:
+				assert.Error(t, err)
+			default:
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestAPNsSender_Send(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	dir, err := ioutil.TempDir("", "apns")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key.p8")
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("authorization"), "bearer "))
+		assert.Equal(t, "com.geogame", r.Header.Get("apns-topic"))
+		if r.URL.Path == "/3/device/gone" {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		}
+	}))
+	defer server.Close()
+	sender, err := NewAPNsSender(&Config{APNsKeyFile: keyFile, APNsKeyID: "kid", APNsTeamID: "team", APNsTopic: "com.geogame", APNsEndpoint: server.URL})
+	assert.Nil(t, err)
+
+	assert.Nil(t, sender.Send(context.TODO(), Message{Token: "device", Platform: PlatformAPNs, Title: "hi"}))
+	assert.Equal(t, ErrInvalidToken, sender.Send(context.TODO(), Message{Token: "gone", Platform: PlatformAPNs, Title: "hi"}))
+}
+
+func TestRouter_Send(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "push.log")
+	router := NewRouter()
+	router.Register(PlatformFCM, NewFileSender(path))
+
+	assert.Nil(t, router.Send(context.TODO(), Message{Token: "a", Platform: PlatformFCM, Title: "first"}))
+	assert.Nil(t, router.Send(context.TODO(), Message{Token: "b", Platform: PlatformFCM, Title: "second"}))
+	assert.Equal(t, ErrUnsupportedPlatform, router.Send(context.TODO(), Message{Token: "c", Platform: PlatformAPNs}))
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+	lines := make([]Message, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var msg Message
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &msg))
+		lines = append(lines, msg)
+	}
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "second", lines[1].Title)
+	}
+}
@@ -0,0 +1,35 @@
+package push
+
+import "context"
+
+var _ PushSender = (*Router)(nil)
+
+// Router hands a message to the sender of the platform of the device
+type Router struct {
+	senders map[Platform]PushSender
+}
+
+func NewRouter() *Router {
+	return &Router{
+		senders: make(map[Platform]PushSender),
+	}
+}
+
+// Register sets the sender of the platform
+func (r *Router) Register(platform Platform, sender PushSender) {
+	r.senders[platform] = sender
+}
+
+// Supports reports whether a sender is registered for the platform
+func (r *Router) Supports(platform Platform) bool {
+	_, ok := r.senders[platform]
+	return ok
+}
+
+func (r *Router) Send(ctx context.Context, msg Message) error {
+	sender, ok := r.senders[msg.Platform]
+	if !ok {
+		return ErrUnsupportedPlatform
+	}
+	return sender.Send(ctx, msg)
+}
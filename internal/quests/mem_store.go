@@ -0,0 +1,139 @@
+package quests
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu          sync.Mutex
+	questMap    map[string]*QuestStoreModel
+	progressMap map[string]*ProgressStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		questMap:    make(map[string]*QuestStoreModel),
+		progressMap: make(map[string]*ProgressStoreModel),
+	}
+}
+
+func (m *MemStore) CreateQuest(ctx context.Context, model *QuestStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *model
+	m.questMap[model.ID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) UpdateQuest(ctx context.Context, model *QuestStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.questMap[model.ID.String()]; !ok {
+		return ErrNotFound
+	}
+	c := *model
+	m.questMap[model.ID.String()] = &c
+	return nil
+}
+
+func (m *MemStore) GetQuest(ctx context.Context, id string) (*QuestStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.questMap[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *q
+	return &c, nil
+}
+
+func (m *MemStore) DeleteQuest(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.questMap, id)
+	for key, p := range m.progressMap {
+		if p.QuestID.String() == id {
+			delete(m.progressMap, key)
+		}
+	}
+	return nil
+}
+
+func (m *MemStore) ListQuests(ctx context.Context) ([]QuestStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]QuestStoreModel, 0, len(m.questMap))
+	for _, q := range m.questMap {
+		res = append(res, *q)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+func (m *MemStore) CreateProgress(ctx context.Context, model *ProgressStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := progressKey(model.ClientID.String(), model.QuestID.String())
+	if _, ok := m.progressMap[key]; ok {
+		return ErrProgressExists
+	}
+	c := *model
+	m.progressMap[key] = &c
+	return nil
+}
+
+func (m *MemStore) GetProgress(ctx context.Context, clientID, questID string) (*ProgressStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.progressMap[progressKey(clientID, questID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *p
+	c.Completed = append(pq.StringArray(nil), p.Completed...)
+	return &c, nil
+}
+
+func (m *MemStore) ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]ProgressStoreModel, 0)
+	for _, p := range m.progressMap {
+		if p.ClientID.String() == clientID {
+			c := *p
+			c.Completed = append(pq.StringArray(nil), p.Completed...)
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (m *MemStore) UpdateProgress(ctx context.Context, clientID, questID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.progressMap[progressKey(clientID, questID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// fn works on a copy so a failing update leaves the progress untouched
+	next := *p
+	next.Completed = append(pq.StringArray(nil), p.Completed...)
+	if err := fn(&next); err != nil {
+		return nil, err
+	}
+	*p = next
+	res := next
+	return &res, nil
+}
+
+func progressKey(clientID, questID string) string {
+	return clientID + ":" + questID
+}
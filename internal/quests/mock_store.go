@@ -0,0 +1,85 @@
+package quests
+
+import "context"
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	CreateQuestFunc    func(model *QuestStoreModel) error
+	UpdateQuestFunc    func(model *QuestStoreModel) error
+	GetQuestFunc       func(id string) (*QuestStoreModel, error)
+	DeleteQuestFunc    func(id string) error
+	ListQuestsFunc     func() ([]QuestStoreModel, error)
+	CreateProgressFunc func(model *ProgressStoreModel) error
+	GetProgressFunc    func(clientID, questID string) (*ProgressStoreModel, error)
+	ListProgressFunc   func(clientID string) ([]ProgressStoreModel, error)
+	UpdateProgressFunc func(clientID, questID string, fn ProgressFunc) (*ProgressStoreModel, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		CreateQuestFunc: func(model *QuestStoreModel) error {
+			return nil
+		},
+		UpdateQuestFunc: func(model *QuestStoreModel) error {
+			return nil
+		},
+		GetQuestFunc: func(id string) (*QuestStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		DeleteQuestFunc: func(id string) error {
+			return nil
+		},
+		ListQuestsFunc: func() ([]QuestStoreModel, error) {
+			return []QuestStoreModel{}, nil
+		},
+		CreateProgressFunc: func(model *ProgressStoreModel) error {
+			return nil
+		},
+		GetProgressFunc: func(clientID, questID string) (*ProgressStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		ListProgressFunc: func(clientID string) ([]ProgressStoreModel, error) {
+			return []ProgressStoreModel{}, nil
+		},
+		UpdateProgressFunc: func(clientID, questID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+			return nil, ErrNotFound
+		},
+	}
+}
+
+func (m *MockStore) CreateQuest(ctx context.Context, model *QuestStoreModel) error {
+	return m.CreateQuestFunc(model)
+}
+
+func (m *MockStore) UpdateQuest(ctx context.Context, model *QuestStoreModel) error {
+	return m.UpdateQuestFunc(model)
+}
+
+func (m *MockStore) GetQuest(ctx context.Context, id string) (*QuestStoreModel, error) {
+	return m.GetQuestFunc(id)
+}
+
+func (m *MockStore) DeleteQuest(ctx context.Context, id string) error {
+	return m.DeleteQuestFunc(id)
+}
+
+func (m *MockStore) ListQuests(ctx context.Context) ([]QuestStoreModel, error) {
+	return m.ListQuestsFunc()
+}
+
+func (m *MockStore) CreateProgress(ctx context.Context, model *ProgressStoreModel) error {
+	return m.CreateProgressFunc(model)
+}
+
+func (m *MockStore) GetProgress(ctx context.Context, clientID, questID string) (*ProgressStoreModel, error) {
+	return m.GetProgressFunc(clientID, questID)
+}
+
+func (m *MockStore) ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error) {
+	return m.ListProgressFunc(clientID)
+}
+
+func (m *MockStore) UpdateProgress(ctx context.Context, clientID, questID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+	return m.UpdateProgressFunc(clientID, questID, fn)
+}
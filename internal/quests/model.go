@@ -0,0 +1,145 @@
+package quests
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type QuestStoreModel struct {
+	ID          uuid.UUID `db:"id"`
+	Title       string    `db:"title"`
+	Description string    `db:"description"`
+	// Ordered quests have to be checked in step by step, unordered ones in any order
+	Ordered          bool           `db:"ordered"`
+	Steps            pq.StringArray `db:"steps"`
+	TimeLimitSeconds int64          `db:"time_limit_seconds"`
+	Prerequisites    pq.StringArray `db:"prerequisites"`
+	Reward           Reward         `db:"reward"`
+	CreatedAt        time.Time      `db:"created_at"`
+	UpdatedAt        time.Time      `db:"updated_at"`
+}
+
+type ProgressStatus string
+
+const (
+	StatusActive    ProgressStatus = "active"
+	StatusCompleted ProgressStatus = "completed"
+	StatusExpired   ProgressStatus = "expired"
+	// StatusAvailable and StatusLocked are only shown to the player, they are never stored
+	StatusAvailable ProgressStatus = "available"
+	StatusLocked    ProgressStatus = "locked"
+)
+
+type ProgressStoreModel struct {
+	ClientID    uuid.UUID      `db:"client_id"`
+	QuestID     uuid.UUID      `db:"quest_id"`
+	Status      ProgressStatus `db:"status"`
+	Completed   pq.StringArray `db:"completed_steps"`
+	StartedAt   time.Time      `db:"started_at"`
+	ExpiresAt   sql.NullTime   `db:"expires_at"`
+	CompletedAt sql.NullTime   `db:"completed_at"`
+}
+
+// Reward is granted once when a quest is completed
+type Reward struct {
+	XP    int          `json:"xp,omitempty"`
+	Items []RewardItem `json:"items,omitempty"`
+}
+
+// Empty reports whether the reward grants nothing
+func (r Reward) Empty() bool {
+	return r.XP == 0 && len(r.Items) == 0
+}
+
+type RewardItem struct {
+	ItemID   string `json:"itemId"`
+	Quantity int    `json:"quantity"`
+}
+
+// Value enables serialization to SQL
+func (r Reward) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan enables deserialization from SQL
+func (r *Reward) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = Reward{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return fmt.Errorf("unsupported reward type %T", src)
+}
+
+// QuestPayload is the admin definition of a quest
+type QuestPayload struct {
+	ID               string   `json:"id"`
+	Title            string   `json:"title"`
+	Description      string   `json:"description"`
+	Ordered          bool     `json:"ordered"`
+	Steps            []string `json:"steps"`
+	TimeLimitSeconds int64    `json:"timeLimitSeconds,omitempty"`
+	Prerequisites    []string `json:"prerequisites,omitempty"`
+	Reward           Reward   `json:"reward"`
+}
+
+type Quest struct {
+	ID               string    `json:"id"`
+	Title            string    `json:"title"`
+	Description      string    `json:"description"`
+	Ordered          bool      `json:"ordered"`
+	Steps            []string  `json:"steps"`
+	TimeLimitSeconds int64     `json:"timeLimitSeconds,omitempty"`
+	Prerequisites    []string  `json:"prerequisites,omitempty"`
+	Reward           Reward    `json:"reward"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// PlayerQuest is a quest as seen by a player
+type PlayerQuest struct {
+	Quest
+	Status ProgressStatus `json:"status"`
+}
+
+type Step struct {
+	LocationID string `json:"locationId"`
+	Done       bool   `json:"done"`
+}
+
+type Progress struct {
+	QuestID     string         `json:"questId"`
+	Title       string         `json:"title"`
+	Status      ProgressStatus `json:"status"`
+	Ordered     bool           `json:"ordered"`
+	Steps       []Step         `json:"steps"`
+	NextStep    string         `json:"nextStep,omitempty"`
+	StartedAt   time.Time      `json:"startedAt"`
+	ExpiresAt   *time.Time     `json:"expiresAt,omitempty"`
+	CompletedAt *time.Time     `json:"completedAt,omitempty"`
+}
+
+func toQuest(m *QuestStoreModel) Quest {
+	return Quest{
+		ID:               m.ID.String(),
+		Title:            m.Title,
+		Description:      m.Description,
+		Ordered:          m.Ordered,
+		Steps:            []string(m.Steps),
+		TimeLimitSeconds: m.TimeLimitSeconds,
+		Prerequisites:    []string(m.Prerequisites),
+		Reward:           m.Reward,
+		CreatedAt:        m.CreatedAt,
+		UpdatedAt:        m.UpdatedAt,
+	}
+}
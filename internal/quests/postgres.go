@@ -0,0 +1,212 @@
+package quests
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	questsAllCols   = "id, title, description, ordered, steps, time_limit_seconds, prerequisites, reward, created_at, updated_at"
+	questsTable     = "quests"
+	progressAllCols = "client_id, quest_id, status, completed_steps, started_at, expires_at, completed_at"
+	progressTable   = "quest_progress"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.quests.store"),
+	}
+}
+
+func (p Postgres) CreateQuest(ctx context.Context, model *QuestStoreModel) error {
+	stmt := `INSERT INTO quests (
+	id,
+	title,
+	description,
+	ordered,
+	steps,
+	time_limit_seconds,
+	prerequisites,
+	reward,
+	created_at,
+	updated_at
+	) VALUES (
+	:id,
+	:title,
+	:description,
+	:ordered,
+	:steps,
+	:time_limit_seconds,
+	:prerequisites,
+	:reward,
+	:created_at,
+	:updated_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("CreateQuest: failed to insert quest to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) UpdateQuest(ctx context.Context, model *QuestStoreModel) error {
+	stmt := `UPDATE quests SET
+	title=:title,
+	description=:description,
+	ordered=:ordered,
+	steps=:steps,
+	time_limit_seconds=:time_limit_seconds,
+	prerequisites=:prerequisites,
+	reward=:reward,
+	updated_at=:updated_at
+	WHERE id=:id
+	`
+	res, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		p.logger.Error("UpdateQuest: failed to update quest to db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) GetQuest(ctx context.Context, id string) (*QuestStoreModel, error) {
+	stmt := "SELECT " + questsAllCols + " FROM " + questsTable + " WHERE id=$1"
+	var q QuestStoreModel
+	if err := p.db.GetContext(ctx, &q, stmt, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetQuest: failed to get quest from db", zap.Error(err))
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (p Postgres) DeleteQuest(ctx context.Context, id string) error {
+	stmt := `DELETE FROM quests
+	WHERE id=$1`
+	_, err := p.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		p.logger.Error("DeleteQuest: failed to delete quest from db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) ListQuests(ctx context.Context) ([]QuestStoreModel, error) {
+	stmt := "SELECT " + questsAllCols + " FROM " + questsTable + " ORDER BY created_at"
+	res := make([]QuestStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt); err != nil {
+		p.logger.Error("ListQuests: failed to list quests from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p Postgres) CreateProgress(ctx context.Context, model *ProgressStoreModel) error {
+	stmt := `INSERT INTO quest_progress (
+	client_id,
+	quest_id,
+	status,
+	completed_steps,
+	started_at,
+	expires_at,
+	completed_at
+	) VALUES (
+	:client_id,
+	:quest_id,
+	:status,
+	:completed_steps,
+	:started_at,
+	:expires_at,
+	:completed_at
+	)`
+	_, err := p.db.NamedExecContext(ctx, stmt, *model)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok {
+			if pgErr.Code == "23505" {
+				return ErrProgressExists
+			}
+		}
+		p.logger.Error("CreateProgress: failed to insert progress to db", zap.Error(err))
+	}
+	return err
+}
+
+func (p Postgres) GetProgress(ctx context.Context, clientID, questID string) (*ProgressStoreModel, error) {
+	stmt := "SELECT " + progressAllCols + " FROM " + progressTable + " WHERE client_id=$1 AND quest_id=$2"
+	var m ProgressStoreModel
+	if err := p.db.GetContext(ctx, &m, stmt, clientID, questID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetProgress: failed to get progress from db", zap.Error(err))
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (p Postgres) ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error) {
+	stmt := "SELECT " + progressAllCols + " FROM " + progressTable + " WHERE client_id=$1"
+	res := make([]ProgressStoreModel, 0)
+	if err := p.db.SelectContext(ctx, &res, stmt, clientID); err != nil {
+		p.logger.Error("ListProgress: failed to list progress from db", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateProgress locks the progress row with FOR UPDATE so two check-ins can not complete the same step twice
+func (p Postgres) UpdateProgress(ctx context.Context, clientID, questID string, fn ProgressFunc) (*ProgressStoreModel, error) {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		p.logger.Error("UpdateProgress: failed to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var m ProgressStoreModel
+	stmt := "SELECT " + progressAllCols + " FROM " + progressTable + " WHERE client_id=$1 AND quest_id=$2 FOR UPDATE"
+	if err := tx.GetContext(ctx, &m, stmt, clientID, questID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("UpdateProgress: failed to lock progress", zap.Error(err))
+		return nil, err
+	}
+	if err := fn(&m); err != nil {
+		return nil, err
+	}
+	update := `UPDATE quest_progress SET
+	status=:status,
+	completed_steps=:completed_steps,
+	started_at=:started_at,
+	expires_at=:expires_at,
+	completed_at=:completed_at
+	WHERE client_id=:client_id AND quest_id=:quest_id
+	`
+	if _, err := tx.NamedExecContext(ctx, update, m); err != nil {
+		p.logger.Error("UpdateProgress: failed to update progress", zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,458 @@
+package quests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/locations"
+)
+
+var (
+	ErrQuestNotFound        = errors.New("quest not found")
+	ErrInvalidQuest         = errors.New("invalid quest")
+	ErrPrerequisitesMissing = errors.New("prerequisite quests are not completed")
+	ErrQuestAlreadyStarted  = errors.New("quest already started or completed")
+	ErrQuestNotStarted      = errors.New("quest not started")
+)
+
+type Service interface {
+	CreateQuest(ctx context.Context, payload QuestPayload) (*Quest, error)
+	UpdateQuest(ctx context.Context, payload QuestPayload) (*Quest, error)
+	GetQuest(ctx context.Context, id string) (*Quest, error)
+	DeleteQuest(ctx context.Context, id string) error
+	ListQuests(ctx context.Context) ([]Quest, error)
+	PlayerQuests(ctx context.Context, clientID string) ([]PlayerQuest, error)
+	Start(ctx context.Context, clientID, questID string) (*Progress, error)
+	Progress(ctx context.Context, clientID, questID string) (*Progress, error)
+	checkins.Listener
+}
+
+// LocationProvider returns the game locations
+type LocationProvider interface {
+	Get(ctx context.Context, id string) (*locations.Location, error)
+}
+
+// RewardSink grants the reward of a completed quest
+type RewardSink interface {
+	GrantReward(ctx context.Context, clientID string, reward Reward) error
+}
+
+type Option func(*DefaultService)
+
+func WithRewardSink(s RewardSink) Option {
+	return func(d *DefaultService) {
+		d.rewards = s
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	locations LocationProvider
+	rewards   RewardSink
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, locations LocationProvider, dbTimeOut time.Duration, options ...Option) *DefaultService {
+	d := &DefaultService{
+		logger:    logger,
+		store:     store,
+		locations: locations,
+		dbTimeOut: dbTimeOut,
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	return d
+}
+
+func (d *DefaultService) CreateQuest(ctx context.Context, payload QuestPayload) (*Quest, error) {
+	now := time.Now().UTC()
+	model := QuestStoreModel{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := d.apply(ctx, &model, payload); err != nil {
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateQuest(dbCtx, &model); err != nil {
+		d.logger.Error("CreateQuest: failed to create quest", zap.String("title", payload.Title), zap.Error(err))
+		return nil, errors.New("failed to create quest:" + err.Error())
+	}
+	q := toQuest(&model)
+	return &q, nil
+}
+
+func (d *DefaultService) UpdateQuest(ctx context.Context, payload QuestPayload) (*Quest, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	model, err := d.store.GetQuest(dbCtx, payload.ID)
+	if err != nil {
+		return nil, d.questError("UpdateQuest", payload.ID, err)
+	}
+	if err := d.apply(ctx, model, payload); err != nil {
+		return nil, err
+	}
+	model.UpdatedAt = time.Now().UTC()
+	if err := d.store.UpdateQuest(dbCtx, model); err != nil {
+		return nil, d.questError("UpdateQuest", payload.ID, err)
+	}
+	q := toQuest(model)
+	return &q, nil
+}
+
+func (d *DefaultService) GetQuest(ctx context.Context, id string) (*Quest, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	model, err := d.store.GetQuest(dbCtx, id)
+	if err != nil {
+		return nil, d.questError("GetQuest", id, err)
+	}
+	q := toQuest(model)
+	return &q, nil
+}
+
+func (d *DefaultService) DeleteQuest(ctx context.Context, id string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.DeleteQuest(dbCtx, id); err != nil {
+		return d.questError("DeleteQuest", id, err)
+	}
+	return nil
+}
+
+func (d *DefaultService) ListQuests(ctx context.Context) ([]Quest, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	models, err := d.store.ListQuests(dbCtx)
+	if err != nil {
+		d.logger.Error("ListQuests: failed to list quests", zap.Error(err))
+		return nil, errors.New("failed to list quests:" + err.Error())
+	}
+	res := make([]Quest, 0, len(models))
+	for i := range models {
+		res = append(res, toQuest(&models[i]))
+	}
+	return res, nil
+}
+
+// PlayerQuests returns every quest with the status of the player
+func (d *DefaultService) PlayerQuests(ctx context.Context, clientID string) ([]PlayerQuest, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		d.logger.Error("PlayerQuests: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	quests, err := d.ListQuests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := d.statuses(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]PlayerQuest, 0, len(quests))
+	for _, q := range quests {
+		status, ok := statuses[q.ID]
+		if !ok {
+			status = StatusAvailable
+			if !prerequisitesMet(q.Prerequisites, statuses) {
+				status = StatusLocked
+			}
+		}
+		res = append(res, PlayerQuest{Quest: q, Status: status})
+	}
+	return res, nil
+}
+
+// Start begins the quest, an expired attempt can be started again
+func (d *DefaultService) Start(ctx context.Context, clientID, questID string) (*Progress, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		d.logger.Error("Start: failed to parse clientId", zap.String("clientID", clientID), zap.Error(err))
+		return nil, err
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	quest, err := d.store.GetQuest(dbCtx, questID)
+	if err != nil {
+		return nil, d.questError("Start", questID, err)
+	}
+	statuses, err := d.statuses(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !prerequisitesMet(quest.Prerequisites, statuses) {
+		return nil, ErrPrerequisitesMissing
+	}
+
+	now := time.Now().UTC()
+	progress := ProgressStoreModel{
+		ClientID:  id,
+		QuestID:   quest.ID,
+		Status:    StatusActive,
+		Completed: pq.StringArray{},
+		StartedAt: now,
+	}
+	if quest.TimeLimitSeconds > 0 {
+		progress.ExpiresAt = sql.NullTime{Time: now.Add(time.Duration(quest.TimeLimitSeconds) * time.Second), Valid: true}
+	}
+	err = d.store.CreateProgress(dbCtx, &progress)
+	if err == ErrProgressExists {
+		var restarted *ProgressStoreModel
+		restarted, err = d.store.UpdateProgress(dbCtx, clientID, questID, func(p *ProgressStoreModel) error {
+			expire(p, now)
+			if p.Status != StatusExpired {
+				return ErrQuestAlreadyStarted
+			}
+			*p = progress
+			return nil
+		})
+		if restarted != nil {
+			progress = *restarted
+		}
+	}
+	if err != nil {
+		if err == ErrQuestAlreadyStarted {
+			return nil, err
+		}
+		d.logger.Error("Start: failed to start quest", zap.String("clientID", clientID), zap.String("questID", questID), zap.Error(err))
+		return nil, errors.New("failed to start quest:" + err.Error())
+	}
+	return toProgress(quest, &progress), nil
+}
+
+func (d *DefaultService) Progress(ctx context.Context, clientID, questID string) (*Progress, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	quest, err := d.store.GetQuest(dbCtx, questID)
+	if err != nil {
+		return nil, d.questError("Progress", questID, err)
+	}
+	progress, err := d.store.GetProgress(dbCtx, clientID, questID)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrQuestNotStarted
+		}
+		d.logger.Error("Progress: failed to get progress", zap.String("clientID", clientID), zap.String("questID", questID), zap.Error(err))
+		return nil, errors.New("failed to get progress:" + err.Error())
+	}
+	expire(progress, time.Now())
+	return toProgress(quest, progress), nil
+}
+
+// OnCheckIn advances every active quest of the player having the location as a pending step
+func (d *DefaultService) OnCheckIn(ctx context.Context, checkIn checkins.CheckIn) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	progresses, err := d.store.ListProgress(dbCtx, checkIn.ClientID)
+	if err != nil {
+		return err
+	}
+	for _, p := range progresses {
+		if p.Status != StatusActive {
+			continue
+		}
+		quest, err := d.store.GetQuest(dbCtx, p.QuestID.String())
+		if err != nil {
+			d.logger.Error("OnCheckIn: failed to get quest", zap.String("questID", p.QuestID.String()), zap.Error(err))
+			continue
+		}
+		completed := false
+		_, err = d.store.UpdateProgress(dbCtx, checkIn.ClientID, p.QuestID.String(), func(p *ProgressStoreModel) error {
+			completed = advance(quest, p, checkIn.LocationID, checkIn.CreatedAt)
+			return nil
+		})
+		if err != nil {
+			d.logger.Error("OnCheckIn: failed to update progress", zap.String("questID", p.QuestID.String()), zap.Error(err))
+			continue
+		}
+		if completed {
+			d.logger.Info("OnCheckIn: quest completed", zap.String("clientID", checkIn.ClientID), zap.String("questID", p.QuestID.String()))
+			if d.rewards != nil && !quest.Reward.Empty() {
+				if err := d.rewards.GrantReward(ctx, checkIn.ClientID, quest.Reward); err != nil {
+					d.logger.Error("OnCheckIn: failed to grant reward", zap.String("clientID", checkIn.ClientID), zap.String("questID", p.QuestID.String()), zap.Error(err))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// advance marks the step of the location as done, it returns true when this completed the quest
+func advance(quest *QuestStoreModel, p *ProgressStoreModel, locationID string, at time.Time) bool {
+	expire(p, at)
+	if p.Status != StatusActive {
+		return false
+	}
+	done := make(map[string]bool, len(p.Completed))
+	for _, s := range p.Completed {
+		done[s] = true
+	}
+	matched := false
+	for _, step := range quest.Steps {
+		if done[step] {
+			continue
+		}
+		if step == locationID {
+			matched = true
+			break
+		}
+		if quest.Ordered {
+			// only the next step counts
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	p.Completed = append(p.Completed, locationID)
+	if len(p.Completed) < len(quest.Steps) {
+		return false
+	}
+	p.Status = StatusCompleted
+	p.CompletedAt = sql.NullTime{Time: at, Valid: true}
+	return true
+}
+
+// expire marks an active progress past its time limit as expired
+func expire(p *ProgressStoreModel, now time.Time) {
+	if p.Status == StatusActive && p.ExpiresAt.Valid && now.After(p.ExpiresAt.Time) {
+		p.Status = StatusExpired
+	}
+}
+
+// apply validates the payload and copies it to the model, every step and prerequisite has to exist
+func (d *DefaultService) apply(ctx context.Context, model *QuestStoreModel, payload QuestPayload) error {
+	if strings.TrimSpace(payload.Title) == "" {
+		return invalid("empty title")
+	}
+	if len(payload.Steps) == 0 {
+		return invalid("a quest needs at least one step")
+	}
+	if payload.TimeLimitSeconds < 0 {
+		return invalid("negative time limit")
+	}
+	seen := make(map[string]bool, len(payload.Steps))
+	for _, step := range payload.Steps {
+		if seen[step] {
+			return invalid("duplicate step " + step)
+		}
+		seen[step] = true
+		if _, err := d.locations.Get(ctx, step); err != nil {
+			if errors.Is(err, locations.ErrNotFound) {
+				return invalid("unknown location " + step)
+			}
+			return errors.New("failed to validate quest:" + err.Error())
+		}
+	}
+	for _, pre := range payload.Prerequisites {
+		if pre == model.ID.String() {
+			return invalid("a quest can not require itself")
+		}
+		if _, err := d.GetQuest(ctx, pre); err != nil {
+			if err == ErrQuestNotFound {
+				return invalid("unknown prerequisite " + pre)
+			}
+			return err
+		}
+	}
+	for _, item := range payload.Reward.Items {
+		if item.ItemID == "" || item.Quantity <= 0 {
+			return invalid("reward items need an id and a positive quantity")
+		}
+	}
+	model.Title = payload.Title
+	model.Description = payload.Description
+	model.Ordered = payload.Ordered
+	model.Steps = pq.StringArray(payload.Steps)
+	model.TimeLimitSeconds = payload.TimeLimitSeconds
+	model.Prerequisites = pq.StringArray(payload.Prerequisites)
+	if model.Prerequisites == nil {
+		model.Prerequisites = pq.StringArray{}
+	}
+	model.Reward = payload.Reward
+	return nil
+}
+
+// statuses returns the stored status of every quest the player started
+func (d *DefaultService) statuses(ctx context.Context, clientID string) (map[string]ProgressStatus, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	progresses, err := d.store.ListProgress(dbCtx, clientID)
+	if err != nil {
+		d.logger.Error("statuses: failed to list progress", zap.String("clientID", clientID), zap.Error(err))
+		return nil, errors.New("failed to list progress:" + err.Error())
+	}
+	now := time.Now()
+	res := make(map[string]ProgressStatus, len(progresses))
+	for i := range progresses {
+		expire(&progresses[i], now)
+		res[progresses[i].QuestID.String()] = progresses[i].Status
+	}
+	return res, nil
+}
+
+func (d *DefaultService) questError(method, id string, err error) error {
+	if err == ErrNotFound {
+		return ErrQuestNotFound
+	}
+	d.logger.Error(method+": failed to access quest", zap.String("questID", id), zap.Error(err))
+	return errors.New("failed to access quest:" + err.Error())
+}
+
+func prerequisitesMet(prerequisites []string, statuses map[string]ProgressStatus) bool {
+	for _, pre := range prerequisites {
+		if statuses[pre] != StatusCompleted {
+			return false
+		}
+	}
+	return true
+}
+
+func toProgress(quest *QuestStoreModel, p *ProgressStoreModel) *Progress {
+	done := make(map[string]bool, len(p.Completed))
+	for _, s := range p.Completed {
+		done[s] = true
+	}
+	res := &Progress{
+		QuestID:   quest.ID.String(),
+		Title:     quest.Title,
+		Status:    p.Status,
+		Ordered:   quest.Ordered,
+		Steps:     make([]Step, 0, len(quest.Steps)),
+		StartedAt: p.StartedAt,
+	}
+	for _, step := range quest.Steps {
+		res.Steps = append(res.Steps, Step{LocationID: step, Done: done[step]})
+		if quest.Ordered && res.NextStep == "" && !done[step] && p.Status == StatusActive {
+			res.NextStep = step
+		}
+	}
+	if p.ExpiresAt.Valid {
+		expiresAt := p.ExpiresAt.Time
+		res.ExpiresAt = &expiresAt
+	}
+	if p.CompletedAt.Valid {
+		completedAt := p.CompletedAt.Time
+		res.CompletedAt = &completedAt
+	}
+	return res
+}
+
+func invalid(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidQuest, reason)
+}
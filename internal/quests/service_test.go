@@ -0,0 +1,168 @@
+package quests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/checkins"
+	"geogame/internal/locations"
+)
+
+type rewardFunc func(clientID string, reward Reward) error
+
+func (f rewardFunc) GrantReward(ctx context.Context, clientID string, reward Reward) error {
+	return f(clientID, reward)
+}
+
+func newTestLocations(t *testing.T, ids ...string) *locations.DefaultService {
+	locSvc := locations.NewDefaultService(zap.NewNop(), locations.NewMemStore(make(map[interface{}]locations.LocationStoreModel)))
+	for _, id := range ids {
+		assert.Nil(t, locSvc.Create(context.TODO(), locations.Location{
+			ID:       id,
+			GeoPoint: locations.GeoPoint{Longitude: 18.0686, Latitude: 59.3293},
+			MetaData: locations.MetaData{LocationName: id, LocationType: "landmark"},
+		}))
+	}
+	return locSvc
+}
+
+func checkIn(clientID, locationID string) checkins.CheckIn {
+	return checkins.CheckIn{ClientID: clientID, LocationID: locationID, CreatedAt: time.Now().UTC()}
+}
+
+func TestDefaultService_CreateQuest(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), newTestLocations(t, "a", "b"), time.Second)
+	first, err := d.CreateQuest(context.TODO(), QuestPayload{Title: "first", Steps: []string{"a"}})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		payload QuestPayload
+		wantErr bool
+	}{
+		{name: "valid", payload: QuestPayload{Title: "tour", Steps: []string{"a", "b"}, Prerequisites: []string{first.ID}}},
+		{name: "empty title", payload: QuestPayload{Steps: []string{"a"}}, wantErr: true},
+		{name: "no steps", payload: QuestPayload{Title: "tour"}, wantErr: true},
+		{name: "duplicate step", payload: QuestPayload{Title: "tour", Steps: []string{"a", "a"}}, wantErr: true},
+		{name: "unknown location", payload: QuestPayload{Title: "tour", Steps: []string{"a", "c"}}, wantErr: true},
+		{name: "unknown prerequisite", payload: QuestPayload{Title: "tour", Steps: []string{"a"}, Prerequisites: []string{uuid.New().String()}}, wantErr: true},
+		{name: "negative time limit", payload: QuestPayload{Title: "tour", Steps: []string{"a"}, TimeLimitSeconds: -1}, wantErr: true},
+		{name: "invalid reward", payload: QuestPayload{Title: "tour", Steps: []string{"a"}, Reward: Reward{Items: []RewardItem{{ItemID: "coin"}}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.CreateQuest(context.TODO(), tt.payload)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+
+	_, err = d.UpdateQuest(context.TODO(), QuestPayload{ID: first.ID, Title: "first", Steps: []string{"a"}, Prerequisites: []string{first.ID}})
+	assert.Error(t, err)
+	_, err = d.UpdateQuest(context.TODO(), QuestPayload{ID: uuid.New().String(), Title: "first", Steps: []string{"a"}})
+	assert.Equal(t, ErrQuestNotFound, err)
+}
+
+func TestDefaultService_OrderedQuest(t *testing.T) {
+	var granted []Reward
+	rewards := rewardFunc(func(clientID string, reward Reward) error {
+		granted = append(granted, reward)
+		return nil
+	})
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), newTestLocations(t, "a", "b", "c"), time.Second, WithRewardSink(rewards))
+	intro, err := d.CreateQuest(context.TODO(), QuestPayload{Title: "intro", Steps: []string{"c"}})
+	assert.Nil(t, err)
+	tour, err := d.CreateQuest(context.TODO(), QuestPayload{
+		Title:         "tour",
+		Ordered:       true,
+		Steps:         []string{"a", "b"},
+		Prerequisites: []string{intro.ID},
+		Reward:        Reward{XP: 50},
+	})
+	assert.Nil(t, err)
+	clientID := uuid.New().String()
+
+	_, err = d.Start(context.TODO(), clientID, tour.ID)
+	assert.Equal(t, ErrPrerequisitesMissing, err)
+	list, err := d.PlayerQuests(context.TODO(), clientID)
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	_, err = d.Start(context.TODO(), clientID, intro.ID)
+	assert.Nil(t, err)
+	_, err = d.Start(context.TODO(), clientID, intro.ID)
+	assert.Equal(t, ErrQuestAlreadyStarted, err)
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "c")))
+
+	p, err := d.Start(context.TODO(), clientID, tour.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", p.NextStep)
+
+	// the second step does not count before the first one
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "b")))
+	p, err = d.Progress(context.TODO(), clientID, tour.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusActive, p.Status)
+	assert.False(t, p.Steps[1].Done)
+
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "a")))
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "b")))
+	p, err = d.Progress(context.TODO(), clientID, tour.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCompleted, p.Status)
+	assert.NotNil(t, p.CompletedAt)
+	assert.Equal(t, []Reward{{XP: 50}}, granted)
+}
+
+func TestDefaultService_UnorderedQuest(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), newTestLocations(t, "a", "b"), time.Second)
+	q, err := d.CreateQuest(context.TODO(), QuestPayload{Title: "hunt", Steps: []string{"a", "b"}})
+	assert.Nil(t, err)
+	clientID := uuid.New().String()
+
+	_, err = d.Progress(context.TODO(), clientID, q.ID)
+	assert.Equal(t, ErrQuestNotStarted, err)
+	_, err = d.Start(context.TODO(), clientID, q.ID)
+	assert.Nil(t, err)
+
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "b")))
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "a")))
+	p, err := d.Progress(context.TODO(), clientID, q.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCompleted, p.Status)
+}
+
+func TestDefaultService_TimeLimit(t *testing.T) {
+	store := NewMemStore()
+	d := NewDefaultService(zap.NewNop(), store, newTestLocations(t, "a", "b"), time.Second)
+	q, err := d.CreateQuest(context.TODO(), QuestPayload{Title: "race", Steps: []string{"a", "b"}, TimeLimitSeconds: 60})
+	assert.Nil(t, err)
+	clientID := uuid.New().String()
+	p, err := d.Start(context.TODO(), clientID, q.ID)
+	assert.Nil(t, err)
+	assert.NotNil(t, p.ExpiresAt)
+
+	_, err = store.UpdateProgress(context.TODO(), clientID, q.ID, func(p *ProgressStoreModel) error {
+		p.ExpiresAt.Time = time.Now().Add(-time.Second)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, d.OnCheckIn(context.TODO(), checkIn(clientID, "a")))
+	p, err = d.Progress(context.TODO(), clientID, q.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusExpired, p.Status)
+	assert.False(t, p.Steps[0].Done)
+
+	// an expired quest can be started again
+	p, err = d.Start(context.TODO(), clientID, q.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusActive, p.Status)
+}
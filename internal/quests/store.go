@@ -0,0 +1,29 @@
+package quests
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNotFound is returned by the stores when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrProgressExists is returned when the player already started the quest
+	ErrProgressExists = errors.New("quest already started")
+)
+
+// ProgressFunc changes the progress while it is locked
+type ProgressFunc func(p *ProgressStoreModel) error
+
+type Store interface {
+	CreateQuest(ctx context.Context, model *QuestStoreModel) error
+	UpdateQuest(ctx context.Context, model *QuestStoreModel) error
+	GetQuest(ctx context.Context, id string) (*QuestStoreModel, error)
+	DeleteQuest(ctx context.Context, id string) error
+	ListQuests(ctx context.Context) ([]QuestStoreModel, error)
+	CreateProgress(ctx context.Context, model *ProgressStoreModel) error
+	GetProgress(ctx context.Context, clientID, questID string) (*ProgressStoreModel, error)
+	ListProgress(ctx context.Context, clientID string) ([]ProgressStoreModel, error)
+	// UpdateProgress runs fn on the locked progress, concurrent updates of the same progress are serialized
+	UpdateProgress(ctx context.Context, clientID, questID string, fn ProgressFunc) (*ProgressStoreModel, error)
+}
@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// conn is a websocket connection of a player, only the write pump writes to the socket
+type conn struct {
+	clientID  string
+	ws        *websocket.Conn
+	send      chan []byte
+	expiresAt time.Time
+
+	closeOnce sync.Once
+	// done is closed once the connection is closing, closeCode and closeText are the reason sent to the client
+	done      chan struct{}
+	closeCode int
+	closeText string
+	// readDone is closed when the read pump returned
+	readDone chan struct{}
+}
+
+func newConn(clientID string, ws *websocket.Conn, buffer int, expiresAt time.Time) *conn {
+	return &conn{
+		clientID:  clientID,
+		ws:        ws,
+		send:      make(chan []byte, buffer),
+		expiresAt: expiresAt,
+		done:      make(chan struct{}),
+		readDone:  make(chan struct{}),
+	}
+}
+
+// close asks the write pump to send the close frame and hang up, only the first reason is kept
+func (c *conn) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		close(c.done)
+	})
+}
+
+// enqueue hands the frame to the write pump, false when the connection is closing or its buffer is full
+func (c *conn) enqueue(data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		// the client does not keep up, dropping it is cheaper than buffering without bound
+		c.close(websocket.CloseTryAgainLater, "too slow")
+		return false
+	}
+}
+
+// writePump writes the queued frames and the pings until the connection closes
+func (c *conn) writePump(config Config) {
+	ticker := time.NewTicker(config.PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.ws.Close()
+	}()
+	var expired <-chan time.Time
+	if !c.expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case data := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(config.WriteTimeout)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-expired:
+			// the client reconnects with a refreshed token
+			c.close(websocket.ClosePolicyViolation, "token expired")
+		case <-c.done:
+			msg := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+			if err := c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(config.WriteTimeout)); err == nil {
+				// give the client the chance to answer the close frame
+				select {
+				case <-c.readDone:
+				case <-time.After(config.WriteTimeout):
+				}
+			}
+			return
+		}
+	}
+}
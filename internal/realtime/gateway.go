@@ -0,0 +1,381 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"go.uber.org/zap"
+
+	"geogame/internal/encounters"
+	"geogame/internal/geofences"
+	"geogame/internal/items"
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+// message types, position and the chat follows are sent by the clients, the others by the server
+const (
+	TypePosition     = "position"
+	TypeChatFollow   = "chat.follow"
+	TypeChatUnfollow = "chat.unfollow"
+	TypeSpawn        = "spawn"
+	TypeEncounter    = "encounter"
+	TypeGeofence     = "geofence"
+	TypeChat         = "chat"
+	TypeError        = "error"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Message is the envelope of every frame in both directions
+type Message struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// ErrorData is sent back when a message of the client failed
+type ErrorData struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// HandlerFunc handles a message type sent by the clients
+type HandlerFunc func(ctx context.Context, clientID string, data json.RawMessage) error
+
+// PositionSink stores the positions streamed by the clients
+type PositionSink interface {
+	UpdateLocation(ctx context.Context, payload locations.Location, clientID string) error
+}
+
+// RoomFollower subscribes the clients to the messages of the chat rooms
+type RoomFollower interface {
+	Follow(ctx context.Context, clientID, locationID string) error
+	Unfollow(ctx context.Context, clientID, locationID string) error
+}
+
+// RoomPayload names the chat room of a follow message
+type RoomPayload struct {
+	LocationID string `json:"locationId"`
+}
+
+type Config struct {
+	// SendBuffer is the number of frames queued per connection, a client falling further behind is dropped
+	SendBuffer   int           `env:"WS_SEND_BUFFER" envDefault:"64"`
+	PingInterval time.Duration `env:"WS_PING_INTERVAL" envDefault:"30s"`
+	// PongTimeout closes a connection without any frame or pong for that long
+	PongTimeout  time.Duration `env:"WS_PONG_TIMEOUT" envDefault:"60s"`
+	WriteTimeout time.Duration `env:"WS_WRITE_TIMEOUT" envDefault:"10s"`
+	// ReadLimit is the maximum size in bytes of a client frame
+	ReadLimit int64 `env:"WS_READ_LIMIT" envDefault:"4096"`
+	// SpawnRadius in meters around the player of the pushed item spawns
+	SpawnRadius float64 `env:"WS_SPAWN_RADIUS" envDefault:"500"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SendBuffer:   64,
+		PingInterval: 30 * time.Second,
+		PongTimeout:  60 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ReadLimit:    4096,
+		SpawnRadius:  500,
+	}
+}
+
+var (
+	_ players.LocationListener = (*Gateway)(nil)
+	_ encounters.Listener      = (*Gateway)(nil)
+	_ geofences.Subscriber     = (*Gateway)(nil)
+	_ items.SpawnListener      = (*Gateway)(nil)
+)
+
+// Gateway keeps the websocket connections of the players and routes the server events to them
+type Gateway struct {
+	logger   *zap.Logger
+	config   Config
+	upgrader websocket.Upgrader
+	handlers map[string]HandlerFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu      sync.RWMutex
+	closing bool
+	conns   map[string]map[*conn]struct{}
+	// positions of the connected players, the spawns are pushed around them
+	positions map[string]orb.Point
+}
+
+func NewGateway(logger *zap.Logger, config Config) *Gateway {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &Gateway{
+		logger:    logger,
+		config:    config,
+		handlers:  make(map[string]HandlerFunc),
+		ctx:       ctx,
+		cancel:    cancel,
+		conns:     make(map[string]map[*conn]struct{}),
+		positions: make(map[string]orb.Point),
+	}
+	return g
+}
+
+// Handle registers the handler of a client message type, handlers are registered before the gateway serves
+func (g *Gateway) Handle(messageType string, fn HandlerFunc) {
+	g.handlers[messageType] = fn
+}
+
+// PositionHandler stores the positions streamed by the clients like POST /client/loc/send
+func PositionHandler(positions PositionSink) HandlerFunc {
+	return func(ctx context.Context, clientID string, data json.RawMessage) error {
+		var payload locations.Location
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		return positions.UpdateLocation(ctx, payload, clientID)
+	}
+}
+
+// FollowHandler subscribes the client to a chat room, the messages arrive as chat messages
+func FollowHandler(rooms RoomFollower) HandlerFunc {
+	return func(ctx context.Context, clientID string, data json.RawMessage) error {
+		var payload RoomPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		return rooms.Follow(ctx, clientID, payload.LocationID)
+	}
+}
+
+// UnfollowHandler unsubscribes the client from a chat room
+func UnfollowHandler(rooms RoomFollower) HandlerFunc {
+	return func(ctx context.Context, clientID string, data json.RawMessage) error {
+		var payload RoomPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		return rooms.Unfollow(ctx, clientID, payload.LocationID)
+	}
+}
+
+// Serve upgrades the request and serves the connection until it closes, expiresAt is the expiry of the access token
+func (g *Gateway) Serve(w http.ResponseWriter, r *http.Request, clientID string, expiresAt time.Time) error {
+	g.mu.RLock()
+	closing := g.closing
+	g.mu.RUnlock()
+	if closing {
+		return ErrShuttingDown
+	}
+	ws, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader answered the request already
+		g.logger.Debug("Serve: failed to upgrade", zap.String("clientID", clientID), zap.Error(err))
+		return nil
+	}
+	c := newConn(clientID, ws, g.config.SendBuffer, expiresAt)
+	if !g.register(c) {
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, ErrShuttingDown.Error())
+		ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(g.config.WriteTimeout))
+		ws.Close()
+		return nil
+	}
+	defer g.unregister(c)
+
+	go c.writePump(g.config)
+	g.readPump(c)
+	return nil
+}
+
+func (g *Gateway) register(c *conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing {
+		return false
+	}
+	if _, ok := g.conns[c.clientID]; !ok {
+		g.conns[c.clientID] = make(map[*conn]struct{})
+	}
+	g.conns[c.clientID][c] = struct{}{}
+	g.wg.Add(1)
+	return true
+}
+
+func (g *Gateway) unregister(c *conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.conns[c.clientID], c)
+	if len(g.conns[c.clientID]) == 0 {
+		delete(g.conns, c.clientID)
+		delete(g.positions, c.clientID)
+	}
+	g.wg.Done()
+}
+
+// readPump handles the client frames, a message is handled before the next one is read so a fast client is slowed down by the socket
+func (g *Gateway) readPump(c *conn) {
+	defer func() {
+		close(c.readDone)
+		c.close(websocket.CloseNormalClosure, "")
+	}()
+	c.ws.SetReadLimit(g.config.ReadLimit)
+	c.ws.SetReadDeadline(time.Now().Add(g.config.PongTimeout))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(g.config.PongTimeout))
+	})
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				g.logger.Debug("readPump: connection lost", zap.String("clientID", c.clientID), zap.Error(err))
+			}
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(g.config.PongTimeout))
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			g.reply(c, "", err)
+			continue
+		}
+		handler, ok := g.handlers[msg.Type]
+		if !ok {
+			g.reply(c, msg.Type, errors.New("unknown message type"))
+			continue
+		}
+		if err := handler(g.ctx, c.clientID, msg.Data); err != nil {
+			g.reply(c, msg.Type, err)
+		}
+	}
+}
+
+func (g *Gateway) reply(c *conn, messageType string, cause error) {
+	data, err := encode(TypeError, ErrorData{Type: messageType, Error: cause.Error()})
+	if err == nil {
+		c.enqueue(data)
+	}
+}
+
+func encode(messageType string, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{Type: messageType, Data: raw})
+}
+
+// Send queues the message on every connection of the player and returns the number of connections reached
+func (g *Gateway) Send(clientID, messageType string, data interface{}) (int, error) {
+	g.mu.RLock()
+	conns := make([]*conn, 0, len(g.conns[clientID]))
+	for c := range g.conns[clientID] {
+		conns = append(conns, c)
+	}
+	g.mu.RUnlock()
+	if len(conns) == 0 {
+		return 0, nil
+	}
+	frame, err := encode(messageType, data)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, c := range conns {
+		if c.enqueue(frame) {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Connected reports whether the player has an open connection
+func (g *Gateway) Connected(clientID string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.conns[clientID]) > 0
+}
+
+// Drain closes every connection with a going away frame and waits for them to finish, new connections are refused
+func (g *Gateway) Drain(ctx context.Context) error {
+	g.mu.Lock()
+	g.closing = true
+	conns := make([]*conn, 0)
+	for _, cs := range g.conns {
+		for c := range cs {
+			conns = append(conns, c)
+		}
+	}
+	g.mu.Unlock()
+	defer g.cancel()
+
+	for _, c := range conns {
+		c.close(websocket.CloseGoingAway, ErrShuttingDown.Error())
+	}
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, c := range conns {
+			c.ws.Close()
+		}
+		return ctx.Err()
+	}
+}
+
+// OnLocationUpdate keeps the position of the connected players
+func (g *Gateway) OnLocationUpdate(ctx context.Context, update players.LocationUpdate) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.conns[update.ClientID]) > 0 {
+		g.positions[update.ClientID] = orb.Point{update.GeoPoint.Longitude, update.GeoPoint.Latitude}
+	}
+	return nil
+}
+
+func (g *Gateway) OnEncounter(ctx context.Context, clientID string, encounter encounters.Encounter) error {
+	_, err := g.Send(clientID, TypeEncounter, encounter)
+	return err
+}
+
+func (g *Gateway) OnGeofenceEvent(ctx context.Context, event geofences.Event) error {
+	_, err := g.Send(event.ClientID, TypeGeofence, event)
+	return err
+}
+
+// OnSpawn pushes the new spawns to the connected players around them
+func (g *Gateway) OnSpawn(ctx context.Context, spawns []items.Spawn) error {
+	g.mu.RLock()
+	positions := make(map[string]orb.Point, len(g.positions))
+	for id, p := range g.positions {
+		positions[id] = p
+	}
+	g.mu.RUnlock()
+	for clientID, p := range positions {
+		nearby := make([]items.Spawn, 0)
+		for _, s := range spawns {
+			distance := geo.Distance(p, orb.Point{s.GeoPoint.Longitude, s.GeoPoint.Latitude})
+			if distance <= g.config.SpawnRadius {
+				s.Distance = math.Round(distance)
+				nearby = append(nearby, s)
+			}
+		}
+		if len(nearby) == 0 {
+			continue
+		}
+		if _, err := g.Send(clientID, TypeSpawn, nearby); err != nil {
+			return err
+		}
+	}
+	return nil
+}
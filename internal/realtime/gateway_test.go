@@ -0,0 +1,193 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"geogame/internal/geofences"
+	"geogame/internal/items"
+	"geogame/internal/locations"
+	"geogame/internal/players"
+)
+
+type positionsFunc func(payload locations.Location, clientID string) error
+
+func (f positionsFunc) UpdateLocation(ctx context.Context, payload locations.Location, clientID string) error {
+	return f(payload, clientID)
+}
+
+func newTestServer(g *Gateway) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := g.Serve(w, r, r.URL.Query().Get("id"), time.Time{}); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	}))
+}
+
+func dial(t *testing.T, server *httptest.Server, clientID string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?id=" + clientID
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	return ws
+}
+
+func read(t *testing.T, ws *websocket.Conn) Message {
+	var msg Message
+	ws.SetReadDeadline(time.Now().Add(time.Second * 2))
+	assert.Nil(t, ws.ReadJSON(&msg))
+	return msg
+}
+
+// waitConnected waits for the server side of the connection to be registered
+func waitConnected(g *Gateway, clientID string) {
+	for i := 0; i < 100 && !g.Connected(clientID); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestGateway_Upstream(t *testing.T) {
+	received := make(chan locations.Location, 1)
+	g := NewGateway(zap.NewNop(), DefaultConfig())
+	g.Handle(TypePosition, PositionHandler(positionsFunc(func(payload locations.Location, clientID string) error {
+		if payload.GeoPoint.Longitude == 0 {
+			return errors.New("missing position")
+		}
+		received <- payload
+		return nil
+	})))
+	server := newTestServer(g)
+	defer server.Close()
+	ws := dial(t, server, "a")
+	defer ws.Close()
+
+	assert.Nil(t, ws.WriteJSON(map[string]interface{}{"type": TypePosition, "data": map[string]interface{}{"geoPoint": map[string]float64{"longitude": 18.07, "latitude": 59.33}}}))
+	select {
+	case loc := <-received:
+		assert.Equal(t, 59.33, loc.GeoPoint.Latitude)
+	case <-time.After(time.Second * 2):
+		t.Fatal("position was not handled")
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		want    ErrorData
+	}{
+		{name: "handler error", message: `{"type":"position","data":{}}`, want: ErrorData{Type: TypePosition, Error: "missing position"}},
+		{name: "unknown type", message: `{"type":"jump"}`, want: ErrorData{Type: "jump", Error: "unknown message type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Nil(t, ws.WriteMessage(websocket.TextMessage, []byte(tt.message)))
+			msg := read(t, ws)
+			assert.Equal(t, TypeError, msg.Type)
+			var got ErrorData
+			assert.Nil(t, json.Unmarshal(msg.Data, &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGateway_Downstream(t *testing.T) {
+	g := NewGateway(zap.NewNop(), DefaultConfig())
+	server := newTestServer(g)
+	defer server.Close()
+	first, second, other := dial(t, server, "a"), dial(t, server, "a"), dial(t, server, "b")
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+	waitConnected(g, "a")
+	waitConnected(g, "b")
+	for i := 0; i < 100; i++ {
+		g.mu.RLock()
+		n := len(g.conns["a"])
+		g.mu.RUnlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// both connections of the player receive the event
+	assert.Nil(t, g.OnGeofenceEvent(context.TODO(), geofences.Event{ClientID: "a", Type: geofences.EventEnter}))
+	for _, ws := range []*websocket.Conn{first, second} {
+		msg := read(t, ws)
+		assert.Equal(t, TypeGeofence, msg.Type)
+	}
+	sent, err := g.Send("unknown", TypeGeofence, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, sent)
+
+	// only the players around the spawn get it
+	assert.Nil(t, g.OnLocationUpdate(context.TODO(), players.LocationUpdate{ClientID: "a", GeoPoint: locations.GeoPoint{Longitude: 18.07, Latitude: 59.33}}))
+	assert.Nil(t, g.OnLocationUpdate(context.TODO(), players.LocationUpdate{ClientID: "b", GeoPoint: locations.GeoPoint{Longitude: 19, Latitude: 60}}))
+	assert.Nil(t, g.OnSpawn(context.TODO(), []items.Spawn{{ID: "1", GeoPoint: locations.GeoPoint{Longitude: 18.0701, Latitude: 59.33}}}))
+	msg := read(t, first)
+	assert.Equal(t, TypeSpawn, msg.Type)
+	var spawns []items.Spawn
+	assert.Nil(t, json.Unmarshal(msg.Data, &spawns))
+	if assert.Len(t, spawns, 1) {
+		assert.Equal(t, float64(6), spawns[0].Distance)
+	}
+	other.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+	_, _, err = other.ReadMessage()
+	assert.Error(t, err)
+}
+
+func TestGateway_SlowClient(t *testing.T) {
+	config := DefaultConfig()
+	config.SendBuffer = 1
+	g := NewGateway(zap.NewNop(), config)
+	c := newConn("a", nil, config.SendBuffer, time.Time{})
+
+	assert.True(t, c.enqueue([]byte("1")))
+	// nobody writes, the full buffer drops the client
+	assert.False(t, c.enqueue([]byte("2")))
+	assert.Equal(t, websocket.CloseTryAgainLater, c.closeCode)
+	assert.False(t, c.enqueue([]byte("3")))
+	assert.False(t, g.Connected("a"))
+}
+
+func TestGateway_Drain(t *testing.T) {
+	g := NewGateway(zap.NewNop(), DefaultConfig())
+	server := newTestServer(g)
+	defer server.Close()
+	ws := dial(t, server, "a")
+	defer ws.Close()
+	waitConnected(g, "a")
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- g.Drain(context.TODO())
+	}()
+	ws.SetReadDeadline(time.Now().Add(time.Second * 2))
+	_, _, err := ws.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	select {
+	case err := <-drained:
+		assert.Nil(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("drain did not finish")
+	}
+	assert.False(t, g.Connected("a"))
+
+	// new connections are refused
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?id=b"
+	_, res, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Error(t, err)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	}
+}
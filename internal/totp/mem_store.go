@@ -0,0 +1,148 @@
+package totp
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemStore)(nil)
+
+type MemStore struct {
+	mu          sync.Mutex
+	enrollments map[string]*EnrollmentStoreModel
+	challenges  map[string]*ChallengeStoreModel
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		enrollments: make(map[string]*EnrollmentStoreModel),
+		challenges:  make(map[string]*ChallengeStoreModel),
+	}
+}
+
+func (m *MemStore) GetEnrollment(ctx context.Context, key string) (*EnrollmentStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.enrollments[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *e
+	c.RecoveryCodes = append(c.RecoveryCodes[:0:0], e.RecoveryCodes...)
+	return &c, nil
+}
+
+func (m *MemStore) SaveEnrollment(ctx context.Context, model *EnrollmentStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.enrollments[model.Key]; ok && e.EnabledAt.Valid {
+		return ErrEnabled
+	}
+	c := *model
+	m.enrollments[model.Key] = &c
+	return nil
+}
+
+func (m *MemStore) EnableEnrollment(ctx context.Context, key string, recoveryCodes []string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.enrollments[key]
+	if !ok {
+		return ErrNotFound
+	}
+	e.RecoveryCodes = append(e.RecoveryCodes[:0:0], recoveryCodes...)
+	e.EnabledAt = sql.NullTime{Time: at, Valid: true}
+	return nil
+}
+
+func (m *MemStore) DeleteEnrollment(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.enrollments, key)
+	return nil
+}
+
+func (m *MemStore) AdvanceStep(ctx context.Context, key string, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.enrollments[key]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if step <= e.LastStep {
+		return false, nil
+	}
+	e.LastStep = step
+	return true, nil
+}
+
+func (m *MemStore) UseRecoveryCode(ctx context.Context, key, hash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.enrollments[key]
+	if !ok {
+		return false, ErrNotFound
+	}
+	for i, h := range e.RecoveryCodes {
+		if h == hash {
+			e.RecoveryCodes = append(e.RecoveryCodes[:i], e.RecoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemStore) CreateChallenge(ctx context.Context, model *ChallengeStoreModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *model
+	m.challenges[model.Hash] = &c
+	return nil
+}
+
+func (m *MemStore) GetChallenge(ctx context.Context, hash string) (*ChallengeStoreModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, ok := m.challenges[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *ch
+	return &c, nil
+}
+
+func (m *MemStore) FailChallenge(ctx context.Context, hash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, ok := m.challenges[hash]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	ch.Attempts++
+	return ch.Attempts, nil
+}
+
+func (m *MemStore) DeleteChallenge(ctx context.Context, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.challenges[hash]; !ok {
+		return ErrNotFound
+	}
+	delete(m.challenges, hash)
+	return nil
+}
+
+func (m *MemStore) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for hash, ch := range m.challenges {
+		if ch.ExpiresAt.Before(before) {
+			delete(m.challenges, hash)
+			n++
+		}
+	}
+	return n, nil
+}
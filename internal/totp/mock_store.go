@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"context"
+	"time"
+)
+
+var _ Store = (*MockStore)(nil)
+
+type MockStore struct {
+	GetEnrollmentFunc           func(key string) (*EnrollmentStoreModel, error)
+	SaveEnrollmentFunc          func(model *EnrollmentStoreModel) error
+	EnableEnrollmentFunc        func(key string, recoveryCodes []string, at time.Time) error
+	DeleteEnrollmentFunc        func(key string) error
+	AdvanceStepFunc             func(key string, step int64) (bool, error)
+	UseRecoveryCodeFunc         func(key, hash string) (bool, error)
+	CreateChallengeFunc         func(model *ChallengeStoreModel) error
+	GetChallengeFunc            func(hash string) (*ChallengeStoreModel, error)
+	FailChallengeFunc           func(hash string) (int, error)
+	DeleteChallengeFunc         func(hash string) error
+	DeleteExpiredChallengesFunc func(before time.Time) (int64, error)
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{
+		GetEnrollmentFunc: func(key string) (*EnrollmentStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		SaveEnrollmentFunc: func(model *EnrollmentStoreModel) error {
+			return nil
+		},
+		EnableEnrollmentFunc: func(key string, recoveryCodes []string, at time.Time) error {
+			return nil
+		},
+		DeleteEnrollmentFunc: func(key string) error {
+			return nil
+		},
+		AdvanceStepFunc: func(key string, step int64) (bool, error) {
+			return true, nil
+		},
+		UseRecoveryCodeFunc: func(key, hash string) (bool, error) {
+			return false, nil
+		},
+		CreateChallengeFunc: func(model *ChallengeStoreModel) error {
+			return nil
+		},
+		GetChallengeFunc: func(hash string) (*ChallengeStoreModel, error) {
+			return nil, ErrNotFound
+		},
+		FailChallengeFunc: func(hash string) (int, error) {
+			return 1, nil
+		},
+		DeleteChallengeFunc: func(hash string) error {
+			return nil
+		},
+		DeleteExpiredChallengesFunc: func(before time.Time) (int64, error) {
+			return 0, nil
+		},
+	}
+}
+
+func (m *MockStore) GetEnrollment(ctx context.Context, key string) (*EnrollmentStoreModel, error) {
+	return m.GetEnrollmentFunc(key)
+}
+
+func (m *MockStore) SaveEnrollment(ctx context.Context, model *EnrollmentStoreModel) error {
+	return m.SaveEnrollmentFunc(model)
+}
+
+func (m *MockStore) EnableEnrollment(ctx context.Context, key string, recoveryCodes []string, at time.Time) error {
+	return m.EnableEnrollmentFunc(key, recoveryCodes, at)
+}
+
+func (m *MockStore) DeleteEnrollment(ctx context.Context, key string) error {
+	return m.DeleteEnrollmentFunc(key)
+}
+
+func (m *MockStore) AdvanceStep(ctx context.Context, key string, step int64) (bool, error) {
+	return m.AdvanceStepFunc(key, step)
+}
+
+func (m *MockStore) UseRecoveryCode(ctx context.Context, key, hash string) (bool, error) {
+	return m.UseRecoveryCodeFunc(key, hash)
+}
+
+func (m *MockStore) CreateChallenge(ctx context.Context, model *ChallengeStoreModel) error {
+	return m.CreateChallengeFunc(model)
+}
+
+func (m *MockStore) GetChallenge(ctx context.Context, hash string) (*ChallengeStoreModel, error) {
+	return m.GetChallengeFunc(hash)
+}
+
+func (m *MockStore) FailChallenge(ctx context.Context, hash string) (int, error) {
+	return m.FailChallengeFunc(hash)
+}
+
+func (m *MockStore) DeleteChallenge(ctx context.Context, hash string) error {
+	return m.DeleteChallengeFunc(hash)
+}
+
+func (m *MockStore) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error) {
+	return m.DeleteExpiredChallengesFunc(before)
+}
@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	clientPrefix = "client:"
+	adminPrefix  = "admin:"
+)
+
+// ClientKey is the enrollment key of a player
+func ClientKey(clientID string) string {
+	return clientPrefix + clientID
+}
+
+// AdminKey is the enrollment key of an admin
+func AdminKey(adminID string) string {
+	return adminPrefix + adminID
+}
+
+// ClientIDOf returns the player of a key
+func ClientIDOf(key string) (string, bool) {
+	if !strings.HasPrefix(key, clientPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, clientPrefix), true
+}
+
+// AdminIDOf returns the admin of a key
+func AdminIDOf(key string) (string, bool) {
+	if !strings.HasPrefix(key, adminPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, adminPrefix), true
+}
+
+// EnrollmentStoreModel is the second factor of an account, it is enabled once a first code was confirmed
+type EnrollmentStoreModel struct {
+	Key    string `db:"key"`
+	Secret string `db:"secret"`
+	// RecoveryCodes are the hashes of the unused recovery codes
+	RecoveryCodes pq.StringArray `db:"recovery_codes"`
+	// LastStep is the step of the last accepted code, a code is never accepted twice
+	LastStep  int64        `db:"last_step"`
+	EnabledAt sql.NullTime `db:"enabled_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+// ChallengeStoreModel is a login waiting for the second factor, only the hash of the token is kept
+type ChallengeStoreModel struct {
+	Hash      string    `db:"hash"`
+	Key       string    `db:"key"`
+	Attempts  int       `db:"attempts"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// Enrollment is shown once to the owner to set up the authenticator app
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodes are shown once, each one replaces a code when the authenticator is lost
+type RecoveryCodes struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
+// ChallengeResult is the account that passed the challenge,
+// RecoveryCodes is set when the challenge completed an enrollment
+type ChallengeResult struct {
+	Key           string
+	RecoveryCodes []string
+}
@@ -0,0 +1,200 @@
+package totp
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Postgres)(nil)
+
+const (
+	enrollmentsAllCols = "key, secret, recovery_codes, last_step, enabled_at, created_at"
+	enrollmentsTable   = "totp_enrollments"
+	challengesAllCols  = "hash, key, attempts, created_at, expires_at"
+	challengesTable    = "totp_challenges"
+)
+
+// Postgres holds the Postgres repository.
+type Postgres struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewPostgres instantiates a new PostgreSQL repository.
+func NewPostgres(db *sqlx.DB, logger *zap.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger.Named("geo-game.totp.store"),
+	}
+}
+
+func (p Postgres) GetEnrollment(ctx context.Context, key string) (*EnrollmentStoreModel, error) {
+	stmt := "SELECT " + enrollmentsAllCols + " FROM " + enrollmentsTable + " WHERE key=$1"
+	var res EnrollmentStoreModel
+	if err := p.db.GetContext(ctx, &res, stmt, key); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetEnrollment: failed to get enrollment from db", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p Postgres) SaveEnrollment(ctx context.Context, model *EnrollmentStoreModel) error {
+	stmt := `INSERT INTO totp_enrollments (
+	key,
+	secret,
+	recovery_codes,
+	last_step,
+	created_at
+	) VALUES (
+	:key,
+	:secret,
+	:recovery_codes,
+	:last_step,
+	:created_at
+	) ON CONFLICT (key) DO UPDATE SET
+	secret=EXCLUDED.secret,
+	recovery_codes=EXCLUDED.recovery_codes,
+	last_step=EXCLUDED.last_step,
+	created_at=EXCLUDED.created_at
+	WHERE totp_enrollments.enabled_at IS NULL`
+	if model.RecoveryCodes == nil {
+		model.RecoveryCodes = pq.StringArray{}
+	}
+	res, err := p.db.NamedExecContext(ctx, stmt, model)
+	if err != nil {
+		p.logger.Error("SaveEnrollment: failed to save enrollment to db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrEnabled
+	}
+	return nil
+}
+
+func (p Postgres) EnableEnrollment(ctx context.Context, key string, recoveryCodes []string, at time.Time) error {
+	stmt := "UPDATE " + enrollmentsTable + " SET recovery_codes=$2, enabled_at=$3 WHERE key=$1"
+	res, err := p.db.ExecContext(ctx, stmt, key, pq.StringArray(recoveryCodes), at)
+	if err != nil {
+		p.logger.Error("EnableEnrollment: failed to update enrollment in db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) DeleteEnrollment(ctx context.Context, key string) error {
+	stmt := "DELETE FROM " + enrollmentsTable + " WHERE key=$1"
+	if _, err := p.db.ExecContext(ctx, stmt, key); err != nil {
+		p.logger.Error("DeleteEnrollment: failed to delete enrollment from db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) AdvanceStep(ctx context.Context, key string, step int64) (bool, error) {
+	stmt := "UPDATE " + enrollmentsTable + " SET last_step=$2 WHERE key=$1 AND last_step < $2"
+	res, err := p.db.ExecContext(ctx, stmt, key, step)
+	if err != nil {
+		p.logger.Error("AdvanceStep: failed to update enrollment in db", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (p Postgres) UseRecoveryCode(ctx context.Context, key, hash string) (bool, error) {
+	stmt := "UPDATE " + enrollmentsTable + " SET recovery_codes=array_remove(recovery_codes, $2) WHERE key=$1 AND $2=ANY(recovery_codes)"
+	res, err := p.db.ExecContext(ctx, stmt, key, hash)
+	if err != nil {
+		p.logger.Error("UseRecoveryCode: failed to update enrollment in db", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (p Postgres) CreateChallenge(ctx context.Context, model *ChallengeStoreModel) error {
+	stmt := `INSERT INTO totp_challenges (
+	hash,
+	key,
+	attempts,
+	created_at,
+	expires_at
+	) VALUES (
+	:hash,
+	:key,
+	:attempts,
+	:created_at,
+	:expires_at
+	)`
+	if _, err := p.db.NamedExecContext(ctx, stmt, model); err != nil {
+		p.logger.Error("CreateChallenge: failed to insert challenge to db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p Postgres) GetChallenge(ctx context.Context, hash string) (*ChallengeStoreModel, error) {
+	stmt := "SELECT " + challengesAllCols + " FROM " + challengesTable + " WHERE hash=$1"
+	var res ChallengeStoreModel
+	if err := p.db.GetContext(ctx, &res, stmt, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		p.logger.Error("GetChallenge: failed to get challenge from db", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p Postgres) FailChallenge(ctx context.Context, hash string) (int, error) {
+	stmt := "UPDATE " + challengesTable + " SET attempts=attempts+1 WHERE hash=$1 RETURNING attempts"
+	var attempts int
+	if err := p.db.GetContext(ctx, &attempts, stmt, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotFound
+		}
+		p.logger.Error("FailChallenge: failed to update challenge in db", zap.Error(err))
+		return 0, err
+	}
+	return attempts, nil
+}
+
+func (p Postgres) DeleteChallenge(ctx context.Context, hash string) error {
+	stmt := "DELETE FROM " + challengesTable + " WHERE hash=$1"
+	res, err := p.db.ExecContext(ctx, stmt, hash)
+	if err != nil {
+		p.logger.Error("DeleteChallenge: failed to delete challenge from db", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p Postgres) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error) {
+	stmt := "DELETE FROM " + challengesTable + " WHERE expires_at < $1"
+	res, err := p.db.ExecContext(ctx, stmt, before)
+	if err != nil {
+		p.logger.Error("DeleteExpiredChallenges: failed to delete challenges from db", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
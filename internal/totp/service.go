@@ -0,0 +1,351 @@
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"geogame/pkg"
+)
+
+var (
+	ErrInvalidCode      = errors.New("invalid two-factor code")
+	ErrInvalidChallenge = errors.New("invalid or expired two-factor challenge")
+	ErrAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrNotEnrolled      = errors.New("two-factor authentication is not enabled")
+)
+
+type Service interface {
+	// Enroll creates a new secret for the key, the second factor is enabled by Confirm
+	Enroll(ctx context.Context, key, account string) (*Enrollment, error)
+	// Confirm enables the second factor with a first code and returns the recovery codes
+	Confirm(ctx context.Context, key, code string) (*RecoveryCodes, error)
+	// Disable removes the second factor, it needs a code or a recovery code
+	Disable(ctx context.Context, key, code string) error
+	Enabled(ctx context.Context, key string) (bool, error)
+	// NewChallenge returns the token a login exchanges for the session once the code is verified
+	NewChallenge(ctx context.Context, key string) (string, error)
+	// ChallengeKey returns the key of a pending challenge so the caller can throttle the owner
+	ChallengeKey(ctx context.Context, token string) (string, error)
+	// CompleteChallenge verifies the code of the challenge, a pending enrollment is confirmed by it
+	CompleteChallenge(ctx context.Context, token, code string) (*ChallengeResult, error)
+	Cleanup(ctx context.Context) error
+}
+
+type Config struct {
+	// Issuer names the game in the authenticator apps
+	Issuer string `env:"TOTP_ISSUER" envDefault:"GeoGame"`
+	// Skew is the number of steps accepted before and after the current one
+	Skew          int           `env:"TOTP_SKEW" envDefault:"1"`
+	ChallengeTTL  time.Duration `env:"TOTP_CHALLENGE_TTL" envDefault:"5m"`
+	MaxAttempts   int           `env:"TOTP_MAX_ATTEMPTS" envDefault:"5"`
+	RecoveryCodes int           `env:"TOTP_RECOVERY_CODES" envDefault:"10"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Issuer:        "GeoGame",
+		Skew:          1,
+		ChallengeTTL:  time.Minute * 5,
+		MaxAttempts:   5,
+		RecoveryCodes: 10,
+	}
+}
+
+var _ Service = (*DefaultService)(nil)
+
+type DefaultService struct {
+	logger    *zap.Logger
+	store     Store
+	config    Config
+	dbTimeOut time.Duration
+}
+
+func NewDefaultService(logger *zap.Logger, store Store, config Config, dbTimeOut time.Duration) *DefaultService {
+	return &DefaultService{
+		logger:    logger,
+		store:     store,
+		config:    config,
+		dbTimeOut: dbTimeOut,
+	}
+}
+
+func (d *DefaultService) Enroll(ctx context.Context, key, account string) (*Enrollment, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return nil, errors.New("failed to enroll:" + err.Error())
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	model := EnrollmentStoreModel{
+		Key:       key,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.SaveEnrollment(dbCtx, &model); err != nil {
+		if err == ErrEnabled {
+			return nil, ErrAlreadyEnabled
+		}
+		d.logger.Error("Enroll: failed to save enrollment", zap.String("key", key), zap.Error(err))
+		return nil, errors.New("failed to enroll:" + err.Error())
+	}
+	return &Enrollment{
+		Secret: secret,
+		URI:    URI(d.config.Issuer, account, secret),
+	}, nil
+}
+
+func (d *DefaultService) Confirm(ctx context.Context, key, code string) (*RecoveryCodes, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	enrollment, err := d.getEnrollment(dbCtx, key)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.EnabledAt.Valid {
+		return nil, ErrAlreadyEnabled
+	}
+	codes, err := d.confirm(dbCtx, enrollment, code)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodes{Codes: codes}, nil
+}
+
+func (d *DefaultService) Disable(ctx context.Context, key, code string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	enrollment, err := d.getEnrollment(dbCtx, key)
+	if err != nil {
+		return err
+	}
+	if !enrollment.EnabledAt.Valid {
+		return ErrNotEnrolled
+	}
+	if err := d.verify(dbCtx, enrollment, code); err != nil {
+		return err
+	}
+	if err := d.store.DeleteEnrollment(dbCtx, key); err != nil {
+		d.logger.Error("Disable: failed to delete enrollment", zap.String("key", key), zap.Error(err))
+		return errors.New("failed to disable two-factor authentication:" + err.Error())
+	}
+	d.logger.Info("Disable: two-factor authentication disabled", zap.String("key", key))
+	return nil
+}
+
+func (d *DefaultService) Enabled(ctx context.Context, key string) (bool, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	enrollment, err := d.store.GetEnrollment(dbCtx, key)
+	if err != nil {
+		if err == ErrNotFound {
+			return false, nil
+		}
+		d.logger.Error("Enabled: failed to get enrollment", zap.String("key", key), zap.Error(err))
+		return false, err
+	}
+	return enrollment.EnabledAt.Valid, nil
+}
+
+func (d *DefaultService) NewChallenge(ctx context.Context, key string) (string, error) {
+	token, err := pkg.RandomToken(32)
+	if err != nil {
+		return "", errors.New("failed to create challenge:" + err.Error())
+	}
+	now := time.Now().UTC()
+	model := ChallengeStoreModel{
+		Hash:      pkg.HashToken(token),
+		Key:       key,
+		CreatedAt: now,
+		ExpiresAt: now.Add(d.config.ChallengeTTL),
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	if err := d.store.CreateChallenge(dbCtx, &model); err != nil {
+		d.logger.Error("NewChallenge: failed to create challenge", zap.String("key", key), zap.Error(err))
+		return "", errors.New("failed to create challenge:" + err.Error())
+	}
+	return token, nil
+}
+
+func (d *DefaultService) ChallengeKey(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidChallenge
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	challenge, err := d.store.GetChallenge(dbCtx, pkg.HashToken(token))
+	if err != nil {
+		if err == ErrNotFound {
+			return "", ErrInvalidChallenge
+		}
+		d.logger.Error("ChallengeKey: failed to get challenge", zap.Error(err))
+		return "", errors.New("failed to get challenge:" + err.Error())
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return "", ErrInvalidChallenge
+	}
+	return challenge.Key, nil
+}
+
+func (d *DefaultService) CompleteChallenge(ctx context.Context, token, code string) (*ChallengeResult, error) {
+	if token == "" {
+		return nil, ErrInvalidChallenge
+	}
+	hash := pkg.HashToken(token)
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	challenge, err := d.store.GetChallenge(dbCtx, hash)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrInvalidChallenge
+		}
+		d.logger.Error("CompleteChallenge: failed to get challenge", zap.Error(err))
+		return nil, errors.New("failed to verify challenge:" + err.Error())
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidChallenge
+	}
+	enrollment, err := d.getEnrollment(dbCtx, challenge.Key)
+	if err != nil {
+		if err == ErrNotEnrolled {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+
+	res := &ChallengeResult{Key: challenge.Key}
+	if enrollment.EnabledAt.Valid {
+		err = d.verify(dbCtx, enrollment, code)
+	} else {
+		res.RecoveryCodes, err = d.confirm(dbCtx, enrollment, code)
+	}
+	if err == ErrInvalidCode {
+		attempts, failErr := d.store.FailChallenge(dbCtx, hash)
+		if failErr != nil && failErr != ErrNotFound {
+			d.logger.Error("CompleteChallenge: failed to count the attempt", zap.String("key", challenge.Key), zap.Error(failErr))
+		}
+		if attempts >= d.config.MaxAttempts {
+			d.logger.Warn("CompleteChallenge: too many wrong codes, challenge dropped", zap.String("key", challenge.Key))
+			_ = d.store.DeleteChallenge(dbCtx, hash)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the challenge is used once, a concurrent completion wins
+	if err := d.store.DeleteChallenge(dbCtx, hash); err != nil {
+		if err == ErrNotFound {
+			return nil, ErrInvalidChallenge
+		}
+		d.logger.Error("CompleteChallenge: failed to delete challenge", zap.String("key", challenge.Key), zap.Error(err))
+		return nil, errors.New("failed to verify challenge:" + err.Error())
+	}
+	return res, nil
+}
+
+func (d *DefaultService) Cleanup(ctx context.Context) error {
+	dbCtx, cancel := context.WithTimeout(ctx, d.dbTimeOut)
+	defer cancel()
+	n, err := d.store.DeleteExpiredChallenges(dbCtx, time.Now().UTC())
+	if err != nil {
+		d.logger.Error("Cleanup: failed to delete expired challenges", zap.Error(err))
+		return err
+	}
+	if n > 0 {
+		d.logger.Info("Cleanup: deleted expired challenges", zap.Int64("count", n))
+	}
+	return nil
+}
+
+func (d *DefaultService) getEnrollment(ctx context.Context, key string) (*EnrollmentStoreModel, error) {
+	enrollment, err := d.store.GetEnrollment(ctx, key)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrNotEnrolled
+		}
+		d.logger.Error("getEnrollment: failed to get enrollment", zap.String("key", key), zap.Error(err))
+		return nil, errors.New("failed to get enrollment:" + err.Error())
+	}
+	return enrollment, nil
+}
+
+// confirm enables a pending enrollment with its first code
+func (d *DefaultService) confirm(ctx context.Context, enrollment *EnrollmentStoreModel, code string) ([]string, error) {
+	step, ok := Match(enrollment.Secret, code, time.Now(), d.config.Skew)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	if err := d.advance(ctx, enrollment.Key, step); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes(d.config.RecoveryCodes)
+	if err != nil {
+		return nil, errors.New("failed to create recovery codes:" + err.Error())
+	}
+	if err := d.store.EnableEnrollment(ctx, enrollment.Key, hashes, time.Now().UTC()); err != nil {
+		d.logger.Error("confirm: failed to enable enrollment", zap.String("key", enrollment.Key), zap.Error(err))
+		return nil, errors.New("failed to enable two-factor authentication:" + err.Error())
+	}
+	d.logger.Info("confirm: two-factor authentication enabled", zap.String("key", enrollment.Key))
+	return codes, nil
+}
+
+// verify accepts a code of the authenticator or an unused recovery code
+func (d *DefaultService) verify(ctx context.Context, enrollment *EnrollmentStoreModel, code string) error {
+	if step, ok := Match(enrollment.Secret, code, time.Now(), d.config.Skew); ok {
+		return d.advance(ctx, enrollment.Key, step)
+	}
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrInvalidCode
+	}
+	used, err := d.store.UseRecoveryCode(ctx, enrollment.Key, pkg.HashToken(normalized))
+	if err != nil {
+		d.logger.Error("verify: failed to use recovery code", zap.String("key", enrollment.Key), zap.Error(err))
+		return errors.New("failed to verify code:" + err.Error())
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	d.logger.Info("verify: recovery code used", zap.String("key", enrollment.Key))
+	return nil
+}
+
+// advance rejects a code whose step was already used, a code seen once can't be replayed
+func (d *DefaultService) advance(ctx context.Context, key string, step int64) error {
+	advanced, err := d.store.AdvanceStep(ctx, key, step)
+	if err != nil {
+		d.logger.Error("advance: failed to record the code", zap.String("key", key), zap.Error(err))
+		return errors.New("failed to verify code:" + err.Error())
+	}
+	if !advanced {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// newRecoveryCodes returns the codes shown to the owner and the hashes kept in the store
+func newRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, pkg.HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,113 @@
+package totp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// rfcSecret is the SHA-1 secret of the RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		at   int64
+		want string
+	}{
+		{at: 59, want: "287082"},
+		{at: 1111111109, want: "081804"},
+		{at: 1234567890, want: "005924"},
+		{at: 2000000000, want: "279037"},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.at, 0)))
+		assert.Nil(t, err)
+		assert.Equal(t, tt.want, code)
+	}
+
+	step, ok := Match(rfcSecret, "287082", time.Unix(59+Period, 0), 1)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), step)
+	_, ok = Match(rfcSecret, "287082", time.Unix(59+Period*2, 0), 1)
+	assert.False(t, ok)
+}
+
+func currentCode(t *testing.T, secret string, offset int64) string {
+	code, err := Code(secret, Step(time.Now())+offset)
+	assert.Nil(t, err)
+	return code
+}
+
+func TestDefaultService_Enroll(t *testing.T) {
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), DefaultConfig(), time.Second)
+	key := ClientKey("1")
+
+	enrollment, err := d.Enroll(context.TODO(), key, "alice@mail.com")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/GeoGame:alice@mail.com?"))
+	enabled, err := d.Enabled(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, enabled)
+
+	_, err = d.Confirm(context.TODO(), key, "000000")
+	assert.Equal(t, ErrInvalidCode, err)
+	codes, err := d.Confirm(context.TODO(), key, currentCode(t, enrollment.Secret, -1))
+	assert.Nil(t, err)
+	assert.Len(t, codes.Codes, 10)
+	enabled, err = d.Enabled(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.True(t, enabled)
+
+	_, err = d.Enroll(context.TODO(), key, "alice@mail.com")
+	assert.Equal(t, ErrAlreadyEnabled, err)
+
+	// a recovery code works once
+	assert.Equal(t, ErrInvalidCode, d.Disable(context.TODO(), key, "aaaa-aaaa"))
+	assert.Nil(t, d.Disable(context.TODO(), key, strings.ToUpper(codes.Codes[0])))
+	enabled, err = d.Enabled(context.TODO(), key)
+	assert.Nil(t, err)
+	assert.False(t, enabled)
+	assert.Equal(t, ErrNotEnrolled, d.Disable(context.TODO(), key, codes.Codes[1]))
+}
+
+func TestDefaultService_CompleteChallenge(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxAttempts = 2
+	d := NewDefaultService(zap.NewNop(), NewMemStore(), config, time.Second)
+	key := AdminKey("1")
+	enrollment, err := d.Enroll(context.TODO(), key, "admin@mail.com")
+	assert.Nil(t, err)
+
+	// a challenge confirms a pending enrollment and hands out the recovery codes
+	token, err := d.NewChallenge(context.TODO(), key)
+	assert.Nil(t, err)
+	res, err := d.CompleteChallenge(context.TODO(), token, currentCode(t, enrollment.Secret, -1))
+	assert.Nil(t, err)
+	assert.Equal(t, key, res.Key)
+	assert.Len(t, res.RecoveryCodes, 10)
+	_, err = d.CompleteChallenge(context.TODO(), token, currentCode(t, enrollment.Secret, 0))
+	assert.Equal(t, ErrInvalidChallenge, err)
+
+	// a code is not accepted twice
+	token, err = d.NewChallenge(context.TODO(), key)
+	assert.Nil(t, err)
+	_, err = d.CompleteChallenge(context.TODO(), token, currentCode(t, enrollment.Secret, -1))
+	assert.Equal(t, ErrInvalidCode, err)
+	res, err = d.CompleteChallenge(context.TODO(), token, currentCode(t, enrollment.Secret, 0))
+	assert.Nil(t, err)
+	assert.Empty(t, res.RecoveryCodes)
+
+	// the challenge is dropped after too many wrong codes
+	token, err = d.NewChallenge(context.TODO(), key)
+	assert.Nil(t, err)
+	_, err = d.CompleteChallenge(context.TODO(), token, "000000")
+	assert.Equal(t, ErrInvalidCode, err)
+	_, err = d.CompleteChallenge(context.TODO(), token, "000000")
+	assert.Equal(t, ErrInvalidCode, err)
+	_, err = d.CompleteChallenge(context.TODO(), token, currentCode(t, enrollment.Secret, 1))
+	assert.Equal(t, ErrInvalidChallenge, err)
+}
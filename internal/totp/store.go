@@ -0,0 +1,32 @@
+package totp
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by the stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrEnabled is returned when an enabled enrollment would be replaced
+var ErrEnabled = errors.New("enrollment is enabled")
+
+type Store interface {
+	GetEnrollment(ctx context.Context, key string) (*EnrollmentStoreModel, error)
+	// SaveEnrollment creates or replaces the enrollment of the key unless it is enabled
+	SaveEnrollment(ctx context.Context, model *EnrollmentStoreModel) error
+	EnableEnrollment(ctx context.Context, key string, recoveryCodes []string, at time.Time) error
+	DeleteEnrollment(ctx context.Context, key string) error
+	// AdvanceStep records the step of an accepted code, it returns false when the step is not after the last one
+	AdvanceStep(ctx context.Context, key string, step int64) (bool, error)
+	// UseRecoveryCode removes the recovery code, it returns false when the code is not left
+	UseRecoveryCode(ctx context.Context, key, hash string) (bool, error)
+
+	CreateChallenge(ctx context.Context, model *ChallengeStoreModel) error
+	GetChallenge(ctx context.Context, hash string) (*ChallengeStoreModel, error)
+	// FailChallenge counts a wrong code and returns the attempts made
+	FailChallenge(ctx context.Context, hash string) (int, error)
+	DeleteChallenge(ctx context.Context, hash string) error
+	DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error)
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"os"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -11,10 +15,28 @@ import (
 	"go.uber.org/zap/zapcore"
 
 	"geogame/config"
+	"geogame/internal/achievements"
+	"geogame/internal/admins"
+	"geogame/internal/apikeys"
 	"geogame/internal/app"
+	"geogame/internal/chat"
+	"geogame/internal/checkins"
+	"geogame/internal/encounters"
+	"geogame/internal/factions"
+	"geogame/internal/geofences"
+	"geogame/internal/items"
 	"geogame/internal/locations"
+	"geogame/internal/lockout"
+	"geogame/internal/mailer"
 	"geogame/internal/middleware"
+	"geogame/internal/notifications"
+	"geogame/internal/oidc"
+	"geogame/internal/passwords"
 	"geogame/internal/players"
+	"geogame/internal/push"
+	"geogame/internal/quests"
+	"geogame/internal/realtime"
+	"geogame/internal/totp"
 	"geogame/pkg"
 )
 
@@ -29,7 +51,9 @@ func main() {
 	logger := loggerSetup(cfg)
 
 	// middleware authentication setup
-	auther := middleware.NewJwtKey(cfg.TokenSecret)
+	keyConfig := &middleware.KeyConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(keyConfig))
+	auther, keyWorker := newJwtKey(cfg, keyConfig)
 
 	// postgres setup
 	pgConfig := &pkg.Config{}
@@ -42,16 +66,216 @@ func main() {
 	locationsStore := newLocationsStore(cfg, pgWorker.DB(), logger)
 	locationsSvc := locations.NewDefaultService(logger, locationsStore)
 
+	// mailer setup
+	mailerConfig := &mailer.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(mailerConfig))
+	playersMailer := newMailer(cfg, mailerConfig, logger)
+
+	// setup achievements, they listen to the players and check-ins
+	checkInsStore := newCheckInsStore(cfg, pgWorker.DB(), logger)
+	achievementsConfig := achievements.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&achievementsConfig))
+	achievementsSvc := achievements.NewDefaultService(logger, newAchievementsStore(cfg, pgWorker.DB(), logger),
+		checkins.NewHistory(logger, checkInsStore, cfg.DBTimeOut), achievementsConfig, cfg.DBTimeOut)
+
+	// setup the websocket gateway, it pushes the events of the services below
+	gatewayConfig := realtime.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&gatewayConfig))
+	gateway := realtime.NewGateway(logger, gatewayConfig)
+
+	// setup notifications and their push delivery
+	pushConfig := &push.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(pushConfig))
+	notificationsConfig := notifications.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&notificationsConfig))
+	notificationsSvc := notifications.NewDefaultService(logger, newNotificationsStore(cfg, pgWorker.DB(), logger),
+		newPushSender(cfg, pushConfig), notificationsConfig, cfg.DBTimeOut)
+	deliveryWorker := pkg.NewTickerWorker("notification-delivery", notificationsConfig.DeliveryInterval, notificationsSvc.Deliver)
+
 	// setup players service
+	verificationConfig := &players.VerificationConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(verificationConfig))
+	sessionConfig := &players.SessionConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(sessionConfig))
+	guestConfig := players.GuestConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(&guestConfig))
+	handleConfig := players.HandleConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(&handleConfig))
+	lockoutConfig := lockout.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&lockoutConfig))
+	lockoutSvc := lockout.NewDefaultService(logger, newLockoutStore(cfg, pgWorker.DB(), logger), lockoutConfig, cfg.DBTimeOut)
+	totpConfig := totp.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&totpConfig))
+	totpSvc := totp.NewDefaultService(logger, newTOTPStore(cfg, pgWorker.DB(), logger), totpConfig, cfg.DBTimeOut)
+	passwordsConfig := passwords.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&passwordsConfig))
+	hasher, err := passwords.NewHasher(passwordsConfig)
+	if err != nil {
+		log.Fatalf("failed to create password hasher : %v", err)
+	}
+	policyConfig := passwords.PolicyConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(&policyConfig))
 	playersStore := newPlayersStore(cfg, pgWorker.DB(), logger)
-	playersSvc := players.NewDefaultService(logger, playersStore, cfg.DBTimeOut, cfg.TokenSecret)
+	encountersConfig := encounters.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&encountersConfig))
+	encountersSvc := encounters.NewDefaultService(logger, newEncountersStore(cfg, pgWorker.DB(), logger), playersStore, encountersConfig, cfg.DBTimeOut,
+		encounters.WithListener(gateway),
+		encounters.WithListener(notificationsSvc),
+	)
+	geofencesConfig := geofences.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&geofencesConfig))
+	geofencesSvc := geofences.NewDefaultService(logger, newGeofencesStore(cfg, pgWorker.DB(), logger), locationsSvc, geofencesConfig, cfg.DBTimeOut,
+		geofences.WithSubscriber(gateway),
+		geofences.WithSubscriber(notificationsSvc),
+	)
+	encountersWorker := pkg.NewTickerWorker("encounters-eviction", encountersConfig.Window, encountersSvc.Evict)
+	playersSvc := players.NewDefaultService(logger, playersStore, cfg.DBTimeOut, cfg.TokenSecret,
+		players.WithMailer(playersMailer),
+		players.WithVerificationConfig(*verificationConfig),
+		players.WithSessionConfig(*sessionConfig),
+		players.WithGuestConfig(guestConfig),
+		players.WithHandleConfig(handleConfig),
+		players.WithTokenIssuer(auther),
+		players.WithLockout(lockoutSvc),
+		players.WithTwoFactor(totpSvc),
+		players.WithHasher(hasher),
+		players.WithPasswordPolicy(policyConfig),
+		players.WithLocationListener(achievementsSvc),
+		players.WithLocationListener(encountersSvc),
+		players.WithLocationListener(geofencesSvc),
+		players.WithLocationListener(gateway),
+	)
+	gateway.Handle(realtime.TypePosition, realtime.PositionHandler(playersSvc))
+	revocationCache := middleware.NewRevocationCache(playersSvc, sessionConfig.RevocationCacheTTL)
+	standingCache := middleware.NewStandingCache(playersSvc, sessionConfig.StandingCacheTTL)
+	cleanupWorker := pkg.NewTickerWorker("unverified-cleanup", verificationConfig.CleanupInterval, func(ctx context.Context) error {
+		_, err := playersSvc.CleanupUnverified(ctx)
+		return err
+	})
+	guestCleanupWorker := pkg.NewTickerWorker("guest-cleanup", guestConfig.CleanupInterval, func(ctx context.Context) error {
+		_, err := playersSvc.CleanupGuests(ctx)
+		return err
+	})
+	lockoutCleanupWorker := pkg.NewTickerWorker("login-attempts-cleanup", time.Hour, func(ctx context.Context) error {
+		_, err := lockoutSvc.Cleanup(ctx)
+		return err
+	})
+	totpCleanupWorker := pkg.NewTickerWorker("totp-challenges-cleanup", time.Hour, totpSvc.Cleanup)
+
+	// setup factions, quests and check-ins
+	factionsConfig := factions.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&factionsConfig))
+	factionsSvc := factions.NewDefaultService(logger, newFactionsStore(cfg, pgWorker.DB(), logger), factionsConfig, cfg.DBTimeOut)
+	questsSvc := quests.NewDefaultService(logger, newQuestsStore(cfg, pgWorker.DB(), logger), locationsSvc, cfg.DBTimeOut)
+	checkInsConfig := checkins.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&checkInsConfig))
+	checkInsSvc := checkins.NewDefaultService(logger, checkInsStore, playersSvc, locationsSvc, checkInsConfig, cfg.DBTimeOut,
+		checkins.WithListener(factionsSvc),
+		checkins.WithListener(questsSvc),
+		checkins.WithListener(achievementsSvc),
+	)
+
+	// setup items and the spawner
+	itemsConfig := items.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&itemsConfig))
+	itemsSvc := items.NewDefaultService(logger, newItemsStore(cfg, pgWorker.DB(), logger), playersSvc, locationsSvc, itemsConfig, cfg.DBTimeOut,
+		items.WithSpawnListener(gateway),
+	)
+	spawnerWorker := pkg.NewTickerWorker("item-spawner", itemsConfig.SpawnInterval, itemsSvc.Spawn)
+
+	// setup the location chat, messages stream through the gateway
+	chatConfig := chat.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&chatConfig))
+	chatSvc := chat.NewDefaultService(logger, newChatStore(cfg, pgWorker.DB(), logger), playersStore, checkInsStore, locationsSvc, chatConfig, cfg.DBTimeOut,
+		chat.WithContentFilter(chat.NewWordFilter(chatConfig.BlockedWords)),
+		chat.WithStreamer(gateway),
+	)
+	gateway.Handle(realtime.TypeChatFollow, realtime.FollowHandler(chatSvc))
+	gateway.Handle(realtime.TypeChatUnfollow, realtime.UnfollowHandler(chatSvc))
+	chatCleanupWorker := pkg.NewTickerWorker("chat-cleanup", chatConfig.CleanupInterval, chatSvc.Cleanup)
+
+	// setup the admin accounts, the first superadmin comes from the bootstrap env vars
+	adminsConfig := admins.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&adminsConfig))
+	adminsSvc := admins.NewDefaultService(logger, newAdminsStore(cfg, pgWorker.DB(), logger), auther, cfg.DBTimeOut,
+		admins.WithLockout(lockoutSvc),
+		admins.WithTwoFactor(totpSvc, adminsConfig.TwoFactorRoles),
+		admins.WithHasher(hasher),
+		admins.WithPasswordPolicy(policyConfig),
+	)
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-admin" {
+		if err := bootstrapAdmin(adminsSvc, adminsConfig); err != nil {
+			log.Fatalf("failed to bootstrap admin : %v", err)
+		}
+		return
+	}
+	if adminsConfig.BootstrapEmail != "" {
+		if err := bootstrapAdmin(adminsSvc, adminsConfig); err != nil && err != admins.ErrAlreadyBootstrapped {
+			log.Fatalf("failed to bootstrap admin : %v", err)
+		}
+	}
+	adminRoleCache := middleware.NewAdminRoleCache(adminsSvc, adminsConfig.RoleCacheTTL)
+
+	// setup the API keys of the internal tools
+	apiKeysConfig := apikeys.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&apiKeysConfig))
+	apiKeysSvc := apikeys.NewDefaultService(logger, newAPIKeysStore(cfg, pgWorker.DB(), logger), apiKeysConfig, cfg.DBTimeOut)
+
+	// setup the client certificates of the admin api, the deny list is reloaded while running
+	tlsConfig := &pkg.TLSConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(tlsConfig))
+	clientCertConfig := &middleware.ClientCertConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(clientCertConfig))
+	certOptions, certWorker := newClientCertOptions(tlsConfig, clientCertConfig)
+
+	// setup the login with an OpenID Connect provider, it is enabled by OIDC_ISSUER
+	oidcConfig := oidc.Config{}
+	svc.MustInit(s, svc.LoadFromEnv(&oidcConfig))
+	oidcProviderConfig := &oidc.ProviderConfig{}
+	svc.MustInit(s, svc.LoadFromEnv(oidcProviderConfig))
+	oidcOptions, oidcWorker := newOIDCOptions(cfg, oidcConfig, oidcProviderConfig, pgWorker.DB(), playersSvc, logger)
 
 	// init controller
-	controller := app.NewController(logger, locationsSvc, playersSvc, auther)
-	HTTPWorker := pkg.NewChiWorker(controller)
+	controllerOptions := []app.Option{
+		app.WithRevocationCache(revocationCache),
+		app.WithStandingCache(standingCache),
+		app.WithCheckIns(checkInsSvc),
+		app.WithFactions(factionsSvc),
+		app.WithQuests(questsSvc),
+		app.WithItems(itemsSvc),
+		app.WithAchievements(achievementsSvc),
+		app.WithEncounters(encountersSvc),
+		app.WithGeofences(geofencesSvc),
+		app.WithGateway(gateway),
+		app.WithNotifications(notificationsSvc),
+		app.WithChat(chatSvc),
+		app.WithAdmins(adminsSvc),
+		app.WithAdminRoleCache(adminRoleCache),
+		app.WithAPIKeys(apiKeysSvc),
+	}
+	controllerOptions = append(controllerOptions, oidcOptions...)
+	controller := app.NewController(logger, locationsSvc, playersSvc, auther, append(controllerOptions, certOptions...)...)
+	HTTPWorker := pkg.NewChiWorker(controller, pkg.WithTLS(tlsConfig))
 
 	s.AddWorker("pg-worker", pgWorker)
 	s.AddWorker("http-worker", HTTPWorker)
+	s.AddWorker("unverified-cleanup-worker", cleanupWorker)
+	s.AddWorker("guest-cleanup-worker", guestCleanupWorker)
+	s.AddWorker("login-attempts-cleanup-worker", lockoutCleanupWorker)
+	s.AddWorker("totp-challenges-cleanup-worker", totpCleanupWorker)
+	s.AddWorker("item-spawner-worker", spawnerWorker)
+	s.AddWorker("encounters-eviction-worker", encountersWorker)
+	s.AddWorker("notification-delivery-worker", deliveryWorker)
+	s.AddWorker("chat-cleanup-worker", chatCleanupWorker)
+	if keyWorker != nil {
+		s.AddWorker("jwt-key-worker", keyWorker)
+	}
+	if certWorker != nil {
+		s.AddWorker("cert-deny-list-worker", certWorker)
+	}
+	if oidcWorker != nil {
+		s.AddWorker("oidc-cleanup-worker", oidcWorker)
+	}
 	s.Run()
 }
 
@@ -62,6 +286,77 @@ func newPlayersStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) player
 	return players.NewPostgres(db, logger)
 }
 
+// newJwtKey uses the asymmetric key ring when it is configured, otherwise the shared token secret
+func newJwtKey(cfg *config.Config, keyConfig *middleware.KeyConfig) (*middleware.JwtKey, *pkg.TickerWorker) {
+	if !keyConfig.Enabled() {
+		return middleware.NewJwtKey(cfg.TokenSecret), nil
+	}
+	manager, err := middleware.NewKeyManager(keyConfig)
+	if err != nil {
+		log.Fatalf("failed to load jwt keys : %v", err)
+	}
+	worker := pkg.NewTickerWorker("jwt-keys", keyConfig.ReloadInterval, func(ctx context.Context) error {
+		return manager.Refresh()
+	})
+	return manager.Ring(), worker
+}
+
+func newMailer(cfg *config.Config, mailerConfig *mailer.Config, logger *zap.Logger) mailer.Mailer {
+	if cfg.Env == config.EnvDev {
+		return mailer.NewLogMailer(logger)
+	}
+	return mailer.NewSMTPMailer(mailerConfig)
+}
+
+func newLockoutStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) lockout.Store {
+	if cfg.Env == config.EnvDev {
+		return lockout.NewMemStore()
+	}
+	return lockout.NewPostgres(db, logger)
+}
+
+func newTOTPStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) totp.Store {
+	if cfg.Env == config.EnvDev {
+		return totp.NewMemStore()
+	}
+	return totp.NewPostgres(db, logger)
+}
+
+func newCheckInsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) checkins.Store {
+	if cfg.Env == config.EnvDev {
+		return checkins.NewMemStore()
+	}
+	return checkins.NewPostgres(db, logger)
+}
+
+func newFactionsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) factions.Store {
+	if cfg.Env == config.EnvDev {
+		return factions.NewMemStore()
+	}
+	return factions.NewPostgres(db, logger)
+}
+
+func newQuestsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) quests.Store {
+	if cfg.Env == config.EnvDev {
+		return quests.NewMemStore()
+	}
+	return quests.NewPostgres(db, logger)
+}
+
+func newItemsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) items.Store {
+	if cfg.Env == config.EnvDev {
+		return items.NewMemStore()
+	}
+	return items.NewPostgres(db, logger)
+}
+
+func newAchievementsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) achievements.Store {
+	if cfg.Env == config.EnvDev {
+		return achievements.NewMemStore()
+	}
+	return achievements.NewPostgres(db, logger)
+}
+
 func newLocationsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) locations.Store {
 	if cfg.Env == config.EnvDev {
 		return locations.NewMemStore(make(map[interface{}]locations.LocationStoreModel))
@@ -87,3 +382,112 @@ func loggerSetup(cfg *config.Config) *zap.Logger {
 	bb.Warn("logger setup done")
 	return bb
 }
+
+func newEncountersStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) encounters.Store {
+	if cfg.Env == config.EnvDev {
+		return encounters.NewMemStore()
+	}
+	return encounters.NewPostgres(db, logger)
+}
+
+func newGeofencesStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) geofences.Store {
+	if cfg.Env == config.EnvDev {
+		return geofences.NewMemStore()
+	}
+	return geofences.NewPostgres(db, logger)
+}
+
+func newNotificationsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) notifications.Store {
+	if cfg.Env == config.EnvDev {
+		return notifications.NewMemStore()
+	}
+	return notifications.NewPostgres(db, logger)
+}
+
+func newChatStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) chat.Store {
+	if cfg.Env == config.EnvDev {
+		return chat.NewMemStore()
+	}
+	return chat.NewPostgres(db, logger)
+}
+
+func newPushSender(cfg *config.Config, pushConfig *push.Config) push.PushSender {
+	if cfg.Env == config.EnvDev {
+		return push.NewFileSender(pushConfig.FileSink)
+	}
+	router := push.NewRouter()
+	if pushConfig.FCMServerKey != "" {
+		router.Register(push.PlatformFCM, push.NewFCMSender(pushConfig))
+	}
+	if pushConfig.APNsKeyFile != "" {
+		sender, err := push.NewAPNsSender(pushConfig)
+		if err != nil {
+			log.Fatalf("failed to load apns key : %v", err)
+		}
+		router.Register(push.PlatformAPNs, sender)
+	}
+	return router
+}
+
+func newAdminsStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) admins.Store {
+	if cfg.Env == config.EnvDev {
+		return admins.NewMemStore()
+	}
+	return admins.NewPostgres(db, logger)
+}
+
+func newAPIKeysStore(cfg *config.Config, db *sqlx.DB, logger *zap.Logger) apikeys.Store {
+	if cfg.Env == config.EnvDev {
+		return apikeys.NewMemStore()
+	}
+	return apikeys.NewPostgres(db, logger)
+}
+
+// bootstrapAdmin creates the first superadmin, `geogame bootstrap-admin` runs it and exits
+func bootstrapAdmin(adminsSvc admins.Service, adminsConfig admins.Config) error {
+	if adminsConfig.BootstrapEmail == "" || adminsConfig.BootstrapPassword == "" {
+		return errors.New("ADMIN_BOOTSTRAP_EMAIL and ADMIN_BOOTSTRAP_PASSWORD are required")
+	}
+	_, err := adminsSvc.Bootstrap(context.Background(), adminsConfig.BootstrapEmail, adminsConfig.BootstrapPassword)
+	return err
+}
+
+// newClientCertOptions requires the client certificates on the admin routes when they are enabled,
+// they are verified by the TLS handshake so the client CA bundle has to be configured
+func newClientCertOptions(tlsConfig *pkg.TLSConfig, certConfig *middleware.ClientCertConfig) ([]app.Option, *pkg.TickerWorker) {
+	if !certConfig.Required {
+		return nil, nil
+	}
+	if !tlsConfig.Enabled() || tlsConfig.ClientCAFile == "" {
+		log.Fatalf("ADMIN_CLIENT_CERT_REQUIRED needs HTTP_TLS_CERT_FILE, HTTP_TLS_KEY_FILE and HTTP_TLS_CLIENT_CA_FILE")
+	}
+	denyList, err := middleware.NewCertDenyList(certConfig.DenyListFile)
+	if err != nil {
+		log.Fatalf("failed to load certificate deny list : %v", err)
+	}
+	options := []app.Option{app.WithClientCerts(denyList)}
+	if certConfig.DenyListFile == "" {
+		return options, nil
+	}
+	return options, pkg.NewTickerWorker("cert-deny-list", certConfig.ReloadInterval, func(ctx context.Context) error {
+		return denyList.Refresh()
+	})
+}
+
+// newOIDCOptions enables the OpenID Connect login when a provider is configured
+func newOIDCOptions(cfg *config.Config, oidcConfig oidc.Config, providerConfig *oidc.ProviderConfig, db *sqlx.DB, linker oidc.IdentityLinker, logger *zap.Logger) ([]app.Option, *pkg.TickerWorker) {
+	if !providerConfig.Enabled() {
+		return nil, nil
+	}
+	if providerConfig.ClientID == "" {
+		log.Fatalf("OIDC_ISSUER needs OIDC_CLIENT_ID")
+	}
+	var store oidc.Store = oidc.NewPostgres(db, logger)
+	if cfg.Env == config.EnvDev {
+		store = oidc.NewMemStore()
+	}
+	oidcSvc := oidc.NewDefaultService(logger, store, linker, oidcConfig, cfg.DBTimeOut,
+		oidc.WithProvider(oidc.NewProvider(*providerConfig, nil)),
+	)
+	return []app.Option{app.WithOIDC(oidcSvc)}, pkg.NewTickerWorker("oidc-cleanup", oidcConfig.CleanupInterval, oidcSvc.Cleanup)
+}
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX checkins_created_at_id_idx;
+DROP TABLE achievement_progress;
+DROP TABLE achievement_rules;
+
+END;
@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE achievement_rules (
+	id VARCHAR NOT NULL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	description VARCHAR NOT NULL DEFAULT '',
+	criteria JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE achievement_progress (
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	rule_id VARCHAR NOT NULL REFERENCES achievement_rules (id) ON DELETE CASCADE,
+	value DOUBLE PRECISION NOT NULL DEFAULT 0,
+	keys TEXT[] NOT NULL DEFAULT '{}',
+	unlocked_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (client_id, rule_id)
+);
+
+CREATE INDEX achievement_progress_rule_id_idx ON achievement_progress (rule_id);
+
+CREATE INDEX checkins_created_at_id_idx ON checkins (created_at, (id::text));
+
+END;
@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE encounters (
+	id UUID NOT NULL PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	other_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	other_name VARCHAR NOT NULL,
+	point GEOGRAPHY(POINT, 4326) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX encounters_client_id_created_at_idx ON encounters (client_id, created_at);
+
+END;
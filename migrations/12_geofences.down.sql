@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE geofence_events;
+DROP TABLE geofence_states;
+DROP TABLE geofences;
+
+END;
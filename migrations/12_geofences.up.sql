@@ -0,0 +1,40 @@
+BEGIN;
+
+CREATE TABLE geofences (
+	id UUID NOT NULL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	geometry JSONB NOT NULL,
+	triggers TEXT[] NOT NULL,
+	dwell_seconds BIGINT NOT NULL DEFAULT 0,
+	min_lon DOUBLE PRECISION NOT NULL,
+	min_lat DOUBLE PRECISION NOT NULL,
+	max_lon DOUBLE PRECISION NOT NULL,
+	max_lat DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX geofences_bounds_idx ON geofences (min_lon, max_lon, min_lat, max_lat);
+
+CREATE TABLE geofence_states (
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	fence_id UUID NOT NULL REFERENCES geofences (id) ON DELETE CASCADE,
+	inside BOOLEAN NOT NULL DEFAULT false,
+	entered_at TIMESTAMPTZ,
+	dwelled BOOLEAN NOT NULL DEFAULT false,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (client_id, fence_id)
+);
+
+CREATE TABLE geofence_events (
+	id UUID NOT NULL PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	fence_id UUID NOT NULL REFERENCES geofences (id) ON DELETE CASCADE,
+	fence_name VARCHAR NOT NULL,
+	type VARCHAR NOT NULL,
+	point GEOGRAPHY(POINT, 4326) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX geofence_events_client_id_created_at_idx ON geofence_events (client_id, created_at);
+
+END;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE notification_settings;
+DROP TABLE notification_devices;
+DROP TABLE notification_outbox;
+DROP TABLE notifications;
+
+END;
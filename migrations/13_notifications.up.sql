@@ -0,0 +1,50 @@
+BEGIN;
+
+CREATE TABLE notifications (
+	id UUID NOT NULL PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	kind VARCHAR NOT NULL,
+	title VARCHAR NOT NULL,
+	body VARCHAR NOT NULL DEFAULT '',
+	data JSONB NOT NULL DEFAULT '{}',
+	read_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX notifications_client_id_created_at_idx ON notifications (client_id, created_at);
+
+CREATE TABLE notification_outbox (
+	id UUID NOT NULL PRIMARY KEY,
+	notification_id UUID NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	title VARCHAR NOT NULL,
+	body VARCHAR NOT NULL DEFAULT '',
+	data JSONB NOT NULL DEFAULT '{}',
+	status VARCHAR NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL,
+	last_error VARCHAR NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX notification_outbox_status_next_attempt_at_idx ON notification_outbox (status, next_attempt_at);
+
+CREATE TABLE notification_devices (
+	token VARCHAR NOT NULL PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	platform VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX notification_devices_client_id_idx ON notification_devices (client_id);
+
+CREATE TABLE notification_settings (
+	client_id UUID NOT NULL PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+	quiet_start INTEGER NOT NULL DEFAULT 0,
+	quiet_end INTEGER NOT NULL DEFAULT 0,
+	timezone VARCHAR NOT NULL DEFAULT 'UTC',
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE chat_reports;
+DROP TABLE chat_messages;
+
+END;
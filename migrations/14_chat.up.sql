@@ -0,0 +1,26 @@
+BEGIN;
+
+CREATE TABLE chat_messages (
+	id UUID NOT NULL PRIMARY KEY,
+	loc_id VARCHAR NOT NULL,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	name VARCHAR NOT NULL,
+	body VARCHAR NOT NULL,
+	reports INTEGER NOT NULL DEFAULT 0,
+	hidden BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX chat_messages_loc_id_created_at_idx ON chat_messages (loc_id, created_at, id);
+CREATE INDEX chat_messages_expires_at_idx ON chat_messages (expires_at);
+
+CREATE TABLE chat_reports (
+	message_id UUID NOT NULL REFERENCES chat_messages (id) ON DELETE CASCADE,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	reason VARCHAR NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (message_id, client_id)
+);
+
+END;
@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE admins (
+	id UUID NOT NULL PRIMARY KEY,
+	email VARCHAR NOT NULL,
+	password VARCHAR NOT NULL,
+	role VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX admins_email_idx ON admins (lower(email));
+
+END;
@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE api_keys (
+	id UUID NOT NULL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	prefix VARCHAR NOT NULL,
+	hash VARCHAR NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL,
+	allowed_ips TEXT[] NOT NULL DEFAULT '{}',
+	created_by VARCHAR NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	last_used_ip VARCHAR NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE oidc_flows;
+DROP TABLE client_identities;
+
+END;
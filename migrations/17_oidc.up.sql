@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE client_identities (
+	provider VARCHAR NOT NULL,
+	subject VARCHAR NOT NULL,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	email VARCHAR NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX client_identities_client_id_idx ON client_identities (client_id);
+
+CREATE TABLE oidc_flows (
+	state VARCHAR NOT NULL PRIMARY KEY,
+	provider VARCHAR NOT NULL,
+	nonce VARCHAR NOT NULL,
+	verifier VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX oidc_flows_expires_at_idx ON oidc_flows (expires_at);
+
+END;
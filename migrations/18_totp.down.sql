@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE totp_challenges;
+DROP TABLE totp_enrollments;
+
+END;
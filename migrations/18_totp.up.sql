@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE totp_enrollments (
+	key VARCHAR NOT NULL PRIMARY KEY,
+	secret VARCHAR NOT NULL,
+	recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+	last_step BIGINT NOT NULL DEFAULT 0,
+	enabled_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE totp_challenges (
+	hash VARCHAR NOT NULL PRIMARY KEY,
+	key VARCHAR NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX totp_challenges_expires_at_idx ON totp_challenges (expires_at);
+
+END;
@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE email_changes (
+	client_id UUID NOT NULL PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+	new_email VARCHAR NOT NULL,
+	token_hash VARCHAR NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+END;
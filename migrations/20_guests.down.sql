@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE oidc_flows DROP COLUMN client_id;
+
+DROP TABLE guests;
+
+DELETE FROM clients WHERE email IS NULL;
+ALTER TABLE clients ALTER COLUMN email SET NOT NULL;
+
+END;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- guests have no email until they upgrade, the unique constraint allows many NULLs
+ALTER TABLE clients ALTER COLUMN email DROP NOT NULL;
+
+CREATE TABLE guests (
+	client_id UUID NOT NULL PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+	device_id VARCHAR NOT NULL,
+	secret_hash VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+-- set for the flows upgrading a guest instead of signing in
+ALTER TABLE oidc_flows ADD COLUMN client_id VARCHAR NOT NULL DEFAULT '';
+
+END;
@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE chat_messages RENAME COLUMN handle TO name;
+UPDATE chat_messages SET name = clients.name FROM clients WHERE clients.id = chat_messages.client_id;
+
+ALTER TABLE encounters RENAME COLUMN other_handle TO other_name;
+UPDATE encounters SET other_name = clients.name FROM clients WHERE clients.id = encounters.other_id;
+
+DROP TABLE handle_history;
+
+DROP INDEX clients_handle_idx;
+ALTER TABLE clients DROP COLUMN handle_changed_at;
+ALTER TABLE clients DROP COLUMN handle;
+
+END;
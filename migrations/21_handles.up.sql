@@ -0,0 +1,30 @@
+BEGIN;
+
+ALTER TABLE clients ADD COLUMN handle VARCHAR;
+ALTER TABLE clients ADD COLUMN handle_changed_at TIMESTAMPTZ;
+
+-- existing players get the same generated handles as the new ones, the first change has no cooldown
+UPDATE clients SET handle = (CASE WHEN status = 'guest' THEN 'guest_' ELSE 'player_' END)
+	|| substring(replace(id::text, '-', '') FROM 1 FOR 12);
+ALTER TABLE clients ALTER COLUMN handle SET NOT NULL;
+
+-- handles are unique regardless of the case
+CREATE UNIQUE INDEX clients_handle_idx ON clients (lower(handle));
+
+CREATE TABLE handle_history (
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	handle VARCHAR NOT NULL,
+	released_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX handle_history_client_id_idx ON handle_history (client_id);
+CREATE INDEX handle_history_handle_idx ON handle_history (lower(handle), released_at);
+
+-- the social features show handles instead of the names
+ALTER TABLE encounters RENAME COLUMN other_name TO other_handle;
+UPDATE encounters SET other_handle = clients.handle FROM clients WHERE clients.id = encounters.other_id;
+
+ALTER TABLE chat_messages RENAME COLUMN name TO handle;
+UPDATE chat_messages SET handle = clients.handle FROM clients WHERE clients.id = chat_messages.client_id;
+
+END;
@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TABLE player_audit;
+DROP TABLE player_notes;
+
+DROP INDEX clients_handle_pattern_idx;
+DROP INDEX clients_email_pattern_idx;
+
+ALTER TABLE clients DROP COLUMN restriction_reason;
+ALTER TABLE clients DROP COLUMN banned_at;
+ALTER TABLE clients DROP COLUMN suspended_until;
+
+END;
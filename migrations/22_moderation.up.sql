@@ -0,0 +1,34 @@
+BEGIN;
+
+-- a ban has no end, a suspension ends at suspended_until
+ALTER TABLE clients ADD COLUMN suspended_until TIMESTAMPTZ;
+ALTER TABLE clients ADD COLUMN banned_at TIMESTAMPTZ;
+ALTER TABLE clients ADD COLUMN restriction_reason VARCHAR NOT NULL DEFAULT '';
+
+-- the admin search matches the start of the email or the handle
+CREATE INDEX clients_email_pattern_idx ON clients (lower(email) varchar_pattern_ops);
+CREATE INDEX clients_handle_pattern_idx ON clients (lower(handle) varchar_pattern_ops);
+
+CREATE TABLE player_notes (
+	id UUID NOT NULL PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	author VARCHAR NOT NULL,
+	body VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX player_notes_client_id_idx ON player_notes (client_id, created_at);
+
+-- the audit outlives the players, client_id is empty for searches
+CREATE TABLE player_audit (
+	id BIGSERIAL PRIMARY KEY,
+	client_id VARCHAR NOT NULL,
+	actor VARCHAR NOT NULL,
+	action VARCHAR NOT NULL,
+	detail VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX player_audit_client_id_idx ON player_audit (client_id, created_at);
+
+END;
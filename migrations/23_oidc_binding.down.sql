@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE oidc_flows DROP COLUMN binding_hash;
+
+END;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE oidc_flows ADD COLUMN binding_hash VARCHAR NOT NULL DEFAULT '';
+
+END;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DELETE FROM inventory WHERE quantity = 0;
+ALTER TABLE inventory DROP CONSTRAINT inventory_quantity_check;
+ALTER TABLE inventory ADD CONSTRAINT inventory_quantity_check CHECK (quantity > 0);
+
+END;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- the empty entry locked while the first items of a kind are added, it is never committed with 0
+ALTER TABLE inventory DROP CONSTRAINT inventory_quantity_check;
+ALTER TABLE inventory ADD CONSTRAINT inventory_quantity_check CHECK (quantity >= 0);
+
+END;
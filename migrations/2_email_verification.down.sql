@@ -0,0 +1,4 @@
+DROP TABLE email_verifications;
+DROP INDEX IF EXISTS clients_status_created_at_idx;
+ALTER TABLE clients DROP COLUMN created_at;
+ALTER TABLE clients DROP COLUMN status;
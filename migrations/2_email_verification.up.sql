@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE clients ADD COLUMN status VARCHAR NOT NULL DEFAULT 'verified';
+ALTER TABLE clients ALTER COLUMN status SET DEFAULT 'unverified';
+ALTER TABLE clients ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE TABLE email_verifications (
+	client_id UUID NOT NULL PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+	token_hash VARCHAR NOT NULL UNIQUE,
+	sent_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX clients_status_created_at_idx ON clients (status, created_at);
+
+END;
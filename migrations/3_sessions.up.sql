@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE sessions (
+	id UUID NOT NULL PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	family_id UUID NOT NULL,
+	refresh_token_hash VARCHAR NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ,
+	replaced_by UUID
+);
+
+CREATE INDEX sessions_family_id_idx ON sessions (family_id);
+CREATE INDEX sessions_client_id_idx ON sessions (client_id);
+
+END;
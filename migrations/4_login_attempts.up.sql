@@ -0,0 +1,6 @@
+CREATE TABLE login_attempts (
+	key VARCHAR NOT NULL PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failure TIMESTAMPTZ NOT NULL,
+	locked_until TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE blocks;
+DROP TABLE friendships;
+ALTER TABLE clients DROP COLUMN location_sharing;
+ALTER TABLE clients DROP COLUMN loc_updated_at;
+
+END;
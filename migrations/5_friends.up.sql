@@ -0,0 +1,27 @@
+BEGIN;
+
+ALTER TABLE clients ADD COLUMN loc_updated_at TIMESTAMPTZ;
+ALTER TABLE clients ADD COLUMN location_sharing VARCHAR NOT NULL DEFAULT 'friends';
+
+CREATE TABLE friendships (
+	requester_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	addressee_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	status VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (requester_id, addressee_id),
+	CHECK (requester_id <> addressee_id)
+);
+
+-- only one friendship per pair of players, whoever sent the request
+CREATE UNIQUE INDEX friendships_pair_idx ON friendships (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
+CREATE INDEX friendships_addressee_id_idx ON friendships (addressee_id);
+
+CREATE TABLE blocks (
+	blocker_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	blocked_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (blocker_id, blocked_id)
+);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE location_access_audit;
+ALTER TABLE clients DROP COLUMN privacy;
+
+END;
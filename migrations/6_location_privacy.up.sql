@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE clients ADD COLUMN privacy JSONB NOT NULL DEFAULT '{"mode":"exact"}';
+
+CREATE TABLE location_access_audit (
+	id BIGSERIAL PRIMARY KEY,
+	client_id UUID NOT NULL,
+	actor VARCHAR NOT NULL,
+	reason VARCHAR NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX location_access_audit_client_id_idx ON location_access_audit (client_id, created_at);
+
+END;
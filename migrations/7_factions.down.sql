@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE territories;
+DROP TABLE faction_members;
+DROP TABLE factions;
+DROP TABLE checkins;
+
+END;
@@ -0,0 +1,42 @@
+BEGIN;
+
+CREATE TABLE checkins (
+	id UUID NOT NULL PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	loc_id VARCHAR NOT NULL,
+	loc_type VARCHAR,
+	point public.geography(POINT,4326) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX checkins_client_id_loc_id_idx ON checkins (client_id, loc_id, created_at);
+
+CREATE TABLE factions (
+	id VARCHAR NOT NULL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	color VARCHAR NOT NULL
+);
+
+INSERT INTO factions (id, name, color) VALUES
+	('aurora', 'Aurora', '#3d7eff'),
+	('ember', 'Ember', '#ff5a36'),
+	('verdant', 'Verdant', '#2fbf71');
+
+CREATE TABLE faction_members (
+	client_id UUID NOT NULL PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+	faction_id VARCHAR NOT NULL REFERENCES factions (id),
+	joined_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE territories (
+	loc_id VARCHAR NOT NULL PRIMARY KEY,
+	faction_id VARCHAR REFERENCES factions (id),
+	strength DOUBLE PRECISION NOT NULL,
+	point public.geography(POINT,4326) NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	captured_at TIMESTAMPTZ
+);
+
+CREATE INDEX territories_point_idx ON territories USING GIST (point);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE quest_progress;
+DROP TABLE quests;
+
+END;
@@ -0,0 +1,27 @@
+BEGIN;
+
+CREATE TABLE quests (
+	id UUID NOT NULL PRIMARY KEY,
+	title VARCHAR NOT NULL,
+	description VARCHAR NOT NULL DEFAULT '',
+	ordered BOOLEAN NOT NULL DEFAULT FALSE,
+	steps TEXT[] NOT NULL,
+	time_limit_seconds BIGINT NOT NULL DEFAULT 0,
+	prerequisites TEXT[] NOT NULL DEFAULT '{}',
+	reward JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE quest_progress (
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	quest_id UUID NOT NULL REFERENCES quests (id) ON DELETE CASCADE,
+	status VARCHAR NOT NULL,
+	completed_steps TEXT[] NOT NULL DEFAULT '{}',
+	started_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ,
+	completed_at TIMESTAMPTZ,
+	PRIMARY KEY (client_id, quest_id)
+);
+
+END;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE inventory;
+DROP TABLE spawns;
+DROP TABLE spawn_rules;
+DROP TABLE items;
+
+END;
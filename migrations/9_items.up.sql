@@ -0,0 +1,47 @@
+BEGIN;
+
+CREATE TABLE items (
+	id VARCHAR NOT NULL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	description VARCHAR NOT NULL DEFAULT '',
+	stack_limit INTEGER NOT NULL
+);
+
+CREATE TABLE spawn_rules (
+	id UUID NOT NULL PRIMARY KEY,
+	item_id VARCHAR NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+	loc_id VARCHAR,
+	radius DOUBLE PRECISION NOT NULL DEFAULT 0,
+	area JSONB,
+	max_active INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	interval_seconds BIGINT NOT NULL,
+	lifetime_seconds BIGINT NOT NULL,
+	last_spawn_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE spawns (
+	id UUID NOT NULL PRIMARY KEY,
+	rule_id UUID REFERENCES spawn_rules (id) ON DELETE SET NULL,
+	item_id VARCHAR NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+	quantity INTEGER NOT NULL,
+	point public.geography(POINT,4326) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	collected_by UUID,
+	collected_at TIMESTAMPTZ
+);
+
+CREATE INDEX spawns_point_idx ON spawns USING GIST (point);
+CREATE INDEX spawns_rule_id_idx ON spawns (rule_id);
+
+CREATE TABLE inventory (
+	client_id UUID NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+	item_id VARCHAR NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (client_id, item_id)
+);
+
+END;
@@ -2,9 +2,14 @@ package pkg
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/voi-oss/svc"
@@ -14,19 +19,50 @@ import (
 
 var _ svc.Worker = (*ChiWorker)(nil)
 
+// drainTimeout bounds the wait for the hijacked connections on shutdown
+const drainTimeout = 15 * time.Second
+
+// TLSConfig enables HTTPS, with a client CA bundle the certificates presented by the clients are verified
+type TLSConfig struct {
+	CertFile string `env:"HTTP_TLS_CERT_FILE"`
+	KeyFile  string `env:"HTTP_TLS_KEY_FILE"`
+	// ClientCAFile is a PEM bundle of the CAs issuing the client certificates,
+	// a certificate is optional on the handshake and the routes requiring one check it
+	ClientCAFile string `env:"HTTP_TLS_CLIENT_CA_FILE"`
+}
+
+// Enabled reports whether the server certificate is configured
+func (c *TLSConfig) Enabled() bool {
+	return c != nil && c.CertFile != "" && c.KeyFile != ""
+}
+
 type ChiWorker struct {
 	port       int
 	logger     *zap.Logger
 	router     chi.Router
 	controller Controller
 	server     *http.Server
+	tls        *TLSConfig
 }
 
-func NewChiWorker(ctrl Controller) *ChiWorker {
-	return &ChiWorker{
+type ChiOption func(*ChiWorker)
+
+// WithTLS serves HTTPS when the certificate and key are configured
+func WithTLS(config *TLSConfig) ChiOption {
+	return func(c *ChiWorker) {
+		c.tls = config
+	}
+}
+
+func NewChiWorker(ctrl Controller, options ...ChiOption) *ChiWorker {
+	c := &ChiWorker{
 		port:       8080,
 		controller: ctrl,
 	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
 }
 func (c *ChiWorker) Init(logger *zap.Logger) error {
 	c.logger = logger
@@ -45,12 +81,26 @@ func (c *ChiWorker) Init(logger *zap.Logger) error {
 		logger.Error("failed to init controller")
 		return err
 	}
+	if c.tls.Enabled() {
+		tlsConfig, err := newServerTLSConfig(c.tls)
+		if err != nil {
+			logger.Error("failed to setup tls", zap.Error(err))
+			return err
+		}
+		c.server.TLSConfig = tlsConfig
+	}
 	r := chi.NewRouter()
 	if err := c.controller.SetupRouter(r); err != nil {
 		logger.Error("failed to setup router")
 		return err
 	}
 	c.router.Mount("/v1", r)
+	if rc, ok := c.controller.(RootController); ok {
+		if err := rc.SetupRootRouter(c.router); err != nil {
+			logger.Error("failed to setup root router")
+			return err
+		}
+	}
 	return nil
 }
 
@@ -59,8 +109,14 @@ func (c *ChiWorker) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 }
 
 func (c *ChiWorker) Run() error {
-	c.logger.Info("HTTP server running", zap.String("host name", c.server.Addr))
-	if err := c.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	c.logger.Info("HTTP server running", zap.String("host name", c.server.Addr), zap.Bool("tls", c.tls.Enabled()))
+	var err error
+	if c.tls.Enabled() {
+		err = c.server.ListenAndServeTLS(c.tls.CertFile, c.tls.KeyFile)
+	} else {
+		err = c.server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		c.logger.Error("HTTP server failed to RUN")
 		return err
 	}
@@ -71,6 +127,13 @@ func (c *ChiWorker) Terminate() error {
 	if err := c.server.Shutdown(context.Background()); err != nil {
 		c.logger.Warn("server shutdown failed")
 	}
+	if d, ok := c.controller.(Drainer); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		if err := d.Drain(ctx); err != nil {
+			c.logger.Warn("connection drain failed", zap.Error(err))
+		}
+	}
 	return c.controller.Terminate()
 }
 
@@ -80,3 +143,23 @@ func (c *ChiWorker) Healthy() error {
 	}
 	return nil
 }
+
+func newServerTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if config.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+	bundle, err := ioutil.ReadFile(config.ClientCAFile)
+	if err != nil {
+		return nil, errors.New("failed to read client CA bundle:" + err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("no certificate found in the client CA bundle")
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
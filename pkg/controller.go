@@ -3,6 +3,8 @@ package pkg
 
 
 import (
+	"context"
+
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
 )
@@ -11,4 +13,15 @@ type Controller interface {
 	Init(logger *zap.Logger) error
 	SetupRouter(router chi.Router) error
 	Terminate() error
-}
\ No newline at end of file
+}
+
+// RootController is implemented by controllers serving routes outside of the versioned /v1 prefix
+type RootController interface {
+	SetupRootRouter(router chi.Router) error
+}
+
+// Drainer is implemented by controllers holding hijacked connections like websockets,
+// the server shutdown does not wait for them
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
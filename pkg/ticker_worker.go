@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"context"
+	"time"
+
+	"github.com/voi-oss/svc"
+	"go.uber.org/zap"
+)
+
+type TaskFunc func(ctx context.Context) error
+
+var _ svc.Worker = (*TickerWorker)(nil)
+
+// TickerWorker runs a task periodically until it is terminated
+type TickerWorker struct {
+	name     string
+	interval time.Duration
+	task     TaskFunc
+	logger   *zap.Logger
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewTickerWorker(name string, interval time.Duration, task TaskFunc) *TickerWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TickerWorker{
+		name:     name,
+		interval: interval,
+		task:     task,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (t *TickerWorker) Init(logger *zap.Logger) error {
+	t.logger = logger.Named(t.name)
+	return nil
+}
+
+func (t *TickerWorker) Run() error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := t.task(t.ctx); err != nil {
+				t.logger.Error("Run: task failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (t *TickerWorker) Terminate() error {
+	t.cancel()
+	return nil
+}